@@ -0,0 +1,75 @@
+package main
+
+import (
+	"dnsflux/internal/detect/baseline"
+	"dnsflux/pkg/flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// baselineCommand 从已保存的记录学习进程基线并写为 YAML 文件
+func baselineCommand(args []string) error {
+	fs := flag.NewFlagSet("dnsflux baseline", sourceArgs, flag.Text{
+		EN: "Learn a per-process domain baseline from saved records and write it as YAML for review and 'detection.baseline' enforce mode. Records are read like 'dnsflux query' and the same filters apply.",
+		ZH: "从已保存的记录学习每个进程解析的域名基线并写为 YAML 文件，供审阅后用于 'detection.baseline' 强制模式。记录来源与过滤条件同 'dnsflux query'。",
+	})
+	source := addSourceFlags(fs, 0)
+	var path, key string
+	var merge bool
+	fs.StringVar(&path, "output", "o", "", "",
+		flag.Text{EN: "Baseline file, '-' for standard output (required)", ZH: "基线文件，'-' 表示标准输出 (必填)"})
+	fs.StringVar(&key, "key", "k", "", baseline.KeyPath,
+		flag.Text{EN: "Process identity [path, name, sha256]", ZH: "进程标识 [path, name, sha256]"})
+	fs.BoolVar(&merge, "merge", "", "", true,
+		flag.Text{EN: "Add to an existing baseline file instead of replacing it; --merge=false starts from an empty baseline", ZH: "在已有的基线文件上追加而非覆盖，--merge=false 时从空基线开始"})
+	fs.Example(
+		"dnsflux baseline -o baseline.yaml --since 7d",
+		"dnsflux baseline -o baseline.yaml -k sha256 logs/dns_records_2024-05-01.1.json.gz",
+		"dnsflux baseline -o - -P apt,unattended-upgr --server http://127.0.0.1:58080",
+	)
+	if err := parseArgs(fs, args, 0, -1); err != nil {
+		return err
+	}
+	if path == "" {
+		return flag.Usagef("%s", flag.Text{EN: "--output is required", ZH: "必须指定 --output"})
+	}
+	if !slices.Contains(baseline.Keys, key) {
+		return flag.Usagef(flag.Text{EN: "unknown key %q, expected one of %s", ZH: "未知的进程标识 %q，可选 %s"}.String(), key, strings.Join(baseline.Keys, ", "))
+	}
+
+	records, from, err := source.load(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	cfg := baseline.Config{Mode: baseline.ModeLearn, Key: key}
+	if merge && path != "-" {
+		cfg.Path = path
+	}
+	learner, err := baseline.New(cfg)
+	if err != nil {
+		return err
+	}
+	before := len(learner.Baseline().Processes)
+	for i := range records {
+		learner.Observe(&records[i])
+	}
+	b := learner.Baseline()
+
+	if path == "-" {
+		data, err := b.Marshal()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := b.WriteFile(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已从 %s 读取 %d 条记录，将 %d 个进程的基线（新增 %d 个）写入 %s\n",
+		from, len(records), len(b.Processes), len(b.Processes)-before, path)
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"dnsflux/internal/detect/sigma"
+	"dnsflux/pkg/flag"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// 检查结果状态
+const (
+	checkOK   = "OK"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+)
+
+// checkResult 单项检查结果
+type checkResult struct {
+	status string
+	name   flag.Text
+	detail string
+}
+
+// checkCommand 检查运行所需的权限、内核支持、端口与目录，任一项失败时返回错误
+func checkCommand(args []string) error {
+	fs, flags := flag.NewRunFlags("dnsflux check", flag.Text{
+		EN: "Check privileges, kernel support, ports and the output directory before 'dnsflux run'. Accepts the same options as 'dnsflux run'.",
+		ZH: "在 'dnsflux run' 之前检查权限、内核支持、端口与输出目录。选项与 'dnsflux run' 相同。",
+	})
+	fs.Example(
+		"sudo dnsflux check",
+		"dnsflux check -c /etc/dnsflux/config.yaml -w",
+	)
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	var results []checkResult
+	cfg, err := loadConfig(flags)
+	if err != nil {
+		results = append(results, checkResult{checkFail, flag.Text{EN: "Configuration", ZH: "配置"}, err.Error()})
+	} else {
+		results = append(results, checkResult{checkOK, flag.Text{EN: "Configuration", ZH: "配置"}, flags.ConfigPath})
+	}
+
+	results = append(results, platformChecks()...)
+
+	if cfg != nil {
+		if cfg.Web.Enabled {
+			// Web 端口被占用时 run 会改用其他端口，只给出警告
+			addr := net.JoinHostPort(cfg.Web.Addr, strconv.Itoa(cfg.Web.Port))
+			results = append(results, checkListen(flag.Text{EN: "Web port", ZH: "Web 端口"}, addr, checkWarn))
+		}
+		if cfg.Metrics.Addr != "" {
+			results = append(results, checkListen(flag.Text{EN: "Metrics address", ZH: "指标地址"}, cfg.Metrics.Addr, checkFail))
+		}
+		if cfg.Output.Enabled == nil || *cfg.Output.Enabled {
+			results = append(results, checkWritable(flag.Text{EN: "Output directory", ZH: "输出目录"}, cfg.Output.Dir))
+		}
+		if nod := cfg.Detection.NOD; nod.Enabled && nod.Path != "" {
+			results = append(results, checkWritable(flag.Text{EN: "First-seen database directory", ZH: "新域名数据库目录"}, filepath.Dir(nod.Path)))
+		}
+		if len(cfg.Detection.Sigma.Rules) > 0 {
+			results = append(results, checkSigma(cfg.Detection.Sigma))
+		}
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.status == checkFail {
+			failed++
+		}
+		line := fmt.Sprintf("[%-4s] %s", r.status, r.name)
+		if r.detail != "" {
+			line += ": " + r.detail
+		}
+		fmt.Println(line)
+	}
+	if failed > 0 {
+		return fmt.Errorf(flag.Text{EN: "%d check(s) failed", ZH: "%d 项检查未通过"}.String(), failed)
+	}
+	return nil
+}
+
+// checkListen 检查地址是否可以监听，failStatus 为不可监听时的状态
+func checkListen(name flag.Text, addr, failStatus string) checkResult {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return checkResult{failStatus, name, fmt.Sprintf("%s: %v", addr, err)}
+	}
+	ln.Close()
+	return checkResult{checkOK, name, addr}
+}
+
+// checkSigma 检查 Sigma 规则可以加载，部分规则无法加载时只给出警告
+func checkSigma(cfg sigma.Config) checkResult {
+	name := flag.Text{EN: "Sigma rules", ZH: "Sigma 规则"}
+	set, err := sigma.Load(cfg)
+	if err != nil {
+		return checkResult{checkFail, name, err.Error()}
+	}
+	detail := fmt.Sprintf(flag.Text{EN: "%d rule(s) loaded", ZH: "已加载 %d 条规则"}.String(), len(set.Rules))
+	if len(set.Errors) > 0 {
+		detail += fmt.Sprintf(flag.Text{
+			EN: ", %d failed (see 'dnsflux sigma --check')",
+			ZH: "，%d 条无法加载 (运行 'dnsflux sigma --check' 查看)",
+		}.String(), len(set.Errors))
+		return checkResult{checkWarn, name, detail}
+	}
+	return checkResult{checkOK, name, detail}
+}
+
+// checkWritable 检查目录可以写入，目录不存在时检查最近的已存在上级目录能否创建子目录
+// 只写入随即删除的临时文件，不创建目录
+func checkWritable(name flag.Text, dir string) checkResult {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				return checkResult{checkFail, name, fmt.Sprintf(flag.Text{EN: "%s is not a directory", ZH: "%s 不是目录"}.String(), existing)}
+			}
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return checkResult{checkFail, name, err.Error()}
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return checkResult{checkFail, name, err.Error()}
+		}
+		existing = parent
+	}
+
+	file, err := os.CreateTemp(existing, ".dnsflux-check-*")
+	if err != nil {
+		return checkResult{checkFail, name, err.Error()}
+	}
+	file.Close()
+	os.Remove(file.Name())
+
+	if existing != dir {
+		return checkResult{checkOK, name, fmt.Sprintf(flag.Text{EN: "%s (created on start)", ZH: "%s（启动时创建）"}.String(), dir)}
+	}
+	return checkResult{checkOK, name, dir}
+}
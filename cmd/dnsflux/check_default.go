@@ -0,0 +1,13 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"dnsflux/pkg/flag"
+	"runtime"
+)
+
+// platformChecks 不支持的平台无法采集
+func platformChecks() []checkResult {
+	return []checkResult{{checkFail, flag.Text{EN: "Platform", ZH: "平台"}, "当前平台 (" + runtime.GOOS + ") 暂不支持 DNS 采集"}}
+}
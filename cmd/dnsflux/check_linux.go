@@ -0,0 +1,127 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"dnsflux/pkg/flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// eBPF 采集所需的内核函数
+var kprobeSymbols = []string{"udp_sendmsg", "tcp_sendmsg"}
+
+// platformChecks 检查 eBPF 采集所需的权限、BTF、内核版本与内核函数
+func platformChecks() []checkResult {
+	return []checkResult{
+		checkPrivileges(),
+		checkBTF(),
+		checkKernel(),
+		checkSymbols(),
+	}
+}
+
+// checkPrivileges 检查 root 或 CAP_BPF 与 CAP_PERFMON（旧内核为 CAP_SYS_ADMIN）
+func checkPrivileges() checkResult {
+	name := flag.Text{EN: "Privileges", ZH: "权限"}
+	if os.Geteuid() == 0 {
+		return checkResult{checkOK, name, "root"}
+	}
+	caps, err := effectiveCaps()
+	if err != nil {
+		return checkResult{checkFail, name, err.Error()}
+	}
+	has := func(c uint) bool { return caps&(1<<c) != 0 }
+	switch {
+	case has(unix.CAP_BPF) && has(unix.CAP_PERFMON):
+		return checkResult{checkOK, name, "CAP_BPF, CAP_PERFMON"}
+	case has(unix.CAP_SYS_ADMIN):
+		return checkResult{checkOK, name, "CAP_SYS_ADMIN"}
+	default:
+		return checkResult{checkFail, name, "需要 root 或 CAP_BPF 与 CAP_PERFMON 权限"}
+	}
+}
+
+// effectiveCaps 读取当前进程的有效能力集
+func effectiveCaps() (uint64, error) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "CapEff:"); ok {
+			return strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		}
+	}
+	return 0, fmt.Errorf("/proc/self/status 中没有 CapEff")
+}
+
+// checkBTF 检查内核 BTF 信息，CO-RE 程序依赖它完成重定位
+func checkBTF() checkResult {
+	name := flag.Text{EN: "Kernel BTF", ZH: "内核 BTF"}
+	const path = "/sys/kernel/btf/vmlinux"
+	if _, err := os.Stat(path); err != nil {
+		return checkResult{checkFail, name, fmt.Sprintf("%s 不存在，需要启用 CONFIG_DEBUG_INFO_BTF 的内核", path)}
+	}
+	return checkResult{checkOK, name, path}
+}
+
+// checkKernel 检查内核版本不低于 5.8
+func checkKernel() checkResult {
+	name := flag.Text{EN: "Kernel version", ZH: "内核版本"}
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return checkResult{checkFail, name, err.Error()}
+	}
+	release := unix.ByteSliceToString(uts.Release[:])
+
+	var major, minor int
+	fmt.Sscanf(release, "%d.%d", &major, &minor)
+	if major < 5 || major == 5 && minor < 8 {
+		return checkResult{checkFail, name, release + "，需要 5.8 或更高版本"}
+	}
+	return checkResult{checkOK, name, release}
+}
+
+// checkSymbols 检查 kprobe 挂载的内核函数存在
+func checkSymbols() checkResult {
+	name := flag.Text{EN: "Kernel symbols", ZH: "内核函数"}
+	file, err := os.Open("/proc/kallsyms")
+	if err != nil {
+		return checkResult{checkWarn, name, err.Error()}
+	}
+	defer file.Close()
+
+	missing := make(map[string]bool, len(kprobeSymbols))
+	for _, symbol := range kprobeSymbols {
+		missing[symbol] = true
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() && len(missing) > 0 {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 {
+			delete(missing, fields[2])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return checkResult{checkWarn, name, err.Error()}
+	}
+	if len(missing) > 0 {
+		var names []string
+		for _, symbol := range kprobeSymbols {
+			if missing[symbol] {
+				names = append(names, symbol)
+			}
+		}
+		return checkResult{checkFail, name, "缺少 " + strings.Join(names, ", ")}
+	}
+	return checkResult{checkOK, name, strings.Join(kprobeSymbols, ", ")}
+}
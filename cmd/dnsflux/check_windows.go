@@ -0,0 +1,18 @@
+//go:build windows
+
+package main
+
+import (
+	"dnsflux/pkg/flag"
+
+	"golang.org/x/sys/windows"
+)
+
+// platformChecks 检查 ETW 采集所需的管理员权限
+func platformChecks() []checkResult {
+	name := flag.Text{EN: "Administrator", ZH: "管理员权限"}
+	if !windows.GetCurrentProcessToken().IsElevated() {
+		return []checkResult{{checkFail, name, "ETW 会话需要以管理员身份运行"}}
+	}
+	return []checkResult{{checkOK, name, ""}}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"dnsflux/pkg/flag"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// command 子命令
+type command struct {
+	name    string
+	summary flag.Text
+	run     func(args []string) error
+}
+
+// commands 全部子命令，按帮助信息中的顺序排列
+var commands = []command{
+	{"run", flag.Text{EN: "Capture DNS queries (default when no command is given)", ZH: "采集 DNS 查询（未指定子命令时的默认行为）"}, runCommand},
+	{"query", flag.Text{EN: "Search records in JSONL/pcap files or a running instance", ZH: "从 JSONL/pcap 文件或运行中的实例查询记录"}, queryCommand},
+	{"export", flag.Text{EN: "Export records to CSV, JSON, Parquet or pcapng", ZH: "将记录导出为 CSV、JSON、Parquet 或 pcapng"}, exportCommand},
+	{"stats", flag.Text{EN: "Show top domains, processes, query types and response codes", ZH: "统计查询最多的域名、进程、查询类型与响应码"}, statsCommand},
+	{"baseline", flag.Text{EN: "Learn a per-process domain baseline from records", ZH: "从记录学习每个进程解析的域名基线"}, baselineCommand},
+	{"sigma", flag.Text{EN: "Run or check Sigma rules against records", ZH: "对记录运行 Sigma 规则或检查规则"}, sigmaCommand},
+	{"replay", flag.Text{EN: "Replay a pcap/pcapng capture or JSONL file through the outputs", ZH: "将 pcap/pcapng 抓包或 JSONL 文件回放到各输出目标"}, replayCommand},
+	{"check", flag.Text{EN: "Check privileges, kernel support and ports before running", ZH: "检查运行所需的权限、内核支持与端口"}, checkCommand},
+	{"version", flag.Text{EN: "Print version information", ZH: "显示版本信息"}, versionCommand},
+}
+
+// execute 根据第一个参数选择子命令并执行，返回进程退出码
+// 第一个参数以 - 开头或没有参数时执行 run，兼容不带子命令的用法
+func execute(args []string) int {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	} else if len(args) > 0 {
+		switch args[0] {
+		case "-h", "-help", "--help":
+			printUsage(os.Stdout)
+			return 0
+		case "-v", "-version", "--version":
+			name, args = "version", args[1:]
+		}
+	}
+
+	if name == "help" {
+		if len(args) == 0 {
+			printUsage(os.Stdout)
+			return 0
+		}
+		name, args = args[0], []string{"--help"}
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(args)
+		var usageErr *flag.UsageError
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.As(err, &usageErr):
+			fmt.Fprintf(os.Stderr, "dnsflux %s: %v\n%s\n", name, err,
+				fmt.Sprintf(flag.Text{EN: "Run 'dnsflux %s --help' for usage.", ZH: "运行 'dnsflux %s --help' 查看用法。"}.String(), name))
+			return 2
+		default:
+			fmt.Fprintf(os.Stderr, "dnsflux %s: %v\n", name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, flag.Text{EN: "dnsflux: unknown command %q\n\n", ZH: "dnsflux: 未知的子命令 %q\n\n"}.String(), name)
+	printUsage(os.Stderr)
+	return 2
+}
+
+// parseArgs 解析子命令参数并检查位置参数个数，max 小于 0 表示不限制
+// -h/--help 时输出帮助信息并返回 flag.ErrHelp
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs.PrintUsage(os.Stdout)
+		}
+		return err
+	}
+	n := len(fs.Args())
+	switch {
+	case n < min:
+		return flag.Usagef("%s", flag.Text{EN: "missing arguments", ZH: "缺少参数"})
+	case max >= 0 && n > max:
+		return flag.Usagef(flag.Text{EN: "unexpected argument %q", ZH: "多余的参数 %q"}.String(), fs.Args()[max])
+	}
+	return nil
+}
+
+// printUsage 输出子命令列表
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "%s\n\n", flag.Text{
+		EN: "DNSFlux - process-level DNS monitoring",
+		ZH: "DNSFlux - 进程级 DNS 监控工具",
+	})
+	fmt.Fprintf(w, "%s: dnsflux <%s> [%s]\n\n", flag.Text{EN: "Usage", ZH: "用法"},
+		flag.Text{EN: "command", ZH: "子命令"}, flag.Text{EN: "options", ZH: "选项"})
+	fmt.Fprintf(w, "%s:\n", flag.Text{EN: "Commands", ZH: "子命令"})
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(tw, "  help\t%s\n", flag.Text{EN: "Show help for a command", ZH: "显示子命令的帮助信息"})
+	tw.Flush()
+	fmt.Fprintf(w, "\n%s\n", flag.Text{
+		EN: "Run 'dnsflux <command> --help' for the options of a command. Help is shown in English when the first of LC_ALL, LC_MESSAGES and LANG that is set does not start with zh, and in Chinese otherwise (override with DNSFLUX_LANG).",
+		ZH: "运行 'dnsflux <子命令> --help' 查看子命令的选项。LC_ALL、LC_MESSAGES、LANG 中第一个已设置的值不以 zh 开头时显示英文帮助，否则显示中文（可通过 DNSFLUX_LANG 指定）。",
+	})
+}
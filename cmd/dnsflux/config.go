@@ -0,0 +1,281 @@
+package main
+
+import (
+	"dnsflux/internal/collector"
+	"dnsflux/internal/config"
+	"dnsflux/internal/detect"
+	"dnsflux/internal/detect/baseline"
+	"dnsflux/internal/detect/beacon"
+	"dnsflux/internal/detect/nod"
+	"dnsflux/internal/metrics"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/store"
+	"dnsflux/internal/utils"
+	"dnsflux/pkg/flag"
+	"dnsflux/pkg/logger"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// configLog 配置加载日志
+var configLog = logger.Component("config")
+
+// loadConfig 读取配置文件，以显式指定的命令行参数与环境变量覆盖后校验
+func loadConfig(flags *flag.Config) (*config.Config, error) {
+	cfg, err := config.Load(flags.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyFlags(cfg, flags); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("配置无效:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// applyFlags 将显式指定的参数写入配置，--sink 定义的输出目标追加在配置文件的输出目标之后
+func applyFlags(cfg *config.Config, flags *flag.Config) error {
+	set := flags.IsSet
+	if set("log-level") {
+		cfg.Log.Level = flags.LogLevel
+	}
+	if set("log-format") {
+		cfg.Log.Format = flags.LogFormat
+	}
+	if set("log-stream") {
+		cfg.Log.Stream = flags.LogStream
+	}
+	if set("log-file") {
+		cfg.Log.File = flags.LogFile
+	}
+	if set("log-max-size") {
+		cfg.Log.MaxSizeMB = flags.LogMaxSize
+	}
+	if set("log-max-files") {
+		cfg.Log.MaxFiles = flags.LogMaxFiles
+	}
+	if set("timezone") {
+		cfg.Timezone = flags.Timezone
+	}
+	if set("quiet") {
+		cfg.Console.Quiet = flags.Quiet
+	}
+	if set("console-format") {
+		cfg.Console.Format = flags.ConsoleFormat
+	}
+	if set("console-template") {
+		cfg.Console.Template = flags.ConsoleTemplate
+	}
+	if set("color") {
+		cfg.Console.Color = flags.Color
+	}
+	if set("output-dir") {
+		cfg.Output.Dir = flags.OutputDir
+	}
+	if set("output-max-size") {
+		cfg.Output.MaxSizeMB = flags.OutputMaxSize
+	}
+	if set("output-max-age") {
+		cfg.Output.MaxAgeDays = flags.OutputMaxAge
+	}
+	if set("output-max-total") {
+		cfg.Output.MaxTotalSizeMB = flags.OutputMaxTotal
+	}
+	if set("output-compress") {
+		cfg.Output.Compress = flags.OutputCompress
+	}
+	if set("output-tz") {
+		cfg.Output.Timezone = flags.OutputTimezone
+	}
+	if set("metrics-addr") {
+		cfg.Metrics.Addr = flags.MetricsAddr
+	}
+	if set("metrics-top-processes") {
+		cfg.Metrics.TopProcesses = flags.MetricsTopProcesses
+	}
+	if set("web") {
+		cfg.Web.Enabled = flags.EnableWeb
+	}
+	if set("addr") {
+		cfg.Web.Addr = flags.ListenAddr
+	}
+	if set("port") {
+		cfg.Web.Port = flags.ListenPort
+	}
+
+	for _, spec := range flags.Sinks {
+		sinkConfig, err := output.ParseSinkSpec(spec)
+		if err != nil {
+			return err
+		}
+		cfg.Sinks = append(cfg.Sinks, sinkConfig)
+	}
+	return nil
+}
+
+// app 运行中可重新加载的配置状态
+// 记录处理协程通过原子指针读取过滤与附加信息配置，重新加载不会阻塞采集
+type app struct {
+	flags   *flag.Config
+	outputs *output.Manager
+	alerts  store.AlertStore
+
+	mu      sync.Mutex
+	current *config.Config
+
+	filter     atomic.Pointer[output.Filter]
+	enrichment atomic.Pointer[config.EnrichmentConfig]
+	detection  atomic.Pointer[detect.Engine]
+}
+
+// newApp 创建运行状态并应用初始配置
+func newApp(flags *flag.Config, outputs *output.Manager, alerts store.AlertStore, cfg *config.Config) (*app, error) {
+	a := &app{flags: flags, outputs: outputs, alerts: alerts}
+	if err := a.apply(cfg); err != nil {
+		return nil, err
+	}
+	a.current = cfg
+	return a, nil
+}
+
+// apply 应用配置中可在运行时修改的部分
+// 先校验时区、日志配置并创建检测引擎与输出目标，任一失败时返回错误且当前配置保持不变；
+// 输出目标切换后的关闭或重建错误以 *output.ApplyError 返回，此时其余配置已经生效
+func (a *app) apply(cfg *config.Config) error {
+	location, err := utils.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return err
+	}
+	logging, err := logger.Prepare(cfg.Log.Options())
+	if err != nil {
+		return err
+	}
+	current := a.detection.Load()
+	engine, err := detect.NewEngine(cfg.Detection, current)
+	if err != nil {
+		logging.Discard()
+		return err
+	}
+	err = a.outputs.Apply(cfg.SinkConfigs())
+	if err != nil && !applied(err) {
+		engine.Retire(current)
+		logging.Discard()
+		return err
+	}
+
+	a.detection.Store(engine)
+	current.Retire(engine)
+	logging.Apply()
+	utils.SetDisplayLocation(location)
+	logger.SetLocation(location)
+
+	filter := cfg.Filter
+	a.filter.Store(&filter)
+	enrichment := cfg.Enrichment
+	a.enrichment.Store(&enrichment)
+	collector.Configure(cfg.Collector)
+	metrics.SetProcessTopN(cfg.Metrics.TopProcesses)
+	return err
+}
+
+// applied 判断 apply 返回的错误是否发生在新配置生效之后
+func applied(err error) bool {
+	var applyErr *output.ApplyError
+	return errors.As(err, &applyErr)
+}
+
+// reload 重新读取配置文件并应用，配置无效时返回错误且当前配置保持不变
+func (a *app) reload() error {
+	if a.flags.ConfigPath == "" {
+		return errors.New("未指定配置文件 (--config)，无法重新加载")
+	}
+	cfg, err := loadConfig(a.flags)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err = a.apply(cfg)
+	if err != nil && !applied(err) {
+		return err
+	}
+	for _, name := range restartRequired(a.current, cfg) {
+		configLog.Warn(fmt.Sprintf("配置项 %s 已修改，需要重启后生效", name))
+	}
+	a.current = cfg
+	if err != nil {
+		return fmt.Errorf("已重新加载配置文件 %s，但部分输出目标未按新配置生效: %w", a.flags.ConfigPath, err)
+	}
+	configLog.Info(fmt.Sprintf("已重新加载配置文件 %s", a.flags.ConfigPath))
+	return nil
+}
+
+// restartRequired 返回两份配置中不支持运行时修改且发生变化的配置项
+func restartRequired(old, cfg *config.Config) []string {
+	var changed []string
+	if old.Store != cfg.Store {
+		changed = append(changed, "store")
+	}
+	if old.Alerts != cfg.Alerts {
+		changed = append(changed, "alerts")
+	}
+	if old.Web != cfg.Web {
+		changed = append(changed, "web")
+	}
+	if old.Metrics.Addr != cfg.Metrics.Addr {
+		changed = append(changed, "metrics.addr")
+	}
+	return changed
+}
+
+// process 为记录应用附加信息配置，通过全局过滤的记录再运行检测器，返回记录是否通过全局过滤
+func (a *app) process(record *model.DNSRecord) bool {
+	a.enrichment.Load().Enrich(record)
+	if !a.filter.Load().Match(record) {
+		return false
+	}
+	a.detection.Load().Process(record)
+	return true
+}
+
+// raise 将记录上的检测器告警加入告警存储并记下合并到的告警 ID，返回新建的告警
+// 去重窗口内合并到已有告警的不再返回，调用方只需通知新建的告警
+func (a *app) raise(record *model.DNSRecord) []model.Alert {
+	var created []model.Alert
+	for i := range record.Alerts {
+		alert, ok := a.alerts.Raise(detect.NewAlert(record, record.Alerts[i]))
+		record.Alerts[i].AlertID = alert.ID
+		if ok {
+			created = append(created, alert)
+		}
+	}
+	return created
+}
+
+// beacons 返回当前检测引擎的心跳检测结果
+func (a *app) beacons(all bool) []beacon.Candidate {
+	return a.detection.Load().Beacons(all)
+}
+
+// newlyObserved 返回当前检测引擎记录的新域名与数据库状态
+func (a *app) newlyObserved(since time.Time, scope string) ([]nod.Observation, nod.Status, bool) {
+	return a.detection.Load().NewlyObserved(since, scope)
+}
+
+// baseline 返回当前检测引擎的进程基线
+func (a *app) baseline() (*baseline.Baseline, bool) {
+	return a.detection.Load().Baseline()
+}
+
+// close 停止检测器的后台任务
+func (a *app) close() {
+	a.detection.Load().Retire(nil)
+}
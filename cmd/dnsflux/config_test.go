@@ -0,0 +1,199 @@
+package main
+
+import (
+	"dnsflux/internal/config"
+	"dnsflux/internal/output"
+	"dnsflux/internal/store/memory"
+	"dnsflux/pkg/flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// writeConfig 在临时目录中写入配置文件并返回路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dnsflux.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// parseFlags 按 run 子命令解析参数，环境变量在定义参数时读取，需在调用前设置
+func parseFlags(t *testing.T, args ...string) *flag.Config {
+	t.Helper()
+	fs, flags := flag.NewRunFlags("run", flag.Text{})
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	file := writeConfig(t, "log:\n  level: warn\nweb:\n  port: 8081\n")
+	tests := []struct {
+		name      string
+		file      bool
+		env       map[string]string
+		args      []string
+		wantLevel string
+		wantPort  int
+	}{
+		{"默认值", false, nil, nil, "info", 58080},
+		{"配置文件覆盖默认值", true, nil, nil, "warn", 8081},
+		{"环境变量覆盖配置文件", true, map[string]string{"DNSFLUX_LOG_LEVEL": "error", "DNSFLUX_PORT": "8082"}, nil, "error", 8082},
+		{"命令行覆盖环境变量", true, map[string]string{"DNSFLUX_LOG_LEVEL": "error", "DNSFLUX_PORT": "8082"}, []string{"--log-level", "debug", "-p", "8083"}, "debug", 8083},
+		{"未指定的项保留配置文件的值", true, map[string]string{"DNSFLUX_LOG_LEVEL": "error"}, nil, "error", 8081},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"DNSFLUX_CONFIG", "DNSFLUX_LOG_LEVEL", "DNSFLUX_PORT"} {
+				t.Setenv(key, tt.env[key])
+			}
+			args := tt.args
+			if tt.file {
+				args = append([]string{"--config", file}, args...)
+			}
+			cfg, err := loadConfig(parseFlags(t, args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Log.Level != tt.wantLevel || cfg.Web.Port != tt.wantPort {
+				t.Errorf("log.level = %s, web.port = %d, want %s, %d", cfg.Log.Level, cfg.Web.Port, tt.wantLevel, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestConfigValidationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     string
+		want    []string
+	}{
+		{
+			"配置文件中的多个错误",
+			"log:\n  format: xml\nstore:\n  capacity: 0\nsinks:\n  - type: kafka\n  - type: syslog\n    alerts: all\n",
+			"",
+			[]string{"log.format: ", "store.capacity: 必须大于 0", "sinks[0].type: 未知的输出目标类型 \"kafka\"", "sinks[1].alerts: "},
+		},
+		{
+			"环境变量覆盖后校验",
+			"timezone: UTC\n",
+			"Mars/Olympus",
+			[]string{"timezone: "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DNSFLUX_CONFIG", "")
+			t.Setenv("DNSFLUX_TZ", tt.env)
+			_, err := loadConfig(parseFlags(t, "--config", writeConfig(t, tt.content)))
+			if err == nil {
+				t.Fatal("配置无效时应返回错误")
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if lines[0] != "配置无效:" {
+				t.Errorf("错误首行 = %q", lines[0])
+			}
+			for _, want := range tt.want {
+				found := false
+				for _, line := range lines[1:] {
+					found = found || strings.HasPrefix(line, want)
+				}
+				if !found {
+					t.Errorf("错误中缺少以 %q 开头的一行:\n%v", want, err)
+				}
+			}
+			if got := len(lines) - 1; got != len(tt.want) {
+				t.Errorf("got %d 条错误, want %d:\n%v", got, len(tt.want), err)
+			}
+		})
+	}
+}
+
+// testApp 使用不写文件的最小配置创建运行状态
+func testApp(t *testing.T) (*app, *config.Config) {
+	t.Helper()
+	cfg := config.Default()
+	disabled := false
+	cfg.Output.Enabled = &disabled
+	cfg.Console.Quiet = true
+	cfg.Log.Stream = "stderr"
+	cfg.Timezone = "UTC"
+	cfg.Detection.NOD.Enabled = false
+	cfg.Detection.Baseline.Enabled = false
+
+	outputs := output.NewManager()
+	alerts := memory.NewAlerts(memory.AlertOptions{Capacity: 10, Window: time.Hour, MaxRecords: 10})
+	a, err := newApp(&flag.Config{}, outputs, alerts, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		a.close()
+		outputs.Close()
+		alerts.Close()
+	})
+	return a, cfg
+}
+
+func TestApplyUnchangedOnError(t *testing.T) {
+	level := logrus.GetLevel()
+	t.Cleanup(func() { logrus.SetLevel(level) })
+
+	// 普通文件下无法创建输出文件
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		modify func(cfg *config.Config)
+	}{
+		{"时区无效", func(cfg *config.Config) { cfg.Timezone = "Mars/Olympus" }},
+		{"日志输出位置无效", func(cfg *config.Config) { cfg.Log.Stream = "printer" }},
+		{"日志文件无法打开", func(cfg *config.Config) { cfg.Log.File = filepath.Join(blocker, "dnsflux.log") }},
+		{"输出目标创建失败", func(cfg *config.Config) {
+			cfg.Sinks = []output.SinkConfig{{Type: "pcapng", Options: map[string]any{"path": filepath.Join(blocker, "dns.pcapng")}}}
+		}},
+		{"日志文件已打开但输出目标创建失败", func(cfg *config.Config) {
+			cfg.Log.File = filepath.Join(t.TempDir(), "dnsflux.log")
+			cfg.Sinks = []output.SinkConfig{{Type: "pcapng", Options: map[string]any{"path": filepath.Join(blocker, "dns.pcapng")}}}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, base := testApp(t)
+			logrus.SetLevel(logrus.InfoLevel)
+			engine, filter := a.detection.Load(), a.filter.Load()
+
+			cfg := *base
+			cfg.Log.Level = "debug"
+			cfg.Filter.ExcludeDomains = []string{"example.com"}
+			tt.modify(&cfg)
+			if err := a.apply(&cfg); err == nil {
+				t.Fatal("应用无效配置应返回错误")
+			}
+
+			if a.detection.Load() != engine {
+				t.Error("检测引擎被替换")
+			}
+			if a.filter.Load() != filter {
+				t.Error("过滤配置被替换")
+			}
+			if got := logrus.GetLevel(); got != logrus.InfoLevel {
+				t.Errorf("日志级别被修改为 %s", got)
+			}
+			if health := a.outputs.Health(); len(health) != 0 {
+				t.Errorf("输出目标被修改: %v", health)
+			}
+		})
+	}
+}
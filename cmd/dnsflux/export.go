@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/output/pcapng"
+	"dnsflux/internal/output/tabular"
+	"dnsflux/pkg/flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 导出格式，csv 与 parquet 之外的格式
+const (
+	exportJSON   = "json"
+	exportPcapng = "pcapng"
+)
+
+// exportCommand 按条件读取记录并导出为 CSV、JSON Lines、Parquet 或 pcapng 文件
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("dnsflux export", sourceArgs, flag.Text{
+		EN: "Export DNS records to CSV, JSON Lines, Parquet or pcapng. Records are read like 'dnsflux query' and the same filters apply.",
+		ZH: "将 DNS 记录导出为 CSV、JSON Lines、Parquet 或 pcapng。记录来源与过滤条件同 'dnsflux query'。",
+	})
+	source := addSourceFlags(fs, 0)
+	var path, format, columns, compression string
+	var responses bool
+	fs.StringVar(&path, "output", "o", "", "",
+		flag.Text{EN: "Output file, '-' for standard output (required)", ZH: "导出文件，'-' 表示标准输出 (必填)"})
+	fs.StringVar(&format, "format", "f", "", "",
+		flag.Text{EN: "Export format [csv, json, parquet, pcapng] (default: from the output file extension)", ZH: "导出格式 [csv, json, parquet, pcapng] (默认根据导出文件扩展名)"})
+	fs.StringVar(&columns, "columns", "", "", "",
+		flag.Text{EN: "CSV columns, comma separated (default: all), e.g. timestamp,processName,queryName", ZH: "CSV 列，以逗号分隔 (默认全部)，如 timestamp,processName,queryName"})
+	fs.StringVar(&compression, "compression", "", "", "zstd",
+		flag.Text{EN: "Parquet compression [none, snappy, gzip, zstd]", ZH: "Parquet 压缩算法 [none, snappy, gzip, zstd]"})
+	fs.BoolVar(&responses, "responses", "", "", true,
+		flag.Text{EN: "Write response packets to pcapng; --responses=false writes queries only", ZH: "pcapng 中写入响应包，--responses=false 时只写查询包"})
+	fs.Example(
+		"dnsflux export -o dns.csv --columns timestamp,processName,queryName,rcode",
+		"dnsflux export -o dns.parquet --since 7d",
+		"dnsflux export -o nxdomain.pcapng -r NXDOMAIN logs/dns_records_2024-05-01.1.json.gz",
+		"dnsflux export -o - -f json --server http://127.0.0.1:58080 | jq .queryName",
+	)
+	if err := parseArgs(fs, args, 0, -1); err != nil {
+		return err
+	}
+
+	if path == "" {
+		return flag.Usagef("%s", flag.Text{EN: "--output is required", ZH: "必须指定 --output"})
+	}
+	if format == "" {
+		if path == "-" {
+			return flag.Usagef("%s", flag.Text{EN: "--format is required when writing to standard output", ZH: "输出到标准输出时必须指定 --format"})
+		}
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	opts := tabular.Options{
+		Columns:     tabular.SplitColumns(columns),
+		Compression: compression,
+		CreatedBy:   "dnsflux " + Version,
+	}
+	switch format {
+	case exportJSON, exportPcapng:
+	default:
+		if err := tabular.CheckOptions(format, opts); err != nil {
+			return &flag.UsageError{Err: err}
+		}
+	}
+
+	records, from, err := source.load(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	if path == "-" {
+		buf := bufio.NewWriter(os.Stdout)
+		if err := exportRecords(buf, format, opts, responses, records); err != nil {
+			return err
+		}
+		return buf.Flush()
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建导出文件失败: %w", err)
+	}
+	buf := bufio.NewWriter(file)
+	err = exportRecords(buf, format, opts, responses, records)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "已从 %s 导出 %d 条记录到 %s\n", from, len(records), path)
+	return nil
+}
+
+// exportRecords 将记录写为指定格式
+func exportRecords(w io.Writer, format string, opts tabular.Options, responses bool, records []model.DNSRecord) error {
+	switch format {
+	case exportJSON:
+		formatter, err := output.NewFormatter(output.FormatJSON, "")
+		if err != nil {
+			return err
+		}
+		for i := range records {
+			data, err := formatter.Format(&records[i])
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	case exportPcapng:
+		writer, err := pcapng.NewWriter(w, "dnsflux "+Version, responses)
+		if err != nil {
+			return err
+		}
+		for i := range records {
+			if err := writer.WriteRecord(&records[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return tabular.Export(w, format, records, opts)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"dnsflux/internal/output/console"
+	"dnsflux/pkg/flag"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// queryCommand 按条件查询记录并输出到标准输出
+func queryCommand(args []string) error {
+	fs := flag.NewFlagSet("dnsflux query", sourceArgs, flag.Text{
+		EN: "Search DNS records in JSONL, pcap or pcapng files, the output directory, or a running instance.",
+		ZH: "从 JSONL、pcap、pcapng 文件、输出目录或运行中的实例查询 DNS 记录。",
+	})
+	source := addSourceFlags(fs, 0)
+	var format, template, color string
+	fs.StringVar(&format, "format", "f", "", console.FormatAuto,
+		flag.Text{EN: "Output format [auto, pretty, compact, json, template]; auto is compact in a terminal and json otherwise", ZH: "输出格式 [auto, pretty, compact, json, template]，auto 在终端中为 compact，否则为 json"})
+	fs.StringVar(&template, "template", "", "", "",
+		flag.Text{EN: "Go template for the template format", ZH: "template 格式使用的 Go 模板"})
+	fs.StringVar(&color, "color", "", "", console.ColorAuto,
+		flag.Text{EN: "Color for the compact format [auto, always, never]", ZH: "compact 格式的颜色 [auto, always, never]"})
+	fs.Example(
+		"dnsflux query --since 1h -r NXDOMAIN",
+		"dnsflux query -d example.com -P curl -n 20",
+		"dnsflux query -f json logs/dns_records_2024-05-01.1.json.gz | jq .queryName",
+		"dnsflux query --server http://127.0.0.1:58080 -t AAAA",
+		"dnsflux query capture.pcapng",
+	)
+	if err := parseArgs(fs, args, 0, -1); err != nil {
+		return err
+	}
+
+	if format == console.FormatAuto {
+		format = "json"
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			format = console.FormatCompact
+		}
+	}
+	formatter, err := console.NewFormatter(format, console.Options{Color: color, Template: template}, os.Stdout)
+	if err != nil {
+		return &flag.UsageError{Err: err}
+	}
+
+	records, _, err := source.load(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	for i := range records {
+		data, err := formatter.Format(&records[i])
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(data); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
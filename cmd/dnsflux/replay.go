@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"dnsflux/internal/detect/baseline"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/replay"
+	"dnsflux/internal/store/memory"
+	"dnsflux/pkg/flag"
+	"dnsflux/pkg/logger"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// replayCommand 读取抓包或记录文件，将其中的记录经过滤与附加信息处理后分发到各输出目标
+func replayCommand(args []string) error {
+	fs := flag.NewFlagSet("dnsflux replay", flag.Text{EN: "[options] <file>...", ZH: "[选项] <文件>..."}, flag.Text{
+		EN: "Replay DNS traffic from pcap/pcapng captures or JSONL record files through the configured filter and outputs. Record files are only written when --output-dir is given.",
+		ZH: "将 pcap/pcapng 抓包或 JSONL 记录文件中的 DNS 记录经配置的过滤条件回放到各输出目标。仅在指定 --output-dir 时写入记录文件。",
+	})
+	flags := &flag.Config{}
+	flags.AddConfigFlags(fs)
+	flags.AddLogFlags(fs)
+	flags.AddConsoleFlags(fs)
+	flags.AddOutputFlags(fs)
+	var speed float64
+	fs.Float64Var(&speed, "speed", "", "", 0,
+		flag.Text{EN: "Replay speed relative to the capture, e.g. 1 for real time or 10 for ten times faster; 0 replays as fast as possible", ZH: "相对抓包时间的回放速度，如 1 为实时、10 为十倍速；0 表示尽快回放"})
+	fs.Example(
+		"dnsflux replay capture.pcapng",
+		"dnsflux replay -f compact --speed 1 capture.pcap",
+		"dnsflux replay -q --sink 'syslog?network=udp&address=127.0.0.1:514' logs/dns_records_2024-05-01.1.json.gz",
+	)
+	if err := parseArgs(fs, args, 1, -1); err != nil {
+		return err
+	}
+	if speed < 0 {
+		return flag.Usagef("%s", flag.Text{EN: "--speed must not be negative", ZH: "--speed 不能为负数"})
+	}
+
+	cfg, err := loadConfig(flags)
+	if err != nil {
+		return err
+	}
+	// 回放的记录默认不写入记录文件，避免与采集的记录混在一起
+	enabled := flags.IsSet("output-dir")
+	cfg.Output.Enabled = &enabled
+	// 回放不读写新域名数据库，学习期从回放的第一条记录开始；学习模式的进程基线不写入基线文件
+	cfg.Detection.NOD.Path = ""
+	if cfg.Detection.Baseline.Mode == baseline.ModeLearn {
+		cfg.Detection.Baseline.Path = ""
+	}
+
+	if err := logger.Configure(cfg.Log.Options()); err != nil {
+		return err
+	}
+	defer logger.Close()
+	output.Version = Version
+
+	outputs := output.NewManager()
+	defer outputs.Close()
+	alerts := memory.NewAlerts(cfg.Alerts.Options())
+	defer alerts.Close()
+	app, err := newApp(flags, outputs, alerts, cfg)
+	if err != nil {
+		return err
+	}
+	defer app.close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		count      int
+		start      time.Time
+		firstStamp time.Time
+	)
+	for _, file := range fs.Args() {
+		err := replay.ReadFile(file, func(record model.DNSRecord) error {
+			if speed > 0 {
+				// 按记录时间间隔等待，抓包中的记录可能因配对而略有乱序，早于进度的记录立即输出
+				if start.IsZero() {
+					start, firstStamp = time.Now(), record.Timestamp
+				}
+				due := start.Add(time.Duration(float64(record.Timestamp.Sub(firstStamp)) / speed))
+				if wait := time.Until(due); wait > 0 {
+					select {
+					case <-time.After(wait):
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+			}
+			if !app.process(&record) {
+				return nil
+			}
+			count++
+			raised := app.raise(&record)
+			if err := outputs.DispatchWait(ctx, record); err != nil {
+				return err
+			}
+			for _, alert := range raised {
+				if err := outputs.DispatchAlertWait(ctx, alert); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, context.Canceled) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info(fmt.Sprintf("已回放 %d 条记录", count))
+	return nil
+}
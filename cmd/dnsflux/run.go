@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"dnsflux/internal/collector"
+	"dnsflux/internal/metrics"
+	"dnsflux/internal/output"
+	_ "dnsflux/internal/output/console"
+	_ "dnsflux/internal/output/dnstap"
+	_ "dnsflux/internal/output/httpsink"
+	_ "dnsflux/internal/output/jsonfile"
+	_ "dnsflux/internal/output/otlp"
+	_ "dnsflux/internal/output/pcapng"
+	_ "dnsflux/internal/output/syslog"
+	_ "dnsflux/internal/output/tabular"
+	"dnsflux/internal/store/memory"
+	"dnsflux/internal/utils"
+	"dnsflux/internal/web"
+	"dnsflux/pkg/flag"
+	"dnsflux/pkg/logger"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runCommand 采集 DNS 记录并分发到各输出目标，直到收到中断信号
+func runCommand(args []string) error {
+	fs, flags := flag.NewRunFlags("dnsflux run", flag.Text{
+		EN: "Capture DNS queries and send them to the console, record files, sinks and the web UI.",
+		ZH: "采集 DNS 查询并输出到控制台、记录文件、输出目标与 Web 界面。",
+	})
+	fs.Example(
+		"dnsflux run -a 0.0.0.0 -p 1688 -l info",
+		"dnsflux run -w -q",
+		"dnsflux run -f compact | grep NXDOMAIN",
+		"dnsflux run -c /etc/dnsflux/config.yaml",
+		"dnsflux run -q --log-file=/var/log/dnsflux/dnsflux.log --log-format=json --log-stream=none",
+	)
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	// 加载完整配置：命令行参数、环境变量、配置文件与默认值
+	cfg, err := loadConfig(flags)
+	if err != nil {
+		return err
+	}
+
+	// 初始化日志：级别、格式、控制台输出位置与日志文件
+	if err := logger.Configure(cfg.Log.Options()); err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	log.Info("DNSFlux 启动中...")
+	log.Info(fmt.Sprintf("版本: %s", Version))
+	output.Version = Version
+	if flags.ConfigPath != "" {
+		log.Info(fmt.Sprintf("配置文件: %s", flags.ConfigPath))
+	}
+	// 输出目标选项可能包含令牌与密码，只记录名称与类型
+	log.Info(fmt.Sprintf("输出目标: %s", sinkSummary(cfg.SinkConfigs())))
+
+	// 创建存储
+	store := memory.New(cfg.Store.Capacity)
+	defer store.Close()
+
+	// 创建告警存储
+	alerts := memory.NewAlerts(cfg.Alerts.Options())
+	defer alerts.Close()
+
+	// 创建输出管理器：存储，以及配置中的 JSONL 文件、控制台（--quiet 时不输出）与其他输出目标
+	outputs := output.NewManager()
+	defer outputs.Close()
+
+	outputs.Add(output.NewStoreSink(store), output.SinkConfig{Name: "store", Type: "store"})
+	app, err := newApp(flags, outputs, alerts, cfg)
+	if err != nil {
+		return err
+	}
+	defer app.close()
+
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Prometheus 指标
+	metrics.SetStoreStats(store.Stats)
+	metrics.SetAlertStats(alerts.Stats)
+	metrics.SetSinkHealth(outputs.Health)
+	if cfg.Metrics.Addr != "" {
+		go func() {
+			if err := metrics.Serve(ctx, cfg.Metrics.Addr); err != nil && err != http.ErrServerClosed {
+				log.Error(fmt.Sprintf("指标服务启动失败: %v", err))
+			}
+		}()
+		log.Info(fmt.Sprintf("Prometheus 指标已启动，访问 http://%s/metrics 获取", cfg.Metrics.Addr))
+	}
+
+	// 根据命令行参数决定是否启动 Web 服务器
+	var webServer *web.Server
+	if cfg.Web.Enabled {
+		// 检测并获取可用端口
+		listenPort := utils.FindAvailablePort(cfg.Web.Port, cfg.Web.Addr)
+		if listenPort != cfg.Web.Port {
+			log.Info(fmt.Sprintf("默认端口 %d 被占用，使用端口 %d", cfg.Web.Port, listenPort))
+		}
+
+		// 创建 Web 服务器
+		webServer = web.New(store, cfg.Web.Addr, listenPort)
+		webServer.SetAlertStore(alerts)
+		webServer.SetSinkHealth(outputs.Health)
+		webServer.SetReloadFunc(app.reload)
+		webServer.SetBeaconsFunc(app.beacons)
+		webServer.SetNewlyObservedFunc(app.newlyObserved)
+		webServer.SetBaselineFunc(app.baseline)
+
+		// 启动 Web 服务器
+		go func() {
+			if err := webServer.Start(ctx); err != nil && err != http.ErrServerClosed {
+				log.Error(fmt.Sprintf("Web 服务器启动失败: %v", err))
+			}
+		}()
+
+		log.Info(fmt.Sprintf("DNSFlux Web 面板已启动，访问 http://%s:%d 查看 Web 界面", cfg.Web.Addr, listenPort))
+	}
+	log.Info(fmt.Sprintf("当前平台: %s/%s", runtime.GOOS, runtime.GOARCH))
+
+	// 创建平台采集器
+	platformCollector := collector.NewPlatformCollector()
+	if platformCollector == nil {
+		return fmt.Errorf("当前平台 (%s) 暂不支持 DNS 采集", runtime.GOOS)
+	}
+
+	go func() {
+		if err := platformCollector.Start(ctx); err != nil {
+			log.With("collector", platformCollector.Name()).Error(fmt.Sprintf("采集器启动失败: %v", err))
+			return
+		}
+	}()
+
+	// 订阅采集器数据并分发到各输出目标
+	go func() {
+		ch := platformCollector.Subscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case record, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				// 附加信息处理与全局过滤
+				if !app.process(&record) {
+					continue
+				}
+				metrics.ObserveRecord(&record)
+				raised := app.raise(&record)
+
+				// 分发到存储、控制台、文件等输出目标（非阻塞），新建的告警分发到配置了告警输出的输出目标
+				outputs.Dispatch(record)
+				for _, alert := range raised {
+					metrics.ObserveAlert(&alert)
+					outputs.DispatchAlert(alert)
+				}
+			}
+		}
+	}()
+
+	defer platformCollector.Stop()
+
+	// 等待中断信号，SIGHUP 时重新加载配置文件
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if err := app.reload(); err != nil {
+			log.Error(fmt.Sprintf("重新加载配置失败: %v", err))
+		}
+	}
+
+	// 优雅关闭
+	cancel()
+
+	// 给服务器一些时间来关闭
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+
+	if webServer != nil {
+		if err := webServer.Stop(shutdownCtx); err != nil {
+			log.Error(fmt.Sprintf("Web 服务器关闭失败: %v", err))
+		}
+	}
+
+	log.Info("DNSFlux 已退出")
+	return nil
+}
+
+// sinkSummary 返回已启用输出目标的名称与类型，如 "jsonl(jsonl), siem(syslog)"
+func sinkSummary(configs []output.SinkConfig) string {
+	var parts []string
+	for _, config := range configs {
+		if !config.IsEnabled() {
+			continue
+		}
+		name := config.Name
+		if name == "" {
+			name = config.Type
+		}
+		parts = append(parts, fmt.Sprintf("%s(%s)", name, config.Type))
+	}
+	if len(parts) == 0 {
+		return "无"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"dnsflux/internal/detect/sigma"
+	"dnsflux/internal/utils"
+	"dnsflux/pkg/flag"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// sigmaMatch 一次规则命中，用于 JSON 输出
+type sigmaMatch struct {
+	Time      time.Time `json:"time"`
+	ID        string    `json:"id,omitempty"`
+	Title     string    `json:"title"`
+	Level     string    `json:"level,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Path      string    `json:"path"`
+	Process   string    `json:"process"`
+	QueryName string    `json:"queryName"`
+	QueryType string    `json:"queryType,omitempty"`
+	Aggregate string    `json:"aggregate,omitempty"`
+	Group     string    `json:"group,omitempty"`
+	Value     float64   `json:"value,omitempty"`
+}
+
+// sigmaCommand 对已保存的记录运行 Sigma 规则，或只检查规则能否加载
+func sigmaCommand(args []string) error {
+	fs := flag.NewFlagSet("dnsflux sigma", sourceArgs, flag.Text{
+		EN: "Run Sigma rules with logsource category dns_query or dns over saved records and print the matches, or only check that the rules load with --check. Records are read like 'dnsflux query' and the same filters apply.",
+		ZH: "对已保存的记录运行 logsource category 为 dns_query 或 dns 的 Sigma 规则并输出命中结果，或以 --check 只检查规则能否加载。记录来源与过滤条件同 'dnsflux query'。",
+	})
+	source := addSourceFlags(fs, 0)
+	var rules []string
+	var minLevel, format string
+	var check bool
+	fs.ListVar(&rules, "rules", "R",
+		flag.Text{EN: "Rule file or directory, repeatable (default: detection.sigma.rules of the configuration)", ZH: "规则文件或目录，可重复指定 (默认为配置中的 detection.sigma.rules)"})
+	fs.StringVar(&minLevel, "min-level", "", "", "",
+		flag.Text{EN: "Skip rules below this level [informational, low, medium, high, critical]", ZH: "跳过低于该级别的规则 [informational, low, medium, high, critical]"})
+	fs.BoolVar(&check, "check", "", "", false,
+		flag.Text{EN: "Only load the rules and list them with the rules that fail to load", ZH: "只加载规则，列出规则与无法加载的规则"})
+	fs.StringVar(&format, "format", "f", "", "text",
+		flag.Text{EN: "Output format [text, json]", ZH: "输出格式 [text, json]"})
+	fs.Example(
+		"dnsflux sigma --check -R rules/sigma",
+		"dnsflux sigma -R rules/sigma --since 24h",
+		"dnsflux sigma -R rules/sigma/dns_query --min-level high -f json logs/dns_records_2024-05-01.1.json.gz",
+	)
+	if err := parseArgs(fs, args, 0, -1); err != nil {
+		return err
+	}
+	if format != "text" && format != "json" {
+		return flag.Usagef(flag.Text{EN: "unknown format %q", ZH: "未知的输出格式 %q"}.String(), format)
+	}
+	if minLevel != "" && !slices.Contains(sigma.Levels, minLevel) {
+		return flag.Usagef(flag.Text{EN: "unknown level %q, expected one of %s", ZH: "未知的级别 %q，可选 %s"}.String(), minLevel, strings.Join(sigma.Levels, ", "))
+	}
+
+	cfg := sigma.Config{Rules: rules}
+	if len(rules) == 0 {
+		conf, err := loadConfig(source.flags)
+		if err != nil {
+			return err
+		}
+		cfg = conf.Detection.Sigma
+	}
+	if len(cfg.Rules) == 0 {
+		return flag.Usagef("%s", flag.Text{EN: "no rules given, use --rules or set detection.sigma.rules", ZH: "未指定规则，请使用 --rules 或在配置中设置 detection.sigma.rules"})
+	}
+	if minLevel != "" {
+		cfg.MinLevel = minLevel
+	}
+	set, err := sigma.Load(cfg)
+	if err != nil {
+		return err
+	}
+
+	if check {
+		printRuleSet(os.Stdout, set)
+		if len(set.Errors) > 0 {
+			return fmt.Errorf(flag.Text{EN: "%d rule(s) failed to load", ZH: "%d 条规则无法加载"}.String(), len(set.Errors))
+		}
+		return nil
+	}
+	for _, err := range set.Errors {
+		fmt.Fprintf(os.Stderr, "跳过无法加载的规则 %v\n", err)
+	}
+
+	records, from, err := source.load(fs.Args())
+	if err != nil {
+		return err
+	}
+	matches := []sigmaMatch{}
+	for i := range records {
+		r := &records[i]
+		for _, m := range set.Match(r) {
+			matches = append(matches, sigmaMatch{
+				Time:      r.Timestamp,
+				ID:        m.Rule.ID,
+				Title:     m.Rule.Title,
+				Level:     m.Rule.Level,
+				Tags:      m.Rule.Tags,
+				Path:      m.Rule.Path,
+				Process:   r.ProcessLabel(),
+				QueryName: r.QueryName,
+				QueryType: r.QueryType,
+				Aggregate: m.Aggregate,
+				Group:     m.Group,
+				Value:     m.Value,
+			})
+		}
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(matches); err != nil {
+			return err
+		}
+	} else {
+		printSigmaMatches(os.Stdout, matches)
+	}
+	fmt.Fprintf(os.Stderr, "已从 %s 读取 %d 条记录，使用 %d 条规则，命中 %d 次\n", from, len(records), len(set.Rules), len(matches))
+	return nil
+}
+
+// printRuleSet 列出已加载的规则与无法加载的规则
+func printRuleSet(w io.Writer, set *sigma.RuleSet) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, rule := range set.Rules {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", valueOr(rule.Level, "-"), valueOr(rule.ID, "-"), rule.Title)
+	}
+	tw.Flush()
+	for _, err := range set.Errors {
+		fmt.Fprintf(w, "[FAIL] %v\n", err)
+	}
+	fmt.Fprintf(w, flag.Text{
+		EN: "%d file(s), %d rule(s) loaded, %d skipped, %d failed\n",
+		ZH: "%d 个文件，已加载 %d 条规则，跳过 %d 条，%d 条无法加载\n",
+	}.String(), set.Files, len(set.Rules), set.Skipped, len(set.Errors))
+}
+
+// printSigmaMatches 以文本表格输出规则命中结果
+func printSigmaMatches(w io.Writer, matches []sigmaMatch) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, m := range matches {
+		detail := m.QueryName
+		if m.Aggregate != "" {
+			detail = fmt.Sprintf("%s  [%s = %g]", m.QueryName, m.Aggregate, m.Value)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			utils.DisplayTime(m.Time).Format("2006-01-02 15:04:05"), valueOr(m.Level, "-"), m.Title, m.Process, detail)
+	}
+	tw.Flush()
+}
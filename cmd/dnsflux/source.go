@@ -0,0 +1,184 @@
+package main
+
+import (
+	"dnsflux/internal/config"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output/jsonfile"
+	"dnsflux/internal/query"
+	"dnsflux/internal/replay"
+	"dnsflux/internal/utils"
+	"dnsflux/pkg/flag"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sourceOptions query、export、stats 共用的记录来源与查询条件参数
+// 记录来源依次为：--server 指定的运行中实例、命令行给出的文件、--dir 或配置文件中输出目录下的记录文件
+type sourceOptions struct {
+	flags *flag.Config
+
+	dir    string
+	server string
+
+	types            string
+	domains          string
+	excludeDomains   string
+	processes        string
+	excludeProcesses string
+	rcodes           string
+	since            string
+	until            string
+	limit            int
+}
+
+// sourceArgs 读取记录的子命令的位置参数说明
+var sourceArgs = flag.Text{EN: "[options] [file...]", ZH: "[选项] [文件...]"}
+
+// addSourceFlags 注册记录来源与查询条件参数，limit 为 --limit 的默认值
+func addSourceFlags(fs *flag.FlagSet, limit int) *sourceOptions {
+	o := &sourceOptions{flags: &flag.Config{}}
+	o.flags.AddConfigFlags(fs)
+	fs.StringVar(&o.dir, "dir", "", "", "",
+		flag.Text{EN: "Read record files from this directory when no file is given (default: output.dir of the configuration)", ZH: "未指定文件时读取该目录中的记录文件 (默认为配置中的 output.dir)"})
+	fs.StringVar(&o.server, "server", "", "DNSFLUX_SERVER", "",
+		flag.Text{EN: "Query a running instance instead of files, e.g. http://127.0.0.1:58080", ZH: "从运行中的实例查询而非读取文件，如 http://127.0.0.1:58080"})
+	fs.StringVar(&o.types, "type", "t", "", "",
+		flag.Text{EN: "Query types, comma separated, e.g. A,AAAA", ZH: "查询类型，以逗号分隔，如 A,AAAA"})
+	fs.StringVar(&o.domains, "domain", "d", "", "",
+		flag.Text{EN: "Domains including subdomains, comma separated", ZH: "域名（包括子域名），以逗号分隔"})
+	fs.StringVar(&o.excludeDomains, "exclude-domain", "", "", "",
+		flag.Text{EN: "Domains to exclude, comma separated", ZH: "排除的域名，以逗号分隔"})
+	fs.StringVar(&o.processes, "process", "P", "", "",
+		flag.Text{EN: "Process names, comma separated", ZH: "进程名，以逗号分隔"})
+	fs.StringVar(&o.excludeProcesses, "exclude-process", "", "", "",
+		flag.Text{EN: "Process names to exclude, comma separated", ZH: "排除的进程名，以逗号分隔"})
+	fs.StringVar(&o.rcodes, "rcode", "r", "", "",
+		flag.Text{EN: "Response codes, comma separated, e.g. NXDOMAIN,SERVFAIL", ZH: "响应码，以逗号分隔，如 NXDOMAIN,SERVFAIL"})
+	fs.StringVar(&o.since, "since", "", "", "",
+		flag.Text{EN: "Records at or after this time: RFC 3339, '2006-01-02 15:04:05' or relative such as 15m, 2h, 7d", ZH: "不早于该时间的记录：RFC 3339、'2006-01-02 15:04:05' 或 15m、2h、7d 等相对时长"})
+	fs.StringVar(&o.until, "until", "", "", "",
+		flag.Text{EN: "Records before this time, same formats as --since", ZH: "早于该时间的记录，格式同 --since"})
+	fs.IntVar(&o.limit, "limit", "n", "", limit,
+		flag.Text{EN: "Keep only the most recent N records, 0 for all", ZH: "只保留最近的 N 条记录，0 表示全部"})
+	return o
+}
+
+// load 按查询条件读取记录，返回按时间先后排序的记录与记录来源的描述
+func (o *sourceOptions) load(files []string) ([]model.DNSRecord, string, error) {
+	cfg, err := loadLocation(o.flags)
+	if err != nil {
+		return nil, "", err
+	}
+
+	q, err := o.query()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if o.server != "" {
+		if len(files) > 0 {
+			return nil, "", flag.Usagef("%s", flag.Text{EN: "--server cannot be combined with files", ZH: "--server 不能与文件同时使用"})
+		}
+		records, err := fetchRecords(o.server, q)
+		return records, o.server, err
+	}
+
+	if len(files) == 0 {
+		dir := cfg.Output.Dir
+		if o.dir != "" {
+			dir = o.dir
+		}
+		if files, err = jsonfile.ListFiles(dir); err != nil {
+			return nil, "", fmt.Errorf("读取输出目录失败: %w", err)
+		}
+		if len(files) == 0 {
+			return nil, "", fmt.Errorf("目录 %s 中没有记录文件", dir)
+		}
+	}
+
+	var records []model.DNSRecord
+	for _, file := range files {
+		err := replay.ReadFile(file, func(record model.DNSRecord) error {
+			if q.Match(&record) {
+				records = append(records, record)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return q.Apply(records), fmt.Sprintf("%d 个文件", len(files)), nil
+}
+
+// query 根据参数创建查询条件
+func (o *sourceOptions) query() (query.Query, error) {
+	values := url.Values{}
+	values.Set("queryTypes", o.types)
+	values.Set("domains", o.domains)
+	values.Set("excludeDomains", o.excludeDomains)
+	values.Set("processes", o.processes)
+	values.Set("excludeProcesses", o.excludeProcesses)
+	values.Set("rcodes", o.rcodes)
+	values.Set("since", o.since)
+	values.Set("until", o.until)
+	if o.limit < 0 {
+		return query.Query{}, flag.Usagef("%s", flag.Text{EN: "--limit must not be negative", ZH: "--limit 不能为负数"})
+	}
+	values.Set("limit", strconv.Itoa(o.limit))
+
+	q, err := query.FromValues(values, time.Now())
+	if err != nil {
+		return q, &flag.UsageError{Err: err}
+	}
+	return q, nil
+}
+
+// fetchRecords 通过 Web API 从运行中的实例查询记录，返回按时间先后排序的记录
+func fetchRecords(server string, q query.Query) ([]model.DNSRecord, error) {
+	values := q.Values()
+	// 接口未指定 limit 时只返回 100 条，显式传 0 表示全部
+	values.Set("limit", strconv.Itoa(q.Limit))
+	endpoint := strings.TrimSuffix(server, "/") + "/api/records?" + values.Encode()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("请求 %s 失败: %w", server, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("请求 %s 失败: %s %s", server, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var records []model.DNSRecord
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, fmt.Errorf("解析 %s 的响应失败: %w", server, err)
+	}
+	// 接口按时间倒序返回
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// loadLocation 读取配置并设置展示时区，供不需要读取记录的子命令使用
+func loadLocation(flags *flag.Config) (*config.Config, error) {
+	cfg, err := loadConfig(flags)
+	if err != nil {
+		return nil, err
+	}
+	location, err := utils.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	utils.SetDisplayLocation(location)
+	return cfg, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"dnsflux/internal/model"
+	"dnsflux/internal/utils"
+	"dnsflux/pkg/flag"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// statsCount 统计项及其记录数
+type statsCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// statsReport 记录统计结果
+type statsReport struct {
+	Total         int          `json:"total"`
+	First         *time.Time   `json:"first,omitempty"`
+	Last          *time.Time   `json:"last,omitempty"`
+	UniqueDomains int          `json:"uniqueDomains"`
+	Domains       []statsCount `json:"domains"`
+	Processes     []statsCount `json:"processes"`
+	QueryTypes    []statsCount `json:"queryTypes"`
+	RCodes        []statsCount `json:"rcodes"`
+}
+
+// statsCommand 统计记录中查询最多的域名、进程、查询类型与响应码
+func statsCommand(args []string) error {
+	fs := flag.NewFlagSet("dnsflux stats", sourceArgs, flag.Text{
+		EN: "Summarize DNS records: top domains, processes, query types and response codes. Records are read like 'dnsflux query' and the same filters apply.",
+		ZH: "统计 DNS 记录中查询最多的域名、进程、查询类型与响应码。记录来源与过滤条件同 'dnsflux query'。",
+	})
+	source := addSourceFlags(fs, 0)
+	var top int
+	var format string
+	fs.IntVar(&top, "top", "", "", 10,
+		flag.Text{EN: "Number of entries in each list", ZH: "每个列表显示的条目数"})
+	fs.StringVar(&format, "format", "f", "", "text",
+		flag.Text{EN: "Output format [text, json]", ZH: "输出格式 [text, json]"})
+	fs.Example(
+		"dnsflux stats --since 24h",
+		"dnsflux stats -P chrome --top 20",
+		"dnsflux stats -f json --server http://127.0.0.1:58080",
+	)
+	if err := parseArgs(fs, args, 0, -1); err != nil {
+		return err
+	}
+	if format != "text" && format != "json" {
+		return flag.Usagef(flag.Text{EN: "unknown format %q", ZH: "未知的输出格式 %q"}.String(), format)
+	}
+	if top <= 0 {
+		return flag.Usagef("%s", flag.Text{EN: "--top must be positive", ZH: "--top 必须大于 0"})
+	}
+
+	records, _, err := source.load(fs.Args())
+	if err != nil {
+		return err
+	}
+	report := summarize(records, top)
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printStats(os.Stdout, report)
+	return nil
+}
+
+// summarize 统计记录，records 按时间先后排序
+func summarize(records []model.DNSRecord, top int) statsReport {
+	domains := make(map[string]int)
+	processes := make(map[string]int)
+	queryTypes := make(map[string]int)
+	rcodes := make(map[string]int)
+	for i := range records {
+		r := &records[i]
+		domains[strings.TrimSuffix(strings.ToLower(r.QueryName), ".")]++
+		processes[valueOr(r.ProcessName, "-")]++
+		queryTypes[valueOr(r.QueryType, "-")]++
+		rcodes[valueOr(r.RCode, "-")]++
+	}
+
+	report := statsReport{
+		Total:         len(records),
+		UniqueDomains: len(domains),
+		Domains:       topCounts(domains, top),
+		Processes:     topCounts(processes, top),
+		QueryTypes:    topCounts(queryTypes, top),
+		RCodes:        topCounts(rcodes, top),
+	}
+	if len(records) > 0 {
+		first, last := records[0].Timestamp, records[len(records)-1].Timestamp
+		report.First, report.Last = &first, &last
+	}
+	return report
+}
+
+// topCounts 按记录数降序返回前 n 项，记录数相同时按名称排序
+func topCounts(counts map[string]int, n int) []statsCount {
+	list := make([]statsCount, 0, len(counts))
+	for name, count := range counts {
+		list = append(list, statsCount{Name: name, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Name < list[j].Name
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// printStats 以文本表格输出统计结果
+func printStats(w io.Writer, report statsReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s:\t%d\n", flag.Text{EN: "Records", ZH: "记录数"}, report.Total)
+	if report.First != nil {
+		const layout = "2006-01-02 15:04:05"
+		fmt.Fprintf(tw, "%s:\t%s ~ %s\n", flag.Text{EN: "Time range", ZH: "时间范围"},
+			utils.DisplayTime(*report.First).Format(layout), utils.DisplayTime(*report.Last).Format(layout))
+	}
+	fmt.Fprintf(tw, "%s:\t%d\n", flag.Text{EN: "Unique domains", ZH: "不同域名数"}, report.UniqueDomains)
+	tw.Flush()
+
+	sections := []struct {
+		title flag.Text
+		list  []statsCount
+	}{
+		{flag.Text{EN: "Top domains", ZH: "查询最多的域名"}, report.Domains},
+		{flag.Text{EN: "Top processes", ZH: "查询最多的进程"}, report.Processes},
+		{flag.Text{EN: "Query types", ZH: "查询类型"}, report.QueryTypes},
+		{flag.Text{EN: "Response codes", ZH: "响应码"}, report.RCodes},
+	}
+	for _, section := range sections {
+		if len(section.list) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", section.title)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		for _, item := range section.list {
+			fmt.Fprintf(tw, "  %d\t%.1f%%\t  %s\n", item.Count, percent(item.Count, report.Total), item.Name)
+		}
+		tw.Flush()
+	}
+}
+
+// percent 计算百分比
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// valueOr 值为空时返回 fallback
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"dnsflux/internal/model"
+	"dnsflux/pkg/flag"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// versionInfo 版本信息
+type versionInfo struct {
+	Version       string `json:"version"`
+	GoVersion     string `json:"goVersion"`
+	Platform      string `json:"platform"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+// versionCommand 输出版本信息
+func versionCommand(args []string) error {
+	fs := flag.NewFlagSet("dnsflux version", flag.Text{EN: "[options]", ZH: "[选项]"}, flag.Text{
+		EN: "Print the version, Go version, platform and record schema version.",
+		ZH: "显示版本、Go 版本、平台与记录结构版本。",
+	})
+	var format string
+	fs.StringVar(&format, "format", "f", "", "text",
+		flag.Text{EN: "Output format [text, json]", ZH: "输出格式 [text, json]"})
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	info := versionInfo{
+		Version:       Version,
+		GoVersion:     runtime.Version(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		SchemaVersion: model.SchemaVersion,
+	}
+	switch format {
+	case "json":
+		return json.NewEncoder(os.Stdout).Encode(info)
+	case "text":
+		fmt.Printf("dnsflux %s\n", info.Version)
+		fmt.Printf("%s: %s\n", flag.Text{EN: "Go version", ZH: "Go 版本"}, info.GoVersion)
+		fmt.Printf("%s: %s\n", flag.Text{EN: "Platform", ZH: "平台"}, info.Platform)
+		fmt.Printf("%s: %d\n", flag.Text{EN: "Record schema", ZH: "记录结构版本"}, info.SchemaVersion)
+		return nil
+	default:
+		return flag.Usagef(flag.Text{EN: "unknown format %q", ZH: "未知的输出格式 %q"}.String(), format)
+	}
+}
@@ -5,6 +5,32 @@ import (
 	"dnsflux/internal/model"
 )
 
+// Config 采集器配置，可在采集过程中通过 Configure 更新
+type Config struct {
+	ETW ETWConfig `yaml:"etw"`
+}
+
+// ETWConfig Windows ETW 采集配置
+type ETWConfig struct {
+	EventIDs       []uint16 `yaml:"eventIds"`       // 处理的事件 ID，为空时处理全部 DNS 事件
+	ExcludeDomains []string `yaml:"excludeDomains"` // 域名包含其中任一字符串时不采集
+}
+
+// DefaultConfig 返回默认采集器配置
+func DefaultConfig() Config {
+	return Config{
+		ETW: ETWConfig{
+			EventIDs:       []uint16{3008},
+			ExcludeDomains: []string{"localhost"},
+		},
+	}
+}
+
+// Configure 更新平台采集器的配置，当前平台不使用的配置项被忽略
+func Configure(cfg Config) {
+	configure(cfg)
+}
+
 // Collector DNS 采集器接口
 type Collector interface {
 	// Start 启动采集器
@@ -13,10 +13,10 @@ struct dns_event {
     __u32 gid;
     __u32 ifindex;
     char comm[64];
-    __u16 sport;
-    __u16 dport;
-    __u32 saddr;
-    __u32 daddr;
+    __u16 sport;   // 主机字节序
+    __u16 dport;   // 网络字节序
+    __u32 saddr;   // 网络字节序
+    __u32 daddr;   // 网络字节序
     __u16 protocol;
     __u16 pkt_len;
     __u8 pkt_data[512];
@@ -59,7 +59,7 @@ static __always_inline int process_dns(struct pt_regs *ctx, struct sock *sk, __u
     // 获取进程名
     bpf_get_current_comm(&event->comm, sizeof(event->comm));
 
-    // 获取网络信息
+    // 获取网络信息，地址与目标端口保持内核中的网络字节序，源端口为主机字节序
     event->sport = sport;
     event->dport = dport;
     BPF_CORE_READ_INTO(&event->saddr, sk, __sk_common.skc_rcv_saddr);
@@ -79,12 +79,6 @@ static __always_inline int process_dns(struct pt_regs *ctx, struct sock *sk, __u
         event->pkt_len = (__u16)len;
     }
 
-    // 转换地址为网络字节序
-    event->saddr = bpf_htonl(event->saddr);
-    event->daddr = bpf_htonl(event->daddr);
-    event->sport = bpf_htons(event->sport);
-    event->dport = bpf_htons(event->dport);
-
     bpf_ringbuf_submit(event, 0);
     return 0;
 }
@@ -0,0 +1,68 @@
+//go:build linux
+
+package linux
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+)
+
+// TestObjectsKeepKernelByteOrder 检查两种字节序的字节码都不转换事件字段的字节序：
+// 地址与目标端口保持内核中的网络字节序，由用户态解码
+func TestObjectsKeepKernelByteOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		object string
+	}{
+		{"小端字节码", "dns_bpf_bpfel.o"},
+		{"大端字节码", "dns_bpf_bpfeb.o"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ebpf.LoadCollectionSpec(tt.object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"trace_udp_sendmsg", "trace_tcp_sendmsg"} {
+				prog := spec.Programs[name]
+				if prog == nil {
+					t.Fatalf("缺少程序 %s", name)
+				}
+				for i, ins := range prog.Instructions {
+					if ins.OpCode.Class().IsALU() && ins.OpCode.ALUOp() == asm.Swap {
+						t.Errorf("%s 第 %d 条指令转换了字节序: %v", name, i, ins)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestNetworkByteOrderFields 按内核内存中的字节构造事件字段，检查解码后的地址与端口
+func TestNetworkByteOrderFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		addr     []byte
+		port     []byte
+		wantIP   string
+		wantPort uint16
+	}{
+		{"DNS 端口", []byte{10, 0, 0, 5}, []byte{0x00, 0x35}, "10.0.0.5", 53},
+		{"DoT 端口", []byte{8, 8, 4, 4}, []byte{0x03, 0x55}, "8.8.4.4", 853},
+		{"高位端口", []byte{192, 168, 1, 255}, []byte{0xc3, 0x50}, "192.168.1.255", 50000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 事件结构按主机字节序解码，网络字节序的字段需要再转换
+			if got := formatIPv4(binary.NativeEndian.Uint32(tt.addr)); got != tt.wantIP {
+				t.Errorf("formatIPv4 = %s, want %s", got, tt.wantIP)
+			}
+			if got := ntohs(binary.NativeEndian.Uint16(tt.port)); got != tt.wantPort {
+				t.Errorf("ntohs = %d, want %d", got, tt.wantPort)
+			}
+		})
+	}
+}
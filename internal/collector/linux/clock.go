@@ -0,0 +1,47 @@
+//go:build linux
+
+package linux
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// clockRefreshInterval 重新计算启动时间偏移的间隔，用于跟随 NTP 等对系统时钟的调整
+const clockRefreshInterval = time.Minute
+
+// bootClock 将 bpf_ktime_get_ns（CLOCK_MONOTONIC，自系统启动起的纳秒数）换算为墙上时间
+// 偏移量为 CLOCK_REALTIME 与 CLOCK_MONOTONIC 之差，即系统启动时刻的 Unix 纳秒时间
+type bootClock struct {
+	offset    int64
+	refreshed time.Time
+}
+
+// Time 将内核单调时间换算为 UTC 时间，ktime 为 0 或换算失败时使用当前时间
+func (c *bootClock) Time(ktime uint64) time.Time {
+	now := time.Now()
+	if now.Sub(c.refreshed) >= clockRefreshInterval {
+		if offset, ok := bootOffset(); ok {
+			c.offset = offset
+			c.refreshed = now
+		}
+	}
+	if ktime == 0 || c.refreshed.IsZero() {
+		return now.UTC()
+	}
+	return time.Unix(0, int64(ktime)+c.offset).UTC()
+}
+
+// bootOffset 计算 CLOCK_REALTIME 与 CLOCK_MONOTONIC 的差值
+// 在两次读取实时时钟之间读取单调时钟，取两次实时时钟的中点以减小读取间隔带来的误差
+func bootOffset() (int64, bool) {
+	var before, mono, after unix.Timespec
+	if unix.ClockGettime(unix.CLOCK_REALTIME, &before) != nil ||
+		unix.ClockGettime(unix.CLOCK_MONOTONIC, &mono) != nil ||
+		unix.ClockGettime(unix.CLOCK_REALTIME, &after) != nil {
+		return 0, false
+	}
+	realtime := before.Nano() + (after.Nano()-before.Nano())/2
+	return realtime - mono.Nano(), true
+}
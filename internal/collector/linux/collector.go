@@ -8,12 +8,18 @@ package linux
 import (
 	"bytes"
 	"context"
+	"dnsflux/internal/metrics"
 	"dnsflux/internal/model"
+	"dnsflux/internal/utils"
 	"dnsflux/pkg/logger"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
+	"os/user"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,6 +29,9 @@ import (
 	"github.com/cilium/ebpf/rlimit"
 )
 
+// log 采集器日志
+var log = logger.Component("collector").With("collector", model.SourceEBPF)
+
 // 网络协议映射
 var protocolMap = map[uint16]string{
 	6:  "TCP",
@@ -44,33 +53,60 @@ var dnsTypeMap = map[uint16]string{
 
 // DNSQueryInfo DNS查询信息
 type DNSQueryInfo struct {
-	QueryName string
-	QueryType uint16
+	TransactionID uint16
+	QueryName     string
+	QueryType     uint16
+}
+
+// 进程信息缓存，同一进程的 DNS 事件不必每次读取 /proc 与用户数据库
+const (
+	processCacheTTL = 30 * time.Second // 进程信息有效期，进程 ID 被复用时最多在此期间内沿用旧信息
+	userCacheTTL    = 5 * time.Minute  // 用户名有效期
+	maxCached       = 4096             // 缓存条目数上限，超过时清空
+)
+
+// processKey 进程信息缓存键，附带事件中的进程名，进程 ID 被其他程序复用时不会命中
+type processKey struct {
+	pid  uint32
+	comm string
 }
 
 // ProcessInfo 进程信息结构
 type ProcessInfo struct {
-	Name string
-	Path string
+	Name    string
+	Path    string
+	PPID    uint32
+	User    string
+	Cmdline string
 }
 
 // LinuxCollector Linux 平台的 DNS 采集器
 type LinuxCollector struct {
 	recordCh chan model.DNSRecord
+	hostID   string
+	hostname string
 	spec     *ebpf.CollectionSpec
 	coll     *ebpf.Collection
 	links    []link.Link
 	reader   *ringbuf.Reader
 	ctx      context.Context
 	cancel   context.CancelFunc
+	clock    bootClock // 仅在 collectData 中使用
+	procs    *utils.Cache[processKey, ProcessInfo]
+	users    *utils.Cache[uint32, string]
 	// 保存已加载的 BPF 对象以便在 Stop 时关闭
 	objs dns_bpfObjects
 }
 
 // NewCollector 创建 Linux 采集器
 func NewCollector() *LinuxCollector {
+	hostID, hostname := utils.HostIdentity()
 	return &LinuxCollector{
 		recordCh: make(chan model.DNSRecord, 100),
+		hostID:   hostID,
+		hostname: hostname,
+		procs:    utils.NewCache[processKey, ProcessInfo](processCacheTTL, maxCached),
+		users:    utils.NewCache[uint32, string](userCacheTTL, maxCached),
 	}
 }
 
@@ -98,7 +134,7 @@ func (c *LinuxCollector) Start(ctx context.Context) error {
 		return fmt.Errorf("加载 eBPF 程序失败: %w", err)
 	}
 
-	logger.Info(fmt.Sprintf("启动 %s", c.Name()))
+	log.Info(fmt.Sprintf("启动 %s", c.Name()))
 
 	// 启动数据收集协程
 	go c.collectData()
@@ -142,7 +178,7 @@ func (c *LinuxCollector) loadEBPFProgram() error {
 	// 使用 bpf2go 生成的装载函数加载嵌入的字节码
 	spec, err := loadDns_bpf()
 	if err != nil {
-		logger.Error("加载 eBPF spec 失败")
+		log.Error("加载 eBPF spec 失败")
 		return fmt.Errorf("加载 eBPF spec 失败: %w", err)
 	}
 	c.spec = spec
@@ -178,7 +214,7 @@ func (c *LinuxCollector) loadEBPFProgram() error {
 	}
 	c.reader = r
 
-	logger.Info("eBPF 程序加载成功，已附加 kprobe 并初始化 ring buffer")
+	log.Info("eBPF 程序加载成功，已附加 kprobe 并初始化 ring buffer")
 	return nil
 }
 
@@ -186,7 +222,7 @@ func (c *LinuxCollector) loadEBPFProgram() error {
 func (c *LinuxCollector) collectData() {
 	if c.reader == nil {
 		// 如果没有 eBPF reader，无法进行真实DNS采集
-		logger.Error("eBPF reader 未初始化，无法进行DNS采集")
+		log.Error("eBPF reader 未初始化，无法进行DNS采集")
 		return
 	}
 
@@ -213,67 +249,94 @@ func (c *LinuxCollector) collectData() {
 		case <-c.ctx.Done():
 			return
 		default:
-			record, err := c.reader.Read()
+			sample, err := c.reader.Read()
 			if err != nil {
-				if err == ringbuf.ErrClosed {
+				if errors.Is(err, ringbuf.ErrClosed) {
 					return
 				}
+				metrics.CollectorError(model.SourceEBPF, metrics.ReasonRingbufRead)
+				log.Debug(fmt.Sprintf("读取 ringbuf 失败: %v", err))
 				continue
 			}
 
-			if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+			// ringbuf 中的事件按主机字节序排列
+			if err := binary.Read(bytes.NewBuffer(sample.RawSample), binary.NativeEndian, &event); err != nil {
+				metrics.CollectorError(model.SourceEBPF, metrics.ReasonEventDecode)
+				log.Debug(fmt.Sprintf("解析 eBPF 事件失败: %v", err))
 				continue
 			}
 
-			if event.PktLen > 0 {
-				dnsInfo := c.parseDNSPacket(event.PktData[:event.PktLen])
-				if dnsInfo != nil {
-					procInfo := c.getProcessInfo(event.PID)
-
-					// 获取查询类型
-					qtype := fmt.Sprintf("TYPE%d", dnsInfo.QueryType)
-					if t, ok := dnsTypeMap[dnsInfo.QueryType]; ok {
-						qtype = t
-					}
-
-					currentTime := c.getBeijingTime()
-
-					record := model.DNSRecord{
-						Timestamp:   currentTime,
-						QueryName:   dnsInfo.QueryName,
-						QueryType:   qtype,
-						QueryResult: "-",
-						ProcessID:   event.PID,
-						ProcessName: procInfo.Name,
-						ProcessPath: procInfo.Path,
-						ClientIP: fmt.Sprintf("%d.%d.%d.%d",
-							byte(event.Saddr),
-							byte(event.Saddr>>8),
-							byte(event.Saddr>>16),
-							byte(event.Saddr>>24)),
-					}
-
-					select {
-					case c.recordCh <- record:
-					case <-c.ctx.Done():
-						return
-					}
-				}
+			if event.PktLen == 0 {
+				continue
+			}
+			dnsInfo := c.parseDNSPacket(event.PktData[:event.PktLen])
+			if dnsInfo == nil {
+				metrics.CollectorError(model.SourceEBPF, metrics.ReasonDNSParse)
+				continue
+			}
+
+			comm := string(bytes.TrimRight(event.Comm[:], "\x00"))
+			procInfo := c.procs.Get(processKey{event.PID, comm}, func() ProcessInfo {
+				return c.getProcessInfo(event.PID)
+			})
+			procInfo.User = c.users.Get(event.UID, func() string {
+				return lookupUser(event.UID)
+			})
+
+			// 获取查询类型
+			qtype := fmt.Sprintf("TYPE%d", dnsInfo.QueryType)
+			if t, ok := dnsTypeMap[dnsInfo.QueryType]; ok {
+				qtype = t
+			}
+
+			transport := model.TransportOther
+			if p, ok := protocolMap[event.Protocol]; ok {
+				transport = strings.ToLower(p)
+			}
+
+			record := model.NewDNSRecord(model.SourceEBPF)
+			record.Timestamp = c.clock.Time(event.Timestamp)
+			record.HostID = c.hostID
+			record.Hostname = c.hostname
+			record.Transport = transport
+			record.ClientIP = formatIPv4(event.Saddr)
+			record.ServerIP = formatIPv4(event.Daddr)
+			record.ServerPort = ntohs(event.Dport)
+			record.TransactionID = dnsInfo.TransactionID
+			record.QueryName = dnsInfo.QueryName
+			record.QueryType = qtype
+			record.ProcessID = event.PID
+			record.ParentPID = procInfo.PPID
+			record.ProcessName = procInfo.Name
+			record.ProcessPath = procInfo.Path
+			record.ProcessUser = procInfo.User
+			record.ProcessCmdline = procInfo.Cmdline
+			record.Payload = append([]byte(nil), event.PktData[:event.PktLen]...)
+
+			select {
+			case c.recordCh <- record:
+			case <-c.ctx.Done():
+				return
 			}
 		}
 	}
 }
 
-// getBeijingTime 获取北京时间
-func (c *LinuxCollector) getBeijingTime() time.Time {
-	loc, err := time.LoadLocation("Asia/Shanghai")
-	if err != nil {
-		loc = time.FixedZone("CST", 8*3600)
-	}
-	return time.Now().In(loc)
+// formatIPv4 将事件中网络字节序的 IPv4 地址格式化为点分十进制
+func formatIPv4(addr uint32) string {
+	var b [4]byte
+	binary.NativeEndian.PutUint32(b[:], addr)
+	return net.IP(b[:]).String()
+}
+
+// ntohs 将按主机字节序解码的网络字节序端口（如 skc_dport）转换为端口号
+func ntohs(port uint16) uint16 {
+	var b [2]byte
+	binary.NativeEndian.PutUint16(b[:], port)
+	return binary.BigEndian.Uint16(b[:])
 }
 
-// getProcessInfo 获取进程信息
+// getProcessInfo 从 /proc 获取进程信息，不含用户名
 func (c *LinuxCollector) getProcessInfo(pid uint32) ProcessInfo {
 	info := ProcessInfo{
 		Name: "unknown",
@@ -288,16 +351,43 @@ func (c *LinuxCollector) getProcessInfo(pid uint32) ProcessInfo {
 	// 获取进程路径
 	if exePath, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
 		info.Path = exePath
-	} else if cmdlineBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
-		args := strings.Split(string(cmdlineBytes), "\x00")
+	}
+
+	// 获取命令行
+	if cmdlineBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		args := strings.Split(strings.TrimRight(string(cmdlineBytes), "\x00"), "\x00")
 		if len(args) > 0 && args[0] != "" {
-			info.Path = args[0]
+			info.Cmdline = strings.Join(args, " ")
+			if info.Path == "unknown" {
+				info.Path = args[0]
+			}
+		}
+	}
+
+	// 获取父进程 ID
+	if statusBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid)); err == nil {
+		for _, line := range strings.Split(string(statusBytes), "\n") {
+			if value, ok := strings.CutPrefix(line, "PPid:"); ok {
+				if ppid, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32); err == nil {
+					info.PPID = uint32(ppid)
+				}
+				break
+			}
 		}
 	}
 
 	return info
 }
 
+// lookupUser 返回 UID 对应的用户名，查询失败时返回 UID
+func lookupUser(uid uint32) string {
+	uidStr := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(uidStr); err == nil {
+		return u.Username
+	}
+	return uidStr
+}
+
 // parseDNSPacket 解析 DNS 数据包
 func (c *LinuxCollector) parseDNSPacket(data []byte) *DNSQueryInfo {
 	if len(data) < 12 {
@@ -348,7 +438,8 @@ func (c *LinuxCollector) parseDNSPacket(data []byte) *DNSQueryInfo {
 	}
 
 	return &DNSQueryInfo{
-		QueryName: string(queryName),
-		QueryType: queryType,
+		TransactionID: binary.BigEndian.Uint16(data[0:2]),
+		QueryName:     string(queryName),
+		QueryType:     queryType,
 	}
 }
@@ -1,7 +1,7 @@
 // Code generated by bpf2go; DO NOT EDIT.
 //go:build mips || mips64 || ppc64 || s390x
 
-package platform
+package linux
 
 import (
 	"bytes"
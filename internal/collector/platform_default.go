@@ -6,3 +6,6 @@ package collector
 func newPlatformCollector() Collector {
 	return nil
 }
+
+// configure 不支持的平台无可配置项
+func configure(cfg Config) {}
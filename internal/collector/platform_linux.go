@@ -24,6 +24,9 @@ func newPlatformCollector() Collector {
 	}
 }
 
+// configure eBPF 采集器目前没有可在运行时更新的配置项
+func configure(cfg Config) {}
+
 // Name 返回采集器名称
 func (c *LinuxCollector) Name() string {
 	return c.linuxCollector.Name()
@@ -24,6 +24,14 @@ func newPlatformCollector() Collector {
 	}
 }
 
+// configure 更新 ETW 事件过滤配置
+func configure(cfg Config) {
+	windows.SetConfig(windows.ETWConfig{
+		EventIDWhitelist: cfg.ETW.EventIDs,
+		DomainBlacklist:  cfg.ETW.ExcludeDomains,
+	})
+}
+
 // Name 返回采集器名称
 func (c *WindowsCollector) Name() string {
 	return c.windowsCollector.Name()
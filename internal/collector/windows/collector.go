@@ -4,12 +4,15 @@ package windows
 
 import (
 	"context"
+	"dnsflux/internal/metrics"
 	"dnsflux/internal/model"
+	"dnsflux/internal/utils"
 	"dnsflux/pkg/logger"
 	"fmt"
-	"regexp"
+	"net"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
@@ -17,6 +20,9 @@ import (
 	"github.com/0xrawsec/golang-etw/etw"
 )
 
+// log 采集器日志
+var log = logger.Component("collector").With("collector", model.SourceETW)
+
 const (
 	// Microsoft-Windows-DNS-Client Provider GUID
 	dnsProviderGUID = "{1C95126E-7EEA-49A9-A3FE-A378B03DDB4D}"
@@ -26,6 +32,20 @@ const (
 	PROCESS_QUERY_INFORMATION         = 0x0400
 )
 
+// 进程信息缓存，同一进程的 DNS 事件不必每次打开进程与创建进程快照
+const (
+	processCacheTTL = 30 * time.Second // 进程信息有效期，进程 ID 被复用时最多在此期间内沿用旧信息
+	maxCached       = 4096             // 缓存条目数上限，超过时清空
+)
+
+// processInfo 进程信息
+type processInfo struct {
+	name      string
+	path      string
+	parentPID uint32
+	user      string
+}
+
 // Windows API 函数声明
 var (
 	modkernel32                    = syscall.NewLazyDLL("kernel32.dll")
@@ -56,15 +76,19 @@ var statusMap = map[int]string{
 	9501: "ERROR(query record not found)",
 }
 
-// IP地址匹配
-var (
-	// IPv4 地址模式
-	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
-
-	// IPv6 地址模式 (包括压缩格式)
-	ipv6Pattern = regexp.MustCompile(`(?i)\b(?:(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}|(?:[0-9a-f]{1,4}:){1,7}:|:(?::[0-9a-f]{1,4}){1,7})\b`)
-)
+// DNS查询状态码到响应码的映射
+var rcodeMap = map[int]string{
+	0:    "NOERROR",
+	1460: "TIMEOUT",
+	9001: "FORMERR",
+	9002: "SERVFAIL",
+	9003: "NXDOMAIN",
+	9004: "NOTIMP",
+	9005: "REFUSED",
+	9501: "NOERROR",
+}
 
+// ETWConfig ETW 事件过滤配置
 type ETWConfig struct {
 	// 事件ID白名单，为空则不过滤
 	EventIDWhitelist []uint16
@@ -72,26 +96,48 @@ type ETWConfig struct {
 	DomainBlacklist []string
 }
 
-// 配置事件白名单ID和域名黑名单
-var etwConfig = ETWConfig{
-	// DNS查询事件ID：3006【开始查询】，3008【已完成的查询】，3009【发起索引查询】，3010【发起DNS服务查询】，3011【DNS服务器响应】，3018【缓存查询响应】，3020【索引查询响应】
-	EventIDWhitelist: []uint16{3008},
-	DomainBlacklist:  []string{"localhost"},
+// DefaultETWConfig 默认的事件白名单ID和域名黑名单
+// DNS查询事件ID：3006【开始查询】，3008【已完成的查询】，3009【发起索引查询】，3010【发起DNS服务查询】，3011【DNS服务器响应】，3018【缓存查询响应】，3020【索引查询响应】
+func DefaultETWConfig() ETWConfig {
+	return ETWConfig{
+		EventIDWhitelist: []uint16{3008},
+		DomainBlacklist:  []string{"localhost"},
+	}
+}
+
+// etwConfig 当前生效的过滤配置，可在采集过程中通过 SetConfig 更新
+var etwConfig atomic.Pointer[ETWConfig]
+
+func init() {
+	cfg := DefaultETWConfig()
+	etwConfig.Store(&cfg)
+}
+
+// SetConfig 更新事件过滤配置，无需重启采集
+func SetConfig(cfg ETWConfig) {
+	etwConfig.Store(&cfg)
 }
 
 // WindowsCollector Windows 平台的 DNS 采集器
 type WindowsCollector struct {
 	recordCh chan model.DNSRecord
+	hostID   string
+	hostname string
 	ctx      context.Context
 	cancel   context.CancelFunc
 	session  *etw.RealTimeSession
 	consumer interface{} // 保存 consumer 实例
+	procs    *utils.Cache[uint32, processInfo]
 }
 
 // NewCollector 创建 Windows 采集器
 func NewCollector() *WindowsCollector {
+	hostID, hostname := utils.HostIdentity()
 	return &WindowsCollector{
 		recordCh: make(chan model.DNSRecord, 100),
+		hostID:   hostID,
+		hostname: hostname,
+		procs:    utils.NewCache[uint32, processInfo](processCacheTTL, maxCached),
 	}
 }
 
@@ -104,7 +150,7 @@ func (c *WindowsCollector) Name() string {
 func (c *WindowsCollector) Start(ctx context.Context) error {
 	c.ctx, c.cancel = context.WithCancel(ctx)
 
-	logger.Info(fmt.Sprintf("启动 %s", c.Name()))
+	log.Info(fmt.Sprintf("启动 %s", c.Name()))
 
 	// 创建实时会话
 	c.session = etw.NewRealTimeSession("DNSMonitor")
@@ -114,7 +160,7 @@ func (c *WindowsCollector) Start(ctx context.Context) error {
 	if err := c.session.EnableProvider(dnsProvider); err != nil {
 		return fmt.Errorf("启用 Provider 失败: %v", err)
 	}
-	logger.Info("DNS Provider 启用成功")
+	log.Info("DNS Provider 启用成功")
 
 	// 启动数据收集协程（使用真实ETW事件）
 	go c.collectData()
@@ -150,7 +196,7 @@ func (c *WindowsCollector) Subscribe() <-chan model.DNSRecord {
 func (c *WindowsCollector) collectData() {
 	// 启动ETW事件处理
 	if c.session == nil {
-		logger.Error("ETW会话未初始化，无法进行DNS采集")
+		log.Error("ETW会话未初始化，无法进行DNS采集")
 		return
 	}
 
@@ -168,56 +214,70 @@ func (c *WindowsCollector) collectData() {
 
 	// 启动消费（阻塞直到 Stop 或上下文结束）
 	if err := consumer.Start(); err != nil {
-		logger.Error(fmt.Sprintf("ETW consumer 启动失败: %v", err))
+		log.Error(fmt.Sprintf("ETW consumer 启动失败: %v", err))
 		return
 	}
 
 	if err := consumer.Err(); err != nil {
-		logger.Error(fmt.Sprintf("ETW consumer 运行错误: %v", err))
+		log.Error(fmt.Sprintf("ETW consumer 运行错误: %v", err))
 	}
 }
 
 // handleProcessEvent 处理 ETW 事件
 func (c *WindowsCollector) handleProcessEvent(evt *etw.Event) {
 	if evt.System.Provider.Guid == dnsProviderGUID {
+		cfg := etwConfig.Load()
+
 		// 过滤白名单事件
-		if !c.isEventIDAllowed(evt.System.EventID, etwConfig.EventIDWhitelist) {
+		if !c.isEventIDAllowed(evt.System.EventID, cfg.EventIDWhitelist) {
 			return
 		}
 
 		queryName, hasQuery := evt.EventData["QueryName"]
 		if !hasQuery {
+			metrics.CollectorError(model.SourceETW, metrics.ReasonEventDecode)
 			return
 		}
 
 		// 过滤黑名单域名
-		if c.isDomainBlocked(fmt.Sprintf("%v", queryName), etwConfig.DomainBlacklist) {
+		if c.isDomainBlocked(fmt.Sprintf("%v", queryName), cfg.DomainBlacklist) {
 			return
 		}
 
 		queryType := c.getDNSQueryType(evt.EventData["QueryType"])
 
-		result := ""
+		answers := []model.DNSAnswer{}
 		if r, ok := evt.EventData["QueryResults"]; ok {
-			result = c.formatDNSResult(fmt.Sprintf("%v", r))
+			answers = c.parseDNSAnswers(fmt.Sprintf("%v", r), queryType)
 		}
 
 		processId := evt.System.Execution.ProcessID
-		processName, processPath := c.getProcessInfo(processId)
-
-		beijingTime := c.formatTimeAsBeijing(evt.System.TimeCreated.SystemTime)
+		proc := c.procs.Get(processId, func() processInfo {
+			name, path := c.getProcessInfo(processId)
+			return processInfo{
+				name:      name,
+				path:      path,
+				parentPID: c.getParentPID(processId),
+				user:      c.getProcessUser(processId),
+			}
+		})
 
 		// 创建 DNS 记录
-		record := model.DNSRecord{
-			Timestamp:   beijingTime,
-			QueryName:   fmt.Sprintf("%v", queryName),
-			QueryType:   queryType,
-			QueryResult: result,
-			ProcessID:   processId,
-			ProcessName: processName,
-			ProcessPath: processPath,
-			ClientIP:    "-",
-		}
+		record := model.NewDNSRecord(model.SourceETW)
+		record.Timestamp = evt.System.TimeCreated.SystemTime.UTC()
+		record.HostID = c.hostID
+		record.Hostname = c.hostname
+		record.Transport = model.TransportOther
+		record.ClientIP = "-"
+		record.QueryName = fmt.Sprintf("%v", queryName)
+		record.QueryType = queryType
+		record.RCode = c.getDNSRCode(evt.EventData["QueryStatus"])
+		record.Answers = answers
+		record.ProcessID = processId
+		record.ParentPID = proc.parentPID
+		record.ProcessName = proc.name
+		record.ProcessPath = proc.path
+		record.ProcessUser = proc.user
 
 		select {
 		case c.recordCh <- record:
@@ -300,7 +360,7 @@ func (c *WindowsCollector) getProcessInfo(pid uint32) (name, path string) {
 	// 使用 PROCESS_QUERY_LIMITED_INFORMATION 权限
 	handle, err := syscall.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
 	if err != nil {
-		logger.Warn(fmt.Sprintf("无法打开进程 %d: %v", pid, err))
+		log.Warn(fmt.Sprintf("无法打开进程 %d: %v", pid, err))
 		// 返回默认值或空值
 		return fmt.Sprintf("PID: %d", pid), ""
 	}
@@ -371,68 +431,112 @@ func (c *WindowsCollector) getDNSStatus(status interface{}) string {
 	}
 }
 
-// 提取查询结果中的 IP 地址
-func (c *WindowsCollector) extractIPs(result string) (ipv4s []string, ipv6s []string) {
-	// 提取所有 IPv4 地址
-	ipv4s = ipv4Pattern.FindAllString(result, -1)
-
-	// 提取所有 IPv6 地址
-	ipv6s = ipv6Pattern.FindAllString(result, -1)
-
-	// 去重
-	ipv4s = c.removeDuplicates(ipv4s)
-	ipv6s = c.removeDuplicates(ipv6s)
-
-	return
+// 获取DNS响应码
+func (c *WindowsCollector) getDNSRCode(status interface{}) string {
+	var code int
+	switch s := status.(type) {
+	case nil:
+		return ""
+	case float64:
+		code = int(s)
+	case int:
+		code = s
+	case string:
+		sInt, err := strconv.Atoi(s)
+		if err != nil {
+			return ""
+		}
+		code = sInt
+	default:
+		return fmt.Sprintf("%v", status)
+	}
+	if rcode, ok := rcodeMap[code]; ok {
+		return rcode
+	}
+	return c.getDNSStatus(code)
 }
 
-// 去重函数
-func (c *WindowsCollector) removeDuplicates(arr []string) []string {
+// 解析 ETW QueryResults 字段为结构化应答
+// 格式示例: "type:  5 edge.example.net;::ffff:93.184.216.34;"
+func (c *WindowsCollector) parseDNSAnswers(result, queryType string) []model.DNSAnswer {
+	answers := []model.DNSAnswer{}
 	seen := make(map[string]bool)
-	result := []string{}
 
-	for _, item := range arr {
-		if !seen[item] {
-			seen[item] = true
-			result = append(result, item)
+	for _, item := range strings.Split(result, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		var answer model.DNSAnswer
+		if rest, ok := strings.CutPrefix(item, "type:"); ok {
+			// 非地址类应答，如 CNAME
+			fields := strings.Fields(rest)
+			if len(fields) < 2 {
+				continue
+			}
+			answer.Type = c.getDNSQueryType(fields[0])
+			answer.Data = fields[1]
+		} else {
+			ip := net.ParseIP(strings.TrimPrefix(item, "::ffff:"))
+			if ip == nil {
+				continue
+			}
+			answer.Data = ip.String()
+			answer.Type = model.AnswerTypeOf(answer.Data, queryType)
 		}
+
+		key := answer.Type + "/" + answer.Data
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		answers = append(answers, answer)
 	}
 
-	return result
+	return answers
 }
 
-// 提取并格式化 IP 地址结果
-func (c *WindowsCollector) formatDNSResult(result string) string {
-	ipv4s, ipv6s := c.extractIPs(result)
-
-	// 优先使用 IPv4 地址
-	if len(ipv4s) > 0 {
-		if len(ipv4s) == 1 {
-			return ipv4s[0]
-		}
-		return strings.Join(ipv4s, ", ")
+// 获取父进程 ID
+func (c *WindowsCollector) getParentPID(pid uint32) uint32 {
+	snapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return 0
 	}
+	defer syscall.CloseHandle(snapshot)
 
-	// 如果没有 IPv4 地址，则使用 IPv6 地址
-	if len(ipv6s) > 0 {
-		if len(ipv6s) == 1 {
-			return ipv6s[0]
+	var entry syscall.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = syscall.Process32First(snapshot, &entry); err == nil; err = syscall.Process32Next(snapshot, &entry) {
+		if entry.ProcessID == pid {
+			return entry.ParentProcessID
 		}
-		return strings.Join(ipv6s, ", ")
 	}
-
-	return ""
+	return 0
 }
 
-// 格式化为北京时间
-func (c *WindowsCollector) formatTimeAsBeijing(t time.Time) time.Time {
-	// 设置时区为北京
-	const beijingTimeZone = "Asia/Shanghai"
-	loc, err := time.LoadLocation(beijingTimeZone)
+// 获取进程所属用户
+func (c *WindowsCollector) getProcessUser(pid uint32) string {
+	handle, err := syscall.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
 	if err != nil {
-		return t // 如果加载时区失败，返回原始时间
+		return ""
+	}
+	defer syscall.CloseHandle(handle)
+
+	var token syscall.Token
+	if err := syscall.OpenProcessToken(handle, syscall.TOKEN_QUERY, &token); err != nil {
+		return ""
 	}
+	defer token.Close()
 
-	// 将时间转换为北京时间并返回
-	return t.In(loc)
+	tokenUser, err := token.GetTokenUser()
+	if err != nil {
+		return ""
+	}
+	account, domain, _, err := tokenUser.User.Sid.LookupAccount("")
+	if err != nil {
+		sid, _ := tokenUser.User.Sid.String()
+		return sid
+	}
+	return domain + `\` + account
 }
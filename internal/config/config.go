@@ -0,0 +1,210 @@
+// Package config 定义 YAML 配置文件的结构、默认值与校验规则
+package config
+
+import (
+	"dnsflux/internal/collector"
+	"dnsflux/internal/detect"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/output/jsonfile"
+	"dnsflux/internal/store/memory"
+	"dnsflux/pkg/logger"
+	"path/filepath"
+)
+
+// Config 完整配置
+// 优先级从高到低为：命令行参数、DNSFLUX_* 环境变量、配置文件、默认值
+type Config struct {
+	Log        LogConfig           `yaml:"log"`
+	Timezone   string              `yaml:"timezone"` // 展示时间使用的时区，记录始终以 UTC 保存
+	Collector  collector.Config    `yaml:"collector"`
+	Filter     output.Filter       `yaml:"filter"` // 全局过滤，不匹配的记录不进入存储与任何输出目标
+	Enrichment EnrichmentConfig    `yaml:"enrichment"`
+	Store      StoreConfig         `yaml:"store"`
+	Alerts     AlertsConfig        `yaml:"alerts"`
+	Console    ConsoleConfig       `yaml:"console"`
+	Output     OutputConfig        `yaml:"output"` // JSONL 记录文件
+	Sinks      []output.SinkConfig `yaml:"sinks"`  // 额外的输出目标
+	Detection  detect.Config       `yaml:"detection"`
+	Metrics    MetricsConfig       `yaml:"metrics"`
+	Web        WebConfig           `yaml:"web"`
+}
+
+// LogConfig 程序日志配置
+type LogConfig struct {
+	Level     string `yaml:"level"`     // debug, info, warn, error
+	Format    string `yaml:"format"`    // text, json
+	Stream    string `yaml:"stream"`    // auto, stdout, stderr, none
+	File      string `yaml:"file"`      // 日志文件路径，为空时不写文件
+	MaxSizeMB int    `yaml:"maxSizeMB"` // 单个日志文件最大大小
+	MaxFiles  int    `yaml:"maxFiles"`  // 保留的轮转文件个数
+}
+
+// EnrichmentConfig 记录附加信息配置
+type EnrichmentConfig struct {
+	ProcessCmdline bool   `yaml:"processCmdline"` // 是否保留进程命令行（可能包含敏感参数）
+	ProcessUser    bool   `yaml:"processUser"`    // 是否保留进程所属用户
+	Hostname       string `yaml:"hostname"`       // 覆盖记录中的主机名，为空时使用系统主机名
+}
+
+// StoreConfig 内存存储配置
+type StoreConfig struct {
+	Capacity int `yaml:"capacity"` // 保留的最近记录数
+}
+
+// AlertsConfig 告警存储配置
+type AlertsConfig struct {
+	Capacity   int             `yaml:"capacity"`   // 保留的告警数
+	Window     output.Duration `yaml:"window"`     // 去重窗口，距上次告警不超过该间隔的相同告警合并且不再通知
+	MaxRecords int             `yaml:"maxRecords"` // 每条告警保留的相关记录 ID 上限
+}
+
+// ConsoleConfig 控制台记录输出配置
+type ConsoleConfig struct {
+	Quiet    bool   `yaml:"quiet"`
+	Format   string `yaml:"format"`   // auto, pretty, compact, json, template
+	Template string `yaml:"template"` // template 格式使用的 Go 模板
+	Color    string `yaml:"color"`    // auto, always, never
+}
+
+// OutputConfig JSONL 记录文件配置
+type OutputConfig struct {
+	Enabled        *bool  `yaml:"enabled"`
+	Dir            string `yaml:"dir"`
+	MaxSizeMB      int    `yaml:"maxSizeMB"`
+	MaxAgeDays     int    `yaml:"maxAgeDays"`
+	MaxTotalSizeMB int    `yaml:"maxTotalSizeMB"`
+	Compress       string `yaml:"compress"` // none, gzip, zstd
+	Timezone       string `yaml:"timezone"` // 按天轮转使用的时区，为空时与 timezone 相同
+	Alerts         bool   `yaml:"alerts"`   // 是否将告警写入 dns_alerts_ 开头的文件
+}
+
+// MetricsConfig Prometheus 指标配置
+type MetricsConfig struct {
+	Addr         string `yaml:"addr"`         // 独立的指标监听地址，为空时仅随 Web 服务提供
+	TopProcesses int    `yaml:"topProcesses"` // 按进程导出查询数的进程个数
+}
+
+// WebConfig Web 服务配置
+type WebConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"`
+	Port    int    `yaml:"port"`
+}
+
+// Default 返回默认配置
+func Default() *Config {
+	records := jsonfile.DefaultConfig()
+	return &Config{
+		Log: LogConfig{
+			Level:     "info",
+			Format:    logger.FormatText,
+			Stream:    logger.StreamAuto,
+			MaxSizeMB: 10,
+			MaxFiles:  5,
+		},
+		Timezone:  "Local",
+		Collector: collector.DefaultConfig(),
+		Enrichment: EnrichmentConfig{
+			ProcessCmdline: true,
+			ProcessUser:    true,
+		},
+		Store: StoreConfig{Capacity: 5000},
+		Alerts: AlertsConfig{
+			Capacity:   memory.DefaultAlertCapacity,
+			Window:     output.Duration(memory.DefaultAlertWindow),
+			MaxRecords: memory.DefaultAlertMaxRecords,
+		},
+		Console: ConsoleConfig{
+			Format: "auto",
+			Color:  "auto",
+		},
+		Output: OutputConfig{
+			Dir:            records.Dir,
+			MaxSizeMB:      records.MaxSizeMB,
+			MaxAgeDays:     records.MaxAgeDays,
+			MaxTotalSizeMB: records.MaxTotalSizeMB,
+			Compress:       records.Compress,
+			Alerts:         true,
+		},
+		Detection: detect.DefaultConfig(),
+		Metrics:   MetricsConfig{TopProcesses: 20},
+		Web: WebConfig{
+			Addr: "127.0.0.1",
+			Port: 58080,
+		},
+	}
+}
+
+// SinkConfigs 返回全部由配置创建的输出目标：JSONL 文件、控制台与额外的输出目标
+func (c *Config) SinkConfigs() []output.SinkConfig {
+	configs := make([]output.SinkConfig, 0, len(c.Sinks)+2)
+	if c.Output.Enabled == nil || *c.Output.Enabled {
+		timezone := c.Output.Timezone
+		if timezone == "" {
+			timezone = c.Timezone
+		}
+		sink := output.SinkConfig{
+			Name: "jsonl",
+			Type: "jsonl",
+			Options: map[string]any{
+				"dir":            filepath.Clean(c.Output.Dir),
+				"maxSizeMB":      c.Output.MaxSizeMB,
+				"maxAgeDays":     c.Output.MaxAgeDays,
+				"maxTotalSizeMB": c.Output.MaxTotalSizeMB,
+				"compress":       c.Output.Compress,
+				"timezone":       timezone,
+			},
+		}
+		if c.Output.Alerts {
+			sink.Alerts = output.AlertsInclude
+		}
+		configs = append(configs, sink)
+	}
+	if !c.Console.Quiet {
+		configs = append(configs, output.SinkConfig{
+			Name:   "console",
+			Type:   "console",
+			Format: c.Console.Format,
+			Options: map[string]any{
+				"color":    c.Console.Color,
+				"template": c.Console.Template,
+			},
+		})
+	}
+	return append(configs, c.Sinks...)
+}
+
+// Options 返回日志配置对应的 logger 选项
+func (l LogConfig) Options() logger.Options {
+	return logger.Options{
+		Level:     l.Level,
+		Format:    l.Format,
+		Stream:    l.Stream,
+		File:      l.File,
+		MaxSizeMB: l.MaxSizeMB,
+		MaxFiles:  l.MaxFiles,
+	}
+}
+
+// Options 返回告警配置对应的告警存储选项
+func (a AlertsConfig) Options() memory.AlertOptions {
+	return memory.AlertOptions{
+		Capacity:   a.Capacity,
+		Window:     a.Window.Std(),
+		MaxRecords: a.MaxRecords,
+	}
+}
+
+// Enrich 按附加信息配置调整记录
+func (e EnrichmentConfig) Enrich(record *model.DNSRecord) {
+	if !e.ProcessCmdline {
+		record.ProcessCmdline = ""
+	}
+	if !e.ProcessUser {
+		record.ProcessUser = ""
+	}
+	if e.Hostname != "" {
+		record.Hostname = e.Hostname
+	}
+}
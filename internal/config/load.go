@@ -0,0 +1,35 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// goTypeRef 解析错误中的 Go 类型名，对配置文件的使用者没有意义
+var goTypeRef = regexp.MustCompile(` in type [\w.\[\]]+`)
+
+// Load 读取并解析配置文件，未出现的配置项保持默认值；path 为空时返回默认配置
+// 未知的配置项视为错误，以便及早发现拼写错误
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("配置文件 %s 格式错误: %s", path, goTypeRef.ReplaceAllString(err.Error(), ""))
+	}
+	return cfg, nil
+}
@@ -0,0 +1,289 @@
+package config
+
+import (
+	"dnsflux/internal/detect/answer"
+	"dnsflux/internal/detect/baseline"
+	"dnsflux/internal/detect/intel"
+	"dnsflux/internal/detect/lookalike"
+	"dnsflux/internal/detect/sigma"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/output/jsonfile"
+	"dnsflux/internal/utils"
+	"dnsflux/pkg/logger"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Validate 校验配置，返回的错误包含全部问题，每条以配置项路径开头
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(path, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		fail("log.level", "无效的日志级别 %q，可选 debug、info、warn、error", c.Log.Level)
+	}
+	if !slices.Contains([]string{logger.FormatText, logger.FormatJSON}, c.Log.Format) {
+		fail("log.format", "无效的日志格式 %q，可选 text、json", c.Log.Format)
+	}
+	if !slices.Contains([]string{logger.StreamAuto, logger.StreamStdout, logger.StreamStderr, logger.StreamNone}, c.Log.Stream) {
+		fail("log.stream", "无效的日志输出位置 %q，可选 auto、stdout、stderr、none", c.Log.Stream)
+	}
+	if c.Log.Stream == logger.StreamNone && c.Log.File == "" {
+		fail("log.stream", "为 none 时必须指定 log.file，否则日志将被丢弃")
+	}
+	if c.Log.File != "" {
+		if c.Log.MaxSizeMB <= 0 {
+			fail("log.maxSizeMB", "必须大于 0")
+		}
+		if c.Log.MaxFiles < 0 {
+			fail("log.maxFiles", "不能为负数")
+		}
+	}
+	if _, err := utils.LoadLocation(c.Timezone); err != nil {
+		fail("timezone", "%v", err)
+	}
+	if c.Store.Capacity <= 0 {
+		fail("store.capacity", "必须大于 0")
+	}
+	if c.Alerts.Capacity <= 0 {
+		fail("alerts.capacity", "必须大于 0")
+	}
+	if c.Alerts.Window <= 0 {
+		fail("alerts.window", "必须大于 0")
+	}
+	if c.Alerts.MaxRecords <= 0 {
+		fail("alerts.maxRecords", "必须大于 0")
+	}
+
+	switch c.Console.Format {
+	case "", "auto", "template":
+	default:
+		if _, err := output.NewFormatter(c.Console.Format, ""); err != nil {
+			fail("console.format", "%v", err)
+		}
+	}
+	if c.Console.Format == "template" && c.Console.Template == "" {
+		fail("console.template", "console.format 为 template 时必须指定模板")
+	}
+	if !slices.Contains([]string{"", "auto", "always", "never"}, c.Console.Color) {
+		fail("console.color", "无效的颜色模式 %q，可选 auto、always、never", c.Console.Color)
+	}
+
+	if c.Output.Dir == "" {
+		fail("output.dir", "不能为空")
+	}
+	if !slices.Contains([]string{jsonfile.CompressNone, jsonfile.CompressGzip, jsonfile.CompressZstd}, c.Output.Compress) {
+		fail("output.compress", "无效的压缩算法 %q，可选 none、gzip、zstd", c.Output.Compress)
+	}
+	if c.Output.Timezone != "" {
+		if _, err := utils.LoadLocation(c.Output.Timezone); err != nil {
+			fail("output.timezone", "%v", err)
+		}
+	}
+
+	// 输出目标名称需唯一，内置的 JSONL 与控制台输出分别对应 output 与 console 配置段
+	names := make(map[string]string)
+	if c.Output.Enabled == nil || *c.Output.Enabled {
+		names["jsonl"] = "output"
+	}
+	if !c.Console.Quiet {
+		names["console"] = "console"
+	}
+	types := output.Types()
+	for i, sink := range c.Sinks {
+		path := fmt.Sprintf("sinks[%d]", i)
+		if sink.Type == "" {
+			fail(path+".type", "不能为空")
+		} else if !slices.Contains(types, sink.Type) {
+			fail(path+".type", "未知的输出目标类型 %q，可用类型: %s", sink.Type, strings.Join(types, ", "))
+		}
+		if sink.QueueSize < 0 {
+			fail(path+".queueSize", "不能为负数")
+		}
+		if !slices.Contains([]string{"", output.AlertsInclude, output.AlertsOnly}, sink.Alerts) {
+			fail(path+".alerts", "无效的告警输出模式 %q，可选 include、only", sink.Alerts)
+		}
+		if sink.MinSeverity != "" && model.SeverityRank(sink.MinSeverity) < 0 {
+			fail(path+".minSeverity", "无效的告警级别 %q，可选 %s", sink.MinSeverity, strings.Join(model.Severities, "、"))
+		}
+
+		name := sink.Name
+		if name == "" {
+			name = sink.Type
+		}
+		if prev, ok := names[name]; ok {
+			fail(path+".name", "输出目标名称 %q 与 %s 重复，请通过 name 区分", name, prev)
+		}
+		names[name] = path
+	}
+
+	feeds := make(map[string]string)
+	for i, feed := range c.Detection.Intel.Feeds {
+		path := fmt.Sprintf("detection.intel.feeds[%d]", i)
+		if feed.Path == "" {
+			fail(path+".path", "不能为空")
+		}
+		if feed.Format != "" && !slices.Contains(intel.Formats, feed.Format) {
+			fail(path+".format", "未知的情报格式 %q，可选 %s", feed.Format, strings.Join(intel.Formats, "、"))
+		}
+		name := feed.FeedName()
+		if prev, ok := feeds[name]; ok {
+			fail(path+".name", "情报源名称 %q 与 %s 重复，请通过 name 区分", name, prev)
+		}
+		feeds[name] = path
+	}
+	if t := c.Detection.DGA.Threshold; t < 0 || t > 1 {
+		fail("detection.dga.threshold", "必须在 0-1 之间")
+	}
+	if tunnel := c.Detection.Tunnel; tunnel.Enabled {
+		if tunnel.Window <= 0 {
+			fail("detection.tunnel.window", "必须大于 0")
+		}
+		if tunnel.UniqueSubdomains <= 0 {
+			fail("detection.tunnel.uniqueSubdomains", "必须大于 0")
+		}
+		if tunnel.EncodedBytes < 0 {
+			fail("detection.tunnel.encodedBytes", "不能为负数")
+		}
+		if tunnel.RecordShare < 0 || tunnel.RecordShare > 1 {
+			fail("detection.tunnel.recordShare", "必须在 0-1 之间")
+		}
+		if tunnel.Cooldown < 0 {
+			fail("detection.tunnel.cooldown", "不能为负数")
+		}
+		if tunnel.MaxTracked <= 0 {
+			fail("detection.tunnel.maxTracked", "必须大于 0")
+		}
+	}
+	if beacon := c.Detection.Beacon; beacon.Enabled {
+		if beacon.MinQueries < 3 {
+			fail("detection.beacon.minQueries", "不能小于 3")
+		}
+		if beacon.MaxHistory < beacon.MinQueries {
+			fail("detection.beacon.maxHistory", "不能小于 minQueries")
+		}
+		if beacon.MinInterval < 0 {
+			fail("detection.beacon.minInterval", "不能为负数")
+		}
+		if beacon.Threshold < 0 || beacon.Threshold > 1 {
+			fail("detection.beacon.threshold", "必须在 0-1 之间")
+		}
+		if beacon.Cooldown < 0 {
+			fail("detection.beacon.cooldown", "不能为负数")
+		}
+		if beacon.MaxIdle <= 0 {
+			fail("detection.beacon.maxIdle", "必须大于 0")
+		}
+		if beacon.MaxTracked <= 0 {
+			fail("detection.beacon.maxTracked", "必须大于 0")
+		}
+	}
+	if nod := c.Detection.NOD; nod.Enabled {
+		if nod.Learning < 0 {
+			fail("detection.nod.learning", "不能为负数")
+		}
+		if nod.Expiry < 0 {
+			fail("detection.nod.expiry", "不能为负数")
+		}
+		if nod.Expiry > 0 && nod.Expiry < nod.Learning {
+			fail("detection.nod.expiry", "不能小于 learning")
+		}
+		if nod.SaveInterval <= 0 {
+			fail("detection.nod.saveInterval", "必须大于 0")
+		}
+		if nod.MaxEntries <= 0 {
+			fail("detection.nod.maxEntries", "必须大于 0")
+		}
+	}
+	if b := c.Detection.Baseline; b.Enabled {
+		if !slices.Contains(baseline.Modes, b.Mode) {
+			fail("detection.baseline.mode", "未知的模式 %q，可选 %s", b.Mode, strings.Join(baseline.Modes, "、"))
+		}
+		if !slices.Contains(baseline.Keys, b.Key) {
+			fail("detection.baseline.key", "未知的进程标识 %q，可选 %s", b.Key, strings.Join(baseline.Keys, "、"))
+		}
+		if b.Path == "" {
+			fail("detection.baseline.path", "不能为空")
+		}
+		if b.Mode == baseline.ModeLearn && b.SaveInterval <= 0 {
+			fail("detection.baseline.saveInterval", "必须大于 0")
+		}
+		if b.Cooldown < 0 {
+			fail("detection.baseline.cooldown", "不能为负数")
+		}
+	}
+	if s := c.Detection.Sigma; len(s.Rules) > 0 {
+		for i, path := range s.Rules {
+			if path == "" {
+				fail(fmt.Sprintf("detection.sigma.rules[%d]", i), "不能为空")
+			}
+		}
+		if s.MinLevel != "" && !slices.Contains(sigma.Levels, s.MinLevel) {
+			fail("detection.sigma.minLevel", "未知的级别 %q，可选 %s", s.MinLevel, strings.Join(sigma.Levels, "、"))
+		}
+	}
+	if l := c.Detection.Lookalike; l.Enabled {
+		if len(l.Brands) == 0 {
+			fail("detection.lookalike.brands", "启用时不能为空")
+		}
+		for i, name := range l.Brands {
+			if _, ok := lookalike.Registrable(name); !ok {
+				fail(fmt.Sprintf("detection.lookalike.brands[%d]", i), "%q 不是可注册域名，应为 example.com 这样的域名", name)
+			}
+		}
+		if l.MaxDistance < 1 || l.MaxDistance > 3 {
+			fail("detection.lookalike.maxDistance", "必须在 1-3 之间")
+		}
+		if l.MinLength <= 0 {
+			fail("detection.lookalike.minLength", "必须大于 0")
+		}
+		if l.Cooldown < 0 {
+			fail("detection.lookalike.cooldown", "不能为负数")
+		}
+	}
+	if a := c.Detection.Answer; a.Enabled {
+		if a.FluxAddresses < 0 {
+			fail("detection.answer.fluxAddresses", "不能为负数")
+		}
+		if a.FluxAddresses > 0 {
+			if a.FluxWindow <= 0 {
+				fail("detection.answer.fluxWindow", "必须大于 0")
+			}
+			if a.FluxNetworks <= 0 {
+				fail("detection.answer.fluxNetworks", "必须大于 0")
+			}
+			if a.FluxNetworks > a.FluxAddresses {
+				fail("detection.answer.fluxNetworks", "不能大于 fluxAddresses")
+			}
+			if a.MaxTracked <= 0 {
+				fail("detection.answer.maxTracked", "必须大于 0")
+			}
+		}
+		if a.LowTTL < 0 {
+			fail("detection.answer.lowTTL", "不能为负数")
+		}
+		for i, entry := range a.Sinkholes {
+			if _, err := answer.ParseSinkhole(strings.TrimSpace(entry)); err != nil {
+				fail(fmt.Sprintf("detection.answer.sinkholes[%d]", i), "无效的地址或网段 %q", entry)
+			}
+		}
+		if a.Cooldown < 0 {
+			fail("detection.answer.cooldown", "不能为负数")
+		}
+	}
+
+	if c.Metrics.TopProcesses < 0 {
+		fail("metrics.topProcesses", "不能为负数")
+	}
+	if c.Web.Port <= 0 || c.Web.Port > 65535 {
+		fail("web.port", "必须在 1-65535 之间")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+package detect
+
+import (
+	"dnsflux/internal/detect/psl"
+	"dnsflux/internal/model"
+	"strings"
+	"time"
+)
+
+// NewAlert 将记录上的检测器告警转换为告警事件
+// 去重键由主机、检测器、规则、进程与可注册域名组成，同一进程反复解析同一域名的子域名时合并为一条告警
+func NewAlert(record *model.DNSRecord, ra model.RecordAlert) model.Alert {
+	at := record.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	severity := ra.Severity
+	if severity == "" {
+		severity = model.SeverityMedium
+	}
+	return model.Alert{
+		Key:         alertKey(record, ra),
+		Detector:    ra.Detector,
+		Rule:        ra.Rule,
+		Severity:    severity,
+		Technique:   ra.Technique,
+		Message:     ra.Message,
+		Fields:      ra.Fields,
+		Hostname:    record.Hostname,
+		ClientIP:    record.ClientIP,
+		QueryName:   record.QueryName,
+		QueryType:   record.QueryType,
+		ProcessID:   record.ProcessID,
+		ProcessName: record.ProcessName,
+		ProcessPath: record.ProcessPath,
+		RecordIDs:   []string{record.ID},
+		Count:       1,
+		FirstSeen:   at,
+		LastSeen:    at,
+		Status:      model.AlertOpen,
+		UpdatedAt:   at,
+	}
+}
+
+// alertKey 返回告警的去重键；进程以路径区分，没有进程信息时使用客户端地址
+func alertKey(record *model.DNSRecord, ra model.RecordAlert) string {
+	process := record.ProcessPath
+	if process == "" {
+		process = record.ProcessName
+	}
+	if process == "" {
+		process = record.ClientIP
+	}
+	domain := ra.Fields["domain"]
+	if domain == "" {
+		name := psl.Normalize(record.QueryName)
+		if _, registrable, ok := psl.Split(name); ok {
+			domain = registrable
+		} else {
+			domain = name
+		}
+	}
+	return strings.Join([]string{record.Hostname, ra.Detector, ra.Rule, strings.ToLower(process), domain}, "|")
+}
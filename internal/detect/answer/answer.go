@@ -0,0 +1,71 @@
+// Package answer 分析解析出的应答记录
+// 发现 A/AAAA 地址在大量不相关网段间快速变化的域名（fast-flux）、TTL 极低的应答、
+// 公网域名返回内网或保留地址（DNS 重绑定），以及返回已知 sinkhole 地址的应答
+package answer
+
+import (
+	"dnsflux/internal/output"
+	"time"
+)
+
+// 告警类别
+const (
+	CategoryFastFlux  = "fast-flux"
+	CategoryLowTTL    = "low-ttl"
+	CategoryRebinding = "rebinding"
+	CategorySinkhole  = "sinkhole"
+)
+
+// Categories 全部告警类别
+var Categories = []string{CategoryFastFlux, CategoryLowTTL, CategoryRebinding, CategorySinkhole}
+
+// 默认配置
+const (
+	DefaultFluxWindow    = time.Hour
+	DefaultFluxAddresses = 12
+	DefaultFluxNetworks  = 8
+	DefaultLowTTL        = 5 * time.Second
+	DefaultCooldown      = time.Hour
+	DefaultMaxTracked    = 10000
+)
+
+// Config 应答分析配置
+type Config struct {
+	Enabled       bool            `yaml:"enabled"`
+	FluxWindow    output.Duration `yaml:"fluxWindow"`    // 统计同一名称解析地址的滑动窗口
+	FluxAddresses int             `yaml:"fluxAddresses"` // 窗口内不同地址数下限，0 表示不检测 fast-flux
+	FluxNetworks  int             `yaml:"fluxNetworks"`  // 窗口内地址分布的不同网段数下限（IPv4 /16、IPv6 /32）
+	LowTTL        output.Duration `yaml:"lowTTL"`        // A/AAAA 应答的 TTL 低于该值时告警，0 表示不检测
+	Rebinding     bool            `yaml:"rebinding"`     // 公网域名返回内网、回环、链路本地或保留地址时告警
+	Sinkholes     []string        `yaml:"sinkholes"`     // sinkhole 地址或网段，如 192.0.2.1、198.51.100.0/24
+	Cooldown      output.Duration `yaml:"cooldown"`      // 同一名称同一类别两次告警的最小间隔
+	MaxTracked    int             `yaml:"maxTracked"`    // fast-flux 同时跟踪的名称上限，超过时淘汰最久未解析的名称
+	Allow         []string        `yaml:"allow"`         // 不检测的可注册域名，如 CDN 与 nip.io 这类把地址编码在名称中的服务
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Enabled:       true,
+		FluxWindow:    output.Duration(DefaultFluxWindow),
+		FluxAddresses: DefaultFluxAddresses,
+		FluxNetworks:  DefaultFluxNetworks,
+		LowTTL:        output.Duration(DefaultLowTTL),
+		Rebinding:     true,
+		Cooldown:      output.Duration(DefaultCooldown),
+		MaxTracked:    DefaultMaxTracked,
+	}
+}
+
+// Finding 一次应答命中的告警类别
+type Finding struct {
+	Category  string
+	Name      string   // 查询名称
+	Owner     string   // 地址所属的名称，经过 CNAME 时为最后一个 CNAME 的目标
+	Domain    string   // 查询名称的可注册域名
+	Addresses []string // 命中的地址；fast-flux 为窗口内的全部地址
+	TTL       uint32   // low-ttl 命中的最小 TTL（秒）
+	Networks  int      // fast-flux 窗口内的不同网段数
+	Window    time.Duration
+	Range     string // rebinding 命中的保留地址段或 sinkhole 命中的配置项
+}
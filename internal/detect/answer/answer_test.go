@@ -0,0 +1,142 @@
+package answer
+
+import (
+	"dnsflux/internal/model"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+// response 返回 name 的应答记录，answers 为 A/AAAA 地址或 CNAME 目标
+func response(name string, answers ...string) *model.DNSRecord {
+	r := &model.DNSRecord{Timestamp: start, QueryName: name, QueryType: "A"}
+	for _, data := range answers {
+		r.Answers = append(r.Answers, model.DNSAnswer{Type: model.AnswerTypeOf(data, "CNAME"), Data: data, TTL: 300})
+	}
+	return r
+}
+
+// describe 将结果转换为“类别 地址所属名称 命中范围 地址”形式
+func describe(findings []Finding) []string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, fmt.Sprintf("%s %s %s %v", f.Category, f.Owner, f.Range, f.Addresses))
+	}
+	return out
+}
+
+func TestRebindingAndSinkhole(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		record *model.DNSRecord
+		want   []string
+	}{
+		{"公网域名返回内网地址", nil, response("evil.com", "10.0.0.5"), []string{"rebinding evil.com 10.0.0.0/8 [10.0.0.5]"}},
+		{"IPv6 回环地址", nil, response("evil.com", "::1"), []string{"rebinding evil.com ::1/128 [::1]"}},
+		{"IPv4 映射的回环地址", nil, response("evil.com", "::ffff:127.0.0.1"), []string{"rebinding evil.com 127.0.0.0/8 [127.0.0.1]"}},
+		{"只列出保留地址", nil, response("evil.com", "93.184.216.34", "192.168.1.1", "172.16.0.1"), []string{"rebinding evil.com 192.168.0.0/16 [192.168.1.1 172.16.0.1]"}},
+		{"经过 CNAME", nil, response("www.evil.com", "target.evil.net", "169.254.169.254"), []string{"rebinding target.evil.net 169.254.0.0/16 [169.254.169.254]"}},
+		{"过滤型解析器的未指定地址", nil, response("ads.com", "0.0.0.0", "::"), nil},
+		{"公网地址", nil, response("example.com", "93.184.216.34"), nil},
+		{"内部使用的后缀", nil, response("nas.lan", "192.168.1.2"), nil},
+		{"单标签名称", nil, response("router", "192.168.1.1"), nil},
+		{"允许的域名", nil, response("10-0-0-1.nip.io", "10.0.0.1"), nil},
+		{"CNAME 到允许的域名", nil, response("app.example.com", "10-0-0-1.nip.io", "10.0.0.1"), nil},
+		{"未开启重绑定检测", func(cfg *Config) { cfg.Rebinding = false }, response("evil.com", "10.0.0.5"), nil},
+		{"sinkhole 地址", nil, response("c2.com", "131.253.18.11"), []string{"sinkhole c2.com 131.253.18.11 [131.253.18.11]"}},
+		{"sinkhole 网段", nil, response("c2.com", "199.2.137.5", "93.184.216.34"), []string{"sinkhole c2.com 199.2.137.0/24 [199.2.137.5]"}},
+		{"保留地址段中的 sinkhole 不算重绑定", nil, response("c2.com", "192.0.2.1", "10.0.0.5"), []string{
+			"sinkhole c2.com 192.0.2.1 [192.0.2.1]",
+			"rebinding c2.com 10.0.0.0/8 [10.0.0.5]",
+		}},
+		{"IPv4 映射格式的 sinkhole 网段", nil, response("c2.com", "10.66.3.4"), []string{"sinkhole c2.com ::ffff:10.66.0.0/112 [10.66.3.4]"}},
+		{"内部名称的 sinkhole 应答", nil, response("host.lan", "131.253.18.11"), []string{"sinkhole host.lan 131.253.18.11 [131.253.18.11]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.LowTTL, cfg.FluxAddresses = 0, 0
+			cfg.Allow = []string{"nip.io"}
+			cfg.Sinkholes = []string{"131.253.18.11", "199.2.137.0/24", "192.0.2.1", "::ffff:10.66.0.0/112", "not-an-address"}
+			if tt.modify != nil {
+				tt.modify(&cfg)
+			}
+			if got := describe(New(cfg).Observe(tt.record)); !slices.Equal(got, tt.want) {
+				t.Errorf("Observe() = %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	d := New(DefaultConfig())
+	observe := func(name string, offset time.Duration) int {
+		r := response(name, "10.0.0.5")
+		r.Timestamp = start.Add(offset)
+		return len(d.Observe(r))
+	}
+	if observe("evil.com", 0) != 1 {
+		t.Fatal("首次应答没有告警")
+	}
+	if observe("evil.com", time.Minute) != 0 {
+		t.Error("告警间隔内重复告警")
+	}
+	if observe("other.com", time.Minute) != 1 {
+		t.Error("告警间隔按名称计算")
+	}
+	if observe("evil.com", DefaultCooldown) != 1 {
+		t.Error("告警间隔过后没有再次告警")
+	}
+}
+
+func TestLowTTLAndFastFlux(t *testing.T) {
+	d := New(DefaultConfig())
+	low := response("flux.com", "93.184.216.34")
+	low.Answers[0].TTL = 1
+	if got := describe(d.Observe(low)); !slices.Equal(got, []string{"low-ttl flux.com  [93.184.216.34]"}) {
+		t.Errorf("低 TTL = %v", got)
+	}
+
+	// 窗口内 12 个地址分布在 12 个 /16 网段
+	var findings []Finding
+	for i := range DefaultFluxAddresses {
+		r := response("flux.com", fmt.Sprintf("%d.%d.1.1", 20+i, i))
+		r.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		findings = append(findings, d.Observe(r)...)
+	}
+	if len(findings) != 1 || findings[0].Category != CategoryFastFlux ||
+		len(findings[0].Addresses) != DefaultFluxAddresses || findings[0].Networks != DefaultFluxAddresses {
+		t.Errorf("fast-flux = %+v", findings)
+	}
+}
+
+func TestParseSinkhole(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"IPv4 地址", "192.0.2.1", "192.0.2.1/32", false},
+		{"IPv6 地址", "2001:db8::1", "2001:db8::1/128", false},
+		{"IPv4 映射地址", "::ffff:192.0.2.1", "192.0.2.1/32", false},
+		{"网段按掩码对齐", "198.51.100.7/24", "198.51.100.0/24", false},
+		{"IPv4 映射网段", "::ffff:10.66.0.0/112", "10.66.0.0/16", false},
+		{"不是地址", "sinkhole.example", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseSinkhole(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSinkhole(%s) error = %v", tt.in, err)
+			}
+			if err == nil && p.String() != tt.want {
+				t.Errorf("ParseSinkhole(%s) = %s, want %s", tt.in, p, tt.want)
+			}
+		})
+	}
+}
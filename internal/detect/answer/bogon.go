@@ -0,0 +1,80 @@
+package answer
+
+import (
+	"net/netip"
+)
+
+// bogons 不应出现在公网域名应答中的地址段：内网、回环、链路本地、运营商级 NAT、
+// 文档示例、基准测试、组播与保留地址
+// 未指定地址 0.0.0.0 与 :: 不在其中，过滤型解析器常以此应答被拦截的域名
+var bogons = prefixes(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::1/128",
+	"100::/64",
+	"2001:db8::/32",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// prefixes 解析地址段列表
+func prefixes(list ...string) []netip.Prefix {
+	result := make([]netip.Prefix, len(list))
+	for i, s := range list {
+		result[i] = netip.MustParsePrefix(s)
+	}
+	return result
+}
+
+// bogon 返回地址所在的保留地址段
+func bogon(addr netip.Addr) (netip.Prefix, bool) {
+	if addr.IsUnspecified() {
+		return netip.Prefix{}, false
+	}
+	for _, p := range bogons {
+		if p.Contains(addr) {
+			return p, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// ParseSinkhole 解析 sinkhole 配置项，单个地址视为只包含该地址的网段
+func ParseSinkhole(s string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr, bits := p.Addr(), p.Bits()
+	if addr.Is4In6() && bits >= 96 {
+		addr, bits = addr.Unmap(), bits-96
+	}
+	return netip.PrefixFrom(addr, bits).Masked(), nil
+}
+
+// network 返回地址所在的网段，IPv4 按 /16、IPv6 按 /32 划分
+func network(addr netip.Addr) netip.Prefix {
+	bits := 32
+	if addr.Is4() {
+		bits = 16
+	}
+	p, _ := addr.Prefix(bits)
+	return p
+}
@@ -0,0 +1,291 @@
+package answer
+
+import (
+	"dnsflux/internal/detect/psl"
+	"dnsflux/internal/model"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxAlerted 保存告警时间的条目上限，超过时清理已过告警间隔的条目
+const maxAlerted = 100000
+
+// maxAddresses 单个名称在窗口内保留的地址数上限，超过时不再加入新地址
+const maxAddresses = 1000
+
+// Detector 分析记录中的 A/AAAA 应答，可并发使用
+type Detector struct {
+	config    Config
+	window    time.Duration
+	cooldown  time.Duration
+	lowTTL    uint32 // 秒
+	sinkholes []sinkhole
+	allow     map[string]bool
+
+	mu        sync.Mutex
+	fluxes    map[string]*flux
+	alerted   map[alertKey]time.Time
+	lastSweep time.Time
+}
+
+// sinkhole 一个 sinkhole 配置项
+type sinkhole struct {
+	prefix netip.Prefix
+	entry  string
+}
+
+// flux 一个名称在窗口内解析到的地址及最近出现时间
+type flux struct {
+	addresses map[netip.Addr]time.Time
+	last      time.Time
+}
+
+// alertKey 告警间隔按类别与名称计算
+type alertKey struct {
+	category string
+	name     string
+}
+
+// address 应答中的一个地址
+type address struct {
+	addr netip.Addr
+	ttl  uint32
+}
+
+// New 创建应答分析器，无法解析的 sinkhole 配置项被忽略
+func New(cfg Config) *Detector {
+	d := &Detector{
+		config:   cfg,
+		window:   cfg.FluxWindow.Std(),
+		cooldown: cfg.Cooldown.Std(),
+		lowTTL:   uint32(cfg.LowTTL.Std() / time.Second),
+		allow:    make(map[string]bool, len(cfg.Allow)),
+		fluxes:   make(map[string]*flux),
+		alerted:  make(map[alertKey]time.Time),
+	}
+	for _, entry := range cfg.Sinkholes {
+		if p, err := ParseSinkhole(strings.TrimSpace(entry)); err == nil {
+			d.sinkholes = append(d.sinkholes, sinkhole{prefix: p, entry: entry})
+		}
+	}
+	for _, domain := range cfg.Allow {
+		d.allow[psl.Normalize(domain)] = true
+	}
+	return d
+}
+
+// Observe 分析一条记录的应答，返回命中且不在告警间隔内的类别
+// 经过 CNAME 的地址归属于最后一个 CNAME 的目标名称，fast-flux 按该名称统计
+func (d *Detector) Observe(record *model.DNSRecord) []Finding {
+	name := psl.Normalize(record.QueryName)
+	_, domain, public := psl.Split(name)
+	if public && d.allow[domain] {
+		return nil
+	}
+	owner := name
+	var addresses []address
+	for _, a := range record.Answers {
+		switch strings.ToUpper(model.AnswerTypeOf(a.Data, a.Type)) {
+		case "CNAME":
+			owner = psl.Normalize(a.Data)
+		case "A", "AAAA":
+			if addr, err := netip.ParseAddr(a.Data); err == nil {
+				addresses = append(addresses, address{addr: addr.Unmap(), ttl: a.TTL})
+			}
+		}
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+	public = public && psl.Public(name)
+	if _, ownerDomain, ok := psl.Split(owner); ok && d.allow[ownerDomain] {
+		public = false
+	}
+	at := record.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	base := Finding{Name: name, Owner: owner, Domain: domain}
+	var findings []Finding
+	report := func(f Finding, key string) {
+		if d.alert(alertKey{category: f.Category, name: key}, at) {
+			findings = append(findings, f)
+		}
+	}
+
+	// sinkhole 地址不再计入重绑定与 fast-flux
+	var sinkholed []string
+	var entry string
+	usable := addresses[:0:0]
+	for _, a := range addresses {
+		if s, ok := d.sinkhole(a.addr); ok {
+			sinkholed = append(sinkholed, a.addr.String())
+			if entry == "" {
+				entry = s
+			}
+			continue
+		}
+		usable = append(usable, a)
+	}
+	if len(sinkholed) > 0 {
+		f := base
+		f.Category, f.Addresses, f.Range = CategorySinkhole, sinkholed, entry
+		report(f, name)
+	}
+	if !public {
+		return findings
+	}
+
+	if d.config.Rebinding {
+		var private []string
+		var prefix netip.Prefix
+		for _, a := range usable {
+			if p, ok := bogon(a.addr); ok {
+				private = append(private, a.addr.String())
+				if !prefix.IsValid() {
+					prefix = p
+				}
+			}
+		}
+		if len(private) > 0 {
+			f := base
+			f.Category, f.Addresses, f.Range = CategoryRebinding, private, prefix.String()
+			report(f, name)
+		}
+	}
+
+	if d.lowTTL > 0 {
+		var low []string
+		var ttl uint32
+		for _, a := range usable {
+			// TTL 为 0 表示未知，ETW 采集的应答不含 TTL
+			if a.ttl > 0 && a.ttl < d.lowTTL {
+				low = append(low, a.addr.String())
+				if ttl == 0 || a.ttl < ttl {
+					ttl = a.ttl
+				}
+			}
+		}
+		if len(low) > 0 {
+			f := base
+			f.Category, f.Addresses, f.TTL = CategoryLowTTL, low, ttl
+			report(f, name)
+		}
+	}
+
+	if d.config.FluxAddresses > 0 {
+		if f, ok := d.observeFlux(owner, usable, at); ok {
+			f.Name, f.Owner, f.Domain = name, owner, domain
+			report(f, owner)
+		}
+	}
+	return findings
+}
+
+// observeFlux 将公网地址计入名称的窗口统计，地址数与网段数均达到阈值时返回 true
+func (d *Detector) observeFlux(owner string, addresses []address, at time.Time) (Finding, bool) {
+	d.sweep(at)
+	fl := d.fluxes[owner]
+	if fl == nil {
+		fl = &flux{addresses: make(map[netip.Addr]time.Time)}
+		d.fluxes[owner] = fl
+	}
+	if at.After(fl.last) {
+		fl.last = at
+	}
+	cutoff := at.Add(-d.window)
+	for addr, seen := range fl.addresses {
+		if seen.Before(cutoff) {
+			delete(fl.addresses, addr)
+		}
+	}
+	for _, a := range addresses {
+		if _, ok := bogon(a.addr); ok || a.addr.IsUnspecified() {
+			continue
+		}
+		if _, ok := fl.addresses[a.addr]; ok || len(fl.addresses) < maxAddresses {
+			fl.addresses[a.addr] = at
+		}
+	}
+	if len(fl.addresses) < d.config.FluxAddresses {
+		return Finding{}, false
+	}
+	networks := make(map[netip.Prefix]bool)
+	for addr := range fl.addresses {
+		networks[network(addr)] = true
+	}
+	if len(networks) < d.config.FluxNetworks {
+		return Finding{}, false
+	}
+	all := make([]netip.Addr, 0, len(fl.addresses))
+	for addr := range fl.addresses {
+		all = append(all, addr)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Less(all[j]) })
+	list := make([]string, len(all))
+	for i, addr := range all {
+		list[i] = addr.String()
+	}
+	return Finding{
+		Category:  CategoryFastFlux,
+		Addresses: list,
+		Networks:  len(networks),
+		Window:    d.window,
+	}, true
+}
+
+// sinkhole 返回地址命中的 sinkhole 配置项
+func (d *Detector) sinkhole(addr netip.Addr) (string, bool) {
+	for _, s := range d.sinkholes {
+		if s.prefix.Contains(addr) {
+			return s.entry, true
+		}
+	}
+	return "", false
+}
+
+// sweep 每个窗口清理一次不再解析的名称，跟踪的名称超过上限时淘汰最久未解析的名称
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.window && len(d.fluxes) <= d.config.MaxTracked {
+		return
+	}
+	d.lastSweep = now
+	for name, fl := range d.fluxes {
+		if now.Sub(fl.last) > d.window {
+			delete(d.fluxes, name)
+		}
+	}
+	for len(d.fluxes) > d.config.MaxTracked {
+		var oldest string
+		var last time.Time
+		for name, fl := range d.fluxes {
+			if last.IsZero() || fl.last.Before(last) {
+				oldest, last = name, fl.last
+			}
+		}
+		delete(d.fluxes, oldest)
+	}
+}
+
+// alert 判断该类别与名称是否不在告警间隔内，是则记录告警时间
+func (d *Detector) alert(key alertKey, at time.Time) bool {
+	if last, ok := d.alerted[key]; ok && at.Sub(last) < d.cooldown {
+		return false
+	}
+	if len(d.alerted) >= maxAlerted {
+		for k, last := range d.alerted {
+			if at.Sub(last) >= d.cooldown {
+				delete(d.alerted, k)
+			}
+		}
+	}
+	d.alerted[key] = at
+	return true
+}
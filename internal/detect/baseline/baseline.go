@@ -0,0 +1,163 @@
+// Package baseline 为每个进程建立解析域名的基线，并在进程解析基线之外的域名时告警
+// 服务器上的大多数进程只与少量固定的域名通信，如 apt 只访问镜像站。学习模式按进程路径、名称或可执行文件的
+// SHA-256 记录其解析过的可注册域名与查询类型并写入 YAML 文件，审阅后切换为强制模式，
+// 进程解析基线之外的域名或使用基线之外的查询类型时产生告警。基线文件可以导出、修改并纳入版本管理
+package baseline
+
+import (
+	"bytes"
+	"dnsflux/internal/output"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 运行模式
+const (
+	ModeLearn   = "learn"   // 记录进程解析的域名并写入基线文件
+	ModeEnforce = "enforce" // 按基线文件检查，进程解析基线之外的域名时告警
+)
+
+// Modes 全部运行模式
+var Modes = []string{ModeLearn, ModeEnforce}
+
+// 进程标识
+const (
+	KeyPath   = "path"   // 可执行文件路径
+	KeyName   = "name"   // 进程名称
+	KeySHA256 = "sha256" // 可执行文件内容的 SHA-256，文件无法读取时使用路径
+)
+
+// Keys 全部进程标识
+var Keys = []string{KeyPath, KeyName, KeySHA256}
+
+// Any 基线中匹配任意域名或查询类型的条目
+const Any = "*"
+
+// 默认配置
+const (
+	DefaultPath         = "data/baseline.yaml"
+	DefaultSaveInterval = 5 * time.Minute
+	DefaultCooldown     = time.Hour
+)
+
+// Config 进程基线配置
+type Config struct {
+	Enabled      bool            `yaml:"enabled"`
+	Mode         string          `yaml:"mode"`         // learn 或 enforce
+	Key          string          `yaml:"key"`          // 进程标识：path、name 或 sha256
+	Path         string          `yaml:"path"`         // 基线文件，学习模式写入，强制模式读取
+	SaveInterval output.Duration `yaml:"saveInterval"` // 学习模式写入基线文件的间隔，退出时也会写入
+	AlertUnknown bool            `yaml:"alertUnknown"` // 强制模式下基线中没有的进程解析域名时也告警
+	Cooldown     output.Duration `yaml:"cooldown"`     // 同一进程对同一域名或查询类型两次告警的最小间隔
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Mode:         ModeLearn,
+		Key:          KeyPath,
+		Path:         DefaultPath,
+		SaveInterval: output.Duration(DefaultSaveInterval),
+		Cooldown:     output.Duration(DefaultCooldown),
+	}
+}
+
+// Baseline 基线文件内容
+type Baseline struct {
+	Key       string    `yaml:"key"` // 生成基线时使用的进程标识
+	Processes []Profile `yaml:"processes"`
+}
+
+// Profile 一个进程的基线
+type Profile struct {
+	Process    string   `yaml:"process"`        // 进程路径、名称或 SHA-256，取决于 key
+	Path       string   `yaml:"path,omitempty"` // key 为 sha256 时记录最近的可执行文件路径，便于审阅
+	Domains    []string `yaml:"domains"`        // 可注册域名，同时匹配其子域名，* 匹配任意域名
+	QueryTypes []string `yaml:"queryTypes"`     // 查询类型，* 匹配任意类型
+}
+
+// header 写入基线文件开头的说明
+const header = `# dnsflux 进程域名基线
+# domains 中的条目同时匹配其子域名，* 匹配任意域名；queryTypes 中的 * 匹配任意查询类型
+`
+
+// ReadFile 读取基线文件
+func ReadFile(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取基线文件失败: %w", err)
+	}
+	var b Baseline
+	if err := yaml.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("解析基线文件 %s 失败: %w", path, err)
+	}
+	if b.Key == "" {
+		b.Key = KeyPath
+	}
+	if !slices.Contains(Keys, b.Key) {
+		return nil, fmt.Errorf("基线文件 %s 中的 key %q 无效，可选 %s", path, b.Key, strings.Join(Keys, "、"))
+	}
+	for i, p := range b.Processes {
+		if p.Process == "" {
+			return nil, fmt.Errorf("基线文件 %s 中第 %d 个进程缺少 process", path, i+1)
+		}
+	}
+	return &b, nil
+}
+
+// Marshal 将基线编码为 YAML，进程、域名与查询类型均按字母顺序排列，便于比较版本差异
+func (b *Baseline) Marshal() ([]byte, error) {
+	sorted := Baseline{Key: b.Key, Processes: make([]Profile, len(b.Processes))}
+	for i, p := range b.Processes {
+		p.Domains = slices.Sorted(slices.Values(p.Domains))
+		p.QueryTypes = slices.Sorted(slices.Values(p.QueryTypes))
+		sorted.Processes[i] = p
+	}
+	slices.SortFunc(sorted.Processes, func(a, b Profile) int { return strings.Compare(a.Process, b.Process) })
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&sorted); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile 将基线写入文件，先写入同目录下的临时文件再重命名
+func (b *Baseline) WriteFile(path string) error {
+	data, err := b.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入基线文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package baseline
+
+import (
+	"crypto/sha256"
+	"dnsflux/internal/model"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+// query 返回 path 处的进程查询 name 的记录
+func query(path, name, qtype string) *model.DNSRecord {
+	return &model.DNSRecord{
+		Timestamp:   start,
+		QueryName:   name,
+		QueryType:   qtype,
+		ProcessName: filepath.Base(path),
+		ProcessID:   1,
+		ProcessPath: path,
+	}
+}
+
+// deviations 将偏离转换为“类型:值”形式
+func deviations(list []Deviation) []string {
+	var out []string
+	for _, d := range list {
+		switch d.Kind {
+		case KindDomain:
+			out = append(out, d.Kind+":"+d.Domain)
+		case KindQueryType:
+			out = append(out, d.Kind+":"+d.QueryType)
+		default:
+			out = append(out, d.Kind+":"+d.Process)
+		}
+	}
+	return out
+}
+
+func TestMarshal(t *testing.T) {
+	b := &Baseline{Key: KeySHA256, Processes: []Profile{
+		{Process: "bbbb", Path: "/usr/bin/curl", Domains: []string{"example.org", "example.com"}, QueryTypes: []string{"AAAA", "A"}},
+		{Process: "aaaa", Domains: []string{Any}, QueryTypes: []string{}},
+	}}
+	data, err := b.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := header + `key: sha256
+processes:
+  - process: aaaa
+    domains:
+      - '*'
+    queryTypes: []
+  - process: bbbb
+    path: /usr/bin/curl
+    domains:
+      - example.com
+      - example.org
+    queryTypes:
+      - A
+      - AAAA
+`
+	if string(data) != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", data, want)
+	}
+	// 编码时不修改原基线
+	if b.Processes[0].Process != "bbbb" || b.Processes[0].Domains[0] != "example.org" {
+		t.Errorf("Marshal() 修改了原基线: %+v", b.Processes)
+	}
+
+	path := filepath.Join(t.TempDir(), "sub", "baseline.yaml")
+	if err := b.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := got.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != want {
+		t.Errorf("读回后重新编码 =\n%s", again)
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		data    string
+		wantKey string
+		wantErr string
+	}{
+		{"未指定 key 时按路径", "processes:\n  - process: /usr/bin/curl\n", KeyPath, ""},
+		{"无效的 key", "key: pid\n", "", "key \"pid\" 无效"},
+		{"缺少 process", "processes:\n  - domains: [example.com]\n", "", "第 1 个进程缺少 process"},
+		{"YAML 格式错误", "processes: [", "", "解析基线文件"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "baseline.yaml")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			b, err := ReadFile(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ReadFile() error = %v, want 包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.Key != tt.wantKey {
+				t.Errorf("key = %s, want %s", b.Key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestLearnAndEnforce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.yaml")
+	learn := DefaultConfig()
+	learn.Path, learn.SaveInterval = path, 0
+	d, err := New(learn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*model.DNSRecord{
+		query("/usr/bin/apt", "Deb.Debian.org.", "A"),
+		query("/usr/bin/apt", "security.debian.org", "AAAA"),
+		query("/usr/bin/curl", "api.example.com", "A"),
+		query("/usr/bin/curl", "10.0.0.10.in-addr.arpa", "PTR"),
+		query("", "no-process.example.com", "A"),
+	} {
+		if got := d.Observe(r); got != nil {
+			t.Errorf("学习模式返回偏离 %v", got)
+		}
+	}
+	// 退出时导出基线
+	d.Close()
+
+	exported, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Profile{
+		{Process: "/usr/bin/apt", Domains: []string{"debian.org"}, QueryTypes: []string{"A", "AAAA"}},
+		{Process: "/usr/bin/curl", Domains: []string{"example.com", "in-addr.arpa"}, QueryTypes: []string{"A", "PTR"}},
+	}
+	if exported.Key != KeyPath || !reflect.DeepEqual(exported.Processes, want) {
+		t.Fatalf("导出的基线 = %+v", exported)
+	}
+
+	// 审阅后放开 curl 的查询类型，再以强制模式导入
+	exported.Processes[1].QueryTypes = []string{Any}
+	if err := exported.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	enforce := learn
+	enforce.Mode, enforce.AlertUnknown = ModeEnforce, true
+	d, err = New(enforce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	tests := []struct {
+		name   string
+		record *model.DNSRecord
+		want   []string
+	}{
+		{"基线中的域名", query("/usr/bin/apt", "ftp.us.debian.org", "A"), nil},
+		{"基线之外的域名", query("/usr/bin/apt", "evil.example.net", "A"), []string{"domain:example.net"}},
+		{"告警间隔内不重复告警", query("/usr/bin/apt", "www.example.net", "A"), nil},
+		{"基线之外的查询类型", query("/usr/bin/apt", "deb.debian.org", "TXT"), []string{"queryType:TXT"}},
+		{"域名与查询类型同时偏离", query("/usr/bin/apt", "c2.example.io", "NULL"), []string{"domain:example.io", "queryType:NULL"}},
+		{"任意查询类型", query("/usr/bin/curl", "api.example.com", "TXT"), nil},
+		{"反向解析", query("/usr/bin/curl", "1.0.0.10.in-addr.arpa", "PTR"), nil},
+		{"基线中没有的进程", query("/tmp/dropper", "example.com", "A"), []string{"process:/tmp/dropper"}},
+		{"没有进程信息", query("", "example.com", "A"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deviations(d.Observe(tt.record)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Observe() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 基线文件修改后需要重新加载
+	if d.Stale() {
+		t.Error("基线文件未修改时 Stale() = true")
+	}
+	if err := os.WriteFile(path, []byte("key: path\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Stale() {
+		t.Error("基线文件修改后 Stale() = false")
+	}
+}
+
+func TestSHA256Key(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "agent")
+	content := []byte("#!/bin/sh\n")
+	if err := os.WriteFile(exe, content, 0755); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(content)
+	sum := hex.EncodeToString(digest[:])
+
+	cfg := DefaultConfig()
+	cfg.Key, cfg.Path, cfg.SaveInterval = KeySHA256, "", 0
+	d, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Observe(query(exe, "example.com", "A"))
+	// 文件无法读取时使用路径
+	d.Observe(query(filepath.Join(dir, "missing"), "example.org", "A"))
+
+	got := map[string]string{}
+	for _, p := range d.Baseline().Processes {
+		got[p.Process] = p.Path
+	}
+	want := map[string]string{sum: exe, filepath.Join(dir, "missing"): filepath.Join(dir, "missing")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("进程标识 = %v, want %v", got, want)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	dir := t.TempDir()
+	byName := filepath.Join(dir, "by-name.yaml")
+	if err := os.WriteFile(byName, []byte("key: name\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"强制模式未指定基线文件", Config{Mode: ModeEnforce, Key: KeyPath}},
+		{"强制模式基线文件不存在", Config{Mode: ModeEnforce, Key: KeyPath, Path: filepath.Join(dir, "missing.yaml")}},
+		{"进程标识与基线文件不一致", Config{Mode: ModeLearn, Key: KeyPath, Path: byName}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); err == nil {
+				t.Error("应返回错误")
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package baseline
+
+import (
+	"context"
+	"dnsflux/internal/detect/psl"
+	"dnsflux/internal/model"
+	"dnsflux/pkg/logger"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// log 进程基线日志
+var log = logger.Component("detect").With("detector", "baseline")
+
+// 偏离基线的类型
+const (
+	KindDomain    = "domain"    // 解析了基线之外的域名
+	KindQueryType = "queryType" // 使用了基线之外的查询类型
+	KindProcess   = "process"   // 基线中没有该进程（alertUnknown）
+)
+
+// maxAlerted 保存告警时间的条目上限，超过时清理已过告警间隔的条目
+const maxAlerted = 100000
+
+// Detector 学习或检查进程解析的域名，可并发使用
+type Detector struct {
+	config   Config
+	cooldown time.Duration
+	hasher   *hasher
+
+	mu       sync.Mutex
+	profiles map[string]*profile
+	dirty    bool
+	alerted  map[alertKey]time.Time
+	modTime  time.Time // 加载时基线文件的修改时间与大小，用于判断文件是否已被修改
+	size     int64
+
+	saveMu sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// profile 内存中一个进程的基线
+type profile struct {
+	path    string
+	domains map[string]bool
+	types   map[string]bool
+}
+
+// alertKey 告警去重的键
+type alertKey struct {
+	process string
+	kind    string
+	value   string
+}
+
+// Deviation 一次偏离基线的查询
+type Deviation struct {
+	Kind      string // domain、queryType 或 process
+	Key       string // 进程标识的类型
+	Process   string // 进程标识：路径、名称或 SHA-256
+	Path      string // 可执行文件路径
+	Label     string // 进程描述，如 curl(1234)
+	Name      string // 查询的完整域名
+	Domain    string // 基线使用的域名，通常为可注册域名
+	QueryType string
+}
+
+// New 创建进程基线检测器并加载基线文件
+// 学习模式下文件不存在时从空基线开始；强制模式下文件必须存在。文件中的 key 与配置不一致时返回错误
+func New(cfg Config) (*Detector, error) {
+	d := &Detector{
+		config:   cfg,
+		cooldown: cfg.Cooldown.Std(),
+		profiles: make(map[string]*profile),
+		alerted:  make(map[alertKey]time.Time),
+		done:     make(chan struct{}),
+	}
+	if cfg.Key == KeySHA256 {
+		d.hasher = newHasher()
+	}
+	if cfg.Path != "" {
+		if err := d.load(); err != nil {
+			return nil, err
+		}
+	} else if cfg.Mode == ModeEnforce {
+		return nil, errors.New("强制模式需要指定基线文件")
+	}
+
+	if cfg.Mode != ModeLearn || cfg.Path == "" || cfg.SaveInterval <= 0 {
+		close(d.done)
+		d.cancel = func() {}
+		return d, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	go d.run(ctx, cfg.SaveInterval.Std())
+	return d, nil
+}
+
+// load 读取基线文件，学习模式下文件不存在时忽略
+func (d *Detector) load() error {
+	info, err := os.Stat(d.config.Path)
+	if errors.Is(err, fs.ErrNotExist) && d.config.Mode == ModeLearn {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取基线文件失败: %w", err)
+	}
+	b, err := ReadFile(d.config.Path)
+	if err != nil {
+		return err
+	}
+	if b.Key != d.config.Key {
+		return fmt.Errorf("基线文件 %s 以 %s 标识进程，与配置的 %s 不一致", d.config.Path, b.Key, d.config.Key)
+	}
+	for _, p := range b.Processes {
+		prof := d.profile(p.Process)
+		if p.Path != "" {
+			prof.path = p.Path
+		}
+		for _, domain := range p.Domains {
+			prof.domains[psl.Normalize(domain)] = true
+		}
+		for _, qtype := range p.QueryTypes {
+			prof.types[strings.ToUpper(qtype)] = true
+		}
+	}
+	d.modTime, d.size = info.ModTime(), info.Size()
+	log.Info(fmt.Sprintf("已加载进程基线 %s: %d 个进程", d.config.Path, len(d.profiles)))
+	return nil
+}
+
+// Observe 学习模式下将查询加入基线；强制模式下返回查询偏离基线之处，已在告警间隔内告警过的不再返回
+func (d *Detector) Observe(record *model.DNSRecord) []Deviation {
+	process, path := d.identify(record)
+	name := psl.Normalize(record.QueryName)
+	if process == "" || name == "" {
+		return nil
+	}
+	domain := domainOf(name)
+	qtype := strings.ToUpper(record.QueryType)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.config.Mode == ModeLearn {
+		d.learn(process, path, domain, qtype)
+		return nil
+	}
+
+	at := record.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	deviation := Deviation{
+		Key:       d.config.Key,
+		Process:   process,
+		Path:      path,
+		Label:     record.ProcessLabel(),
+		Name:      name,
+		Domain:    domain,
+		QueryType: qtype,
+	}
+	p := d.profiles[process]
+	if p == nil {
+		if !d.config.AlertUnknown || !d.alert(alertKey{process, KindProcess, ""}, at) {
+			return nil
+		}
+		deviation.Kind = KindProcess
+		return []Deviation{deviation}
+	}
+
+	var deviations []Deviation
+	if !p.matchDomain(name) && d.alert(alertKey{process, KindDomain, domain}, at) {
+		deviation.Kind = KindDomain
+		deviations = append(deviations, deviation)
+	}
+	if qtype != "" && !p.types[qtype] && !p.types[Any] && d.alert(alertKey{process, KindQueryType, qtype}, at) {
+		deviation.Kind = KindQueryType
+		deviations = append(deviations, deviation)
+	}
+	return deviations
+}
+
+// Baseline 返回当前的基线
+func (d *Detector) Baseline() *Baseline {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	b := &Baseline{Key: d.config.Key, Processes: make([]Profile, 0, len(d.profiles))}
+	for process, p := range d.profiles {
+		profile := Profile{Process: process, Domains: []string{}, QueryTypes: []string{}}
+		if d.config.Key == KeySHA256 {
+			profile.Path = p.path
+		}
+		for domain := range p.domains {
+			profile.Domains = append(profile.Domains, domain)
+		}
+		for qtype := range p.types {
+			profile.QueryTypes = append(profile.QueryTypes, qtype)
+		}
+		b.Processes = append(b.Processes, profile)
+	}
+	return b
+}
+
+// Stale 判断强制模式使用的基线文件在加载后是否被修改，重新加载配置时据此重新读取基线
+func (d *Detector) Stale() bool {
+	if d.config.Mode != ModeEnforce {
+		return false
+	}
+	info, err := os.Stat(d.config.Path)
+	return err != nil || !info.ModTime().Equal(d.modTime) || info.Size() != d.size
+}
+
+// Save 学习模式下将有变化的基线写入基线文件
+func (d *Detector) Save() error {
+	if d.config.Mode != ModeLearn || d.config.Path == "" {
+		return nil
+	}
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+
+	d.mu.Lock()
+	dirty := d.dirty
+	d.dirty = false
+	d.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+	if err := d.Baseline().WriteFile(d.config.Path); err != nil {
+		d.mu.Lock()
+		d.dirty = true
+		d.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Close 停止定期写入，学习模式下写入最新的基线
+func (d *Detector) Close() {
+	d.cancel()
+	<-d.done
+	if err := d.Save(); err != nil {
+		log.Warn(fmt.Sprintf("写入基线文件失败: %v", err))
+	}
+}
+
+// run 定期写入基线文件
+func (d *Detector) run(ctx context.Context, interval time.Duration) {
+	defer close(d.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := d.Save(); err != nil {
+			log.Warn(fmt.Sprintf("写入基线文件失败: %v", err))
+		}
+	}
+}
+
+// identify 按配置返回记录的进程标识与可执行文件路径，无法标识进程时返回空字符串
+func (d *Detector) identify(record *model.DNSRecord) (process, path string) {
+	path = record.ProcessPath
+	switch d.config.Key {
+	case KeyName:
+		return record.ProcessName, path
+	case KeySHA256:
+		if path == "" {
+			return "", ""
+		}
+		if sum := d.hasher.sum(path); sum != "" {
+			return sum, path
+		}
+		return path, path
+	default:
+		return path, path
+	}
+}
+
+// learn 将查询加入进程的基线
+func (d *Detector) learn(process, path, domain, qtype string) {
+	p := d.profile(process)
+	if !p.domains[domain] {
+		p.domains[domain] = true
+		d.dirty = true
+	}
+	if qtype != "" && !p.types[qtype] {
+		p.types[qtype] = true
+		d.dirty = true
+	}
+	if d.config.Key == KeySHA256 && p.path != path {
+		p.path = path
+		d.dirty = true
+	}
+}
+
+// profile 返回进程的基线，不存在时创建
+func (d *Detector) profile(process string) *profile {
+	p := d.profiles[process]
+	if p == nil {
+		p = &profile{domains: make(map[string]bool), types: make(map[string]bool)}
+		d.profiles[process] = p
+	}
+	return p
+}
+
+// alert 判断该键是否不在告警间隔内，是则记录告警时间
+func (d *Detector) alert(key alertKey, at time.Time) bool {
+	if last, ok := d.alerted[key]; ok && at.Sub(last) < d.cooldown {
+		return false
+	}
+	if len(d.alerted) >= maxAlerted {
+		for k, last := range d.alerted {
+			if at.Sub(last) >= d.cooldown {
+				delete(d.alerted, k)
+			}
+		}
+	}
+	d.alerted[key] = at
+	return true
+}
+
+// matchDomain 判断域名本身或任一父域名在基线中
+func (p *profile) matchDomain(name string) bool {
+	if p.domains[Any] {
+		return true
+	}
+	for {
+		if p.domains[name] {
+			return true
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			return false
+		}
+		name = name[i+1:]
+	}
+}
+
+// domainOf 返回基线中记录的域名：可注册域名，反向解析为 in-addr.arpa 或 ip6.arpa，其余（如单标签名称）为名称本身
+func domainOf(name string) string {
+	if _, domain, ok := psl.Split(name); ok {
+		return domain
+	}
+	for _, zone := range []string{"in-addr.arpa", "ip6.arpa"} {
+		if strings.HasSuffix(name, "."+zone) {
+			return zone
+		}
+	}
+	return name
+}
@@ -0,0 +1,81 @@
+package baseline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// 可执行文件哈希缓存
+const (
+	hashRecheck   = time.Minute // 同一路径重新检查文件修改时间与大小的间隔
+	maxHashCached = 10000       // 缓存的路径数上限，超过时清空
+)
+
+// hasher 计算并缓存可执行文件的 SHA-256，文件修改后重新计算
+type hasher struct {
+	mu    sync.Mutex
+	cache map[string]*hashEntry
+}
+
+// hashEntry 一个路径的哈希
+type hashEntry struct {
+	sum     string
+	size    int64
+	modTime time.Time
+	checked time.Time
+}
+
+// newHasher 创建哈希缓存
+func newHasher() *hasher {
+	return &hasher{cache: make(map[string]*hashEntry)}
+}
+
+// sum 返回文件的 SHA-256 十六进制字符串，文件无法读取时返回空字符串
+func (h *hasher) sum(path string) string {
+	now := time.Now()
+	h.mu.Lock()
+	e := h.cache[path]
+	if e != nil && now.Sub(e.checked) < hashRecheck {
+		h.mu.Unlock()
+		return e.sum
+	}
+	h.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	if e != nil && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+		h.mu.Lock()
+		e.checked = now
+		h.mu.Unlock()
+		return e.sum
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	digest := sha256.New()
+	if _, err := io.Copy(digest, file); err != nil {
+		return ""
+	}
+	e = &hashEntry{
+		sum:     hex.EncodeToString(digest.Sum(nil)),
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		checked: now,
+	}
+	h.mu.Lock()
+	if len(h.cache) >= maxHashCached {
+		clear(h.cache)
+	}
+	h.cache[path] = e
+	h.mu.Unlock()
+	return e.sum
+}
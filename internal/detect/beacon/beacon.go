@@ -0,0 +1,66 @@
+// Package beacon 根据查询时间间隔检测 C2 心跳
+// 植入程序通常以固定间隔（加上随机抖动）解析 C2 域名。检测器按进程与查询域名保存最近的查询时间，
+// 参照 RITA 的做法以间隔的变异系数、Bowley 偏度与中位数绝对偏差评价周期性
+package beacon
+
+import (
+	"dnsflux/internal/output"
+	"time"
+)
+
+// 默认配置
+const (
+	DefaultMinQueries  = 10
+	DefaultMaxHistory  = 100
+	DefaultMinInterval = 2 * time.Second
+	DefaultThreshold   = 0.8
+	DefaultCooldown    = time.Hour
+	DefaultMaxIdle     = 24 * time.Hour
+	DefaultMaxTracked  = 10000
+)
+
+// Config 心跳检测配置
+type Config struct {
+	Enabled     bool            `yaml:"enabled"`
+	MinQueries  int             `yaml:"minQueries"`  // 评分所需的最少查询次数
+	MaxHistory  int             `yaml:"maxHistory"`  // 每个进程与域名组合保留的最近查询次数
+	MinInterval output.Duration `yaml:"minInterval"` // 间隔短于该值的查询合并为一次，如同时发出的 A 与 AAAA 查询和重传
+	Threshold   float64         `yaml:"threshold"`   // 置信度达到该值时告警，0 到 1 之间，0 表示只评分不告警
+	Cooldown    output.Duration `yaml:"cooldown"`    // 同一进程与域名两次告警的最小间隔
+	MaxIdle     output.Duration `yaml:"maxIdle"`     // 超过该时间没有查询的组合不再跟踪
+	MaxTracked  int             `yaml:"maxTracked"`  // 同时跟踪的组合上限，超过时淘汰最久未活动的组合
+	Allow       []string        `yaml:"allow"`       // 不检测的域名，同时匹配其子域名，如定时检查更新的服务
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Enabled:     true,
+		MinQueries:  DefaultMinQueries,
+		MaxHistory:  DefaultMaxHistory,
+		MinInterval: output.Duration(DefaultMinInterval),
+		Threshold:   DefaultThreshold,
+		Cooldown:    output.Duration(DefaultCooldown),
+		MaxIdle:     output.Duration(DefaultMaxIdle),
+		MaxTracked:  DefaultMaxTracked,
+	}
+}
+
+// Candidate 一个进程与域名组合的周期性评分
+type Candidate struct {
+	Process    string          `json:"process"`
+	Domain     string          `json:"domain"`
+	Period     output.Duration `json:"period"`     // 间隔中位数
+	Jitter     output.Duration `json:"jitter"`     // 间隔的中位数绝对偏差
+	Queries    int             `json:"queries"`    // 参与评分的查询次数（合并后）
+	Confidence float64         `json:"confidence"` // 三项评分的平均值，0 到 1
+
+	CV        float64 `json:"cv"`        // 间隔的变异系数
+	Skew      float64 `json:"skew"`      // 间隔的 Bowley 偏度，-1 到 1
+	CVScore   float64 `json:"cvScore"`   // 1 - 变异系数
+	SkewScore float64 `json:"skewScore"` // 1 - |偏度|
+	MADMScore float64 `json:"madmScore"` // 1 - 中位数绝对偏差 / 中位数
+
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
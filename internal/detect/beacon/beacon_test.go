@@ -0,0 +1,218 @@
+package beacon
+
+import (
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+// series 返回从 start 开始、依次间隔 intervals 秒的查询时间
+func series(intervals ...float64) []time.Time {
+	times := []time.Time{start}
+	for _, v := range intervals {
+		times = append(times, times[len(times)-1].Add(time.Duration(v*float64(time.Second))))
+	}
+	return times
+}
+
+// jittered 返回 n 个在 period 上加 ±jitter 均匀抖动的间隔
+func jittered(seed uint64, n int, period, jitter float64) []float64 {
+	r := rand.New(rand.NewPCG(seed, seed))
+	intervals := make([]float64, n)
+	for i := range intervals {
+		intervals[i] = period + (r.Float64()*2-1)*jitter
+	}
+	return intervals
+}
+
+// exponential 返回 n 个均值为 mean 的指数分布间隔，近似人为操作
+func exponential(seed uint64, n int, mean float64) []float64 {
+	r := rand.New(rand.NewPCG(seed, seed))
+	intervals := make([]float64, n)
+	for i := range intervals {
+		intervals[i] = r.ExpFloat64() * mean
+	}
+	return intervals
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name  string
+		times []time.Time
+		want  Candidate
+	}{
+		{
+			"固定间隔",
+			series(60, 60, 60, 60, 60),
+			Candidate{Period: output.Duration(time.Minute), Queries: 6, CVScore: 1, SkewScore: 1, MADMScore: 1, Confidence: 1},
+		},
+		{
+			// 均值 20，标准差 8.165；四分位数 15、20、25 对称；偏差中位数 10
+			"对称分布",
+			series(30, 10, 20),
+			Candidate{Period: output.Duration(20 * time.Second), Jitter: output.Duration(10 * time.Second), Queries: 4,
+				CV: 0.408, CVScore: 0.592, SkewScore: 1, MADMScore: 0.5, Confidence: 0.697},
+		},
+		{
+			// 四分位数 1.75、2.5、4.75，Bowley 偏度 0.5
+			"右偏分布",
+			series(10, 1, 3, 2),
+			Candidate{Period: output.Duration(2500 * time.Millisecond), Jitter: output.Duration(time.Second), Queries: 5,
+				CV: 0.884, Skew: 0.5, CVScore: 0.116, SkewScore: 0.5, MADMScore: 0.6, Confidence: 0.405},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := score(tt.times)
+			tt.want.LastSeen = tt.times[len(tt.times)-1]
+			if got != tt.want {
+				t.Errorf("score() = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreSeparatesBeaconsFromHumans(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals []float64
+		min, max  float64
+	}{
+		{"60 秒间隔加 ±10% 均匀抖动", jittered(1, 99, 60, 6), DefaultThreshold, 1},
+		{"300 秒间隔加 ±20% 均匀抖动", jittered(2, 99, 300, 60), DefaultThreshold, 1},
+		{"偶尔漏掉一次心跳", append(jittered(3, 97, 60, 3), 120, 120), DefaultThreshold, 1},
+		{"平均 5 分钟的人为操作", exponential(4, 99, 300), 0, 0.6},
+		{"平均 1 分钟的人为操作", exponential(5, 99, 60), 0, 0.6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := score(series(tt.intervals...))
+			if c.Confidence < tt.min || c.Confidence > tt.max {
+				t.Errorf("置信度 = %v (cv %v, skew %v, madm %v), want %v-%v",
+					c.Confidence, c.CVScore, c.SkewScore, c.MADMScore, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+// observe 以 process 的身份在指定时间查询 domain
+func observe(d *Detector, process, domain string, at time.Time) (Candidate, bool) {
+	return d.Observe(&model.DNSRecord{Timestamp: at, QueryName: domain, ProcessName: process, ProcessID: 100})
+}
+
+// testConfig 返回 5 次查询即可评分的配置
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.MinQueries = 5
+	cfg.MaxHistory = 8
+	return cfg
+}
+
+func TestObserve(t *testing.T) {
+	d := New(testConfig())
+	var alerts []time.Time
+	for i := range 12 {
+		at := start.Add(time.Duration(i) * time.Minute)
+		if c, ok := observe(d, "implant", "C2.Example.", at); ok {
+			if c.Process != "implant(100)" || c.Domain != "c2.example" || !c.FirstSeen.Equal(start) {
+				t.Errorf("告警 = %+v", c)
+			}
+			alerts = append(alerts, at)
+		}
+		// 同时发出的 AAAA 查询合并为一次
+		observe(d, "implant", "c2.example", at.Add(10*time.Millisecond))
+	}
+
+	// 第 5 次查询时开始评分并告警，告警间隔内不再告警
+	if len(alerts) != 1 || !alerts[0].Equal(start.Add(4*time.Minute)) {
+		t.Errorf("告警时间 = %v, want 仅第 5 次查询", alerts)
+	}
+	candidates := d.Candidates(false)
+	if len(candidates) != 1 {
+		t.Fatalf("Candidates() = %+v", candidates)
+	}
+	// 只保留最近 MaxHistory 次查询
+	if c := candidates[0]; c.Queries != 8 || c.Period != output.Duration(time.Minute) || c.Confidence != 1 {
+		t.Errorf("评分 = %+v", c)
+	}
+
+	// 告警间隔过后再次告警
+	if _, ok := observe(d, "implant", "c2.example", start.Add(12*time.Minute+time.Hour)); ok {
+		t.Error("间隔变化时仍然告警")
+	}
+	at := start.Add(12*time.Minute + 2*time.Hour)
+	for i := 0; i < 8; i++ {
+		if _, ok := observe(d, "implant", "c2.example", at.Add(time.Duration(i)*time.Minute)); ok {
+			return
+		}
+	}
+	t.Error("告警间隔过后没有再次告警")
+}
+
+func TestObserveIgnored(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		domain string
+	}{
+		{"白名单域名", func(cfg *Config) { cfg.Allow = []string{"Update.Example."} }, "update.example"},
+		{"白名单的子域名", func(cfg *Config) { cfg.Allow = []string{"update.example"} }, "cdn.update.example"},
+		{"反向解析", func(cfg *Config) {}, "1.0.0.10.in-addr.arpa"},
+		{"阈值为 0 时只评分", func(cfg *Config) { cfg.Threshold = 0 }, "c2.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			tt.modify(&cfg)
+			d := New(cfg)
+			for i := range 10 {
+				if _, ok := observe(d, "implant", tt.domain, start.Add(time.Duration(i)*time.Minute)); ok {
+					t.Fatal("不应告警")
+				}
+			}
+			scored := len(d.Candidates(true)) == 1
+			if want := cfg.Threshold == 0; scored != want {
+				t.Errorf("已评分 = %v, want %v", scored, want)
+			}
+		})
+	}
+}
+
+func TestTracking(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxTracked = 2
+	cfg.MaxIdle = output.Duration(time.Hour)
+	d := New(cfg)
+
+	// 每个组合各评分一次
+	for i, domain := range []string{"a.example", "b.example", "c.example"} {
+		for j := range 5 {
+			observe(d, "p", domain, start.Add(time.Duration(i*10+j)*time.Minute))
+		}
+	}
+	// 超过跟踪上限时淘汰最久未活动的组合
+	if got := domains(d.Candidates(true)); !slices.Equal(got, []string{"b.example", "c.example"}) {
+		t.Errorf("跟踪的组合 = %v, want [b.example c.example]", got)
+	}
+
+	// 长时间没有查询的组合不再跟踪
+	observe(d, "p", "d.example", start.Add(14*time.Minute+time.Hour+time.Second))
+	if got := d.Candidates(true); len(got) != 1 || got[0].Domain != "c.example" {
+		t.Errorf("清理后跟踪的组合 = %v", domains(got))
+	}
+}
+
+// domains 返回评分的域名，按域名排序
+func domains(candidates []Candidate) []string {
+	var out []string
+	for _, c := range candidates {
+		out = append(out, c.Domain)
+	}
+	slices.Sort(out)
+	return out
+}
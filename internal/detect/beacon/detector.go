@@ -0,0 +1,234 @@
+package beacon
+
+import (
+	"dnsflux/internal/detect/psl"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Detector 按进程与查询域名保存查询时间并评价周期性，可并发使用
+type Detector struct {
+	config      Config
+	minInterval time.Duration
+	cooldown    time.Duration
+	maxIdle     time.Duration
+	allow       []string
+
+	mu        sync.Mutex
+	pairs     map[pairKey]*pair
+	lastSweep time.Time
+}
+
+// pairKey 跟踪的进程与查询域名组合
+type pairKey struct {
+	process string
+	domain  string
+}
+
+// pair 一个组合最近的查询时间与评分
+type pair struct {
+	times     []time.Time // 合并后的查询时间，按时间顺序，最多 MaxHistory 个
+	firstSeen time.Time
+	score     *Candidate // 最近一次评分，查询次数不足时为 nil
+	alerted   time.Time
+}
+
+// New 创建心跳检测器
+func New(cfg Config) *Detector {
+	d := &Detector{
+		config:      cfg,
+		minInterval: cfg.MinInterval.Std(),
+		cooldown:    cfg.Cooldown.Std(),
+		maxIdle:     cfg.MaxIdle.Std(),
+		pairs:       make(map[pairKey]*pair),
+	}
+	for _, domain := range cfg.Allow {
+		d.allow = append(d.allow, psl.Normalize(domain))
+	}
+	return d
+}
+
+// Observe 记录一次查询并更新该组合的评分，置信度达到阈值且不在告警间隔内时返回 true
+func (d *Detector) Observe(record *model.DNSRecord) (Candidate, bool) {
+	domain := psl.Normalize(record.QueryName)
+	if domain == "" || strings.HasSuffix(domain, ".arpa") || d.allowed(domain) {
+		return Candidate{}, false
+	}
+	at := record.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sweep(at)
+
+	key := pairKey{process: record.ProcessLabel(), domain: domain}
+	p := d.pairs[key]
+	if p == nil {
+		p = &pair{firstSeen: at}
+		d.pairs[key] = p
+	}
+	if n := len(p.times); n > 0 && at.Sub(p.times[n-1]) < d.minInterval {
+		return Candidate{}, false
+	}
+	if len(p.times) >= d.config.MaxHistory {
+		p.times = append(p.times[:0], p.times[len(p.times)-d.config.MaxHistory+1:]...)
+	}
+	p.times = append(p.times, at)
+	if len(p.times) < d.config.MinQueries {
+		return Candidate{}, false
+	}
+
+	c := score(p.times)
+	c.Process, c.Domain, c.FirstSeen = key.process, key.domain, p.firstSeen
+	p.score = &c
+	if d.config.Threshold <= 0 || c.Confidence < d.config.Threshold ||
+		(!p.alerted.IsZero() && at.Sub(p.alerted) < d.cooldown) {
+		return Candidate{}, false
+	}
+	p.alerted = at
+	return c, true
+}
+
+// Candidates 返回已评分的组合，按置信度从高到低排列，all 为 false 时只返回达到告警阈值的组合
+func (d *Detector) Candidates(all bool) []Candidate {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	candidates := []Candidate{}
+	for _, p := range d.pairs {
+		if p.score == nil || (!all && p.score.Confidence < d.config.Threshold) {
+			continue
+		}
+		candidates = append(candidates, *p.score)
+	}
+	slices.SortFunc(candidates, func(a, b Candidate) int {
+		if a.Confidence != b.Confidence {
+			if a.Confidence > b.Confidence {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Process+a.Domain, b.Process+b.Domain)
+	})
+	return candidates
+}
+
+// allowed 判断域名是否在白名单中，白名单条目同时匹配其子域名
+func (d *Detector) allowed(domain string) bool {
+	for _, allow := range d.allow {
+		if domain == allow || strings.HasSuffix(domain, "."+allow) {
+			return true
+		}
+	}
+	return false
+}
+
+// sweep 每分钟清理一次长时间没有查询的组合，跟踪的组合超过上限时淘汰最久未活动的组合
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < time.Minute && len(d.pairs) <= d.config.MaxTracked {
+		return
+	}
+	d.lastSweep = now
+	for key, p := range d.pairs {
+		if now.Sub(p.times[len(p.times)-1]) > d.maxIdle {
+			delete(d.pairs, key)
+		}
+	}
+	for len(d.pairs) > d.config.MaxTracked {
+		var oldest pairKey
+		var last time.Time
+		for key, p := range d.pairs {
+			if seen := p.times[len(p.times)-1]; last.IsZero() || seen.Before(last) {
+				oldest, last = key, seen
+			}
+		}
+		delete(d.pairs, oldest)
+	}
+}
+
+// score 根据查询时间的间隔评价周期性
+// 三项评分参照 RITA：1 - 变异系数、1 - |Bowley 偏度|、1 - 中位数绝对偏差 / 中位数，均限制在 0 到 1 之间，
+// 置信度为三者的平均值。固定间隔加均匀抖动的心跳三项均接近 1，人为操作的查询间隔近似指数分布，评分明显更低
+func score(times []time.Time) Candidate {
+	intervals := make([]float64, len(times)-1)
+	for i := 1; i < len(times); i++ {
+		intervals[i-1] = times[i].Sub(times[i-1]).Seconds()
+	}
+	slices.Sort(intervals)
+
+	var sum float64
+	for _, v := range intervals {
+		sum += v
+	}
+	mean := sum / float64(len(intervals))
+	var variance float64
+	for _, v := range intervals {
+		variance += (v - mean) * (v - mean)
+	}
+	cv := 0.0
+	if mean > 0 {
+		cv = math.Sqrt(variance/float64(len(intervals))) / mean
+	}
+
+	q1, median, q3 := quantile(intervals, 0.25), quantile(intervals, 0.5), quantile(intervals, 0.75)
+	skew := 0.0
+	if q3 > q1 {
+		skew = (q1 + q3 - 2*median) / (q3 - q1)
+	}
+
+	deviations := make([]float64, len(intervals))
+	for i, v := range intervals {
+		deviations[i] = math.Abs(v - median)
+	}
+	slices.Sort(deviations)
+	madm := quantile(deviations, 0.5)
+	madmScore := 0.0
+	if median > 0 {
+		madmScore = clamp(1 - madm/median)
+	}
+
+	c := Candidate{
+		Period:    output.Duration(seconds(median)),
+		Jitter:    output.Duration(seconds(madm)),
+		Queries:   len(times),
+		CV:        round(cv),
+		Skew:      round(skew),
+		CVScore:   round(clamp(1 - cv)),
+		SkewScore: round(clamp(1 - math.Abs(skew))),
+		MADMScore: round(madmScore),
+		LastSeen:  times[len(times)-1],
+	}
+	c.Confidence = round((c.CVScore + c.SkewScore + c.MADMScore) / 3)
+	return c
+}
+
+// quantile 返回已排序数据的分位数，在相邻两个值之间线性插值
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (sorted[i+1]-sorted[i])*(pos-float64(i))
+}
+
+// seconds 将秒数转换为时间间隔，精确到毫秒
+func seconds(v float64) time.Duration {
+	return time.Duration(v * float64(time.Second)).Round(time.Millisecond)
+}
+
+// round 保留三位小数
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// clamp 将值限制在 0 到 1 之间
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
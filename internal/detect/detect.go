@@ -0,0 +1,593 @@
+// Package detect 对 DNS 记录运行检测器，将命中结果作为告警附加到记录
+package detect
+
+import (
+	"dnsflux/internal/detect/answer"
+	"dnsflux/internal/detect/baseline"
+	"dnsflux/internal/detect/beacon"
+	"dnsflux/internal/detect/dga"
+	"dnsflux/internal/detect/intel"
+	"dnsflux/internal/detect/lookalike"
+	"dnsflux/internal/detect/nod"
+	"dnsflux/internal/detect/sigma"
+	"dnsflux/internal/detect/tunnel"
+	"dnsflux/internal/model"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MITRE ATT&CK 技术编号
+const (
+	techniqueProtocol = "T1071"     // Application Layer Protocol
+	techniqueDNS      = "T1071.004" // Application Layer Protocol: DNS
+	techniqueFastFlux = "T1568.001" // Dynamic Resolution: Fast Flux DNS
+	techniqueDGA      = "T1568.002" // Dynamic Resolution: Domain Generation Algorithms
+	techniqueDomains  = "T1583.001" // Acquire Infrastructure: Domains
+)
+
+// 检测器名称
+const (
+	DetectorIntel     = "intel"
+	DetectorDGA       = "dga"
+	DetectorTunnel    = "tunnel"
+	DetectorBeacon    = "beacon"
+	DetectorNOD       = "nod"
+	DetectorBaseline  = "baseline"
+	DetectorSigma     = "sigma"
+	DetectorLookalike = "lookalike"
+	DetectorAnswer    = "answer"
+)
+
+// Config 检测配置
+type Config struct {
+	Intel     intel.Config     `yaml:"intel"`     // 威胁情报域名匹配
+	DGA       dga.Config       `yaml:"dga"`       // 算法生成域名评分
+	Tunnel    tunnel.Config    `yaml:"tunnel"`    // DNS 隧道与数据外传
+	Beacon    beacon.Config    `yaml:"beacon"`    // 周期性查询（C2 心跳）
+	NOD       nod.Config       `yaml:"nod"`       // 新出现的域名
+	Baseline  baseline.Config  `yaml:"baseline"`  // 进程解析域名的基线
+	Sigma     sigma.Config     `yaml:"sigma"`     // Sigma 规则
+	Lookalike lookalike.Config `yaml:"lookalike"` // 仿冒品牌的域名
+	Answer    answer.Config    `yaml:"answer"`    // 应答分析（fast-flux、低 TTL、DNS 重绑定、sinkhole）
+}
+
+// DefaultConfig 返回默认检测配置
+func DefaultConfig() Config {
+	return Config{
+		DGA:       dga.DefaultConfig(),
+		Tunnel:    tunnel.DefaultConfig(),
+		Beacon:    beacon.DefaultConfig(),
+		NOD:       nod.DefaultConfig(),
+		Baseline:  baseline.DefaultConfig(),
+		Lookalike: lookalike.DefaultConfig(),
+		Answer:    answer.DefaultConfig(),
+	}
+}
+
+// Engine 检测引擎，持有按配置创建的检测器
+// 重新加载配置时通过 NewEngine 创建新引擎，配置未变化的检测器沿用原实例
+type Engine struct {
+	config    Config
+	intel     *intel.Matcher
+	dga       *dga.Scorer
+	tunnel    *tunnel.Detector
+	beacon    *beacon.Detector
+	nod       *nod.Detector
+	baseline  *baseline.Detector
+	sigma     *sigma.Engine
+	lookalike *lookalike.Detector
+	answer    *answer.Detector
+}
+
+// NewEngine 根据配置创建检测引擎，current 非空时沿用其中配置未变化的检测器
+// 沿用的有状态检测器（如隧道检测的滑动窗口、心跳检测的查询时间）保留已有的统计。
+// 新域名检测与学习模式的进程基线在配置变化时先写入当前状态，再由新的检测器重新加载文件；
+// 强制模式的基线文件被修改后重新加载配置时重新读取
+func NewEngine(cfg Config, current *Engine) (*Engine, error) {
+	e := &Engine{config: cfg}
+	if len(cfg.Intel.Feeds) > 0 {
+		if current != nil && current.intel != nil && reflect.DeepEqual(current.config.Intel, cfg.Intel) {
+			e.intel = current.intel
+		} else {
+			matcher, err := intel.New(cfg.Intel)
+			if err != nil {
+				return nil, err
+			}
+			e.intel = matcher
+		}
+	}
+	if cfg.DGA.Enabled {
+		e.dga = dga.New(cfg.DGA)
+	}
+	if cfg.Tunnel.Enabled {
+		if current != nil && current.tunnel != nil && reflect.DeepEqual(current.config.Tunnel, cfg.Tunnel) {
+			e.tunnel = current.tunnel
+		} else {
+			e.tunnel = tunnel.New(cfg.Tunnel)
+		}
+	}
+	if cfg.Beacon.Enabled {
+		if current != nil && current.beacon != nil && reflect.DeepEqual(current.config.Beacon, cfg.Beacon) {
+			e.beacon = current.beacon
+		} else {
+			e.beacon = beacon.New(cfg.Beacon)
+		}
+	}
+	if cfg.NOD.Enabled {
+		if current != nil && current.nod != nil && reflect.DeepEqual(current.config.NOD, cfg.NOD) {
+			e.nod = current.nod
+		} else {
+			if current != nil && current.nod != nil {
+				if err := current.nod.Save(); err != nil {
+					e.Retire(current)
+					return nil, fmt.Errorf("写入新域名数据库失败: %w", err)
+				}
+			}
+			detector, err := nod.New(cfg.NOD)
+			if err != nil {
+				e.Retire(current)
+				return nil, err
+			}
+			e.nod = detector
+		}
+	}
+	if cfg.Baseline.Enabled {
+		if current != nil && current.baseline != nil && reflect.DeepEqual(current.config.Baseline, cfg.Baseline) && !current.baseline.Stale() {
+			e.baseline = current.baseline
+		} else {
+			if current != nil && current.baseline != nil {
+				if err := current.baseline.Save(); err != nil {
+					e.Retire(current)
+					return nil, fmt.Errorf("写入基线文件失败: %w", err)
+				}
+			}
+			detector, err := baseline.New(cfg.Baseline)
+			if err != nil {
+				e.Retire(current)
+				return nil, err
+			}
+			e.baseline = detector
+		}
+	}
+	if len(cfg.Sigma.Rules) > 0 {
+		if current != nil && current.sigma != nil && reflect.DeepEqual(current.config.Sigma, cfg.Sigma) {
+			e.sigma = current.sigma
+		} else {
+			engine, err := sigma.New(cfg.Sigma)
+			if err != nil {
+				e.Retire(current)
+				return nil, err
+			}
+			e.sigma = engine
+		}
+	}
+	if cfg.Lookalike.Enabled {
+		if current != nil && current.lookalike != nil && reflect.DeepEqual(current.config.Lookalike, cfg.Lookalike) {
+			e.lookalike = current.lookalike
+		} else {
+			e.lookalike = lookalike.New(cfg.Lookalike)
+		}
+	}
+	if cfg.Answer.Enabled {
+		if current != nil && current.answer != nil && reflect.DeepEqual(current.config.Answer, cfg.Answer) {
+			e.answer = current.answer
+		} else {
+			e.answer = answer.New(cfg.Answer)
+		}
+	}
+	return e, nil
+}
+
+// Process 对记录运行全部检测器，命中结果追加到 record.Alerts
+func (e *Engine) Process(record *model.DNSRecord) {
+	if e.intel != nil {
+		for _, match := range e.intel.Match(record.QueryName) {
+			record.Alerts = append(record.Alerts, intelAlert(record, match))
+		}
+	}
+	if e.dga != nil {
+		if result, ok := dga.Score(record.QueryName); ok {
+			record.DGAScore = math.Round(result.Score*1000) / 1000
+			if e.dga.Alert(result) {
+				record.Alerts = append(record.Alerts, dgaAlert(record, result))
+			}
+		}
+	}
+	if e.tunnel != nil {
+		if finding, ok := e.tunnel.Observe(record); ok {
+			record.Alerts = append(record.Alerts, tunnelAlert(finding))
+		}
+	}
+	if e.beacon != nil {
+		if candidate, ok := e.beacon.Observe(record); ok {
+			record.Alerts = append(record.Alerts, beaconAlert(candidate))
+		}
+	}
+	if e.nod != nil {
+		if observation, ok := e.nod.Observe(record); ok {
+			record.NewlyObserved = observation.Scope
+			if e.config.NOD.Alert && observation.Scope == nod.ScopeDomain {
+				record.Alerts = append(record.Alerts, nodAlert(observation))
+			}
+		}
+	}
+	if e.baseline != nil {
+		for _, deviation := range e.baseline.Observe(record) {
+			record.Alerts = append(record.Alerts, baselineAlert(deviation))
+		}
+	}
+	if e.sigma != nil {
+		for _, match := range e.sigma.Match(record) {
+			record.Alerts = append(record.Alerts, sigmaAlert(record, match))
+		}
+	}
+	if e.lookalike != nil {
+		if finding, ok := e.lookalike.Observe(record); ok {
+			record.Alerts = append(record.Alerts, lookalikeAlert(record, finding))
+		}
+	}
+	if e.answer != nil {
+		for _, finding := range e.answer.Observe(record) {
+			record.Alerts = append(record.Alerts, answerAlert(record, finding))
+		}
+	}
+}
+
+// Beacons 返回心跳检测的评分结果，all 为 false 时只返回达到告警阈值的组合，未启用时返回空列表
+func (e *Engine) Beacons(all bool) []beacon.Candidate {
+	if e == nil || e.beacon == nil {
+		return []beacon.Candidate{}
+	}
+	return e.beacon.Candidates(all)
+}
+
+// NewlyObserved 返回 since 之后记录的新域名与数据库状态，未启用时返回 false
+func (e *Engine) NewlyObserved(since time.Time, scope string) ([]nod.Observation, nod.Status, bool) {
+	if e == nil || e.nod == nil {
+		return []nod.Observation{}, nod.Status{}, false
+	}
+	return e.nod.Recent(since, scope), e.nod.Status(), true
+}
+
+// Baseline 返回当前的进程基线，未启用时返回 false
+func (e *Engine) Baseline() (*baseline.Baseline, bool) {
+	if e == nil || e.baseline == nil {
+		return nil, false
+	}
+	return e.baseline.Baseline(), true
+}
+
+// Retire 关闭未被 next 沿用的检测器，next 为 nil 时全部关闭
+func (e *Engine) Retire(next *Engine) {
+	if e == nil {
+		return
+	}
+	if e.intel != nil && (next == nil || next.intel != e.intel) {
+		e.intel.Close()
+	}
+	if e.nod != nil && (next == nil || next.nod != e.nod) {
+		e.nod.Close()
+	}
+	if e.baseline != nil && (next == nil || next.baseline != e.baseline) {
+		e.baseline.Close()
+	}
+	if e.sigma != nil && (next == nil || next.sigma != e.sigma) {
+		e.sigma.Close()
+	}
+}
+
+// intelAlert 将威胁情报匹配结果转换为告警
+func intelAlert(record *model.DNSRecord, match intel.Match) model.RecordAlert {
+	ioc, feed := match.IOC, match.IOC.Feed
+	how := "exact"
+	message := fmt.Sprintf("%s 命中威胁情报 %s", record.QueryName, feed.Name)
+	if match.Parent {
+		how = "parent"
+		message = fmt.Sprintf("%s 的父域名 %s 命中威胁情报 %s", record.QueryName, ioc.Domain, feed.Name)
+	}
+	if ioc.Description != "" {
+		message += "（" + ioc.Description + "）"
+	}
+
+	fields := map[string]string{
+		"ioc":        ioc.Domain,
+		"match":      how,
+		"feed":       feed.Name,
+		"feedFormat": feed.Format,
+	}
+	set := func(key, value string) {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	set("description", ioc.Description)
+	set("reference", ioc.Reference)
+	set("tags", strings.Join(ioc.Tags, ","))
+	set("action", ioc.Action)
+	set("feedDescription", feed.Description)
+	set("feedReference", feed.Reference)
+	return model.RecordAlert{
+		Detector:  DetectorIntel,
+		Rule:      feed.Name,
+		Severity:  model.SeverityHigh,
+		Technique: techniqueDNS,
+		Message:   message,
+		Fields:    fields,
+	}
+}
+
+// dgaAlert 将超过阈值的 DGA 评分转换为告警
+func dgaAlert(record *model.DNSRecord, result dga.Result) model.RecordAlert {
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 3, 64)
+	}
+	return model.RecordAlert{
+		Detector:  DetectorDGA,
+		Rule:      DetectorDGA,
+		Severity:  model.SeverityMedium,
+		Technique: techniqueDGA,
+		Message:   fmt.Sprintf("%s 疑似算法生成域名（评分 %.2f）", result.Domain, result.Score),
+		Fields: map[string]string{
+			"domain":    result.Domain,
+			"label":     result.Label,
+			"score":     format(result.Score),
+			"ngram":     format(result.Ngram),
+			"entropy":   format(result.Entropy),
+			"consonant": format(result.Consonant),
+			"digits":    format(result.Digits),
+			"length":    format(result.Length),
+		},
+	}
+}
+
+// tunnelAlert 将隧道检测的窗口统计转换为告警
+func tunnelAlert(f tunnel.Finding) model.RecordAlert {
+	types := make([]string, 0, len(f.Types))
+	for qtype, n := range f.Types {
+		types = append(types, fmt.Sprintf("%s:%d", qtype, n))
+	}
+	sort.Strings(types)
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	return model.RecordAlert{
+		Detector:  DetectorTunnel,
+		Rule:      DetectorTunnel,
+		Severity:  model.SeverityHigh,
+		Technique: techniqueDNS,
+		Message: fmt.Sprintf("%s 疑似通过 %s 进行 DNS 隧道传输（%s 内 %d 个不同子域名，估计外传 %d 字节）",
+			f.Process, f.Domain, f.Window, f.UniqueSubdomains, f.EstimatedBytes),
+		Fields: map[string]string{
+			"domain":           f.Domain,
+			"process":          f.Process,
+			"window":           f.Window.String(),
+			"queries":          strconv.Itoa(f.Queries),
+			"uniqueSubdomains": strconv.Itoa(f.UniqueSubdomains),
+			"encodedBytes":     strconv.Itoa(f.EncodedBytes),
+			"estimatedBytes":   strconv.Itoa(f.EstimatedBytes),
+			"totalBytes":       strconv.Itoa(f.TotalBytes),
+			"labelLength":      format(f.LabelLength),
+			"entropy":          format(f.Entropy),
+			"recordShare":      format(f.RecordShare),
+			"queryTypes":       strings.Join(types, ","),
+		},
+	}
+}
+
+// beaconAlert 将心跳检测的评分转换为告警
+func beaconAlert(c beacon.Candidate) model.RecordAlert {
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 3, 64)
+	}
+	return model.RecordAlert{
+		Detector:  DetectorBeacon,
+		Rule:      DetectorBeacon,
+		Severity:  model.SeverityHigh,
+		Technique: techniqueProtocol,
+		Message: fmt.Sprintf("%s 以约 %s 的间隔周期性解析 %s，疑似 C2 心跳（置信度 %.2f）",
+			c.Process, c.Period.Std(), c.Domain, c.Confidence),
+		Fields: map[string]string{
+			"domain":     c.Domain,
+			"process":    c.Process,
+			"period":     c.Period.Std().String(),
+			"jitter":     c.Jitter.Std().String(),
+			"queries":    strconv.Itoa(c.Queries),
+			"confidence": format(c.Confidence),
+			"cv":         format(c.CV),
+			"skew":       format(c.Skew),
+			"cvScore":    format(c.CVScore),
+			"skewScore":  format(c.SkewScore),
+			"madmScore":  format(c.MADMScore),
+			"firstSeen":  c.FirstSeen.UTC().Format(time.RFC3339),
+		},
+	}
+}
+
+// nodAlert 将首次出现的可注册域名转换为告警
+func nodAlert(o nod.Observation) model.RecordAlert {
+	return model.RecordAlert{
+		Detector: DetectorNOD,
+		Rule:     o.Scope,
+		Severity: model.SeverityLow,
+		Message:  fmt.Sprintf("%s 首次解析新域名 %s", o.Process, o.Domain),
+		Fields: map[string]string{
+			"domain":  o.Domain,
+			"name":    o.Name,
+			"scope":   o.Scope,
+			"process": o.Process,
+		},
+	}
+}
+
+// baselineAlert 将偏离进程基线的查询转换为告警
+func baselineAlert(d baseline.Deviation) model.RecordAlert {
+	var message string
+	switch d.Kind {
+	case baseline.KindProcess:
+		message = fmt.Sprintf("%s 不在进程基线中，解析了 %s", d.Label, d.Name)
+	case baseline.KindQueryType:
+		message = fmt.Sprintf("%s 使用了基线之外的查询类型 %s 解析 %s", d.Label, d.QueryType, d.Name)
+	default:
+		message = fmt.Sprintf("%s 解析了基线之外的域名 %s", d.Label, d.Name)
+	}
+	fields := map[string]string{
+		"kind":      d.Kind,
+		"key":       d.Key,
+		"process":   d.Process,
+		"name":      d.Name,
+		"domain":    d.Domain,
+		"queryType": d.QueryType,
+	}
+	if d.Path != "" {
+		fields["path"] = d.Path
+	}
+	return model.RecordAlert{
+		Detector: DetectorBaseline,
+		Rule:     d.Kind,
+		Severity: model.SeverityMedium,
+		Message:  message,
+		Fields:   fields,
+	}
+}
+
+// sigmaAlert 将 Sigma 规则命中转换为告警
+func sigmaAlert(record *model.DNSRecord, m sigma.Match) model.RecordAlert {
+	rule := m.Rule
+	message := fmt.Sprintf("%s 解析 %s 命中 Sigma 规则 %s", record.ProcessLabel(), record.QueryName, rule.Title)
+	fields := map[string]string{"title": rule.Title}
+	set := func(key, value string) {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	set("id", rule.ID)
+	set("level", rule.Level)
+	set("status", rule.Status)
+	set("tags", strings.Join(rule.Tags, ","))
+	if m.Aggregate != "" {
+		message += fmt.Sprintf("（%s，聚合值 %g）", m.Aggregate, m.Value)
+		fields["aggregate"] = m.Aggregate
+		fields["value"] = strconv.FormatFloat(m.Value, 'f', -1, 64)
+		set("group", m.Group)
+	}
+	return model.RecordAlert{
+		Detector:  DetectorSigma,
+		Rule:      rule.Name(),
+		Severity:  sigmaSeverity(rule.Level),
+		Technique: sigmaTechnique(rule.Tags),
+		Message:   message,
+		Fields:    fields,
+	}
+}
+
+// sigmaSeverity 将 Sigma 规则级别转换为告警级别，未设置级别的规则按 medium 处理
+func sigmaSeverity(level string) string {
+	if model.SeverityRank(level) < 0 {
+		return model.SeverityMedium
+	}
+	return level
+}
+
+// sigmaTechnique 返回规则标签中的第一个 ATT&CK 技术编号，如 attack.t1071.004 返回 T1071.004
+func sigmaTechnique(tags []string) string {
+	for _, tag := range tags {
+		id, ok := strings.CutPrefix(strings.ToLower(tag), "attack.t")
+		if ok && id != "" && id[0] >= '0' && id[0] <= '9' {
+			return "T" + strings.ToUpper(id)
+		}
+	}
+	return ""
+}
+
+// lookalikeTechniques 仿冒手法的中文名称
+var lookalikeTechniques = map[string]string{
+	lookalike.TechniqueTLDSwap:     "更换后缀",
+	lookalike.TechniqueHomoglyph:   "形近字符",
+	lookalike.TechniqueBitsquat:    "比特翻转",
+	lookalike.TechniqueHyphenation: "添加连字符",
+	lookalike.TechniqueKeyword:     "添加关键字",
+	lookalike.TechniqueTypo:        "拼写变形",
+	lookalike.TechniqueSubdomain:   "子域名伪装",
+}
+
+// lookalikeAlert 将疑似仿冒品牌的域名转换为告警
+func lookalikeAlert(record *model.DNSRecord, f lookalike.Finding) model.RecordAlert {
+	name := f.Name
+	if f.Unicode != "" {
+		name = fmt.Sprintf("%s（%s）", f.Name, f.Unicode)
+	}
+	technique := lookalikeTechniques[f.Technique]
+	if f.Distance > 0 {
+		technique += fmt.Sprintf("，编辑距离 %d", f.Distance)
+	}
+	fields := map[string]string{
+		"technique": f.Technique,
+		"brand":     f.Brand,
+		"domain":    f.Domain,
+		"name":      f.Name,
+	}
+	if f.Unicode != "" {
+		fields["unicode"] = f.Unicode
+	}
+	if f.Distance > 0 {
+		fields["distance"] = strconv.Itoa(f.Distance)
+	}
+	return model.RecordAlert{
+		Detector:  DetectorLookalike,
+		Rule:      f.Technique,
+		Severity:  model.SeverityMedium,
+		Technique: techniqueDomains,
+		Message:   fmt.Sprintf("%s 解析的 %s 疑似仿冒 %s（%s）", record.ProcessLabel(), name, f.Brand, technique),
+		Fields:    fields,
+	}
+}
+
+// answerAlert 将应答分析的结果转换为告警
+func answerAlert(record *model.DNSRecord, f answer.Finding) model.RecordAlert {
+	addresses := strings.Join(f.Addresses, ",")
+	fields := map[string]string{
+		"category":  f.Category,
+		"name":      f.Name,
+		"addresses": addresses,
+	}
+	if f.Domain != "" {
+		fields["domain"] = f.Domain
+	}
+	if f.Owner != f.Name {
+		fields["owner"] = f.Owner
+	}
+	var message, technique string
+	severity := model.SeverityHigh
+	switch f.Category {
+	case answer.CategoryFastFlux:
+		technique = techniqueFastFlux
+		message = fmt.Sprintf("%s 的解析地址快速变化，疑似 fast-flux（%s 内 %d 个地址，分布在 %d 个网段）",
+			f.Owner, f.Window, len(f.Addresses), f.Networks)
+		fields["window"] = f.Window.String()
+		fields["count"] = strconv.Itoa(len(f.Addresses))
+		fields["networks"] = strconv.Itoa(f.Networks)
+	case answer.CategoryLowTTL:
+		message = fmt.Sprintf("%s 解析的 %s 应答 TTL 仅 %d 秒", record.ProcessLabel(), f.Name, f.TTL)
+		fields["ttl"] = strconv.FormatUint(uint64(f.TTL), 10)
+		severity = model.SeverityLow
+	case answer.CategoryRebinding:
+		message = fmt.Sprintf("%s 解析的公网域名 %s 返回了内网或保留地址 %s，疑似 DNS 重绑定", record.ProcessLabel(), f.Name, addresses)
+		fields["range"] = f.Range
+	case answer.CategorySinkhole:
+		message = fmt.Sprintf("%s 解析的 %s 返回了 sinkhole 地址 %s", record.ProcessLabel(), f.Name, addresses)
+		fields["sinkhole"] = f.Range
+	}
+	return model.RecordAlert{
+		Detector:  DetectorAnswer,
+		Rule:      f.Category,
+		Severity:  severity,
+		Technique: technique,
+		Message:   message,
+		Fields:    fields,
+	}
+}
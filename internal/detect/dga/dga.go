@@ -0,0 +1,186 @@
+// Package dga 评估查询域名由域名生成算法（DGA）产生的可能性
+// 评分基于可注册域名的标签（如 a.b.example.co.uk 中的 example），综合字符熵、元音与辅音分布、
+// 由嵌入的良性域名语料训练的字符 n-gram 语言模型、数字比例与标签长度
+package dga
+
+import (
+	"dnsflux/internal/detect/psl"
+	"math"
+	"strings"
+)
+
+// 默认配置
+const (
+	DefaultThreshold = 0.8
+)
+
+// Config DGA 评分配置
+type Config struct {
+	Enabled   bool     `yaml:"enabled"`
+	Threshold float64  `yaml:"threshold"` // 评分达到该值时告警，0 到 1 之间，0 表示只评分不告警
+	Allow     []string `yaml:"allow"`     // 不告警的可注册域名，如 CDN 使用的随机域名
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Enabled:   true,
+		Threshold: DefaultThreshold,
+	}
+}
+
+// 综合评分为各特征加权和的 logistic 函数，权重由常见网站与多种 DGA 家族的标注样本拟合
+const (
+	weightNgram     = 16
+	weightEntropy   = 5
+	weightLength    = 3.5
+	weightConsonant = 1
+	weightDigits    = 0.5
+	bias            = -7.3
+)
+
+// reliableLength 短于该长度的标签统计特征不可靠（如 qq、x、hgtv），评分按长度比例降低
+const reliableLength = 7
+
+// Result 单个域名的评分结果
+type Result struct {
+	Domain string  // 可注册域名
+	Label  string  // 参与评分的标签
+	Score  float64 // 综合评分，0 到 1，越高越可能是算法生成
+
+	// 各项特征，均映射到 0 到 1，越高越可疑
+	Ngram     float64
+	Consonant float64
+	Entropy   float64
+	Digits    float64
+	Length    float64
+}
+
+// Scorer 按配置评分并判断是否告警，可并发使用
+type Scorer struct {
+	config Config
+	allow  map[string]bool
+}
+
+// New 创建评分器
+func New(cfg Config) *Scorer {
+	s := &Scorer{config: cfg, allow: make(map[string]bool, len(cfg.Allow))}
+	for _, domain := range cfg.Allow {
+		s.allow[psl.Normalize(domain)] = true
+	}
+	return s
+}
+
+// Alert 判断评分结果是否需要告警
+func (s *Scorer) Alert(r Result) bool {
+	return s.config.Threshold > 0 &&
+		r.Score >= s.config.Threshold &&
+		!s.allow[r.Domain]
+}
+
+// Score 评估域名，无法确定可注册域名（如单标签名称、反向解析、国际化域名）时返回 false
+func Score(name string) (Result, bool) {
+	domain, label, ok := registrable(psl.Normalize(name))
+	if !ok {
+		return Result{}, false
+	}
+	r := Result{
+		Domain:    domain,
+		Label:     label,
+		Ngram:     languageModel().score(label),
+		Entropy:   entropyScore(label),
+		Consonant: consonantScore(label),
+		Digits:    digitScore(label),
+		Length:    clamp(float64(len(label)-10) / 15),
+	}
+	z := bias +
+		weightNgram*r.Ngram +
+		weightEntropy*r.Entropy +
+		weightLength*r.Length +
+		weightConsonant*r.Consonant +
+		weightDigits*r.Digits
+	r.Score = 1 / (1 + math.Exp(-z))
+	if len(label) < reliableLength {
+		r.Score *= float64(len(label)) / reliableLength
+	}
+	return r, true
+}
+
+// registrable 返回可注册域名及其公共后缀前的标签，国际化域名（xn--）不评分
+func registrable(name string) (domain, label string, ok bool) {
+	_, domain, ok = psl.Split(name)
+	if !ok {
+		return "", "", false
+	}
+	label, _, _ = strings.Cut(domain, ".")
+	if strings.HasPrefix(label, "xn--") {
+		return "", "", false
+	}
+	return domain, label, true
+}
+
+// entropyScore 字符香农熵，随机字母数字串的熵接近 log2(36)，常见单词明显更低
+func entropyScore(label string) float64 {
+	// 按固定顺序累加，保证相同标签的评分完全一致
+	var counts [256]int
+	for i := 0; i < len(label); i++ {
+		counts[label[i]]++
+	}
+	var h float64
+	n := float64(len(label))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return clamp((h - 2.5) / 1.5)
+}
+
+// consonantScore 元音比例越低、连续辅音越长越可疑，各占一半
+func consonantScore(label string) float64 {
+	var letters, vowels, run, longest int
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if c < 'a' || c > 'z' {
+			run = 0
+			continue
+		}
+		letters++
+		if strings.IndexByte("aeiouy", c) >= 0 {
+			vowels++
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	if letters == 0 {
+		return 0
+	}
+	ratio := float64(vowels) / float64(letters)
+	return 0.5*clamp((0.4-ratio)/0.3) + 0.5*clamp(float64(longest-2)/3)
+}
+
+// digitScore 字母与数字混合时数字比例越高越可疑，纯数字标签（如 163、12306）不计
+func digitScore(label string) float64 {
+	var digits, letters int
+	for i := 0; i < len(label); i++ {
+		switch c := label[i]; {
+		case c >= '0' && c <= '9':
+			digits++
+		case c >= 'a' && c <= 'z':
+			letters++
+		}
+	}
+	if digits == 0 || letters == 0 {
+		return 0
+	}
+	return clamp(float64(digits) / float64(digits+letters) / 0.4)
+}
+
+// clamp 将值限制在 0 到 1 之间
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,228 @@
+package dga
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// sampleSet 一组带标签的评估样本
+type sampleSet struct {
+	name    string
+	domains []string
+}
+
+// loadSamples 读取评估样本，"# " 开头的行作为分组名，文件首部的注释行忽略
+func loadSamples(t *testing.T, path string) []sampleSet {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var sets []sampleSet
+	current := sampleSet{name: path}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			if len(current.domains) > 0 {
+				sets = append(sets, current)
+				current = sampleSet{}
+			}
+			current.name = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		default:
+			current.domains = append(current.domains, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(current.domains) > 0 {
+		sets = append(sets, current)
+	}
+	return sets
+}
+
+// scores 评分一组域名，无法评分的域名视为测试数据错误
+func scores(t *testing.T, domains []string) []float64 {
+	t.Helper()
+	result := make([]float64, 0, len(domains))
+	for _, domain := range domains {
+		r, ok := Score(domain)
+		if !ok {
+			t.Fatalf("无法评分: %s", domain)
+		}
+		result = append(result, r.Score)
+	}
+	return result
+}
+
+// auc 计算 ROC 曲线下面积，即随机取一个 DGA 样本评分高于随机良性样本的概率
+func auc(benign, dga []float64) float64 {
+	sorted := append([]float64(nil), benign...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, s := range dga {
+		below := sort.SearchFloat64s(sorted, s)
+		equal := sort.SearchFloat64s(sorted, s+1e-12) - below
+		sum += float64(below) + float64(equal)/2
+	}
+	return sum / float64(len(benign)*len(dga))
+}
+
+// TestEvaluation 在标注样本上评估默认阈值下的检出率与误报率
+// 良性样本不在训练语料中，DGA 样本覆盖纯字母、十六进制、字母数字混合与多级公共后缀
+func TestEvaluation(t *testing.T) {
+	var benign []string
+	for _, set := range loadSamples(t, "testdata/benign.txt") {
+		benign = append(benign, set.domains...)
+	}
+	benignScores := scores(t, benign)
+
+	var falsePositives []string
+	for i, s := range benignScores {
+		if s >= DefaultThreshold {
+			falsePositives = append(falsePositives, benign[i])
+		}
+	}
+	fpr := float64(len(falsePositives)) / float64(len(benign))
+	t.Logf("良性样本 %d 个，误报率 %.1f%% %v", len(benign), fpr*100, falsePositives)
+	if fpr > 0.03 {
+		t.Errorf("误报率 %.1f%% 超过 3%%: %v", fpr*100, falsePositives)
+	}
+
+	var allDGA []float64
+	for _, family := range loadSamples(t, "testdata/dga.txt") {
+		familyScores := scores(t, family.domains)
+		allDGA = append(allDGA, familyScores...)
+
+		detected := 0
+		for _, s := range familyScores {
+			if s >= DefaultThreshold {
+				detected++
+			}
+		}
+		rate := float64(detected) / float64(len(familyScores))
+		t.Logf("%s: 检出 %d/%d", family.name, detected, len(familyScores))
+		if rate < 0.9 {
+			t.Errorf("%s 检出率 %.1f%% 低于 90%%", family.name, rate*100)
+		}
+	}
+
+	area := auc(benignScores, allDGA)
+	t.Logf("AUC %.4f", area)
+	if area < 0.98 {
+		t.Errorf("AUC %.4f 低于 0.98", area)
+	}
+}
+
+func TestScoreUsesRegistrableDomain(t *testing.T) {
+	// 子域名不影响评分，只评价可注册域名的标签
+	plain, ok := Score("example.com")
+	if !ok {
+		t.Fatal("无法评分 example.com")
+	}
+	for _, name := range []string{"x7k2q9zv0w.cdn.example.com", "WWW.Example.COM.", "a.b.c.example.com"} {
+		r, ok := Score(name)
+		if !ok || r.Domain != "example.com" || r.Label != "example" || r.Score != plain.Score {
+			t.Errorf("Score(%q) = %+v, %v", name, r, ok)
+		}
+	}
+
+	r, ok := Score("www.qxvbjkzwpfmt.co.uk")
+	if !ok || r.Domain != "qxvbjkzwpfmt.co.uk" || r.Label != "qxvbjkzwpfmt" {
+		t.Errorf("多级公共后缀: %+v, %v", r, ok)
+	}
+}
+
+func TestScoreUnscorable(t *testing.T) {
+	for _, name := range []string{"", "localhost", "com", "xn--fiqs8s.xn--fiqs8s", "xn--80ak6aa92e.com"} {
+		if r, ok := Score(name); ok {
+			t.Errorf("Score(%q) 应无法评分, got %+v", name, r)
+		}
+	}
+}
+
+func TestShortLabelsDamped(t *testing.T) {
+	// 短标签统计特征不可靠，评分按长度比例降低
+	for _, name := range []string{"qq.com", "x.com", "zx.cn", "hgtv.com", "163.com"} {
+		r, ok := Score(name)
+		if !ok {
+			t.Fatalf("无法评分 %s", name)
+		}
+		if r.Score >= DefaultThreshold {
+			t.Errorf("%s 评分 %.3f 过高", name, r.Score)
+		}
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	tests := []struct {
+		name  string
+		fn    func(string) float64
+		label string
+		want  float64
+	}{
+		{"单一字符熵为 0", entropyScore, "aaaaaaaa", 0},
+		{"随机串熵接近上限", entropyScore, "q8w2e7r1t6y3u9i0", 1},
+		{"元音充足", consonantScore, "banana", 0},
+		{"无元音且连续辅音", consonantScore, "bcdfghjk", 1},
+		{"纯字母", digitScore, "google", 0},
+		{"纯数字", digitScore, "12306", 0},
+		{"数字占一半", digitScore, "a1b2c3", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fn(tt.label); got < tt.want-0.05 || got > tt.want+0.05 {
+				t.Errorf("%s = %.3f, want %.3f", tt.label, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLanguageModel(t *testing.T) {
+	m := languageModel()
+	if m.benign <= m.random {
+		t.Fatalf("良性语料平均对数概率 %.3f 应高于随机串 %.3f", m.benign, m.random)
+	}
+	// 连字符分隔的部分分别评价
+	if joined, split := m.score("googleanalytics"), m.score("google-analytics"); split > joined+0.1 {
+		t.Errorf("google-analytics 评分 %.3f 高于 googleanalytics %.3f", split, joined)
+	}
+	if s := m.score("---"); s != 0 {
+		t.Errorf("无有效字符时评分 = %.3f, want 0", s)
+	}
+}
+
+func TestScorerAlert(t *testing.T) {
+	random, ok := Score("xjw3kq9vbz7tplm.com")
+	if !ok || random.Score < DefaultThreshold {
+		t.Fatalf("随机域名评分 %.3f 低于默认阈值", random.Score)
+	}
+	benign, _ := Score("wikipedia.org")
+
+	tests := []struct {
+		name   string
+		config Config
+		result Result
+		want   bool
+	}{
+		{"达到阈值", DefaultConfig(), random, true},
+		{"良性域名", DefaultConfig(), benign, false},
+		{"阈值为 0 时只评分", Config{Enabled: true}, random, false},
+		{"允许列表", Config{Enabled: true, Threshold: DefaultThreshold, Allow: []string{"XJW3KQ9VBZ7TPLM.com."}}, random, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.config).Alert(tt.result); got != tt.want {
+				t.Errorf("Alert = %v, want %v (score %.3f)", got, tt.want, tt.result.Score)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package dga
+
+import (
+	_ "embed"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+// benignCorpus 训练语言模型使用的良性域名标签
+//
+//go:embed benign.txt
+var benignCorpus string
+
+// 字符表：26 个字母、数字（统一按 0 处理），以及标签首尾的边界符
+const (
+	alphabet = "abcdefghijklmnopqrstuvwxyz0"
+	boundary = len(alphabet)
+	symbols  = len(alphabet) + 1
+)
+
+// 插值平滑权重，依次为三元、二元与一元概率
+const (
+	lambdaTrigram = 0.6
+	lambdaBigram  = 0.3
+	lambdaUnigram = 0.1
+)
+
+// model 字符三元语言模型，按标签内每个字符的平均对数概率评价标签与良性语料的相似程度
+type model struct {
+	unigram [symbols]float64
+	bigram  [symbols][symbols]float64
+	trigram [symbols][symbols][symbols]float64
+	total   float64
+
+	// 作为前文出现的次数
+	context1 [symbols]float64
+	context2 [symbols][symbols]float64
+
+	// 校准：良性语料与随机字符串的平均对数概率
+	benign float64
+	random float64
+}
+
+// languageModel 在首次使用时由嵌入的语料训练
+var languageModel = sync.OnceValue(func() *model {
+	m := &model{}
+	var labels []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(benignCorpus, "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+			continue
+		}
+		seen[line] = true
+		labels = append(labels, line)
+		m.train(line)
+	}
+
+	var sum float64
+	for _, label := range labels {
+		sum += m.logProb(label)
+	}
+	m.benign = sum / float64(len(labels))
+
+	// 以固定种子生成的随机字母数字串作为随机域名的参照
+	rng := rand.New(rand.NewSource(1))
+	const samples = 500
+	chars := "abcdefghijklmnopqrstuvwxyz0123456789"
+	sum = 0
+	for i := 0; i < samples; i++ {
+		b := make([]byte, 8+rng.Intn(9))
+		for j := range b {
+			b[j] = chars[rng.Intn(len(chars))]
+		}
+		sum += m.logProb(string(b))
+	}
+	m.random = sum / samples
+	return m
+})
+
+// symbol 返回字符在字符表中的序号，数字统一视为 0，不在字符表中的字符返回 -1
+func symbol(c byte) int {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a')
+	case c >= '0' && c <= '9':
+		return 26
+	default:
+		return -1
+	}
+}
+
+// sequence 将标签转换为带首尾边界符的字符序号，忽略字符表以外的字符
+func sequence(label string) []int {
+	seq := make([]int, 0, len(label)+3)
+	seq = append(seq, boundary, boundary)
+	for i := 0; i < len(label); i++ {
+		if s := symbol(label[i]); s >= 0 {
+			seq = append(seq, s)
+		}
+	}
+	return append(seq, boundary)
+}
+
+// train 统计标签中的一元、二元与三元字符组合
+func (m *model) train(label string) {
+	seq := sequence(label)
+	for i := 2; i < len(seq); i++ {
+		a, b, c := seq[i-2], seq[i-1], seq[i]
+		m.unigram[c]++
+		m.bigram[b][c]++
+		m.trigram[a][b][c]++
+		m.context1[b]++
+		m.context2[a][b]++
+		m.total++
+	}
+}
+
+// logProb 返回标签中每个字符（含结束边界）的平均自然对数概率
+// 连字符分隔的各部分分别计算，如 google-analytics 按 google 与 analytics 评价
+func (m *model) logProb(label string) float64 {
+	var sum float64
+	var n int
+	for _, part := range strings.Split(label, "-") {
+		if part == "" {
+			continue
+		}
+		seq := sequence(part)
+		for i := 2; i < len(seq); i++ {
+			a, b, c := seq[i-2], seq[i-1], seq[i]
+			p := lambdaUnigram * (m.unigram[c] + 1) / (m.total + float64(symbols))
+			if n := m.context2[a][b]; n > 0 {
+				p += lambdaTrigram * m.trigram[a][b][c] / n
+			}
+			if n := m.context1[b]; n > 0 {
+				p += lambdaBigram * m.bigram[b][c] / n
+			}
+			sum += math.Log(p)
+		}
+		n += len(seq) - 2
+	}
+	if n == 0 {
+		return m.benign
+	}
+	return sum / float64(n)
+}
+
+// score 将平均对数概率映射到 0-1：接近良性语料为 0，接近随机字符串为 1
+func (m *model) score(label string) float64 {
+	return clamp((m.benign - m.logProb(label)) / (m.benign - m.random))
+}
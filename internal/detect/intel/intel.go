@@ -0,0 +1,96 @@
+// Package intel 从本地威胁情报文件加载恶意域名，按域名本身与父域名匹配 DNS 查询
+// 支持纯域名列表、hosts 文件、RPZ 区域文件、STIX 2.1 bundle 与 MISP JSON 导出
+package intel
+
+import (
+	"dnsflux/internal/output"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 情报文件格式
+const (
+	FormatAuto    = "auto"
+	FormatDomains = "domains"
+	FormatHosts   = "hosts"
+	FormatRPZ     = "rpz"
+	FormatSTIX    = "stix"
+	FormatMISP    = "misp"
+)
+
+// ActionPassthru RPZ 放行动作，匹配时不产生告警
+const ActionPassthru = "PASSTHRU"
+
+// Formats 支持的情报文件格式
+var Formats = []string{FormatAuto, FormatDomains, FormatHosts, FormatRPZ, FormatSTIX, FormatMISP}
+
+// DefaultReloadInterval 默认的情报文件变化检查间隔
+const DefaultReloadInterval = 30 * time.Second
+
+// Config 威胁情报配置
+type Config struct {
+	Feeds          []FeedConfig    `yaml:"feeds"`
+	ReloadInterval output.Duration `yaml:"reloadInterval"` // 检查情报文件变化的间隔，0 使用默认值，负数表示不检查
+}
+
+// FeedConfig 单个情报源配置
+type FeedConfig struct {
+	Name        string `yaml:"name"`        // 情报源名称，为空时使用文件名
+	Path        string `yaml:"path"`        // 情报文件路径
+	Format      string `yaml:"format"`      // 文件格式，为空或 auto 时根据内容判断
+	Exact       bool   `yaml:"exact"`       // 只匹配域名本身，不匹配子域名（RPZ 中的通配符条目不受影响）
+	Description string `yaml:"description"` // 情报源说明，附加到告警
+	Reference   string `yaml:"reference"`   // 情报源链接，附加到告警
+}
+
+// FeedName 返回情报源名称，未配置时使用不带扩展名的文件名
+func (c FeedConfig) FeedName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	base := filepath.Base(c.Path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Feed 情报源信息
+type Feed struct {
+	Name        string
+	Path        string
+	Format      string // 实际使用的格式，auto 时为判断出的格式
+	Description string
+	Reference   string
+}
+
+// IOC 一条域名情报
+type IOC struct {
+	Domain      string   // 小写、不带末尾的点
+	Scope       Scope    // 匹配范围
+	Feed        *Feed    // 所属情报源
+	Description string   // 情报说明，如 STIX indicator 名称、MISP 事件信息
+	Reference   string   // 情报 ID 或链接
+	Tags        []string // 标签、分类
+	Action      string   // RPZ 策略动作，如 NXDOMAIN、NODATA、DROP
+}
+
+// Match 一次匹配结果
+type Match struct {
+	IOC    *IOC
+	Parent bool // 通过父域名匹配
+}
+
+// normalizeDomain 规范化情报中的域名，无效时返回空字符串
+func normalizeDomain(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	value = strings.TrimSuffix(value, ".")
+	if value == "" || len(value) > 253 || strings.ContainsAny(value, " \t/:@") || net.ParseIP(value) != nil {
+		return ""
+	}
+	for _, label := range strings.Split(value, ".") {
+		if label == "" || len(label) > 63 {
+			return ""
+		}
+	}
+	return value
+}
@@ -0,0 +1,315 @@
+package intel
+
+import (
+	"dnsflux/internal/output"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// describe 将情报条目转换为便于比较的字符串
+// ScopeExact 以 = 开头，ScopeSubdomains 以 *. 开头，有动作时附加在空格后
+func describe(iocs []*IOC) []string {
+	var out []string
+	for _, ioc := range iocs {
+		s := ioc.Domain
+		switch ioc.Scope {
+		case ScopeExact:
+			s = "=" + s
+		case ScopeSubdomains:
+			s = "*." + s
+		}
+		if ioc.Action != "" {
+			s += " " + ioc.Action
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantFormat string
+		want       []string
+	}{
+		{
+			"纯域名列表",
+			"# 注释\nEvil.Example.\n*.wild.example\n||adblock.example^\nbad.example # 行内注释\n! adblock 注释\n10.0.0.1\nnot/a/domain\n",
+			FormatDomains,
+			[]string{"evil.example", "*.wild.example", "adblock.example", "bad.example"},
+		},
+		{
+			"hosts 文件",
+			"127.0.0.1 localhost\n::1 ip6-localhost ip6-loopback\n0.0.0.0 ads.example tracker.example\n# 0.0.0.0 commented.example\n",
+			FormatHosts,
+			[]string{"ads.example", "tracker.example"},
+		},
+		{
+			"RPZ 区域文件",
+			`$TTL 300
+$ORIGIN rpz.local.
+@ SOA ns.rpz.local. admin.rpz.local. (
+      1 3600 600 86400 300 )
+  NS ns.rpz.local.
+bad.example        CNAME .
+*.bad.example      CNAME *.
+ok.bad.example     CNAME rpz-passthru.
+drop.example.rpz.local. 300 IN CNAME rpz-drop.
+redirect.example   CNAME walled.garden.example.
+local.example      A 10.0.0.1
+                   AAAA fd00::1
+32.1.0.0.10.rpz-ip CNAME .  ; 按 IP 触发的条目不是域名
+ns.example.rpz-nsdname CNAME .
+`,
+			FormatRPZ,
+			[]string{
+				"=bad.example NXDOMAIN",
+				"*.bad.example NODATA",
+				"=ok.bad.example PASSTHRU",
+				"=drop.example DROP",
+				"=redirect.example CNAME walled.garden.example",
+				"=local.example LOCAL-DATA",
+				"=local.example LOCAL-DATA",
+			},
+		},
+		{
+			"STIX bundle",
+			`{"type":"bundle","objects":[
+				{"type":"indicator","id":"indicator--1","name":"C2","pattern_type":"stix","pattern":"[domain-name:value = 'c2.example'] OR [url:value = 'https://user@phish.example:8443/login?x=1']"},
+				{"type":"indicator","id":"indicator--2","pattern":"[email-addr:value = 'bad@mail.example']"},
+				{"type":"indicator","id":"indicator--3","revoked":true,"pattern":"[domain-name:value = 'revoked.example']"},
+				{"type":"indicator","id":"indicator--4","valid_until":"2000-01-01T00:00:00Z","pattern":"[domain-name:value = 'expired.example']"},
+				{"type":"indicator","id":"indicator--5","pattern_type":"sigma","pattern":"domain-name:value = 'sigma.example'"},
+				{"type":"indicator","id":"indicator--6","pattern":"[ipv4-addr:value = '10.0.0.1']"},
+				{"type":"domain-name","id":"domain-name--1","value":"object.example"}
+			]}`,
+			FormatSTIX,
+			[]string{"c2.example", "phish.example", "mail.example", "object.example"},
+		},
+		{
+			"MISP 单个事件",
+			`{"Event":{"uuid":"e1","info":"钓鱼活动","Attribute":[
+				{"type":"domain","value":"phish.example","to_ids":true},
+				{"type":"hostname","value":"host.example","to_ids":"1"},
+				{"type":"domain|ip","value":"pair.example|10.0.0.1"},
+				{"type":"domain","value":"noids.example","to_ids":false},
+				{"type":"domain","value":"deleted.example","deleted":true},
+				{"type":"ip-dst","value":"10.0.0.2"}
+			],"Object":[{"Attribute":[{"type":"domain","value":"object.example","to_ids":"0"},{"type":"domain","value":"nested.example"}]}]}}`,
+			FormatMISP,
+			[]string{"phish.example", "host.example", "pair.example", "nested.example"},
+		},
+		{
+			"MISP 搜索结果",
+			`{"response":[{"Event":{"uuid":"e1","Attribute":[{"type":"domain","value":"a.example"}]}},{"Event":{"uuid":"e2","Attribute":[{"type":"domain","value":"b.example"}]}}]}`,
+			FormatMISP,
+			[]string{"a.example", "b.example"},
+		},
+		{
+			"MISP 事件数组",
+			`[{"Event":{"uuid":"e1","Attribute":[{"type":"hostname","value":"c.example"}]}}]`,
+			FormatMISP,
+			[]string{"c.example"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(tt.data)
+			if got := DetectFormat(data); got != tt.wantFormat {
+				t.Errorf("DetectFormat() = %s, want %s", got, tt.wantFormat)
+			}
+			iocs, err := Parse(data, FormatAuto)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describe(iocs); !slices.Equal(got, tt.want) {
+				t.Errorf("Parse() = %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDetails(t *testing.T) {
+	stix := `{"type":"bundle","objects":[{"type":"indicator","id":"indicator--1","name":"C2","labels":["malicious-activity"],"indicator_types":["c2"],"pattern":"[domain-name:value = 'c2.example']"}]}`
+	misp := `{"Event":{"uuid":"e1","info":"钓鱼活动","Tag":[{"name":"tlp:white"}],"Attribute":[{"uuid":"a1","category":"Network activity","type":"domain","value":"phish.example","comment":"登录页","Tag":[{"name":"phishing"}]}]}}`
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		want   IOC
+	}{
+		{"STIX 名称、ID 与标签", stix, FormatSTIX, IOC{Domain: "c2.example", Description: "C2", Reference: "indicator--1", Tags: []string{"c2", "malicious-activity"}}},
+		{"MISP 事件信息、属性 UUID 与标签", misp, FormatMISP, IOC{Domain: "phish.example", Description: "钓鱼活动: 登录页", Reference: "a1", Tags: []string{"Network activity", "tlp:white", "phishing"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iocs, err := Parse([]byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(iocs) != 1 {
+				t.Fatalf("解析出 %d 条, want 1", len(iocs))
+			}
+			got := iocs[0]
+			if got.Domain != tt.want.Domain || got.Description != tt.want.Description || got.Reference != tt.want.Reference || !slices.Equal(got.Tags, tt.want.Tags) {
+				t.Errorf("got %+v\nwant %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+	}{
+		{"未知格式", "evil.example", "csv"},
+		{"STIX 格式错误", "{", FormatSTIX},
+		{"不是 STIX bundle", `{"type":"indicator"}`, FormatSTIX},
+		{"MISP 中没有事件", `{"response":[]}`, FormatMISP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data), tt.format); err == nil {
+				t.Error("应返回错误")
+			}
+		})
+	}
+}
+
+// writeFeed 在临时目录中写入情报文件
+func writeFeed(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// matched 将匹配结果转换为“情报源:域名”形式，通过父域名匹配时以 ^ 结尾
+func matched(matches []Match) []string {
+	var out []string
+	for _, m := range matches {
+		s := m.IOC.Feed.Name + ":" + m.IOC.Domain
+		if m.Parent {
+			s += "^"
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestMatch(t *testing.T) {
+	dir := t.TempDir()
+	m, err := New(Config{
+		ReloadInterval: -1,
+		Feeds: []FeedConfig{
+			{Name: "list", Path: writeFeed(t, dir, "list.txt", "evil.example\nsub.evil.example\n*.wild.example\n")},
+			{Path: writeFeed(t, dir, "exact.txt", "evil.example\nexact.example\n"), Exact: true},
+			{Name: "rpz", Path: writeFeed(t, dir, "policy.rpz", "$ORIGIN rpz.local.\nbad.example CNAME .\n*.bad.example CNAME .\nok.bad.example CNAME rpz-passthru.\n")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if m.Size() != 8 {
+		t.Errorf("Size() = %d, want 8", m.Size())
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"域名本身", "evil.example", []string{"list:evil.example", "exact:evil.example"}},
+		{"大小写与末尾的点", "EVIL.Example.", []string{"list:evil.example", "exact:evil.example"}},
+		{"父域名匹配，exact 情报源不匹配子域名", "www.evil.example", []string{"list:evil.example^"}},
+		{"同一情报源只返回最具体的条目", "a.sub.evil.example", []string{"list:sub.evil.example^"}},
+		{"仅子域名的条目不匹配域名本身", "wild.example", nil},
+		{"仅子域名的条目匹配子域名", "x.y.wild.example", []string{"list:wild.example^"}},
+		{"标签不完整时不匹配", "notevil.example", nil},
+		{"exact 情报源匹配域名本身", "exact.example", []string{"exact:exact.example"}},
+		{"RPZ 精确条目", "bad.example", []string{"rpz:bad.example"}},
+		{"RPZ 通配符条目", "x.bad.example", []string{"rpz:bad.example^"}},
+		{"RPZ 放行条目使该情报源不返回结果", "ok.bad.example", nil},
+		{"RPZ 放行条目只对域名本身生效", "x.ok.bad.example", []string{"rpz:bad.example^"}},
+		{"空查询", ".", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matched(m.Match(tt.query)); !slices.Equal(got, tt.want) {
+				t.Errorf("Match(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// 情报源信息附加到每条匹配结果
+	feed := m.Match("bad.example")[0].IOC.Feed
+	if feed.Format != FormatRPZ || feed.Path != filepath.Join(dir, "policy.rpz") {
+		t.Errorf("情报源信息 = %+v", *feed)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFeed(t, dir, "feed.json", `{"Event":{"uuid":"e1","Attribute":[{"type":"domain","value":"old.example"}]}}`)
+	m, err := New(Config{
+		ReloadInterval: output.Duration(10 * time.Millisecond),
+		Feeds:          []FeedConfig{{Name: "misp", Path: path, Format: FormatMISP}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// waitMatch 等待查询的匹配结果变为 want
+	waitMatch := func(query string, want bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for (len(m.Match(query)) > 0) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("等待 %s 匹配结果变为 %v 超时", query, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	writeFeed(t, dir, "feed.json", `{"Event":{"uuid":"e1","Attribute":[{"type":"domain","value":"new.example"},{"type":"domain","value":"other.example"}]}}`)
+	waitMatch("new.example", true)
+	if m.Match("old.example") != nil {
+		t.Error("重新加载后仍匹配已移除的条目")
+	}
+
+	// 重新加载失败时保留之前的内容
+	writeFeed(t, dir, "feed.json", strings.Repeat("{", 10))
+	time.Sleep(100 * time.Millisecond)
+	if m.Match("new.example") == nil || m.Size() != 2 {
+		t.Error("文件无效时丢弃了之前的内容")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		feed FeedConfig
+	}{
+		{"文件不存在", FeedConfig{Path: filepath.Join(dir, "missing.txt")}},
+		{"文件格式错误", FeedConfig{Path: writeFeed(t, dir, "bad.json", "{"), Format: FormatSTIX}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(Config{Feeds: []FeedConfig{tt.feed}}); err == nil {
+				t.Error("应返回错误")
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package intel
+
+import (
+	"context"
+	"dnsflux/pkg/logger"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// log 威胁情报日志
+var log = logger.Component("detect").With("detector", "intel")
+
+// Matcher 加载情报源并匹配域名，情报文件变化时自动重新加载
+// Match 可与重新加载并发调用
+type Matcher struct {
+	feeds  []*feed
+	trie   atomic.Pointer[trie]
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// feed 已加载的情报源
+type feed struct {
+	config  FeedConfig
+	name    string
+	iocs    []*IOC
+	modTime time.Time
+	size    int64
+}
+
+// New 加载全部情报源，任一情报文件无法读取或解析时返回错误
+func New(cfg Config) (*Matcher, error) {
+	m := &Matcher{done: make(chan struct{})}
+	for _, fc := range cfg.Feeds {
+		f := &feed{config: fc, name: fc.FeedName()}
+		if err := f.load(); err != nil {
+			return nil, err
+		}
+		log.Info(fmt.Sprintf("已加载威胁情报 %s: %d 条", f.name, len(f.iocs)))
+		m.feeds = append(m.feeds, f)
+	}
+	m.rebuild()
+
+	interval := cfg.ReloadInterval.Std()
+	if interval == 0 {
+		interval = DefaultReloadInterval
+	}
+	if interval < 0 || len(m.feeds) == 0 {
+		close(m.done)
+		m.cancel = func() {}
+		return m, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	go m.watch(ctx, interval)
+	return m, nil
+}
+
+// Match 返回匹配域名的情报，越具体的匹配越靠前
+// 同一情报源只返回最具体的一条，最具体的条目为 RPZ 放行条目时该情报源不返回结果
+func (m *Matcher) Match(name string) []Match {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name == "" {
+		return nil
+	}
+	matches := m.trie.Load().lookup(name)
+	if len(matches) == 0 {
+		return nil
+	}
+	seen := make(map[*Feed]bool, len(matches))
+	result := matches[:0]
+	for _, match := range matches {
+		if seen[match.IOC.Feed] {
+			continue
+		}
+		seen[match.IOC.Feed] = true
+		if match.IOC.Action != ActionPassthru {
+			result = append(result, match)
+		}
+	}
+	return result
+}
+
+// Size 返回已加载的情报条数
+func (m *Matcher) Size() int {
+	return m.trie.Load().size
+}
+
+// Close 停止检查情报文件变化
+func (m *Matcher) Close() {
+	m.cancel()
+	<-m.done
+}
+
+// watch 定期检查情报文件的修改时间与大小，变化时重新加载
+// 重新加载失败时保留该情报源之前的内容
+func (m *Matcher) watch(ctx context.Context, interval time.Duration) {
+	defer close(m.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed := false
+		for _, f := range m.feeds {
+			info, err := os.Stat(f.config.Path)
+			if err != nil || info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+				continue
+			}
+			if err := f.load(); err != nil {
+				log.Warn(fmt.Sprintf("重新加载威胁情报 %s 失败，继续使用之前的内容: %v", f.name, err))
+				continue
+			}
+			log.Info(fmt.Sprintf("已重新加载威胁情报 %s: %d 条", f.name, len(f.iocs)))
+			changed = true
+		}
+		if changed {
+			m.rebuild()
+		}
+	}
+}
+
+// rebuild 由全部情报源重建后缀树并原子替换
+func (m *Matcher) rebuild() {
+	t := &trie{}
+	for _, f := range m.feeds {
+		for _, ioc := range f.iocs {
+			t.insert(ioc)
+		}
+	}
+	m.trie.Store(t)
+}
+
+// load 读取并解析情报文件
+func (f *feed) load() error {
+	stat, err := os.Stat(f.config.Path)
+	if err != nil {
+		return fmt.Errorf("读取威胁情报 %s 失败: %w", f.name, err)
+	}
+	data, err := os.ReadFile(f.config.Path)
+	if err != nil {
+		return fmt.Errorf("读取威胁情报 %s 失败: %w", f.name, err)
+	}
+	format := f.config.Format
+	if format == "" || format == FormatAuto {
+		format = DetectFormat(data)
+	}
+	iocs, err := Parse(data, format)
+	if err != nil {
+		return fmt.Errorf("解析威胁情报 %s 失败: %w", f.name, err)
+	}
+	// 每次加载使用新的情报源信息，已附加到告警的旧条目不受影响
+	info := &Feed{
+		Name:        f.name,
+		Path:        f.config.Path,
+		Format:      format,
+		Description: f.config.Description,
+		Reference:   f.config.Reference,
+	}
+	for _, ioc := range iocs {
+		ioc.Feed = info
+		if f.config.Exact && ioc.Scope == ScopeDomain {
+			ioc.Scope = ScopeExact
+		}
+	}
+	f.iocs, f.modTime, f.size = iocs, stat.ModTime(), stat.Size()
+	return nil
+}
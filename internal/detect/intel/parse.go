@@ -0,0 +1,517 @@
+package intel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// hosts 文件中不作为情报的主机名
+var hostsIgnored = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+	"0.0.0.0":               true,
+}
+
+// Parse 按格式解析情报文件，format 为 auto 或空时根据内容判断
+// 返回的条目尚未设置 Feed，匹配范围为默认的 ScopeDomain（RPZ 按条目决定）
+func Parse(data []byte, format string) ([]*IOC, error) {
+	if format == "" || format == FormatAuto {
+		format = DetectFormat(data)
+	}
+	switch format {
+	case FormatDomains:
+		return parseDomains(data), nil
+	case FormatHosts:
+		return parseHosts(data), nil
+	case FormatRPZ:
+		return parseRPZ(data), nil
+	case FormatSTIX:
+		return parseSTIX(data)
+	case FormatMISP:
+		return parseMISP(data)
+	default:
+		return nil, fmt.Errorf("未知的情报格式 %q", format)
+	}
+}
+
+// DetectFormat 根据内容判断情报文件格式
+func DetectFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		var probe struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(trimmed, &probe) == nil && probe.Type == "bundle" {
+			return FormatSTIX
+		}
+		return FormatMISP
+	}
+
+	hosts := 0
+	for _, line := range lines(data) {
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "$ORIGIN"), strings.HasPrefix(line, "$TTL"), rrType(fields) == "SOA":
+			return FormatRPZ
+		case len(fields) >= 2 && isIP(fields[0]):
+			hosts++
+		}
+	}
+	if hosts > 0 {
+		return FormatHosts
+	}
+	return FormatDomains
+}
+
+// lines 返回去除注释与首尾空白后的非空行
+// 支持 #、; 与 ! 开头的整行注释，以及行内 # 注释
+func lines(data []byte) []string {
+	var result []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '!' {
+			continue
+		}
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// parseDomains 解析纯域名列表，每行一个域名
+// 兼容 *.example.com（仅子域名）与 Adblock 风格的 ||example.com^
+func parseDomains(data []byte) []*IOC {
+	var iocs []*IOC
+	for _, line := range lines(data) {
+		value := strings.Fields(line)[0]
+		scope := ScopeDomain
+		if strings.HasPrefix(value, "||") {
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "||"), "^")
+		} else if rest, ok := strings.CutPrefix(value, "*."); ok {
+			value, scope = rest, ScopeSubdomains
+		}
+		if domain := normalizeDomain(value); domain != "" {
+			iocs = append(iocs, &IOC{Domain: domain, Scope: scope})
+		}
+	}
+	return iocs
+}
+
+// parseHosts 解析 hosts 文件格式，每行为 IP 地址与一个或多个主机名
+func parseHosts(data []byte) []*IOC {
+	var iocs []*IOC
+	for _, line := range lines(data) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !isIP(fields[0]) {
+			continue
+		}
+		for _, name := range fields[1:] {
+			domain := normalizeDomain(name)
+			if domain == "" || hostsIgnored[domain] {
+				continue
+			}
+			iocs = append(iocs, &IOC{Domain: domain})
+		}
+	}
+	return iocs
+}
+
+// rpzTriggerLabels RPZ 中按 IP、名称服务器或客户端触发的条目，不是查询域名
+var rpzTriggerLabels = []string{"rpz-ip", "rpz-nsdname", "rpz-nsip", "rpz-client-ip"}
+
+// parseRPZ 解析 RPZ 区域文件
+// 条目名称相对于 $ORIGIN，example.com 只匹配域名本身，*.example.com 只匹配子域名；
+// 策略为 rpz-passthru 的放行条目以 PASSTHRU 动作保留，匹配时使同一区域中更宽泛的条目失效
+func parseRPZ(data []byte) []*IOC {
+	var (
+		iocs   []*IOC
+		origin string
+		owner  string
+		depth  int // SOA 等记录跨行的括号层数
+	)
+	for _, raw := range rpzLines(data) {
+		line := strings.TrimRight(raw, " \t")
+		if depth > 0 {
+			depth += strings.Count(line, "(") - strings.Count(line, ")")
+			continue
+		}
+		depth = strings.Count(line, "(") - strings.Count(line, ")")
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "$ORIGIN":
+			if len(fields) > 1 {
+				origin = strings.ToLower(strings.TrimSuffix(fields[1], "."))
+			}
+			continue
+		case "$TTL", "$INCLUDE":
+			continue
+		}
+		// 以空白开头的行沿用上一条记录的名称
+		if line[0] != ' ' && line[0] != '\t' {
+			owner = fields[0]
+			fields = fields[1:]
+		}
+
+		typ := rrType(append([]string{owner}, fields...))
+		if typ == "" || typ == "SOA" || typ == "NS" {
+			continue
+		}
+		name := rpzOwner(owner, origin)
+		if name == "" {
+			continue
+		}
+		action := rpzAction(typ, fields)
+
+		scope := ScopeExact
+		if rest, ok := strings.CutPrefix(name, "*."); ok {
+			name, scope = rest, ScopeSubdomains
+		}
+		if domain := normalizeDomain(name); domain != "" {
+			iocs = append(iocs, &IOC{Domain: domain, Scope: scope, Action: action})
+		}
+	}
+	return iocs
+}
+
+// rpzLines 返回去除 ; 注释后的行，保留行首空白
+func rpzLines(data []byte) []string {
+	var result []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// rpzOwner 将条目名称转换为查询域名，@ 表示区域本身，不是策略条目
+func rpzOwner(owner, origin string) string {
+	owner = strings.ToLower(owner)
+	if owner == "" || owner == "@" {
+		return ""
+	}
+	if strings.HasSuffix(owner, ".") {
+		owner = strings.TrimSuffix(owner, ".")
+		if origin != "" {
+			if owner == origin {
+				return ""
+			}
+			owner = strings.TrimSuffix(owner, "."+origin)
+		}
+	}
+	for _, label := range strings.Split(owner, ".") {
+		for _, trigger := range rpzTriggerLabels {
+			if label == trigger {
+				return ""
+			}
+		}
+	}
+	return owner
+}
+
+// rpzAction 根据记录类型与数据确定 RPZ 策略动作
+func rpzAction(typ string, fields []string) string {
+	if typ != "CNAME" {
+		return "LOCAL-DATA"
+	}
+	target := strings.ToLower(fields[len(fields)-1])
+	switch target {
+	case ".":
+		return "NXDOMAIN"
+	case "*.":
+		return "NODATA"
+	case "rpz-passthru.":
+		return ActionPassthru
+	case "rpz-drop.":
+		return "DROP"
+	case "rpz-tcp-only.":
+		return "TCP-ONLY"
+	default:
+		return "CNAME " + strings.TrimSuffix(target, ".")
+	}
+}
+
+// rrClasses 区域文件中的记录类别
+var rrClasses = map[string]bool{"IN": true, "CH": true, "HS": true, "CS": true}
+
+// rrType 返回区域文件记录的类型，fields 第一项为名称，其后可有 TTL 与类别
+func rrType(fields []string) string {
+	for i := 1; i < len(fields); i++ {
+		field := strings.ToUpper(fields[i])
+		if rrClasses[field] || isTTL(field) {
+			continue
+		}
+		if i == len(fields)-1 {
+			return ""
+		}
+		return field
+	}
+	return ""
+}
+
+// isTTL 判断字段是否为 TTL，如 300、1h、1d2h
+func isTTL(field string) bool {
+	if field == "" {
+		return false
+	}
+	for _, c := range field {
+		if !strings.ContainsRune("0123456789SMHDW", c) {
+			return false
+		}
+	}
+	return field[0] >= '0' && field[0] <= '9'
+}
+
+// stixDomainPattern STIX 模式中的域名比较表达式
+var stixDomainPattern = regexp.MustCompile(`(domain-name|url|email-addr):value\s*=\s*'((?:[^'\\]|\\.)*)'`)
+
+// stixObject STIX 2.1 对象中用到的字段
+type stixObject struct {
+	Type           string   `json:"type"`
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	Description    string   `json:"description"`
+	Pattern        string   `json:"pattern"`
+	PatternType    string   `json:"pattern_type"`
+	Value          string   `json:"value"`
+	Labels         []string `json:"labels"`
+	IndicatorTypes []string `json:"indicator_types"`
+	Revoked        bool     `json:"revoked"`
+	ValidUntil     string   `json:"valid_until"`
+}
+
+// parseSTIX 解析 STIX 2.1 bundle
+// 提取 indicator 模式中的 domain-name、url 与 email-addr 域名，以及 domain-name 对象；已撤销或过期的 indicator 被忽略
+func parseSTIX(data []byte) ([]*IOC, error) {
+	var bundle struct {
+		Type    string       `json:"type"`
+		Objects []stixObject `json:"objects"`
+	}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("STIX bundle 格式错误: %w", err)
+	}
+	if bundle.Type != "bundle" {
+		return nil, fmt.Errorf("不是 STIX bundle (type=%q)", bundle.Type)
+	}
+
+	now := time.Now()
+	var iocs []*IOC
+	for _, obj := range bundle.Objects {
+		if obj.Revoked {
+			continue
+		}
+		switch obj.Type {
+		case "indicator":
+			if obj.PatternType != "" && obj.PatternType != "stix" {
+				continue
+			}
+			if until, err := time.Parse(time.RFC3339, obj.ValidUntil); err == nil && until.Before(now) {
+				continue
+			}
+			description := obj.Name
+			if description == "" {
+				description = obj.Description
+			}
+			tags := append(append([]string{}, obj.IndicatorTypes...), obj.Labels...)
+			for _, m := range stixDomainPattern.FindAllStringSubmatch(obj.Pattern, -1) {
+				value := strings.ReplaceAll(m[2], `\'`, `'`)
+				if domain := normalizeDomain(hostOf(m[1], value)); domain != "" {
+					iocs = append(iocs, &IOC{Domain: domain, Description: description, Reference: obj.ID, Tags: tags})
+				}
+			}
+		case "domain-name":
+			if domain := normalizeDomain(obj.Value); domain != "" {
+				iocs = append(iocs, &IOC{Domain: domain, Reference: obj.ID})
+			}
+		}
+	}
+	return iocs, nil
+}
+
+// hostOf 从 URL 或邮件地址中取出域名
+func hostOf(kind, value string) string {
+	switch kind {
+	case "url":
+		if _, rest, ok := strings.Cut(value, "://"); ok {
+			value = rest
+		}
+		if i := strings.IndexAny(value, "/?#"); i >= 0 {
+			value = value[:i]
+		}
+		if i := strings.LastIndexByte(value, '@'); i >= 0 {
+			value = value[i+1:]
+		}
+		if i := strings.LastIndexByte(value, ':'); i >= 0 && !strings.Contains(value, "]") {
+			value = value[:i]
+		}
+	case "email-addr":
+		if i := strings.LastIndexByte(value, '@'); i >= 0 {
+			value = value[i+1:]
+		}
+	}
+	return value
+}
+
+// MISP 中表示域名的属性类型
+var mispDomainTypes = map[string]bool{
+	"domain":    true,
+	"hostname":  true,
+	"domain|ip": true,
+}
+
+// mispTag MISP 标签
+type mispTag struct {
+	Name string `json:"name"`
+}
+
+// mispAttribute MISP 属性
+type mispAttribute struct {
+	UUID     string    `json:"uuid"`
+	Type     string    `json:"type"`
+	Category string    `json:"category"`
+	Value    string    `json:"value"`
+	Comment  string    `json:"comment"`
+	ToIDS    any       `json:"to_ids"`
+	Deleted  any       `json:"deleted"`
+	Tag      []mispTag `json:"Tag"`
+}
+
+// mispEvent MISP 事件
+type mispEvent struct {
+	UUID      string          `json:"uuid"`
+	Info      string          `json:"info"`
+	Attribute []mispAttribute `json:"Attribute"`
+	Object    []struct {
+		Attribute []mispAttribute `json:"Attribute"`
+	} `json:"Object"`
+	Tag []mispTag `json:"Tag"`
+}
+
+// parseMISP 解析 MISP JSON 导出，支持单个事件、事件数组与 REST 搜索结果 {"response": [...]}
+// 只使用 domain、hostname 与 domain|ip 属性，to_ids 为 false 或已删除的属性被忽略
+func parseMISP(data []byte) ([]*IOC, error) {
+	events, err := mispEvents(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var iocs []*IOC
+	for _, event := range events {
+		attrs := event.Attribute
+		for _, obj := range event.Object {
+			attrs = append(attrs, obj.Attribute...)
+		}
+		for _, attr := range attrs {
+			if !mispDomainTypes[attr.Type] || isFalse(attr.ToIDS) || isTrue(attr.Deleted) {
+				continue
+			}
+			value, _, _ := strings.Cut(attr.Value, "|")
+			domain := normalizeDomain(value)
+			if domain == "" {
+				continue
+			}
+			description := event.Info
+			if attr.Comment != "" {
+				description += ": " + attr.Comment
+			}
+			var tags []string
+			if attr.Category != "" {
+				tags = append(tags, attr.Category)
+			}
+			for _, tag := range append(event.Tag, attr.Tag...) {
+				tags = append(tags, tag.Name)
+			}
+			reference := attr.UUID
+			if reference == "" {
+				reference = event.UUID
+			}
+			iocs = append(iocs, &IOC{Domain: domain, Description: description, Reference: reference, Tags: tags})
+		}
+	}
+	return iocs, nil
+}
+
+// mispEvents 解析 MISP 导出中的事件
+func mispEvents(data []byte) ([]mispEvent, error) {
+	type wrapped struct {
+		Event mispEvent `json:"Event"`
+	}
+	var doc struct {
+		wrapped
+		Response []wrapped `json:"response"`
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var list []wrapped
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return nil, fmt.Errorf("MISP 导出格式错误: %w", err)
+		}
+		doc.Response = list
+	} else if err := json.Unmarshal(trimmed, &doc); err != nil {
+		return nil, fmt.Errorf("MISP 导出格式错误: %w", err)
+	}
+
+	events := make([]mispEvent, 0, len(doc.Response)+1)
+	if doc.Event.UUID != "" || len(doc.Event.Attribute) > 0 || len(doc.Event.Object) > 0 {
+		events = append(events, doc.Event)
+	}
+	for _, w := range doc.Response {
+		events = append(events, w.Event)
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("MISP 导出中没有事件")
+	}
+	return events, nil
+}
+
+// isTrue 判断 MISP 中以布尔值、数字或字符串表示的真值
+func isTrue(v any) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case float64:
+		return value != 0
+	case string:
+		return value == "1" || strings.EqualFold(value, "true")
+	}
+	return false
+}
+
+// isFalse 判断 MISP 中以布尔值、数字或字符串表示的假值
+func isFalse(v any) bool {
+	return v != nil && !isTrue(v)
+}
+
+// isIP 判断字符串是否为 IP 地址
+func isIP(value string) bool {
+	return net.ParseIP(value) != nil
+}
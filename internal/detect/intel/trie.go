@@ -0,0 +1,88 @@
+package intel
+
+import "strings"
+
+// Scope 情报条目的匹配范围
+type Scope int
+
+const (
+	ScopeDomain     Scope = iota // 域名本身及其子域名
+	ScopeExact                   // 仅域名本身
+	ScopeSubdomains              // 仅子域名，如 RPZ 中的 *.example.com
+)
+
+// trie 按标签从右到左组织的域名后缀树
+type trie struct {
+	root node
+	size int
+}
+
+// node 后缀树节点，self 为匹配该域名本身的条目，below 为匹配其子域名的条目
+type node struct {
+	children map[string]*node
+	self     []*IOC
+	below    []*IOC
+}
+
+// insert 添加情报条目
+func (t *trie) insert(ioc *IOC) {
+	n := &t.root
+	labels := strings.Split(ioc.Domain, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		child, ok := n.children[labels[i]]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[string]*node)
+			}
+			child = &node{}
+			n.children[labels[i]] = child
+		}
+		n = child
+	}
+	if ioc.Scope != ScopeSubdomains {
+		n.self = append(n.self, ioc)
+	}
+	if ioc.Scope != ScopeExact {
+		n.below = append(n.below, ioc)
+	}
+	t.size++
+}
+
+// lookup 返回匹配域名的全部条目，越具体的匹配越靠前，同一层级按添加顺序排列
+// name 须为小写且不带末尾的点
+func (t *trie) lookup(name string) []Match {
+	// 自上而下记录各层级命中的条目
+	var levels [][]Match
+	n := &t.root
+	end := len(name)
+	for end > 0 {
+		start := strings.LastIndexByte(name[:end], '.') + 1
+		child, ok := n.children[name[start:end]]
+		if !ok {
+			break
+		}
+		n = child
+		if start == 0 {
+			levels = append(levels, matchesOf(n.self, false))
+			break
+		}
+		// 祖先域名上的条目以子域名方式匹配
+		levels = append(levels, matchesOf(n.below, true))
+		end = start - 1
+	}
+
+	var matches []Match
+	for i := len(levels) - 1; i >= 0; i-- {
+		matches = append(matches, levels[i]...)
+	}
+	return matches
+}
+
+// matchesOf 将条目转换为匹配结果
+func matchesOf(iocs []*IOC, parent bool) []Match {
+	matches := make([]Match, len(iocs))
+	for i, ioc := range iocs {
+		matches[i] = Match{IOC: ioc, Parent: parent}
+	}
+	return matches
+}
@@ -0,0 +1,68 @@
+package lookalike
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables 与拉丁字母或数字形近的字符，映射到用于比较的规范形式
+// 易混淆的 i、l、1 统一映射为 l，0 映射为 o；带附加符号的字母先由 NFD 分解去掉符号
+var confusables = map[rune]string{
+	// ASCII
+	'0': "o", '1': "l", 'i': "l",
+	// 拉丁字母扩展
+	'ı': "l", 'ɩ': "l", 'ł': "l", 'ɑ': "a", 'ø': "o", 'đ': "d", 'ħ': "h", 'ɡ': "g", 'ɢ': "g", 'ß': "ss",
+	// 西里尔字母
+	'а': "a", 'в': "b", 'е': "e", 'о': "o", 'р': "p", 'с': "c", 'у': "y", 'х': "x", 'к': "k",
+	'м': "m", 'н': "h", 'т': "t", 'ѕ': "s", 'і': "l", 'ј': "j", 'ԁ': "d", 'һ': "h", 'ӏ': "l",
+	'ԛ': "q", 'ԝ': "w", 'ь': "b", 'ү': "y",
+	// 希腊字母
+	'α': "a", 'β': "b", 'ε': "e", 'η': "n", 'ι': "l", 'κ': "k", 'ν': "v", 'ο': "o",
+	'ρ': "p", 'τ': "t", 'υ': "u", 'χ': "x", 'ω': "w",
+}
+
+// sequences 形近的 ASCII 字符组合，如 rnicrosoft 与 microsoft
+var sequences = strings.NewReplacer("rn", "m", "vv", "w", "cl", "d")
+
+// skeleton 返回用于形近比较的规范形式，形近的名称规范形式相同
+func skeleton(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(s)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if m, ok := confusables[r]; ok {
+			b.WriteString(m)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return sequences.Replace(b.String())
+}
+
+// decode 将国际化域名的 xn-- 标签解码为 Unicode，无法解码的标签保持原样
+func decode(name string) string {
+	if !strings.Contains(name, "xn--") {
+		return name
+	}
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if strings.HasPrefix(label, "xn--") {
+			if u, err := idna.Punycode.ToUnicode(label); err == nil {
+				labels[i] = u
+			}
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// encode 将包含 Unicode 字符的域名转换为 xn-- 形式，无法转换时保持原样
+func encode(name string) string {
+	if ascii, err := idna.Punycode.ToASCII(name); err == nil {
+		return ascii
+	}
+	return name
+}
@@ -0,0 +1,154 @@
+package lookalike
+
+import (
+	"dnsflux/internal/detect/psl"
+	"dnsflux/internal/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCached 缓存比较结果的可注册域名上限，超过时清空
+const maxCached = 100000
+
+// maxAlerted 保存告警时间的条目上限，超过时清理已过告警间隔的条目
+const maxAlerted = 100000
+
+// Detector 将查询的可注册域名与品牌比较，可并发使用
+type Detector struct {
+	config      Config
+	brands      []*brand
+	own         map[string]bool // 品牌域名与允许的域名
+	keywords    map[string]bool
+	maxDistance int
+	minLength   int
+	cooldown    time.Duration
+
+	mu      sync.Mutex
+	cached  map[string]*Finding // 可注册域名的比较结果，nil 表示未命中
+	alerted map[string]time.Time
+}
+
+// New 创建仿冒域名检测器，无法确定可注册域名的品牌被忽略
+func New(cfg Config) *Detector {
+	d := &Detector{
+		config:      cfg,
+		own:         make(map[string]bool),
+		keywords:    make(map[string]bool),
+		maxDistance: cfg.MaxDistance,
+		minLength:   cfg.MinLength,
+		cooldown:    cfg.Cooldown.Std(),
+		cached:      make(map[string]*Finding),
+		alerted:     make(map[string]time.Time),
+	}
+	if d.maxDistance <= 0 {
+		d.maxDistance = DefaultMaxDistance
+	}
+	if d.minLength <= 0 {
+		d.minLength = DefaultMinLength
+	}
+	for _, name := range cfg.Brands {
+		domain, ok := Registrable(name)
+		if !ok || d.own[domain] {
+			continue
+		}
+		d.own[domain] = true
+		d.brands = append(d.brands, newBrand(domain))
+	}
+	for _, name := range cfg.Allow {
+		d.own[encode(psl.Normalize(name))] = true
+	}
+	keywords := cfg.Keywords
+	if len(keywords) == 0 {
+		keywords = DefaultKeywords
+	}
+	for _, word := range keywords {
+		d.keywords[strings.ToLower(strings.TrimSpace(word))] = true
+	}
+	return d
+}
+
+// Registrable 返回域名规范化后的可注册域名，国际化域名转换为 xn-- 形式；
+// 域名不是可注册域名本身（如带有子域名或只是公共后缀）时返回 false
+func Registrable(name string) (string, bool) {
+	name = encode(psl.Normalize(name))
+	sub, domain, ok := psl.Split(name)
+	if !ok || sub != "" {
+		return "", false
+	}
+	return domain, true
+}
+
+// Observe 比较一次查询，域名疑似仿冒品牌且不在告警间隔内时返回 true
+func (d *Detector) Observe(record *model.DNSRecord) (Finding, bool) {
+	name := encode(psl.Normalize(record.QueryName))
+	sub, domain, ok := psl.Split(name)
+	if !ok || d.own[domain] {
+		return Finding{}, false
+	}
+	at := record.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f := d.lookup(domain)
+	if f == nil {
+		for _, b := range d.brands {
+			if subdomain(sub, b) {
+				f = &Finding{Technique: TechniqueSubdomain, Brand: b.domain, Domain: domain}
+				break
+			}
+		}
+	}
+	if f == nil || !d.alert(domain, at) {
+		return Finding{}, false
+	}
+	finding := *f
+	finding.Name = name
+	if unicode := decode(name); unicode != name {
+		finding.Unicode = unicode
+	}
+	return finding, true
+}
+
+// lookup 返回可注册域名与全部品牌比较的结果，取优先级最高的手法，相同手法取编辑距离最小的品牌
+func (d *Detector) lookup(domain string) *Finding {
+	if f, ok := d.cached[domain]; ok {
+		return f
+	}
+	c := newCandidate(domain)
+	var best *Finding
+	for _, b := range d.brands {
+		technique, n, ok := d.compare(c, b)
+		if !ok {
+			continue
+		}
+		if best == nil || rank(technique) < rank(best.Technique) ||
+			technique == best.Technique && n < best.Distance {
+			best = &Finding{Technique: technique, Brand: b.domain, Domain: domain, Distance: n}
+		}
+	}
+	if len(d.cached) >= maxCached {
+		clear(d.cached)
+	}
+	d.cached[domain] = best
+	return best
+}
+
+// alert 判断该域名是否不在告警间隔内，是则记录告警时间
+func (d *Detector) alert(domain string, at time.Time) bool {
+	if last, ok := d.alerted[domain]; ok && at.Sub(last) < d.cooldown {
+		return false
+	}
+	if len(d.alerted) >= maxAlerted {
+		for k, last := range d.alerted {
+			if at.Sub(last) >= d.cooldown {
+				delete(d.alerted, k)
+			}
+		}
+	}
+	d.alerted[domain] = at
+	return true
+}
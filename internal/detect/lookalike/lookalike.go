@@ -0,0 +1,70 @@
+// Package lookalike 将查询的可注册域名与受保护的品牌及自有域名比较，发现仿冒域名
+// 检查拼写变形（Damerau-Levenshtein 距离）、形近字符与国际化域名（解码 xn-- 标签）、比特翻转、
+// 更换公共后缀、添加连字符或关键字，以及把品牌域名放在其他域名的子域名中
+package lookalike
+
+import (
+	"dnsflux/internal/output"
+	"time"
+)
+
+// 仿冒手法，按判定的优先级排列
+const (
+	TechniqueTLDSwap     = "tld-swap"     // 相同名称、不同公共后缀，如 paypal.co
+	TechniqueHomoglyph   = "homoglyph"    // 形近字符或国际化域名，如 paypa1.com、pаypal.com（西里尔字母 а）
+	TechniqueBitsquat    = "bitsquatting" // 一个字符的一位被翻转，如 paypar.com
+	TechniqueHyphenation = "hyphenation"  // 添加连字符，如 pay-pal.com
+	TechniqueKeyword     = "keyword"      // 添加关键字，如 paypal-login.com
+	TechniqueTypo        = "typo"         // 拼写变形：遗漏、重复、替换、插入或交换相邻字符
+	TechniqueSubdomain   = "subdomain"    // 品牌域名出现在其他域名的子域名中，如 paypal.com.account-verify.net
+)
+
+// Techniques 全部仿冒手法
+var Techniques = []string{
+	TechniqueTLDSwap, TechniqueHomoglyph, TechniqueBitsquat, TechniqueHyphenation,
+	TechniqueKeyword, TechniqueTypo, TechniqueSubdomain,
+}
+
+// 默认配置
+const (
+	DefaultMaxDistance = 1
+	DefaultMinLength   = 4
+	DefaultCooldown    = time.Hour
+)
+
+// DefaultKeywords 默认与品牌名组合的关键字
+var DefaultKeywords = []string{
+	"account", "app", "auth", "billing", "help", "login", "mail", "my", "online", "pay",
+	"payment", "portal", "secure", "security", "service", "signin", "sso", "support",
+	"update", "verify", "wallet", "web",
+}
+
+// Config 仿冒域名检测配置
+type Config struct {
+	Enabled     bool            `yaml:"enabled"`
+	Brands      []string        `yaml:"brands"`      // 受保护的品牌与自有域名（可注册域名），如 paypal.com、example.co.uk
+	Keywords    []string        `yaml:"keywords"`    // 与品牌名组合的关键字，为空时使用默认列表
+	MaxDistance int             `yaml:"maxDistance"` // 拼写变形的最大编辑距离，名称短于 8 个字符时固定为 1
+	MinLength   int             `yaml:"minLength"`   // 名称短于该长度的品牌不检查拼写变形与关键字，避免短名称的大量误报
+	Allow       []string        `yaml:"allow"`       // 不告警的可注册域名，如品牌自己注册的防御性域名
+	Cooldown    output.Duration `yaml:"cooldown"`    // 同一域名两次告警的最小间隔
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		MaxDistance: DefaultMaxDistance,
+		MinLength:   DefaultMinLength,
+		Cooldown:    output.Duration(DefaultCooldown),
+	}
+}
+
+// Finding 一个疑似仿冒的域名
+type Finding struct {
+	Technique string
+	Brand     string // 被仿冒的品牌域名
+	Domain    string // 查询的可注册域名
+	Name      string // 查询的完整域名
+	Unicode   string // 国际化域名解码后的完整域名，非国际化域名时为空
+	Distance  int    // 拼写变形的编辑距离
+}
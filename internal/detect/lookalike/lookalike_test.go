@@ -0,0 +1,156 @@
+package lookalike
+
+import (
+	"dnsflux/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+// testDetector 返回保护 paypal.com、microsoft.com、example.co.uk 与短名称 ibm.com 的检测器
+func testDetector() *Detector {
+	cfg := DefaultConfig()
+	cfg.Brands = []string{"PayPal.com.", "microsoft.com", "example.co.uk", "ibm.com", "www.invalid.com"}
+	cfg.Allow = []string{"paypal-help.com"}
+	cfg.MaxDistance = 2
+	return New(cfg)
+}
+
+func TestObserve(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		technique string
+		brand     string
+		domain    string
+		distance  int
+	}{
+		{"更换公共后缀", "www.paypal.co", TechniqueTLDSwap, "paypal.com", "paypal.co", 0},
+		{"更换多级公共后缀", "example.com", TechniqueTLDSwap, "example.co.uk", "example.com", 0},
+		{"形近数字", "paypa1.com", TechniqueHomoglyph, "paypal.com", "paypa1.com", 0},
+		{"形近字母组合", "rnicrosoft.com", TechniqueHomoglyph, "microsoft.com", "rnicrosoft.com", 0},
+		{"短名称的形近字符", "lbm.com", TechniqueHomoglyph, "ibm.com", "lbm.com", 0},
+		{"比特翻转", "paypam.com", TechniqueBitsquat, "paypal.com", "paypam.com", 0},
+		{"添加连字符", "pay-pal.com", TechniqueHyphenation, "paypal.com", "pay-pal.com", 0},
+		{"后缀关键字", "paypal-login.com", TechniqueKeyword, "paypal.com", "paypal-login.com", 0},
+		{"前缀关键字", "securepaypal.com", TechniqueKeyword, "paypal.com", "securepaypal.com", 0},
+		{"前后缀关键字", "my-paypal-support.com", TechniqueKeyword, "paypal.com", "my-paypal-support.com", 0},
+		{"插入字符", "paypall.com", TechniqueTypo, "paypal.com", "paypall.com", 1},
+		{"交换相邻字符", "micorsoft.com", TechniqueTypo, "microsoft.com", "micorsoft.com", 1},
+		{"长名称允许更大的编辑距离", "micrsft.com", TechniqueTypo, "microsoft.com", "micrsft.com", 2},
+		{"品牌域名在子域名中", "paypal.com.account-verify.net", TechniqueSubdomain, "paypal.com", "account-verify.net", 0},
+		{"连字符分隔的品牌域名", "www-paypal-com.evil.net", TechniqueSubdomain, "paypal.com", "evil.net", 0},
+		{"短名称只允许 1 处差异", "pypl.com", "", "", "", 0},
+		{"不是关键字的组合", "paypal-evil.com", "", "", "", 0},
+		{"短名称不检查拼写变形", "ibn.com", "", "", "", 0},
+		{"品牌域名本身", "www.paypal.com", "", "", "", 0},
+		{"允许的域名", "paypal-help.com", "", "", "", 0},
+		{"不相关的域名", "github.com", "", "", "", 0},
+	}
+	d := testDetector()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := d.Observe(&model.DNSRecord{Timestamp: start, QueryName: tt.query})
+			if ok != (tt.technique != "") {
+				t.Fatalf("Observe(%s) = %+v, %v", tt.query, f, ok)
+			}
+			if !ok {
+				return
+			}
+			if f.Technique != tt.technique || f.Brand != tt.brand || f.Domain != tt.domain || f.Distance != tt.distance {
+				t.Errorf("Observe(%s) = %+v", tt.query, f)
+			}
+			if f.Name != strings.ToLower(tt.query) || f.Unicode != "" {
+				t.Errorf("完整域名 = %q, 解码 = %q", f.Name, f.Unicode)
+			}
+		})
+	}
+}
+
+func TestObserveIDN(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Unicode 形式", "login.pаypal.com"},
+		{"xn-- 形式", "login." + encode("pаypal.com")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 西里尔字母 а 与拉丁字母 a 形近
+			f, ok := testDetector().Observe(&model.DNSRecord{Timestamp: start, QueryName: tt.query})
+			if !ok || f.Technique != TechniqueHomoglyph || f.Brand != "paypal.com" {
+				t.Fatalf("Observe(%s) = %+v, %v", tt.query, f, ok)
+			}
+			if !strings.HasPrefix(f.Domain, "xn--") || f.Name != "login."+f.Domain || f.Unicode != "login.pаypal.com" {
+				t.Errorf("域名 = %q, 完整域名 = %q, 解码 = %q", f.Domain, f.Name, f.Unicode)
+			}
+		})
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	d := testDetector()
+	observe := func(name string, offset time.Duration) bool {
+		_, ok := d.Observe(&model.DNSRecord{Timestamp: start.Add(offset), QueryName: name})
+		return ok
+	}
+	if !observe("paypa1.com", 0) {
+		t.Fatal("首次查询没有告警")
+	}
+	// 同一可注册域名的其他子域名也在告警间隔内
+	if observe("www.paypa1.com", time.Minute) {
+		t.Error("告警间隔内重复告警")
+	}
+	if !observe("paypa1.com", DefaultCooldown) {
+		t.Error("告警间隔过后没有再次告警")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{"相同", "paypal", "paypal", 0},
+		{"替换", "paypal", "paypel", 1},
+		{"遗漏", "paypal", "paypl", 1},
+		{"交换相邻字符", "paypal", "papyal", 1},
+		{"两处差异", "microsoft", "micrsft", 2},
+		{"长度差超过上限", "microsoft", "ms", 2},
+		{"按字符而非字节计算", "pаypal", "paypal", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := distance(tt.a, tt.b, 1); got != tt.want {
+				t.Errorf("distance(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistrable(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		ok   bool
+	}{
+		{"规范化", "PayPal.COM.", "paypal.com", true},
+		{"多级公共后缀", "example.co.uk", "example.co.uk", true},
+		{"国际化域名", "bücher.de", encode("bücher.de"), true},
+		{"带有子域名", "www.paypal.com", "", false},
+		{"公共后缀", "co.uk", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Registrable(tt.in)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Registrable(%s) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
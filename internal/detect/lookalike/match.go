@@ -0,0 +1,163 @@
+package lookalike
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// longLabel 名称达到该长度的品牌按配置的最大编辑距离检查拼写变形，较短的名称只允许 1 处差异
+const longLabel = 8
+
+// brand 受保护的品牌域名
+type brand struct {
+	domain   string // 可注册域名，国际化域名为 xn-- 形式
+	label    string // 公共后缀前的标签，国际化域名已解码
+	skeleton string
+	length   int // label 的字符数
+}
+
+// candidate 待比较的可注册域名
+type candidate struct {
+	label    string // 公共后缀前的标签，国际化域名已解码
+	skeleton string
+	ascii    bool // 标签只包含 ASCII 字符
+}
+
+// compare 按优先级依次检查各仿冒手法，返回命中的手法与拼写变形的编辑距离
+func (d *Detector) compare(c candidate, b *brand) (string, int, bool) {
+	if c.label == b.label {
+		return TechniqueTLDSwap, 0, true
+	}
+	if c.skeleton == b.skeleton {
+		return TechniqueHomoglyph, 0, true
+	}
+	if c.ascii && b.length >= d.minLength && bitsquat(c.label, b.label) {
+		return TechniqueBitsquat, 0, true
+	}
+	if strings.Contains(c.label, "-") && strings.ReplaceAll(c.label, "-", "") == b.label {
+		return TechniqueHyphenation, 0, true
+	}
+	if b.length < d.minLength {
+		return "", 0, false
+	}
+	if d.keyword(c.label, b.label) {
+		return TechniqueKeyword, 0, true
+	}
+	limit := 1
+	if b.length >= longLabel {
+		limit = d.maxDistance
+	}
+	if n := distance(c.label, b.label, limit); n > 0 && n <= limit {
+		return TechniqueTypo, n, true
+	}
+	return "", 0, false
+}
+
+// keyword 判断标签是否由品牌名与关键字组成，如 paypal-login、securepaypal、my-paypal-support
+func (d *Detector) keyword(label, brand string) bool {
+	i := strings.Index(label, brand)
+	if i < 0 || label == brand {
+		return false
+	}
+	for _, part := range []string{label[:i], label[i+len(brand):]} {
+		for _, word := range strings.Split(part, "-") {
+			if word != "" && !d.keywords[word] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// bitsquat 判断两个等长标签是否只有一个字符不同，且该字符只相差一位，翻转后仍是合法的域名字符
+func bitsquat(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	diff := -1
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			if diff >= 0 {
+				return false
+			}
+			diff = i
+		}
+	}
+	if diff < 0 {
+		return false
+	}
+	x := a[diff] ^ b[diff]
+	return x&(x-1) == 0 && hostChar(a[diff])
+}
+
+// hostChar 判断是否为域名标签中可用的字符
+func hostChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-'
+}
+
+// distance 返回两个字符串的 Damerau-Levenshtein 距离（相邻字符交换计为 1 处差异），
+// 长度差已超过 limit 时直接返回 limit+1
+func distance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if n := len(ra) - len(rb); n > limit || -n > limit {
+		return limit + 1
+	}
+	// 只保留最近三行的动态规划表
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// subdomain 判断子域名部分是否包含品牌域名，连字符视同点号，如 paypal.com.verify、www-paypal-com
+func subdomain(sub string, b *brand) bool {
+	if sub == "" {
+		return false
+	}
+	return strings.Contains("."+strings.ReplaceAll(sub, "-", ".")+".", "."+b.domain+".")
+}
+
+// rank 返回仿冒手法的优先级，越小越优先
+func rank(technique string) int {
+	return slices.Index(Techniques, technique)
+}
+
+// newCandidate 由可注册域名构造待比较的标签
+func newCandidate(domain string) candidate {
+	label, _, _ := strings.Cut(decode(domain), ".")
+	return candidate{label: label, skeleton: skeleton(label), ascii: isASCII(label)}
+}
+
+// newBrand 由已规范化的可注册域名构造品牌
+func newBrand(domain string) *brand {
+	label, _, _ := strings.Cut(decode(domain), ".")
+	return &brand{domain: domain, label: label, skeleton: skeleton(label), length: utf8.RuneCountInString(label)}
+}
+
+// isASCII 判断字符串是否只包含 ASCII 字符
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
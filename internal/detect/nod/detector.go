@@ -0,0 +1,291 @@
+package nod
+
+import (
+	"context"
+	"dnsflux/internal/detect/psl"
+	"dnsflux/internal/model"
+	"dnsflux/pkg/logger"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// log 新域名检测日志
+var log = logger.Component("detect").With("detector", "nod")
+
+// sweepInterval 清理过期条目的间隔
+const sweepInterval = time.Hour
+
+// Detector 保存域名的首次与最近解析时间，可并发使用
+type Detector struct {
+	config   Config
+	learning time.Duration
+	expiry   time.Duration
+	allow    []string
+
+	mu        sync.Mutex
+	created   time.Time // 学习期起点，首次记录时设置
+	latest    time.Time // 最近一条记录的时间
+	domains   map[string]*entry
+	names     map[string]*entry
+	processes map[string]*entry // 键为 进程名|可注册域名
+	recent    []Observation
+	dirty     bool
+	lastSweep time.Time
+
+	saveMu sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// entry 首次与最近解析时间
+type entry struct {
+	first time.Time
+	last  time.Time
+}
+
+// New 创建新域名检测器，配置了数据库文件且文件存在时从中加载，文件无法读取或解析时返回错误
+func New(cfg Config) (*Detector, error) {
+	d := &Detector{
+		config:    cfg,
+		learning:  cfg.Learning.Std(),
+		expiry:    cfg.Expiry.Std(),
+		domains:   make(map[string]*entry),
+		names:     make(map[string]*entry),
+		processes: make(map[string]*entry),
+		done:      make(chan struct{}),
+	}
+	for _, domain := range cfg.Allow {
+		d.allow = append(d.allow, psl.Normalize(domain))
+	}
+	if cfg.Path != "" {
+		loaded, err := d.load()
+		if err != nil {
+			return nil, err
+		}
+		if loaded {
+			log.Info(fmt.Sprintf("已加载新域名数据库 %s: %d 个可注册域名，%d 个完整域名",
+				cfg.Path, len(d.domains), len(d.names)))
+		}
+	}
+
+	if cfg.Path == "" || cfg.SaveInterval <= 0 {
+		close(d.done)
+		d.cancel = func() {}
+		return d, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	go d.run(ctx, cfg.SaveInterval.Std())
+	return d, nil
+}
+
+// Observe 记录一次查询，域名在学习期之后首次出现时返回 true
+func (d *Detector) Observe(record *model.DNSRecord) (Observation, bool) {
+	name := psl.Normalize(record.QueryName)
+	_, domain, ok := psl.Split(name)
+	if !ok || d.allowed(name) {
+		return Observation{}, false
+	}
+	at := record.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.created.IsZero() {
+		d.created = at
+	}
+	if at.After(d.latest) {
+		d.latest = at
+	}
+	d.sweep(at)
+	d.dirty = true
+
+	newDomain := touch(d.domains, domain, at)
+	newName := newDomain
+	if name != domain {
+		newName = touch(d.names, name, at)
+	}
+	newProcess := false
+	process := record.ProcessName
+	if process == "" {
+		process = record.ClientIP
+	}
+	if d.config.PerProcess && process != "" {
+		newProcess = touch(d.processes, process+"|"+domain, at)
+	}
+
+	if at.Sub(d.created) < d.learning {
+		return Observation{}, false
+	}
+	var scope string
+	switch {
+	case newDomain:
+		scope = ScopeDomain
+	case newName:
+		scope = ScopeName
+	case newProcess:
+		scope = ScopeProcess
+	default:
+		return Observation{}, false
+	}
+
+	o := Observation{
+		Time:        at,
+		Scope:       scope,
+		Name:        name,
+		Domain:      domain,
+		Process:     record.ProcessLabel(),
+		ProcessName: record.ProcessName,
+		QueryType:   record.QueryType,
+	}
+	d.recent = append(d.recent, o)
+	d.trimRecent(at)
+	return o, true
+}
+
+// Recent 返回 since 之后记录的新域名，最新的在前，scope 非空时只返回该范围的记录
+func (d *Detector) Recent(since time.Time, scope string) []Observation {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	observations := []Observation{}
+	for i := len(d.recent) - 1; i >= 0; i-- {
+		o := d.recent[i]
+		if o.Time.Before(since) {
+			break
+		}
+		if scope == "" || o.Scope == scope {
+			observations = append(observations, o)
+		}
+	}
+	return observations
+}
+
+// Status 返回数据库状态
+func (d *Detector) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := Status{
+		Path:      d.config.Path,
+		Created:   d.created,
+		Learning:  d.created.IsZero() || d.latest.Sub(d.created) < d.learning,
+		Domains:   len(d.domains),
+		Names:     len(d.names),
+		Processes: len(d.processes),
+	}
+	if !d.created.IsZero() {
+		s.LearningUntil = d.created.Add(d.learning)
+	}
+	return s
+}
+
+// Close 停止定期写入，配置了数据库文件时写入最新状态
+func (d *Detector) Close() {
+	d.cancel()
+	<-d.done
+	if d.config.Path == "" {
+		return
+	}
+	if err := d.Save(); err != nil {
+		log.Warn(fmt.Sprintf("写入新域名数据库失败: %v", err))
+	}
+}
+
+// run 定期将有变化的状态写入数据库文件
+func (d *Detector) run(ctx context.Context, interval time.Duration) {
+	defer close(d.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := d.Save(); err != nil {
+			log.Warn(fmt.Sprintf("写入新域名数据库失败: %v", err))
+		}
+	}
+}
+
+// allowed 判断域名是否在白名单中，白名单条目同时匹配其子域名
+func (d *Detector) allowed(name string) bool {
+	for _, allow := range d.allow {
+		if name == allow || strings.HasSuffix(name, "."+allow) {
+			return true
+		}
+	}
+	return false
+}
+
+// touch 更新条目的最近解析时间，条目不存在时创建并返回 true
+func touch(entries map[string]*entry, key string, at time.Time) bool {
+	e := entries[key]
+	if e == nil {
+		entries[key] = &entry{first: at, last: at}
+		return true
+	}
+	if at.After(e.last) {
+		e.last = at
+	}
+	if at.Before(e.first) {
+		e.first = at
+	}
+	return false
+}
+
+// trimRecent 丢弃超出保留时间或数量上限的新域名记录
+func (d *Detector) trimRecent(now time.Time) {
+	cutoff := now.Add(-RecentWindow)
+	n := 0
+	for n < len(d.recent) && (d.recent[n].Time.Before(cutoff) || len(d.recent)-n > maxRecent) {
+		n++
+	}
+	if n > 0 {
+		d.recent = append(d.recent[:0], d.recent[n:]...)
+	}
+}
+
+// sweep 每小时删除一次过期的条目，条目总数超过上限时删除最久未解析的条目，直到降到上限的 90%
+func (d *Detector) sweep(now time.Time) {
+	total := len(d.domains) + len(d.names) + len(d.processes)
+	if now.Sub(d.lastSweep) < sweepInterval && total <= d.config.MaxEntries {
+		return
+	}
+	d.lastSweep = now
+	d.trimRecent(now)
+	if d.expiry > 0 {
+		for _, entries := range []map[string]*entry{d.domains, d.names, d.processes} {
+			for key, e := range entries {
+				if now.Sub(e.last) > d.expiry {
+					delete(entries, key)
+				}
+			}
+		}
+	}
+
+	total = len(d.domains) + len(d.names) + len(d.processes)
+	if total <= d.config.MaxEntries {
+		return
+	}
+	type ref struct {
+		entries map[string]*entry
+		key     string
+		last    time.Time
+	}
+	refs := make([]ref, 0, total)
+	for _, entries := range []map[string]*entry{d.domains, d.names, d.processes} {
+		for key, e := range entries {
+			refs = append(refs, ref{entries, key, e.last})
+		}
+	}
+	slices.SortFunc(refs, func(a, b ref) int { return a.last.Compare(b.last) })
+	for _, r := range refs[:total-d.config.MaxEntries*9/10] {
+		delete(r.entries, r.key)
+	}
+}
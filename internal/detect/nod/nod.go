@@ -0,0 +1,82 @@
+// Package nod 记录每个域名首次被解析的时间，标记新出现的域名（Newly Observed Domain）
+// 主机从未解析过的域名是可疑活动的重要信号。检测器按完整域名、可注册域名以及（可选）进程与可注册域名的组合
+// 保存首次与最近解析时间并定期写入磁盘，重启后继续沿用。数据库创建后的学习期内只记录不标记，
+// 长时间没有解析的条目过期删除，再次解析时重新视为新域名
+package nod
+
+import (
+	"dnsflux/internal/output"
+	"time"
+)
+
+// 默认配置
+const (
+	DefaultPath         = "data/firstseen.json.gz"
+	DefaultLearning     = 7 * 24 * time.Hour
+	DefaultExpiry       = 90 * 24 * time.Hour
+	DefaultSaveInterval = 5 * time.Minute
+	DefaultMaxEntries   = 1000000
+)
+
+// 新域名的范围，一条记录同时满足多个范围时取最大的范围
+const (
+	ScopeDomain  = "domain"  // 可注册域名首次出现
+	ScopeName    = "name"    // 已知可注册域名下的完整域名首次出现
+	ScopeProcess = "process" // 进程首次解析该可注册域名
+)
+
+// Scopes 全部新域名范围，按从大到小排列
+var Scopes = []string{ScopeDomain, ScopeName, ScopeProcess}
+
+// RecentWindow 保留新域名记录的时间，Web 页面与 /api/nod 列出这段时间内的新域名
+const RecentWindow = 24 * time.Hour
+
+// maxRecent 保留的新域名记录上限，超过时丢弃最早的记录
+const maxRecent = 10000
+
+// Config 新域名检测配置
+type Config struct {
+	Enabled      bool            `yaml:"enabled"`
+	Path         string          `yaml:"path"`         // 数据库文件，为空时只保存在内存中，重启后丢失
+	Learning     output.Duration `yaml:"learning"`     // 数据库创建后的学习期，期间只记录不标记，0 表示不学习
+	Expiry       output.Duration `yaml:"expiry"`       // 超过该时间没有解析的条目被删除，0 表示不过期
+	PerProcess   bool            `yaml:"perProcess"`   // 同时按进程名记录可注册域名，标记进程首次解析的域名
+	Alert        bool            `yaml:"alert"`        // 可注册域名首次出现时产生告警
+	SaveInterval output.Duration `yaml:"saveInterval"` // 写入数据库文件的间隔，退出时也会写入
+	MaxEntries   int             `yaml:"maxEntries"`   // 条目总数上限，超过时删除最久未解析的条目
+	Allow        []string        `yaml:"allow"`        // 不记录的域名，同时匹配其子域名
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Enabled:      true,
+		Path:         DefaultPath,
+		Learning:     output.Duration(DefaultLearning),
+		Expiry:       output.Duration(DefaultExpiry),
+		SaveInterval: output.Duration(DefaultSaveInterval),
+		MaxEntries:   DefaultMaxEntries,
+	}
+}
+
+// Observation 一次新域名记录
+type Observation struct {
+	Time        time.Time `json:"time"`
+	Scope       string    `json:"scope"`  // domain、name 或 process
+	Name        string    `json:"name"`   // 查询的完整域名
+	Domain      string    `json:"domain"` // 可注册域名
+	Process     string    `json:"process"`
+	ProcessName string    `json:"processName,omitempty"`
+	QueryType   string    `json:"queryType"`
+}
+
+// Status 数据库状态
+type Status struct {
+	Path          string    `json:"path,omitempty"`
+	Created       time.Time `json:"created"`       // 数据库创建时间，即学习期起点
+	Learning      bool      `json:"learning"`      // 是否仍在学习期内
+	LearningUntil time.Time `json:"learningUntil"` // 学习期结束时间
+	Domains       int       `json:"domains"`       // 已知的可注册域名数
+	Names         int       `json:"names"`         // 已知的完整域名数
+	Processes     int       `json:"processes"`     // 已知的进程与可注册域名组合数
+}
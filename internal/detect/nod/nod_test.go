@@ -0,0 +1,211 @@
+package nod
+
+import (
+	"compress/gzip"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+// query 返回进程在 start 之后 offset 时查询 name 的记录
+func query(name, process string, offset time.Duration) *model.DNSRecord {
+	return &model.DNSRecord{Timestamp: start.Add(offset), QueryName: name, QueryType: "A", ProcessName: process, ProcessID: 1}
+}
+
+// testConfig 返回学习期 1 小时、只保存在内存中的配置
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Path = ""
+	cfg.Learning = output.Duration(time.Hour)
+	cfg.PerProcess = true
+	return cfg
+}
+
+func TestObserve(t *testing.T) {
+	cfg := testConfig()
+	cfg.Allow = []string{"corp.example.com"}
+	d, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// 学习期内只记录
+	for _, name := range []string{"www.known.com", "known.org"} {
+		if _, ok := d.Observe(query(name, "chrome", 0)); ok {
+			t.Errorf("学习期内标记了 %s", name)
+		}
+	}
+	if s := d.Status(); !s.Learning || s.Domains != 2 || s.Names != 1 || s.Processes != 2 {
+		t.Errorf("学习期状态 = %+v", s)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		process   string
+		wantScope string
+	}{
+		{"已知域名", "WWW.Known.com.", "chrome", ""},
+		{"新的可注册域名", "cdn.new.com", "chrome", ScopeDomain},
+		{"再次解析不再标记", "cdn.new.com", "chrome", ""},
+		{"已知可注册域名下的新完整域名", "mail.known.com", "chrome", ScopeName},
+		{"进程首次解析已知域名", "known.org", "curl", ScopeProcess},
+		{"白名单的子域名", "host.corp.example.com", "chrome", ""},
+		{"无法确定可注册域名", "com", "chrome", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, ok := d.Observe(query(tt.query, tt.process, 2*time.Hour))
+			if ok != (tt.wantScope != "") || o.Scope != tt.wantScope {
+				t.Errorf("Observe(%s) = %q, %v, want %q", tt.query, o.Scope, ok, tt.wantScope)
+			}
+		})
+	}
+
+	recent := d.Recent(start, "")
+	if len(recent) != 3 || recent[0].Scope != ScopeProcess || recent[2].Name != "cdn.new.com" || recent[2].Domain != "new.com" {
+		t.Errorf("Recent() = %+v", recent)
+	}
+	if got := d.Recent(start, ScopeName); len(got) != 1 || got[0].Name != "mail.known.com" {
+		t.Errorf("Recent(name) = %+v", got)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	cfg := testConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "data", "firstseen.json.gz")
+	cfg.SaveInterval = 0
+	cfg.Expiry = output.Duration(24 * time.Hour)
+
+	d, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Observe(query("old.com", "chrome", 0))
+	d.Observe(query("www.known.com", "chrome", 0))
+	d.Observe(query("new.com", "chrome", 2*time.Hour))
+	d.Observe(query("www.known.com", "chrome", 20*time.Hour))
+	before := d.Status()
+	// 退出时写入数据库文件
+	d.Close()
+
+	d, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// 重启后沿用创建时间与学习期，不重新学习
+	after := d.Status()
+	if !after.Created.Equal(start) || after.Learning || after != before {
+		t.Errorf("重启后状态 = %+v, want %+v", after, before)
+	}
+	if got := d.Recent(start, ""); len(got) != 1 || got[0].Name != "new.com" {
+		t.Errorf("重启后的新域名记录 = %+v", got)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantScope string
+	}{
+		{"重启前解析过的域名", "www.known.com", ""},
+		{"重启前标记过的域名", "new.com", ""},
+		{"超过过期时间的条目重新视为新域名", "old.com", ScopeDomain},
+		{"从未解析过的域名", "other.com", ScopeDomain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, ok := d.Observe(query(tt.query, "chrome", 26*time.Hour))
+			if ok != (tt.wantScope != "") || o.Scope != tt.wantScope {
+				t.Errorf("Observe(%s) = %q, %v, want %q", tt.query, o.Scope, ok, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestPeriodicSave(t *testing.T) {
+	cfg := testConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "firstseen.json.gz")
+	cfg.SaveInterval = output.Duration(10 * time.Millisecond)
+	d, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	d.Observe(query("example.com", "chrome", 0))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(cfg.Path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("等待定期写入超时")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 状态没有变化时不重写文件
+	info, err := os.Stat(cfg.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := info.ModTime().Add(-time.Hour)
+	if err := os.Chtimes(cfg.Path, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(cfg.Path); err != nil || !info.ModTime().Equal(past) {
+		t.Error("状态没有变化时重写了数据库文件")
+	}
+	if matches, _ := filepath.Glob(cfg.Path + ".*.tmp"); len(matches) != 0 {
+		t.Errorf("遗留临时文件 %v", matches)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	gzipped := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw := gzip.NewWriter(file)
+		zw.Write([]byte(data))
+		zw.Close()
+		file.Close()
+		return path
+	}
+	plain := filepath.Join(dir, "plain.json")
+	if err := os.WriteFile(plain, []byte(`{"version":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"不是 gzip 文件", plain},
+		{"JSON 格式错误", gzipped("broken.json.gz", "{")},
+		{"不支持的版本", gzipped("v2.json.gz", `{"version":2}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Path = tt.path
+			if _, err := New(cfg); err == nil {
+				t.Error("应返回错误")
+			}
+		})
+	}
+}
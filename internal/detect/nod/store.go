@@ -0,0 +1,140 @@
+package nod
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion 数据库文件格式版本
+const snapshotVersion = 1
+
+// snapshot 数据库文件内容，gzip 压缩的 JSON
+type snapshot struct {
+	Version   int             `json:"version"`
+	Created   time.Time       `json:"created"`
+	Domains   []snapshotEntry `json:"domains"`
+	Names     []snapshotEntry `json:"names"`
+	Processes []snapshotEntry `json:"processes"`
+	Recent    []Observation   `json:"recent"`
+}
+
+// snapshotEntry 一个条目，时间为 Unix 秒
+type snapshotEntry struct {
+	Key   string `json:"k"`
+	First int64  `json:"f"`
+	Last  int64  `json:"l"`
+}
+
+// Save 将当前状态写入数据库文件，状态没有变化时不写入
+// 先写入同目录下的临时文件再重命名，写入中途退出不会损坏已有的文件
+func (d *Detector) Save() error {
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+
+	d.mu.Lock()
+	if !d.dirty {
+		d.mu.Unlock()
+		return nil
+	}
+	snap := snapshot{
+		Version:   snapshotVersion,
+		Created:   d.created,
+		Domains:   export(d.domains),
+		Names:     export(d.names),
+		Processes: export(d.processes),
+		Recent:    append([]Observation(nil), d.recent...),
+	}
+	d.dirty = false
+	d.mu.Unlock()
+
+	if err := d.write(&snap); err != nil {
+		d.mu.Lock()
+		d.dirty = true
+		d.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// write 将快照写入数据库文件
+func (d *Detector) write(snap *snapshot) error {
+	path := d.config.Path
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	zw := gzip.NewWriter(file)
+	err = json.NewEncoder(zw).Encode(snap)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// load 读取数据库文件，文件不存在时返回 false
+func (d *Detector) load() (bool, error) {
+	file, err := os.Open(d.config.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("读取新域名数据库失败: %w", err)
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return false, fmt.Errorf("读取新域名数据库 %s 失败: %w", d.config.Path, err)
+	}
+	var snap snapshot
+	if err := json.NewDecoder(zr).Decode(&snap); err != nil {
+		return false, fmt.Errorf("解析新域名数据库 %s 失败: %w", d.config.Path, err)
+	}
+	if snap.Version != snapshotVersion {
+		return false, fmt.Errorf("新域名数据库 %s 的版本 %d 不受支持", d.config.Path, snap.Version)
+	}
+
+	d.created = snap.Created
+	load := func(entries map[string]*entry, list []snapshotEntry) {
+		for _, e := range list {
+			entries[e.Key] = &entry{first: time.Unix(e.First, 0).UTC(), last: time.Unix(e.Last, 0).UTC()}
+			if last := entries[e.Key].last; last.After(d.latest) {
+				d.latest = last
+			}
+		}
+	}
+	load(d.domains, snap.Domains)
+	load(d.names, snap.Names)
+	load(d.processes, snap.Processes)
+	d.recent = snap.Recent
+	return true, nil
+}
+
+// export 将条目转换为快照格式
+func export(entries map[string]*entry) []snapshotEntry {
+	list := make([]snapshotEntry, 0, len(entries))
+	for key, e := range entries {
+		list = append(list, snapshotEntry{Key: key, First: e.first.Unix(), Last: e.last.Unix()})
+	}
+	return list
+}
@@ -0,0 +1,58 @@
+// Package psl 按公共后缀列表拆分域名，供各检测器按可注册域名归类查询
+package psl
+
+import (
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Normalize 转为小写并去掉首尾空白与末尾的点
+func Normalize(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// Split 将已规范化的域名拆分为子域名部分与可注册域名，如 a.b.example.co.uk 拆分为 a.b 与 example.co.uk
+// 只使用 ICANN 公共后缀：云服务商登记的私有后缀（如 cloudfront.net）下的主机名由服务商分配，归入服务商域名
+// 单标签名称、公共后缀本身与反向解析（.arpa）返回 false
+func Split(name string) (subdomain, domain string, ok bool) {
+	if name == "" || name == "arpa" || strings.HasSuffix(name, ".arpa") {
+		return "", "", false
+	}
+	suffix, _ := icannSuffix(name)
+	if len(name) <= len(suffix)+1 {
+		return "", "", false
+	}
+	rest := name[:len(name)-len(suffix)-1]
+	i := strings.LastIndexByte(rest, '.')
+	if rest[i+1:] == "" {
+		return "", "", false
+	}
+	if i < 0 {
+		return "", name, true
+	}
+	return rest[:i], name[i+1:], true
+}
+
+// Public 判断已规范化的域名是否位于 ICANN 公共后缀下，.local、.lan、.internal 等内部使用的后缀、
+// 单标签名称与反向解析返回 false
+func Public(name string) bool {
+	if _, _, ok := Split(name); !ok {
+		return false
+	}
+	_, icann := icannSuffix(name)
+	return icann
+}
+
+// icannSuffix 返回域名的 ICANN 公共后缀，域名不在任何 ICANN 后缀下时返回最后一个标签与 false
+func icannSuffix(name string) (string, bool) {
+	suffix, icann := publicsuffix.PublicSuffix(name)
+	for !icann {
+		i := strings.IndexByte(suffix, '.')
+		if i < 0 {
+			break
+		}
+		suffix, icann = publicsuffix.PublicSuffix(suffix[i+1:])
+	}
+	return suffix, icann
+}
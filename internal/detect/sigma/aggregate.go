@@ -0,0 +1,187 @@
+package sigma
+
+import (
+	"dnsflux/internal/model"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 聚合状态的上限
+const (
+	maxGroups  = 10000 // 每条规则的分组数上限，超过时清理已过时间窗口的分组，仍超过时清空
+	maxEntries = 10000 // 每个分组时间窗口内保留的记录数上限
+)
+
+// aggregationPattern 条件中 | 之后的聚合表达式，如 count(QueryName) by Image > 50
+var aggregationPattern = regexp.MustCompile(`(?i)^(count|min|max|avg|sum)\(\s*([\w.]*)\s*\)(?:\s+by\s+([\w.]+(?:\s*,\s*[\w.]+)*))?\s*(<=|>=|<|>|==|=)\s*(\d+(?:\.\d+)?)$`)
+
+// aggregation 已编译的聚合表达式，保存各分组时间窗口内的记录
+type aggregation struct {
+	expr      string
+	function  string
+	field     fieldFunc // 为 nil 时 count() 统计记录数
+	groupBy   []fieldFunc
+	op        string
+	threshold float64
+
+	mu     sync.Mutex
+	groups map[string]*window
+}
+
+// window 一个分组时间窗口内的记录
+type window struct {
+	entries []entry
+	last    time.Time
+}
+
+// entry 时间窗口内的一条记录
+type entry struct {
+	at     time.Time
+	value  string
+	number float64
+}
+
+// parseAggregation 解析聚合表达式
+func parseAggregation(expr string) (*aggregation, error) {
+	expr = strings.TrimSpace(expr)
+	m := aggregationPattern.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("不支持的聚合表达式 %q", expr)
+	}
+	a := &aggregation{
+		expr:     expr,
+		function: strings.ToLower(m[1]),
+		op:       m[4],
+		groups:   make(map[string]*window),
+	}
+	a.threshold, _ = strconv.ParseFloat(m[5], 64)
+	if m[2] != "" {
+		f, ok := lookupField(m[2])
+		if !ok {
+			return nil, fmt.Errorf("不支持的字段 %s", m[2])
+		}
+		a.field = f.value
+	} else if a.function != "count" {
+		return nil, fmt.Errorf("%s() 需要指定字段", a.function)
+	}
+	if m[3] != "" {
+		for _, name := range strings.Split(m[3], ",") {
+			f, ok := lookupField(strings.TrimSpace(name))
+			if !ok {
+				return nil, fmt.Errorf("不支持的字段 %s", strings.TrimSpace(name))
+			}
+			a.groupBy = append(a.groupBy, f.value)
+		}
+	}
+	return a, nil
+}
+
+// observe 将满足检测条件的记录加入分组的时间窗口，聚合值满足比较条件时返回分组与聚合值并清空该分组
+func (a *aggregation) observe(r *model.DNSRecord, at time.Time, timeframe time.Duration) (string, float64, bool) {
+	e := entry{at: at}
+	if a.field != nil {
+		values := a.field(r)
+		if len(values) == 0 {
+			return "", 0, false
+		}
+		e.value = strings.Join(values, ",")
+		if a.function != "count" {
+			n, err := strconv.ParseFloat(values[0], 64)
+			if err != nil {
+				return "", 0, false
+			}
+			e.number = n
+		}
+	}
+	group := a.group(r)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	w := a.groups[group]
+	if w == nil {
+		if len(a.groups) >= maxGroups {
+			a.sweep(at, timeframe)
+		}
+		w = &window{}
+		a.groups[group] = w
+	}
+	w.last = at
+	w.entries = append(w.entries, e)
+	i := 0
+	for i < len(w.entries) && at.Sub(w.entries[i].at) > timeframe {
+		i++
+	}
+	if n := len(w.entries) - maxEntries; n > i {
+		i = n
+	}
+	if i > 0 {
+		w.entries = append(w.entries[:0], w.entries[i:]...)
+	}
+
+	value := a.value(w.entries)
+	if !compare(value, a.op, a.threshold) {
+		return "", 0, false
+	}
+	delete(a.groups, group)
+	return group, value, true
+}
+
+// group 返回记录的分组，多个分组字段以逗号分隔
+func (a *aggregation) group(r *model.DNSRecord) string {
+	if len(a.groupBy) == 0 {
+		return ""
+	}
+	parts := make([]string, len(a.groupBy))
+	for i, f := range a.groupBy {
+		parts[i] = strings.Join(f(r), ";")
+	}
+	return strings.Join(parts, ",")
+}
+
+// value 计算时间窗口内的聚合值，count(field) 统计字段不同值的个数
+func (a *aggregation) value(entries []entry) float64 {
+	switch a.function {
+	case "count":
+		if a.field == nil {
+			return float64(len(entries))
+		}
+		distinct := make(map[string]bool, len(entries))
+		for _, e := range entries {
+			distinct[e.value] = true
+		}
+		return float64(len(distinct))
+	case "min", "max":
+		result := entries[0].number
+		for _, e := range entries[1:] {
+			if a.function == "min" && e.number < result || a.function == "max" && e.number > result {
+				result = e.number
+			}
+		}
+		return result
+	default:
+		sum := 0.0
+		for _, e := range entries {
+			sum += e.number
+		}
+		if a.function == "avg" {
+			return sum / float64(len(entries))
+		}
+		return sum
+	}
+}
+
+// sweep 清理已过时间窗口的分组，仍超过上限时清空
+func (a *aggregation) sweep(at time.Time, timeframe time.Duration) {
+	for group, w := range a.groups {
+		if at.Sub(w.last) > timeframe {
+			delete(a.groups, group)
+		}
+	}
+	if len(a.groups) >= maxGroups {
+		clear(a.groups)
+	}
+}
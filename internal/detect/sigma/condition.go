@@ -0,0 +1,309 @@
+package sigma
+
+import (
+	"dnsflux/internal/model"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// detection 已编译的 detection 部分
+type detection struct {
+	searches  map[string]*search
+	condition node
+}
+
+// search 一个检测标识（如 selection），各组之间为 or，组内字段条件之间为 and
+type search struct {
+	groups [][]*fieldMatcher
+}
+
+func (s *search) eval(r *model.DNSRecord) bool {
+	for _, group := range s.groups {
+		ok := true
+		for _, m := range group {
+			if !m.match(r) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSearch 编译一个检测标识：字段映射为 and，映射列表为 or，值列表为关键字搜索
+func compileSearch(value any) (*search, error) {
+	s := &search{}
+	var keywords []any
+	items, ok := value.([]any)
+	if !ok {
+		items = []any{value}
+	}
+	for _, item := range items {
+		fields, ok := item.(map[string]any)
+		if !ok {
+			keywords = append(keywords, item)
+			continue
+		}
+		if len(fields) == 0 {
+			return nil, errors.New("字段映射为空")
+		}
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		group := make([]*fieldMatcher, 0, len(keys))
+		for _, key := range keys {
+			m, err := compileField(key, fields[key])
+			if err != nil {
+				return nil, err
+			}
+			group = append(group, m)
+		}
+		s.groups = append(s.groups, group)
+	}
+	if len(keywords) > 0 {
+		m, err := compileField("", keywords)
+		if err != nil {
+			return nil, err
+		}
+		s.groups = append(s.groups, []*fieldMatcher{m})
+	}
+	if len(s.groups) == 0 {
+		return nil, errors.New("检测标识为空")
+	}
+	return s, nil
+}
+
+// node 条件表达式的节点
+type node interface {
+	eval(r *model.DNSRecord) bool
+}
+
+type andNode []node
+
+func (n andNode) eval(r *model.DNSRecord) bool {
+	for _, c := range n {
+		if !c.eval(r) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode []node
+
+func (n orNode) eval(r *model.DNSRecord) bool {
+	for _, c := range n {
+		if c.eval(r) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct {
+	node
+}
+
+func (n notNode) eval(r *model.DNSRecord) bool {
+	return !n.node.eval(r)
+}
+
+// ofNode N of / all of 表达式，min 为 0 时要求全部匹配
+type ofNode struct {
+	min      int
+	searches []*search
+}
+
+func (n ofNode) eval(r *model.DNSRecord) bool {
+	matched := 0
+	for _, s := range n.searches {
+		if s.eval(r) {
+			matched++
+			if n.min > 0 && matched >= n.min {
+				return true
+			}
+		} else if n.min == 0 {
+			return false
+		}
+	}
+	return n.min == 0
+}
+
+// conditionParser 条件表达式的递归下降解析器，优先级从高到低为 not、and、or
+type conditionParser struct {
+	tokens   []string
+	pos      int
+	searches map[string]*search
+}
+
+// parseCondition 解析条件表达式
+func parseCondition(expr string, searches map[string]*search) (node, error) {
+	p := &conditionParser{tokens: tokenize(expr), searches: searches}
+	if len(p.tokens) == 0 {
+		return nil, errors.New("条件为空")
+	}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("条件中有多余的 %q", p.tokens[p.pos])
+	}
+	return n, nil
+}
+
+// tokenize 将条件拆分为括号与单词
+func tokenize(expr string) []string {
+	var tokens []string
+	for _, word := range strings.Fields(expr) {
+		for word != "" {
+			i := strings.IndexAny(word, "()")
+			switch {
+			case i < 0:
+				tokens, word = append(tokens, word), ""
+			case i > 0:
+				tokens, word = append(tokens, word[:i]), word[i:]
+			default:
+				tokens, word = append(tokens, word[:1]), word[1:]
+			}
+		}
+	}
+	return tokens
+}
+
+func (p *conditionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return strings.ToLower(p.tokens[p.pos])
+	}
+	return ""
+}
+
+func (p *conditionParser) or() (node, error) {
+	n, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []node{n}
+	for p.peek() == "or" {
+		p.pos++
+		n, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return orNode(nodes), nil
+}
+
+func (p *conditionParser) and() (node, error) {
+	n, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []node{n}
+	for p.peek() == "and" {
+		p.pos++
+		n, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return andNode(nodes), nil
+}
+
+func (p *conditionParser) not() (node, error) {
+	if p.peek() == "not" {
+		p.pos++
+		n, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	return p.primary()
+}
+
+func (p *conditionParser) primary() (node, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, errors.New("条件不完整")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	switch lower := strings.ToLower(token); {
+	case lower == "(":
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("条件中的括号不匹配")
+		}
+		p.pos++
+		return n, nil
+	case lower == ")" || lower == "and" || lower == "or" || lower == "of":
+		return nil, fmt.Errorf("条件中不应出现 %q", token)
+	case p.peek() == "of":
+		return p.of(lower)
+	}
+	s, ok := p.searches[token]
+	if !ok {
+		return nil, fmt.Errorf("条件引用了不存在的检测标识 %s", token)
+	}
+	return s, nil
+}
+
+// of 解析 1 of selection_*、all of them 等表达式
+func (p *conditionParser) of(quantifier string) (node, error) {
+	p.pos++
+	need := 0
+	switch quantifier {
+	case "all":
+	case "any":
+		need = 1
+	default:
+		n, err := strconv.Atoi(quantifier)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("无效的 of 表达式 %q", quantifier)
+		}
+		need = n
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, errors.New("of 之后缺少检测标识")
+	}
+	target := p.tokens[p.pos]
+	p.pos++
+
+	names := make([]string, 0, len(p.searches))
+	for name := range p.searches {
+		if target == "them" && !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		} else if ok, _ := path.Match(target, name); ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s 没有匹配的检测标识", target)
+	}
+	sort.Strings(names)
+	n := ofNode{min: need, searches: make([]*search, len(names))}
+	for i, name := range names {
+		n.searches[i] = p.searches[name]
+	}
+	return n, nil
+}
@@ -0,0 +1,147 @@
+package sigma
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/pkg/logger"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// log Sigma 规则日志
+var log = logger.Component("detect").With("detector", "sigma")
+
+// Engine 对记录求值已加载的 Sigma 规则，规则文件变化时自动重新加载
+// Match 可与重新加载并发调用
+type Engine struct {
+	config      Config
+	rules       atomic.Pointer[RuleSet]
+	fingerprint string
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// New 加载规则，规则路径不存在或无法读取时返回错误；单条规则无法加载时记录警告并跳过
+func New(cfg Config) (*Engine, error) {
+	e := &Engine{config: cfg, done: make(chan struct{})}
+	e.fingerprint, _ = fingerprint(cfg.Rules)
+	set, err := Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	e.store(set, "已加载")
+
+	interval := cfg.ReloadInterval.Std()
+	if interval == 0 {
+		interval = DefaultReloadInterval
+	}
+	if interval < 0 {
+		close(e.done)
+		e.cancel = func() {}
+		return e, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	go e.watch(ctx, interval)
+	return e, nil
+}
+
+// Match 返回记录命中的规则
+func (e *Engine) Match(record *model.DNSRecord) []Match {
+	return e.rules.Load().Match(record)
+}
+
+// Close 停止检查规则文件变化
+func (e *Engine) Close() {
+	e.cancel()
+	<-e.done
+}
+
+// store 替换当前的规则并记录日志
+func (e *Engine) store(set *RuleSet, action string) {
+	for _, err := range set.Errors {
+		log.Warn(fmt.Sprintf("跳过无法加载的 Sigma 规则 %v", err))
+	}
+	log.Info(fmt.Sprintf("%s Sigma 规则 %d 条（%d 个文件，跳过 %d 条，%d 条无法加载）",
+		action, len(set.Rules), set.Files, set.Skipped, len(set.Errors)))
+	e.rules.Store(set)
+}
+
+// watch 定期检查规则文件的修改时间与大小，变化时重新加载
+// 重新加载失败时保留之前的规则
+func (e *Engine) watch(ctx context.Context, interval time.Duration) {
+	defer close(e.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fp, err := fingerprint(e.config.Rules)
+		if err != nil || fp == e.fingerprint {
+			continue
+		}
+		set, err := Load(e.config)
+		if err != nil {
+			log.Warn(fmt.Sprintf("重新加载 Sigma 规则失败，继续使用之前的规则: %v", err))
+			continue
+		}
+		e.fingerprint = fp
+		e.store(set, "已重新加载")
+	}
+}
+
+// fingerprint 返回全部规则文件的路径、大小与修改时间，用于判断规则是否变化
+func fingerprint(paths []string) (string, error) {
+	files, err := ruleFiles(paths)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s\x00%d\x00%d\n", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// Match 返回记录命中的规则；聚合规则在时间窗口内的聚合值满足条件时命中
+// 聚合状态保存在规则中，同一 RuleSet 的记录应按时间先后传入
+func (s *RuleSet) Match(record *model.DNSRecord) []Match {
+	var matches []Match
+	for _, rule := range s.Rules {
+		if m, ok := rule.match(record); ok {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// match 判断记录是否命中规则
+func (r *Rule) match(record *model.DNSRecord) (Match, bool) {
+	if !r.detection.condition.eval(record) {
+		return Match{}, false
+	}
+	if r.aggregate == nil {
+		return Match{Rule: r}, true
+	}
+	at := record.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	group, value, ok := r.aggregate.observe(record, at, r.timeframe)
+	if !ok {
+		return Match{}, false
+	}
+	return Match{Rule: r, Aggregate: r.aggregate.expr, Group: group, Value: value}, true
+}
@@ -0,0 +1,130 @@
+package sigma
+
+import (
+	"dnsflux/internal/model"
+	"strconv"
+	"strings"
+)
+
+// fieldFunc 返回记录中字段的值，多值字段（如应答）返回多个值，字段为空时返回 nil
+type fieldFunc func(r *model.DNSRecord) []string
+
+// field 映射到 DNSRecord 的 Sigma 字段
+type field struct {
+	name  string // 规则中的字段名
+	value fieldFunc
+}
+
+// fieldAliases Sigma 字段名（小写）到记录字段的映射
+// 包括 Sysmon 事件 22（dns_query）、Sigma dns 类别与 ECS 的常用字段名
+var fieldAliases = map[string]fieldFunc{
+	"queryname":            str(func(r *model.DNSRecord) string { return r.QueryName }),
+	"query":                str(func(r *model.DNSRecord) string { return r.QueryName }),
+	"dns.question.name":    str(func(r *model.DNSRecord) string { return r.QueryName }),
+	"querytype":            str(func(r *model.DNSRecord) string { return r.QueryType }),
+	"record_type":          str(func(r *model.DNSRecord) string { return r.QueryType }),
+	"dns.question.type":    str(func(r *model.DNSRecord) string { return r.QueryType }),
+	"queryresults":         answers,
+	"answer":               answers,
+	"answers":              answers,
+	"dns.answers.data":     answers,
+	"querystatus":          str(func(r *model.DNSRecord) string { return queryStatus(r.RCode) }),
+	"rcode":                str(func(r *model.DNSRecord) string { return r.RCode }),
+	"dns.response_code":    str(func(r *model.DNSRecord) string { return r.RCode }),
+	"image":                str(func(r *model.DNSRecord) string { return r.ProcessPath }),
+	"processpath":          str(func(r *model.DNSRecord) string { return r.ProcessPath }),
+	"process.executable":   str(func(r *model.DNSRecord) string { return r.ProcessPath }),
+	"processname":          str(func(r *model.DNSRecord) string { return r.ProcessName }),
+	"process.name":         str(func(r *model.DNSRecord) string { return r.ProcessName }),
+	"processid":            num(func(r *model.DNSRecord) uint64 { return uint64(r.ProcessID) }),
+	"process.pid":          num(func(r *model.DNSRecord) uint64 { return uint64(r.ProcessID) }),
+	"parentprocessid":      num(func(r *model.DNSRecord) uint64 { return uint64(r.ParentPID) }),
+	"process.parent.pid":   num(func(r *model.DNSRecord) uint64 { return uint64(r.ParentPID) }),
+	"user":                 str(func(r *model.DNSRecord) string { return r.ProcessUser }),
+	"user.name":            str(func(r *model.DNSRecord) string { return r.ProcessUser }),
+	"commandline":          str(func(r *model.DNSRecord) string { return r.ProcessCmdline }),
+	"process.command_line": str(func(r *model.DNSRecord) string { return r.ProcessCmdline }),
+	"computer":             str(func(r *model.DNSRecord) string { return r.Hostname }),
+	"computername":         str(func(r *model.DNSRecord) string { return r.Hostname }),
+	"hostname":             str(func(r *model.DNSRecord) string { return r.Hostname }),
+	"host.name":            str(func(r *model.DNSRecord) string { return r.Hostname }),
+	"src_ip":               str(func(r *model.DNSRecord) string { return r.ClientIP }),
+	"source.ip":            str(func(r *model.DNSRecord) string { return r.ClientIP }),
+	"dst_ip":               str(func(r *model.DNSRecord) string { return r.ServerIP }),
+	"destination.ip":       str(func(r *model.DNSRecord) string { return r.ServerIP }),
+	"dst_port":             num(func(r *model.DNSRecord) uint64 { return uint64(r.ServerPort) }),
+	"destination.port":     num(func(r *model.DNSRecord) uint64 { return uint64(r.ServerPort) }),
+	"transport":            str(func(r *model.DNSRecord) string { return r.Transport }),
+	"network.transport":    str(func(r *model.DNSRecord) string { return r.Transport }),
+}
+
+// keywordFields 关键字（不指定字段的值）搜索的字段
+var keywordFields = []fieldFunc{
+	fieldAliases["queryname"],
+	fieldAliases["image"],
+	fieldAliases["commandline"],
+	answers,
+}
+
+// lookupField 按名称查找字段，不区分大小写
+func lookupField(name string) (*field, bool) {
+	value, ok := fieldAliases[strings.ToLower(name)]
+	if !ok {
+		return nil, false
+	}
+	return &field{name: name, value: value}, true
+}
+
+// str 字符串字段
+func str(get func(r *model.DNSRecord) string) fieldFunc {
+	return func(r *model.DNSRecord) []string {
+		if v := get(r); v != "" {
+			return []string{v}
+		}
+		return nil
+	}
+}
+
+// num 数值字段，0 视为空
+func num(get func(r *model.DNSRecord) uint64) fieldFunc {
+	return func(r *model.DNSRecord) []string {
+		if v := get(r); v != 0 {
+			return []string{strconv.FormatUint(v, 10)}
+		}
+		return nil
+	}
+}
+
+// answers 返回全部应答数据
+func answers(r *model.DNSRecord) []string {
+	if len(r.Answers) == 0 {
+		return nil
+	}
+	values := make([]string, len(r.Answers))
+	for i, a := range r.Answers {
+		values[i] = a.Data
+	}
+	return values
+}
+
+// queryStatuses 响应码到 Sysmon QueryStatus（Windows DNS 状态码）的映射
+var queryStatuses = map[string]string{
+	"NOERROR":  "0",
+	"FORMERR":  "9001",
+	"SERVFAIL": "9002",
+	"NXDOMAIN": "9003",
+	"NOTIMP":   "9004",
+	"REFUSED":  "9005",
+	"TIMEOUT":  "1460",
+}
+
+// queryStatus 将响应码转换为 Sysmon 的 QueryStatus，未知的响应码原样返回
+func queryStatus(rcode string) string {
+	if rcode == "" {
+		return ""
+	}
+	if status, ok := queryStatuses[strings.ToUpper(rcode)]; ok {
+		return status
+	}
+	return rcode
+}
@@ -0,0 +1,248 @@
+package sigma
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleSet 一次加载的规则
+type RuleSet struct {
+	Rules   []*Rule
+	Errors  []*RuleError // 无法加载的规则
+	Files   int          // 读取的规则文件数
+	Skipped int          // 因类别、级别、状态或 disabled 未加载的规则数
+}
+
+// ruleFile 规则文件中的一条规则
+type ruleFile struct {
+	Title       string         `yaml:"title"`
+	ID          string         `yaml:"id"`
+	Status      string         `yaml:"status"`
+	Description string         `yaml:"description"`
+	Author      string         `yaml:"author"`
+	References  []string       `yaml:"references"`
+	Tags        []string       `yaml:"tags"`
+	Level       string         `yaml:"level"`
+	Action      string         `yaml:"action"`
+	Correlation any            `yaml:"correlation"`
+	Timeframe   string         `yaml:"timeframe"`
+	Detection   map[string]any `yaml:"detection"`
+	LogSource   struct {
+		Category string `yaml:"category"`
+		Product  string `yaml:"product"`
+		Service  string `yaml:"service"`
+	} `yaml:"logsource"`
+}
+
+// Load 加载配置中的全部规则，规则路径不存在或无法读取时返回错误，单条规则无法加载时记录在 Errors 中
+func Load(cfg Config) (*RuleSet, error) {
+	paths, err := ruleFiles(cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+	minLevel := -1
+	if cfg.MinLevel != "" {
+		minLevel = levelIndex(cfg.MinLevel)
+	}
+	set := &RuleSet{Files: len(paths)}
+	for _, path := range paths {
+		if err := set.loadFile(path, cfg, minLevel); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// ruleFiles 返回规则路径中的全部规则文件，目录中递归查找 .yml 与 .yaml 文件
+func ruleFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取 Sigma 规则失败: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := strings.ToLower(filepath.Ext(name)); !d.IsDir() && (ext == ".yml" || ext == ".yaml") {
+				files = append(files, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("读取 Sigma 规则失败: %w", err)
+		}
+	}
+	sort.Strings(files)
+	return slices.Compact(files), nil
+}
+
+// loadFile 加载一个规则文件，文件可包含多个 YAML 文档
+func (s *RuleSet) loadFile(path string, cfg Config, minLevel int) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取 Sigma 规则失败: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var rf ruleFile
+		err := decoder.Decode(&rf)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			s.Errors = append(s.Errors, &RuleError{Path: path, Err: err})
+			return nil
+		}
+		if rf.Title == "" && rf.Detection == nil && rf.Action == "" && rf.Correlation == nil {
+			continue
+		}
+		if rf.Action != "" {
+			s.Errors = append(s.Errors, &RuleError{Path: path, Title: rf.Title, Err: fmt.Errorf("不支持规则集合（action: %s）", rf.Action)})
+			continue
+		}
+		if rf.Correlation != nil {
+			s.Errors = append(s.Errors, &RuleError{Path: path, Title: rf.Title, Err: errors.New("不支持关联规则")})
+			continue
+		}
+		if !slices.Contains(Categories, rf.LogSource.Category) ||
+			rf.Status == "deprecated" || rf.Status == "unsupported" ||
+			slices.Contains(cfg.Disabled, rf.ID) || slices.Contains(cfg.Disabled, rf.Title) ||
+			levelIndex(strings.ToLower(rf.Level)) < minLevel {
+			s.Skipped++
+			continue
+		}
+		rule, err := compileRule(&rf)
+		if err != nil {
+			s.Errors = append(s.Errors, &RuleError{Path: path, Title: rf.Title, Err: err})
+			continue
+		}
+		rule.Path = path
+		s.Rules = append(s.Rules, rule)
+	}
+}
+
+// compileRule 编译一条规则
+func compileRule(rf *ruleFile) (*Rule, error) {
+	if rf.Title == "" {
+		return nil, errors.New("缺少 title")
+	}
+	if len(rf.Detection) == 0 {
+		return nil, errors.New("缺少 detection")
+	}
+	rule := &Rule{
+		ID:          rf.ID,
+		Title:       rf.Title,
+		Level:       strings.ToLower(rf.Level),
+		Status:      rf.Status,
+		Description: strings.TrimSpace(rf.Description),
+		Tags:        rf.Tags,
+		Author:      rf.Author,
+		References:  rf.References,
+		detection:   &detection{searches: make(map[string]*search)},
+	}
+
+	var conditions []string
+	timeframe := rf.Timeframe
+	for name, value := range rf.Detection {
+		switch name {
+		case "condition":
+			switch v := value.(type) {
+			case string:
+				conditions = []string{v}
+			case []any:
+				for _, c := range v {
+					s, ok := c.(string)
+					if !ok {
+						return nil, errors.New("condition 必须为字符串或字符串列表")
+					}
+					conditions = append(conditions, s)
+				}
+			default:
+				return nil, errors.New("condition 必须为字符串或字符串列表")
+			}
+		case "timeframe":
+			s, ok := value.(string)
+			if !ok {
+				return nil, errors.New("timeframe 必须为字符串")
+			}
+			timeframe = s
+		default:
+			s, err := compileSearch(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			rule.detection.searches[name] = s
+		}
+	}
+	if len(conditions) == 0 {
+		return nil, errors.New("缺少 condition")
+	}
+
+	var nodes []node
+	for _, condition := range conditions {
+		expr, agg, hasAgg := strings.Cut(condition, "|")
+		if hasAgg {
+			if len(conditions) > 1 {
+				return nil, errors.New("多个 condition 时不支持聚合")
+			}
+			a, err := parseAggregation(agg)
+			if err != nil {
+				return nil, err
+			}
+			rule.aggregate = a
+		}
+		n, err := parseCondition(expr, rule.detection.searches)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 {
+		rule.detection.condition = nodes[0]
+	} else {
+		rule.detection.condition = orNode(nodes)
+	}
+
+	if timeframe != "" {
+		d, err := parseTimeframe(timeframe)
+		if err != nil {
+			return nil, err
+		}
+		rule.timeframe = d
+	} else if rule.aggregate != nil {
+		rule.timeframe = DefaultTimeframe
+	}
+	return rule, nil
+}
+
+// parseTimeframe 解析 timeframe，如 30s、5m、1h、1d
+func parseTimeframe(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'s': time.Second, 'm': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour}
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 {
+		if unit, ok := units[s[len(s)-1]]; ok {
+			if n, err := strconv.Atoi(s[:len(s)-1]); err == nil && n > 0 {
+				return time.Duration(n) * unit, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("无效的 timeframe %q", s)
+}
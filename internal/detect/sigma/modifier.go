@@ -0,0 +1,475 @@
+package sigma
+
+import (
+	"dnsflux/internal/model"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// 值的匹配方式
+const (
+	matchEquals = iota
+	matchPrefix
+	matchSuffix
+	matchContains
+	matchRegexp
+	matchCIDR
+	matchCompare
+)
+
+// pattern 一个已编译的值
+type pattern struct {
+	kind   int
+	s      string // 字面值，不区分大小写时已转为小写
+	cased  bool
+	re     *regexp.Regexp
+	prefix netip.Prefix
+	op     string // 数值比较：gt、gte、lt、lte
+	number float64
+}
+
+// match 判断字段的一个值是否匹配
+func (p *pattern) match(v string) bool {
+	switch p.kind {
+	case matchRegexp:
+		return p.re.MatchString(v)
+	case matchCIDR:
+		addr, err := netip.ParseAddr(v)
+		return err == nil && p.prefix.Contains(addr.Unmap())
+	case matchCompare:
+		n, err := strconv.ParseFloat(v, 64)
+		return err == nil && compare(n, p.op, p.number)
+	}
+	if !p.cased {
+		v = strings.ToLower(v)
+	}
+	switch p.kind {
+	case matchPrefix:
+		return strings.HasPrefix(v, p.s)
+	case matchSuffix:
+		return strings.HasSuffix(v, p.s)
+	case matchContains:
+		return strings.Contains(v, p.s)
+	default:
+		return v == p.s
+	}
+}
+
+// fieldMatcher 一个字段条件，如 QueryName|endswith: [...]
+type fieldMatcher struct {
+	fields []fieldFunc
+	values [][]*pattern // 每个值编码后可能有多个变体，变体之间为 or；nil 表示 null
+	all    bool         // 全部值都须匹配（|all），否则任一值匹配即可
+	exists *bool        // |exists 修饰符
+}
+
+// match 判断记录是否满足字段条件
+func (m *fieldMatcher) match(r *model.DNSRecord) bool {
+	var values []string
+	for _, f := range m.fields {
+		values = append(values, f(r)...)
+	}
+	if m.exists != nil {
+		return (len(values) > 0) == *m.exists
+	}
+	for _, variants := range m.values {
+		ok := matchAny(values, variants)
+		if ok != m.all {
+			return ok
+		}
+	}
+	return m.all
+}
+
+// matchAny 判断任一字段值匹配任一变体，变体为 nil（null）时字段为空即匹配
+func matchAny(values []string, variants []*pattern) bool {
+	if variants == nil {
+		return len(values) == 0
+	}
+	for _, v := range values {
+		for _, p := range variants {
+			if p.match(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// modifiers 字段名中 | 之后的值修饰符
+type modifiers struct {
+	kind      string   // contains、startswith、endswith、re、cidr、exists 或数值比较，为空表示相等
+	encodings []string // 按顺序应用的编码：wide、utf16le、utf16be、utf16、base64、base64offset、windash
+	reFlags   string
+	all       bool
+	cased     bool
+}
+
+// parseModifiers 解析修饰符列表
+func parseModifiers(names []string) (*modifiers, error) {
+	m := &modifiers{}
+	for _, name := range names {
+		switch name {
+		case "contains", "startswith", "endswith", "re", "cidr", "exists", "gt", "gte", "lt", "lte":
+			if m.kind != "" {
+				return nil, fmt.Errorf("修饰符 %s 与 %s 不能同时使用", m.kind, name)
+			}
+			m.kind = name
+		case "i", "ignorecase", "m", "multiline", "s", "dotall":
+			if m.kind != "re" {
+				return nil, fmt.Errorf("修饰符 %s 只能用于 re 之后", name)
+			}
+			m.reFlags += name[:1]
+		case "wide", "utf16le", "utf16be", "utf16", "base64", "base64offset", "windash":
+			m.encodings = append(m.encodings, name)
+		case "all":
+			m.all = true
+		case "cased":
+			m.cased = true
+		default:
+			return nil, fmt.Errorf("不支持的修饰符 %s", name)
+		}
+	}
+	if len(m.encodings) > 0 && m.kind != "" && !slices.Contains([]string{"contains", "startswith", "endswith"}, m.kind) {
+		return nil, fmt.Errorf("修饰符 %s 不能与编码修饰符同时使用", m.kind)
+	}
+	return m, nil
+}
+
+// compileField 编译一个字段条件，key 为字段名与修饰符，如 QueryName|endswith；字段名为空时为关键字搜索
+func compileField(key string, value any) (*fieldMatcher, error) {
+	parts := strings.Split(key, "|")
+	mods, err := parseModifiers(parts[1:])
+	if err != nil {
+		return nil, err
+	}
+	m := &fieldMatcher{all: mods.all}
+	if parts[0] == "" {
+		m.fields = keywordFields
+		if mods.kind == "" {
+			mods.kind = "contains"
+		}
+	} else {
+		f, ok := lookupField(parts[0])
+		if !ok {
+			return nil, fmt.Errorf("不支持的字段 %s", parts[0])
+		}
+		m.fields = []fieldFunc{f.value}
+	}
+
+	values, ok := value.([]any)
+	if !ok {
+		values = []any{value}
+	}
+	if mods.kind == "exists" {
+		b, ok := value.(bool)
+		if !ok {
+			return nil, errors.New("exists 修饰符的值必须为 true 或 false")
+		}
+		m.exists = &b
+		return m, nil
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("字段 %s 没有值", key)
+	}
+	for _, v := range values {
+		variants, err := mods.compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("字段 %s: %w", key, err)
+		}
+		m.values = append(m.values, variants)
+	}
+	return m, nil
+}
+
+// compile 编译一个值，返回其全部变体；值为 null 时返回 nil
+func (m *modifiers) compile(value any) ([]*pattern, error) {
+	var s string
+	literal := false
+	switch v := value.(type) {
+	case nil:
+		if m.kind != "" || len(m.encodings) > 0 {
+			return nil, errors.New("null 不能与修饰符同时使用")
+		}
+		return nil, nil
+	case string:
+		s = v
+	case int, int64, uint64, float64, bool:
+		s, literal = fmt.Sprint(v), true
+	default:
+		return nil, fmt.Errorf("不支持的值 %v", value)
+	}
+
+	switch m.kind {
+	case "re":
+		flags := m.reFlags
+		if flags != "" {
+			flags = "(?" + flags + ")"
+		}
+		re, err := regexp.Compile(flags + s)
+		if err != nil {
+			return nil, fmt.Errorf("正则表达式无效: %w", err)
+		}
+		return []*pattern{{kind: matchRegexp, re: re}}, nil
+	case "cidr":
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("CIDR 无效: %w", err)
+		}
+		return []*pattern{{kind: matchCIDR, prefix: prefix.Masked()}}, nil
+	case "gt", "gte", "lt", "lte":
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s 修饰符的值必须为数值", m.kind)
+		}
+		return []*pattern{{kind: matchCompare, op: m.kind, number: n}}, nil
+	}
+
+	// windash 作用于原始值，其余编码将值转为字节序列，编码后的值按字面值区分大小写匹配
+	variants := []string{s}
+	cased := m.cased
+	for _, encoding := range m.encodings {
+		var next []string
+		for _, v := range variants {
+			next = append(next, encode(encoding, unescape(v, encoding == "windash" || literal))...)
+		}
+		variants = next
+		if encoding != "windash" {
+			literal, cased = true, true
+		}
+	}
+
+	patterns := make([]*pattern, 0, len(variants))
+	for _, v := range variants {
+		var segs []segment
+		if literal {
+			segs = []segment{{lit: v}}
+		} else {
+			segs = parseWildcard(v)
+		}
+		switch m.kind {
+		case "contains":
+			segs = append(append([]segment{{wild: '*'}}, segs...), segment{wild: '*'})
+		case "startswith":
+			segs = append(segs, segment{wild: '*'})
+		case "endswith":
+			segs = append([]segment{{wild: '*'}}, segs...)
+		}
+		p, err := newPattern(segs, cased)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// segment 通配符值的一段：字面值或通配符 * 与 ?
+type segment struct {
+	lit  string
+	wild byte
+}
+
+// parseWildcard 解析带通配符的值，\* \? \\ 为转义，其余反斜杠按字面值处理
+func parseWildcard(s string) []segment {
+	var segs []segment
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			segs = append(segs, segment{lit: lit.String()})
+			lit.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(`*?\`, s[i+1]) >= 0:
+			lit.WriteByte(s[i+1])
+			i++
+		case c == '*' || c == '?':
+			flush()
+			segs = append(segs, segment{wild: c})
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	flush()
+	return segs
+}
+
+// unescape 去掉通配符转义，用于按字面值编码的值；raw 为 true 时原样返回
+func unescape(s string, raw bool) string {
+	if raw {
+		return s
+	}
+	var b strings.Builder
+	for _, seg := range parseWildcard(s) {
+		if seg.wild != 0 {
+			b.WriteByte(seg.wild)
+		} else {
+			b.WriteString(seg.lit)
+		}
+	}
+	return b.String()
+}
+
+// newPattern 由通配符段生成匹配，只在首尾有 * 时使用字符串比较，否则使用正则表达式
+func newPattern(segs []segment, cased bool) (*pattern, error) {
+	leading, trailing := false, false
+	for len(segs) > 0 && segs[0].wild == '*' {
+		segs, leading = segs[1:], true
+	}
+	for len(segs) > 0 && segs[len(segs)-1].wild == '*' {
+		segs, trailing = segs[:len(segs)-1], true
+	}
+
+	var lit strings.Builder
+	wildcard := false
+	for _, seg := range segs {
+		if seg.wild != 0 {
+			wildcard = true
+			break
+		}
+		lit.WriteString(seg.lit)
+	}
+	if !wildcard {
+		p := &pattern{s: lit.String(), cased: cased}
+		if !cased {
+			p.s = strings.ToLower(p.s)
+		}
+		switch {
+		case leading && trailing:
+			p.kind = matchContains
+		case leading:
+			p.kind = matchSuffix
+		case trailing:
+			p.kind = matchPrefix
+		default:
+			p.kind = matchEquals
+		}
+		return p, nil
+	}
+
+	var expr strings.Builder
+	expr.WriteString("(?s)")
+	if !cased {
+		expr.WriteString("(?i)")
+	}
+	if !leading {
+		expr.WriteString("^")
+	}
+	for _, seg := range segs {
+		switch seg.wild {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(seg.lit))
+		}
+	}
+	if !trailing {
+		expr.WriteString("$")
+	}
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	return &pattern{kind: matchRegexp, re: re}, nil
+}
+
+// windashes windash 修饰符替换的破折号
+var windashes = []string{"/", "–", "—", "―"}
+
+// encode 对值应用编码修饰符，返回全部变体
+func encode(encoding, s string) []string {
+	switch encoding {
+	case "wide", "utf16le":
+		return []string{utf16String(s, false, false)}
+	case "utf16be":
+		return []string{utf16String(s, true, false)}
+	case "utf16":
+		return []string{utf16String(s, false, true)}
+	case "base64":
+		return []string{base64.StdEncoding.EncodeToString([]byte(s))}
+	case "base64offset":
+		return base64Offsets(s)
+	case "windash":
+		variants := []string{s}
+		for _, dash := range windashes {
+			if v := replaceDashes(s, dash); v != s {
+				variants = append(variants, v)
+			}
+		}
+		return variants
+	}
+	return []string{s}
+}
+
+// utf16String 将值编码为 UTF-16 字节序列
+func utf16String(s string, bigEndian, bom bool) string {
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xfeff}, units...)
+	}
+	b := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+	return string(b)
+}
+
+// base64Offsets 返回值位于 base64 数据中三种偏移位置时编码结果中稳定不变的部分
+func base64Offsets(s string) []string {
+	starts := []int{0, 2, 3}
+	ends := []int{0, 3, 2}
+	variants := make([]string, 0, 3)
+	for i := range 3 {
+		encoded := base64.StdEncoding.EncodeToString([]byte(strings.Repeat(" ", i) + s))
+		end := len(encoded) - ends[(len(s)+i)%3]
+		if end > starts[i] {
+			variants = append(variants, encoded[starts[i]:end])
+		}
+	}
+	return variants
+}
+
+// replaceDashes 替换位于开头或空白之后的 -
+func replaceDashes(s, dash string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '-' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t') {
+			b.WriteString(dash)
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// compare 数值比较
+func compare(a float64, op string, b float64) bool {
+	switch op {
+	case "gt", ">":
+		return a > b
+	case "gte", ">=":
+		return a >= b
+	case "lt", "<":
+		return a < b
+	case "lte", "<=":
+		return a <= b
+	default:
+		return a == b
+	}
+}
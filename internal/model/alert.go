@@ -0,0 +1,123 @@
+package model
+
+import (
+	"dnsflux/internal/utils"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 告警级别，与 Sigma 规则级别一致
+const (
+	SeverityInformational = "informational"
+	SeverityLow           = "low"
+	SeverityMedium        = "medium"
+	SeverityHigh          = "high"
+	SeverityCritical      = "critical"
+)
+
+// Severities 全部告警级别，从低到高排列
+var Severities = []string{SeverityInformational, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// SeverityRank 返回告警级别的序号，级别越高序号越大，未知级别返回 -1
+func SeverityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// 告警状态
+const (
+	AlertOpen         = "open"
+	AlertAcknowledged = "acknowledged"
+	AlertClosed       = "closed"
+)
+
+// AlertStatuses 全部告警状态
+var AlertStatuses = []string{AlertOpen, AlertAcknowledged, AlertClosed}
+
+// Alert 由检测器告警生成的事件，去重窗口内相同的告警合并为一条
+type Alert struct {
+	ID        string            `json:"id"`
+	Key       string            `json:"key"`                 // 去重键：主机、检测器、规则、进程与域名
+	Detector  string            `json:"detector"`            // 检测器，如 intel
+	Rule      string            `json:"rule"`                // 命中的规则或情报源名称
+	Severity  string            `json:"severity"`            // 告警级别
+	Technique string            `json:"technique,omitempty"` // MITRE ATT&CK 技术编号，如 T1071.004
+	Message   string            `json:"message"`             // 首次告警的说明
+	Fields    map[string]string `json:"fields,omitempty"`    // 首次告警的详细信息
+
+	// 首次告警记录的主机、查询与进程
+	Hostname    string `json:"hostname,omitempty"`
+	ClientIP    string `json:"clientIP,omitempty"`
+	QueryName   string `json:"queryName"`
+	QueryType   string `json:"queryType,omitempty"`
+	ProcessID   uint32 `json:"processId,omitempty"`
+	ProcessName string `json:"processName,omitempty"`
+	ProcessPath string `json:"processPath,omitempty"`
+
+	// 合并情况与处理状态
+	RecordIDs []string  `json:"recordIds"` // 相关记录 ID，按时间顺序，超过上限时只保留最早的部分
+	Count     int       `json:"count"`     // 合并的告警次数
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updatedAt"` // 状态最近一次变化的时间
+}
+
+// ProcessLabel 返回告警进程描述，如 curl(1234)，无进程信息时使用客户端地址
+func (a *Alert) ProcessLabel() string {
+	if a.ProcessID != 0 || a.ProcessName != "" {
+		return fmt.Sprintf("%s(%d)", a.ProcessName, a.ProcessID)
+	}
+	if a.ClientIP != "" {
+		return a.ClientIP
+	}
+	return "-"
+}
+
+// FormatAlert 格式化告警为字符串
+func (a *Alert) FormatAlert() string {
+	var fields strings.Builder
+	for _, key := range sortedKeys(a.Fields) {
+		fmt.Fprintf(&fields, "  %s = %s\n", key, a.Fields[key])
+	}
+	return fmt.Sprintf("\n[!] DNS Alert\n"+
+		"First Seen   : %s\n"+
+		"Severity     : %s\n"+
+		"Detector     : %s\n"+
+		"Rule         : %s\n"+
+		"Technique    : %s\n"+
+		"Message      : %s\n"+
+		"Query Name   : %s\n"+
+		"Process      : %s\n"+
+		"Count        : %d\n"+
+		"Status       : %s\n"+
+		"Fields       :\n%s"+
+		"*************************************",
+		utils.DisplayTime(a.FirstSeen).Format("2006-01-02 15:04:05"),
+		a.Severity,
+		a.Detector,
+		a.Rule,
+		valueOrDash(a.Technique),
+		a.Message,
+		a.QueryName,
+		a.ProcessLabel(),
+		a.Count,
+		a.Status,
+		fields.String())
+}
+
+// sortedKeys 返回按字母顺序排列的键
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
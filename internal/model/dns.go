@@ -1,77 +1,303 @@
 package model
 
 import (
+	"bufio"
+	"crypto/rand"
+	"dnsflux/internal/utils"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
+	"net"
+	"strings"
 	"time"
 )
 
+// SchemaVersion 当前 DNSRecord 的结构版本
+// 版本 1 为旧版扁平结构（queryResult 为逗号拼接的字符串），版本 2 引入结构化应答等字段
+const SchemaVersion = 2
+
+// 采集来源
+const (
+	SourceEBPF = "ebpf"
+	SourceETW  = "etw"
+	SourcePcap = "pcap" // 从抓包文件回放
+)
+
+// 传输协议
+const (
+	TransportUDP   = "udp"
+	TransportTCP   = "tcp"
+	TransportDoT   = "dot"
+	TransportDoH   = "doh"
+	TransportDoQ   = "doq"
+	TransportOther = "unknown"
+)
+
+// DNSAnswer DNS 应答记录
+type DNSAnswer struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+	TTL  uint32 `json:"ttl"`
+}
+
+// RecordAlert 检测器对单条记录给出的告警
+type RecordAlert struct {
+	Detector  string            `json:"detector"`            // 检测器，如 intel
+	Rule      string            `json:"rule"`                // 命中的规则或情报源名称
+	Severity  string            `json:"severity,omitempty"`  // 告警级别
+	Technique string            `json:"technique,omitempty"` // MITRE ATT&CK 技术编号
+	Message   string            `json:"message"`             // 告警说明
+	Fields    map[string]string `json:"fields,omitempty"`    // 检测器相关的详细信息
+	AlertID   string            `json:"alertId,omitempty"`   // 合并到的告警 ID
+}
+
 // DNSRecord 定义通用的 DNS 记录结构在 collector/api/store 间复用
 type DNSRecord struct {
-	Timestamp   time.Time `json:"timestamp"`
-	QueryName   string    `json:"queryName"`
-	QueryType   string    `json:"queryType"`
-	QueryResult string    `json:"queryResult"`
-	ProcessID   uint32    `json:"processId"`
-	ProcessName string    `json:"processName"`
-	ProcessPath string    `json:"processPath"`
-	ClientIP    string    `json:"clientIP"`
+	SchemaVersion int       `json:"schemaVersion"`
+	ID            string    `json:"id"`
+	Timestamp     time.Time `json:"timestamp"`
+
+	// 主机与采集来源
+	HostID   string `json:"hostId,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Source   string `json:"source,omitempty"`
+
+	// 网络信息
+	Transport  string `json:"transport,omitempty"`
+	ClientIP   string `json:"clientIP"`
+	ServerIP   string `json:"serverIP,omitempty"`
+	ServerPort uint16 `json:"serverPort,omitempty"`
+
+	// 查询与应答
+	TransactionID uint16      `json:"transactionId"`
+	QueryName     string      `json:"queryName"`
+	QueryType     string      `json:"queryType"`
+	RCode         string      `json:"rcode,omitempty"`
+	Answers       []DNSAnswer `json:"answers"`
+	LatencyMs     float64     `json:"latencyMs,omitempty"`
+
+	// 进程信息
+	ProcessID      uint32 `json:"processId"`
+	ParentPID      uint32 `json:"parentProcessId,omitempty"`
+	ProcessName    string `json:"processName"`
+	ProcessPath    string `json:"processPath"`
+	ProcessUser    string `json:"processUser,omitempty"`
+	ProcessCmdline string `json:"processCmdline,omitempty"`
+
+	// 检测结果：DGA 评分（0 到 1）、新域名范围（domain、name、process）与检测器命中的告警
+	DGAScore      float64       `json:"dgaScore,omitempty"`
+	NewlyObserved string        `json:"newlyObserved,omitempty"`
+	Alerts        []RecordAlert `json:"alerts,omitempty"`
+
+	// 采集到的原始 DNS 报文，仅在内存中保留，不参与序列化
+	Payload []byte `json:"-"`
+}
+
+// legacyDNSRecord 兼容旧版本记录中已移除的字段
+type legacyDNSRecord struct {
+	QueryResult string `json:"queryResult"`
+}
+
+// NewRecordID 生成唯一的记录 ID（128 位随机数的十六进制表示）
+func NewRecordID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// NewDNSRecord 创建当前版本的 DNS 记录并生成记录 ID
+func NewDNSRecord(source string) DNSRecord {
+	return DNSRecord{
+		SchemaVersion: SchemaVersion,
+		ID:            NewRecordID(),
+		Source:        source,
+		Answers:       []DNSAnswer{},
+	}
+}
+
+// UnmarshalJSON 解码 DNS 记录，并将旧版本结构升级为当前版本
+func (r *DNSRecord) UnmarshalJSON(data []byte) error {
+	type plain DNSRecord
+	var rec plain
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+
+	if rec.SchemaVersion < 2 {
+		var legacy legacyDNSRecord
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		if len(rec.Answers) == 0 {
+			rec.Answers = ParseLegacyResult(legacy.QueryResult, rec.QueryType)
+		}
+	}
+
+	*r = DNSRecord(rec)
+	r.upgrade()
+	return nil
+}
+
+// upgrade 填充旧记录缺失的字段并将版本号设置为当前版本
+func (r *DNSRecord) upgrade() {
+	// 旧版本记录带有采集时的时区（如 +08:00），统一转换为 UTC
+	r.Timestamp = r.Timestamp.UTC()
+	if r.Answers == nil {
+		r.Answers = []DNSAnswer{}
+	}
+	if r.SchemaVersion > SchemaVersion {
+		// 来自更新版本的记录，保留版本号，未知字段已被忽略
+		return
+	}
+	if r.ID == "" {
+		r.ID = NewRecordID()
+	}
+	r.SchemaVersion = SchemaVersion
+}
+
+// ParseLegacyResult 将旧版本的逗号拼接结果转换为结构化应答
+func ParseLegacyResult(result, queryType string) []DNSAnswer {
+	answers := []DNSAnswer{}
+	for _, item := range strings.Split(result, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || item == "-" {
+			continue
+		}
+		answers = append(answers, DNSAnswer{
+			Type: AnswerTypeOf(item, queryType),
+			Data: item,
+		})
+	}
+	return answers
+}
+
+// AnswerTypeOf 根据应答数据推断记录类型，无法推断时使用查询类型
+func AnswerTypeOf(data, queryType string) string {
+	if ip := net.ParseIP(data); ip != nil {
+		if ip.To4() != nil {
+			return "A"
+		}
+		return "AAAA"
+	}
+	if queryType != "" {
+		return queryType
+	}
+	return "UNKNOWN"
+}
+
+// DecodeDNSRecord 解码单行 JSON 记录，兼容旧版本日志
+func DecodeDNSRecord(line []byte) (DNSRecord, error) {
+	var rec DNSRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+// ReadJSONL 逐行读取 JSONL 格式的 DNS 记录，空行将被跳过
+func ReadJSONL(r io.Reader, fn func(DNSRecord) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		rec, err := DecodeDNSRecord([]byte(line))
+		if err != nil {
+			return fmt.Errorf("第 %d 行解析失败: %w", lineNo, err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// AnswerSummary 返回应答数据的简要文本表示
+func (r *DNSRecord) AnswerSummary() string {
+	if len(r.Answers) == 0 {
+		return "-"
+	}
+	data := make([]string, 0, len(r.Answers))
+	for _, answer := range r.Answers {
+		data = append(data, answer.Data)
+	}
+	return strings.Join(data, ", ")
 }
 
 // FormatDNSRecord 格式化DNS查询记录为字符串
 func (r *DNSRecord) FormatDNSRecord() string {
-	timestamp := r.Timestamp.In(time.Local).Format("2006-01-02 15:04:05")
+	timestamp := utils.DisplayTime(r.Timestamp).Format("2006-01-02 15:04:05")
 	return fmt.Sprintf("\n[+] DNS Query Record\n"+
 		"Timestamp    : %s\n"+
 		"Query Name   : %s\n"+
 		"Query Type   : %s\n"+
-		"Query Result : %s\n"+
+		"Response Code: %s\n"+
+		"Answers      : %s\n"+
+		"Transport    : %s\n"+
+		"Server       : %s\n"+
 		"Process ID   : %d\n"+
 		"Process Name : %s\n"+
 		"Process Path : %s\n"+
 		"Client IP    : %s\n"+
+		"%s"+
 		"*************************************",
 		timestamp,
 		r.QueryName,
 		r.QueryType,
-		r.QueryResult,
+		valueOrDash(r.RCode),
+		r.AnswerSummary(),
+		valueOrDash(r.Transport),
+		r.serverAddr(),
 		r.ProcessID,
 		r.ProcessName,
 		r.ProcessPath,
-		r.ClientIP)
+		r.ClientIP,
+		r.alertSummary())
 }
 
-// SaveDNSRecordToJSON 保存DNS记录到JSON文件
-func (r *DNSRecord) SaveDNSRecordToJSON() error {
-	// 创建logs目录
-	logsDir := "logs"
-	if err := os.MkdirAll(logsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create logs directory: %v", err)
+// alertSummary 返回告警说明，每条一行，没有告警时为空
+func (r *DNSRecord) alertSummary() string {
+	var b strings.Builder
+	for _, alert := range r.Alerts {
+		fmt.Fprintf(&b, "Alert        : [%s] %s\n", alert.Detector, alert.Message)
 	}
+	return b.String()
+}
 
-	// 生成文件名（按日期）
-	filename := fmt.Sprintf("dns_records_%s.json", time.Now().Format("2006-01-02"))
-	filePath := filepath.Join(logsDir, filename)
-
-	// 打开文件（追加模式）
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %v", err)
+// ProcessLabel 返回发起查询的进程描述，如 curl(1234)，无进程信息（如回放抓包文件）时使用客户端地址
+func (r *DNSRecord) ProcessLabel() string {
+	if r.ProcessID != 0 || r.ProcessName != "" {
+		return fmt.Sprintf("%s(%d)", r.ProcessName, r.ProcessID)
 	}
-	defer file.Close()
-
-	// 将记录编码为JSON
-	jsonData, err := json.Marshal(r)
-	if err != nil {
-		return fmt.Errorf("failed to marshal record to JSON: %v", err)
+	if r.ClientIP != "" {
+		return r.ClientIP
 	}
+	return "-"
+}
 
-	// 写入文件（每条记录一行）
-	if _, err := file.Write(append(jsonData, '\n')); err != nil {
-		return fmt.Errorf("failed to write to file: %v", err)
+// serverAddr 返回 DNS 服务器地址
+func (r *DNSRecord) serverAddr() string {
+	if r.ServerIP == "" {
+		return "-"
+	}
+	if r.ServerPort == 0 {
+		return r.ServerIP
 	}
+	return fmt.Sprintf("%s:%d", r.ServerIP, r.ServerPort)
+}
 
-	return nil
+// valueOrDash 空字符串显示为 "-"
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
 }
@@ -0,0 +1,13 @@
+package model
+
+import _ "embed"
+
+// 嵌入 DNSRecord 的 JSON Schema 文档
+//
+//go:embed dns_record.schema.json
+var dnsRecordSchema []byte
+
+// DNSRecordSchema 返回当前版本 DNSRecord 的 JSON Schema
+func DNSRecordSchema() []byte {
+	return dnsRecordSchema
+}
@@ -0,0 +1,147 @@
+package console
+
+import (
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/utils"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	output.RegisterFormatter(FormatCompact, compactFormatter{})
+}
+
+// ANSI 颜色
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
+)
+
+// queryTypeColors 各查询类型的颜色，未列出的类型使用默认颜色
+var queryTypeColors = map[string]string{
+	"A":     colorBlue,
+	"AAAA":  colorCyan,
+	"CNAME": colorMagenta,
+	"HTTPS": colorMagenta,
+	"SVCB":  colorMagenta,
+	"PTR":   colorYellow,
+	"TXT":   colorYellow,
+	"MX":    colorYellow,
+	"SRV":   colorYellow,
+}
+
+// 紧凑格式的列宽
+const (
+	widthType    = 6
+	widthRCode   = 9
+	widthLatency = 9
+	widthProcess = 24
+)
+
+// compactFormatter 单行紧凑格式，各列对齐，可按查询类型与响应状态着色
+type compactFormatter struct {
+	color bool
+}
+
+// Format 实现 output.Formatter 接口
+func (f compactFormatter) Format(r *model.DNSRecord) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(f.paint(colorGray, utils.DisplayTime(r.Timestamp).Format("2006-01-02 15:04:05.000")))
+	b.WriteString("  ")
+	b.WriteString(f.paint(queryTypeColors[r.QueryType], pad(valueOrDash(r.QueryType), widthType)))
+	b.WriteString(" ")
+	b.WriteString(f.paint(rcodeColor(r.RCode), pad(valueOrDash(r.RCode), widthRCode)))
+	b.WriteString(" ")
+	b.WriteString(padLeft(formatLatency(r.LatencyMs), widthLatency))
+	b.WriteString("  ")
+	b.WriteString(pad(processLabel(r), widthProcess))
+	b.WriteString(" ")
+	b.WriteString(r.QueryName)
+	if len(r.Answers) > 0 {
+		b.WriteString(f.paint(colorGray, " -> "))
+		b.WriteString(r.AnswerSummary())
+	}
+	for _, alert := range r.Alerts {
+		b.WriteString(" ")
+		b.WriteString(f.paint(colorRed, "[!"+alert.Detector+":"+alert.Rule+"]"))
+	}
+	b.WriteByte('\n')
+	return []byte(b.String()), nil
+}
+
+// paint 启用颜色时为文本加上颜色
+func (f compactFormatter) paint(color, s string) string {
+	if !f.color || color == "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// rcodeColor 成功为绿色，域名不存在为黄色，其他错误为红色
+func rcodeColor(rcode string) string {
+	switch rcode {
+	case "":
+		return ""
+	case "NOERROR":
+		return colorGreen
+	case "NXDOMAIN":
+		return colorYellow
+	default:
+		return colorRed
+	}
+}
+
+// processLabel 返回 "进程名[PID]"，过长时截断进程名
+func processLabel(r *model.DNSRecord) string {
+	name := valueOrDash(r.ProcessName)
+	suffix := "[" + strconv.FormatUint(uint64(r.ProcessID), 10) + "]"
+	if room := widthProcess - len(suffix); utf8.RuneCountInString(name) > room && room > 1 {
+		runes := []rune(name)
+		name = string(runes[:room-1]) + "~"
+	}
+	return name + suffix
+}
+
+// formatLatency 格式化延迟，未知时显示 -
+func formatLatency(ms float64) string {
+	if ms <= 0 {
+		return "-"
+	}
+	if ms >= 1000 {
+		return fmt.Sprintf("%.2fs", ms/1000)
+	}
+	return fmt.Sprintf("%.1fms", ms)
+}
+
+// pad 右侧补空格到指定宽度
+func pad(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// padLeft 左侧补空格到指定宽度
+func padLeft(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return strings.Repeat(" ", width-n) + s
+	}
+	return s
+}
+
+// valueOrDash 空值显示为 -
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,161 @@
+package console
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+func init() {
+	output.Register("console", New)
+}
+
+// 控制台专用格式，pretty 与 json 为通用格式
+const (
+	FormatAuto     = "auto"     // 终端中使用 pretty，重定向或管道中使用 json
+	FormatCompact  = "compact"  // 单行对齐格式
+	FormatTemplate = "template" // 用户提供的 text/template
+)
+
+// 颜色模式
+const (
+	ColorAuto   = "auto" // 终端中且未设置 NO_COLOR 时启用
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// Options 控制台输出选项
+type Options struct {
+	Stream   string `json:"stream"`   // stdout 或 stderr
+	Color    string `json:"color"`    // auto, always, never
+	Template string `json:"template"` // template 格式使用的模板
+}
+
+// Sink 控制台输出目标
+type Sink struct {
+	name      string
+	writer    *os.File
+	formatter output.Formatter
+	json      bool // 告警以单行 JSON 输出，否则以文本块输出
+	color     bool
+}
+
+// New 根据配置创建控制台输出目标
+func New(config output.SinkConfig) (output.Sink, error) {
+	var opts Options
+	if err := config.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+
+	writer := os.Stdout
+	if opts.Stream == "stderr" {
+		writer = os.Stderr
+	}
+	tty := term.IsTerminal(int(writer.Fd()))
+
+	formatter, err := newFormatter(config.Format, opts, writer, tty)
+	if err != nil {
+		return nil, err
+	}
+	color, err := useColor(opts.Color, writer, tty)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sink{
+		name:      config.Name,
+		writer:    writer,
+		formatter: formatter,
+		json:      config.Format == output.FormatJSON || (config.Format == "" || config.Format == FormatAuto) && !tty,
+		color:     color,
+	}, nil
+}
+
+// NewFormatter 创建输出到 writer 的控制台格式化器，供命令行查询等输出记录的场景复用
+func NewFormatter(format string, opts Options, writer *os.File) (output.Formatter, error) {
+	return newFormatter(format, opts, writer, term.IsTerminal(int(writer.Fd())))
+}
+
+// newFormatter 根据格式与终端状态创建格式化器
+func newFormatter(format string, opts Options, writer *os.File, tty bool) (output.Formatter, error) {
+	if format == "" || format == FormatAuto {
+		format = output.FormatJSON
+		if tty {
+			format = output.FormatPretty
+		}
+	}
+
+	color, err := useColor(opts.Color, writer, tty)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatCompact:
+		return compactFormatter{color: color}, nil
+	case FormatTemplate:
+		return newTemplateFormatter(opts.Template)
+	default:
+		return output.NewFormatter(format, output.FormatPretty)
+	}
+}
+
+// useColor 判断是否输出 ANSI 颜色，遵循 NO_COLOR 约定 (https://no-color.org)
+func useColor(mode string, writer *os.File, tty bool) (bool, error) {
+	switch mode {
+	case "", ColorAuto:
+		return tty && os.Getenv("NO_COLOR") == "" && enableColor(writer), nil
+	case ColorAlways:
+		enableColor(writer)
+		return true, nil
+	case ColorNever:
+		return false, nil
+	default:
+		return false, fmt.Errorf("未知的颜色模式: %s", mode)
+	}
+}
+
+// Name 返回输出目标名称
+func (s *Sink) Name() string {
+	return s.name
+}
+
+// Write 格式化并输出记录
+func (s *Sink) Write(ctx context.Context, record model.DNSRecord) error {
+	data, err := s.formatter.Format(&record)
+	if err != nil {
+		return err
+	}
+	_, err = s.writer.Write(data)
+	return err
+}
+
+// WriteAlert 输出告警，json 格式（含非终端下的 auto）为单行 JSON，其他格式为文本块
+func (s *Sink) WriteAlert(ctx context.Context, alert model.Alert) error {
+	var data []byte
+	if s.json {
+		encoded, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+		data = append(encoded, '\n')
+	} else {
+		text := alert.FormatAlert()
+		if s.color {
+			text = colorRed + text + colorReset
+		}
+		data = []byte(text)
+	}
+	_, err := s.writer.Write(data)
+	return err
+}
+
+// Close 控制台无需关闭
+func (s *Sink) Close() error {
+	return nil
+}
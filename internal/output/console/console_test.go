@@ -0,0 +1,253 @@
+package console
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/utils"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// utcDisplay 测试期间以 UTC 显示时间
+func utcDisplay(t *testing.T) {
+	t.Helper()
+	previous := utils.DisplayLocation()
+	utils.SetDisplayLocation(time.UTC)
+	t.Cleanup(func() { utils.SetDisplayLocation(previous) })
+}
+
+// testRecord 返回一条带应答的示例记录
+func testRecord() model.DNSRecord {
+	return model.DNSRecord{
+		Timestamp:   time.Date(2024, 5, 1, 8, 0, 0, 123000000, time.UTC),
+		QueryName:   "example.com",
+		QueryType:   "A",
+		RCode:       "NOERROR",
+		LatencyMs:   12.34,
+		ProcessName: "curl",
+		ProcessID:   4321,
+		Answers:     []model.DNSAnswer{{Type: "A", Data: "93.184.216.34"}, {Type: "A", Data: "93.184.216.35"}},
+	}
+}
+
+func TestCompactFormat(t *testing.T) {
+	utcDisplay(t)
+	tests := []struct {
+		name   string
+		color  bool
+		modify func(r *model.DNSRecord)
+		want   string
+	}{
+		{
+			"对齐的列",
+			false,
+			func(r *model.DNSRecord) {},
+			"2024-05-01 08:00:00.123  A      NOERROR      12.3ms  curl[4321]               example.com -> 93.184.216.34, 93.184.216.35\n",
+		},
+		{
+			"空值显示为短横线",
+			false,
+			func(r *model.DNSRecord) { *r = model.DNSRecord{Timestamp: r.Timestamp, QueryName: "example.com"} },
+			"2024-05-01 08:00:00.123  -      -                 -  -[0]                     example.com\n",
+		},
+		{
+			"过长的进程名被截断",
+			false,
+			func(r *model.DNSRecord) {
+				r.ProcessName, r.LatencyMs, r.Answers = "very-long-process-name-here.exe", 1500, nil
+			},
+			"2024-05-01 08:00:00.123  A      NOERROR       1.50s  very-long-process~[4321] example.com\n",
+		},
+		{
+			"告警标记",
+			false,
+			func(r *model.DNSRecord) {
+				r.Answers = nil
+				r.Alerts = []model.RecordAlert{{Detector: "intel", Rule: "feed"}}
+			},
+			"2024-05-01 08:00:00.123  A      NOERROR      12.3ms  curl[4321]               example.com [!intel:feed]\n",
+		},
+		{
+			"按查询类型与响应状态着色",
+			true,
+			func(r *model.DNSRecord) {
+				r.QueryType, r.RCode, r.Answers = "TXT", "SERVFAIL", nil
+				r.Alerts = []model.RecordAlert{{Detector: "dga", Rule: "score"}}
+			},
+			colorGray + "2024-05-01 08:00:00.123" + colorReset + "  " +
+				colorYellow + "TXT   " + colorReset + " " +
+				colorRed + "SERVFAIL " + colorReset + "    12.3ms  curl[4321]               example.com " +
+				colorRed + "[!dga:score]" + colorReset + "\n",
+		},
+		{
+			"未列出的查询类型不着色",
+			true,
+			func(r *model.DNSRecord) { r.QueryType, r.RCode, r.Answers = "NS", "NXDOMAIN", nil },
+			colorGray + "2024-05-01 08:00:00.123" + colorReset + "  NS     " +
+				colorYellow + "NXDOMAIN " + colorReset + "    12.3ms  curl[4321]               example.com\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := testRecord()
+			tt.modify(&record)
+			got, err := compactFormatter{color: tt.color}.Format(&record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateFormat(t *testing.T) {
+	utcDisplay(t)
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{"字段与自动换行", "{{.ProcessName}} {{.QueryName}}", "curl example.com\n", false},
+		{"已有换行时不重复", "{{.QueryName}}\n", "example.com\n", false},
+		{"辅助函数", `{{time "15:04:05" .Timestamp}} {{pad .QueryType 5}}|{{upper .ProcessName}} {{answers .}}`, "08:00:00 A    |CURL 93.184.216.34, 93.184.216.35\n", false},
+		{"json 函数", `{{json .Answers}}`, `[{"type":"A","data":"93.184.216.34","ttl":0},{"type":"A","data":"93.184.216.35","ttl":0}]` + "\n", false},
+		{"模板为空", "", "", true},
+		{"模板语法错误", "{{.QueryName", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newTemplateFormatter(tt.template)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("应返回错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			record := testRecord()
+			got, err := f.Format(&record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoFormatAndColor(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	tests := []struct {
+		name      string
+		format    string
+		color     string
+		tty       bool
+		noColor   string
+		wantJSON  bool
+		wantColor bool
+		wantErr   bool
+	}{
+		{"终端中默认 pretty", FormatAuto, "", true, "", false, false, false},
+		{"重定向时默认 json", "", "", false, "", true, false, false},
+		{"always 强制颜色", FormatCompact, ColorAlways, false, "", false, true, false},
+		{"NO_COLOR 关闭自动颜色", FormatCompact, ColorAuto, true, "1", false, false, false},
+		{"never 关闭颜色", FormatCompact, ColorNever, true, "", false, false, false},
+		{"未知颜色模式", FormatCompact, "rainbow", true, "", false, false, true},
+		{"template 缺少模板", FormatTemplate, "", true, "", false, false, true},
+	}
+	record := testRecord()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			f, err := newFormatter(tt.format, Options{Color: tt.color}, file, tt.tty)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("应返回错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := f.Format(&record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isJSON := json.Valid(data); isJSON != tt.wantJSON {
+				t.Errorf("输出是否为 JSON = %v: %q", isJSON, data)
+			}
+			if colored := strings.Contains(string(data), "\x1b["); tt.format == FormatCompact && colored != tt.wantColor {
+				t.Errorf("输出是否着色 = %v: %q", colored, data)
+			}
+		})
+	}
+}
+
+func TestSinkWriteAlert(t *testing.T) {
+	utcDisplay(t)
+	alert := model.Alert{ID: "alert-1", Detector: "intel", Rule: "feed", Severity: model.SeverityHigh, QueryName: "evil.example"}
+	tests := []struct {
+		name   string
+		format string
+		color  string
+		check  func(t *testing.T, out string)
+	}{
+		{"json 格式输出单行 JSON", output.FormatJSON, "", func(t *testing.T, out string) {
+			var got model.Alert
+			if strings.Count(out, "\n") != 1 || json.Unmarshal([]byte(out), &got) != nil || got.ID != "alert-1" {
+				t.Errorf("输出 = %q", out)
+			}
+		}},
+		{"compact 格式输出文本块", FormatCompact, ColorNever, func(t *testing.T, out string) {
+			if !strings.Contains(out, "[!] DNS Alert") || !strings.Contains(out, "evil.example") || strings.Contains(out, colorRed) {
+				t.Errorf("输出 = %q", out)
+			}
+		}},
+		{"着色的文本块", output.FormatPretty, ColorAlways, func(t *testing.T, out string) {
+			if !strings.HasPrefix(out, colorRed) || !strings.HasSuffix(out, colorReset) {
+				t.Errorf("输出 = %q", out)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out")
+			file, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			f, err := newFormatter(tt.format, Options{Color: tt.color}, file, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			color, _ := useColor(tt.color, file, false)
+			sink := &Sink{name: "console", writer: file, formatter: f, json: tt.format == output.FormatJSON, color: color}
+
+			if err := sink.WriteAlert(context.Background(), alert); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, string(data))
+		})
+	}
+}
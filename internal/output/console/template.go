@@ -0,0 +1,55 @@
+package console
+
+import (
+	"bytes"
+	"dnsflux/internal/model"
+	"dnsflux/internal/utils"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFormatter 使用用户提供的 text/template 格式化记录，模板数据为 DNSRecord
+type templateFormatter struct {
+	tmpl *template.Template
+}
+
+// newTemplateFormatter 解析模板，输出末尾没有换行时自动补上
+func newTemplateFormatter(text string) (*templateFormatter, error) {
+	if text == "" {
+		return nil, fmt.Errorf("template 格式需要指定模板")
+	}
+	tmpl, err := template.New("console").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"answers": func(r *model.DNSRecord) string {
+			return r.AnswerSummary()
+		},
+		"time": func(layout string, t time.Time) string {
+			return utils.DisplayTime(t).Format(layout)
+		},
+		"pad":   pad,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("解析控制台模板失败: %w", err)
+	}
+	return &templateFormatter{tmpl: tmpl}, nil
+}
+
+// Format 实现 output.Formatter 接口
+func (f *templateFormatter) Format(record *model.DNSRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := f.tmpl.Execute(&buf, record); err != nil {
+		return nil, err
+	}
+	if buf.Len() == 0 || buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
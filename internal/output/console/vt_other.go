@@ -0,0 +1,10 @@
+//go:build !windows
+
+package console
+
+import "os"
+
+// enableColor 类 Unix 终端原生支持 ANSI 颜色
+func enableColor(file *os.File) bool {
+	return true
+}
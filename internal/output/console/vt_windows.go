@@ -0,0 +1,22 @@
+//go:build windows
+
+package console
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableColor 为 Windows 控制台开启虚拟终端处理，使 ANSI 颜色生效
+func enableColor(file *os.File) bool {
+	handle := windows.Handle(file.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
@@ -0,0 +1,368 @@
+package dnstap
+
+import (
+	"context"
+	"dnsflux/internal/dnswire"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/utils"
+	"dnsflux/pkg/logger"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	dnstap "github.com/dnstap/golang-dnstap"
+	framestream "github.com/farsightsec/golang-framestream"
+	"google.golang.org/protobuf/proto"
+)
+
+// log dnstap 输出日志
+var log = logger.Component("output").With("sink", "dnstap")
+
+func init() {
+	output.Register("dnstap", New)
+}
+
+// contentType dnstap 的 Frame Streams 内容类型
+var contentType = []byte("protobuf:dnstap.Dnstap")
+
+// 输出方式
+const (
+	NetworkFile = "file"
+	NetworkUnix = "unix"
+	NetworkTCP  = "tcp"
+)
+
+// Options dnstap 输出选项
+type Options struct {
+	Network   string          `json:"network"` // file, unix, tcp
+	Path      string          `json:"path"`    // 文件路径或 Unix 套接字路径
+	Address   string          `json:"address"` // TCP 地址 host:port
+	Identity  string          `json:"identity"`
+	Version   string          `json:"version"`
+	Responses *bool           `json:"responses"` // 是否输出 CLIENT_RESPONSE，默认 true
+	Timeout   output.Duration `json:"timeout"`
+}
+
+// Sink dnstap 输出目标
+// 每条记录输出为 CLIENT_QUERY 与 CLIENT_RESPONSE 两条消息，进程信息以 JSON 写入 extra 字段。
+// 文件输出为单向 Frame Streams；Unix 套接字与 TCP 使用双向握手，断开后在下一次写入时重连
+type Sink struct {
+	name      string
+	opts      Options
+	responses bool
+	identity  []byte
+	version   []byte
+
+	file   *os.File
+	conn   net.Conn
+	writer *framestream.Writer
+}
+
+// New 根据配置创建 dnstap 输出目标
+func New(config output.SinkConfig) (output.Sink, error) {
+	_, hostname := utils.HostIdentity()
+	opts := Options{
+		Network:  NetworkFile,
+		Path:     filepath.Join("logs", "dns_records.dnstap"),
+		Identity: hostname,
+		Version:  "dnsflux " + output.Version,
+		Timeout:  output.Duration(5 * time.Second),
+	}
+	if err := config.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+
+	switch opts.Network {
+	case NetworkFile, NetworkUnix:
+		if opts.Path == "" {
+			return nil, fmt.Errorf("dnstap 输出目标 %s 缺少 path", config.Name)
+		}
+	case NetworkTCP:
+		if opts.Address == "" {
+			return nil, fmt.Errorf("dnstap 输出目标 %s 缺少 address", config.Name)
+		}
+	default:
+		return nil, fmt.Errorf("未知的 dnstap 输出方式: %s", opts.Network)
+	}
+
+	s := &Sink{
+		name:      config.Name,
+		opts:      opts,
+		responses: opts.Responses == nil || *opts.Responses,
+		identity:  []byte(opts.Identity),
+		version:   []byte(opts.Version),
+	}
+	if opts.Network == NetworkFile {
+		if err := s.openFile(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Name 返回输出目标名称
+func (s *Sink) Name() string {
+	return s.name
+}
+
+// Write 将记录转换为 dnstap 消息并写入
+func (s *Sink) Write(ctx context.Context, record model.DNSRecord) error {
+	frames, err := s.frames(&record)
+	if err != nil {
+		// 无法编码的记录重试也不会成功，直接跳过
+		log.Debug(fmt.Sprintf("跳过无法编码为 dnstap 的记录 %s: %v", record.QueryName, err))
+		return nil
+	}
+
+	if s.writer == nil {
+		if err := s.dial(ctx); err != nil {
+			return err
+		}
+	}
+	for _, frame := range frames {
+		if _, err := s.writer.WriteFrame(frame); err != nil {
+			s.reset()
+			return fmt.Errorf("写入 dnstap 消息失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Flush 将缓冲的帧写出
+func (s *Sink) Flush(ctx context.Context) error {
+	if s.writer == nil {
+		return nil
+	}
+	if err := s.writer.Flush(); err != nil {
+		s.reset()
+		return fmt.Errorf("刷新 dnstap 输出失败: %w", err)
+	}
+	if s.file != nil {
+		return s.file.Sync()
+	}
+	return nil
+}
+
+// Close 发送 STOP 控制帧并关闭输出
+func (s *Sink) Close() error {
+	if s.writer == nil {
+		return nil
+	}
+	err := s.writer.Close()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	if s.file != nil {
+		if closeErr := s.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	s.writer, s.conn, s.file = nil, nil, nil
+	return err
+}
+
+// openFile 创建新的 dnstap 文件
+// 一个 Frame Streams 文件只能包含一个数据流，已存在的文件按修改时间重命名保留
+func (s *Sink) openFile() error {
+	if err := os.MkdirAll(filepath.Dir(s.opts.Path), 0755); err != nil {
+		return fmt.Errorf("创建 dnstap 输出目录失败: %w", err)
+	}
+	if info, err := os.Stat(s.opts.Path); err == nil && info.Size() > 0 {
+		archived := fmt.Sprintf("%s.%s", s.opts.Path, info.ModTime().Format("20060102-150405"))
+		if err := os.Rename(s.opts.Path, archived); err != nil {
+			return fmt.Errorf("归档已有 dnstap 文件失败: %w", err)
+		}
+	}
+
+	file, err := os.Create(s.opts.Path)
+	if err != nil {
+		return fmt.Errorf("创建 dnstap 文件失败: %w", err)
+	}
+	writer, err := framestream.NewWriter(file, &framestream.WriterOptions{
+		ContentTypes: [][]byte{contentType},
+	})
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("写入 dnstap 文件头失败: %w", err)
+	}
+	s.file, s.writer = file, writer
+	return nil
+}
+
+// dial 连接 dnstap 接收端并完成双向握手
+func (s *Sink) dial(ctx context.Context) error {
+	if s.opts.Network == NetworkFile {
+		return s.openFile()
+	}
+
+	address := s.opts.Address
+	if s.opts.Network == NetworkUnix {
+		address = s.opts.Path
+	}
+	dialer := &net.Dialer{Timeout: s.opts.Timeout.Std()}
+	conn, err := dialer.DialContext(ctx, s.opts.Network, address)
+	if err != nil {
+		return fmt.Errorf("连接 dnstap 接收端 %s 失败: %w", address, err)
+	}
+
+	writer, err := framestream.NewWriter(conn, &framestream.WriterOptions{
+		ContentTypes:  [][]byte{contentType},
+		Bidirectional: true,
+		Timeout:       s.opts.Timeout.Std(),
+	})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("dnstap 握手失败: %w", err)
+	}
+	s.conn, s.writer = conn, writer
+	return nil
+}
+
+// reset 丢弃出错的连接，下一次写入时重新建立
+func (s *Sink) reset() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+		s.writer = nil
+	}
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+		s.writer = nil
+	}
+}
+
+// processExtra 写入 extra 字段的进程信息
+type processExtra struct {
+	RecordID       string `json:"recordId"`
+	ProcessID      uint32 `json:"processId"`
+	ParentPID      uint32 `json:"parentProcessId,omitempty"`
+	ProcessName    string `json:"processName,omitempty"`
+	ProcessPath    string `json:"processPath,omitempty"`
+	ProcessUser    string `json:"processUser,omitempty"`
+	ProcessCmdline string `json:"processCmdline,omitempty"`
+	Source         string `json:"source,omitempty"`
+}
+
+// frames 将记录编码为 dnstap 帧
+func (s *Sink) frames(record *model.DNSRecord) ([][]byte, error) {
+	query, err := dnswire.Query(record)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := json.Marshal(processExtra{
+		RecordID:       record.ID,
+		ProcessID:      record.ProcessID,
+		ParentPID:      record.ParentPID,
+		ProcessName:    record.ProcessName,
+		ProcessPath:    record.ProcessPath,
+		ProcessUser:    record.ProcessUser,
+		ProcessCmdline: record.ProcessCmdline,
+		Source:         record.Source,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	queryTime := record.Timestamp
+	msg := baseMessage(record, dnstap.Message_CLIENT_QUERY)
+	setQueryTime(msg, queryTime)
+	msg.QueryMessage = query
+
+	frame, err := s.marshal(msg, extra)
+	if err != nil {
+		return nil, err
+	}
+	frames := [][]byte{frame}
+
+	if !s.responses {
+		return frames, nil
+	}
+	response, err := dnswire.BuildResponse(record)
+	if err != nil || response == nil {
+		return frames, nil
+	}
+
+	msg = baseMessage(record, dnstap.Message_CLIENT_RESPONSE)
+	setQueryTime(msg, queryTime)
+	responseTime := queryTime.Add(time.Duration(record.LatencyMs * float64(time.Millisecond)))
+	msg.ResponseTimeSec = proto.Uint64(uint64(responseTime.Unix()))
+	msg.ResponseTimeNsec = proto.Uint32(uint32(responseTime.Nanosecond()))
+	msg.ResponseMessage = response
+
+	if frame, err = s.marshal(msg, extra); err != nil {
+		return nil, err
+	}
+	return append(frames, frame), nil
+}
+
+// marshal 将消息封装为 Dnstap 并序列化
+func (s *Sink) marshal(msg *dnstap.Message, extra []byte) ([]byte, error) {
+	return proto.Marshal(&dnstap.Dnstap{
+		Identity: s.identity,
+		Version:  s.version,
+		Extra:    extra,
+		Type:     dnstap.Dnstap_MESSAGE.Enum(),
+		Message:  msg,
+	})
+}
+
+// baseMessage 构建包含地址与协议信息的消息
+func baseMessage(record *model.DNSRecord, msgType dnstap.Message_Type) *dnstap.Message {
+	msg := &dnstap.Message{
+		Type:           msgType.Enum(),
+		SocketProtocol: socketProtocol(record.Transport).Enum(),
+	}
+
+	clientIP := net.ParseIP(record.ClientIP)
+	serverIP := net.ParseIP(record.ServerIP)
+	family := dnstap.SocketFamily_INET
+	if (clientIP != nil && clientIP.To4() == nil) || (serverIP != nil && serverIP.To4() == nil) {
+		family = dnstap.SocketFamily_INET6
+	}
+	msg.SocketFamily = family.Enum()
+	msg.QueryAddress = ipBytes(clientIP, family)
+	msg.ResponseAddress = ipBytes(serverIP, family)
+	if record.ServerPort != 0 {
+		msg.ResponsePort = proto.Uint32(uint32(record.ServerPort))
+	}
+	return msg
+}
+
+// setQueryTime 设置查询时间
+func setQueryTime(msg *dnstap.Message, ts time.Time) {
+	if ts.IsZero() {
+		return
+	}
+	msg.QueryTimeSec = proto.Uint64(uint64(ts.Unix()))
+	msg.QueryTimeNsec = proto.Uint32(uint32(ts.Nanosecond()))
+}
+
+// socketProtocol 将记录的传输协议映射为 dnstap 协议
+func socketProtocol(transport string) dnstap.SocketProtocol {
+	switch transport {
+	case model.TransportTCP:
+		return dnstap.SocketProtocol_TCP
+	case model.TransportDoT:
+		return dnstap.SocketProtocol_DOT
+	case model.TransportDoH:
+		return dnstap.SocketProtocol_DOH
+	default:
+		return dnstap.SocketProtocol_UDP
+	}
+}
+
+// ipBytes 返回指定地址族的地址字节，地址缺失时返回 nil
+func ipBytes(ip net.IP, family dnstap.SocketFamily) []byte {
+	if ip == nil {
+		return nil
+	}
+	if family == dnstap.SocketFamily_INET {
+		return ip.To4()
+	}
+	return ip.To16()
+}
@@ -0,0 +1,187 @@
+package dnstap
+
+import (
+	"context"
+	"dnsflux/internal/dnswire"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	dnstap "github.com/dnstap/golang-dnstap"
+	"google.golang.org/protobuf/proto"
+)
+
+// readFile 按 dnstap-read 的方式读取 Frame Streams 文件中的全部消息
+func readFile(t *testing.T, path string) []*dnstap.Dnstap {
+	t.Helper()
+	input, err := dnstap.NewFrameStreamInputFromFilename(path)
+	if err != nil {
+		t.Fatalf("dnstap-read 无法打开文件: %v", err)
+	}
+	frames := make(chan []byte, 16)
+	go func() {
+		input.ReadInto(frames)
+		close(frames)
+	}()
+
+	var messages []*dnstap.Dnstap
+	for frame := range frames {
+		dt := &dnstap.Dnstap{}
+		if err := proto.Unmarshal(frame, dt); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, dt)
+	}
+	return messages
+}
+
+// fileSink 创建写入临时目录的 dnstap 文件输出目标
+func fileSink(t *testing.T, path string, options map[string]any) output.Sink {
+	t.Helper()
+	opts := map[string]any{"path": path, "identity": "sensor-1", "version": "dnsflux test"}
+	for k, v := range options {
+		opts[k] = v
+	}
+	sink, err := New(output.SinkConfig{Name: "dnstap", Type: "dnstap", Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 8, 0, 0, 250000000, time.UTC)
+	records := []model.DNSRecord{
+		{
+			ID: "rec-1", Source: model.SourceEBPF, Timestamp: ts, Transport: model.TransportUDP,
+			ClientIP: "10.0.0.5", ServerIP: "8.8.8.8", ServerPort: 53, TransactionID: 0x1234,
+			QueryName: "example.com", QueryType: "A", RCode: "NOERROR", LatencyMs: 20,
+			Answers:     []model.DNSAnswer{{Type: "A", Data: "93.184.216.34", TTL: 300}},
+			ProcessName: "curl", ProcessID: 4321, ProcessPath: "/usr/bin/curl",
+		},
+		{
+			ID: "rec-2", Source: model.SourceETW, Timestamp: ts.Add(time.Second), Transport: model.TransportTCP,
+			ClientIP: "fd00::5", ServerIP: "2001:4860:4860::8888", ServerPort: 53, TransactionID: 7,
+			QueryName: "mail.example.org", QueryType: "MX", RCode: "NXDOMAIN", LatencyMs: 5,
+			ProcessName: "outlook.exe", ProcessID: 88,
+		},
+	}
+
+	tests := []struct {
+		name      string
+		responses bool
+		want      []dnstap.Message_Type
+	}{
+		{"查询与响应", true, []dnstap.Message_Type{dnstap.Message_CLIENT_QUERY, dnstap.Message_CLIENT_RESPONSE, dnstap.Message_CLIENT_QUERY, dnstap.Message_CLIENT_RESPONSE}},
+		{"只输出查询", false, []dnstap.Message_Type{dnstap.Message_CLIENT_QUERY, dnstap.Message_CLIENT_QUERY}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dns.dnstap")
+			sink := fileSink(t, path, map[string]any{"responses": tt.responses})
+			for _, record := range records {
+				if err := sink.Write(context.Background(), record); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			messages := readFile(t, path)
+			if len(messages) != len(tt.want) {
+				t.Fatalf("读回 %d 条消息, want %d", len(messages), len(tt.want))
+			}
+			perRecord := len(tt.want) / len(records)
+			for i, dt := range messages {
+				record := records[i/perRecord]
+				msg := dt.GetMessage()
+				if msg.GetType() != tt.want[i] {
+					t.Errorf("消息 %d 类型 = %v, want %v", i, msg.GetType(), tt.want[i])
+				}
+				if string(dt.GetIdentity()) != "sensor-1" || string(dt.GetVersion()) != "dnsflux test" {
+					t.Errorf("消息 %d identity = %q, version = %q", i, dt.GetIdentity(), dt.GetVersion())
+				}
+				if got := net.IP(msg.GetQueryAddress()).String(); got != record.ClientIP {
+					t.Errorf("消息 %d 客户端地址 = %s, want %s", i, got, record.ClientIP)
+				}
+				if got := net.IP(msg.GetResponseAddress()).String(); got != record.ServerIP || msg.GetResponsePort() != 53 {
+					t.Errorf("消息 %d 服务器 = %s:%d", i, got, msg.GetResponsePort())
+				}
+				if got := time.Unix(int64(msg.GetQueryTimeSec()), int64(msg.GetQueryTimeNsec())); !got.Equal(record.Timestamp) {
+					t.Errorf("消息 %d 查询时间 = %v, want %v", i, got, record.Timestamp)
+				}
+
+				var extra processExtra
+				if err := json.Unmarshal(dt.GetExtra(), &extra); err != nil {
+					t.Fatalf("消息 %d extra 无效: %v", i, err)
+				}
+				if extra.RecordID != record.ID || extra.ProcessID != record.ProcessID || extra.ProcessName != record.ProcessName || extra.Source != record.Source {
+					t.Errorf("消息 %d extra = %+v", i, extra)
+				}
+
+				packet := msg.GetQueryMessage()
+				if msg.GetType() == dnstap.Message_CLIENT_RESPONSE {
+					packet = msg.GetResponseMessage()
+					latency := time.Unix(int64(msg.GetResponseTimeSec()), int64(msg.GetResponseTimeNsec())).Sub(record.Timestamp)
+					if latency != time.Duration(record.LatencyMs*float64(time.Millisecond)) {
+						t.Errorf("消息 %d 响应延迟 = %v, want %vms", i, latency, record.LatencyMs)
+					}
+				}
+				parsed, err := dnswire.Parse(packet)
+				if err != nil {
+					t.Fatalf("消息 %d DNS 报文无效: %v", i, err)
+				}
+				if parsed.QueryName != record.QueryName || parsed.QueryType != record.QueryType || parsed.ID != record.TransactionID {
+					t.Errorf("消息 %d 报文 = %s %s #%d", i, parsed.QueryName, parsed.QueryType, parsed.ID)
+				}
+				if parsed.Response && (parsed.RCode != record.RCode || len(parsed.Answers) != len(record.Answers)) {
+					t.Errorf("消息 %d 响应 = %s, %d 条应答", i, parsed.RCode, len(parsed.Answers))
+				}
+
+				// dnstap-read 的默认文本格式能解析每条消息
+				text, ok := dnstap.TextFormat(dt)
+				if !ok || !strings.Contains(string(text), record.QueryName+".") {
+					t.Errorf("消息 %d 文本格式 = %q", i, text)
+				}
+			}
+		})
+	}
+}
+
+func TestFileArchivesExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dns.dnstap")
+	record := model.DNSRecord{Timestamp: time.Now(), ClientIP: "10.0.0.5", ServerIP: "8.8.8.8", QueryName: "example.com", QueryType: "A"}
+	for range 2 {
+		sink := fileSink(t, path, map[string]any{"responses": false})
+		if err := sink.Write(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 一个 Frame Streams 文件只能包含一个数据流，重新打开时原文件被重命名保留
+	matches, err := filepath.Glob(path + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("got %v, want 当前文件与一个归档文件", matches)
+	}
+	for _, file := range matches {
+		if info, err := os.Stat(file); err != nil || info.Size() == 0 {
+			t.Fatalf("%s 为空: %v", file, err)
+		}
+		if got := len(readFile(t, file)); got != 1 {
+			t.Errorf("%s 中有 %d 条消息, want 1", file, got)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package output
+
+import (
+	"dnsflux/internal/model"
+	"net/url"
+	"strings"
+)
+
+// Filter 输出目标的记录过滤条件，空条件表示不过滤
+type Filter struct {
+	QueryTypes       []string `json:"queryTypes,omitempty" yaml:"queryTypes,omitempty"`
+	Domains          []string `json:"domains,omitempty" yaml:"domains,omitempty"`
+	ExcludeDomains   []string `json:"excludeDomains,omitempty" yaml:"excludeDomains,omitempty"`
+	Processes        []string `json:"processes,omitempty" yaml:"processes,omitempty"`
+	ExcludeProcesses []string `json:"excludeProcesses,omitempty" yaml:"excludeProcesses,omitempty"`
+}
+
+// FilterFromValues 从 URL 查询参数解析过滤条件，各字段以逗号分隔，键名可带前缀（如 filter.）
+func FilterFromValues(values url.Values, prefix string) Filter {
+	return Filter{
+		QueryTypes:       splitList(values.Get(prefix + "queryTypes")),
+		Domains:          splitList(values.Get(prefix + "domains")),
+		ExcludeDomains:   splitList(values.Get(prefix + "excludeDomains")),
+		Processes:        splitList(values.Get(prefix + "processes")),
+		ExcludeProcesses: splitList(values.Get(prefix + "excludeProcesses")),
+	}
+}
+
+// Match 判断记录是否满足过滤条件
+func (f *Filter) Match(record *model.DNSRecord) bool {
+	if len(f.QueryTypes) > 0 && !containsFold(f.QueryTypes, record.QueryType) {
+		return false
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(record.QueryName), ".")
+	if len(f.Domains) > 0 && !matchDomain(f.Domains, name) {
+		return false
+	}
+	if matchDomain(f.ExcludeDomains, name) {
+		return false
+	}
+
+	if len(f.Processes) > 0 && !containsFold(f.Processes, record.ProcessName) {
+		return false
+	}
+	if containsFold(f.ExcludeProcesses, record.ProcessName) {
+		return false
+	}
+	return true
+}
+
+// MatchAlert 判断告警是否满足过滤条件，按首次告警记录的查询与进程判断
+func (f *Filter) MatchAlert(alert *model.Alert) bool {
+	return f.Match(&model.DNSRecord{
+		QueryName:   alert.QueryName,
+		QueryType:   alert.QueryType,
+		ProcessName: alert.ProcessName,
+	})
+}
+
+// IsEmpty 判断过滤条件是否为空
+func (f *Filter) IsEmpty() bool {
+	return len(f.QueryTypes) == 0 && len(f.Domains) == 0 && len(f.ExcludeDomains) == 0 &&
+		len(f.Processes) == 0 && len(f.ExcludeProcesses) == 0
+}
+
+// matchDomain 判断域名是否等于列表中的某个域名或为其子域名
+func matchDomain(domains []string, name string) bool {
+	for _, domain := range domains {
+		domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+		if domain == "" {
+			continue
+		}
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold 忽略大小写判断列表是否包含指定值
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
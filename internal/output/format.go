@@ -0,0 +1,79 @@
+package output
+
+import (
+	"dnsflux/internal/model"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Formatter 将 DNS 记录格式化为字节序列
+type Formatter interface {
+	Format(record *model.DNSRecord) ([]byte, error)
+}
+
+// FormatterFunc 函数形式的格式化器
+type FormatterFunc func(record *model.DNSRecord) ([]byte, error)
+
+// Format 实现 Formatter 接口
+func (f FormatterFunc) Format(record *model.DNSRecord) ([]byte, error) {
+	return f(record)
+}
+
+// 内置格式
+const (
+	FormatJSON   = "json"
+	FormatPretty = "pretty"
+)
+
+var (
+	formatterMu sync.RWMutex
+	formatters  = map[string]Formatter{
+		FormatJSON:   FormatterFunc(formatJSON),
+		FormatPretty: FormatterFunc(formatPretty),
+	}
+)
+
+// RegisterFormatter 注册命名格式化器
+func RegisterFormatter(name string, formatter Formatter) {
+	formatterMu.Lock()
+	defer formatterMu.Unlock()
+	formatters[name] = formatter
+}
+
+// NewFormatter 根据名称获取格式化器，名称为空时使用默认格式
+func NewFormatter(name, defaultName string) (Formatter, error) {
+	if name == "" {
+		name = defaultName
+	}
+
+	formatterMu.RLock()
+	defer formatterMu.RUnlock()
+
+	formatter, ok := formatters[name]
+	if !ok {
+		names := make([]string, 0, len(formatters))
+		for n := range formatters {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("未知的输出格式 %q，可用格式: %s", name, strings.Join(names, ", "))
+	}
+	return formatter, nil
+}
+
+// formatJSON 单行 JSON 格式
+func formatJSON(record *model.DNSRecord) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// formatPretty 多行文本块格式
+func formatPretty(record *model.DNSRecord) ([]byte, error) {
+	return []byte(record.FormatDNSRecord()), nil
+}
@@ -0,0 +1,95 @@
+package httpsink
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAlertBatches(t *testing.T) {
+	firstSeen := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		options map[string]any
+		check   func(t *testing.T, body []byte)
+	}{
+		{"通用 JSON 数组", map[string]any{}, func(t *testing.T, body []byte) {
+			var alerts []model.Alert
+			if err := json.Unmarshal(body, &alerts); err != nil || len(alerts) != 1 || alerts[0].ID != "alert-1" {
+				t.Errorf("告警数组错误: %v %s", err, body)
+			}
+		}},
+		{"Splunk sourcetype", map[string]any{"target": TargetSplunk, "splunkToken": "t", "index": "dns"}, func(t *testing.T, body []byte) {
+			events := decodeJSONStream(t, body)
+			if len(events) != 1 || events[0]["sourcetype"] != "dnsflux:alert" || events[0]["index"] != "dns" ||
+				events[0]["time"] != float64(firstSeen.Unix()) {
+				t.Errorf("告警事件错误: %v", events)
+			}
+		}},
+		{"Elasticsearch 告警索引", map[string]any{"target": TargetElasticsearch, "index": "dns-logs"}, func(t *testing.T, body []byte) {
+			lines := decodeJSONStream(t, body)
+			action, _ := lines[0]["create"].(map[string]any)
+			if len(lines) != 2 || action["_index"] != "dns-logs-alerts" || action["_id"] != "alert-1" ||
+				lines[1]["@timestamp"] != "2024-05-01T08:00:00.000Z" {
+				t.Errorf("告警操作错误: %v", lines)
+			}
+		}},
+		{"Loki 告警流", map[string]any{"target": TargetLoki}, func(t *testing.T, body []byte) {
+			var push struct {
+				Streams []struct {
+					Stream map[string]string `json:"stream"`
+				} `json:"streams"`
+			}
+			if err := json.Unmarshal(body, &push); err != nil || len(push.Streams) != 1 ||
+				push.Streams[0].Stream["type"] != "alert" || push.Streams[0].Stream["severity"] != model.SeverityHigh {
+				t.Errorf("告警流错误: %v %s", err, body)
+			}
+		}},
+		{"告警模板", map[string]any{"template": "{{len .Records}}", "alertTemplate": `{"alerts":{{json .Alerts}}}`}, func(t *testing.T, body []byte) {
+			var alerts struct{ Alerts []model.Alert }
+			if err := json.Unmarshal(body, &alerts); err != nil || len(alerts.Alerts) != 1 {
+				t.Errorf("告警模板请求体错误: %v %s", err, body)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, server := newCollector(t)
+			tt.options["url"] = server.URL
+			sink := sinkFor(t, output.SinkConfig{Alerts: output.AlertsInclude, Options: tt.options})
+			writeAll(t, sink, sequence(1))
+			alert := model.Alert{
+				ID:        "alert-1",
+				Detector:  "intel",
+				Severity:  model.SeverityHigh,
+				Hostname:  "host-1",
+				QueryName: "evil.example",
+				FirstSeen: firstSeen,
+				LastSeen:  firstSeen,
+			}
+			if err := sink.WriteAlert(context.Background(), alert); err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// 记录与告警分别成批发送
+			requests := c.received()
+			if len(requests) != 2 {
+				t.Fatalf("got %d 个请求, want 2", len(requests))
+			}
+			tt.check(t, requests[1].body)
+		})
+	}
+}
+
+func TestAlertTemplateRequired(t *testing.T) {
+	config := output.SinkConfig{Name: "test", Alerts: output.AlertsInclude, Options: map[string]any{"url": "http://localhost", "template": "{{.Records}}"}}
+	if _, err := New(config); err == nil {
+		t.Fatal("使用 template 输出告警时缺少 alertTemplate 应返回错误")
+	}
+}
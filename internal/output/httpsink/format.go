@@ -0,0 +1,317 @@
+package httpsink
+
+import (
+	"bytes"
+	"dnsflux/internal/model"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// 目标类型
+const (
+	TargetGeneric       = "generic"
+	TargetSplunk        = "splunk"
+	TargetElasticsearch = "elasticsearch"
+	TargetLoki          = "loki"
+)
+
+// encoder 将一批记录或告警编码为请求体
+type encoder interface {
+	ContentType() string
+	Encode(records []model.DNSRecord) ([]byte, error)
+	EncodeAlerts(alerts []model.Alert) ([]byte, error)
+}
+
+// newEncoder 根据目标类型与模板创建编码器
+func newEncoder(opts Options) (encoder, error) {
+	if opts.Template != "" {
+		tmpl, err := parseTemplate("body", opts.Template)
+		if err != nil {
+			return nil, fmt.Errorf("解析请求体模板失败: %w", err)
+		}
+		contentType := opts.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		e := &templateEncoder{tmpl: tmpl, contentType: contentType}
+		if opts.AlertTemplate != "" {
+			if e.alertTmpl, err = parseTemplate("alerts", opts.AlertTemplate); err != nil {
+				return nil, fmt.Errorf("解析告警请求体模板失败: %w", err)
+			}
+		}
+		return e, nil
+	}
+
+	switch opts.Target {
+	case TargetGeneric, "":
+		return genericEncoder{}, nil
+	case TargetSplunk:
+		return splunkEncoder{sourcetype: opts.SourceType, alertSourcetype: opts.AlertSourceType, index: opts.Index}, nil
+	case TargetElasticsearch:
+		index := opts.Index
+		if index == "" {
+			index = "dnsflux"
+		}
+		alertIndex := opts.AlertIndex
+		if alertIndex == "" {
+			alertIndex = index + "-alerts"
+		}
+		return elasticEncoder{index: index, alertIndex: alertIndex}, nil
+	case TargetLoki:
+		return lokiEncoder{labels: opts.Labels}, nil
+	default:
+		return nil, fmt.Errorf("未知的 HTTP 目标类型: %s", opts.Target)
+	}
+}
+
+// parseTemplate 解析请求体模板
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"unixNano": func(r model.DNSRecord) string {
+			return strconv.FormatInt(r.Timestamp.UnixNano(), 10)
+		},
+	}).Parse(text)
+}
+
+// genericEncoder 以 JSON 数组发送记录或告警
+type genericEncoder struct{}
+
+func (genericEncoder) ContentType() string { return "application/json" }
+
+func (genericEncoder) Encode(records []model.DNSRecord) ([]byte, error) {
+	return json.Marshal(records)
+}
+
+func (genericEncoder) EncodeAlerts(alerts []model.Alert) ([]byte, error) {
+	return json.Marshal(alerts)
+}
+
+// splunkEncoder Splunk HEC 事件格式，多个事件对象直接拼接
+type splunkEncoder struct {
+	sourcetype      string
+	alertSourcetype string
+	index           string
+}
+
+func (splunkEncoder) ContentType() string { return "application/json" }
+
+func (e splunkEncoder) Encode(records []model.DNSRecord) ([]byte, error) {
+	sourcetype := e.sourcetype
+	if sourcetype == "" {
+		sourcetype = "dnsflux:dns"
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range records {
+		event := map[string]any{
+			"time":       float64(records[i].Timestamp.UnixMilli()) / 1000,
+			"host":       records[i].Hostname,
+			"source":     "dnsflux",
+			"sourcetype": sourcetype,
+			"event":      &records[i],
+		}
+		if e.index != "" {
+			event["index"] = e.index
+		}
+		if err := enc.Encode(event); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (e splunkEncoder) EncodeAlerts(alerts []model.Alert) ([]byte, error) {
+	sourcetype := e.alertSourcetype
+	if sourcetype == "" {
+		sourcetype = "dnsflux:alert"
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range alerts {
+		event := map[string]any{
+			"time":       float64(alerts[i].FirstSeen.UnixMilli()) / 1000,
+			"host":       alerts[i].Hostname,
+			"source":     "dnsflux",
+			"sourcetype": sourcetype,
+			"event":      &alerts[i],
+		}
+		if e.index != "" {
+			event["index"] = e.index
+		}
+		if err := enc.Encode(event); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// elasticEncoder Elasticsearch/OpenSearch _bulk NDJSON 格式，告警写入单独的索引
+type elasticEncoder struct {
+	index      string
+	alertIndex string
+}
+
+func (elasticEncoder) ContentType() string { return "application/x-ndjson" }
+
+func (e elasticEncoder) Encode(records []model.DNSRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range records {
+		action := map[string]any{
+			"create": map[string]string{"_index": e.index, "_id": records[i].ID},
+		}
+		if err := enc.Encode(action); err != nil {
+			return nil, err
+		}
+		doc := struct {
+			*model.DNSRecord
+			TimestampAlias string `json:"@timestamp"`
+		}{&records[i], records[i].Timestamp.UTC().Format("2006-01-02T15:04:05.000Z07:00")}
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (e elasticEncoder) EncodeAlerts(alerts []model.Alert) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range alerts {
+		action := map[string]any{
+			"create": map[string]string{"_index": e.alertIndex, "_id": alerts[i].ID},
+		}
+		if err := enc.Encode(action); err != nil {
+			return nil, err
+		}
+		doc := struct {
+			*model.Alert
+			TimestampAlias string `json:"@timestamp"`
+		}{&alerts[i], alerts[i].FirstSeen.UTC().Format("2006-01-02T15:04:05.000Z07:00")}
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// lokiEncoder Grafana Loki push API 格式，记录按主机与查询类型分流，告警按主机与级别分流
+type lokiEncoder struct {
+	labels map[string]string
+}
+
+func (lokiEncoder) ContentType() string { return "application/json" }
+
+func (e lokiEncoder) Encode(records []model.DNSRecord) ([]byte, error) {
+	streams := make(map[string]*lokiStream)
+	order := make([]string, 0)
+
+	for i := range records {
+		labels := map[string]string{"job": "dnsflux"}
+		for k, v := range e.labels {
+			labels[k] = v
+		}
+		labels["host"] = records[i].Hostname
+		labels["query_type"] = records[i].QueryType
+
+		key := labels["host"] + "\x00" + labels["query_type"]
+		s, ok := streams[key]
+		if !ok {
+			s = &lokiStream{Stream: labels}
+			streams[key] = s
+			order = append(order, key)
+		}
+
+		line, err := json.Marshal(&records[i])
+		if err != nil {
+			return nil, err
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(records[i].Timestamp.UnixNano(), 10), string(line)})
+	}
+
+	return e.push(streams, order)
+}
+
+func (e lokiEncoder) EncodeAlerts(alerts []model.Alert) ([]byte, error) {
+	streams := make(map[string]*lokiStream)
+	order := make([]string, 0)
+
+	for i := range alerts {
+		labels := map[string]string{"job": "dnsflux"}
+		for k, v := range e.labels {
+			labels[k] = v
+		}
+		labels["host"] = alerts[i].Hostname
+		labels["type"] = "alert"
+		labels["severity"] = alerts[i].Severity
+
+		key := labels["host"] + "\x00" + labels["severity"]
+		s, ok := streams[key]
+		if !ok {
+			s = &lokiStream{Stream: labels}
+			streams[key] = s
+			order = append(order, key)
+		}
+
+		line, err := json.Marshal(&alerts[i])
+		if err != nil {
+			return nil, err
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(alerts[i].FirstSeen.UnixNano(), 10), string(line)})
+	}
+	return e.push(streams, order)
+}
+
+// lokiStream Loki push API 中的一个日志流
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// push 按出现顺序组装 push API 请求体
+func (lokiEncoder) push(streams map[string]*lokiStream, order []string) ([]byte, error) {
+	body := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{Streams: make([]*lokiStream, 0, len(order))}
+	for _, key := range order {
+		body.Streams = append(body.Streams, streams[key])
+	}
+	return json.Marshal(body)
+}
+
+// templateEncoder 使用 text/template 生成请求体，模板数据为 {{.Records}}，告警模板的数据为 {{.Alerts}}
+type templateEncoder struct {
+	tmpl        *template.Template
+	alertTmpl   *template.Template
+	contentType string
+}
+
+func (e *templateEncoder) ContentType() string { return e.contentType }
+
+func (e *templateEncoder) Encode(records []model.DNSRecord) ([]byte, error) {
+	var buf strings.Builder
+	if err := e.tmpl.Execute(&buf, struct{ Records []model.DNSRecord }{records}); err != nil {
+		return nil, fmt.Errorf("执行请求体模板失败: %w", err)
+	}
+	return []byte(buf.String()), nil
+}
+
+func (e *templateEncoder) EncodeAlerts(alerts []model.Alert) ([]byte, error) {
+	if e.alertTmpl == nil {
+		return nil, fmt.Errorf("未指定告警请求体模板 alertTemplate")
+	}
+	var buf strings.Builder
+	if err := e.alertTmpl.Execute(&buf, struct{ Alerts []model.Alert }{alerts}); err != nil {
+		return nil, fmt.Errorf("执行告警请求体模板失败: %w", err)
+	}
+	return []byte(buf.String()), nil
+}
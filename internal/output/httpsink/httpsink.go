@@ -0,0 +1,397 @@
+package httpsink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+func init() {
+	output.Register("http", New)
+}
+
+// Options HTTP 输出选项
+type Options struct {
+	URL         string            `json:"url"`
+	Method      string            `json:"method"`
+	Target      string            `json:"target"` // generic, splunk, elasticsearch, loki
+	Headers     map[string]string `json:"headers"`
+	BearerToken string            `json:"bearerToken"`
+	SplunkToken string            `json:"splunkToken"`
+	Username    string            `json:"username"`
+	Password    string            `json:"password"`
+	Gzip        bool              `json:"gzip"`
+
+	// 批量发送
+	BatchSize int `json:"batchSize"`
+
+	// 请求体
+	Template        string            `json:"template"`
+	AlertTemplate   string            `json:"alertTemplate"` // 使用 template 时告警请求体的模板，数据为 {{.Alerts}}
+	ContentType     string            `json:"contentType"`
+	Index           string            `json:"index"`
+	AlertIndex      string            `json:"alertIndex"` // Elasticsearch 告警索引，默认为 index 加 -alerts
+	SourceType      string            `json:"sourceType"`
+	AlertSourceType string            `json:"alertSourceType"` // Splunk 告警事件的 sourcetype，默认 dnsflux:alert
+	Labels          map[string]string `json:"labels"`
+
+	// 重试与暂存
+	Timeout     output.Duration `json:"timeout"`
+	MaxRetries  int             `json:"-"` // 取自输出目标通用配置 maxRetries
+	MaxBackoff  output.Duration `json:"maxBackoff"`
+	SpoolDir    string          `json:"spoolDir"`
+	SpoolMaxMB  int             `json:"spoolMaxMB"`
+	ReplayBatch int             `json:"replayBatch"`
+
+	TLS output.TLSOptions `json:"tls"`
+}
+
+// permanentError 不可重试的错误（如 4xx 响应）
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Sink HTTP 批量输出目标
+// 记录与告警分别在内存中攒批，达到批量大小或刷新周期时发送；发送失败时按指数退避重试，
+// 仍失败则将请求体暂存到磁盘，在后续刷新时按顺序重发
+type Sink struct {
+	name    string
+	opts    Options
+	client  *http.Client
+	encoder encoder
+	spool   *spool
+	pending []model.DNSRecord
+	alerts  []model.Alert
+
+	// 批次已满时在 Write 中发送失败的错误，由下一次 Flush 或 Close 返回
+	lastErr error
+}
+
+// New 根据配置创建 HTTP 输出目标
+func New(config output.SinkConfig) (output.Sink, error) {
+	opts := Options{
+		Method:      http.MethodPost,
+		Target:      TargetGeneric,
+		BatchSize:   100,
+		MaxRetries:  3,
+		MaxBackoff:  output.Duration(30 * time.Second),
+		Timeout:     output.Duration(10 * time.Second),
+		SpoolMaxMB:  256,
+		ReplayBatch: 10,
+	}
+	if err := config.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+	if opts.URL == "" {
+		return nil, fmt.Errorf("HTTP 输出目标 %s 缺少 url", config.Name)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	if config.MaxRetries > 0 {
+		opts.MaxRetries = config.MaxRetries
+	}
+	if config.Alerts != "" && opts.Template != "" && opts.AlertTemplate == "" {
+		return nil, fmt.Errorf("HTTP 输出目标 %s 使用 template 时输出告警需要指定 alertTemplate", config.Name)
+	}
+
+	enc, err := newEncoder(opts)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := opts.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+	sp, err := newSpool(opts.SpoolDir, opts.SpoolMaxMB)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &Sink{
+		name:    config.Name,
+		opts:    opts,
+		client:  &http.Client{Timeout: opts.Timeout.Std(), Transport: transport},
+		encoder: enc,
+		spool:   sp,
+		pending: make([]model.DNSRecord, 0, opts.BatchSize),
+	}, nil
+}
+
+// Name 返回输出目标名称
+func (s *Sink) Name() string {
+	return s.name
+}
+
+// Write 将记录加入当前批次，批次已满时立即发送
+// 发送失败的批次已被暂存或丢弃，因此此处不返回错误，避免记录被重复加入批次；
+// 错误保留到下一次 Flush 返回，使运行状态能反映丢弃的批次
+func (s *Sink) Write(ctx context.Context, record model.DNSRecord) error {
+	s.pending = append(s.pending, record)
+	if len(s.pending) >= s.opts.BatchSize {
+		s.keepError(s.flush(ctx))
+	}
+	return nil
+}
+
+// WriteAlert 将告警加入当前告警批次，批次已满时立即发送
+func (s *Sink) WriteAlert(ctx context.Context, alert model.Alert) error {
+	s.alerts = append(s.alerts, alert)
+	if len(s.alerts) >= s.opts.BatchSize {
+		s.keepError(s.flush(ctx))
+	}
+	return nil
+}
+
+// keepError 保留最近一次发送错误
+func (s *Sink) keepError(err error) {
+	if err != nil {
+		s.lastErr = err
+	}
+}
+
+// takeError 返回并清除保留的发送错误
+func (s *Sink) takeError() error {
+	err := s.lastErr
+	s.lastErr = nil
+	return err
+}
+
+// Flush 重发暂存的请求体并发送当前批次，同时返回此前批次已满时发送失败的错误
+func (s *Sink) Flush(ctx context.Context) error {
+	earlier := s.takeError()
+	return errors.Join(earlier, s.flush(ctx))
+}
+
+// flush 重发暂存的请求体并发送当前批次
+func (s *Sink) flush(ctx context.Context) error {
+	if err := s.replay(ctx); err != nil {
+		// 目标仍不可用，当前批次直接暂存保证顺序
+		if s.spool != nil {
+			s.sendPendingToSpool()
+		}
+		return err
+	}
+	return s.sendPending(ctx)
+}
+
+// Close 发送剩余记录与告警，失败时写入暂存
+func (s *Sink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout.Std())
+	defer cancel()
+	err := errors.Join(s.takeError(), s.sendPending(ctx))
+	s.client.CloseIdleConnections()
+	return err
+}
+
+// sendPending 发送当前的记录批次与告警批次
+func (s *Sink) sendPending(ctx context.Context) error {
+	var errs []error
+	if n := len(s.pending); n > 0 {
+		body, err := s.encoder.Encode(s.pending)
+		s.pending = s.pending[:0]
+		errs = append(errs, s.deliver(ctx, body, err, n, "记录"))
+	}
+	if n := len(s.alerts); n > 0 {
+		body, err := s.encoder.EncodeAlerts(s.alerts)
+		s.alerts = s.alerts[:0]
+		errs = append(errs, s.deliver(ctx, body, err, n, "告警"))
+	}
+	return errors.Join(errs...)
+}
+
+// deliver 发送编码后的请求体，重试后仍失败时写入暂存
+// 未能暂存的批次被丢弃，错误中注明丢弃的条数
+func (s *Sink) deliver(ctx context.Context, body []byte, err error, n int, kind string) error {
+	if err != nil {
+		return fmt.Errorf("编码请求体失败，丢弃 %d 条%s: %w", n, kind, err)
+	}
+
+	err = s.sendWithRetry(ctx, body)
+	if err == nil {
+		return nil
+	}
+	var perm *permanentError
+	if !errors.As(err, &perm) && s.spool != nil {
+		spoolErr := s.spool.put(body)
+		if spoolErr == nil {
+			return err
+		}
+		err = errors.Join(err, spoolErr)
+	}
+	return fmt.Errorf("发送失败，丢弃 %d 条%s: %w", n, kind, err)
+}
+
+// sendPendingToSpool 将当前批次直接写入暂存
+func (s *Sink) sendPendingToSpool() {
+	if len(s.pending) > 0 {
+		body, err := s.encoder.Encode(s.pending)
+		s.pending = s.pending[:0]
+		if err == nil {
+			s.spool.put(body)
+		}
+	}
+	if len(s.alerts) > 0 {
+		body, err := s.encoder.EncodeAlerts(s.alerts)
+		s.alerts = s.alerts[:0]
+		if err == nil {
+			s.spool.put(body)
+		}
+	}
+}
+
+// replay 按顺序重发暂存的请求体，遇到失败即停止
+func (s *Sink) replay(ctx context.Context) error {
+	if s.spool == nil {
+		return nil
+	}
+	for i, path := range s.spool.files() {
+		if i >= s.opts.ReplayBatch {
+			break
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			os.Remove(path)
+			continue
+		}
+		if err := s.send(ctx, body); err != nil {
+			var perm *permanentError
+			if errors.As(err, &perm) {
+				// 目标拒绝该请求体，重发也不会成功
+				os.Remove(path)
+				continue
+			}
+			return fmt.Errorf("重发暂存数据失败: %w", err)
+		}
+		os.Remove(path)
+	}
+	return nil
+}
+
+// sendWithRetry 发送请求体，失败时按指数退避重试
+func (s *Sink) sendWithRetry(ctx context.Context, body []byte) error {
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err := s.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) || attempt >= s.opts.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+		if backoff > s.opts.MaxBackoff.Std() {
+			backoff = s.opts.MaxBackoff.Std()
+		}
+	}
+}
+
+// send 发送一次 HTTP 请求
+func (s *Sink) send(ctx context.Context, body []byte) error {
+	payload := body
+	if s.opts.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		payload = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, s.opts.Method, s.opts.URL, bytes.NewReader(payload))
+	if err != nil {
+		return &permanentError{fmt.Errorf("创建请求失败: %w", err)}
+	}
+	req.Header.Set("Content-Type", s.encoder.ContentType())
+	req.Header.Set("User-Agent", "dnsflux/"+output.Version)
+	if s.opts.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	switch {
+	case s.opts.SplunkToken != "":
+		req.Header.Set("Authorization", "Splunk "+s.opts.SplunkToken)
+	case s.opts.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+s.opts.BearerToken)
+	case s.opts.Username != "":
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+	}
+	for k, v := range s.opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 %s 失败: %w", s.opts.URL, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if s.opts.Target == TargetElasticsearch {
+			return checkBulkResponse(respBody)
+		}
+		return nil
+	}
+
+	err = fmt.Errorf("请求 %s 返回 %s: %s", s.opts.URL, resp.Status, bytes.TrimSpace(respBody))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{err}
+	}
+	return err
+}
+
+// checkBulkResponse 检查 _bulk 响应中的逐条错误，已存在的文档（409）视为成功
+func checkBulkResponse(body []byte) error {
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || !resp.Errors {
+		return nil
+	}
+	failed := 0
+	var first string
+	for _, item := range resp.Items {
+		for _, result := range item {
+			if result.Status >= 300 && result.Status != http.StatusConflict {
+				failed++
+				if first == "" {
+					first = string(result.Error)
+				}
+			}
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return &permanentError{fmt.Errorf("_bulk 请求中 %d 条记录写入失败: %s", failed, first)}
+}
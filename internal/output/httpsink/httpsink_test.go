@@ -0,0 +1,606 @@
+package httpsink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// capturedRequest 模拟服务端收到的请求，请求体已解压
+type capturedRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// collector 模拟 SIEM 接收端，按顺序记录请求，status 决定响应状态码
+type collector struct {
+	mu       sync.Mutex
+	requests []capturedRequest
+	status   atomic.Int32
+	response string
+}
+
+// newCollector 启动模拟接收端
+func newCollector(t *testing.T) (*collector, *httptest.Server) {
+	t.Helper()
+	c := &collector{}
+	c.status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(c.serve))
+	t.Cleanup(server.Close)
+	return c, server
+}
+
+func (c *collector) serve(w http.ResponseWriter, r *http.Request) {
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reader = zr
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	c.requests = append(c.requests, capturedRequest{r.Method, r.URL.Path, r.Header.Clone(), body})
+	response := c.response
+	c.mu.Unlock()
+
+	w.WriteHeader(int(c.status.Load()))
+	io.WriteString(w, response)
+}
+
+// received 返回已收到的请求
+func (c *collector) received() []capturedRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]capturedRequest(nil), c.requests...)
+}
+
+// sinkFor 以测试名称创建 HTTP 输出目标，config 中只需填写选项
+func sinkFor(t *testing.T, config output.SinkConfig) *Sink {
+	t.Helper()
+	config.Name = "test"
+	config.Type = "http"
+	sink, err := New(config)
+	if err != nil {
+		t.Fatalf("创建 HTTP 输出目标失败: %v", err)
+	}
+	return sink.(*Sink)
+}
+
+// sequence 构造 rec-1 到 rec-n 的记录，时间按秒递增、查询类型 A 与 AAAA 交替，
+// 用于检查批次拆分、分流与重放顺序
+func sequence(n int) []model.DNSRecord {
+	records := make([]model.DNSRecord, n)
+	for i := range records {
+		records[i] = model.DNSRecord{
+			ID:          fmt.Sprintf("rec-%d", i+1),
+			Timestamp:   time.Date(2024, 5, 1, 8, 30, i, 0, time.UTC),
+			Hostname:    "host-1",
+			ClientIP:    "10.0.0.5",
+			QueryName:   fmt.Sprintf("q%d.example.com", i+1),
+			QueryType:   []string{"A", "AAAA"}[i%2],
+			ProcessID:   1234,
+			ProcessName: "curl",
+		}
+	}
+	return records
+}
+
+// writeAll 依次写入记录，写入本身不应失败
+func writeAll(t *testing.T, sink *Sink, records []model.DNSRecord) {
+	t.Helper()
+	for _, record := range records {
+		if err := sink.Write(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGenericBatching(t *testing.T) {
+	c, server := newCollector(t)
+	sink := sinkFor(t, output.SinkConfig{Options: map[string]any{
+		"url":         server.URL + "/ingest",
+		"batchSize":   2,
+		"bearerToken": "secret",
+		"headers":     map[string]string{"X-Tenant": "blue"},
+	}})
+
+	writeAll(t, sink, sequence(3))
+	if got := len(c.received()); got != 1 {
+		t.Fatalf("批次已满时应发送 1 个请求, got %d", got)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := c.received()
+	if len(requests) != 2 {
+		t.Fatalf("关闭时应发送剩余批次, got %d 个请求", len(requests))
+	}
+	var sizes []int
+	for _, req := range requests {
+		if req.method != http.MethodPost || req.path != "/ingest" {
+			t.Errorf("请求为 %s %s", req.method, req.path)
+		}
+		if got := req.header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := req.header.Get("X-Tenant"); got != "blue" {
+			t.Errorf("自定义请求头 X-Tenant = %q", got)
+		}
+		if got := req.header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		if !strings.HasPrefix(req.header.Get("User-Agent"), "dnsflux/") {
+			t.Errorf("User-Agent = %q", req.header.Get("User-Agent"))
+		}
+		var records []model.DNSRecord
+		if err := json.Unmarshal(req.body, &records); err != nil {
+			t.Fatalf("请求体不是记录数组: %v", err)
+		}
+		sizes = append(sizes, len(records))
+	}
+	if sizes[0] != 2 || sizes[1] != 1 {
+		t.Errorf("批次大小为 %v, want [2 1]", sizes)
+	}
+}
+
+func TestGzipAndBasicAuth(t *testing.T) {
+	c, server := newCollector(t)
+	sink := sinkFor(t, output.SinkConfig{Options: map[string]any{
+		"url":      server.URL,
+		"gzip":     true,
+		"username": "user",
+		"password": "pass",
+	}})
+	writeAll(t, sink, sequence(2))
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := c.received()
+	if len(requests) != 1 {
+		t.Fatalf("got %d 个请求, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("缺少 Content-Encoding: gzip")
+	}
+	if user, pass, ok := (&http.Request{Header: req.header}).BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Errorf("Basic 认证错误: %q %q %v", user, pass, ok)
+	}
+	var records []model.DNSRecord
+	if err := json.Unmarshal(req.body, &records); err != nil || len(records) != 2 {
+		t.Fatalf("解压后的请求体错误: %v %s", err, req.body)
+	}
+}
+
+func TestSplunkHEC(t *testing.T) {
+	c, server := newCollector(t)
+	sink := sinkFor(t, output.SinkConfig{Options: map[string]any{
+		"url":         server.URL + "/services/collector/event",
+		"target":      TargetSplunk,
+		"splunkToken": "hec-token",
+		"index":       "dns",
+		"batchSize":   10,
+	}})
+	writeAll(t, sink, sequence(2))
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := c.received()
+	if len(requests) != 1 {
+		t.Fatalf("got %d 个请求, want 1", len(requests))
+	}
+	if got := requests[0].header.Get("Authorization"); got != "Splunk hec-token" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	events := decodeJSONStream(t, requests[0].body)
+	if len(events) != 2 {
+		t.Fatalf("got %d 个事件, want 2", len(events))
+	}
+	for i, event := range events {
+		if event["sourcetype"] != "dnsflux:dns" || event["index"] != "dns" || event["host"] != "host-1" {
+			t.Errorf("事件 %d 元数据错误: %v", i, event)
+		}
+		if event["time"] != float64(sequence(2)[i].Timestamp.Unix()) {
+			t.Errorf("事件 %d time = %v", i, event["time"])
+		}
+		if body, _ := event["event"].(map[string]any); body["queryName"] != fmt.Sprintf("q%d.example.com", i+1) {
+			t.Errorf("事件 %d 内容错误: %v", i, event["event"])
+		}
+	}
+}
+
+func TestElasticsearchBulk(t *testing.T) {
+	c, server := newCollector(t)
+	sink := sinkFor(t, output.SinkConfig{Options: map[string]any{
+		"url":    server.URL + "/_bulk",
+		"target": TargetElasticsearch,
+		"index":  "dns-logs",
+	}})
+	writeAll(t, sink, sequence(2))
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := c.received()
+	if len(requests) != 1 {
+		t.Fatalf("got %d 个请求, want 1", len(requests))
+	}
+	if got := requests[0].header.Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if !bytes.HasSuffix(requests[0].body, []byte("\n")) {
+		t.Errorf("_bulk 请求体应以换行结尾")
+	}
+
+	lines := decodeJSONStream(t, requests[0].body)
+	if len(lines) != 4 {
+		t.Fatalf("got %d 行, want 4", len(lines))
+	}
+	for i := 0; i < len(lines); i += 2 {
+		action, _ := lines[i]["create"].(map[string]any)
+		if action["_index"] != "dns-logs" || action["_id"] != fmt.Sprintf("rec-%d", i/2+1) {
+			t.Errorf("第 %d 行操作错误: %v", i, lines[i])
+		}
+		if lines[i+1]["@timestamp"] != fmt.Sprintf("2024-05-01T08:30:%02d.000Z", i/2) {
+			t.Errorf("第 %d 行 @timestamp = %v", i+1, lines[i+1]["@timestamp"])
+		}
+	}
+}
+
+func TestElasticsearchBulkItemErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  bool
+	}{
+		{"全部成功", `{"errors":false,"items":[{"create":{"status":201}}]}`, false},
+		{"文档已存在", `{"errors":true,"items":[{"create":{"status":201}},{"create":{"status":409,"error":{"type":"version_conflict_engine_exception"}}}]}`, false},
+		{"映射错误", `{"errors":true,"items":[{"create":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, server := newCollector(t)
+			c.response = tt.response
+			dir := t.TempDir()
+			sink := sinkFor(t, output.SinkConfig{Options: map[string]any{
+				"url":      server.URL,
+				"target":   TargetElasticsearch,
+				"spoolDir": dir,
+			}})
+			writeAll(t, sink, sequence(1))
+			err := sink.Close()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			// 逐条写入失败不可重试，不应重发也不应暂存
+			if got := len(c.received()); got != 1 {
+				t.Errorf("got %d 个请求, want 1", got)
+			}
+			if files := sink.spool.files(); len(files) != 0 {
+				t.Errorf("不应暂存, got %v", files)
+			}
+		})
+	}
+}
+
+func TestLokiPush(t *testing.T) {
+	c, server := newCollector(t)
+	sink := sinkFor(t, output.SinkConfig{Options: map[string]any{
+		"url":    server.URL + "/loki/api/v1/push",
+		"target": TargetLoki,
+		"labels": map[string]string{"env": "prod"},
+	}})
+	writeAll(t, sink, sequence(3))
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := c.received()
+	if len(requests) != 1 {
+		t.Fatalf("got %d 个请求, want 1", len(requests))
+	}
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(requests[0].body, &push); err != nil {
+		t.Fatal(err)
+	}
+
+	// 记录按主机与查询类型分流，流的顺序与首次出现顺序一致
+	if len(push.Streams) != 2 {
+		t.Fatalf("got %d 个流, want 2", len(push.Streams))
+	}
+	for i, want := range []struct {
+		queryType string
+		values    int
+	}{{"A", 2}, {"AAAA", 1}} {
+		stream := push.Streams[i]
+		if stream.Stream["job"] != "dnsflux" || stream.Stream["env"] != "prod" ||
+			stream.Stream["host"] != "host-1" || stream.Stream["query_type"] != want.queryType {
+			t.Errorf("流 %d 标签错误: %v", i, stream.Stream)
+		}
+		if len(stream.Values) != want.values {
+			t.Errorf("流 %d 有 %d 条日志, want %d", i, len(stream.Values), want.values)
+		}
+	}
+	first := push.Streams[0].Values[0]
+	if first[0] != fmt.Sprint(sequence(1)[0].Timestamp.UnixNano()) {
+		t.Errorf("时间戳 = %s", first[0])
+	}
+	var record model.DNSRecord
+	if err := json.Unmarshal([]byte(first[1]), &record); err != nil || record.ID != "rec-1" {
+		t.Errorf("日志行错误: %v %s", err, first[1])
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	c, server := newCollector(t)
+	sink := sinkFor(t, output.SinkConfig{Options: map[string]any{
+		"url":         server.URL,
+		"template":    `{{range .Records}}{{.QueryName}} {{unixNano .}}{{"\n"}}{{end}}`,
+		"contentType": "text/plain",
+	}})
+	writeAll(t, sink, sequence(2))
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := c.received()
+	if len(requests) != 1 {
+		t.Fatalf("got %d 个请求, want 1", len(requests))
+	}
+	records := sequence(2)
+	want := fmt.Sprintf("q1.example.com %d\nq2.example.com %d\n",
+		records[0].Timestamp.UnixNano(), records[1].Timestamp.UnixNano())
+	if string(requests[0].body) != want {
+		t.Errorf("模板请求体:\n got %q\nwant %q", requests[0].body, want)
+	}
+	if got := requests[0].header.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		config output.SinkConfig
+	}{
+		{"缺少地址", output.SinkConfig{}},
+		{"未知目标类型", output.SinkConfig{Options: map[string]any{"url": "http://localhost", "target": "kafka"}}},
+		{"模板语法错误", output.SinkConfig{Options: map[string]any{"url": "http://localhost", "template": "{{.Records"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Name = "test"
+			if _, err := New(tt.config); err == nil {
+				t.Fatal("应返回错误")
+			}
+		})
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	var attempts atomic.Int32
+	var times []time.Time
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		if attempts.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sink := sinkFor(t, output.SinkConfig{MaxRetries: 2, Options: map[string]any{
+		"url":        server.URL,
+		"maxBackoff": "50ms",
+	}})
+	writeAll(t, sink, sequence(1))
+	if err := sink.Close(); err != nil {
+		t.Fatalf("重试后应发送成功: %v", err)
+	}
+
+	if got := attempts.Load(); got != 3 {
+		t.Fatalf("got %d 次请求, want 3", got)
+	}
+	// 首次退避 500ms，之后翻倍并受 maxBackoff 限制
+	if gap := times[1].Sub(times[0]); gap < 450*time.Millisecond {
+		t.Errorf("首次重试间隔 %v 过短", gap)
+	}
+	if gap := times[2].Sub(times[1]); gap > 400*time.Millisecond {
+		t.Errorf("第二次重试间隔 %v 未受 maxBackoff 限制", gap)
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	c, server := newCollector(t)
+	c.status.Store(http.StatusBadRequest)
+	dir := t.TempDir()
+	sink := sinkFor(t, output.SinkConfig{Options: map[string]any{"url": server.URL, "spoolDir": dir}})
+	writeAll(t, sink, sequence(1))
+	if err := sink.Close(); err == nil {
+		t.Fatal("4xx 响应应返回错误")
+	}
+	if got := len(c.received()); got != 1 {
+		t.Errorf("4xx 响应不应重试, got %d 个请求", got)
+	}
+	if files := sink.spool.files(); len(files) != 0 {
+		t.Errorf("4xx 响应不应暂存, got %v", files)
+	}
+}
+
+func TestDroppedBatchReportedOnFlush(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"目标不可用且未配置暂存", http.StatusServiceUnavailable},
+		{"目标拒绝请求", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, server := newCollector(t)
+			c.status.Store(int32(tt.status))
+			sink := sinkFor(t, output.SinkConfig{MaxRetries: 1, Options: map[string]any{
+				"url":        server.URL,
+				"batchSize":  2,
+				"maxBackoff": "10ms",
+			}})
+
+			// 批次已满时的发送失败不从 Write 返回，而是保留到下一次 Flush
+			writeAll(t, sink, sequence(2))
+			err := sink.Flush(context.Background())
+			if err == nil || !strings.Contains(err.Error(), "丢弃 2 条记录") {
+				t.Fatalf("刷新应返回丢弃批次的错误, got %v", err)
+			}
+			if err := sink.Flush(context.Background()); err != nil {
+				t.Errorf("错误只应返回一次, got %v", err)
+			}
+
+			// 关闭时同样返回未报告的错误
+			writeAll(t, sink, sequence(2))
+			if err := sink.Close(); err == nil {
+				t.Error("关闭时应返回丢弃批次的错误")
+			}
+		})
+	}
+}
+
+func TestSpoolAndReplay(t *testing.T) {
+	c, server := newCollector(t)
+	c.status.Store(http.StatusServiceUnavailable)
+	dir := filepath.Join(t.TempDir(), "spool")
+	sink := sinkFor(t, output.SinkConfig{MaxRetries: 1, Options: map[string]any{
+		"url":        server.URL,
+		"batchSize":  2,
+		"maxBackoff": "10ms",
+		"spoolDir":   dir,
+	}})
+	records := sequence(4)
+
+	// 目标不可用：第一批重试后暂存
+	writeAll(t, sink, records[:2])
+	if files := sink.spool.files(); len(files) != 1 {
+		t.Fatalf("发送失败的批次应暂存, got %v", files)
+	}
+
+	// 暂存重发失败时当前批次直接暂存，不再重试
+	before := len(c.received())
+	writeAll(t, sink, records[2:3])
+	if err := sink.Flush(context.Background()); err == nil {
+		t.Fatal("目标不可用时刷新应返回错误")
+	}
+	if got := len(c.received()) - before; got != 1 {
+		t.Errorf("重发暂存失败后应停止, got %d 个请求", got)
+	}
+	if files := sink.spool.files(); len(files) != 2 {
+		t.Fatalf("got %d 个暂存文件, want 2", len(files))
+	}
+
+	// 目标恢复后按写入顺序重发，再发送当前批次
+	c.status.Store(http.StatusOK)
+	before = len(c.received())
+	writeAll(t, sink, records[3:])
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("目标恢复后刷新失败: %v", err)
+	}
+	if files := sink.spool.files(); len(files) != 0 {
+		t.Errorf("重发后暂存应清空, got %v", files)
+	}
+
+	var ids []string
+	for _, req := range c.received()[before:] {
+		var batch []model.DNSRecord
+		if err := json.Unmarshal(req.body, &batch); err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range batch {
+			ids = append(ids, record.ID)
+		}
+	}
+	if got := strings.Join(ids, ","); got != "rec-1,rec-2,rec-3,rec-4" {
+		t.Errorf("重发顺序为 %s", got)
+	}
+	sink.Close()
+}
+
+func TestSpoolLimit(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := newSpool(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp.maxBytes = 25
+
+	for i := 0; i < 4; i++ {
+		if err := sp.put([]byte(fmt.Sprintf("body-%d-xxxx", i))); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 每个文件 11 字节，上限 25 字节时只保留最新的两个
+	files := sp.files()
+	if len(files) != 2 {
+		t.Fatalf("got %d 个暂存文件, want 2", len(files))
+	}
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("body-%d-xxxx", i+2); string(data) != want {
+			t.Errorf("暂存文件 %d = %q, want %q", i, data, want)
+		}
+	}
+}
+
+// decodeJSONStream 解析拼接或换行分隔的 JSON 对象
+func decodeJSONStream(t *testing.T, body []byte) []map[string]any {
+	t.Helper()
+	var objects []map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for dec.More() {
+		var obj map[string]any
+		if err := dec.Decode(&obj); err != nil {
+			t.Fatalf("解析 JSON 失败: %v", err)
+		}
+		objects = append(objects, obj)
+	}
+	return objects
+}
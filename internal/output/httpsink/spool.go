@@ -0,0 +1,81 @@
+package httpsink
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const spoolSuffix = ".spool"
+
+// spool 目标不可用时将请求体暂存到磁盘，恢复后按写入顺序重发
+type spool struct {
+	dir      string
+	maxBytes int64
+}
+
+// newSpool 创建磁盘暂存目录，dir 为空时不启用暂存
+func newSpool(dir string, maxMB int) (*spool, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建暂存目录失败: %w", err)
+	}
+	return &spool{dir: dir, maxBytes: int64(maxMB) * 1024 * 1024}, nil
+}
+
+// put 保存一个已编码的请求体，超出容量时删除最旧的文件
+func (s *spool) put(body []byte) error {
+	name := filepath.Join(s.dir, fmt.Sprintf("%020d%s", time.Now().UnixNano(), spoolSuffix))
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, body, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入暂存文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("写入暂存文件失败: %w", err)
+	}
+	s.enforceLimit()
+	return nil
+}
+
+// files 按写入顺序返回暂存文件
+func (s *spool) files() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), spoolSuffix) {
+			files = append(files, filepath.Join(s.dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// enforceLimit 删除最旧的暂存文件直到总大小不超过上限
+func (s *spool) enforceLimit() {
+	if s.maxBytes <= 0 {
+		return
+	}
+	files := s.files()
+	sizes := make([]int64, len(files))
+	var total int64
+	for i, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+	for i := 0; total > s.maxBytes && i < len(files); i++ {
+		if os.Remove(files[i]) == nil {
+			total -= sizes[i]
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package jsonfile
+
+import (
+	"compress/gzip"
+	"dnsflux/internal/model"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ReadFile 读取 JSONL 记录文件，按扩展名自动解压 .gz 与 .zst 归档
+func ReadFile(path string, fn func(model.DNSRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	switch filepath.Ext(path) {
+	case ".gz":
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("解压 %s 失败: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	case ".zst":
+		dec, err := zstd.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("解压 %s 失败: %w", path, err)
+		}
+		defer dec.Close()
+		r = dec
+	}
+
+	if err := model.ReadJSONL(r, fn); err != nil {
+		return fmt.Errorf("读取 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// ListFiles 列出目录中的记录文件（含归档），按文件名排序，即按日期与归档序号排序
+func ListFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.Contains(name, fileSuffix) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,587 @@
+package jsonfile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/utils"
+	"dnsflux/pkg/logger"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// log JSONL 输出日志
+var log = logger.Component("output").With("sink", "jsonl")
+
+func init() {
+	output.Register("jsonl", NewSink)
+}
+
+const (
+	filePrefix  = "dns_records_"
+	alertPrefix = "dns_alerts_"
+	fileSuffix  = ".json"
+
+	// cleanupInterval 没有发生轮转时按保留策略清理归档文件的间隔
+	cleanupInterval = time.Hour
+)
+
+// 压缩算法
+const (
+	CompressNone = "none"
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// Config JSONL 文件输出配置
+type Config struct {
+	Dir            string         `json:"dir"`            // 输出目录
+	MaxSizeMB      int            `json:"maxSizeMB"`      // 单个文件最大大小（MB），<= 0 表示不按大小轮转
+	MaxAgeDays     int            `json:"maxAgeDays"`     // 轮转文件最长保留天数，<= 0 表示不限制
+	MaxTotalSizeMB int            `json:"maxTotalSizeMB"` // 轮转文件总大小上限（MB），<= 0 表示不限制
+	Compress       string         `json:"compress"`       // 轮转文件压缩算法 [none, gzip, zstd]
+	Timezone       string         `json:"timezone"`       // 按天轮转所使用的时区名称，Location 为空时生效，为空表示本地时区
+	Location       *time.Location `json:"-"`              // 按天轮转所使用的时区
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Dir:            "logs",
+		MaxSizeMB:      100,
+		MaxAgeDays:     30,
+		MaxTotalSizeMB: 1024,
+		Compress:       CompressGzip,
+	}
+}
+
+// Writer 按大小和日期轮转的 JSONL 写入器
+// 写入经缓冲后由 Flush 周期性同步到磁盘，异步与重试由输出管理器负责
+type Writer struct {
+	mu        sync.Mutex
+	name      string
+	prefix    string
+	config    Config
+	formatter output.Formatter
+	alerts    *Writer // 告警文件，输出告警时按相同配置写入 dns_alerts_ 开头的文件
+
+	file  *os.File
+	buf   *bufio.Writer
+	size  int64
+	day   string
+	dirty bool
+
+	errMu   sync.Mutex
+	lastErr time.Time
+
+	lastCleanup time.Time // 最近一次安排清理的时间，由 mu 保护
+
+	// 归档文件由单个后台协程依次压缩，队列为空时再清理，避免清理正在压缩的文件
+	archiveMu  sync.Mutex
+	queue      []string // 待压缩的归档文件
+	archiving  bool
+	compressWG sync.WaitGroup
+}
+
+// NewSink 根据输出目标配置创建 JSONL 写入器
+func NewSink(config output.SinkConfig) (output.Sink, error) {
+	opts := DefaultConfig()
+	if err := config.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+	formatter, err := output.NewFormatter(config.Format, output.FormatJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	w.name = config.Name
+	w.formatter = formatter
+	if config.Alerts != "" {
+		alerts, err := newWriter(opts, alertPrefix)
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		alerts.name = config.Name
+		w.alerts = alerts
+	}
+	return w, nil
+}
+
+// New 创建 JSONL 写入器
+func New(config Config) (*Writer, error) {
+	return newWriter(config, filePrefix)
+}
+
+// newWriter 创建写入指定前缀文件的写入器，并接续上次运行遗留的轮转、压缩与清理
+func newWriter(config Config, prefix string) (*Writer, error) {
+	defaults := DefaultConfig()
+	if config.Dir == "" {
+		config.Dir = defaults.Dir
+	}
+	if config.Location == nil {
+		loc, err := utils.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		config.Location = loc
+	}
+	switch config.Compress {
+	case "":
+		config.Compress = CompressNone
+	case CompressNone, CompressGzip, CompressZstd:
+	default:
+		return nil, fmt.Errorf("不支持的压缩算法: %s", config.Compress)
+	}
+
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建输出目录失败: %w", err)
+	}
+
+	formatter, _ := output.NewFormatter(output.FormatJSON, "")
+	w := &Writer{
+		name:      "jsonl",
+		prefix:    prefix,
+		config:    config,
+		formatter: formatter,
+	}
+	w.resume()
+	return w, nil
+}
+
+// Name 返回输出目标名称
+func (w *Writer) Name() string {
+	return w.name
+}
+
+// Write 写入单条记录
+func (w *Writer) Write(ctx context.Context, record model.DNSRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writeRecord(record)
+}
+
+// WriteAlert 以单行 JSON 将告警写入告警文件
+func (w *Writer) WriteAlert(ctx context.Context, alert model.Alert) error {
+	if w.alerts == nil {
+		return fmt.Errorf("输出目标 %s 未启用告警输出", w.name)
+	}
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("序列化告警失败: %w", err)
+	}
+
+	w.alerts.mu.Lock()
+	defer w.alerts.mu.Unlock()
+	return w.alerts.write(append(data, '\n'))
+}
+
+// Flush 刷新缓冲区并同步到磁盘，同时检查日期变化，并定期清理归档文件
+func (w *Writer) Flush(ctx context.Context) error {
+	if w.alerts != nil {
+		if err := w.alerts.Flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.sync(); err != nil {
+		return err
+	}
+	if w.file != nil && w.currentDay() != w.day {
+		w.rotate()
+	}
+	if time.Since(w.lastCleanup) >= cleanupInterval {
+		w.lastCleanup = time.Now()
+		w.schedule()
+	}
+	return nil
+}
+
+// Close 刷新缓冲区、关闭当前文件并等待压缩任务完成
+func (w *Writer) Close() error {
+	if w.alerts != nil {
+		w.alerts.Close()
+	}
+
+	w.mu.Lock()
+	w.closeFile()
+	w.mu.Unlock()
+
+	w.compressWG.Wait()
+	return nil
+}
+
+// writeRecord 写入单条记录
+func (w *Writer) writeRecord(record model.DNSRecord) error {
+	data, err := w.formatter.Format(&record)
+	if err != nil {
+		return fmt.Errorf("序列化记录失败: %w", err)
+	}
+	return w.write(data)
+}
+
+// write 写入一行数据，必要时先轮转文件
+func (w *Writer) write(data []byte) error {
+	day := w.currentDay()
+	if w.file != nil && day != w.day {
+		w.rotate()
+	}
+	maxSize := int64(w.config.MaxSizeMB) * 1024 * 1024
+	if w.file != nil && maxSize > 0 && w.size+int64(len(data)) > maxSize {
+		w.rotate()
+	}
+	if w.file == nil {
+		if err := w.openFile(day); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.buf.Write(data)
+	w.size += int64(n)
+	w.dirty = true
+	if err != nil {
+		// 写入失败时丢弃当前文件句柄，下次写入时重新打开
+		w.closeFile()
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	return nil
+}
+
+// openFile 打开指定日期的活动文件
+func (w *Writer) openFile(day string) error {
+	if err := os.MkdirAll(w.config.Dir, 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+
+	path := w.activePath(day)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("获取文件信息失败: %w", err)
+	}
+
+	w.file = file
+	w.buf = bufio.NewWriterSize(file, 64*1024)
+	w.size = stat.Size()
+	w.day = day
+	return nil
+}
+
+// sync 刷新缓冲区并同步到磁盘
+func (w *Writer) sync() error {
+	if w.file == nil || !w.dirty {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("刷新缓冲区失败: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("同步文件失败: %w", err)
+	}
+	w.dirty = false
+	return nil
+}
+
+// closeFile 刷新并关闭活动文件
+func (w *Writer) closeFile() {
+	if w.file == nil {
+		return
+	}
+	if err := w.sync(); err != nil {
+		w.reportError(err)
+	}
+	w.file.Close()
+	w.file = nil
+	w.buf = nil
+	w.size = 0
+}
+
+// rotate 关闭活动文件，将其重命名为带序号的归档文件并在后台压缩
+func (w *Writer) rotate() {
+	day := w.day
+	w.closeFile()
+	w.archive(day)
+}
+
+// resume 接续上次运行未完成的工作：压缩遗留的未压缩归档文件（例如压缩过程中程序退出），
+// 轮转以前日期遗留的活动文件（例如程序跨天停止后重新启动），并按保留策略清理归档文件
+func (w *Writer) resume() {
+	var orphans, stale []string
+	if entries, err := os.ReadDir(w.config.Dir); err == nil {
+		today := w.currentDay()
+		for _, entry := range entries {
+			name := entry.Name()
+			switch day, active := w.activeDay(name); {
+			case entry.IsDir():
+			case active && day != today:
+				stale = append(stale, day)
+			case w.config.Compress != CompressNone && w.uncompressedArchive(name):
+				orphans = append(orphans, filepath.Join(w.config.Dir, name))
+			}
+		}
+	}
+
+	w.lastCleanup = time.Now()
+	w.schedule(orphans...)
+	for _, day := range stale {
+		w.archive(day)
+	}
+}
+
+// archive 将指定日期的活动文件重命名为归档文件并加入压缩队列
+func (w *Writer) archive(day string) {
+	w.archiveMu.Lock()
+	defer w.archiveMu.Unlock()
+
+	archived := w.nextArchivePath(day)
+	if err := os.Rename(w.activePath(day), archived); err != nil {
+		w.reportError(fmt.Errorf("轮转文件失败: %w", err))
+		return
+	}
+	w.enqueue(archived)
+}
+
+// schedule 将归档文件加入压缩队列，没有文件时只安排一次清理
+func (w *Writer) schedule(paths ...string) {
+	w.archiveMu.Lock()
+	defer w.archiveMu.Unlock()
+	w.enqueue(paths...)
+}
+
+// enqueue 将归档文件加入压缩队列并在需要时启动后台协程，调用方持有 archiveMu
+func (w *Writer) enqueue(paths ...string) {
+	w.queue = append(w.queue, paths...)
+	if !w.archiving {
+		w.archiving = true
+		w.compressWG.Add(1)
+		go w.compressLoop()
+	}
+}
+
+// compressLoop 依次压缩队列中的归档文件，队列为空时清理归档文件后退出
+func (w *Writer) compressLoop() {
+	defer w.compressWG.Done()
+	for {
+		w.archiveMu.Lock()
+		if len(w.queue) == 0 {
+			// 持有锁清理，期间不会有新的文件被重命名为归档文件
+			w.cleanup()
+			w.archiving = false
+			w.archiveMu.Unlock()
+			return
+		}
+		path := w.queue[0]
+		w.queue = w.queue[1:]
+		w.archiveMu.Unlock()
+
+		if err := compressFile(path, w.config.Compress); err != nil {
+			w.reportError(err)
+		}
+	}
+}
+
+// activePath 返回指定日期的活动文件路径
+func (w *Writer) activePath(day string) string {
+	return filepath.Join(w.config.Dir, w.prefix+day+fileSuffix)
+}
+
+// activeDay 判断文件名是否为活动文件，是则返回其日期
+func (w *Writer) activeDay(name string) (string, bool) {
+	day, ok := strings.CutPrefix(name, w.prefix)
+	if !ok {
+		return "", false
+	}
+	day, ok = strings.CutSuffix(day, fileSuffix)
+	if !ok {
+		return "", false
+	}
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		return "", false
+	}
+	return day, true
+}
+
+// uncompressedArchive 判断文件名是否为未压缩的归档文件，如 dns_records_2024-05-01.1.json
+func (w *Writer) uncompressedArchive(name string) bool {
+	rest, ok := strings.CutPrefix(name, w.prefix)
+	if !ok {
+		return false
+	}
+	rest, ok = strings.CutSuffix(rest, fileSuffix)
+	if !ok {
+		return false
+	}
+	day, seq, ok := strings.Cut(rest, ".")
+	if !ok {
+		return false
+	}
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		return false
+	}
+	n, err := strconv.Atoi(seq)
+	return err == nil && n > 0
+}
+
+// nextArchivePath 返回指定日期下一个可用的归档文件路径
+func (w *Writer) nextArchivePath(day string) string {
+	for seq := 1; ; seq++ {
+		base := filepath.Join(w.config.Dir, fmt.Sprintf("%s%s.%d%s", w.prefix, day, seq, fileSuffix))
+		if !exists(base) && !exists(base+".gz") && !exists(base+".zst") {
+			return base
+		}
+	}
+}
+
+// currentDay 返回配置时区下的当前日期
+func (w *Writer) currentDay() string {
+	return time.Now().In(w.config.Location).Format("2006-01-02")
+}
+
+// cleanup 按保留时间和总大小清理归档文件，调用方持有 archiveMu
+func (w *Writer) cleanup() {
+	entries, err := os.ReadDir(w.config.Dir)
+	if err != nil {
+		return
+	}
+
+	type archive struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var archives []archive
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, active := w.activeDay(name); entry.IsDir() || active || !strings.HasPrefix(name, w.prefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		archives = append(archives, archive{
+			path:    filepath.Join(w.config.Dir, name),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	// 从旧到新排序
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].modTime.Before(archives[j].modTime)
+	})
+
+	var total int64
+	kept := archives[:0]
+	for _, a := range archives {
+		if w.config.MaxAgeDays > 0 && time.Since(a.modTime) > time.Duration(w.config.MaxAgeDays)*24*time.Hour {
+			os.Remove(a.path)
+			continue
+		}
+		kept = append(kept, a)
+		total += a.size
+	}
+
+	maxTotal := int64(w.config.MaxTotalSizeMB) * 1024 * 1024
+	for i := 0; maxTotal > 0 && total > maxTotal && i < len(kept); i++ {
+		if err := os.Remove(kept[i].path); err == nil {
+			total -= kept[i].size
+		}
+	}
+}
+
+// reportError 记录写入错误，每 10 秒最多记录一次避免刷屏
+func (w *Writer) reportError(err error) {
+	w.errMu.Lock()
+	defer w.errMu.Unlock()
+
+	if time.Since(w.lastErr) < 10*time.Second {
+		return
+	}
+	w.lastErr = time.Now()
+	log.Error(fmt.Sprintf("JSONL 输出错误: %v", err))
+}
+
+// compressFile 压缩文件并删除原始文件
+func compressFile(path, algorithm string) error {
+	var ext string
+	switch algorithm {
+	case CompressGzip:
+		ext = ".gz"
+	case CompressZstd:
+		ext = ".zst"
+	default:
+		return nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开待压缩文件失败: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+ext, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("创建压缩文件失败: %w", err)
+	}
+
+	var enc io.WriteCloser
+	if algorithm == CompressGzip {
+		enc = gzip.NewWriter(dst)
+	} else {
+		enc, err = zstd.NewWriter(dst)
+		if err != nil {
+			dst.Close()
+			os.Remove(path + ext)
+			return fmt.Errorf("创建 zstd 编码器失败: %w", err)
+		}
+	}
+
+	if _, err := io.Copy(enc, src); err != nil {
+		enc.Close()
+		dst.Close()
+		os.Remove(path + ext)
+		return fmt.Errorf("压缩文件失败: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ext)
+		return fmt.Errorf("压缩文件失败: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ext)
+		return fmt.Errorf("关闭压缩文件失败: %w", err)
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
+
+// exists 判断文件是否存在
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,229 @@
+package jsonfile
+
+import (
+	"bytes"
+	"context"
+	"dnsflux/internal/model"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dirFiles 返回目录中的文件名，按名称排序
+func dirFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// readNames 读取记录文件（含压缩归档）中的查询域名
+func readNames(t *testing.T, path string) []string {
+	t.Helper()
+	var names []string
+	if err := ReadFile(path, func(record model.DNSRecord) error {
+		names = append(names, record.QueryName)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// writeFile 写入文件并设置修改时间
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recordLine 返回一条记录的 JSONL 行
+func recordLine(name string) []byte {
+	return []byte(`{"queryName":"` + name + `"}` + "\n")
+}
+
+func TestSizeRotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		compress string
+		archive  string
+	}{
+		{"不压缩", CompressNone, ".1.json"},
+		{"gzip", CompressGzip, ".1.json.gz"},
+		{"zstd", CompressZstd, ".1.json.zst"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := New(Config{Dir: dir, MaxSizeMB: 1, Compress: tt.compress, Timezone: "UTC"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 超过 1MB 的记录写入新文件，之前的内容轮转为归档文件
+			long := strings.Repeat("a", 600*1024)
+			for _, name := range []string{long + ".first", long + ".second"} {
+				if err := w.Write(context.Background(), model.DNSRecord{QueryName: name}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			day := time.Now().UTC().Format("2006-01-02")
+			active, archived := filePrefix+day+fileSuffix, filePrefix+day+tt.archive
+			if got := dirFiles(t, dir); !slices.Equal(got, []string{archived, active}) {
+				t.Fatalf("目录内容 = %v, want [%s %s]", got, archived, active)
+			}
+			if got := readNames(t, filepath.Join(dir, archived)); len(got) != 1 || !strings.HasSuffix(got[0], ".first") {
+				t.Errorf("归档文件中有 %d 条记录, want 第一条", len(got))
+			}
+			if got := readNames(t, filepath.Join(dir, active)); len(got) != 1 || !strings.HasSuffix(got[0], ".second") {
+				t.Errorf("活动文件中有 %d 条记录, want 第二条", len(got))
+			}
+		})
+	}
+}
+
+func TestStartupRecovery(t *testing.T) {
+	stale, orphan := filePrefix+"2024-05-01"+fileSuffix, filePrefix+"2024-05-02.1"+fileSuffix
+	tests := []struct {
+		name     string
+		compress string
+		want     []string
+	}{
+		// 以前日期的活动文件被轮转，压缩中断遗留的未压缩归档文件重新压缩
+		{"gzip", CompressGzip, []string{filePrefix + "2024-05-01.1.json.gz", orphan + ".gz"}},
+		// 不压缩时未压缩的归档文件是正常的归档
+		{"不压缩", CompressNone, []string{filePrefix + "2024-05-01.1.json", orphan}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			now := time.Now()
+			writeFile(t, filepath.Join(dir, stale), recordLine("stale.example"), now)
+			writeFile(t, filepath.Join(dir, orphan), recordLine("orphan.example"), now)
+			if tt.compress != CompressNone {
+				// 中断时写了一半的压缩文件被覆盖
+				writeFile(t, filepath.Join(dir, orphan+".gz"), []byte("partial"), now)
+			}
+
+			w, err := New(Config{Dir: dir, Compress: tt.compress, Timezone: "UTC"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := dirFiles(t, dir); !slices.Equal(got, tt.want) {
+				t.Fatalf("目录内容 = %v, want %v", got, tt.want)
+			}
+			for _, name := range tt.want {
+				if records := readNames(t, filepath.Join(dir, name)); len(records) != 1 {
+					t.Errorf("%s 中有 %d 条记录, want 1", name, len(records))
+				}
+			}
+		})
+	}
+}
+
+func TestRetention(t *testing.T) {
+	now := time.Now()
+	archive := func(day string) string { return filePrefix + day + ".1.json.gz" }
+	alerts := alertPrefix + "2024-05-01.1.json.gz"
+	data := bytes.Repeat([]byte{'x'}, 400*1024)
+	tests := []struct {
+		name   string
+		config Config
+		want   []string
+	}{
+		{
+			"按保留天数删除",
+			Config{MaxAgeDays: 7},
+			[]string{alerts, archive("2024-05-03"), archive("2024-05-04")},
+		},
+		{
+			// 每个归档 400KB，1MB 上限内保留最新的两个
+			"按总大小删除最旧的归档",
+			Config{MaxTotalSizeMB: 1},
+			[]string{alerts, archive("2024-05-03"), archive("2024-05-04")},
+		},
+		{
+			"不限制时全部保留",
+			Config{},
+			[]string{alerts, archive("2024-05-01"), archive("2024-05-02"), archive("2024-05-03"), archive("2024-05-04")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, archive("2024-05-01")), data, now.AddDate(0, 0, -30))
+			writeFile(t, filepath.Join(dir, archive("2024-05-02")), data, now.AddDate(0, 0, -10))
+			writeFile(t, filepath.Join(dir, archive("2024-05-03")), data, now.AddDate(0, 0, -3))
+			writeFile(t, filepath.Join(dir, archive("2024-05-04")), data, now.AddDate(0, 0, -1))
+			// 其他前缀的文件由各自的写入器清理
+			writeFile(t, filepath.Join(dir, alerts), data, now.AddDate(0, 0, -30))
+
+			// 启动时即按保留策略清理，不等待下一次轮转
+			config := tt.config
+			config.Dir, config.Compress, config.Timezone = dir, CompressGzip, "UTC"
+			w, err := New(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := dirFiles(t, dir); !slices.Equal(got, tt.want) {
+				t.Errorf("目录内容 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodicCleanup(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New(Config{Dir: dir, MaxAgeDays: 7, Compress: CompressGzip, Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.compressWG.Wait()
+
+	expired := filepath.Join(dir, filePrefix+"2024-05-01.1.json.gz")
+	writeFile(t, expired, []byte("old"), time.Now().AddDate(0, 0, -30))
+
+	// 距上次清理未到间隔时刷新不清理
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	w.compressWG.Wait()
+	if !exists(expired) {
+		t.Fatal("未到清理间隔时删除了归档文件")
+	}
+
+	w.mu.Lock()
+	w.lastCleanup = time.Now().Add(-cleanupInterval)
+	w.mu.Unlock()
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	w.compressWG.Wait()
+	if exists(expired) {
+		t.Error("到达清理间隔后过期的归档文件未被删除")
+	}
+}
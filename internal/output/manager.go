@@ -0,0 +1,305 @@
+package output
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Manager 输出管理器，将记录与告警分发到所有已启用的输出目标
+type Manager struct {
+	mu      sync.RWMutex
+	runners []*runner
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewManager 创建输出管理器
+// 管理器使用独立的上下文，保证关闭时队列中的剩余记录仍可写出
+func NewManager() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		runners: make([]*runner, 0),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Add 添加已创建的输出目标并启动其处理协程
+func (m *Manager) Add(sink Sink, config SinkConfig) {
+	r := newRunner(sink, config)
+	go r.run(m.ctx)
+
+	m.mu.Lock()
+	m.runners = append(m.runners, r)
+	m.mu.Unlock()
+}
+
+// AddConfig 根据配置创建输出目标并添加到管理器
+func (m *Manager) AddConfig(config SinkConfig) error {
+	if !config.IsEnabled() {
+		return nil
+	}
+	r, err := m.build(config)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.runners = append(m.runners, r)
+	m.mu.Unlock()
+	return nil
+}
+
+// ApplyError 新配置已生效，但部分输出目标关闭或按新配置重建失败
+// 重建失败的输出目标已按原配置恢复
+type ApplyError struct {
+	Err error
+}
+
+func (e *ApplyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// Apply 使由配置创建的输出目标与 configs 一致，用于重新加载配置
+// 配置未变化的输出目标保持运行，变化的重建，不再存在或已禁用的关闭；通过 Add 添加的输出目标不受影响。
+// 其余输出目标任一创建失败时不做任何修改，直接返回错误。
+// 名称与类型都相同的输出目标可能写入同一文件，在切换后先关闭旧实例、写完队列中的记录再创建新实例，
+// 创建失败时恢复原配置；切换后的错误以 *ApplyError 返回
+func (m *Manager) Apply(configs []SinkConfig) error {
+	m.mu.RLock()
+	current := make(map[string]*runner)
+	for _, r := range m.runners {
+		if r.managed {
+			current[r.config.Name] = r
+		}
+	}
+	m.mu.RUnlock()
+
+	type replacement struct {
+		old    *runner
+		config SinkConfig
+	}
+	var (
+		next     []*runner
+		created  []*runner
+		replaced []replacement
+		keep     = make(map[*runner]bool)
+	)
+	for _, config := range configs {
+		if !config.IsEnabled() {
+			continue
+		}
+		if config.Name == "" {
+			config.Name = config.Type
+		}
+		if r, ok := current[config.Name]; ok && (reflect.DeepEqual(r.config, config) || r.config.Type == config.Type) {
+			if !reflect.DeepEqual(r.config, config) {
+				replaced = append(replaced, replacement{old: r, config: config})
+			}
+			keep[r] = true
+			next = append(next, r)
+			continue
+		}
+		r, err := m.build(config)
+		if err != nil {
+			for _, c := range created {
+				c.close()
+			}
+			return err
+		}
+		created = append(created, r)
+		next = append(next, r)
+	}
+
+	m.mu.Lock()
+	var removed []*runner
+	runners := make([]*runner, 0, len(m.runners)+len(created))
+	for _, r := range m.runners {
+		switch {
+		case !r.managed:
+			runners = append(runners, r)
+		case !keep[r]:
+			removed = append(removed, r)
+		}
+	}
+	m.runners = append(runners, next...)
+	m.mu.Unlock()
+
+	// 在锁外关闭，等待被移除的输出目标写完队列中的记录
+	var errs []error
+	for _, r := range removed {
+		if err := r.close(); err != nil {
+			errs = append(errs, fmt.Errorf("关闭输出目标 %s 失败: %w", r.sink.Name(), err))
+		}
+	}
+	for _, rep := range replaced {
+		if err := m.replace(rep.old, rep.config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return &ApplyError{Err: errors.Join(errs...)}
+	}
+	return nil
+}
+
+// replace 关闭旧的输出目标后按新配置重建，重建失败时按原配置恢复
+// 关闭期间分发给旧实例的记录被丢弃
+func (m *Manager) replace(old *runner, config SinkConfig) error {
+	var errs []error
+	if err := old.close(); err != nil {
+		errs = append(errs, fmt.Errorf("关闭输出目标 %s 失败: %w", old.sink.Name(), err))
+	}
+
+	r, err := m.build(config)
+	if err != nil {
+		errs = append(errs, err)
+		if r, err = m.build(old.config); err != nil {
+			errs = append(errs, fmt.Errorf("恢复输出目标 %s 失败: %w", config.Name, err))
+		}
+	}
+
+	m.mu.Lock()
+	for i, current := range m.runners {
+		if current == old {
+			if r != nil {
+				m.runners[i] = r
+			} else {
+				m.runners = slices.Delete(m.runners, i, i+1)
+			}
+			break
+		}
+	}
+	m.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// build 创建输出目标并启动其处理协程
+func (m *Manager) build(config SinkConfig) (*runner, error) {
+	if config.Name == "" {
+		config.Name = config.Type
+	}
+	if err := checkAlerts(config); err != nil {
+		return nil, fmt.Errorf("创建输出目标 %s 失败: %w", config.Name, err)
+	}
+	sink, err := Build(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建输出目标 %s 失败: %w", config.Name, err)
+	}
+	if _, ok := sink.(AlertSink); !ok && config.Alerts != "" {
+		sink.Close()
+		return nil, fmt.Errorf("创建输出目标 %s 失败: 输出目标类型 %s 不支持告警", config.Name, config.Type)
+	}
+	r := newRunner(sink, config)
+	r.managed = true
+	go r.run(m.ctx)
+	return r, nil
+}
+
+// Dispatch 将记录分发到所有输出目标，不会阻塞调用方
+func (m *Manager) Dispatch(record model.DNSRecord) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.runners {
+		if r.accepts(&record) {
+			r.enqueue(event{record: record})
+		}
+	}
+}
+
+// DispatchWait 将记录分发到所有输出目标，队列已满时等待而不丢弃，用于回放等需要完整输出的场景
+func (m *Manager) DispatchWait(ctx context.Context, record model.DNSRecord) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.runners {
+		if !r.accepts(&record) {
+			continue
+		}
+		if err := r.enqueueWait(ctx, event{record: record}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DispatchAlert 将告警分发到配置了告警输出的输出目标，不会阻塞调用方
+func (m *Manager) DispatchAlert(alert model.Alert) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.runners {
+		if r.acceptsAlert(&alert) {
+			r.enqueue(event{alert: &alert})
+		}
+	}
+}
+
+// DispatchAlertWait 将告警分发到配置了告警输出的输出目标，队列已满时等待而不丢弃
+func (m *Manager) DispatchAlertWait(ctx context.Context, alert model.Alert) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.runners {
+		if !r.acceptsAlert(&alert) {
+			continue
+		}
+		if err := r.enqueueWait(ctx, event{alert: &alert}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAlerts 检查告警输出模式与最低级别
+func checkAlerts(config SinkConfig) error {
+	switch config.Alerts {
+	case "", AlertsInclude, AlertsOnly:
+	default:
+		return fmt.Errorf("未知的告警输出模式 %q，可用模式: %s, %s", config.Alerts, AlertsInclude, AlertsOnly)
+	}
+	if config.MinSeverity != "" && model.SeverityRank(config.MinSeverity) < 0 {
+		return fmt.Errorf("未知的告警级别 %q，可用级别: %s", config.MinSeverity, strings.Join(model.Severities, ", "))
+	}
+	return nil
+}
+
+// Health 返回所有输出目标的运行状态
+func (m *Manager) Health() []Health {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	health := make([]Health, 0, len(m.runners))
+	for _, r := range m.runners {
+		health = append(health, r.health())
+	}
+	return health
+}
+
+// Close 关闭所有输出目标，等待队列中的记录处理完成
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	runners := m.runners
+	m.runners = nil
+	m.mu.Unlock()
+
+	var errs []error
+	for _, r := range runners {
+		if err := r.close(); err != nil {
+			errs = append(errs, fmt.Errorf("关闭输出目标 %s 失败: %w", r.sink.Name(), err))
+		}
+	}
+	m.cancel()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,216 @@
+package output
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// built 测试类型的输出目标按创建顺序记录，选项 fail 为 true 时创建失败
+var built struct {
+	mu    sync.Mutex
+	sinks []*testSink
+}
+
+func init() {
+	Register("test", func(config SinkConfig) (Sink, error) {
+		if fail, _ := config.Options["fail"].(bool); fail {
+			return nil, errors.New("创建失败")
+		}
+		sink := newTestSink(config.Name)
+		built.mu.Lock()
+		built.sinks = append(built.sinks, sink)
+		built.mu.Unlock()
+		return sink, nil
+	})
+}
+
+// builtSince 返回第 n 个之后创建的输出目标
+func builtSince(n int) []*testSink {
+	built.mu.Lock()
+	defer built.mu.Unlock()
+	return append([]*testSink(nil), built.sinks[n:]...)
+}
+
+func builtCount() int {
+	built.mu.Lock()
+	defer built.mu.Unlock()
+	return len(built.sinks)
+}
+
+// names 返回输出目标名称
+func names(sinks []*testSink) []string {
+	var out []string
+	for _, s := range sinks {
+		out = append(out, s.name)
+	}
+	return out
+}
+
+// running 返回管理器中的输出目标名称
+func running(m *Manager) []string {
+	var out []string
+	for _, h := range m.Health() {
+		out = append(out, h.Name)
+	}
+	return out
+}
+
+func testConfig(name string, domains ...string) SinkConfig {
+	return SinkConfig{Name: name, Type: "test", Filter: Filter{Domains: domains}}
+}
+
+func TestManagerApply(t *testing.T) {
+	m := NewManager()
+	defer m.Close()
+	static := newTestSink("static")
+	m.Add(static, SinkConfig{Name: "static", Type: "test"})
+
+	failing := SinkConfig{Name: "bad", Type: "test", Options: map[string]any{"fail": true}}
+	steps := []struct {
+		name        string
+		configs     []SinkConfig
+		wantErr     bool
+		wantApplied bool // 错误发生在切换之后
+		wantRunning []string
+		wantBuilt   []string
+		wantClosed  []string
+	}{
+		{
+			name:        "添加",
+			configs:     []SinkConfig{testConfig("a"), testConfig("b")},
+			wantRunning: []string{"static", "a", "b"},
+			wantBuilt:   []string{"a", "b"},
+		},
+		{
+			name:        "配置未变化时保持运行",
+			configs:     []SinkConfig{testConfig("a"), testConfig("b")},
+			wantRunning: []string{"static", "a", "b"},
+		},
+		{
+			name:        "替换配置变化的输出目标",
+			configs:     []SinkConfig{testConfig("a"), testConfig("b", "example.com")},
+			wantRunning: []string{"static", "a", "b"},
+			wantBuilt:   []string{"b"},
+			wantClosed:  []string{"b"},
+		},
+		{
+			name:        "移除",
+			configs:     []SinkConfig{testConfig("b", "example.com")},
+			wantRunning: []string{"static", "b"},
+			wantClosed:  []string{"a"},
+		},
+		{
+			name:        "创建失败时不做任何修改",
+			configs:     []SinkConfig{testConfig("c"), failing},
+			wantErr:     true,
+			wantRunning: []string{"static", "b"},
+			wantBuilt:   []string{"c"},
+			wantClosed:  []string{"c"},
+		},
+		{
+			name:        "重建失败时恢复原配置",
+			configs:     []SinkConfig{{Name: "b", Type: "test", Options: map[string]any{"fail": true}}},
+			wantErr:     true,
+			wantApplied: true,
+			wantRunning: []string{"static", "b"},
+			wantBuilt:   []string{"b"},
+			wantClosed:  []string{"b"},
+		},
+	}
+
+	var open []*testSink
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			before := builtCount()
+			err := m.Apply(step.configs)
+			var applyErr *ApplyError
+			if (err != nil) != step.wantErr || errors.As(err, &applyErr) != step.wantApplied {
+				t.Errorf("Apply() error = %v", err)
+			}
+			if got := running(m); !slices.Equal(got, step.wantRunning) {
+				t.Errorf("运行中的输出目标 = %v, want %v", got, step.wantRunning)
+			}
+
+			created := builtSince(before)
+			if got := names(created); !slices.Equal(got, step.wantBuilt) {
+				t.Errorf("新建的输出目标 = %v, want %v", got, step.wantBuilt)
+			}
+			var closed []string
+			var still []*testSink
+			for _, s := range append(open, created...) {
+				if s.isClosed() {
+					closed = append(closed, s.name)
+				} else {
+					still = append(still, s)
+				}
+			}
+			open = still
+			if !slices.Equal(closed, step.wantClosed) {
+				t.Errorf("关闭的输出目标 = %v, want %v", closed, step.wantClosed)
+			}
+		})
+	}
+
+	// 恢复的输出目标仍使用原配置
+	m.mu.RLock()
+	restored := m.runners[len(m.runners)-1].config
+	m.mu.RUnlock()
+	if !slices.Equal(restored.Filter.Domains, []string{"example.com"}) {
+		t.Errorf("恢复后的配置 = %+v", restored)
+	}
+	if static.isClosed() {
+		t.Error("通过 Add 添加的输出目标被关闭")
+	}
+}
+
+func TestReplaceDropsClosingRecords(t *testing.T) {
+	m := NewManager()
+	defer m.Close()
+	if err := m.Apply([]SinkConfig{testConfig("a")}); err != nil {
+		t.Fatal(err)
+	}
+	m.mu.RLock()
+	old := m.runners[0]
+	m.mu.RUnlock()
+	oldSink := old.sink.(*testSink)
+	release := make(chan struct{})
+	oldSink.mu.Lock()
+	oldSink.release = release
+	oldSink.mu.Unlock()
+
+	// 旧实例阻塞在第一条记录的写入中，替换时等待其写完队列
+	m.Dispatch(record("first.example").record)
+	<-oldSink.started
+	before := builtCount()
+	applied := make(chan error)
+	go func() { applied <- m.Apply([]SinkConfig{{Name: "a", Type: "test", QueueSize: 8}}) }()
+	waitFor(t, "旧实例开始关闭", func() bool {
+		old.closeMu.RLock()
+		defer old.closeMu.RUnlock()
+		return old.closed
+	})
+
+	// 关闭期间分发的记录既不进入旧实例也不进入新实例
+	m.Dispatch(record("closing.example").record)
+	close(release)
+	if err := <-applied; err != nil {
+		t.Fatal(err)
+	}
+	m.Dispatch(record("after.example").record)
+
+	created := builtSince(before)
+	if len(created) != 1 {
+		t.Fatalf("新建了 %d 个输出目标, want 1", len(created))
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := oldSink.written(); !slices.Equal(got, []string{"first.example"}) {
+		t.Errorf("旧实例写入 %v", got)
+	}
+	if got := created[0].written(); !slices.Equal(got, []string{"after.example"}) {
+		t.Errorf("新实例写入 %v", got)
+	}
+}
@@ -0,0 +1,99 @@
+package otlp
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+func TestHTTPAlertLog(t *testing.T) {
+	var status atomic.Int32
+	c, server := httpCollector(t, &status)
+	sink := otlpSink(t, map[string]any{"endpoint": server.URL, "disableMetrics": true})
+
+	firstSeen := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	alert := model.Alert{
+		ID:          "alert-1",
+		Detector:    "intel",
+		Rule:        "feed",
+		Severity:    model.SeverityHigh,
+		Technique:   "T1071.004",
+		Message:     "命中情报",
+		QueryName:   "evil.example",
+		ProcessID:   1234,
+		ProcessName: "curl",
+		RecordIDs:   []string{"rec-1", "rec-2"},
+		Count:       2,
+		FirstSeen:   firstSeen,
+		LastSeen:    firstSeen.Add(30 * time.Minute),
+		Status:      model.AlertOpen,
+	}
+	if err := sink.WriteAlert(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+
+	logs, metrics := c.received()
+	if len(logs) != 1 || len(metrics) != 0 {
+		t.Fatalf("got %d 条日志、%d 个指标, want 1、0", len(logs), len(metrics))
+	}
+	got := logs[0]
+	if got.EventName != "dnsflux.alert" || got.SeverityNumber != logspb.SeverityNumber_SEVERITY_NUMBER_ERROR || got.SeverityText != "HIGH" {
+		t.Errorf("告警日志事件 = %q, 严重程度 = %v %q", got.EventName, got.SeverityNumber, got.SeverityText)
+	}
+	if got.TimeUnixNano != uint64(firstSeen.UnixNano()) {
+		t.Errorf("告警日志时间 = %d", got.TimeUnixNano)
+	}
+	if body := got.Body.GetStringValue(); body != "命中情报" {
+		t.Errorf("告警日志正文 = %q", body)
+	}
+	checkAttrs(t, "告警日志", attrMap(got.Attributes), map[string]any{
+		attrAlertID:        "alert-1",
+		attrAlertDetector:  "intel",
+		attrAlertRule:      "feed",
+		attrAlertSeverity:  model.SeverityHigh,
+		attrAlertCount:     int64(2),
+		attrAlertStatus:    model.AlertOpen,
+		attrTechniqueID:    "T1071.004",
+		attrQuestionName:   "evil.example",
+		attrPID:            int64(1234),
+		attrExecutableName: "curl",
+		attrAlertRecords:   []any{"rec-1", "rec-2"},
+	})
+}
+
+func TestAlertSeverityNumbers(t *testing.T) {
+	tests := []struct {
+		name     string
+		severity string
+		want     logspb.SeverityNumber
+	}{
+		{"信息", model.SeverityInformational, logspb.SeverityNumber_SEVERITY_NUMBER_INFO},
+		{"低", model.SeverityLow, logspb.SeverityNumber_SEVERITY_NUMBER_WARN},
+		{"中", model.SeverityMedium, logspb.SeverityNumber_SEVERITY_NUMBER_WARN2},
+		{"严重", model.SeverityCritical, logspb.SeverityNumber_SEVERITY_NUMBER_FATAL},
+		{"未知级别", "bogus", logspb.SeverityNumber_SEVERITY_NUMBER_WARN},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status atomic.Int32
+			c, server := httpCollector(t, &status)
+			sink := otlpSink(t, map[string]any{"endpoint": server.URL, "disableMetrics": true})
+			if err := sink.WriteAlert(context.Background(), model.Alert{ID: "a", Severity: tt.severity}); err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if logs, _ := c.received(); len(logs) != 1 || logs[0].SeverityNumber != tt.want {
+				t.Errorf("严重程度 = %v, want %v", logs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package otlp
+
+import (
+	"dnsflux/internal/model"
+	"strings"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// 语义约定属性名
+const (
+	attrQuestionName   = "dns.question.name"
+	attrQuestionType   = "dns.question.type"
+	attrResponseCode   = "dns.response_code"
+	attrAnswers        = "dns.answers"
+	attrTransactionID  = "dns.id"
+	attrTransport      = "network.transport"
+	attrServerAddress  = "server.address"
+	attrServerPort     = "server.port"
+	attrClientAddress  = "client.address"
+	attrLatency        = "dns.lookup.duration"
+	attrPID            = "process.pid"
+	attrParentPID      = "process.parent_pid"
+	attrExecutableName = "process.executable.name"
+	attrExecutablePath = "process.executable.path"
+	attrCommandLine    = "process.command_line"
+	attrOwner          = "process.owner"
+	attrRecordID       = "dnsflux.record.id"
+	attrSource         = "dnsflux.source"
+	attrAlertID        = "dnsflux.alert.id"
+	attrAlertDetector  = "dnsflux.alert.detector"
+	attrAlertRule      = "dnsflux.alert.rule"
+	attrAlertSeverity  = "dnsflux.alert.severity"
+	attrAlertCount     = "dnsflux.alert.count"
+	attrAlertStatus    = "dnsflux.alert.status"
+	attrAlertRecords   = "dnsflux.alert.record_ids"
+	attrTechniqueID    = "threat.technique.id"
+)
+
+// toLogRecord 将 DNS 记录转换为 OTLP 日志记录
+func toLogRecord(record *model.DNSRecord, observed uint64) *logspb.LogRecord {
+	attrs := []*commonpb.KeyValue{
+		stringAttr(attrQuestionName, record.QueryName),
+		stringAttr(attrQuestionType, record.QueryType),
+		intAttr(attrTransactionID, int64(record.TransactionID)),
+		intAttr(attrPID, int64(record.ProcessID)),
+		stringAttr(attrRecordID, record.ID),
+	}
+	attrs = appendString(attrs, attrResponseCode, record.RCode)
+	attrs = appendString(attrs, attrTransport, record.Transport)
+	attrs = appendString(attrs, attrServerAddress, record.ServerIP)
+	if record.ServerPort != 0 {
+		attrs = append(attrs, intAttr(attrServerPort, int64(record.ServerPort)))
+	}
+	if record.ClientIP != "-" {
+		attrs = appendString(attrs, attrClientAddress, record.ClientIP)
+	}
+	if record.ParentPID != 0 {
+		attrs = append(attrs, intAttr(attrParentPID, int64(record.ParentPID)))
+	}
+	attrs = appendString(attrs, attrExecutableName, record.ProcessName)
+	attrs = appendString(attrs, attrExecutablePath, record.ProcessPath)
+	attrs = appendString(attrs, attrCommandLine, record.ProcessCmdline)
+	attrs = appendString(attrs, attrOwner, record.ProcessUser)
+	attrs = appendString(attrs, attrSource, record.Source)
+	if record.LatencyMs > 0 {
+		attrs = append(attrs, doubleAttr(attrLatency, record.LatencyMs/1000))
+	}
+	if len(record.Answers) > 0 {
+		values := make([]*commonpb.AnyValue, 0, len(record.Answers))
+		for _, answer := range record.Answers {
+			values = append(values, stringValue(answer.Data))
+		}
+		attrs = append(attrs, &commonpb.KeyValue{
+			Key:   attrAnswers,
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}},
+		})
+	}
+
+	// 零值时间的 UnixNano 会溢出，此时按规范留空由接收端使用观测时间
+	var timestamp uint64
+	if !record.Timestamp.IsZero() {
+		timestamp = uint64(record.Timestamp.UnixNano())
+	}
+
+	body := strings.TrimSpace(record.ProcessName + " " + record.QueryType + " " + record.QueryName)
+	return &logspb.LogRecord{
+		TimeUnixNano:         timestamp,
+		ObservedTimeUnixNano: observed,
+		SeverityNumber:       logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+		SeverityText:         "INFO",
+		EventName:            "dns.query",
+		Body:                 stringValue(body),
+		Attributes:           attrs,
+	}
+}
+
+// alertSeverities 告警级别对应的 OTLP 日志严重程度
+var alertSeverities = map[string]logspb.SeverityNumber{
+	model.SeverityInformational: logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+	model.SeverityLow:           logspb.SeverityNumber_SEVERITY_NUMBER_WARN,
+	model.SeverityMedium:        logspb.SeverityNumber_SEVERITY_NUMBER_WARN2,
+	model.SeverityHigh:          logspb.SeverityNumber_SEVERITY_NUMBER_ERROR,
+	model.SeverityCritical:      logspb.SeverityNumber_SEVERITY_NUMBER_FATAL,
+}
+
+// toAlertLogRecord 将告警转换为 OTLP 日志记录，事件名为 dnsflux.alert，正文为告警说明
+func toAlertLogRecord(alert *model.Alert, observed uint64) *logspb.LogRecord {
+	attrs := []*commonpb.KeyValue{
+		stringAttr(attrAlertID, alert.ID),
+		stringAttr(attrAlertDetector, alert.Detector),
+		stringAttr(attrAlertSeverity, alert.Severity),
+		intAttr(attrAlertCount, int64(alert.Count)),
+		stringAttr(attrAlertStatus, alert.Status),
+	}
+	attrs = appendString(attrs, attrAlertRule, alert.Rule)
+	attrs = appendString(attrs, attrTechniqueID, alert.Technique)
+	attrs = appendString(attrs, attrQuestionName, alert.QueryName)
+	attrs = appendString(attrs, attrQuestionType, alert.QueryType)
+	attrs = appendString(attrs, attrClientAddress, alert.ClientIP)
+	if alert.ProcessID != 0 {
+		attrs = append(attrs, intAttr(attrPID, int64(alert.ProcessID)))
+	}
+	attrs = appendString(attrs, attrExecutableName, alert.ProcessName)
+	attrs = appendString(attrs, attrExecutablePath, alert.ProcessPath)
+	if len(alert.RecordIDs) > 0 {
+		values := make([]*commonpb.AnyValue, 0, len(alert.RecordIDs))
+		for _, id := range alert.RecordIDs {
+			values = append(values, stringValue(id))
+		}
+		attrs = append(attrs, &commonpb.KeyValue{
+			Key:   attrAlertRecords,
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}},
+		})
+	}
+
+	var timestamp uint64
+	if !alert.FirstSeen.IsZero() {
+		timestamp = uint64(alert.FirstSeen.UnixNano())
+	}
+
+	severity, ok := alertSeverities[alert.Severity]
+	if !ok {
+		severity = logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	}
+	return &logspb.LogRecord{
+		TimeUnixNano:         timestamp,
+		ObservedTimeUnixNano: observed,
+		SeverityNumber:       severity,
+		SeverityText:         strings.ToUpper(alert.Severity),
+		EventName:            "dnsflux.alert",
+		Body:                 stringValue(alert.Message),
+		Attributes:           attrs,
+	}
+}
+
+// stringValue 字符串属性值
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+// stringAttr 字符串属性
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: stringValue(value)}
+}
+
+// intAttr 整数属性
+func intAttr(key string, value int64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}}}
+}
+
+// doubleAttr 浮点属性
+func doubleAttr(key string, value float64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: value}}}
+}
+
+// appendString 值非空时追加字符串属性
+func appendString(attrs []*commonpb.KeyValue, key, value string) []*commonpb.KeyValue {
+	if value == "" {
+		return attrs
+	}
+	return append(attrs, stringAttr(key, value))
+}
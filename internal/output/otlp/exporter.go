@@ -0,0 +1,174 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// exporter OTLP 传输层
+type exporter interface {
+	exportLogs(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error
+	exportMetrics(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error
+	close() error
+}
+
+// httpExporter OTLP/HTTP protobuf 传输
+type httpExporter struct {
+	client   *http.Client
+	endpoint string
+	headers  map[string]string
+	gzip     bool
+}
+
+// newHTTPExporter 创建 OTLP/HTTP 传输，endpoint 为不含 /v1/logs 的基础地址
+func newHTTPExporter(opts Options, tlsConfig *tls.Config) *httpExporter {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	endpoint := strings.TrimRight(opts.Endpoint, "/")
+	if !strings.Contains(endpoint, "://") {
+		scheme := "https://"
+		if opts.Insecure {
+			scheme = "http://"
+		}
+		endpoint = scheme + endpoint
+	}
+	return &httpExporter{
+		client:   &http.Client{Timeout: opts.Timeout.Std(), Transport: transport},
+		endpoint: endpoint,
+		headers:  opts.Headers,
+		gzip:     opts.Compression == "gzip",
+	}
+}
+
+func (e *httpExporter) exportLogs(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	return e.post(ctx, "/v1/logs", req)
+}
+
+func (e *httpExporter) exportMetrics(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	return e.post(ctx, "/v1/metrics", req)
+}
+
+// post 以 protobuf 编码发送请求
+func (e *httpExporter) post(ctx context.Context, path string, msg proto.Message) error {
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("OTLP 编码失败: %w", err)
+	}
+	if e.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		zw.Close()
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if e.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("OTLP 请求 %s 失败: %w", req.URL, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP 请求 %s 返回 %s", req.URL, resp.Status)
+	}
+	return nil
+}
+
+func (e *httpExporter) close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// grpcExporter OTLP/gRPC 传输
+type grpcExporter struct {
+	conn    *grpc.ClientConn
+	logs    collogspb.LogsServiceClient
+	metrics colmetricspb.MetricsServiceClient
+	md      metadata.MD
+	opts    []grpc.CallOption
+}
+
+// newGRPCExporter 创建 OTLP/gRPC 传输，endpoint 为 host:port
+func newGRPCExporter(opts Options, tlsConfig *tls.Config) (*grpcExporter, error) {
+	endpoint := opts.Endpoint
+	plaintext := opts.Insecure
+	if scheme, rest, ok := strings.Cut(endpoint, "://"); ok {
+		endpoint = strings.TrimRight(rest, "/")
+		plaintext = plaintext || scheme == "http"
+	}
+
+	creds := insecure.NewCredentials()
+	if !plaintext {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("创建 OTLP gRPC 连接失败: %w", err)
+	}
+
+	md := metadata.New(nil)
+	for k, v := range opts.Headers {
+		md.Set(strings.ToLower(k), v)
+	}
+	var callOpts []grpc.CallOption
+	if opts.Compression == "gzip" {
+		callOpts = append(callOpts, grpc.UseCompressor("gzip"))
+	}
+
+	return &grpcExporter{
+		conn:    conn,
+		logs:    collogspb.NewLogsServiceClient(conn),
+		metrics: colmetricspb.NewMetricsServiceClient(conn),
+		md:      md,
+		opts:    callOpts,
+	}, nil
+}
+
+func (e *grpcExporter) exportLogs(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	_, err := e.logs.Export(metadata.NewOutgoingContext(ctx, e.md), req, e.opts...)
+	if err != nil {
+		return fmt.Errorf("OTLP gRPC 日志导出失败: %w", err)
+	}
+	return nil
+}
+
+func (e *grpcExporter) exportMetrics(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	_, err := e.metrics.Export(metadata.NewOutgoingContext(ctx, e.md), req, e.opts...)
+	if err != nil {
+		return fmt.Errorf("OTLP gRPC 指标导出失败: %w", err)
+	}
+	return nil
+}
+
+func (e *grpcExporter) close() error {
+	return e.conn.Close()
+}
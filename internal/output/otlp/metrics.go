@@ -0,0 +1,154 @@
+package otlp
+
+import (
+	"dnsflux/internal/model"
+	"sort"
+	"sync"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// maxSeries 每个指标的最大序列数，超出部分归入溢出序列
+const maxSeries = 2000
+
+// overflowKey 溢出序列的键
+var overflowKey = seriesKey{process: "_overflow_", queryType: "_overflow_"}
+
+// 延迟直方图边界（毫秒）
+var latencyBounds = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// seriesKey 指标序列维度：进程名与查询类型
+type seriesKey struct {
+	process   string
+	queryType string
+}
+
+// histogram 延迟直方图
+type histogram struct {
+	count   uint64
+	sum     float64
+	min     float64
+	max     float64
+	buckets []uint64
+}
+
+// aggregator 累积的查询计数与延迟直方图
+type aggregator struct {
+	mu        sync.Mutex
+	startTime uint64
+	queries   map[seriesKey]uint64
+	latency   map[seriesKey]*histogram
+}
+
+// newAggregator 创建指标聚合器
+func newAggregator(startTime uint64) *aggregator {
+	return &aggregator{
+		startTime: startTime,
+		queries:   make(map[seriesKey]uint64),
+		latency:   make(map[seriesKey]*histogram),
+	}
+}
+
+// observe 记录一次查询
+func (a *aggregator) observe(record *model.DNSRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := seriesKey{process: record.ProcessName, queryType: record.QueryType}
+	if _, ok := a.queries[key]; !ok && len(a.queries) >= maxSeries {
+		key = overflowKey
+	}
+	a.queries[key]++
+
+	if record.LatencyMs <= 0 {
+		return
+	}
+	h, ok := a.latency[key]
+	if !ok {
+		if len(a.latency) >= maxSeries {
+			key = overflowKey
+			h = a.latency[key]
+		}
+		if h == nil {
+			h = &histogram{min: record.LatencyMs, max: record.LatencyMs, buckets: make([]uint64, len(latencyBounds)+1)}
+			a.latency[key] = h
+		}
+	}
+	h.count++
+	h.sum += record.LatencyMs
+	if record.LatencyMs < h.min {
+		h.min = record.LatencyMs
+	}
+	if record.LatencyMs > h.max {
+		h.max = record.LatencyMs
+	}
+	idx := sort.SearchFloat64s(latencyBounds, record.LatencyMs)
+	h.buckets[idx]++
+}
+
+// snapshot 生成当前累积值的指标数据
+func (a *aggregator) snapshot(now uint64) []*metricspb.Metric {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.queries) == 0 {
+		return nil
+	}
+
+	counts := make([]*metricspb.NumberDataPoint, 0, len(a.queries))
+	for key, n := range a.queries {
+		counts = append(counts, &metricspb.NumberDataPoint{
+			Attributes:        key.attributes(),
+			StartTimeUnixNano: a.startTime,
+			TimeUnixNano:      now,
+			Value:             &metricspb.NumberDataPoint_AsInt{AsInt: int64(n)},
+		})
+	}
+	metrics := []*metricspb.Metric{{
+		Name:        "dns.queries",
+		Description: "Number of DNS queries observed",
+		Unit:        "{query}",
+		Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			DataPoints:             counts,
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}},
+	}}
+
+	if len(a.latency) > 0 {
+		points := make([]*metricspb.HistogramDataPoint, 0, len(a.latency))
+		for key, h := range a.latency {
+			sum, min, max := h.sum, h.min, h.max
+			points = append(points, &metricspb.HistogramDataPoint{
+				Attributes:        key.attributes(),
+				StartTimeUnixNano: a.startTime,
+				TimeUnixNano:      now,
+				Count:             h.count,
+				Sum:               &sum,
+				Min:               &min,
+				Max:               &max,
+				BucketCounts:      append([]uint64(nil), h.buckets...),
+				ExplicitBounds:    latencyBounds,
+			})
+		}
+		metrics = append(metrics, &metricspb.Metric{
+			Name:        "dns.lookup.duration",
+			Description: "DNS lookup latency",
+			Unit:        "ms",
+			Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				DataPoints:             points,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			}},
+		})
+	}
+	return metrics
+}
+
+// attributes 序列维度属性
+func (k seriesKey) attributes() []*commonpb.KeyValue {
+	return []*commonpb.KeyValue{
+		stringAttr(attrExecutableName, k.process),
+		stringAttr(attrQuestionType, k.queryType),
+	}
+}
@@ -0,0 +1,289 @@
+package otlp
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/utils"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	output.Register("otlp", New)
+}
+
+// 传输协议
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc"
+)
+
+// scopeName 插桩范围名称
+const scopeName = "dnsflux"
+
+// Options OTLP 输出选项
+type Options struct {
+	Protocol           string            `json:"protocol"` // http, grpc
+	Endpoint           string            `json:"endpoint"` // 默认 http://127.0.0.1:4318（http）或 127.0.0.1:4317（grpc）
+	Insecure           bool              `json:"insecure"`
+	Headers            keyValues         `json:"headers"`
+	Compression        string            `json:"compression"` // gzip 或空
+	ServiceName        string            `json:"serviceName"`
+	ResourceAttributes keyValues         `json:"resourceAttributes"`
+	BatchSize          int               `json:"batchSize"`
+	MaxPending         int               `json:"maxPending"`
+	MetricsInterval    output.Duration   `json:"metricsInterval"`
+	DisableLogs        bool              `json:"disableLogs"`
+	DisableMetrics     bool              `json:"disableMetrics"`
+	Timeout            output.Duration   `json:"timeout"`
+	TLS                output.TLSOptions `json:"tls"`
+}
+
+// Sink OTLP 输出目标，将记录作为日志导出，并周期性导出查询计数与延迟指标
+type Sink struct {
+	name        string
+	opts        Options
+	exporter    exporter
+	resource    *resourcepb.Resource
+	scope       *commonpb.InstrumentationScope
+	pending     []*logspb.LogRecord
+	metrics     *aggregator
+	lastMetrics time.Time
+}
+
+// New 根据配置创建 OTLP 输出目标
+func New(config output.SinkConfig) (output.Sink, error) {
+	opts := Options{
+		Protocol:        ProtocolHTTP,
+		ServiceName:     "dnsflux",
+		BatchSize:       512,
+		MaxPending:      8192,
+		MetricsInterval: output.Duration(30 * time.Second),
+		Timeout:         output.Duration(10 * time.Second),
+	}
+	if err := config.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := opts.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	var exp exporter
+	switch opts.Protocol {
+	case ProtocolHTTP:
+		if opts.Endpoint == "" {
+			opts.Endpoint = "http://127.0.0.1:4318"
+		}
+		exp = newHTTPExporter(opts, tlsConfig)
+	case ProtocolGRPC:
+		if opts.Endpoint == "" {
+			opts.Endpoint, opts.Insecure = "127.0.0.1:4317", true
+		}
+		if exp, err = newGRPCExporter(opts, tlsConfig); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("未知的 OTLP 协议: %s", opts.Protocol)
+	}
+
+	now := time.Now()
+	return &Sink{
+		name:        config.Name,
+		opts:        opts,
+		exporter:    exp,
+		resource:    buildResource(opts),
+		scope:       &commonpb.InstrumentationScope{Name: scopeName, Version: output.Version},
+		metrics:     newAggregator(uint64(now.UnixNano())),
+		lastMetrics: now,
+	}, nil
+}
+
+// buildResource 构建资源属性
+func buildResource(opts Options) *resourcepb.Resource {
+	hostID, hostname := utils.HostIdentity()
+	attrs := map[string]string{
+		"service.name":    opts.ServiceName,
+		"service.version": output.Version,
+		"host.name":       hostname,
+		"host.id":         hostID,
+	}
+	for k, v := range opts.ResourceAttributes {
+		attrs[k] = v
+	}
+
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	resource := &resourcepb.Resource{}
+	for _, k := range keys {
+		resource.Attributes = append(resource.Attributes, stringAttr(k, attrs[k]))
+	}
+	return resource
+}
+
+// Name 返回输出目标名称
+func (s *Sink) Name() string {
+	return s.name
+}
+
+// Write 转换记录并加入待导出批次，批次已满时立即导出
+// 导出失败的记录保留在批次中由下一次刷新重试，因此此处不返回错误
+func (s *Sink) Write(ctx context.Context, record model.DNSRecord) error {
+	if !s.opts.DisableMetrics {
+		s.metrics.observe(&record)
+	}
+	if s.opts.DisableLogs {
+		return nil
+	}
+
+	s.appendLog(ctx, toLogRecord(&record, uint64(time.Now().UnixNano())))
+	return nil
+}
+
+// WriteAlert 将告警转换为日志记录加入待导出批次，不受 disableLogs 影响
+func (s *Sink) WriteAlert(ctx context.Context, alert model.Alert) error {
+	s.appendLog(ctx, toAlertLogRecord(&alert, uint64(time.Now().UnixNano())))
+	return nil
+}
+
+// appendLog 加入待导出批次，批次已满时立即导出
+func (s *Sink) appendLog(ctx context.Context, log *logspb.LogRecord) {
+	s.pending = append(s.pending, log)
+	if over := len(s.pending) - s.opts.MaxPending; s.opts.MaxPending > 0 && over > 0 {
+		// 目标长时间不可用，丢弃最旧的记录
+		s.pending = s.pending[over:]
+	}
+	if len(s.pending) >= s.opts.BatchSize {
+		_ = s.flushLogs(ctx)
+	}
+}
+
+// Flush 导出待发送日志，并在到达指标周期时导出指标
+func (s *Sink) Flush(ctx context.Context) error {
+	if err := s.flushLogs(ctx); err != nil {
+		return err
+	}
+	if !s.opts.DisableMetrics && time.Since(s.lastMetrics) >= s.opts.MetricsInterval.Std() {
+		if err := s.flushMetrics(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close 导出剩余数据并关闭连接
+func (s *Sink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout.Std())
+	defer cancel()
+
+	err := s.flushLogs(ctx)
+	if !s.opts.DisableMetrics {
+		if metricsErr := s.flushMetrics(ctx); err == nil {
+			err = metricsErr
+		}
+	}
+	s.exporter.close()
+	return err
+}
+
+// flushLogs 导出待发送的日志记录
+func (s *Sink) flushLogs(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout.Std())
+	defer cancel()
+
+	req := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: s.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      s.scope,
+				LogRecords: s.pending,
+			}},
+		}},
+	}
+	if err := s.exporter.exportLogs(ctx, req); err != nil {
+		return err
+	}
+	s.pending = nil
+	return nil
+}
+
+// flushMetrics 导出累积指标
+func (s *Sink) flushMetrics(ctx context.Context) error {
+	now := time.Now()
+	metrics := s.metrics.snapshot(uint64(now.UnixNano()))
+	if len(metrics) == 0 {
+		s.lastMetrics = now
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout.Std())
+	defer cancel()
+
+	req := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: s.resource,
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   s.scope,
+				Metrics: metrics,
+			}},
+		}},
+	}
+	if err := s.exporter.exportMetrics(ctx, req); err != nil {
+		return err
+	}
+	s.lastMetrics = now
+	return nil
+}
+
+// keyValues 键值对选项，既可以是 JSON 对象，也可以是 OTEL 环境变量风格的 "k1=v1,k2=v2" 字符串，
+// 以便在命令行输出目标定义中直接填写
+type keyValues map[string]string
+
+// UnmarshalJSON 解析对象或逗号分隔的键值对字符串
+func (kv *keyValues) UnmarshalJSON(data []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err == nil {
+		*kv = m
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("键值对选项格式无效: %s", data)
+	}
+	m = make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("键值对选项 %q 缺少 '='", pair)
+		}
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = unescaped
+		}
+		m[key] = value
+	}
+	*kv = m
+	return nil
+}
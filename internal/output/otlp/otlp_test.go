@@ -0,0 +1,494 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector 模拟 OTLP 接收端，保存收到的日志与指标请求
+type collector struct {
+	mu      sync.Mutex
+	logs    []*collogspb.ExportLogsServiceRequest
+	metrics []*colmetricspb.ExportMetricsServiceRequest
+	headers []http.Header
+	md      []metadata.MD
+}
+
+// received 返回收到的日志记录与指标
+func (c *collector) received() ([]*logspb.LogRecord, []*metricspb.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var logs []*logspb.LogRecord
+	for _, req := range c.logs {
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				logs = append(logs, sl.LogRecords...)
+			}
+		}
+	}
+	var metrics []*metricspb.Metric
+	for _, req := range c.metrics {
+		for _, rm := range req.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				metrics = append(metrics, sm.Metrics...)
+			}
+		}
+	}
+	return logs, metrics
+}
+
+// httpCollector 启动 OTLP/HTTP 接收端，status 非 0 时返回该状态码
+func httpCollector(t *testing.T, status *atomic.Int32) (*collector, *httptest.Server) {
+	t.Helper()
+	c := &collector{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code := status.Load(); code != 0 {
+			w.WriteHeader(int(code))
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/x-protobuf" {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		var reader io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			reader = zr
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.headers = append(c.headers, r.Header.Clone())
+		switch r.URL.Path {
+		case "/v1/logs":
+			req := &collogspb.ExportLogsServiceRequest{}
+			if err := proto.Unmarshal(body, req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			c.logs = append(c.logs, req)
+		case "/v1/metrics":
+			req := &colmetricspb.ExportMetricsServiceRequest{}
+			if err := proto.Unmarshal(body, req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			c.metrics = append(c.metrics, req)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	t.Cleanup(server.Close)
+	return c, server
+}
+
+// grpcLogs OTLP/gRPC 日志服务
+type grpcLogs struct {
+	collogspb.UnimplementedLogsServiceServer
+	c *collector
+}
+
+func (s grpcLogs) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.c.mu.Lock()
+	s.c.logs = append(s.c.logs, req)
+	s.c.md = append(s.c.md, md)
+	s.c.mu.Unlock()
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+// grpcMetrics OTLP/gRPC 指标服务
+type grpcMetrics struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	c *collector
+}
+
+func (s grpcMetrics) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	s.c.mu.Lock()
+	s.c.metrics = append(s.c.metrics, req)
+	s.c.mu.Unlock()
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+// grpcCollector 启动 OTLP/gRPC 接收端
+func grpcCollector(t *testing.T) (*collector, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &collector{}
+	server := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(server, grpcLogs{c: c})
+	colmetricspb.RegisterMetricsServiceServer(server, grpcMetrics{c: c})
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+	return c, ln.Addr().String()
+}
+
+// otlpSink 创建导出到测试接收端的 OTLP 输出目标
+func otlpSink(t *testing.T, options map[string]any) *Sink {
+	t.Helper()
+	sink, err := New(output.SinkConfig{Name: "test", Type: "otlp", Options: options})
+	if err != nil {
+		t.Fatalf("创建 OTLP 输出目标失败: %v", err)
+	}
+	return sink.(*Sink)
+}
+
+// curlQuery 返回一次 curl 发起的查询，填充全部映射为语义约定属性的字段
+func curlQuery() model.DNSRecord {
+	return model.DNSRecord{
+		ID:             "rec-1",
+		Timestamp:      time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+		Source:         "ebpf",
+		Transport:      "udp",
+		ClientIP:       "10.0.0.5",
+		ServerIP:       "8.8.8.8",
+		ServerPort:     53,
+		TransactionID:  4660,
+		QueryName:      "example.com",
+		QueryType:      "A",
+		RCode:          "NOERROR",
+		Answers:        []model.DNSAnswer{{Type: "A", Data: "93.184.216.34"}, {Type: "A", Data: "93.184.216.35"}},
+		LatencyMs:      12.5,
+		ProcessID:      1234,
+		ParentPID:      1,
+		ProcessName:    "curl",
+		ProcessPath:    "/usr/bin/curl",
+		ProcessUser:    "alice",
+		ProcessCmdline: "curl https://example.com",
+	}
+}
+
+// attrMap 将属性列表转换为便于比较的映射
+func attrMap(attrs []*commonpb.KeyValue) map[string]any {
+	m := make(map[string]any, len(attrs))
+	for _, kv := range attrs {
+		m[kv.Key] = anyValue(kv.Value)
+	}
+	return m
+}
+
+// anyValue 取出属性值
+func anyValue(v *commonpb.AnyValue) any {
+	switch value := v.Value.(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue
+	case *commonpb.AnyValue_IntValue:
+		return value.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return value.DoubleValue
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]any, 0, len(value.ArrayValue.Values))
+		for _, item := range value.ArrayValue.Values {
+			values = append(values, anyValue(item))
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// checkAttrs 检查属性值
+func checkAttrs(t *testing.T, what string, got map[string]any, want map[string]any) {
+	t.Helper()
+	for key, value := range want {
+		if gotSlice, ok := got[key].([]any); ok {
+			wantSlice := value.([]any)
+			if len(gotSlice) != len(wantSlice) {
+				t.Errorf("%s 属性 %s = %v, want %v", what, key, got[key], value)
+				continue
+			}
+			for i := range gotSlice {
+				if gotSlice[i] != wantSlice[i] {
+					t.Errorf("%s 属性 %s = %v, want %v", what, key, got[key], value)
+					break
+				}
+			}
+			continue
+		}
+		if got[key] != value {
+			t.Errorf("%s 属性 %s = %v (%T), want %v (%T)", what, key, got[key], got[key], value, value)
+		}
+	}
+}
+
+func TestHTTPLogs(t *testing.T) {
+	var status atomic.Int32
+	c, server := httpCollector(t, &status)
+	sink := otlpSink(t, map[string]any{
+		"endpoint":           server.URL + "/",
+		"headers":            "authorization=Bearer%20token,x-tenant=blue",
+		"resourceAttributes": map[string]string{"deployment.environment": "prod"},
+		"compression":        "gzip",
+		"disableMetrics":     true,
+	})
+	if err := sink.Write(context.Background(), curlQuery()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+
+	c.mu.Lock()
+	if len(c.logs) != 1 || len(c.metrics) != 0 {
+		t.Fatalf("got %d 个日志请求、%d 个指标请求, want 1、0", len(c.logs), len(c.metrics))
+	}
+	header := c.headers[0]
+	resourceLogs := c.logs[0].ResourceLogs[0]
+	c.mu.Unlock()
+
+	if header.Get("Authorization") != "Bearer token" || header.Get("X-Tenant") != "blue" {
+		t.Errorf("请求头错误: %v", header)
+	}
+	if header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("缺少 Content-Encoding: gzip")
+	}
+	checkAttrs(t, "资源", attrMap(resourceLogs.Resource.Attributes), map[string]any{
+		"service.name":           "dnsflux",
+		"service.version":        output.Version,
+		"deployment.environment": "prod",
+	})
+	if scope := resourceLogs.ScopeLogs[0].Scope; scope.Name != scopeName {
+		t.Errorf("插桩范围 = %q", scope.Name)
+	}
+
+	logs, _ := c.received()
+	if len(logs) != 1 {
+		t.Fatalf("got %d 条日志, want 1", len(logs))
+	}
+
+	query := logs[0]
+	if query.EventName != "dns.query" || query.SeverityNumber != logspb.SeverityNumber_SEVERITY_NUMBER_INFO {
+		t.Errorf("查询日志事件 = %q, 严重程度 = %v", query.EventName, query.SeverityNumber)
+	}
+	if query.TimeUnixNano != uint64(curlQuery().Timestamp.UnixNano()) || query.ObservedTimeUnixNano == 0 {
+		t.Errorf("查询日志时间 = %d, 观测时间 = %d", query.TimeUnixNano, query.ObservedTimeUnixNano)
+	}
+	if body := query.Body.GetStringValue(); body != "curl A example.com" {
+		t.Errorf("查询日志正文 = %q", body)
+	}
+	checkAttrs(t, "查询日志", attrMap(query.Attributes), map[string]any{
+		attrQuestionName:   "example.com",
+		attrQuestionType:   "A",
+		attrResponseCode:   "NOERROR",
+		attrTransactionID:  int64(4660),
+		attrTransport:      "udp",
+		attrServerAddress:  "8.8.8.8",
+		attrServerPort:     int64(53),
+		attrClientAddress:  "10.0.0.5",
+		attrLatency:        0.0125,
+		attrPID:            int64(1234),
+		attrParentPID:      int64(1),
+		attrExecutableName: "curl",
+		attrExecutablePath: "/usr/bin/curl",
+		attrCommandLine:    "curl https://example.com",
+		attrOwner:          "alice",
+		attrRecordID:       "rec-1",
+		attrSource:         "ebpf",
+		attrAnswers:        []any{"93.184.216.34", "93.184.216.35"},
+	})
+
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	var status atomic.Int32
+	c, server := httpCollector(t, &status)
+	sink := otlpSink(t, map[string]any{
+		"endpoint":    server.URL,
+		"disableLogs": true,
+	})
+
+	ctx := context.Background()
+	for _, r := range []struct {
+		process, queryType string
+		latency            float64
+	}{
+		{"curl", "A", 1},
+		{"curl", "A", 30},
+		{"curl", "AAAA", 0},
+		{"firefox", "A", 6000},
+	} {
+		record := curlQuery()
+		record.ProcessName, record.QueryType, record.LatencyMs = r.process, r.queryType, r.latency
+		if err := sink.Write(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+
+	logs, metrics := c.received()
+	if len(logs) != 0 {
+		t.Errorf("disableLogs 时不应导出日志, got %d 条", len(logs))
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("got %d 个指标, want 2", len(metrics))
+	}
+
+	queries := metrics[0]
+	if queries.Name != "dns.queries" || !queries.GetSum().IsMonotonic ||
+		queries.GetSum().AggregationTemporality != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Fatalf("查询计数指标错误: %v", queries)
+	}
+	counts := make(map[string]int64)
+	for _, point := range queries.GetSum().DataPoints {
+		attrs := attrMap(point.Attributes)
+		counts[attrs[attrExecutableName].(string)+"/"+attrs[attrQuestionType].(string)] = point.GetAsInt()
+		if point.StartTimeUnixNano == 0 || point.TimeUnixNano < point.StartTimeUnixNano {
+			t.Errorf("数据点时间错误: %d %d", point.StartTimeUnixNano, point.TimeUnixNano)
+		}
+	}
+	if counts["curl/A"] != 2 || counts["curl/AAAA"] != 1 || counts["firefox/A"] != 1 || len(counts) != 3 {
+		t.Errorf("查询计数 = %v", counts)
+	}
+
+	latency := metrics[1]
+	if latency.Name != "dns.lookup.duration" || latency.Unit != "ms" {
+		t.Fatalf("延迟指标错误: %v", latency)
+	}
+	histograms := make(map[string]*metricspb.HistogramDataPoint)
+	for _, point := range latency.GetHistogram().DataPoints {
+		attrs := attrMap(point.Attributes)
+		histograms[attrs[attrExecutableName].(string)+"/"+attrs[attrQuestionType].(string)] = point
+	}
+	// 无延迟的记录不进入直方图
+	if len(histograms) != 2 {
+		t.Fatalf("got %d 个直方图序列, want 2", len(histograms))
+	}
+	curl := histograms["curl/A"]
+	if curl.Count != 2 || curl.GetSum() != 31 || curl.GetMin() != 1 || curl.GetMax() != 30 {
+		t.Errorf("curl 直方图 count=%d sum=%v min=%v max=%v", curl.Count, curl.GetSum(), curl.GetMin(), curl.GetMax())
+	}
+	// 边界值归入上界所在的桶：1 落入 (-inf,1]，30 落入 (25,50]
+	if len(curl.BucketCounts) != len(latencyBounds)+1 || curl.BucketCounts[0] != 1 || curl.BucketCounts[5] != 1 {
+		t.Errorf("curl 直方图桶 = %v", curl.BucketCounts)
+	}
+	if firefox := histograms["firefox/A"]; firefox.BucketCounts[len(latencyBounds)] != 1 {
+		t.Errorf("超出最大边界的延迟应落入溢出桶: %v", firefox.BucketCounts)
+	}
+}
+
+func TestHTTPRetainsPendingOnFailure(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	c, server := httpCollector(t, &status)
+	sink := otlpSink(t, map[string]any{
+		"endpoint":       server.URL,
+		"batchSize":      2,
+		"maxPending":     3,
+		"disableMetrics": true,
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		record := curlQuery()
+		record.ID = string(rune('a' + i))
+		sink.Write(ctx, record)
+	}
+	if err := sink.Flush(ctx); err == nil {
+		t.Fatal("接收端不可用时刷新应返回错误")
+	}
+	// 超出 maxPending 时丢弃最旧的记录
+	if len(sink.pending) != 3 {
+		t.Fatalf("待导出 %d 条, want 3", len(sink.pending))
+	}
+
+	status.Store(0)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("接收端恢复后导出失败: %v", err)
+	}
+	logs, _ := c.received()
+	var ids string
+	for _, log := range logs {
+		ids += attrMap(log.Attributes)[attrRecordID].(string)
+	}
+	if ids != "cde" {
+		t.Errorf("导出的记录为 %q, want %q", ids, "cde")
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	c, addr := grpcCollector(t)
+	sink := otlpSink(t, map[string]any{
+		"protocol":    ProtocolGRPC,
+		"endpoint":    "http://" + addr,
+		"headers":     map[string]string{"X-Tenant": "blue"},
+		"compression": "gzip",
+		"serviceName": "dns-sensor",
+	})
+
+	if err := sink.Write(context.Background(), curlQuery()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("gRPC 导出失败: %v", err)
+	}
+
+	logs, metrics := c.received()
+	if len(logs) != 1 || len(metrics) != 2 {
+		t.Fatalf("got %d 条日志、%d 个指标, want 1、2", len(logs), len(metrics))
+	}
+	checkAttrs(t, "查询日志", attrMap(logs[0].Attributes), map[string]any{
+		attrQuestionName: "example.com",
+		attrRecordID:     "rec-1",
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if got := c.md[0].Get("x-tenant"); len(got) != 1 || got[0] != "blue" {
+		t.Errorf("gRPC 元数据 x-tenant = %v", got)
+	}
+	checkAttrs(t, "资源", attrMap(c.logs[0].ResourceLogs[0].Resource.Attributes), map[string]any{
+		"service.name": "dns-sensor",
+	})
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]any
+	}{
+		{"未知协议", map[string]any{"protocol": "thrift"}},
+		{"请求头缺少等号", map[string]any{"headers": "authorization"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(output.SinkConfig{Name: "test", Type: "otlp", Options: tt.options}); err == nil {
+				t.Fatal("应返回错误")
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package pcapng
+
+import (
+	"dnsflux/internal/model"
+	"encoding/binary"
+	"net"
+)
+
+// IP 协议号
+const (
+	protoTCP = 6
+	protoUDP = 17
+)
+
+// endpoint 重建报文使用的客户端与服务器地址
+type endpoint struct {
+	clientIP   net.IP
+	serverIP   net.IP
+	clientPort uint16
+	serverPort uint16
+	tcp        bool
+}
+
+// recordEndpoint 从记录中推导报文的地址、端口与传输层协议
+// 记录中未采集到的客户端端口由事务 ID 映射到临时端口范围，使同一查询的请求与响应可以关联
+func recordEndpoint(record *model.DNSRecord) endpoint {
+	ep := endpoint{
+		clientIP:   net.ParseIP(record.ClientIP),
+		serverIP:   net.ParseIP(record.ServerIP),
+		clientPort: 49152 + record.TransactionID%16384,
+		serverPort: record.ServerPort,
+	}
+
+	switch record.Transport {
+	case model.TransportTCP, model.TransportDoT:
+		ep.tcp = true
+	}
+	if ep.serverPort == 0 {
+		switch record.Transport {
+		case model.TransportDoT, model.TransportDoQ:
+			ep.serverPort = 853
+		case model.TransportDoH:
+			ep.serverPort = 443
+		default:
+			ep.serverPort = 53
+		}
+	}
+
+	// 缺失的地址使用未指定地址，地址族不一致时统一为 IPv6
+	v6 := (ep.clientIP != nil && ep.clientIP.To4() == nil) || (ep.serverIP != nil && ep.serverIP.To4() == nil)
+	ep.clientIP = normalizeIP(ep.clientIP, v6)
+	ep.serverIP = normalizeIP(ep.serverIP, v6)
+	return ep
+}
+
+// normalizeIP 将地址转换为指定地址族的字节形式
+func normalizeIP(ip net.IP, v6 bool) net.IP {
+	if ip == nil {
+		if v6 {
+			return net.IPv6unspecified
+		}
+		return net.IPv4zero.To4()
+	}
+	if v6 {
+		return ip.To16()
+	}
+	return ip.To4()
+}
+
+// buildPacket 构建承载 DNS 报文的原始 IP 数据包
+// outbound 为 true 时方向为客户端到服务器，tcpSeq/tcpAck 仅用于 TCP
+func buildPacket(ep endpoint, dns []byte, outbound bool, tcpSeq, tcpAck uint32) []byte {
+	src, dst := ep.clientIP, ep.serverIP
+	sport, dport := ep.clientPort, ep.serverPort
+	if !outbound {
+		src, dst = dst, src
+		sport, dport = dport, sport
+	}
+
+	var transport []byte
+	var proto byte
+	if ep.tcp {
+		proto = protoTCP
+		transport = tcpSegment(sport, dport, tcpSeq, tcpAck, dns)
+	} else {
+		proto = protoUDP
+		transport = udpDatagram(sport, dport, dns)
+	}
+	setTransportChecksum(transport, proto, src, dst)
+
+	if len(src) == net.IPv4len {
+		return append(ipv4Header(src, dst, proto, len(transport)), transport...)
+	}
+	return append(ipv6Header(src, dst, proto, len(transport)), transport...)
+}
+
+// udpDatagram 构建 UDP 数据报（校验和稍后填充）
+func udpDatagram(sport, dport uint16, payload []byte) []byte {
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(b[0:], sport)
+	binary.BigEndian.PutUint16(b[2:], dport)
+	binary.BigEndian.PutUint16(b[4:], uint16(8+len(payload)))
+	return append(b, payload...)
+}
+
+// tcpSegment 构建携带 DNS over TCP 报文（两字节长度前缀）的 TCP 段（校验和稍后填充）
+func tcpSegment(sport, dport uint16, seq, ack uint32, dns []byte) []byte {
+	b := make([]byte, 22, 22+len(dns))
+	binary.BigEndian.PutUint16(b[0:], sport)
+	binary.BigEndian.PutUint16(b[2:], dport)
+	binary.BigEndian.PutUint32(b[4:], seq)
+	binary.BigEndian.PutUint32(b[8:], ack)
+	b[12] = 5 << 4 // 数据偏移：20 字节
+	b[13] = 0x18   // PSH|ACK
+	binary.BigEndian.PutUint16(b[14:], 65535)
+	binary.BigEndian.PutUint16(b[20:], uint16(len(dns)))
+	return append(b, dns...)
+}
+
+// setTransportChecksum 计算并填充 UDP/TCP 校验和
+func setTransportChecksum(segment []byte, proto byte, src, dst net.IP) {
+	var pseudo []byte
+	pseudo = append(pseudo, src...)
+	pseudo = append(pseudo, dst...)
+	if len(src) == net.IPv4len {
+		pseudo = append(pseudo, 0, proto, byte(len(segment)>>8), byte(len(segment)))
+	} else {
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(segment)))
+		pseudo = append(pseudo, 0, 0, 0, proto)
+	}
+
+	offset := 6 // UDP
+	if proto == protoTCP {
+		offset = 16
+	}
+	sum := checksum(checksumAdd(0, pseudo), segment)
+	if sum == 0 && proto == protoUDP {
+		sum = 0xffff
+	}
+	binary.BigEndian.PutUint16(segment[offset:], sum)
+}
+
+// ipv4Header 构建 IPv4 头部
+func ipv4Header(src, dst net.IP, proto byte, payloadLen int) []byte {
+	b := make([]byte, 20)
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:], uint16(20+payloadLen))
+	binary.BigEndian.PutUint16(b[6:], 0x4000) // DF
+	b[8] = 64
+	b[9] = proto
+	copy(b[12:16], src)
+	copy(b[16:20], dst)
+	binary.BigEndian.PutUint16(b[10:], checksum(0, b))
+	return b
+}
+
+// ipv6Header 构建 IPv6 头部
+func ipv6Header(src, dst net.IP, proto byte, payloadLen int) []byte {
+	b := make([]byte, 40)
+	b[0] = 0x60
+	binary.BigEndian.PutUint16(b[4:], uint16(payloadLen))
+	b[6] = proto
+	b[7] = 64
+	copy(b[8:24], src)
+	copy(b[24:40], dst)
+	return b
+}
+
+// checksumAdd 累加 16 位大端字
+func checksumAdd(sum uint32, b []byte) uint32 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	return sum
+}
+
+// checksum 计算互联网校验和
+func checksum(initial uint32, b []byte) uint16 {
+	sum := checksumAdd(initial, b)
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,219 @@
+package pcapng
+
+import (
+	"bytes"
+	"context"
+	"dnsflux/internal/dnswire"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/replay"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// block 读回的 pcapng 块
+type block struct {
+	typ  uint32
+	body []byte
+}
+
+// readBlocks 按小端字节序拆分 pcapng 数据中的块，并检查首尾长度一致
+func readBlocks(t *testing.T, data []byte) []block {
+	t.Helper()
+	var blocks []block
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("块不完整: 剩余 %d 字节", len(data))
+		}
+		typ := binary.LittleEndian.Uint32(data)
+		total := binary.LittleEndian.Uint32(data[4:])
+		if total%4 != 0 || int(total) > len(data) || binary.LittleEndian.Uint32(data[total-4:]) != total {
+			t.Fatalf("块 %#x 长度 %d 无效", typ, total)
+		}
+		blocks = append(blocks, block{typ: typ, body: data[8 : total-4]})
+		data = data[total:]
+	}
+	return blocks
+}
+
+// packets 返回增强数据包块中的原始数据包
+func packets(blocks []block) [][]byte {
+	var out [][]byte
+	for _, b := range blocks {
+		if b.typ == blockEnhancedPacket {
+			n := binary.LittleEndian.Uint32(b.body[12:])
+			out = append(out, b.body[20:20+n])
+		}
+	}
+	return out
+}
+
+// longLabel 返回超过 63 字节的标签，无法编码为 DNS 域名
+var longLabel = strings.Repeat("x", 64)
+
+func TestWriteRecordUnencodable(t *testing.T) {
+	query, err := dnswire.BuildQuery(&model.DNSRecord{QueryName: "example.com", QueryType: "A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		record model.DNSRecord
+	}{
+		{"查询无法重建", model.DNSRecord{QueryName: longLabel + ".example", RCode: "NOERROR"}},
+		// 携带原始查询时查询包可以写入，但响应包需要从记录字段重建
+		{"响应无法重建", model.DNSRecord{QueryName: longLabel + ".example", RCode: "NOERROR", Payload: query}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, "test", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := buf.Len()
+
+			err = w.WriteRecord(&tt.record)
+			var encErr *encodeError
+			if !errors.As(err, &encErr) {
+				t.Fatalf("应返回编码错误, got %v", err)
+			}
+			if buf.Len() != header {
+				t.Errorf("编码失败时不应写入任何数据包, 写入了 %d 字节", buf.Len()-header)
+			}
+		})
+	}
+}
+
+func TestSinkSkipsUnencodable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dns.pcapng")
+	sink, err := NewSink(output.SinkConfig{Name: "test", Type: "pcapng", Options: map[string]any{"path": path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 无法编码的记录被跳过而不返回错误，避免运行器重试并将输出目标标记为不健康
+	ts := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	records := []model.DNSRecord{
+		{Timestamp: ts, QueryName: longLabel + ".example", RCode: "NOERROR"},
+		{Timestamp: ts, QueryName: "example.com", QueryType: "A", RCode: "NOERROR"},
+	}
+	for _, record := range records {
+		if err := sink.Write(context.Background(), record); err != nil {
+			t.Fatalf("写入 %s 失败: %v", record.QueryName, err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(packets(readBlocks(t, data))); got != 2 {
+		t.Errorf("got %d 个数据包, want 2（仅有效记录的查询与响应）", got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 8, 0, 0, 123456000, time.UTC)
+	tests := []struct {
+		name      string
+		responses bool
+		record    model.DNSRecord
+	}{
+		{
+			"UDP IPv4 查询与响应",
+			true,
+			model.DNSRecord{
+				ID: "rec-1", Timestamp: ts, Transport: model.TransportUDP,
+				ClientIP: "10.0.0.5", ServerIP: "8.8.8.8", ServerPort: 53, TransactionID: 0x1234,
+				QueryName: "example.com", QueryType: "A", RCode: "NOERROR", LatencyMs: 12.5,
+				Answers:     []model.DNSAnswer{{Type: "A", Data: "93.184.216.34", TTL: 300}, {Type: "A", Data: "93.184.216.35", TTL: 300}},
+				ProcessName: "curl", ProcessID: 4321, ParentPID: 1, ProcessPath: "/usr/bin/curl", ProcessUser: "alice", Hostname: "workstation",
+			},
+		},
+		{
+			"TCP IPv6 带空格的进程路径",
+			true,
+			model.DNSRecord{
+				ID: "rec-2", Timestamp: ts, Transport: model.TransportTCP,
+				ClientIP: "fd00::5", ServerIP: "2001:4860:4860::8888", ServerPort: 53, TransactionID: 7,
+				QueryName: "mail.example.org", QueryType: "MX", RCode: "NXDOMAIN", LatencyMs: 3,
+				Answers:     []model.DNSAnswer{},
+				ProcessName: "outlook.exe", ProcessID: 88, ProcessPath: `C:\Program Files\Office\outlook.exe`, Hostname: "laptop",
+			},
+		},
+		{
+			"只写查询包",
+			false,
+			model.DNSRecord{
+				ID: "rec-3", Timestamp: ts, Transport: model.TransportUDP,
+				ClientIP: "10.0.0.5", ServerIP: "1.1.1.1", ServerPort: 53, TransactionID: 9,
+				QueryName: "example.net", QueryType: "AAAA", RCode: "NOERROR",
+				Answers:     []model.DNSAnswer{{Type: "AAAA", Data: "2001:db8::1", TTL: 60}},
+				ProcessName: "svchost.exe", ProcessID: 1024,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, "test", tt.responses)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.WriteRecord(&tt.record); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []model.DNSRecord
+			if err := replay.ReadCapture(&buf, func(record model.DNSRecord) error {
+				got = append(got, record)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("读回 %d 条记录, want 1", len(got))
+			}
+
+			want := tt.record
+			if !tt.responses {
+				// 没有响应包时读回的记录只有查询信息
+				want.RCode, want.Answers, want.LatencyMs = "", []model.DNSAnswer{}, 0
+			}
+			r := got[0]
+			fields := []struct {
+				name      string
+				got, want any
+			}{
+				{"时间", r.Timestamp.UTC(), want.Timestamp},
+				{"传输协议", r.Transport, want.Transport},
+				{"客户端地址", r.ClientIP, want.ClientIP},
+				{"服务器地址", r.ServerIP, want.ServerIP},
+				{"服务器端口", r.ServerPort, want.ServerPort},
+				{"事务 ID", r.TransactionID, want.TransactionID},
+				{"查询", r.QueryName + " " + r.QueryType, want.QueryName + " " + want.QueryType},
+				{"响应码", r.RCode, want.RCode},
+				{"应答", r.Answers, want.Answers},
+				{"延迟", r.LatencyMs, want.LatencyMs},
+				{"记录 ID", r.ID, want.ID},
+				{"进程", []any{r.ProcessName, r.ProcessID, r.ParentPID, r.ProcessPath, r.ProcessUser}, []any{want.ProcessName, want.ProcessID, want.ParentPID, want.ProcessPath, want.ProcessUser}},
+				{"主机名", r.Hostname, want.Hostname},
+			}
+			for _, f := range fields {
+				if !reflect.DeepEqual(f.got, f.want) {
+					t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+				}
+			}
+		})
+	}
+}
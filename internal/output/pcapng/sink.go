@@ -0,0 +1,93 @@
+package pcapng
+
+import (
+	"bufio"
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/pkg/logger"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// log pcapng 输出日志
+var log = logger.Component("output").With("sink", "pcapng")
+
+func init() {
+	output.Register("pcapng", NewSink)
+}
+
+// Options pcapng 输出选项
+type Options struct {
+	Path      string `json:"path"`
+	Responses *bool  `json:"responses"` // 是否同时写入重建的响应包，默认 true
+}
+
+// Sink pcapng 文件输出目标
+// 文件以追加方式打开，每次启动写入新的节（pcapng 允许一个文件包含多个节）
+type Sink struct {
+	name   string
+	file   *os.File
+	buf    *bufio.Writer
+	writer *Writer
+}
+
+// NewSink 根据配置创建 pcapng 输出目标
+func NewSink(config output.SinkConfig) (output.Sink, error) {
+	opts := Options{Path: filepath.Join("logs", "dns_records.pcapng")}
+	if err := config.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+	responses := opts.Responses == nil || *opts.Responses
+
+	if dir := filepath.Dir(opts.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建 pcapng 输出目录失败: %w", err)
+		}
+	}
+	file, err := os.OpenFile(opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开 pcapng 文件失败: %w", err)
+	}
+
+	buf := bufio.NewWriter(file)
+	writer, err := NewWriter(buf, "dnsflux "+output.Version, responses)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Sink{name: config.Name, file: file, buf: buf, writer: writer}, nil
+}
+
+// Name 返回输出目标名称
+func (s *Sink) Name() string {
+	return s.name
+}
+
+// Write 写入一条记录
+func (s *Sink) Write(ctx context.Context, record model.DNSRecord) error {
+	err := s.writer.WriteRecord(&record)
+	var encErr *encodeError
+	if errors.As(err, &encErr) {
+		// 无法编码的记录重试也不会成功，直接跳过
+		log.Debug(fmt.Sprintf("跳过无法编码为 pcapng 的记录: %v", err))
+		return nil
+	}
+	return err
+}
+
+// Flush 将缓冲区写入文件
+func (s *Sink) Flush(ctx context.Context) error {
+	return s.buf.Flush()
+}
+
+// Close 刷新缓冲区并关闭文件
+func (s *Sink) Close() error {
+	err := s.buf.Flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
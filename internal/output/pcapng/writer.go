@@ -0,0 +1,186 @@
+package pcapng
+
+import (
+	"dnsflux/internal/dnswire"
+	"dnsflux/internal/model"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// pcapng 块类型
+const (
+	blockSectionHeader    = 0x0A0D0D0A
+	blockInterfaceDesc    = 0x00000001
+	blockEnhancedPacket   = 0x00000006
+	byteOrderMagic        = 0x1A2B3C4D
+	linkTypeRaw           = 101 // LINKTYPE_RAW，数据包以 IP 头开始
+	optEndOfOpt           = 0
+	optComment            = 1
+	optSHBUserAppl        = 4
+	optIfName             = 2
+	optIfTsResol          = 9
+	optEPBFlags           = 2
+	epbFlagInbound        = 1
+	epbFlagOutbound       = 2
+	timestampResolutionNs = 9
+)
+
+// Writer pcapng 写入器，每条 DNS 记录写为查询与响应两个数据包，
+// 进程信息写入数据包注释，可在 Wireshark 中通过 frame.comment 查看与过滤
+type Writer struct {
+	w         io.Writer
+	responses bool
+	buf       []byte
+}
+
+// NewWriter 写入节头与接口描述块并返回写入器，responses 为 false 时只写查询包
+func NewWriter(w io.Writer, appName string, responses bool) (*Writer, error) {
+	pw := &Writer{w: w, responses: responses}
+
+	var opts []byte
+	opts = appendOption(opts, optSHBUserAppl, []byte(appName))
+	opts = appendOption(opts, optEndOfOpt, nil)
+	body := make([]byte, 16, 16+len(opts))
+	binary.LittleEndian.PutUint32(body[0:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(body[4:], 1) // 主版本
+	binary.LittleEndian.PutUint16(body[6:], 0) // 次版本
+	binary.LittleEndian.PutUint64(body[8:], ^uint64(0))
+	if err := pw.writeBlock(blockSectionHeader, append(body, opts...)); err != nil {
+		return nil, err
+	}
+
+	opts = opts[:0]
+	opts = appendOption(opts, optIfName, []byte("dnsflux"))
+	opts = appendOption(opts, optIfTsResol, []byte{timestampResolutionNs})
+	opts = appendOption(opts, optEndOfOpt, nil)
+	body = make([]byte, 8, 8+len(opts))
+	binary.LittleEndian.PutUint16(body[0:], linkTypeRaw)
+	binary.LittleEndian.PutUint32(body[4:], 0) // 不限制快照长度
+	if err := pw.writeBlock(blockInterfaceDesc, append(body, opts...)); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// encodeError 记录无法重建为 DNS 报文，重试也不会成功
+type encodeError struct {
+	err error
+}
+
+func (e *encodeError) Error() string { return e.err.Error() }
+func (e *encodeError) Unwrap() error { return e.err }
+
+// WriteRecord 写入一条 DNS 记录
+// 记录携带原始报文时直接使用采集到的查询，否则根据记录字段重建查询；响应始终根据记录字段重建。
+// 两个数据包都重建成功后才开始写入，无法重建时不写入任何内容并返回 *encodeError
+func (pw *Writer) WriteRecord(record *model.DNSRecord) error {
+	query, err := dnswire.Query(record)
+	if err != nil {
+		return &encodeError{fmt.Errorf("重建查询报文 %s 失败: %w", record.QueryName, err)}
+	}
+	var response []byte
+	if pw.responses {
+		if response, err = dnswire.BuildResponse(record); err != nil {
+			return &encodeError{fmt.Errorf("重建响应报文 %s 失败: %w", record.QueryName, err)}
+		}
+	}
+
+	ep := recordEndpoint(record)
+	comment := packetComment(record)
+	if err := pw.writePacket(record.Timestamp, buildPacket(ep, query, true, 1, 1), epbFlagOutbound, comment); err != nil {
+		return err
+	}
+	if response == nil {
+		return nil
+	}
+	// TCP 序号按 DNS over TCP 的两字节长度前缀累加
+	ts := record.Timestamp.Add(time.Duration(record.LatencyMs * float64(time.Millisecond)))
+	packet := buildPacket(ep, response, false, 1, uint32(1+2+len(query)))
+	return pw.writePacket(ts, packet, epbFlagInbound, comment)
+}
+
+// writePacket 写入增强数据包块
+func (pw *Writer) writePacket(ts time.Time, packet []byte, flags uint32, comment string) error {
+	var nanos uint64
+	if !ts.IsZero() {
+		nanos = uint64(ts.UnixNano())
+	}
+
+	body := make([]byte, 20, 20+len(packet)+len(comment)+32)
+	binary.LittleEndian.PutUint32(body[0:], 0) // 接口 ID
+	binary.LittleEndian.PutUint32(body[4:], uint32(nanos>>32))
+	binary.LittleEndian.PutUint32(body[8:], uint32(nanos))
+	binary.LittleEndian.PutUint32(body[12:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(body[16:], uint32(len(packet)))
+	body = append(body, packet...)
+	body = pad4(body)
+
+	if comment != "" {
+		body = appendOption(body, optComment, []byte(comment))
+	}
+	body = appendOption(body, optEPBFlags, binary.LittleEndian.AppendUint32(nil, flags))
+	body = appendOption(body, optEndOfOpt, nil)
+	return pw.writeBlock(blockEnhancedPacket, body)
+}
+
+// writeBlock 写入一个完整的块：类型、总长度、内容、总长度
+func (pw *Writer) writeBlock(blockType uint32, body []byte) error {
+	total := uint32(12 + len(body))
+	pw.buf = pw.buf[:0]
+	pw.buf = binary.LittleEndian.AppendUint32(pw.buf, blockType)
+	pw.buf = binary.LittleEndian.AppendUint32(pw.buf, total)
+	pw.buf = append(pw.buf, body...)
+	pw.buf = binary.LittleEndian.AppendUint32(pw.buf, total)
+	_, err := pw.w.Write(pw.buf)
+	return err
+}
+
+// appendOption 追加一个选项（代码、长度、按 4 字节对齐的值）
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	return pad4(b)
+}
+
+// pad4 补零到 4 字节对齐
+func pad4(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// packetComment 生成包含进程上下文的数据包注释
+func packetComment(record *model.DNSRecord) string {
+	var sb strings.Builder
+	add := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		if strings.ContainsAny(value, " \"") {
+			fmt.Fprintf(&sb, "%q", value)
+		} else {
+			sb.WriteString(value)
+		}
+	}
+
+	add("process", record.ProcessName)
+	add("pid", fmt.Sprint(record.ProcessID))
+	if record.ParentPID != 0 {
+		add("ppid", fmt.Sprint(record.ParentPID))
+	}
+	add("path", record.ProcessPath)
+	add("user", record.ProcessUser)
+	add("host", record.Hostname)
+	add("record", record.ID)
+	return sb.String()
+}
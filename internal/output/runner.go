@@ -0,0 +1,251 @@
+package output
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/pkg/logger"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// log 输出管理日志
+var log = logger.Component("output")
+
+const (
+	defaultQueueSize     = 1024
+	defaultMaxRetries    = 3
+	defaultFlushInterval = time.Second
+	initialBackoff       = 100 * time.Millisecond
+	maxBackoff           = 10 * time.Second
+)
+
+// Health 输出目标运行状态
+type Health struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Healthy     bool      `json:"healthy"`
+	QueueLen    int       `json:"queueLen"`
+	QueueCap    int       `json:"queueCap"`
+	Sent        uint64    `json:"sent"`
+	Dropped     uint64    `json:"dropped"`
+	Failed      uint64    `json:"failed"`
+	Retries     uint64    `json:"retries"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastErrorAt time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// runner 在独立协程中驱动单个输出目标
+type runner struct {
+	sink          Sink
+	config        SinkConfig
+	managed       bool // 由配置创建，可被 Apply 替换或移除
+	sinkType      string
+	filter        Filter
+	queue         chan event
+	maxRetries    int
+	flushInterval time.Duration
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+	retries atomic.Uint64
+
+	// 连续失败次数，大于 0 表示当前不健康
+	consecutiveFailures atomic.Int64
+
+	errMu       sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+	lastLogged  time.Time
+
+	closeMu sync.RWMutex
+	closed  bool
+	done    chan struct{}
+}
+
+// event 队列中的一条记录或告警
+type event struct {
+	record model.DNSRecord
+	alert  *model.Alert
+}
+
+// newRunner 创建输出目标运行器
+func newRunner(sink Sink, config SinkConfig) *runner {
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	maxRetries := config.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	flushInterval := config.FlushInterval.Std()
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+
+	return &runner{
+		sink:          sink,
+		config:        config,
+		sinkType:      config.Type,
+		filter:        config.Filter,
+		queue:         make(chan event, queueSize),
+		maxRetries:    maxRetries,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+}
+
+// accepts 判断输出目标是否输出该记录
+func (r *runner) accepts(record *model.DNSRecord) bool {
+	return r.config.WantsRecords() && r.filter.Match(record)
+}
+
+// acceptsAlert 判断输出目标是否输出该告警
+func (r *runner) acceptsAlert(alert *model.Alert) bool {
+	return r.config.WantsAlert(alert.Severity) && r.filter.MatchAlert(alert)
+}
+
+// enqueue 非阻塞地将记录或告警加入队列，队列已满时丢弃
+func (r *runner) enqueue(ev event) {
+	r.closeMu.RLock()
+	defer r.closeMu.RUnlock()
+
+	if r.closed {
+		return
+	}
+	select {
+	case r.queue <- ev:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// enqueueWait 将记录或告警加入队列，队列已满时等待，直到加入队列或 ctx 取消
+func (r *runner) enqueueWait(ctx context.Context, ev event) error {
+	r.closeMu.RLock()
+	defer r.closeMu.RUnlock()
+
+	if r.closed {
+		return nil
+	}
+	select {
+	case r.queue <- ev:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run 处理队列中的记录与告警直到队列关闭
+func (r *runner) run(ctx context.Context) {
+	defer close(r.done)
+
+	flusher, canFlush := r.sink.(Flusher)
+	alertSink, _ := r.sink.(AlertSink)
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev, ok := <-r.queue:
+			if !ok {
+				if canFlush {
+					// 退出前尽力刷新，使用独立的超时上下文
+					flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					r.deliver(flushCtx, func(ctx context.Context) error { return flusher.Flush(ctx) })
+					cancel()
+				}
+				return
+			}
+			write := func(ctx context.Context) error { return r.sink.Write(ctx, ev.record) }
+			if ev.alert != nil {
+				write = func(ctx context.Context) error { return alertSink.WriteAlert(ctx, *ev.alert) }
+			}
+			if r.deliver(ctx, write) {
+				r.sent.Add(1)
+			}
+		case <-ticker.C:
+			if canFlush {
+				r.deliver(ctx, func(ctx context.Context) error { return flusher.Flush(ctx) })
+			}
+		}
+	}
+}
+
+// deliver 执行写入操作，失败时按指数退避重试
+func (r *runner) deliver(ctx context.Context, op func(ctx context.Context) error) bool {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		err := op(ctx)
+		if err == nil {
+			r.consecutiveFailures.Store(0)
+			return true
+		}
+
+		r.recordError(err)
+		if attempt >= r.maxRetries || ctx.Err() != nil {
+			r.failed.Add(1)
+			r.consecutiveFailures.Add(1)
+			return false
+		}
+
+		r.retries.Add(1)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// recordError 记录最近一次错误，日志每 10 秒最多输出一次
+func (r *runner) recordError(err error) {
+	r.errMu.Lock()
+	defer r.errMu.Unlock()
+
+	r.lastError = err.Error()
+	r.lastErrorAt = time.Now()
+	if time.Since(r.lastLogged) >= 10*time.Second {
+		r.lastLogged = r.lastErrorAt
+		log.With("sink", r.sink.Name()).Warn(fmt.Sprintf("输出目标 %s 写入失败: %v", r.sink.Name(), err))
+	}
+}
+
+// close 关闭队列，等待剩余记录处理完成后关闭输出目标
+func (r *runner) close() error {
+	r.closeMu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.closeMu.Unlock()
+
+	<-r.done
+	return r.sink.Close()
+}
+
+// health 返回当前运行状态
+func (r *runner) health() Health {
+	r.errMu.Lock()
+	defer r.errMu.Unlock()
+
+	return Health{
+		Name:        r.sink.Name(),
+		Type:        r.sinkType,
+		Healthy:     r.consecutiveFailures.Load() == 0,
+		QueueLen:    len(r.queue),
+		QueueCap:    cap(r.queue),
+		Sent:        r.sent.Load(),
+		Dropped:     r.dropped.Load(),
+		Failed:      r.failed.Load(),
+		Retries:     r.retries.Load(),
+		LastError:   r.lastError,
+		LastErrorAt: r.lastErrorAt,
+	}
+}
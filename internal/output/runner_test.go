@@ -0,0 +1,217 @@
+package output
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSink 记录写入内容的输出目标，可让前若干次写入失败或阻塞到 release 关闭
+type testSink struct {
+	name    string
+	started chan struct{} // 每次开始写入时发送，缓冲满时不发送
+
+	mu       sync.Mutex
+	fail     int // 剩余失败次数
+	release  chan struct{}
+	attempts []time.Time
+	records  []string
+	closed   bool
+}
+
+func newTestSink(name string) *testSink {
+	return &testSink{name: name, started: make(chan struct{}, 16)}
+}
+
+func (s *testSink) Name() string { return s.name }
+
+func (s *testSink) Write(ctx context.Context, record model.DNSRecord) error {
+	s.mu.Lock()
+	s.attempts = append(s.attempts, time.Now())
+	if s.fail > 0 {
+		s.fail--
+		n := len(s.attempts)
+		s.mu.Unlock()
+		return fmt.Errorf("第 %d 次写入失败", n)
+	}
+	release := s.release
+	s.mu.Unlock()
+
+	select {
+	case s.started <- struct{}{}:
+	default:
+	}
+	if release != nil {
+		<-release
+	}
+
+	s.mu.Lock()
+	s.records = append(s.records, record.QueryName)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *testSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return nil
+}
+
+// isClosed 判断输出目标是否已关闭
+func (s *testSink) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// written 返回已写入记录的查询域名
+func (s *testSink) written() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.records...)
+}
+
+// startRunner 启动输出目标运行器，测试结束时关闭
+func startRunner(t *testing.T, sink Sink, config SinkConfig) *runner {
+	t.Helper()
+	r := newRunner(sink, config)
+	go r.run(context.Background())
+	t.Cleanup(func() { r.close() })
+	return r
+}
+
+// waitFor 等待条件成立，超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func record(name string) event {
+	return event{record: model.DNSRecord{QueryName: name}}
+}
+
+func TestRunnerQueueFullDrops(t *testing.T) {
+	sink := newTestSink("slow")
+	sink.release = make(chan struct{})
+	r := startRunner(t, sink, SinkConfig{Name: "slow", Type: "test", QueueSize: 2})
+
+	// 第一条记录被处理协程取出并阻塞在写入中
+	r.enqueue(record("a.example"))
+	<-sink.started
+
+	done := make(chan struct{})
+	go func() {
+		for _, name := range []string{"b.example", "c.example", "d.example", "e.example", "f.example"} {
+			r.enqueue(record(name))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("队列已满时 enqueue 阻塞")
+	}
+
+	h := r.health()
+	if h.QueueLen != 2 || h.QueueCap != 2 || h.Dropped != 3 {
+		t.Errorf("队列 %d/%d, 丢弃 %d, want 2/2, 3", h.QueueLen, h.QueueCap, h.Dropped)
+	}
+
+	close(sink.release)
+	r.close()
+	if got := sink.written(); len(got) != 3 || got[0] != "a.example" || got[2] != "c.example" {
+		t.Errorf("写入 %v, want 先入队的 3 条", got)
+	}
+	if h := r.health(); h.Sent != 3 {
+		t.Errorf("发送 %d 条, want 3", h.Sent)
+	}
+}
+
+func TestRunnerRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxRetries  int
+		fail        int
+		wantOK      bool
+		wantAttempt int
+	}{
+		{"重试后成功", 3, 2, true, 3},
+		{"超过重试次数后放弃", 2, 5, false, 3},
+		{"默认重试 3 次", 0, 5, false, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newTestSink("flaky")
+			sink.fail = tt.fail
+			r := startRunner(t, sink, SinkConfig{Name: "flaky", Type: "test", MaxRetries: tt.maxRetries})
+			r.enqueue(record("a.example"))
+			waitFor(t, "写入完成", func() bool {
+				h := r.health()
+				return h.Sent+h.Failed == 1
+			})
+
+			h := r.health()
+			if ok := h.Sent == 1; ok != tt.wantOK {
+				t.Errorf("发送 %d 条, 失败 %d 条", h.Sent, h.Failed)
+			}
+			if h.Retries != uint64(tt.wantAttempt-1) {
+				t.Errorf("重试 %d 次, want %d", h.Retries, tt.wantAttempt-1)
+			}
+
+			sink.mu.Lock()
+			attempts := append([]time.Time(nil), sink.attempts...)
+			sink.mu.Unlock()
+			if len(attempts) != tt.wantAttempt {
+				t.Fatalf("尝试 %d 次, want %d", len(attempts), tt.wantAttempt)
+			}
+			// 退避时间从 initialBackoff 开始逐次加倍
+			backoff := initialBackoff
+			for i := 1; i < len(attempts); i++ {
+				if gap := attempts[i].Sub(attempts[i-1]); gap < backoff {
+					t.Errorf("第 %d 次重试间隔 %v, want 至少 %v", i, gap, backoff)
+				}
+				backoff *= 2
+			}
+		})
+	}
+}
+
+func TestRunnerHealth(t *testing.T) {
+	sink := newTestSink("flaky")
+	sink.fail = 2
+	r := startRunner(t, sink, SinkConfig{Name: "flaky", Type: "test", MaxRetries: 1})
+
+	if h := r.health(); !h.Healthy || h.LastError != "" || !h.LastErrorAt.IsZero() {
+		t.Fatalf("初始状态 = %+v, want 健康且没有错误", h)
+	}
+
+	// 两次尝试都失败，输出目标变为不健康并记下最后一次错误
+	before := time.Now()
+	r.enqueue(record("a.example"))
+	waitFor(t, "写入失败", func() bool { return r.health().Failed == 1 })
+	h := r.health()
+	if h.Healthy || h.Name != "flaky" || h.Type != "test" {
+		t.Errorf("失败后状态 = %+v, want 不健康", h)
+	}
+	if h.LastError != "第 2 次写入失败" || h.LastErrorAt.Before(before) {
+		t.Errorf("最近错误 = %q (%v)", h.LastError, h.LastErrorAt)
+	}
+
+	// 写入成功后恢复健康，最近错误保留以便排查
+	r.enqueue(record("b.example"))
+	waitFor(t, "写入成功", func() bool { return r.health().Sent == 1 })
+	h = r.health()
+	if !h.Healthy || h.LastError != "第 2 次写入失败" {
+		t.Errorf("恢复后状态 = %+v, want 健康且保留最近错误", h)
+	}
+}
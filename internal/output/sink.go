@@ -0,0 +1,182 @@
+package output
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink 输出目标接口
+// Write 由输出管理器在独立协程中串行调用，实现无需考虑并发
+type Sink interface {
+	// Name 返回输出目标名称
+	Name() string
+
+	// Write 写入一条 DNS 记录，返回错误时由管理器负责重试
+	Write(ctx context.Context, record model.DNSRecord) error
+
+	// Close 关闭输出目标，清理资源
+	Close() error
+}
+
+// Flusher 可选接口，支持批量缓冲的输出目标实现该接口以便周期性刷新
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// AlertSink 可选接口，支持输出告警的输出目标实现该接口
+// WriteAlert 与 Write 在同一协程中串行调用
+type AlertSink interface {
+	// WriteAlert 写入一条告警，返回错误时由管理器负责重试
+	WriteAlert(ctx context.Context, alert model.Alert) error
+}
+
+// 告警输出模式
+const (
+	AlertsInclude = "include" // 同时输出记录与告警
+	AlertsOnly    = "only"    // 只输出告警
+)
+
+// SinkConfig 输出目标配置
+type SinkConfig struct {
+	Name          string         `json:"name" yaml:"name"`
+	Type          string         `json:"type" yaml:"type"`
+	Enabled       *bool          `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Format        string         `json:"format,omitempty" yaml:"format,omitempty"`
+	Filter        Filter         `json:"filter,omitempty" yaml:"filter,omitempty"`
+	QueueSize     int            `json:"queueSize,omitempty" yaml:"queueSize,omitempty"`
+	MaxRetries    int            `json:"maxRetries,omitempty" yaml:"maxRetries,omitempty"`
+	FlushInterval Duration       `json:"flushInterval,omitempty" yaml:"flushInterval,omitempty"`
+	Options       map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
+	Alerts        string         `json:"alerts,omitempty" yaml:"alerts,omitempty"`           // 告警输出模式，为空时只输出记录
+	MinSeverity   string         `json:"minSeverity,omitempty" yaml:"minSeverity,omitempty"` // 输出告警的最低级别，为空时输出全部告警
+}
+
+// IsEnabled 判断输出目标是否启用，未配置时默认启用
+func (c SinkConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// WantsRecords 判断输出目标是否输出记录
+func (c SinkConfig) WantsRecords() bool {
+	return c.Alerts != AlertsOnly
+}
+
+// WantsAlert 判断输出目标是否输出该级别的告警
+func (c SinkConfig) WantsAlert(severity string) bool {
+	if c.Alerts != AlertsInclude && c.Alerts != AlertsOnly {
+		return false
+	}
+	return model.SeverityRank(severity) >= model.SeverityRank(c.MinSeverity)
+}
+
+// DecodeOptions 将通用选项解码到具体输出目标的配置结构
+func (c SinkConfig) DecodeOptions(target any) error {
+	if len(c.Options) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(c.Options)
+	if err != nil {
+		return fmt.Errorf("输出目标 %s 选项编码失败: %w", c.Name, err)
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("输出目标 %s 选项无效: %w", c.Name, err)
+	}
+	return nil
+}
+
+// Factory 根据配置创建输出目标
+type Factory func(config SinkConfig) (Sink, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register 注册输出目标类型，通常在实现包的 init 中调用
+func Register(sinkType string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[sinkType]; exists {
+		panic(fmt.Sprintf("output: 输出目标类型 %s 重复注册", sinkType))
+	}
+	registry[sinkType] = factory
+}
+
+// Types 返回已注册的输出目标类型
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Build 根据配置创建输出目标
+func Build(config SinkConfig) (Sink, error) {
+	registryMu.RLock()
+	factory, ok := registry[config.Type]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("未知的输出目标类型 %q，可用类型: %s", config.Type, strings.Join(Types(), ", "))
+	}
+	return factory(config)
+}
+
+// Duration 支持 "5s" 形式字符串的时间间隔
+type Duration time.Duration
+
+// UnmarshalJSON 解析字符串或纳秒数形式的时间间隔
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(time.Duration(value))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("无效的时间间隔: %s", string(data))
+	}
+	return nil
+}
+
+// MarshalJSON 以字符串形式输出时间间隔
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalText 解析字符串形式的时间间隔
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Std 返回标准库时间间隔
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// Version 产品版本，由主程序在启动时设置，用于 CEF/LEEF 等格式的头部字段
+var Version = "dev"
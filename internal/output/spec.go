@@ -0,0 +1,88 @@
+package output
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ParseSinkSpec 解析命令行形式的输出目标定义
+// 格式: type?key=value&key=value，例如 syslog?network=tcp&address=10.0.0.1:514&format=cef
+// name、format、queueSize、maxRetries、flushInterval、alerts、minSeverity 为通用字段，
+// filter.queryTypes 等以逗号分隔的字段为过滤条件，其余键作为输出目标选项
+func ParseSinkSpec(spec string) (SinkConfig, error) {
+	sinkType, rawQuery, _ := strings.Cut(spec, "?")
+	config := SinkConfig{
+		Type:    strings.TrimSpace(sinkType),
+		Options: make(map[string]any),
+	}
+	if config.Type == "" {
+		return config, fmt.Errorf("输出目标定义 %q 缺少类型", spec)
+	}
+
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return config, fmt.Errorf("输出目标定义 %q 解析失败: %w", spec, err)
+	}
+
+	for key, vals := range values {
+		value := vals[len(vals)-1]
+		switch key {
+		case "name":
+			config.Name = value
+		case "format":
+			config.Format = value
+		case "alerts":
+			config.Alerts = value
+		case "minSeverity":
+			config.MinSeverity = value
+		case "queueSize", "maxRetries":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return config, fmt.Errorf("输出目标定义 %q 中 %s 无效: %w", spec, key, err)
+			}
+			if key == "queueSize" {
+				config.QueueSize = n
+			} else {
+				config.MaxRetries = n
+			}
+		case "flushInterval":
+			if err := config.FlushInterval.UnmarshalText([]byte(value)); err != nil {
+				return config, fmt.Errorf("输出目标定义 %q 中 flushInterval 无效: %w", spec, err)
+			}
+		case "filter.queryTypes", "filter.domains", "filter.excludeDomains", "filter.processes", "filter.excludeProcesses":
+			// 由 FilterFromValues 统一解析
+		default:
+			config.Options[key] = optionValue(value)
+		}
+	}
+
+	config.Filter = FilterFromValues(values, "filter.")
+	if config.Name == "" {
+		config.Name = config.Type
+	}
+	return config, nil
+}
+
+// splitList 以逗号分隔列表
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// optionValue 将字符串选项转换为布尔或数字，以便解码到具体类型
+func optionValue(value string) any {
+	if b, err := strconv.ParseBool(value); err == nil && (value == "true" || value == "false") {
+		return b
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	return value
+}
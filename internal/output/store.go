@@ -0,0 +1,32 @@
+package output
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/store"
+)
+
+// storeSink 将记录写入存储的输出目标
+type storeSink struct {
+	store store.Store
+}
+
+// NewStoreSink 创建写入存储的输出目标，存储的生命周期由调用方管理
+func NewStoreSink(s store.Store) Sink {
+	return &storeSink{store: s}
+}
+
+// Name 返回输出目标名称
+func (s *storeSink) Name() string {
+	return "store"
+}
+
+// Write 写入记录
+func (s *storeSink) Write(ctx context.Context, record model.DNSRecord) error {
+	return s.store.AddRecord(record)
+}
+
+// Close 存储由调用方关闭
+func (s *storeSink) Close() error {
+	return nil
+}
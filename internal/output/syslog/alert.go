@@ -0,0 +1,171 @@
+package syslog
+
+import (
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// alertFormatter 将告警格式化为消息体
+type alertFormatter func(alert *model.Alert) ([]byte, error)
+
+// newAlertFormatter 按记录的消息体格式选择告警格式，cef、leef、kv 以外的格式输出单行 JSON
+func newAlertFormatter(format string) alertFormatter {
+	switch format {
+	case "", FormatCEF:
+		return formatAlertCEF
+	case FormatLEEF:
+		return formatAlertLEEF
+	case FormatKV:
+		return formatAlertKV
+	default:
+		return func(alert *model.Alert) ([]byte, error) {
+			return json.Marshal(alert)
+		}
+	}
+}
+
+// cefSeverities 告警级别对应的 CEF 严重程度（0-10），LEEF 的 sev 使用相同取值
+var cefSeverities = map[string]int{
+	model.SeverityInformational: 1,
+	model.SeverityLow:           3,
+	model.SeverityMedium:        5,
+	model.SeverityHigh:          8,
+	model.SeverityCritical:      10,
+}
+
+// formatAlertCEF ArcSight CEF 格式，Signature ID 为 dns-alert:检测器:规则，Name 为告警说明
+func formatAlertCEF(alert *model.Alert) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeaderEscaper.Replace(vendor),
+		cefHeaderEscaper.Replace(product),
+		cefHeaderEscaper.Replace(output.Version),
+		cefHeaderEscaper.Replace("dns-alert:"+alert.Detector+":"+alert.Rule),
+		cefHeaderEscaper.Replace(alert.Message),
+		cefSeverities[alert.Severity])
+
+	fields := []field{
+		{"rt", strconv.FormatInt(alert.LastSeen.UnixMilli(), 10)},
+		{"start", strconv.FormatInt(alert.FirstSeen.UnixMilli(), 10)},
+		{"externalId", alert.ID},
+		{"dvchost", alert.Hostname},
+		{"src", alert.ClientIP},
+		{"spid", pidString(alert.ProcessID)},
+		{"sproc", alert.ProcessName},
+		{"cnt", strconv.Itoa(alert.Count)},
+		{"msg", alert.Message},
+	}
+	fields = appendCustom(fields, "cs1", "queryName", alert.QueryName)
+	fields = appendCustom(fields, "cs2", "detector", alert.Detector)
+	fields = appendCustom(fields, "cs3", "rule", alert.Rule)
+	fields = appendCustom(fields, "cs4", "technique", alert.Technique)
+	fields = appendCustom(fields, "cs5", "processPath", alert.ProcessPath)
+	fields = appendCustom(fields, "cs6", "recordIds", strings.Join(alert.RecordIDs, ","))
+
+	first := true
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		b.WriteString(cefValueEscaper.Replace(f.value))
+	}
+	return []byte(b.String()), nil
+}
+
+// formatAlertLEEF IBM QRadar LEEF 1.0 格式，EventID 为 DNSAlert
+func formatAlertLEEF(alert *model.Alert) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:1.0|%s|%s|%s|DNSAlert|",
+		cefHeaderEscaper.Replace(vendor),
+		cefHeaderEscaper.Replace(product),
+		cefHeaderEscaper.Replace(output.Version))
+
+	fields := []field{
+		{"devTime", strconv.FormatInt(alert.LastSeen.UnixMilli(), 10)},
+		{"devTimeFormat", "epoch"},
+		{"sev", strconv.Itoa(cefSeverities[alert.Severity])},
+		{"identHostName", alert.Hostname},
+		{"src", alert.ClientIP},
+		{"alertId", alert.ID},
+		{"detector", alert.Detector},
+		{"rule", alert.Rule},
+		{"severity", alert.Severity},
+		{"technique", alert.Technique},
+		{"message", alert.Message},
+		{"queryName", alert.QueryName},
+		{"pid", pidString(alert.ProcessID)},
+		{"processName", alert.ProcessName},
+		{"processPath", alert.ProcessPath},
+		{"count", strconv.Itoa(alert.Count)},
+		{"recordIds", strings.Join(alert.RecordIDs, ",")},
+	}
+	first := true
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte('\t')
+		}
+		first = false
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		b.WriteString(leefValueEscaper.Replace(f.value))
+	}
+	return []byte(b.String()), nil
+}
+
+// formatAlertKV key="value" 格式
+func formatAlertKV(alert *model.Alert) ([]byte, error) {
+	fields := []field{
+		{"alert_id", alert.ID},
+		{"time", alert.LastSeen.UTC().Format("2006-01-02T15:04:05.000Z07:00")},
+		{"first_seen", alert.FirstSeen.UTC().Format("2006-01-02T15:04:05.000Z07:00")},
+		{"host", alert.Hostname},
+		{"severity", alert.Severity},
+		{"detector", alert.Detector},
+		{"rule", alert.Rule},
+		{"technique", alert.Technique},
+		{"message", alert.Message},
+		{"client_ip", alert.ClientIP},
+		{"query_name", alert.QueryName},
+		{"pid", pidString(alert.ProcessID)},
+		{"process_name", alert.ProcessName},
+		{"process_path", alert.ProcessPath},
+		{"count", strconv.Itoa(alert.Count)},
+		{"status", alert.Status},
+		{"record_ids", strings.Join(alert.RecordIDs, ",")},
+	}
+
+	var b strings.Builder
+	first := true
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		fmt.Fprintf(&b, `%s="%s"`, f.key, kvValueEscaper.Replace(f.value))
+	}
+	return []byte(b.String()), nil
+}
+
+// pidString 进程 ID 为 0 时返回空字符串
+func pidString(pid uint32) string {
+	if pid == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(pid), 10)
+}
@@ -0,0 +1,79 @@
+package syslog
+
+import (
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatAlertCEFHeaderEscaping(t *testing.T) {
+	alert := model.Alert{
+		ID:        "alert-1",
+		Detector:  "intel",
+		Rule:      "feed|1",
+		Severity:  model.SeverityHigh,
+		Message:   "命中情报=恶意域名",
+		QueryName: "evil.example",
+		RecordIDs: []string{"rec-1", "rec-2"},
+		Count:     2,
+		FirstSeen: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+		LastSeen:  time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+		Status:    model.AlertOpen,
+	}
+	body, err := formatAlertCEF(&alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(body)
+
+	// 头部中的 | 需要转义，= 不需要；扩展字段中的 = 需要转义，| 不需要
+	want := fmt.Sprintf(`CEF:0|DNSFlux|dnsflux|%s|dns-alert:intel:feed\|1|命中情报=恶意域名|8|`, output.Version)
+	if !strings.HasPrefix(got, want) {
+		t.Fatalf("CEF 告警头部错误:\n got %q\nwant %q", got, want)
+	}
+	for _, want := range []string{"msg=命中情报\\=恶意域名", "cs3Label=rule cs3=feed|1", "cnt=2", "cs6=rec-1,rec-2"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("CEF 告警缺少 %q: %s", want, got)
+		}
+	}
+}
+
+func TestAlertPriority(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := acceptAll(t, ln)
+
+	sink := dialSink(t, FormatKV, map[string]any{
+		"network":  NetworkTCP,
+		"address":  ln.Addr().String(),
+		"hostname": "host-1",
+	})
+	severities := []string{model.SeverityCritical, model.SeverityHigh, model.SeverityMedium, model.SeverityLow, model.SeverityInformational}
+	for i, severity := range severities {
+		alert := model.Alert{ID: fmt.Sprintf("alert-%d", i), Detector: "dga", Severity: severity, QueryName: "x.example"}
+		if err := sink.WriteAlert(context.Background(), alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.Close()
+
+	// 告警级别映射为 syslog 严重性 crit(2) 到 info(6)，设施为默认的 local0(16)
+	msgs := readOctetCounted(t, <-received)
+	if len(msgs) != len(severities) {
+		t.Fatalf("收到 %d 条消息, want %d", len(msgs), len(severities))
+	}
+	for i, msg := range msgs {
+		want := fmt.Sprintf("<%d>1 ", 16*8+2+i)
+		if !strings.HasPrefix(msg, want) || !strings.Contains(msg, fmt.Sprintf(` alert - alert_id="alert-%d"`, i)) {
+			t.Errorf("%s 告警消息错误: %q", severities[i], msg)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package syslog
+
+import (
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 消息体格式
+const (
+	FormatCEF  = "cef"
+	FormatLEEF = "leef"
+	FormatKV   = "kv"
+)
+
+func init() {
+	output.RegisterFormatter(FormatCEF, output.FormatterFunc(formatCEF))
+	output.RegisterFormatter(FormatLEEF, output.FormatterFunc(formatLEEF))
+	output.RegisterFormatter(FormatKV, output.FormatterFunc(formatKV))
+}
+
+const (
+	vendor  = "DNSFlux"
+	product = "dnsflux"
+)
+
+// 字段转义
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\r", `\r`, "\n", `\n`)
+	leefValueEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\r\n", `\n`, "\r", `\r`, "\n", `\n`)
+	kvValueEscaper   = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r\n", `\n`, "\r", `\r`, "\n", `\n`)
+)
+
+// field 有序的键值对
+type field struct {
+	key   string
+	value string
+}
+
+// formatCEF ArcSight CEF 格式
+// CEF:Version|Device Vendor|Device Product|Device Version|Signature ID|Name|Severity|Extension
+func formatCEF(record *model.DNSRecord) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|dns-query|DNS Query|3|",
+		cefHeaderEscaper.Replace(vendor),
+		cefHeaderEscaper.Replace(product),
+		cefHeaderEscaper.Replace(output.Version))
+
+	fields := []field{
+		{"rt", strconv.FormatInt(record.Timestamp.UnixMilli(), 10)},
+		{"externalId", record.ID},
+		{"dvchost", record.Hostname},
+		{"src", record.ClientIP},
+		{"dst", record.ServerIP},
+		{"dpt", portString(record.ServerPort)},
+		{"proto", strings.ToUpper(record.Transport)},
+		{"spid", strconv.FormatUint(uint64(record.ProcessID), 10)},
+		{"sproc", record.ProcessName},
+		{"suser", record.ProcessUser},
+	}
+	fields = appendCustom(fields, "cs1", "queryName", record.QueryName)
+	fields = appendCustom(fields, "cs2", "queryType", record.QueryType)
+	fields = appendCustom(fields, "cs3", "answers", answerList(record))
+	fields = appendCustom(fields, "cs4", "rcode", record.RCode)
+	fields = appendCustom(fields, "cs5", "processPath", record.ProcessPath)
+	fields = appendCustom(fields, "cn1", "transactionId", strconv.FormatUint(uint64(record.TransactionID), 10))
+
+	first := true
+	for _, f := range fields {
+		if f.value == "" || f.value == "-" {
+			continue
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		b.WriteString(cefValueEscaper.Replace(f.value))
+	}
+	return []byte(b.String()), nil
+}
+
+// formatLEEF IBM QRadar LEEF 1.0 格式，属性以制表符分隔
+// LEEF:Version|Vendor|Product|Version|EventID|Attributes
+func formatLEEF(record *model.DNSRecord) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:1.0|%s|%s|%s|DNSQuery|",
+		cefHeaderEscaper.Replace(vendor),
+		cefHeaderEscaper.Replace(product),
+		cefHeaderEscaper.Replace(output.Version))
+
+	fields := []field{
+		{"devTime", strconv.FormatInt(record.Timestamp.UnixMilli(), 10)},
+		{"devTimeFormat", "epoch"},
+		{"identHostName", record.Hostname},
+		{"src", record.ClientIP},
+		{"dst", record.ServerIP},
+		{"dstPort", portString(record.ServerPort)},
+		{"proto", strings.ToUpper(record.Transport)},
+		{"usrName", record.ProcessUser},
+		{"recordId", record.ID},
+		{"queryName", record.QueryName},
+		{"queryType", record.QueryType},
+		{"rcode", record.RCode},
+		{"answers", answerList(record)},
+		{"pid", strconv.FormatUint(uint64(record.ProcessID), 10)},
+		{"processName", record.ProcessName},
+		{"processPath", record.ProcessPath},
+	}
+	first := true
+	for _, f := range fields {
+		if f.value == "" || f.value == "-" {
+			continue
+		}
+		if !first {
+			b.WriteByte('\t')
+		}
+		first = false
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		b.WriteString(leefValueEscaper.Replace(f.value))
+	}
+	return []byte(b.String()), nil
+}
+
+// formatKV key="value" 格式
+func formatKV(record *model.DNSRecord) ([]byte, error) {
+	fields := []field{
+		{"id", record.ID},
+		{"time", record.Timestamp.UTC().Format("2006-01-02T15:04:05.000Z07:00")},
+		{"host", record.Hostname},
+		{"transport", record.Transport},
+		{"client_ip", record.ClientIP},
+		{"server_ip", record.ServerIP},
+		{"server_port", portString(record.ServerPort)},
+		{"query_name", record.QueryName},
+		{"query_type", record.QueryType},
+		{"rcode", record.RCode},
+		{"answers", answerList(record)},
+		{"pid", strconv.FormatUint(uint64(record.ProcessID), 10)},
+		{"process_name", record.ProcessName},
+		{"process_path", record.ProcessPath},
+		{"process_user", record.ProcessUser},
+	}
+
+	var b strings.Builder
+	first := true
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		fmt.Fprintf(&b, `%s="%s"`, f.key, kvValueEscaper.Replace(f.value))
+	}
+	return []byte(b.String()), nil
+}
+
+// appendCustom 追加 CEF 自定义字段及其标签，值为空时两者都省略
+func appendCustom(fields []field, key, label, value string) []field {
+	if value == "" {
+		return fields
+	}
+	return append(fields, field{key + "Label", label}, field{key, value})
+}
+
+// answerList 以逗号拼接应答数据
+func answerList(record *model.DNSRecord) string {
+	data := make([]string, 0, len(record.Answers))
+	for _, answer := range record.Answers {
+		data = append(data, answer.Data)
+	}
+	return strings.Join(data, ",")
+}
+
+// portString 端口为 0 时返回空字符串
+func portString(port uint16) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(port))
+}
@@ -0,0 +1,286 @@
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/utils"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	output.Register("syslog", New)
+}
+
+// 报文帧格式
+const (
+	FramingRFC5424 = "rfc5424"
+	FramingRFC3164 = "rfc3164"
+)
+
+// 传输协议
+const (
+	NetworkUDP = "udp"
+	NetworkTCP = "tcp"
+	NetworkTLS = "tls"
+)
+
+// 日志设施名称到编号的映射
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslog 严重级别
+const (
+	severityCritical = 2
+	severityError    = 3
+	severityWarning  = 4
+	severityNotice   = 5
+	severityInfo     = 6
+)
+
+// alertSeverities 告警级别对应的 syslog 严重级别
+var alertSeverities = map[string]int{
+	model.SeverityCritical:      severityCritical,
+	model.SeverityHigh:          severityError,
+	model.SeverityMedium:        severityWarning,
+	model.SeverityLow:           severityNotice,
+	model.SeverityInformational: severityInfo,
+}
+
+// Options syslog 输出选项
+type Options struct {
+	Network       string            `json:"network"`       // udp, tcp, tls
+	Address       string            `json:"address"`       // host:port
+	Framing       string            `json:"framing"`       // rfc5424, rfc3164
+	OctetCounting *bool             `json:"octetCounting"` // TCP/TLS 是否使用 RFC 6587 八位组计数，默认启用
+	Facility      string            `json:"facility"`
+	AppName       string            `json:"appName"`
+	Hostname      string            `json:"hostname"`
+	Timezone      string            `json:"timezone"` // RFC 3164 时间戳使用的时区，为空表示展示时区
+	Timeout       output.Duration   `json:"timeout"`
+	TLS           output.TLSOptions `json:"tls"`
+}
+
+// Sink syslog 输出目标
+type Sink struct {
+	name      string
+	opts      Options
+	formatter output.Formatter
+	alertFmt  alertFormatter
+	tlsConfig *tls.Config
+	facility  int
+	location  *time.Location // RFC 3164 时间戳的时区，为 nil 时使用展示时区
+	timeout   time.Duration
+	conn      net.Conn
+}
+
+// New 根据配置创建 syslog 输出目标
+func New(config output.SinkConfig) (output.Sink, error) {
+	opts := Options{
+		Network:  NetworkUDP,
+		Framing:  FramingRFC5424,
+		Facility: "local0",
+		AppName:  "dnsflux",
+	}
+	if err := config.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Address == "" {
+		return nil, fmt.Errorf("syslog 输出目标 %s 缺少 address", config.Name)
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+
+	facility, ok := facilities[strings.ToLower(opts.Facility)]
+	if !ok {
+		return nil, fmt.Errorf("未知的 syslog facility: %s", opts.Facility)
+	}
+
+	switch opts.Framing {
+	case FramingRFC5424, FramingRFC3164:
+	default:
+		return nil, fmt.Errorf("未知的 syslog 帧格式: %s", opts.Framing)
+	}
+
+	var location *time.Location
+	if opts.Timezone != "" {
+		loc, err := utils.LoadLocation(opts.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		location = loc
+	}
+
+	s := &Sink{
+		name:     config.Name,
+		opts:     opts,
+		facility: facility,
+		location: location,
+		timeout:  opts.Timeout.Std(),
+	}
+	if s.timeout <= 0 {
+		s.timeout = 5 * time.Second
+	}
+
+	switch opts.Network {
+	case NetworkUDP, NetworkTCP:
+	case NetworkTLS:
+		tlsConfig, err := opts.TLS.Config()
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(opts.Address)
+		}
+		s.tlsConfig = tlsConfig
+	default:
+		return nil, fmt.Errorf("未知的 syslog 传输协议: %s", opts.Network)
+	}
+
+	formatter, err := output.NewFormatter(config.Format, FormatCEF)
+	if err != nil {
+		return nil, err
+	}
+	s.formatter = formatter
+	s.alertFmt = newAlertFormatter(config.Format)
+	return s, nil
+}
+
+// Name 返回输出目标名称
+func (s *Sink) Name() string {
+	return s.name
+}
+
+// Write 格式化记录并发送，连接失败时关闭连接以便重试时重新建立
+func (s *Sink) Write(ctx context.Context, record model.DNSRecord) error {
+	body, err := s.formatter.Format(&record)
+	if err != nil {
+		return err
+	}
+	return s.send(ctx, s.frame(s.header(record.Timestamp, severityInfo, "dns"), body))
+}
+
+// WriteAlert 按消息体格式发送告警，syslog 严重级别随告警级别变化
+func (s *Sink) WriteAlert(ctx context.Context, alert model.Alert) error {
+	body, err := s.alertFmt(&alert)
+	if err != nil {
+		return err
+	}
+	severity, ok := alertSeverities[alert.Severity]
+	if !ok {
+		severity = severityWarning
+	}
+	return s.send(ctx, s.frame(s.header(alert.LastSeen, severity, "alert"), body))
+}
+
+// send 发送组装好的报文
+func (s *Sink) send(ctx context.Context, msg []byte) error {
+	if s.conn == nil {
+		if err := s.dial(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("发送 syslog 消息失败: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭连接
+func (s *Sink) Close() error {
+	if s.conn != nil {
+		err := s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+// dial 建立到 syslog 服务器的连接
+func (s *Sink) dial(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: s.timeout}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	switch s.opts.Network {
+	case NetworkTLS:
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: s.tlsConfig}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", s.opts.Address)
+	default:
+		conn, err = dialer.DialContext(ctx, s.opts.Network, s.opts.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("连接 syslog 服务器 %s 失败: %w", s.opts.Address, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// header 生成 syslog 头部
+func (s *Sink) header(ts time.Time, severity int, msgID string) string {
+	priority := s.facility*8 + severity
+	if s.opts.Framing == FramingRFC3164 {
+		// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]:
+		// RFC 3164 时间戳不含时区，按配置的时区或展示时区输出
+		loc := s.location
+		if loc == nil {
+			loc = utils.DisplayLocation()
+		}
+		return fmt.Sprintf("<%d>%s %s %s[%d]: ",
+			priority,
+			ts.In(loc).Format(time.Stamp),
+			s.opts.Hostname,
+			s.opts.AppName,
+			os.Getpid())
+	}
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - ",
+		priority,
+		ts.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		nilValue(s.opts.Hostname),
+		nilValue(s.opts.AppName),
+		os.Getpid(),
+		msgID)
+}
+
+// frame 按传输协议组装报文
+// UDP 每个数据报一条消息；TCP/TLS 默认使用八位组计数（RFC 6587），否则以换行分隔
+func (s *Sink) frame(header string, body []byte) []byte {
+	msg := make([]byte, 0, len(header)+len(body)+8)
+	msg = append(msg, header...)
+	msg = append(msg, body...)
+
+	if s.opts.Network == NetworkUDP {
+		return msg
+	}
+	if s.opts.OctetCounting == nil || *s.opts.OctetCounting {
+		prefix := strconv.Itoa(len(msg)) + " "
+		return append([]byte(prefix), msg...)
+	}
+	return append(msg, '\n')
+}
+
+// nilValue RFC 5424 中空字段使用 "-"
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(s, " ", "_")
+}
@@ -0,0 +1,462 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/utils"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// escapeRecord 返回一条进程路径含有 = | \ 引号与换行的记录，覆盖各格式的转义规则
+func escapeRecord() model.DNSRecord {
+	return model.DNSRecord{
+		ID:            "rec-1",
+		Timestamp:     time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+		Hostname:      "host-1",
+		Transport:     "udp",
+		ClientIP:      "10.0.0.5",
+		ServerIP:      "8.8.8.8",
+		ServerPort:    53,
+		TransactionID: 4660,
+		QueryName:     "example.com",
+		QueryType:     "A",
+		RCode:         "NOERROR",
+		Answers: []model.DNSAnswer{
+			{Type: "A", Data: "93.184.216.34", TTL: 300},
+			{Type: "A", Data: "93.184.216.35", TTL: 300},
+		},
+		ProcessID:   1234,
+		ProcessName: "curl",
+		ProcessPath: `C:\Program Files\a=b|c "x"` + "\n" + "y",
+		ProcessUser: "alice",
+	}
+}
+
+// dialSink 创建发往本地测试监听地址的输出目标，测试结束时关闭
+func dialSink(t *testing.T, format string, options map[string]any) *Sink {
+	t.Helper()
+	sink, err := New(output.SinkConfig{Name: "test", Type: "syslog", Format: format, Options: options})
+	if err != nil {
+		t.Fatalf("创建 syslog 输出目标失败: %v", err)
+	}
+	t.Cleanup(func() { sink.Close() })
+	return sink.(*Sink)
+}
+
+func TestFormatCEFEscaping(t *testing.T) {
+	record := escapeRecord()
+	body, err := formatCEF(&record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(body)
+
+	prefix := fmt.Sprintf("CEF:0|DNSFlux|dnsflux|%s|dns-query|DNS Query|3|", output.Version)
+	if !strings.HasPrefix(got, prefix) {
+		t.Fatalf("CEF 头部错误: %q", got)
+	}
+	for _, want := range []string{
+		"rt=1714552200000",
+		"src=10.0.0.5",
+		"dpt=53",
+		"proto=UDP",
+		"cs1Label=queryName cs1=example.com",
+		"cs3=93.184.216.34,93.184.216.35",
+		"cn1Label=transactionId cn1=4660",
+		`cs5=C:\\Program Files\\a\=b|c "x"\ny`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("CEF 消息缺少 %q: %s", want, got)
+		}
+	}
+	if strings.ContainsAny(got, "\r\n") {
+		t.Errorf("CEF 消息包含换行: %q", got)
+	}
+}
+
+func TestFormatLEEFEscaping(t *testing.T) {
+	record := escapeRecord()
+	record.ProcessPath = "a\tb\\c\nd"
+	body, err := formatLEEF(&record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(body)
+
+	prefix := fmt.Sprintf("LEEF:1.0|DNSFlux|dnsflux|%s|DNSQuery|", output.Version)
+	if !strings.HasPrefix(got, prefix) {
+		t.Fatalf("LEEF 头部错误: %q", got)
+	}
+	attrs := make(map[string]string)
+	for _, pair := range strings.Split(strings.TrimPrefix(got, prefix), "\t") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			t.Fatalf("LEEF 属性格式错误: %q", pair)
+		}
+		attrs[key] = value
+	}
+	for key, want := range map[string]string{
+		"devTimeFormat": "epoch",
+		"src":           "10.0.0.5",
+		"dstPort":       "53",
+		"queryName":     "example.com",
+		"processPath":   `a\tb\\c\nd`,
+	} {
+		if attrs[key] != want {
+			t.Errorf("LEEF 属性 %s = %q, want %q", key, attrs[key], want)
+		}
+	}
+}
+
+func TestFormatKVEscaping(t *testing.T) {
+	record := escapeRecord()
+	body, err := formatKV(&record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(body)
+
+	for _, want := range []string{
+		`id="rec-1"`,
+		`time="2024-05-01T08:30:00.000Z"`,
+		`query_name="example.com"`,
+		`answers="93.184.216.34,93.184.216.35"`,
+		`process_path="C:\\Program Files\\a=b|c \"x\"\ny"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("kv 消息缺少 %s: %s", want, got)
+		}
+	}
+	if strings.Contains(got, "server_port=\"\"") {
+		t.Errorf("kv 消息包含空字段: %s", got)
+	}
+}
+
+func TestUDPRFC5424(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink := dialSink(t, FormatCEF, map[string]any{
+		"network":  NetworkUDP,
+		"address":  conn.LocalAddr().String(),
+		"hostname": "my host",
+		"facility": "local3",
+	})
+	if err := sink.Write(context.Background(), escapeRecord()); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	buf := make([]byte, 65535)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("接收失败: %v", err)
+	}
+	got := string(buf[:n])
+
+	// local3(19)*8 + info(6) = 158，主机名中的空格替换为下划线
+	want := fmt.Sprintf("<158>1 2024-05-01T08:30:00.000000Z my_host dnsflux %d dns - CEF:0|", os.Getpid())
+	if !strings.HasPrefix(got, want) {
+		t.Fatalf("RFC 5424 头部错误:\n got %q\nwant %q", got, want)
+	}
+	if strings.HasSuffix(got, "\n") {
+		t.Errorf("UDP 数据报不应以换行结尾")
+	}
+}
+
+func TestTCPOctetCounting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := acceptAll(t, ln)
+
+	sink := dialSink(t, FormatKV, map[string]any{
+		"network":  NetworkTCP,
+		"address":  ln.Addr().String(),
+		"hostname": "host-1",
+	})
+	ctx := context.Background()
+	if err := sink.Write(ctx, escapeRecord()); err != nil {
+		t.Fatal(err)
+	}
+	record := escapeRecord()
+	record.ID = "rec-2"
+	if err := sink.Write(ctx, record); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	msgs := readOctetCounted(t, <-received)
+	if len(msgs) != 2 {
+		t.Fatalf("收到 %d 条消息, want 2", len(msgs))
+	}
+	// 默认设施 local0(16)*8 + info(6) = 134，每条消息按长度前缀拆分
+	for i, msg := range msgs {
+		if !strings.HasPrefix(msg, "<134>1 ") || !strings.Contains(msg, fmt.Sprintf(` dns - id="rec-%d"`, i+1)) {
+			t.Errorf("消息 %d 错误: %q", i, msg)
+		}
+	}
+}
+
+func TestTCPNewlineRFC3164(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := acceptAll(t, ln)
+
+	sink := dialSink(t, FormatLEEF, map[string]any{
+		"network":       NetworkTCP,
+		"address":       ln.Addr().String(),
+		"framing":       FramingRFC3164,
+		"octetCounting": false,
+		"hostname":      "host-1",
+		"appName":       "dnsflux",
+	})
+	record := escapeRecord()
+	record.ProcessPath = "line1\nline2"
+	if err := sink.Write(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	data := <-received
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("换行分隔帧应只有一行, got %q", data)
+	}
+	pattern := regexp.MustCompile(`^<134>[A-Z][a-z]{2} [ 0-9]\d \d{2}:\d{2}:\d{2} host-1 dnsflux\[\d+\]: LEEF:1\.0\|`)
+	if !pattern.MatchString(lines[0]) {
+		t.Errorf("RFC 3164 头部错误: %q", lines[0])
+	}
+}
+
+func TestRFC3164Timezone(t *testing.T) {
+	previous := utils.DisplayLocation()
+	t.Cleanup(func() { utils.SetDisplayLocation(previous) })
+	utils.SetDisplayLocation(time.FixedZone("CST", 8*3600))
+
+	ts := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		timezone string
+		want     string
+	}{
+		{"默认使用展示时区", "", "<134>May  1 16:30:00 host-1 "},
+		{"指定时区", "UTC", "<134>May  1 08:30:00 host-1 "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink, err := New(output.SinkConfig{Name: "test", Options: map[string]any{
+				"address":  "127.0.0.1:514",
+				"framing":  FramingRFC3164,
+				"hostname": "host-1",
+				"timezone": tt.timezone,
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sink.(*Sink).header(ts, severityInfo, "dns"); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("RFC 3164 头部 = %q, want 前缀 %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := New(output.SinkConfig{Name: "test", Options: map[string]any{"address": "127.0.0.1:514", "timezone": "Mars/Base"}}); err == nil {
+		t.Error("无效的时区应返回错误")
+	}
+}
+
+func TestTLS(t *testing.T) {
+	caFile, serverConfig := selfSignedTLS(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := acceptAll(t, ln)
+
+	sink := dialSink(t, FormatCEF, map[string]any{
+		"network":  NetworkTLS,
+		"address":  ln.Addr().String(),
+		"hostname": "host-1",
+		"tls":      map[string]any{"caFile": caFile},
+	})
+	if err := sink.Write(context.Background(), escapeRecord()); err != nil {
+		t.Fatalf("TLS 发送失败: %v", err)
+	}
+	sink.Close()
+
+	msgs := readOctetCounted(t, <-received)
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "CEF:0|DNSFlux|") {
+		t.Fatalf("TLS 消息错误: %q", msgs)
+	}
+}
+
+func TestTLSUntrusted(t *testing.T) {
+	_, serverConfig := selfSignedTLS(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	acceptAll(t, ln)
+
+	// 未配置 CA 时服务器证书不受信任
+	sink := dialSink(t, FormatCEF, map[string]any{
+		"network": NetworkTLS,
+		"address": ln.Addr().String(),
+	})
+	if err := sink.Write(context.Background(), escapeRecord()); err == nil {
+		t.Fatal("不受信任的证书应连接失败")
+	}
+}
+
+func TestReconnectAfterFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	sink := dialSink(t, FormatKV, map[string]any{"network": NetworkTCP, "address": addr})
+	if err := sink.Write(context.Background(), escapeRecord()); err == nil {
+		t.Fatal("服务器未监听时应发送失败")
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("无法重新监听 %s: %v", addr, err)
+	}
+	defer ln.Close()
+	received := acceptAll(t, ln)
+
+	if err := sink.Write(context.Background(), escapeRecord()); err != nil {
+		t.Fatalf("服务器恢复后发送失败: %v", err)
+	}
+	sink.Close()
+	if msgs := readOctetCounted(t, <-received); len(msgs) != 1 {
+		t.Fatalf("收到 %d 条消息, want 1", len(msgs))
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]any
+	}{
+		{"缺少地址", map[string]any{}},
+		{"未知设施", map[string]any{"address": "127.0.0.1:514", "facility": "local9"}},
+		{"未知帧格式", map[string]any{"address": "127.0.0.1:514", "framing": "rfc9999"}},
+		{"未知传输协议", map[string]any{"address": "127.0.0.1:514", "network": "sctp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(output.SinkConfig{Name: "test", Type: "syslog", Options: tt.options}); err == nil {
+				t.Fatal("应返回错误")
+			}
+		})
+	}
+}
+
+// acceptAll 接受一个连接并读取到连接关闭，返回收到的全部数据
+func acceptAll(t *testing.T, ln net.Listener) <-chan []byte {
+	t.Helper()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+	return received
+}
+
+// readOctetCounted 按 RFC 6587 八位组计数拆分消息
+func readOctetCounted(t *testing.T, data []byte) []string {
+	t.Helper()
+	var msgs []string
+	r := bufio.NewReader(strings.NewReader(string(data)))
+	for {
+		prefix, err := r.ReadString(' ')
+		if err == io.EOF && prefix == "" {
+			return msgs
+		}
+		if err != nil {
+			t.Fatalf("读取长度前缀失败: %v (%q)", err, prefix)
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+		if err != nil {
+			t.Fatalf("长度前缀无效: %q", prefix)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatalf("消息长度与前缀不符: %v", err)
+		}
+		msgs = append(msgs, string(msg))
+	}
+}
+
+// selfSignedTLS 生成 127.0.0.1 的自签名证书，返回 CA 文件路径与服务器配置
+func selfSignedTLS(t *testing.T) (string, *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile, &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}
+}
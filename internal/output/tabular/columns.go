@@ -0,0 +1,179 @@
+// Package tabular 将 DNS 记录导出为 CSV 与 Parquet 等表格格式
+package tabular
+
+import (
+	"dnsflux/internal/model"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// column CSV 列定义，列名与记录的 JSON 字段名一致
+type column struct {
+	name   string
+	format func(r *model.DNSRecord) string
+	parse  func(r *model.DNSRecord, value string) error
+}
+
+// columns 可用的 CSV 列，顺序即默认列顺序
+var columns = []column{
+	{"schemaVersion",
+		func(r *model.DNSRecord) string { return strconv.Itoa(r.SchemaVersion) },
+		func(r *model.DNSRecord, v string) (err error) { r.SchemaVersion, err = strconv.Atoi(v); return }},
+	{"id",
+		func(r *model.DNSRecord) string { return r.ID },
+		func(r *model.DNSRecord, v string) error { r.ID = v; return nil }},
+	{"timestamp",
+		func(r *model.DNSRecord) string { return r.Timestamp.Format(time.RFC3339Nano) },
+		func(r *model.DNSRecord, v string) (err error) {
+			r.Timestamp, err = time.Parse(time.RFC3339Nano, v)
+			return
+		}},
+	{"hostId",
+		func(r *model.DNSRecord) string { return r.HostID },
+		func(r *model.DNSRecord, v string) error { r.HostID = v; return nil }},
+	{"hostname",
+		func(r *model.DNSRecord) string { return r.Hostname },
+		func(r *model.DNSRecord, v string) error { r.Hostname = v; return nil }},
+	{"source",
+		func(r *model.DNSRecord) string { return r.Source },
+		func(r *model.DNSRecord, v string) error { r.Source = v; return nil }},
+	{"transport",
+		func(r *model.DNSRecord) string { return r.Transport },
+		func(r *model.DNSRecord, v string) error { r.Transport = v; return nil }},
+	{"clientIP",
+		func(r *model.DNSRecord) string { return r.ClientIP },
+		func(r *model.DNSRecord, v string) error { r.ClientIP = v; return nil }},
+	{"serverIP",
+		func(r *model.DNSRecord) string { return r.ServerIP },
+		func(r *model.DNSRecord, v string) error { r.ServerIP = v; return nil }},
+	{"serverPort",
+		func(r *model.DNSRecord) string { return strconv.Itoa(int(r.ServerPort)) },
+		func(r *model.DNSRecord, v string) error {
+			n, err := strconv.ParseUint(v, 10, 16)
+			r.ServerPort = uint16(n)
+			return err
+		}},
+	{"transactionId",
+		func(r *model.DNSRecord) string { return strconv.Itoa(int(r.TransactionID)) },
+		func(r *model.DNSRecord, v string) error {
+			n, err := strconv.ParseUint(v, 10, 16)
+			r.TransactionID = uint16(n)
+			return err
+		}},
+	{"queryName",
+		func(r *model.DNSRecord) string { return r.QueryName },
+		func(r *model.DNSRecord, v string) error { r.QueryName = v; return nil }},
+	{"queryType",
+		func(r *model.DNSRecord) string { return r.QueryType },
+		func(r *model.DNSRecord, v string) error { r.QueryType = v; return nil }},
+	{"rcode",
+		func(r *model.DNSRecord) string { return r.RCode },
+		func(r *model.DNSRecord, v string) error { r.RCode = v; return nil }},
+	{"answers",
+		formatAnswers,
+		parseAnswers},
+	{"latencyMs",
+		func(r *model.DNSRecord) string { return strconv.FormatFloat(r.LatencyMs, 'f', -1, 64) },
+		func(r *model.DNSRecord, v string) (err error) { r.LatencyMs, err = strconv.ParseFloat(v, 64); return }},
+	{"processId",
+		func(r *model.DNSRecord) string { return strconv.FormatUint(uint64(r.ProcessID), 10) },
+		func(r *model.DNSRecord, v string) error {
+			n, err := strconv.ParseUint(v, 10, 32)
+			r.ProcessID = uint32(n)
+			return err
+		}},
+	{"parentProcessId",
+		func(r *model.DNSRecord) string { return strconv.FormatUint(uint64(r.ParentPID), 10) },
+		func(r *model.DNSRecord, v string) error {
+			n, err := strconv.ParseUint(v, 10, 32)
+			r.ParentPID = uint32(n)
+			return err
+		}},
+	{"processName",
+		func(r *model.DNSRecord) string { return r.ProcessName },
+		func(r *model.DNSRecord, v string) error { r.ProcessName = v; return nil }},
+	{"processPath",
+		func(r *model.DNSRecord) string { return r.ProcessPath },
+		func(r *model.DNSRecord, v string) error { r.ProcessPath = v; return nil }},
+	{"processUser",
+		func(r *model.DNSRecord) string { return r.ProcessUser },
+		func(r *model.DNSRecord, v string) error { r.ProcessUser = v; return nil }},
+	{"processCmdline",
+		func(r *model.DNSRecord) string { return r.ProcessCmdline },
+		func(r *model.DNSRecord, v string) error { r.ProcessCmdline = v; return nil }},
+	{"dgaScore",
+		func(r *model.DNSRecord) string { return strconv.FormatFloat(r.DGAScore, 'f', -1, 64) },
+		func(r *model.DNSRecord, v string) (err error) { r.DGAScore, err = strconv.ParseFloat(v, 64); return }},
+	{"newlyObserved",
+		func(r *model.DNSRecord) string { return r.NewlyObserved },
+		func(r *model.DNSRecord, v string) error { r.NewlyObserved = v; return nil }},
+}
+
+// columnIndex 列名到列定义的索引
+var columnIndex = func() map[string]*column {
+	index := make(map[string]*column, len(columns))
+	for i := range columns {
+		index[strings.ToLower(columns[i].name)] = &columns[i]
+	}
+	return index
+}()
+
+// ColumnNames 返回所有可用的列名
+func ColumnNames() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// SplitColumns 解析逗号分隔的列名列表
+func SplitColumns(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// resolveColumns 根据列名列表返回列定义，列表为空时返回全部列
+func resolveColumns(names []string) ([]*column, error) {
+	if len(names) == 0 {
+		names = ColumnNames()
+	}
+	resolved := make([]*column, 0, len(names))
+	for _, name := range names {
+		c, ok := columnIndex[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("未知的列 %q，可用列: %s", name, strings.Join(ColumnNames(), ", "))
+		}
+		resolved = append(resolved, c)
+	}
+	return resolved, nil
+}
+
+// formatAnswers 将应答编码为 JSON 数组，避免应答数据中的分隔符（如 TXT 记录）破坏列内容
+func formatAnswers(r *model.DNSRecord) string {
+	if len(r.Answers) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(r.Answers)
+	return string(data)
+}
+
+// parseAnswers 解析 formatAnswers 生成的应答列表
+func parseAnswers(r *model.DNSRecord, v string) error {
+	r.Answers = []model.DNSAnswer{}
+	if v == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(v), &r.Answers); err != nil {
+		return fmt.Errorf("应答列表 %q 格式无效: %w", v, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package tabular
+
+import (
+	"dnsflux/internal/model"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CSVWriter 按列定义写入 CSV，首行为列名
+type CSVWriter struct {
+	w       *csv.Writer
+	columns []*column
+	row     []string
+}
+
+// NewCSVWriter 创建 CSV 写入器并写入表头，columns 为空时输出全部列
+func NewCSVWriter(w io.Writer, columns []string) (*CSVWriter, error) {
+	cols, err := resolveColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+	return newCSVWriter(w, cols, true)
+}
+
+// newCSVWriter 创建 CSV 写入器，header 为 false 时不写表头（追加到已有文件）
+func newCSVWriter(w io.Writer, cols []*column, header bool) (*CSVWriter, error) {
+	cw := &CSVWriter{w: csv.NewWriter(w), columns: cols, row: make([]string, len(cols))}
+	if !header {
+		return cw, nil
+	}
+	for i, c := range cols {
+		cw.row[i] = c.name
+	}
+	if err := cw.w.Write(cw.row); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Write 写入一条记录
+func (cw *CSVWriter) Write(record *model.DNSRecord) error {
+	for i, c := range cw.columns {
+		cw.row[i] = c.format(record)
+	}
+	return cw.w.Write(cw.row)
+}
+
+// Flush 将缓冲数据写出
+func (cw *CSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// Close 刷新缓冲区，CSV 没有文件尾，因此与 Flush 相同
+func (cw *CSVWriter) Close() error {
+	return cw.Flush()
+}
+
+// ReadCSV 读取 CSVWriter 写出的文件，按表头中的列名还原记录，未导出的字段保持零值
+// 按 CSV 规范，带引号字段中的 \r\n 读回为 \n
+func ReadCSV(r io.Reader, fn func(model.DNSRecord) error) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("读取 CSV 表头失败: %w", err)
+	}
+	cols, err := resolveColumns(header)
+	if err != nil {
+		return err
+	}
+	cr.FieldsPerRecord = len(cols)
+
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取 CSV 第 %d 行失败: %w", line, err)
+		}
+
+		record := model.DNSRecord{Answers: []model.DNSAnswer{}}
+		for i, c := range cols {
+			if err := c.parse(&record, row[i]); err != nil {
+				return fmt.Errorf("CSV 第 %d 行 %s 列无效: %w", line, c.name, err)
+			}
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
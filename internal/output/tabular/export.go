@@ -0,0 +1,75 @@
+package tabular
+
+import (
+	"dnsflux/internal/model"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 导出格式
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// RecordWriter 表格格式写入器
+type RecordWriter interface {
+	Write(record *model.DNSRecord) error
+	Flush() error
+	Close() error
+}
+
+// Options 表格导出选项
+type Options struct {
+	Columns     []string // CSV 列，为空时输出全部列
+	Compression string   // Parquet 压缩算法：none, snappy, gzip, zstd
+	CreatedBy   string   // 写入 Parquet 元数据的程序名
+}
+
+// NewWriter 按格式创建写入器
+func NewWriter(w io.Writer, format string, opts Options) (RecordWriter, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return NewCSVWriter(w, opts.Columns)
+	case FormatParquet:
+		return NewParquetWriter(w, opts.Compression, opts.CreatedBy)
+	default:
+		return nil, fmt.Errorf("未知的导出格式: %s", format)
+	}
+}
+
+// CheckOptions 校验导出选项，便于在写出任何数据之前报告错误
+func CheckOptions(format string, opts Options) error {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		_, err := resolveColumns(opts.Columns)
+		return err
+	case FormatParquet:
+		if _, ok := parquetCodecs[opts.Compression]; !ok && opts.Compression != "" {
+			return fmt.Errorf("未知的 Parquet 压缩算法: %s", opts.Compression)
+		}
+		return nil
+	default:
+		return fmt.Errorf("未知的导出格式: %s", format)
+	}
+}
+
+// Export 将记录一次性写为指定格式
+func Export(w io.Writer, format string, records []model.DNSRecord, opts Options) error {
+	writer, err := NewWriter(w, format, opts)
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		if err := writer.Write(&records[i]); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// Extension 返回格式对应的文件扩展名
+func Extension(format string) string {
+	return "." + strings.ToLower(format)
+}
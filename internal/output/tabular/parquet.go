@@ -0,0 +1,196 @@
+package tabular
+
+import (
+	"dnsflux/internal/model"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+)
+
+// parquetRow Parquet 行结构，列名与记录的 JSON 字段名一致
+// 取值重复度高的字符串列（域名、进程、主机等）使用字典编码
+type parquetRow struct {
+	SchemaVersion  int32           `parquet:"schemaVersion"`
+	ID             string          `parquet:"id"`
+	Timestamp      time.Time       `parquet:"timestamp,timestamp(nanosecond)"`
+	HostID         string          `parquet:"hostId,dict"`
+	Hostname       string          `parquet:"hostname,dict"`
+	Source         string          `parquet:"source,dict"`
+	Transport      string          `parquet:"transport,dict"`
+	ClientIP       string          `parquet:"clientIP,dict"`
+	ServerIP       string          `parquet:"serverIP,dict"`
+	ServerPort     int32           `parquet:"serverPort"`
+	TransactionID  int32           `parquet:"transactionId"`
+	QueryName      string          `parquet:"queryName,dict"`
+	QueryType      string          `parquet:"queryType,dict"`
+	RCode          string          `parquet:"rcode,dict"`
+	Answers        []parquetAnswer `parquet:"answers,list"`
+	LatencyMs      float64         `parquet:"latencyMs"`
+	ProcessID      int64           `parquet:"processId"`
+	ParentPID      int64           `parquet:"parentProcessId"`
+	ProcessName    string          `parquet:"processName,dict"`
+	ProcessPath    string          `parquet:"processPath,dict"`
+	ProcessUser    string          `parquet:"processUser,dict"`
+	ProcessCmdline string          `parquet:"processCmdline,dict"`
+	DGAScore       float64         `parquet:"dgaScore"`
+	NewlyObserved  string          `parquet:"newlyObserved,dict"`
+}
+
+// parquetAnswer 应答列表元素
+type parquetAnswer struct {
+	Type string `parquet:"type,dict"`
+	Data string `parquet:"data"`
+	TTL  int64  `parquet:"ttl"`
+}
+
+// parquetCodecs 支持的压缩算法
+var parquetCodecs = map[string]compress.Codec{
+	"none":   &parquet.Uncompressed,
+	"snappy": &parquet.Snappy,
+	"gzip":   &parquet.Gzip,
+	"zstd":   &parquet.Zstd,
+}
+
+// ParquetWriter Parquet 写入器，文件尾在 Close 时写入，之前的数据不构成完整文件
+type ParquetWriter struct {
+	w   *parquet.GenericWriter[parquetRow]
+	buf []parquetRow
+}
+
+// NewParquetWriter 创建 Parquet 写入器，compression 为空时使用 zstd
+func NewParquetWriter(w io.Writer, compression string, createdBy string) (*ParquetWriter, error) {
+	return newParquetWriter(w, compression, createdBy, 0)
+}
+
+// newParquetWriter 创建 Parquet 写入器，rowGroupSize 大于 0 时限制每个行组的行数
+func newParquetWriter(w io.Writer, compression string, createdBy string, rowGroupSize int64) (*ParquetWriter, error) {
+	if compression == "" {
+		compression = "zstd"
+	}
+	codec, ok := parquetCodecs[compression]
+	if !ok {
+		return nil, fmt.Errorf("未知的 Parquet 压缩算法: %s", compression)
+	}
+	options := []parquet.WriterOption{
+		parquet.Compression(codec),
+		parquet.CreatedBy(createdBy, "", ""),
+	}
+	if rowGroupSize > 0 {
+		options = append(options, parquet.MaxRowsPerRowGroup(rowGroupSize))
+	}
+	return &ParquetWriter{
+		w:   parquet.NewGenericWriter[parquetRow](w, options...),
+		buf: make([]parquetRow, 1),
+	}, nil
+}
+
+// Write 写入一条记录
+func (pw *ParquetWriter) Write(record *model.DNSRecord) error {
+	pw.buf[0] = toParquetRow(record)
+	_, err := pw.w.Write(pw.buf)
+	return err
+}
+
+// Flush 将缓冲的行写为行组
+func (pw *ParquetWriter) Flush() error {
+	return pw.w.Flush()
+}
+
+// Close 写入文件尾
+func (pw *ParquetWriter) Close() error {
+	return pw.w.Close()
+}
+
+// ReadParquet 读取 Parquet 文件中的记录
+func ReadParquet(r io.ReaderAt, fn func(model.DNSRecord) error) error {
+	reader := parquet.NewGenericReader[parquetRow](r)
+	defer reader.Close()
+
+	rows := make([]parquetRow, 256)
+	for {
+		n, err := reader.Read(rows)
+		for i := 0; i < n; i++ {
+			if fnErr := fn(fromParquetRow(&rows[i])); fnErr != nil {
+				return fnErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取 Parquet 文件失败: %w", err)
+		}
+	}
+}
+
+// toParquetRow 将记录转换为 Parquet 行
+func toParquetRow(r *model.DNSRecord) parquetRow {
+	answers := make([]parquetAnswer, len(r.Answers))
+	for i, a := range r.Answers {
+		answers[i] = parquetAnswer{Type: a.Type, Data: a.Data, TTL: int64(a.TTL)}
+	}
+	return parquetRow{
+		SchemaVersion:  int32(r.SchemaVersion),
+		ID:             r.ID,
+		Timestamp:      r.Timestamp,
+		HostID:         r.HostID,
+		Hostname:       r.Hostname,
+		Source:         r.Source,
+		Transport:      r.Transport,
+		ClientIP:       r.ClientIP,
+		ServerIP:       r.ServerIP,
+		ServerPort:     int32(r.ServerPort),
+		TransactionID:  int32(r.TransactionID),
+		QueryName:      r.QueryName,
+		QueryType:      r.QueryType,
+		RCode:          r.RCode,
+		Answers:        answers,
+		LatencyMs:      r.LatencyMs,
+		ProcessID:      int64(r.ProcessID),
+		ParentPID:      int64(r.ParentPID),
+		ProcessName:    r.ProcessName,
+		ProcessPath:    r.ProcessPath,
+		ProcessUser:    r.ProcessUser,
+		ProcessCmdline: r.ProcessCmdline,
+		DGAScore:       r.DGAScore,
+		NewlyObserved:  r.NewlyObserved,
+	}
+}
+
+// fromParquetRow 将 Parquet 行还原为记录
+func fromParquetRow(row *parquetRow) model.DNSRecord {
+	answers := make([]model.DNSAnswer, len(row.Answers))
+	for i, a := range row.Answers {
+		answers[i] = model.DNSAnswer{Type: a.Type, Data: a.Data, TTL: uint32(a.TTL)}
+	}
+	return model.DNSRecord{
+		SchemaVersion:  int(row.SchemaVersion),
+		ID:             row.ID,
+		Timestamp:      row.Timestamp,
+		HostID:         row.HostID,
+		Hostname:       row.Hostname,
+		Source:         row.Source,
+		Transport:      row.Transport,
+		ClientIP:       row.ClientIP,
+		ServerIP:       row.ServerIP,
+		ServerPort:     uint16(row.ServerPort),
+		TransactionID:  uint16(row.TransactionID),
+		QueryName:      row.QueryName,
+		QueryType:      row.QueryType,
+		RCode:          row.RCode,
+		Answers:        answers,
+		LatencyMs:      row.LatencyMs,
+		ProcessID:      uint32(row.ProcessID),
+		ParentPID:      uint32(row.ParentPID),
+		ProcessName:    row.ProcessName,
+		ProcessPath:    row.ProcessPath,
+		ProcessUser:    row.ProcessUser,
+		ProcessCmdline: row.ProcessCmdline,
+		DGAScore:       row.DGAScore,
+		NewlyObserved:  row.NewlyObserved,
+	}
+}
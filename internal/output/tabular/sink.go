@@ -0,0 +1,229 @@
+package tabular
+
+import (
+	"bufio"
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func init() {
+	output.Register(FormatCSV, func(config output.SinkConfig) (output.Sink, error) {
+		return NewSink(config, FormatCSV)
+	})
+	output.Register(FormatParquet, func(config output.SinkConfig) (output.Sink, error) {
+		return NewSink(config, FormatParquet)
+	})
+}
+
+// SinkOptions 表格文件输出选项
+type SinkOptions struct {
+	Dir          string          `json:"dir"`
+	Prefix       string          `json:"prefix"`
+	Rotate       output.Duration `json:"rotate"`       // 文件轮转周期，默认 1h
+	Columns      stringList      `json:"columns"`      // CSV 列，为空时输出全部列
+	Compression  string          `json:"compression"`  // Parquet 压缩算法，默认 zstd
+	RowGroupSize int64           `json:"rowGroupSize"` // Parquet 每个行组的最大行数
+}
+
+// Sink 按周期轮转的 CSV/Parquet 文件输出目标
+// 每个周期一个文件，文件名包含周期起始时间。Parquet 文件在周期结束时才写入文件尾，
+// 写入过程中使用 .tmp 后缀，完成后重命名，避免读取方拿到不完整的文件
+type Sink struct {
+	name   string
+	format string
+	opts   SinkOptions
+
+	window time.Time
+	path   string
+	file   *os.File
+	buf    *bufio.Writer
+	writer RecordWriter
+}
+
+// NewSink 根据配置创建指定格式的表格文件输出目标
+func NewSink(config output.SinkConfig, format string) (*Sink, error) {
+	opts := SinkOptions{
+		Dir:          filepath.Join("logs", format),
+		Prefix:       "dns_records",
+		Rotate:       output.Duration(time.Hour),
+		Compression:  "zstd",
+		RowGroupSize: 100000,
+	}
+	if err := config.DecodeOptions(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Rotate.Std() <= 0 {
+		return nil, fmt.Errorf("%s 输出目标 %s 的 rotate 必须大于 0", format, config.Name)
+	}
+	// 提前校验列与压缩算法，避免在首次写入时才报错
+	if err := CheckOptions(format, Options{Columns: opts.Columns, Compression: opts.Compression}); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建 %s 输出目录失败: %w", format, err)
+	}
+	return &Sink{name: config.Name, format: format, opts: opts}, nil
+}
+
+// Name 返回输出目标名称
+func (s *Sink) Name() string {
+	return s.name
+}
+
+// Write 写入一条记录，进入新周期时先轮转文件
+func (s *Sink) Write(ctx context.Context, record model.DNSRecord) error {
+	if err := s.rotate(time.Now()); err != nil {
+		return err
+	}
+	if s.writer == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	return s.writer.Write(&record)
+}
+
+// Flush 刷新 CSV 缓冲区；周期结束后即使没有新记录也会关闭当前文件
+// Parquet 行组由 rowGroupSize 控制，不在此处刷新，以免产生大量小行组
+func (s *Sink) Flush(ctx context.Context) error {
+	if err := s.rotate(time.Now()); err != nil {
+		return err
+	}
+	if s.writer == nil || s.format != FormatCSV {
+		return nil
+	}
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	return s.buf.Flush()
+}
+
+// Close 完成并关闭当前文件
+func (s *Sink) Close() error {
+	return s.finish()
+}
+
+// rotate 当前文件所属周期结束时完成该文件
+func (s *Sink) rotate(now time.Time) error {
+	window := now.Truncate(s.opts.Rotate.Std())
+	if window.Equal(s.window) {
+		return nil
+	}
+	if err := s.finish(); err != nil {
+		return err
+	}
+	s.window = window
+	return nil
+}
+
+// open 为当前周期创建文件
+func (s *Sink) open() error {
+	name := fmt.Sprintf("%s-%s", s.opts.Prefix, utils.DisplayTime(s.window).Format(windowLayout(s.opts.Rotate.Std())))
+	path := filepath.Join(s.opts.Dir, name+Extension(s.format))
+
+	var (
+		file   *os.File
+		writer RecordWriter
+		err    error
+	)
+	if s.format == FormatCSV {
+		// 同一周期内重启时追加到已有文件，不再重复写入表头
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("打开 CSV 文件失败: %w", err)
+		}
+		info, statErr := file.Stat()
+		if statErr != nil {
+			file.Close()
+			return statErr
+		}
+		s.buf = bufio.NewWriter(file)
+		var cols []*column
+		if cols, err = resolveColumns(s.opts.Columns); err == nil {
+			writer, err = newCSVWriter(s.buf, cols, info.Size() == 0)
+		}
+	} else {
+		// Parquet 文件无法追加，同一周期内重启时使用带序号的新文件名
+		for i := 1; fileExists(path); i++ {
+			path = filepath.Join(s.opts.Dir, fmt.Sprintf("%s.%d%s", name, i, Extension(s.format)))
+		}
+		file, err = os.Create(path + ".tmp")
+		if err != nil {
+			return fmt.Errorf("创建 Parquet 文件失败: %w", err)
+		}
+		s.buf = bufio.NewWriter(file)
+		writer, err = newParquetWriter(s.buf, s.opts.Compression, "dnsflux "+output.Version, s.opts.RowGroupSize)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.path, s.file, s.writer = path, file, writer
+	return nil
+}
+
+// finish 写入文件尾并关闭文件，Parquet 文件去掉 .tmp 后缀
+func (s *Sink) finish() error {
+	if s.writer == nil {
+		return nil
+	}
+	err := s.writer.Close()
+	if flushErr := s.buf.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && s.format == FormatParquet {
+		err = os.Rename(s.file.Name(), s.path)
+	}
+	s.file, s.buf, s.writer = nil, nil, nil
+	if err != nil {
+		return fmt.Errorf("完成 %s 文件 %s 失败: %w", s.format, s.path, err)
+	}
+	return nil
+}
+
+// windowLayout 返回文件名中周期起始时间的格式，精度与轮转周期一致
+func windowLayout(rotate time.Duration) string {
+	switch {
+	case rotate%time.Hour == 0:
+		return "20060102-15"
+	case rotate%time.Minute == 0:
+		return "20060102-1504"
+	default:
+		return "20060102-150405"
+	}
+}
+
+// fileExists 判断文件是否存在
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// stringList 列表选项，既可以是 JSON 数组，也可以是逗号分隔的字符串，以便在命令行输出目标定义中直接填写
+type stringList []string
+
+// UnmarshalJSON 解析数组或逗号分隔的字符串
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*l = list
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("列表选项格式无效: %s", data)
+	}
+	*l = SplitColumns(s)
+	return nil
+}
@@ -0,0 +1,331 @@
+package tabular
+
+import (
+	"bytes"
+	"context"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// allColumns 返回两条记录：第一条填满每一列并带有逗号、引号与换行，
+// 第二条只有必需列，时间位于非 UTC 时区
+func allColumns() []model.DNSRecord {
+	loc := time.FixedZone("CST", 8*3600)
+	return []model.DNSRecord{
+		{
+			SchemaVersion:  1,
+			ID:             "rec-1",
+			Timestamp:      time.Date(2024, 5, 1, 8, 30, 0, 123456789, time.UTC),
+			HostID:         "host-id-1",
+			Hostname:       "host-1",
+			Source:         "ebpf",
+			Transport:      "udp",
+			ClientIP:       "10.0.0.5",
+			ServerIP:       "8.8.8.8",
+			ServerPort:     53,
+			TransactionID:  65535,
+			QueryName:      "example.com",
+			QueryType:      "TXT",
+			RCode:          "NOERROR",
+			Answers:        []model.DNSAnswer{{Type: "TXT", Data: `v=spf1 include:a,b "quoted"`, TTL: 300}, {Type: "TXT", Data: "line1\nline2", TTL: 4294967295}},
+			LatencyMs:      12.345,
+			ProcessID:      4294967295,
+			ParentPID:      1,
+			ProcessName:    "curl",
+			ProcessPath:    `C:\Program Files\curl, "x"\curl.exe`,
+			ProcessUser:    "alice",
+			ProcessCmdline: "curl -H \"a: b\"\nhttps://example.com",
+			DGAScore:       0.8731,
+			NewlyObserved:  "domain",
+		},
+		{
+			SchemaVersion: 1,
+			ID:            "rec-2",
+			Timestamp:     time.Date(2024, 5, 1, 16, 31, 0, 0, loc),
+			ClientIP:      "-",
+			QueryName:     "中文.example",
+			QueryType:     "AAAA",
+			RCode:         "NXDOMAIN",
+			Answers:       []model.DNSAnswer{},
+		},
+	}
+}
+
+// checkRecords 比较读回的记录，时间按时刻比较
+func checkRecords(t *testing.T, got, want []model.DNSRecord) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("读回 %d 条记录, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Timestamp.Equal(want[i].Timestamp) {
+			t.Errorf("记录 %d 时间 = %v, want %v", i, got[i].Timestamp, want[i].Timestamp)
+		}
+		g, w := got[i], want[i]
+		g.Timestamp, w.Timestamp = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("记录 %d 不一致:\n got %+v\nwant %+v", i, g, w)
+		}
+	}
+}
+
+// readCSV 读取 CSV 中的全部记录
+func readCSV(t *testing.T, data []byte) []model.DNSRecord {
+	t.Helper()
+	var records []model.DNSRecord
+	err := ReadCSV(bytes.NewReader(data), func(r model.DNSRecord) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("读取 CSV 失败: %v", err)
+	}
+	return records
+}
+
+// readParquet 读取 Parquet 中的全部记录
+func readParquet(t *testing.T, data []byte) []model.DNSRecord {
+	t.Helper()
+	var records []model.DNSRecord
+	err := ReadParquet(bytes.NewReader(data), func(r model.DNSRecord) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("读取 Parquet 失败: %v", err)
+	}
+	return records
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	records := allColumns()
+	var buf bytes.Buffer
+	if err := Export(&buf, FormatCSV, records, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	header, _, _ := strings.Cut(buf.String(), "\n")
+	if header != strings.Join(ColumnNames(), ",") {
+		t.Errorf("表头 = %q", header)
+	}
+	checkRecords(t, readCSV(t, buf.Bytes()), records)
+}
+
+func TestCSVCarriageReturn(t *testing.T) {
+	records := []model.DNSRecord{{ProcessCmdline: "a\rb\r\nc", Answers: []model.DNSAnswer{}}}
+	var buf bytes.Buffer
+	if err := Export(&buf, FormatCSV, records, Options{Columns: []string{"processCmdline"}}); err != nil {
+		t.Fatal(err)
+	}
+	// 单独的 \r 原样保留，\r\n 读回为 \n
+	got := readCSV(t, buf.Bytes())
+	if len(got) != 1 || got[0].ProcessCmdline != "a\rb\nc" {
+		t.Fatalf("读回 %+v", got)
+	}
+}
+
+func TestCSVColumns(t *testing.T) {
+	var buf bytes.Buffer
+	columns := SplitColumns(" queryName, PROCESSID ,answers,")
+	if err := Export(&buf, FormatCSV, allColumns(), Options{Columns: columns}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.SplitN(buf.String(), "\n", 2)
+	if lines[0] != "queryName,processId,answers" {
+		t.Errorf("表头 = %q", lines[0])
+	}
+
+	// 未导出的列保持零值
+	got := readCSV(t, buf.Bytes())
+	want := []model.DNSRecord{
+		{QueryName: "example.com", ProcessID: 4294967295, Answers: allColumns()[0].Answers},
+		{QueryName: "中文.example", Answers: []model.DNSAnswer{}},
+	}
+	checkRecords(t, got, want)
+}
+
+func TestCSVInvalid(t *testing.T) {
+	if err := CheckOptions(FormatCSV, Options{Columns: []string{"queryName", "nope"}}); err == nil {
+		t.Error("未知列应返回错误")
+	}
+	tests := map[string]string{
+		"未知列":    "queryName,nope\na,b\n",
+		"端口越界":   "serverPort\n70000\n",
+		"应答格式错误": "answers\nnot-json\n",
+		"列数不一致":  "queryName,queryType\na\n",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ReadCSV(strings.NewReader(data), func(model.DNSRecord) error { return nil })
+			if err == nil {
+				t.Fatal("应返回错误")
+			}
+		})
+	}
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	for _, compression := range []string{"", "none", "snappy", "gzip", "zstd"} {
+		t.Run(compression, func(t *testing.T) {
+			records := allColumns()
+			var buf bytes.Buffer
+			if err := Export(&buf, FormatParquet, records, Options{Compression: compression, CreatedBy: "test"}); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(buf.Bytes(), []byte("PAR1")) || !bytes.HasSuffix(buf.Bytes(), []byte("PAR1")) {
+				t.Fatal("不是完整的 Parquet 文件")
+			}
+			checkRecords(t, readParquet(t, buf.Bytes()), records)
+		})
+	}
+
+	if err := CheckOptions(FormatParquet, Options{Compression: "lz4"}); err == nil {
+		t.Error("未知压缩算法应返回错误")
+	}
+}
+
+func TestParquetManyRowGroups(t *testing.T) {
+	var records []model.DNSRecord
+	for i := 0; i < 1000; i++ {
+		r := allColumns()[i%2]
+		r.ID = strings.Repeat("x", i%7) + r.ID
+		records = append(records, r)
+	}
+
+	var buf bytes.Buffer
+	pw, err := newParquetWriter(&buf, "snappy", "test", 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range records {
+		if err := pw.Write(&records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, readParquet(t, buf.Bytes()), records)
+}
+
+// dailySink 在 dir 中创建按天轮转的输出目标，重复调用模拟进程重启
+func dailySink(t *testing.T, format, dir string, options map[string]any) *Sink {
+	t.Helper()
+	if options == nil {
+		options = map[string]any{}
+	}
+	options["dir"] = dir
+	options["rotate"] = "24h"
+	sink, err := NewSink(output.SinkConfig{Name: "test", Type: format, Options: options}, format)
+	if err != nil {
+		t.Fatalf("创建 %s 输出目标失败: %v", format, err)
+	}
+	return sink
+}
+
+// writeRecords 通过输出目标写入记录并关闭
+func writeRecords(t *testing.T, sink *Sink, records []model.DNSRecord) {
+	t.Helper()
+	ctx := context.Background()
+	for _, r := range records {
+		if err := sink.Write(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// listFiles 返回目录中的文件名
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCSVSinkAppendsAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	records := allColumns()
+
+	// 同一周期内重启时追加到已有文件，表头只写一次
+	writeRecords(t, dailySink(t, FormatCSV, dir, nil), records[:1])
+	writeRecords(t, dailySink(t, FormatCSV, dir, nil), records[1:])
+
+	files := listFiles(t, dir)
+	if len(files) != 1 || !strings.HasPrefix(files[0], "dns_records-") || !strings.HasSuffix(files[0], ".csv") {
+		t.Fatalf("输出文件 = %v", files)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, files[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, readCSV(t, data), records)
+}
+
+func TestParquetSinkRestart(t *testing.T) {
+	dir := t.TempDir()
+	records := allColumns()
+	options := func() map[string]any {
+		return map[string]any{"prefix": "dns", "compression": "gzip", "rowGroupSize": 1}
+	}
+
+	writeRecords(t, dailySink(t, FormatParquet, dir, options()), records)
+	writeRecords(t, dailySink(t, FormatParquet, dir, options()), records[:1])
+
+	// Parquet 文件无法追加，同一周期内重启时使用带序号的文件名；完成后不留 .tmp 文件
+	files := listFiles(t, dir)
+	if len(files) != 2 || !strings.HasSuffix(files[0], ".1.parquet") || !strings.HasSuffix(files[1], ".parquet") {
+		t.Fatalf("输出文件 = %v", files)
+	}
+	for _, name := range files {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := records
+		if strings.HasSuffix(name, ".1.parquet") {
+			want = records[:1]
+		}
+		checkRecords(t, readParquet(t, data), want)
+	}
+}
+
+func TestNewSinkInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		options map[string]any
+	}{
+		{"轮转周期为 0", FormatCSV, map[string]any{"rotate": "0s"}},
+		{"未知列", FormatCSV, map[string]any{"columns": "queryName,nope"}},
+		{"未知压缩算法", FormatParquet, map[string]any{"compression": "brotli"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options["dir"] = t.TempDir()
+			if _, err := NewSink(output.SinkConfig{Name: "test", Options: tt.options}, tt.format); err == nil {
+				t.Fatal("应返回错误")
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+// Package pcapfile 读取 pcap 与 pcapng 抓包文件中的数据包
+package pcapfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// 链路层类型
+const (
+	LinkTypeNull     = 0   // BSD 回环
+	LinkTypeEthernet = 1   // 以太网
+	LinkTypeRaw      = 101 // 以 IP 头开始
+	LinkTypeLinuxSLL = 113 // Linux cooked capture v1
+	LinkTypeIPv4     = 228
+	LinkTypeIPv6     = 229
+	LinkTypeSLL2     = 276 // Linux cooked capture v2
+)
+
+// 文件魔数
+const (
+	magicMicros   = 0xA1B2C3D4
+	magicNanos    = 0xA1B23C4D
+	blockSHB      = 0x0A0D0D0A
+	blockIDB      = 0x00000001
+	blockSPB      = 0x00000003
+	blockEPB      = 0x00000006
+	byteOrderSHB  = 0x1A2B3C4D
+	optEndOfOpt   = 0
+	optComment    = 1
+	optIfTsResol  = 9
+	maxBlockBytes = 64 << 20
+)
+
+// Packet 抓包文件中的一个数据包
+type Packet struct {
+	Timestamp time.Time
+	LinkType  uint16
+	Data      []byte
+	Comment   string // pcapng 数据包注释
+}
+
+// Reader 抓包文件读取器，自动识别 pcap 与 pcapng 格式
+type Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	ng    bool
+
+	// pcap
+	linkType uint16
+	nanos    bool
+
+	// pcapng 当前节中的接口
+	interfaces []iface
+}
+
+// iface pcapng 接口描述
+type iface struct {
+	linkType uint16
+	unit     time.Duration // 时间戳单位，不足 1ns 时为 0 并使用 scale
+	scale    float64       // 每个时间戳单位的秒数
+}
+
+// IsCapture 判断文件头是否为 pcap 或 pcapng 格式
+func IsCapture(header []byte) bool {
+	if len(header) < 4 {
+		return false
+	}
+	le, be := binary.LittleEndian.Uint32(header), binary.BigEndian.Uint32(header)
+	return le == blockSHB || le == magicMicros || le == magicNanos || be == magicMicros || be == magicNanos
+}
+
+// NewReader 读取文件头并返回读取器
+func NewReader(r io.Reader) (*Reader, error) {
+	pr := &Reader{r: bufio.NewReaderSize(r, 64*1024)}
+	head, err := pr.r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件头失败: %w", err)
+	}
+	if binary.LittleEndian.Uint32(head) == blockSHB {
+		pr.ng = true
+		return pr, nil
+	}
+
+	var hdr [24]byte
+	if _, err := io.ReadFull(pr.r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("读取文件头失败: %w", err)
+	}
+	switch {
+	case binary.LittleEndian.Uint32(hdr[:]) == magicMicros:
+		pr.order = binary.LittleEndian
+	case binary.LittleEndian.Uint32(hdr[:]) == magicNanos:
+		pr.order, pr.nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(hdr[:]) == magicMicros:
+		pr.order = binary.BigEndian
+	case binary.BigEndian.Uint32(hdr[:]) == magicNanos:
+		pr.order, pr.nanos = binary.BigEndian, true
+	default:
+		return nil, errors.New("不是 pcap 或 pcapng 文件")
+	}
+	pr.linkType = uint16(pr.order.Uint32(hdr[20:]))
+	return pr, nil
+}
+
+// Next 返回下一个数据包，读完时返回 io.EOF
+func (pr *Reader) Next() (Packet, error) {
+	if pr.ng {
+		return pr.nextBlock()
+	}
+
+	var hdr [16]byte
+	if _, err := io.ReadFull(pr.r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Packet{}, io.EOF
+		}
+		return Packet{}, err
+	}
+	sec, frac := pr.order.Uint32(hdr[0:]), pr.order.Uint32(hdr[4:])
+	capLen := pr.order.Uint32(hdr[8:])
+	if capLen > maxBlockBytes {
+		return Packet{}, fmt.Errorf("数据包长度 %d 无效", capLen)
+	}
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(pr.r, data); err != nil {
+		return Packet{}, io.EOF
+	}
+
+	nsec := int64(frac) * 1000
+	if pr.nanos {
+		nsec = int64(frac)
+	}
+	return Packet{
+		Timestamp: time.Unix(int64(sec), nsec).UTC(),
+		LinkType:  pr.linkType,
+		Data:      data,
+	}, nil
+}
+
+// nextBlock 读取 pcapng 块直到得到一个数据包
+func (pr *Reader) nextBlock() (Packet, error) {
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(pr.r, hdr[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return Packet{}, io.EOF
+			}
+			return Packet{}, err
+		}
+
+		blockType := binary.LittleEndian.Uint32(hdr[:])
+		if blockType == blockSHB {
+			// 节头决定后续块的字节序
+			order, err := pr.sectionOrder()
+			if err != nil {
+				return Packet{}, err
+			}
+			pr.order = order
+			pr.interfaces = nil
+		} else {
+			blockType = pr.order.Uint32(hdr[:])
+		}
+
+		length := pr.order.Uint32(hdr[4:])
+		if length < 12 || length%4 != 0 || length > maxBlockBytes {
+			return Packet{}, fmt.Errorf("pcapng 块长度 %d 无效", length)
+		}
+		body := make([]byte, length-8)
+		if _, err := io.ReadFull(pr.r, body); err != nil {
+			return Packet{}, io.EOF
+		}
+		body = body[:len(body)-4] // 末尾重复的块长度
+
+		switch blockType {
+		case blockIDB:
+			pr.interfaces = append(pr.interfaces, pr.parseInterface(body))
+		case blockEPB:
+			if pkt, ok := pr.parseEnhanced(body); ok {
+				return pkt, nil
+			}
+		case blockSPB:
+			if len(body) >= 4 && len(pr.interfaces) > 0 {
+				return Packet{LinkType: pr.interfaces[0].linkType, Data: body[4:]}, nil
+			}
+		}
+	}
+}
+
+// sectionOrder 读取节头中的字节序标记
+func (pr *Reader) sectionOrder() (binary.ByteOrder, error) {
+	magic, err := pr.r.Peek(4)
+	if err != nil {
+		return nil, io.EOF
+	}
+	switch {
+	case binary.LittleEndian.Uint32(magic) == byteOrderSHB:
+		return binary.LittleEndian, nil
+	case binary.BigEndian.Uint32(magic) == byteOrderSHB:
+		return binary.BigEndian, nil
+	}
+	return nil, errors.New("pcapng 节头字节序标记无效")
+}
+
+// parseInterface 解析接口描述块，只关心链路类型与时间戳精度
+func (pr *Reader) parseInterface(body []byte) iface {
+	ifc := iface{unit: time.Microsecond}
+	if len(body) < 8 {
+		return ifc
+	}
+	ifc.linkType = pr.order.Uint16(body)
+	pr.options(body[8:], func(code uint16, value []byte) {
+		if code != optIfTsResol || len(value) < 1 {
+			return
+		}
+		v := value[0]
+		if v&0x80 != 0 {
+			ifc.unit, ifc.scale = 0, math.Pow(2, -float64(v&0x7F))
+			return
+		}
+		ifc.unit = time.Duration(math.Pow10(9 - int(v)))
+		if v > 9 {
+			ifc.unit, ifc.scale = 0, math.Pow10(-int(v))
+		}
+	})
+	return ifc
+}
+
+// parseEnhanced 解析增强数据包块
+func (pr *Reader) parseEnhanced(body []byte) (Packet, bool) {
+	if len(body) < 20 {
+		return Packet{}, false
+	}
+	id := pr.order.Uint32(body[0:])
+	if int(id) >= len(pr.interfaces) {
+		return Packet{}, false
+	}
+	ifc := pr.interfaces[id]
+	ts := uint64(pr.order.Uint32(body[4:]))<<32 | uint64(pr.order.Uint32(body[8:]))
+	capLen := int(pr.order.Uint32(body[12:]))
+	if 20+capLen > len(body) {
+		return Packet{}, false
+	}
+
+	pkt := Packet{
+		LinkType: ifc.linkType,
+		Data:     body[20 : 20+capLen],
+	}
+	if ifc.unit > 0 {
+		pkt.Timestamp = time.Unix(0, 0).Add(time.Duration(ts) * ifc.unit).UTC()
+	} else {
+		sec := float64(ts) * ifc.scale
+		pkt.Timestamp = time.Unix(0, int64(sec*1e9)).UTC()
+	}
+
+	optStart := 20 + (capLen+3)&^3
+	if optStart <= len(body) {
+		pr.options(body[optStart:], func(code uint16, value []byte) {
+			if code == optComment && pkt.Comment == "" {
+				pkt.Comment = string(value)
+			}
+		})
+	}
+	return pkt, true
+}
+
+// options 遍历块中的选项
+func (pr *Reader) options(b []byte, fn func(code uint16, value []byte)) {
+	for len(b) >= 4 {
+		code, length := pr.order.Uint16(b), int(pr.order.Uint16(b[2:]))
+		if code == optEndOfOpt || 4+length > len(b) {
+			return
+		}
+		fn(code, b[4:4+length])
+		next := 4 + (length+3)&^3
+		if next > len(b) {
+			return
+		}
+		b = b[next:]
+	}
+}
@@ -0,0 +1,158 @@
+// Package query 定义按过滤条件、响应码、时间范围与条数查询 DNS 记录的条件，供 Web API 与命令行共用
+package query
+
+import (
+	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/utils"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query 记录查询条件，零值表示返回全部记录
+type Query struct {
+	output.Filter
+	RCodes []string  // 响应码，如 NXDOMAIN
+	Since  time.Time // 不早于该时间
+	Until  time.Time // 早于该时间
+	Limit  int       // 只保留最近的 N 条，0 表示不限制
+}
+
+// 不带时区的时间格式，按展示时区解析
+var timeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseTime 解析时间：RFC 3339、不带时区的日期时间（按展示时区），或相对 now 的时长（如 15m、2h、7d）
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, utils.DisplayLocation()); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n).UTC(), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("无效的时间 %q，支持 RFC 3339、2006-01-02 15:04:05 或 15m、2h、7d 等相对时长", value)
+}
+
+// FromValues 从 URL 查询参数解析查询条件
+// 过滤参数见 output.FilterFromValues，rcodes 以逗号分隔，since 与 until 的格式见 ParseTime
+func FromValues(values url.Values, now time.Time) (Query, error) {
+	q := Query{
+		Filter: output.FilterFromValues(values, ""),
+		RCodes: splitList(values.Get("rcodes")),
+	}
+	var err error
+	if q.Since, err = ParseTime(values.Get("since"), now); err != nil {
+		return q, fmt.Errorf("since 参数无效: %w", err)
+	}
+	if q.Until, err = ParseTime(values.Get("until"), now); err != nil {
+		return q, fmt.Errorf("until 参数无效: %w", err)
+	}
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("limit 参数无效")
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+// Values 将查询条件编码为 URL 查询参数，与 FromValues 对应
+func (q *Query) Values() url.Values {
+	values := url.Values{}
+	set := func(key string, list []string) {
+		if len(list) > 0 {
+			values.Set(key, strings.Join(list, ","))
+		}
+	}
+	set("queryTypes", q.QueryTypes)
+	set("domains", q.Domains)
+	set("excludeDomains", q.ExcludeDomains)
+	set("processes", q.Processes)
+	set("excludeProcesses", q.ExcludeProcesses)
+	set("rcodes", q.RCodes)
+	if !q.Since.IsZero() {
+		values.Set("since", q.Since.UTC().Format(time.RFC3339Nano))
+	}
+	if !q.Until.IsZero() {
+		values.Set("until", q.Until.UTC().Format(time.RFC3339Nano))
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return values
+}
+
+// Match 判断记录是否满足过滤条件、响应码与时间范围，不考虑条数限制
+func (q *Query) Match(record *model.DNSRecord) bool {
+	if !q.Since.IsZero() && record.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !record.Timestamp.Before(q.Until) {
+		return false
+	}
+	if len(q.RCodes) > 0 && !containsFold(q.RCodes, record.RCode) {
+		return false
+	}
+	return q.Filter.Match(record)
+}
+
+// Apply 返回满足条件的记录，按时间先后排序，设置了条数限制时只保留最近的记录
+func (q *Query) Apply(records []model.DNSRecord) []model.DNSRecord {
+	matched := make([]model.DNSRecord, 0, len(records))
+	for i := range records {
+		if q.Match(&records[i]) {
+			matched = append(matched, records[i])
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Timestamp.Before(matched[j].Timestamp)
+	})
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[len(matched)-q.Limit:]
+	}
+	return matched
+}
+
+// splitList 按逗号拆分列表并去除空项
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// containsFold 忽略大小写判断列表是否包含指定值
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package replay
+
+import (
+	"dnsflux/internal/model"
+	"dnsflux/internal/pcapfile"
+	"encoding/binary"
+	"net"
+)
+
+// DNS 服务端口
+const dnsPort = 53
+
+// segment 从数据包中解析出的 DNS 传输层数据
+type segment struct {
+	transport string // udp 或 tcp
+	srcIP     net.IP
+	dstIP     net.IP
+	srcPort   uint16
+	dstPort   uint16
+	payload   []byte
+}
+
+// decodePacket 逐层解析链路层、IP 与 UDP/TCP，返回 53 端口的传输层数据
+// 不处理 IP 分片与 TCP 重组，只解析在单个数据包中完整的 DNS 报文
+func decodePacket(linkType uint16, data []byte) (segment, bool) {
+	var ip []byte
+	switch linkType {
+	case pcapfile.LinkTypeEthernet:
+		if len(data) < 14 {
+			return segment{}, false
+		}
+		etherType, rest := binary.BigEndian.Uint16(data[12:]), data[14:]
+		// 跳过 802.1Q / 802.1ad VLAN 标签
+		for (etherType == 0x8100 || etherType == 0x88A8) && len(rest) >= 4 {
+			etherType, rest = binary.BigEndian.Uint16(rest[2:]), rest[4:]
+		}
+		if etherType != 0x0800 && etherType != 0x86DD {
+			return segment{}, false
+		}
+		ip = rest
+	case pcapfile.LinkTypeNull:
+		if len(data) < 4 {
+			return segment{}, false
+		}
+		ip = data[4:]
+	case pcapfile.LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return segment{}, false
+		}
+		ip = data[16:]
+	case pcapfile.LinkTypeSLL2:
+		if len(data) < 20 {
+			return segment{}, false
+		}
+		ip = data[20:]
+	case pcapfile.LinkTypeRaw, pcapfile.LinkTypeIPv4, pcapfile.LinkTypeIPv6, 12, 14:
+		ip = data
+	default:
+		return segment{}, false
+	}
+	return decodeIP(ip)
+}
+
+// decodeIP 解析 IPv4/IPv6 头
+func decodeIP(b []byte) (segment, bool) {
+	if len(b) < 1 {
+		return segment{}, false
+	}
+	var (
+		seg     segment
+		proto   byte
+		payload []byte
+	)
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < 20 {
+			return segment{}, false
+		}
+		ihl := int(b[0]&0x0F) * 4
+		total := int(binary.BigEndian.Uint16(b[2:]))
+		flags := binary.BigEndian.Uint16(b[6:])
+		if flags&0x3FFF != 0 { // 分片
+			return segment{}, false
+		}
+		if total > len(b) || total < ihl {
+			total = len(b)
+		}
+		if ihl < 20 || ihl > total {
+			return segment{}, false
+		}
+		proto = b[9]
+		seg.srcIP, seg.dstIP = net.IP(b[12:16]), net.IP(b[16:20])
+		payload = b[ihl:total]
+	case 6:
+		if len(b) < 40 {
+			return segment{}, false
+		}
+		proto = b[6]
+		seg.srcIP, seg.dstIP = net.IP(b[8:24]), net.IP(b[24:40])
+		payload = b[40:]
+		if n := int(binary.BigEndian.Uint16(b[4:])); n < len(payload) {
+			payload = payload[:n]
+		}
+		// 跳过逐跳、路由与目的选项扩展头，分片头不处理
+		for proto == 0 || proto == 43 || proto == 60 {
+			if len(payload) < 8 {
+				return segment{}, false
+			}
+			size := (int(payload[1]) + 1) * 8
+			if size > len(payload) {
+				return segment{}, false
+			}
+			proto, payload = payload[0], payload[size:]
+		}
+	default:
+		return segment{}, false
+	}
+
+	switch proto {
+	case 17:
+		if len(payload) < 8 {
+			return segment{}, false
+		}
+		seg.transport = model.TransportUDP
+		seg.srcPort, seg.dstPort = binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])
+		seg.payload = payload[8:]
+	case 6:
+		if len(payload) < 20 {
+			return segment{}, false
+		}
+		offset := int(payload[12]>>4) * 4
+		if offset < 20 || offset > len(payload) {
+			return segment{}, false
+		}
+		seg.transport = model.TransportTCP
+		seg.srcPort, seg.dstPort = binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])
+		seg.payload = payload[offset:]
+	default:
+		return segment{}, false
+	}
+
+	if (seg.srcPort != dnsPort && seg.dstPort != dnsPort) || len(seg.payload) == 0 {
+		return segment{}, false
+	}
+	return seg, true
+}
+
+// messages 返回传输层数据中的 DNS 报文，TCP 报文带两字节长度前缀，可能包含多个报文
+func (s *segment) messages() [][]byte {
+	if s.transport == model.TransportUDP {
+		return [][]byte{s.payload}
+	}
+	var msgs [][]byte
+	b := s.payload
+	for len(b) >= 2 {
+		n := int(binary.BigEndian.Uint16(b))
+		if n == 0 || 2+n > len(b) {
+			break
+		}
+		msgs = append(msgs, b[2:2+n])
+		b = b[2+n:]
+	}
+	return msgs
+}
@@ -0,0 +1,206 @@
+// Package replay 从 pcap、pcapng 抓包文件或 JSONL 记录文件读取 DNS 记录，用于离线回放
+package replay
+
+import (
+	"dnsflux/internal/dnswire"
+	"dnsflux/internal/model"
+	"dnsflux/internal/output/jsonfile"
+	"dnsflux/internal/pcapfile"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryTimeout 查询超过该时长（按抓包时间）仍未收到响应时作为无响应记录输出
+const queryTimeout = 5 * time.Second
+
+// ReadFile 读取文件中的 DNS 记录：pcap 与 pcapng 按数据包解析并关联查询与响应，
+// 其他文件按 JSONL 记录文件读取（支持 .gz、.zst 归档）
+func ReadFile(path string, fn func(model.DNSRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	head := make([]byte, 4)
+	n, _ := io.ReadFull(file, head)
+	if !pcapfile.IsCapture(head[:n]) {
+		file.Close()
+		return jsonfile.ReadFile(path, fn)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return ReadCapture(file, fn)
+}
+
+// ReadCapture 解析抓包文件中的 DNS 报文，每对查询与响应生成一条记录
+// 无响应的查询与找不到查询的响应也各生成一条记录；dnsflux 导出的 pcapng 中的进程注释会被还原
+func ReadCapture(r io.Reader, fn func(model.DNSRecord) error) error {
+	reader, err := pcapfile.NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	p := &pairer{pending: make(map[flowKey]*model.DNSRecord), emit: fn}
+	for {
+		pkt, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		seg, ok := decodePacket(pkt.LinkType, pkt.Data)
+		if !ok {
+			continue
+		}
+		for _, raw := range seg.messages() {
+			msg, err := dnswire.Parse(raw)
+			if err != nil {
+				continue
+			}
+			if err := p.add(pkt, &seg, msg, raw); err != nil {
+				return err
+			}
+		}
+		if err := p.expire(pkt.Timestamp.Add(-queryTimeout)); err != nil {
+			return err
+		}
+	}
+	return p.expire(time.Time{})
+}
+
+// flowKey 关联查询与响应的键
+type flowKey struct {
+	transport  string
+	clientIP   string
+	clientPort uint16
+	serverIP   string
+	serverPort uint16
+	id         uint16
+}
+
+// pairer 关联查询与响应
+type pairer struct {
+	pending map[flowKey]*model.DNSRecord
+	emit    func(model.DNSRecord) error
+}
+
+// add 处理一个 DNS 报文：查询加入待响应列表，响应与对应的查询合并后输出
+func (p *pairer) add(pkt pcapfile.Packet, seg *segment, msg *dnswire.Message, raw []byte) error {
+	if !msg.Response {
+		key := flowKey{seg.transport, seg.srcIP.String(), seg.srcPort, seg.dstIP.String(), seg.dstPort, msg.ID}
+		if _, exists := p.pending[key]; exists {
+			return nil // 重传
+		}
+		record := newRecord(pkt, seg, msg, seg.srcIP.String(), seg.dstIP.String(), seg.dstPort)
+		record.Payload = append([]byte(nil), raw...)
+		p.pending[key] = &record
+		return nil
+	}
+
+	key := flowKey{seg.transport, seg.dstIP.String(), seg.dstPort, seg.srcIP.String(), seg.srcPort, msg.ID}
+	record, ok := p.pending[key]
+	if !ok {
+		// 抓包开始前发出的查询，仅根据响应生成记录
+		r := newRecord(pkt, seg, msg, seg.dstIP.String(), seg.srcIP.String(), seg.srcPort)
+		r.RCode, r.Answers = msg.RCode, msg.Answers
+		return p.emit(r)
+	}
+	delete(p.pending, key)
+	record.RCode, record.Answers = msg.RCode, msg.Answers
+	record.LatencyMs = float64(pkt.Timestamp.Sub(record.Timestamp).Microseconds()) / 1000
+	return p.emit(*record)
+}
+
+// expire 按时间顺序输出早于 before 的待响应查询，before 为零值时输出全部
+func (p *pairer) expire(before time.Time) error {
+	var expired []flowKey
+	for key, record := range p.pending {
+		if before.IsZero() || record.Timestamp.Before(before) {
+			expired = append(expired, key)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return p.pending[expired[i]].Timestamp.Before(p.pending[expired[j]].Timestamp)
+	})
+	for _, key := range expired {
+		record := p.pending[key]
+		delete(p.pending, key)
+		if err := p.emit(*record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newRecord 根据报文创建记录
+func newRecord(pkt pcapfile.Packet, seg *segment, msg *dnswire.Message, clientIP, serverIP string, serverPort uint16) model.DNSRecord {
+	record := model.NewDNSRecord(model.SourcePcap)
+	record.Timestamp = pkt.Timestamp
+	record.Transport = seg.transport
+	record.ClientIP = clientIP
+	record.ServerIP = serverIP
+	record.ServerPort = serverPort
+	record.TransactionID = msg.ID
+	record.QueryName = msg.QueryName
+	record.QueryType = msg.QueryType
+	applyComment(&record, pkt.Comment)
+	return record
+}
+
+// applyComment 还原 dnsflux 导出的 pcapng 数据包注释中的进程与主机信息
+// 注释格式为 key=value 以空格分隔，值包含空格或引号时使用 Go 字符串字面量
+func applyComment(record *model.DNSRecord, comment string) {
+	for comment != "" {
+		comment = strings.TrimLeft(comment, " ")
+		key, rest, ok := strings.Cut(comment, "=")
+		if !ok {
+			return
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+		comment = rest
+
+		switch key {
+		case "process":
+			record.ProcessName = value
+		case "pid":
+			record.ProcessID = parseUint32(value)
+		case "ppid":
+			record.ParentPID = parseUint32(value)
+		case "path":
+			record.ProcessPath = value
+		case "user":
+			record.ProcessUser = value
+		case "host":
+			record.Hostname = value
+		case "record":
+			record.ID = value
+		}
+	}
+}
+
+// parseUint32 解析无符号整数，无效时为 0
+func parseUint32(s string) uint32 {
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(n)
+}
@@ -0,0 +1,246 @@
+package memory
+
+import (
+	"dnsflux/internal/model"
+	"dnsflux/internal/store"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// 告警存储默认配置
+const (
+	DefaultAlertCapacity   = 1000
+	DefaultAlertWindow     = time.Hour
+	DefaultAlertMaxRecords = 100
+)
+
+// AlertOptions 告警存储配置
+type AlertOptions struct {
+	Capacity   int           // 保留的告警数，超过时删除最早的告警
+	Window     time.Duration // 去重窗口，距上次告警不超过该间隔的相同告警合并
+	MaxRecords int           // 每条告警保留的相关记录 ID 上限
+}
+
+// alertStore 告警内存存储实现
+type alertStore struct {
+	mu         sync.RWMutex
+	alerts     []*model.Alert          // 按创建顺序，最新的在末尾
+	byID       map[string]*model.Alert // ID 到告警
+	byKey      map[string]*model.Alert // 去重键到最近创建的告警
+	subs       []chan store.AlertEvent
+	opts       AlertOptions
+	suppressed uint64
+	dropped    uint64
+	closed     bool
+}
+
+// NewAlerts 创建告警内存存储实例，未设置的配置使用默认值
+func NewAlerts(opts AlertOptions) store.AlertStore {
+	if opts.Capacity <= 0 {
+		opts.Capacity = DefaultAlertCapacity
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultAlertWindow
+	}
+	if opts.MaxRecords <= 0 {
+		opts.MaxRecords = DefaultAlertMaxRecords
+	}
+	return &alertStore{
+		opts:  opts,
+		byID:  make(map[string]*model.Alert),
+		byKey: make(map[string]*model.Alert),
+	}
+}
+
+// Raise 添加告警，去重窗口内已有相同去重键且未关闭的告警时合并，并向订阅者推送更新
+func (m *alertStore) Raise(alert model.Alert) (model.Alert, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return alert, false
+	}
+
+	if existing := m.byKey[alert.Key]; existing != nil && existing.Status != model.AlertClosed &&
+		alert.FirstSeen.Sub(existing.LastSeen) < m.opts.Window {
+		existing.Count += max(alert.Count, 1)
+		if alert.LastSeen.After(existing.LastSeen) {
+			existing.LastSeen = alert.LastSeen
+		}
+		for _, id := range alert.RecordIDs {
+			if len(existing.RecordIDs) >= m.opts.MaxRecords {
+				break
+			}
+			existing.RecordIDs = append(existing.RecordIDs, id)
+		}
+		m.suppressed++
+		m.broadcast(store.AlertEvent{Type: store.AlertUpdated, Alert: clone(existing)})
+		return clone(existing), false
+	}
+
+	if alert.ID == "" {
+		alert.ID = model.NewRecordID()
+	}
+	if alert.Status == "" {
+		alert.Status = model.AlertOpen
+	}
+	if alert.Count <= 0 {
+		alert.Count = 1
+	}
+	if len(alert.RecordIDs) > m.opts.MaxRecords {
+		alert.RecordIDs = alert.RecordIDs[:m.opts.MaxRecords]
+	}
+	saved := clone(&alert)
+	m.alerts = append(m.alerts, &saved)
+	m.byID[saved.ID] = &saved
+	m.byKey[saved.Key] = &saved
+
+	// 控制容量，删除最早的告警
+	for len(m.alerts) > m.opts.Capacity {
+		oldest := m.alerts[0]
+		m.alerts[0] = nil
+		m.alerts = m.alerts[1:]
+		delete(m.byID, oldest.ID)
+		if m.byKey[oldest.Key] == oldest {
+			delete(m.byKey, oldest.Key)
+		}
+	}
+
+	m.broadcast(store.AlertEvent{Type: store.AlertCreated, Alert: clone(&saved)})
+	return clone(&saved), true
+}
+
+// Get 根据 ID 获取告警
+func (m *alertStore) Get(id string) (model.Alert, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	alert, ok := m.byID[id]
+	if !ok {
+		return model.Alert{}, false
+	}
+	return clone(alert), true
+}
+
+// List 返回满足过滤条件的告警，最新的在前
+func (m *alertStore) List(filter store.AlertFilter) []model.Alert {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	minRank := model.SeverityRank(filter.MinSeverity)
+	out := make([]model.Alert, 0)
+	for i := len(m.alerts) - 1; i >= 0; i-- {
+		alert := m.alerts[i]
+		if filter.Status != "" && alert.Status != filter.Status ||
+			filter.Detector != "" && alert.Detector != filter.Detector ||
+			model.SeverityRank(alert.Severity) < minRank ||
+			alert.LastSeen.Before(filter.Since) {
+			continue
+		}
+		out = append(out, clone(alert))
+		if filter.Limit > 0 && len(out) >= filter.Limit {
+			break
+		}
+	}
+	return out
+}
+
+// SetStatus 修改告警状态
+func (m *alertStore) SetStatus(id, status string) (model.Alert, error) {
+	if !slices.Contains(model.AlertStatuses, status) {
+		return model.Alert{}, fmt.Errorf("无效的告警状态 %q", status)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	alert, ok := m.byID[id]
+	if !ok {
+		return model.Alert{}, store.ErrAlertNotFound
+	}
+	if alert.Status != status {
+		alert.Status = status
+		alert.UpdatedAt = time.Now().UTC()
+		m.broadcast(store.AlertEvent{Type: store.AlertUpdated, Alert: clone(alert)})
+	}
+	return clone(alert), nil
+}
+
+// broadcast 非阻塞广播给所有订阅者，调用方持有写锁
+// 合并重复告警时事件可能很频繁，通道已满时只丢弃该事件而不断开订阅者
+func (m *alertStore) broadcast(event store.AlertEvent) {
+	for _, ch := range m.subs {
+		select {
+		case ch <- event:
+		default:
+			m.dropped++
+		}
+	}
+}
+
+// Subscribe 订阅新建告警、告警合并与状态变化
+func (m *alertStore) Subscribe() <-chan store.AlertEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		ch := make(chan store.AlertEvent)
+		close(ch)
+		return ch
+	}
+
+	ch := make(chan store.AlertEvent, 256)
+	m.subs = append(m.subs, ch)
+	return ch
+}
+
+// Stats 返回告警存储运行状态
+func (m *alertStore) Stats() store.AlertStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	open := 0
+	for _, alert := range m.alerts {
+		if alert.Status == model.AlertOpen {
+			open++
+		}
+	}
+	return store.AlertStats{
+		Alerts:      len(m.alerts),
+		Open:        open,
+		Capacity:    m.opts.Capacity,
+		Suppressed:  m.suppressed,
+		Subscribers: len(m.subs),
+		Dropped:     m.dropped,
+	}
+}
+
+// Close 关闭存储
+func (m *alertStore) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	for _, ch := range m.subs {
+		close(ch)
+	}
+	m.subs = nil
+	m.alerts = nil
+	clear(m.byID)
+	clear(m.byKey)
+	return nil
+}
+
+// clone 复制告警，避免调用方修改存储中的切片与映射
+func clone(alert *model.Alert) model.Alert {
+	out := *alert
+	out.RecordIDs = slices.Clone(alert.RecordIDs)
+	out.Fields = maps.Clone(alert.Fields)
+	return out
+}
@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"dnsflux/internal/model"
+	"dnsflux/internal/store"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+// newAlert 返回在 start 之后 offset 时发生的告警
+func newAlert(key string, offset time.Duration, records ...string) model.Alert {
+	at := start.Add(offset)
+	return model.Alert{Key: key, Detector: "intel", Severity: model.SeverityHigh, FirstSeen: at, LastSeen: at, RecordIDs: records}
+}
+
+// nextEvent 返回订阅通道中的下一个事件，没有事件时测试失败
+func nextEvent(t *testing.T, events <-chan store.AlertEvent) store.AlertEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	default:
+		t.Fatal("没有推送事件")
+		return store.AlertEvent{}
+	}
+}
+
+func TestRaiseDedup(t *testing.T) {
+	s := NewAlerts(AlertOptions{Window: 10 * time.Minute, MaxRecords: 3})
+	defer s.Close()
+	events := s.Subscribe()
+
+	steps := []struct {
+		name        string
+		alert       model.Alert
+		setStatus   string // 先将上一条 a 告警设为该状态
+		wantCreated bool
+		wantCount   int
+		wantRecords []string
+		wantLast    time.Duration
+	}{
+		{"首次告警", newAlert("a", 0, "r1"), "", true, 1, []string{"r1"}, 0},
+		{"窗口内合并", newAlert("a", 5*time.Minute, "r2", "r3"), "", false, 2, []string{"r1", "r2", "r3"}, 5 * time.Minute},
+		{"相关记录超过上限时保留最早的", newAlert("a", 14*time.Minute, "r4"), "", false, 3, []string{"r1", "r2", "r3"}, 14 * time.Minute},
+		{"确认后仍然合并", newAlert("a", 20*time.Minute), model.AlertAcknowledged, false, 4, []string{"r1", "r2", "r3"}, 20 * time.Minute},
+		{"距上次告警达到窗口时新建", newAlert("a", 30*time.Minute, "r5"), "", true, 1, []string{"r5"}, 30 * time.Minute},
+		{"关闭后新建", newAlert("a", 31*time.Minute, "r6"), model.AlertClosed, true, 1, []string{"r6"}, 31 * time.Minute},
+		{"不同的去重键", newAlert("b", 31*time.Minute), "", true, 1, nil, 31 * time.Minute},
+	}
+	var last model.Alert
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.setStatus != "" {
+				if _, err := s.SetStatus(last.ID, step.setStatus); err != nil {
+					t.Fatal(err)
+				}
+				nextEvent(t, events)
+			}
+
+			saved, created := s.Raise(step.alert)
+			if created != step.wantCreated || saved.Count != step.wantCount || !slices.Equal(saved.RecordIDs, step.wantRecords) {
+				t.Errorf("Raise() = %v, count %d, records %v", created, saved.Count, saved.RecordIDs)
+			}
+			if !saved.LastSeen.Equal(start.Add(step.wantLast)) {
+				t.Errorf("最近告警时间 = %v", saved.LastSeen)
+			}
+			if created && (saved.ID == "" || saved.ID == last.ID || saved.Status != model.AlertOpen) {
+				t.Errorf("新建的告警 = %+v", saved)
+			}
+			if !created && saved.ID != last.ID {
+				t.Errorf("合并到 %s, want %s", saved.ID, last.ID)
+			}
+
+			wantType := store.AlertUpdated
+			if created {
+				wantType = store.AlertCreated
+			}
+			if event := nextEvent(t, events); event.Type != wantType || event.Alert.ID != saved.ID || event.Alert.Count != saved.Count {
+				t.Errorf("事件 = %s %s, count %d", event.Type, event.Alert.ID, event.Alert.Count)
+			}
+			if saved.Key == "a" {
+				last = saved
+			}
+		})
+	}
+
+	stats := s.Stats()
+	if stats.Alerts != 4 || stats.Open != 2 || stats.Suppressed != 3 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	s := NewAlerts(AlertOptions{})
+	defer s.Close()
+	alert, _ := s.Raise(newAlert("a", 0))
+	events := s.Subscribe()
+
+	tests := []struct {
+		name      string
+		id        string
+		status    string
+		wantErr   bool
+		wantEvent bool
+	}{
+		{"确认", alert.ID, model.AlertAcknowledged, false, true},
+		{"状态未变化时不推送", alert.ID, model.AlertAcknowledged, false, false},
+		{"关闭", alert.ID, model.AlertClosed, false, true},
+		{"重新打开", alert.ID, model.AlertOpen, false, true},
+		{"无效的状态", alert.ID, "resolved", true, false},
+		{"告警不存在", "missing", model.AlertClosed, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now().UTC()
+			saved, err := s.SetStatus(tt.id, tt.status)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("应返回错误")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if saved.Status != tt.status {
+				t.Errorf("状态 = %s, want %s", saved.Status, tt.status)
+			}
+
+			select {
+			case event := <-events:
+				if !tt.wantEvent || event.Type != store.AlertUpdated || event.Alert.Status != tt.status || event.Alert.UpdatedAt.Before(before) {
+					t.Errorf("事件 = %s %s (%v)", event.Type, event.Alert.Status, event.Alert.UpdatedAt)
+				}
+			default:
+				if tt.wantEvent {
+					t.Error("没有推送事件")
+				}
+			}
+		})
+	}
+
+	if _, err := s.SetStatus("missing", model.AlertClosed); !errors.Is(err, store.ErrAlertNotFound) {
+		t.Errorf("告警不存在时 error = %v", err)
+	}
+	if got, ok := s.Get(alert.ID); !ok || got.Status != model.AlertOpen {
+		t.Errorf("Get() = %+v, %v", got, ok)
+	}
+	if got := s.List(store.AlertFilter{Status: model.AlertOpen}); len(got) != 1 {
+		t.Errorf("List(open) = %d 条", len(got))
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	s := NewAlerts(AlertOptions{Capacity: 2})
+	defer s.Close()
+	first, _ := s.Raise(newAlert("a", 0))
+	s.Raise(newAlert("b", time.Minute))
+	s.Raise(newAlert("c", 2*time.Minute))
+
+	// 超过容量时删除最早的告警，其去重键不再合并
+	if _, ok := s.Get(first.ID); ok {
+		t.Error("最早的告警未被删除")
+	}
+	if _, created := s.Raise(newAlert("a", 3*time.Minute)); !created {
+		t.Error("已删除告警的去重键仍然合并")
+	}
+	var keys []string
+	for _, alert := range s.List(store.AlertFilter{}) {
+		keys = append(keys, alert.Key)
+	}
+	if !slices.Equal(keys, []string{"a", "c"}) {
+		t.Errorf("List() = %v, want 最新的在前 [a c]", keys)
+	}
+}
+
+func TestReturnedAlertsAreCopies(t *testing.T) {
+	s := NewAlerts(AlertOptions{})
+	defer s.Close()
+	saved, _ := s.Raise(newAlert("a", 0, "r1"))
+	saved.RecordIDs[0] = "changed"
+
+	got, _ := s.Get(saved.ID)
+	if got.RecordIDs[0] != "r1" {
+		t.Error("修改返回的告警影响了存储中的告警")
+	}
+}
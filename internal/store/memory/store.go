@@ -13,6 +13,7 @@ type memoryStore struct {
 	subs    []chan model.DNSRecord
 	cap     int
 	closed  bool
+	drops   uint64
 }
 
 // New 创建新的内存存储实例
@@ -45,16 +46,19 @@ func (m *memoryStore) AddRecord(rec model.DNSRecord) error {
 	}
 
 	// 非阻塞广播给所有订阅者
-	for i, ch := range m.subs {
+	active := m.subs[:0]
+	for _, ch := range m.subs {
 		select {
 		case ch <- rec:
 			// 发送成功
+			active = append(active, ch)
 		default:
 			// 通道阻塞，移除该订阅者
 			close(ch)
-			m.subs = append(m.subs[:i], m.subs[i+1:]...)
+			m.drops++
 		}
 	}
+	m.subs = active
 
 	return nil
 }
@@ -91,6 +95,19 @@ func (m *memoryStore) Subscribe() <-chan model.DNSRecord {
 	return ch
 }
 
+// Stats 返回存储运行状态
+func (m *memoryStore) Stats() store.Stats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return store.Stats{
+		Records:         len(m.records),
+		Capacity:        m.cap,
+		Subscribers:     len(m.subs),
+		SubscriberDrops: m.drops,
+	}
+}
+
 // Close 关闭存储
 func (m *memoryStore) Close() error {
 	m.mu.Lock()
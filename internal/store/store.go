@@ -1,6 +1,10 @@
 package store
 
-import "dnsflux/internal/model"
+import (
+	"dnsflux/internal/model"
+	"errors"
+	"time"
+)
 
 // Store 定义数据存储接口
 // 支持添加记录、查询记录和实时订阅功能
@@ -14,6 +18,77 @@ type Store interface {
 	// Subscribe 订阅新记录，返回一个只读通道用于实时推送
 	Subscribe() <-chan model.DNSRecord
 
+	// Stats 返回存储运行状态
+	Stats() Stats
+
 	// Close 关闭存储，清理资源
 	Close() error
 }
+
+// Stats 存储运行状态
+type Stats struct {
+	Records         int    `json:"records"`
+	Capacity        int    `json:"capacity"`
+	Subscribers     int    `json:"subscribers"`
+	SubscriberDrops uint64 `json:"subscriberDrops"` // 因消费过慢被断开的订阅者数
+}
+
+// AlertStore 定义告警存储接口
+// 去重窗口内相同去重键的告警合并为一条，并保存确认与关闭状态
+type AlertStore interface {
+	// Raise 添加告警；去重窗口内已有相同去重键且未关闭的告警时合并到该告警
+	// 返回保存后的告警，created 为 false 表示已合并，订阅者收到 updated 事件，但不应再次发送到输出目标
+	Raise(alert model.Alert) (saved model.Alert, created bool)
+
+	// Get 根据 ID 获取告警
+	Get(id string) (model.Alert, bool)
+
+	// List 返回满足过滤条件的告警，最新的在前
+	List(filter AlertFilter) []model.Alert
+
+	// SetStatus 修改告警状态，告警不存在时返回 ErrAlertNotFound
+	SetStatus(id, status string) (model.Alert, error)
+
+	// Subscribe 订阅新建告警、告警合并与状态变化
+	Subscribe() <-chan AlertEvent
+
+	// Stats 返回告警存储运行状态
+	Stats() AlertStats
+
+	// Close 关闭存储，清理资源
+	Close() error
+}
+
+// ErrAlertNotFound 告警不存在
+var ErrAlertNotFound = errors.New("告警不存在")
+
+// 告警事件类型
+const (
+	AlertCreated = "created" // 新建告警
+	AlertUpdated = "updated" // 合并了重复告警或告警状态变化
+)
+
+// AlertEvent 推送给订阅者的告警事件
+type AlertEvent struct {
+	Type  string      `json:"type"`
+	Alert model.Alert `json:"alert"`
+}
+
+// AlertFilter 告警查询条件，空字段表示不过滤
+type AlertFilter struct {
+	Status      string    // 告警状态
+	MinSeverity string    // 最低告警级别
+	Detector    string    // 检测器
+	Since       time.Time // 最近一次告警不早于该时间
+	Limit       int       // 返回数量上限，<= 0 表示不限制
+}
+
+// AlertStats 告警存储运行状态
+type AlertStats struct {
+	Alerts      int    `json:"alerts"`
+	Open        int    `json:"open"`
+	Capacity    int    `json:"capacity"`
+	Suppressed  uint64 `json:"suppressed"` // 因去重合并而未新建的告警数
+	Subscribers int    `json:"subscribers"`
+	Dropped     uint64 `json:"dropped"` // 因订阅者消费过慢未送达的事件数
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache 带过期时间的并发安全缓存，条目数达到上限时清空
+// 用于采集器按进程查询系统信息，避免每个 DNS 事件都访问 /proc 或创建进程快照
+type Cache[K comparable, V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	entries map[K]cacheEntry[V]
+}
+
+// cacheEntry 缓存条目
+type cacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// NewCache 创建缓存，ttl 为条目有效期，max 为条目数上限
+func NewCache[K comparable, V any](ttl time.Duration, max int) *Cache[K, V] {
+	return &Cache[K, V]{
+		ttl:     ttl,
+		max:     max,
+		entries: make(map[K]cacheEntry[V]),
+	}
+}
+
+// Get 返回未过期的缓存值，不存在或已过期时调用 load 获取并缓存
+// load 在锁外执行，并发未命中时可能重复加载
+func (c *Cache[K, V]) Get(key K, load func() V) V {
+	now := time.Now()
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && now.Before(e.expires) {
+		c.mu.Unlock()
+		return e.value
+	}
+	c.mu.Unlock()
+
+	value := load()
+
+	c.mu.Lock()
+	if len(c.entries) >= c.max {
+		clear(c.entries)
+	}
+	c.entries[key] = cacheEntry[V]{value: value, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return value
+}
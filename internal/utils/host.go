@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	hostOnce sync.Once
+	hostID   string
+	hostName string
+)
+
+// HostIdentity 返回本机的稳定标识与主机名
+// 标识优先读取系统机器 ID，读取失败时退化为主机名的哈希
+func HostIdentity() (id, name string) {
+	hostOnce.Do(func() {
+		hostName, _ = os.Hostname()
+		hostID = strings.ToLower(strings.TrimSpace(machineID()))
+		if hostID == "" {
+			sum := sha256.Sum256([]byte(hostName))
+			hostID = hex.EncodeToString(sum[:16])
+		}
+	})
+	return hostID, hostName
+}
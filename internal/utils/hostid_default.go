@@ -0,0 +1,8 @@
+//go:build !linux && !windows
+
+package utils
+
+// machineID 不支持的平台没有机器 ID
+func machineID() string {
+	return ""
+}
@@ -0,0 +1,15 @@
+//go:build linux
+
+package utils
+
+import "os"
+
+// machineID 读取 systemd/dbus 的机器 ID
+func machineID() string {
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(path); err == nil {
+			return string(data)
+		}
+	}
+	return ""
+}
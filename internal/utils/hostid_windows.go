@@ -0,0 +1,20 @@
+//go:build windows
+
+package utils
+
+import "golang.org/x/sys/windows/registry"
+
+// machineID 读取注册表中的 MachineGuid
+func machineID() string {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Cryptography`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+
+	guid, _, err := key.GetStringValue("MachineGuid")
+	if err != nil {
+		return ""
+	}
+	return guid
+}
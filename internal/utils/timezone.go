@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// displayLocation 展示时间所使用的时区，记录本身始终以 UTC 保存
+var displayLocation atomic.Pointer[time.Location]
+
+// LoadLocation 解析时区名称，支持 Local、UTC 与 IANA 时区名（如 Asia/Shanghai）
+func LoadLocation(name string) (*time.Location, error) {
+	switch strings.TrimSpace(name) {
+	case "", "Local", "local":
+		return time.Local, nil
+	case "UTC", "utc", "Z":
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(strings.TrimSpace(name))
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区 %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// SetDisplayLocation 设置展示时间所使用的时区
+func SetDisplayLocation(loc *time.Location) {
+	displayLocation.Store(loc)
+}
+
+// DisplayLocation 返回展示时间所使用的时区，未设置时为系统本地时区
+func DisplayLocation() *time.Location {
+	if loc := displayLocation.Load(); loc != nil {
+		return loc
+	}
+	return time.Local
+}
+
+// DisplayTime 将时间转换到展示时区
+func DisplayTime(t time.Time) time.Time {
+	return t.In(DisplayLocation())
+}
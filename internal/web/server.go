@@ -2,19 +2,37 @@ package web
 
 import (
 	"context"
+	"dnsflux/internal/detect/baseline"
+	"dnsflux/internal/detect/beacon"
+	"dnsflux/internal/detect/nod"
+	"dnsflux/internal/metrics"
 	"dnsflux/internal/model"
+	"dnsflux/internal/output"
+	"dnsflux/internal/output/pcapng"
+	"dnsflux/internal/output/tabular"
+	"dnsflux/internal/query"
 	"dnsflux/internal/store"
+	"dnsflux/internal/utils"
 	"dnsflux/pkg/logger"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// log Web 服务日志
+var log = logger.Component("web")
+
 type WebServerConfig struct {
 	Addr string
 }
@@ -26,31 +44,107 @@ type Server struct {
 	upgrader websocket.Upgrader
 	server   *http.Server
 	mu       sync.RWMutex
-	clients  map[*websocket.Conn]bool
+	clients  map[*websocket.Conn]*wsClient
+
+	// 告警存储与订阅告警事件的 WebSocket 客户端
+	alerts       store.AlertStore
+	alertClients map[*websocket.Conn]*wsClient
+
+	// 输出目标运行状态
+	sinkHealth func() []output.Health
+	// 重新加载配置文件
+	reload func() error
+	// 心跳检测结果
+	beacons func(all bool) []beacon.Candidate
+	// 新域名记录与数据库状态
+	newlyObserved func(since time.Time, scope string) ([]nod.Observation, nod.Status, bool)
+	// 进程基线
+	baseline func() (*baseline.Baseline, bool)
 }
 
 // New 创建新的 API 服务器
 func New(store store.Store, addr string, port int) *Server {
 	return &Server{
-		store:   store,
-		config:  WebServerConfig{Addr: fmt.Sprintf("%s:%d", addr, port)},
-		clients: make(map[*websocket.Conn]bool),
+		store:        store,
+		config:       WebServerConfig{Addr: fmt.Sprintf("%s:%d", addr, port)},
+		clients:      make(map[*websocket.Conn]*wsClient),
+		alertClients: make(map[*websocket.Conn]*wsClient),
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // 允许跨域
-			},
+			CheckOrigin: sameOrigin,
 		},
 	}
 }
 
+// SetAlertStore 设置告警存储，未设置时告警接口返回空列表
+func (s *Server) SetAlertStore(alerts store.AlertStore) {
+	s.alerts = alerts
+}
+
+// SetSinkHealth 设置输出目标运行状态的获取函数
+func (s *Server) SetSinkHealth(fn func() []output.Health) {
+	s.sinkHealth = fn
+}
+
+// SetReloadFunc 设置重新加载配置文件的函数
+func (s *Server) SetReloadFunc(fn func() error) {
+	s.reload = fn
+}
+
+// SetBeaconsFunc 设置获取心跳检测结果的函数
+func (s *Server) SetBeaconsFunc(fn func(all bool) []beacon.Candidate) {
+	s.beacons = fn
+}
+
+// SetNewlyObservedFunc 设置获取新域名记录的函数
+func (s *Server) SetNewlyObservedFunc(fn func(since time.Time, scope string) ([]nod.Observation, nod.Status, bool)) {
+	s.newlyObserved = fn
+}
+
+// SetBaselineFunc 设置获取进程基线的函数
+func (s *Server) SetBaselineFunc(fn func() (*baseline.Baseline, bool)) {
+	s.baseline = fn
+}
+
 // Start 启动 Web 服务器
 func (s *Server) Start(ctx context.Context) error {
+	s.server = &http.Server{
+		Addr:    s.config.Addr,
+		Handler: s.handler(),
+	}
+
+	// 启动 WebSocket 广播
+	metrics.SetWebSocketClients(s.clientCount)
+	go s.broadcastLoop(ctx)
+	if s.alerts != nil {
+		go s.alertLoop(ctx)
+	}
+
+	// 启动服务器
+	return s.server.ListenAndServe()
+}
+
+// handler 注册路由并返回拒绝跨站修改请求的处理器
+func (s *Server) handler() http.Handler {
 	mux := http.NewServeMux()
 
 	// 注册路由
 	mux.HandleFunc("/", s.handleIndex)
 	mux.HandleFunc("/api/records", s.handleRecords)
+	mux.HandleFunc("/api/schema", s.handleSchema)
+	mux.HandleFunc("/api/sinks", s.handleSinks)
+	mux.HandleFunc("/api/config/reload", s.handleConfigReload)
+	mux.HandleFunc("/api/alerts", s.handleAlerts)
+	mux.HandleFunc("/api/alerts/{id}", s.handleAlert)
+	mux.HandleFunc("/api/alerts/{id}/{action}", s.handleAlertAction)
+	mux.HandleFunc("/api/beacons", s.handleBeacons)
+	mux.HandleFunc("/api/nod", s.handleNewlyObserved)
+	mux.HandleFunc("/api/baseline", s.handleBaseline)
+	mux.HandleFunc("/api/export.pcapng", s.handleExportPcapng)
+	mux.HandleFunc("/api/export.csv", s.handleExportCSV)
+	mux.HandleFunc("/api/export.parquet", s.handleExportParquet)
 	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/ws/alerts", s.handleAlertWebSocket)
+	mux.Handle("/metrics", metrics.Handler())
 
 	// 静态文件服务
 	if HasStatic() {
@@ -59,17 +153,33 @@ func (s *Server) Start(ctx context.Context) error {
 			mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
 		}
 	}
+	return checkOrigin(mux)
+}
 
-	s.server = &http.Server{
-		Addr:    s.config.Addr,
-		Handler: mux,
-	}
-
-	// 启动 WebSocket 广播
-	go s.broadcastLoop(ctx)
+// checkOrigin 拒绝其他网页跨站发起的修改请求（重新加载配置、修改告警状态），
+// 只读的 GET 与 HEAD 请求不受影响，WebSocket 握手由 upgrader 检查
+func checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !sameOrigin(r) {
+			http.Error(w, "拒绝跨站请求", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-	// 启动服务器
-	return s.server.ListenAndServe()
+// sameOrigin 判断请求的 Origin 是否与请求的主机一致
+// 浏览器在跨站请求与 WebSocket 握手中总会携带 Origin；没有 Origin 的请求（如 curl）不是由网页发起，允许
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
 }
 
 // Stop 停止 Web 服务器
@@ -83,7 +193,7 @@ func (s *Server) Stop(ctx context.Context) error {
 // AddRecord 添加 DNS 记录（供外部调用）
 func (s *Server) AddRecord(record model.DNSRecord) {
 	if err := s.store.AddRecord(record); err != nil {
-		logger.Error(fmt.Sprintf("添加记录失败: %v", err))
+		log.Error(fmt.Sprintf("添加记录失败: %v", err))
 	}
 }
 
@@ -107,23 +217,341 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 使用系统本地时区时交给浏览器决定，其他时区以 IANA 名称传给前端
+	timeZone := ""
+	if loc := utils.DisplayLocation(); loc != time.Local {
+		timeZone = loc.String()
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := tmpl.Execute(w, nil); err != nil {
-		logger.Error(fmt.Sprintf("模板执行失败: %v", err))
+	if err := tmpl.Execute(w, map[string]any{"TimeZone": timeZone}); err != nil {
+		log.Error(fmt.Sprintf("模板执行失败: %v", err))
 	}
 }
 
-// handleRecords 处理获取记录的 API 请求
+// handleRecords 返回最近的记录，最新记录在前
+// 查询参数见 exportRecords，未指定 limit 时最多返回 100 条
 func (s *Server) handleRecords(w http.ResponseWriter, r *http.Request) {
-	records, err := s.store.GetRecent(100) // 获取最近 100 条记录
-	if err != nil {
-		http.Error(w, "获取记录失败", http.StatusInternalServerError)
+	values := r.URL.Query()
+	if values.Get("limit") == "" {
+		values.Set("limit", "100")
+	}
+	records, ok := s.exportRecords(w, values)
+	if !ok {
 		return
 	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(records); err != nil {
-		logger.Error(fmt.Sprintf("JSON 编码失败: %v", err))
+		log.Error(fmt.Sprintf("JSON 编码失败: %v", err))
+	}
+}
+
+// handleSchema 返回 DNS 记录的 JSON Schema
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(model.DNSRecordSchema())
+}
+
+// handleSinks 返回输出目标运行状态
+func (s *Server) handleSinks(w http.ResponseWriter, r *http.Request) {
+	health := []output.Health{}
+	if s.sinkHealth != nil {
+		health = s.sinkHealth()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		log.Error(fmt.Sprintf("JSON 编码失败: %v", err))
+	}
+}
+
+// alertActions 告警操作对应的状态
+var alertActions = map[string]string{
+	"acknowledge": model.AlertAcknowledged,
+	"close":       model.AlertClosed,
+	"reopen":      model.AlertOpen,
+}
+
+// handleAlerts 返回告警，最近创建的在前
+// 查询参数 status 为告警状态，severity 为最低级别，detector 为检测器，
+// since 为最近一次告警的时间范围（如 1h），limit 默认 100
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	filter := store.AlertFilter{
+		Status:      values.Get("status"),
+		MinSeverity: values.Get("severity"),
+		Detector:    values.Get("detector"),
+		Limit:       100,
+	}
+	if filter.Status != "" && !slices.Contains(model.AlertStatuses, filter.Status) {
+		http.Error(w, fmt.Sprintf("未知的告警状态: %s", filter.Status), http.StatusBadRequest)
+		return
+	}
+	if filter.MinSeverity != "" && model.SeverityRank(filter.MinSeverity) < 0 {
+		http.Error(w, fmt.Sprintf("未知的告警级别: %s", filter.MinSeverity), http.StatusBadRequest)
+		return
+	}
+	if v := values.Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("无效的时间范围: %s", v), http.StatusBadRequest)
+			return
+		}
+		filter.Since = time.Now().Add(-d)
+	}
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, fmt.Sprintf("无效的数量: %s", v), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	alerts := []model.Alert{}
+	if s.alerts != nil {
+		alerts = s.alerts.List(filter)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(alerts); err != nil {
+		log.Error(fmt.Sprintf("JSON 编码失败: %v", err))
+	}
+}
+
+// handleAlert 返回单条告警
+func (s *Server) handleAlert(w http.ResponseWriter, r *http.Request) {
+	var alert model.Alert
+	ok := false
+	if s.alerts != nil {
+		alert, ok = s.alerts.Get(r.PathValue("id"))
+	}
+	if !ok {
+		http.Error(w, "告警不存在", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(alert); err != nil {
+		log.Error(fmt.Sprintf("JSON 编码失败: %v", err))
+	}
+}
+
+// handleAlertAction 确认、关闭或重新打开告警，仅接受 POST 请求，返回修改后的告警
+func (s *Server) handleAlertAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "仅支持 POST 请求", http.StatusMethodNotAllowed)
+		return
+	}
+	status, ok := alertActions[r.PathValue("action")]
+	if !ok {
+		http.Error(w, fmt.Sprintf("未知的告警操作: %s，可选 acknowledge、close、reopen", r.PathValue("action")), http.StatusNotFound)
+		return
+	}
+	if s.alerts == nil {
+		http.Error(w, "告警不存在", http.StatusNotFound)
+		return
+	}
+	alert, err := s.alerts.SetStatus(r.PathValue("id"), status)
+	if errors.Is(err, store.ErrAlertNotFound) {
+		http.Error(w, "告警不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(alert); err != nil {
+		log.Error(fmt.Sprintf("JSON 编码失败: %v", err))
+	}
+}
+
+// handleBeacons 返回心跳检测的候选，按置信度从高到低排列
+// 查询参数 all=true 时返回所有已评分的进程与域名组合，否则只返回达到告警阈值的组合
+func (s *Server) handleBeacons(w http.ResponseWriter, r *http.Request) {
+	all := r.URL.Query().Get("all") == "true"
+	candidates := []beacon.Candidate{}
+	if s.beacons != nil {
+		candidates = s.beacons(all)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(candidates); err != nil {
+		log.Error(fmt.Sprintf("JSON 编码失败: %v", err))
+	}
+}
+
+// handleNewlyObserved 返回最近记录的新域名（最新的在前）与数据库状态
+// 查询参数 since 为时间范围，默认且最多为 24h；scope 为 domain、name 或 process 时只返回该范围的记录
+func (s *Server) handleNewlyObserved(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	since := nod.RecentWindow
+	if v := values.Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("无效的时间范围: %s", v), http.StatusBadRequest)
+			return
+		}
+		since = min(d, nod.RecentWindow)
+	}
+	scope := values.Get("scope")
+	if scope != "" && !slices.Contains(nod.Scopes, scope) {
+		http.Error(w, fmt.Sprintf("未知的范围: %s", scope), http.StatusBadRequest)
+		return
+	}
+
+	response := struct {
+		Enabled      bool              `json:"enabled"`
+		Status       nod.Status        `json:"status"`
+		Observations []nod.Observation `json:"observations"`
+	}{Observations: []nod.Observation{}}
+	if s.newlyObserved != nil {
+		response.Observations, response.Status, response.Enabled = s.newlyObserved(time.Now().Add(-since), scope)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error(fmt.Sprintf("JSON 编码失败: %v", err))
+	}
+}
+
+// handleBaseline 以 YAML 格式下载当前的进程基线，学习模式下包含截至目前学习到的内容
+func (s *Server) handleBaseline(w http.ResponseWriter, r *http.Request) {
+	var b *baseline.Baseline
+	ok := false
+	if s.baseline != nil {
+		b, ok = s.baseline()
+	}
+	if !ok {
+		http.Error(w, "未启用进程基线", http.StatusNotFound)
+		return
+	}
+	data, err := b.Marshal()
+	if err != nil {
+		http.Error(w, "编码基线失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", `attachment; filename="baseline.yaml"`)
+	w.Write(data)
+}
+
+// handleConfigReload 重新加载配置文件，仅接受 POST 请求
+// 配置无效时返回 400 与错误信息，当前配置保持不变
+func (s *Server) handleConfigReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "仅支持 POST 请求", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.reload == nil {
+		http.Error(w, "不支持重新加载配置", http.StatusNotImplemented)
+		return
+	}
+	if err := s.reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]bool{"ok": true}); err != nil {
+		log.Error(fmt.Sprintf("JSON 编码失败: %v", err))
+	}
+}
+
+// exportRecords 按查询参数筛选要导出的记录，按时间顺序返回
+// 查询参数 queryTypes、domains、excludeDomains、processes、excludeProcesses、rcodes 以逗号分隔，
+// since、until 限定时间范围，limit 限制导出的最近记录数。参数无效时已写入错误响应并返回 false
+func (s *Server) exportRecords(w http.ResponseWriter, values url.Values) ([]model.DNSRecord, bool) {
+	q, err := query.FromValues(values, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	records, err := s.store.GetRecent(0)
+	if err != nil {
+		http.Error(w, "获取记录失败", http.StatusInternalServerError)
+		return nil, false
+	}
+	return q.Apply(records), true
+}
+
+// setAttachment 设置下载文件名
+func setAttachment(w http.ResponseWriter, contentType, ext string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="dnsflux-%s%s"`, utils.DisplayTime(time.Now()).Format("20060102-150405"), ext))
+}
+
+// handleExportPcapng 将存储中的记录导出为 pcapng 文件
+// 过滤参数见 exportRecords，responses=false 时只导出查询包
+func (s *Server) handleExportPcapng(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	records, ok := s.exportRecords(w, values)
+	if !ok {
+		return
+	}
+	responses := values.Get("responses") != "false"
+
+	setAttachment(w, "application/x-pcapng", ".pcapng")
+	writer, err := pcapng.NewWriter(w, "dnsflux", responses)
+	if err != nil {
+		log.Error(fmt.Sprintf("导出 pcapng 失败: %v", err))
+		return
+	}
+	for i := range records {
+		if err := writer.WriteRecord(&records[i]); err != nil {
+			log.Debug(fmt.Sprintf("跳过无法导出的记录: %v", err))
+		}
+	}
+}
+
+// handleExportCSV 将存储中的记录导出为 CSV 文件
+// 过滤参数见 exportRecords，columns 以逗号分隔指定输出列
+func (s *Server) handleExportCSV(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	opts := tabular.Options{Columns: tabular.SplitColumns(values.Get("columns"))}
+	if err := tabular.CheckOptions(tabular.FormatCSV, opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	records, ok := s.exportRecords(w, values)
+	if !ok {
+		return
+	}
+
+	setAttachment(w, "text/csv; charset=utf-8", ".csv")
+	if err := tabular.Export(w, tabular.FormatCSV, records, opts); err != nil {
+		log.Error(fmt.Sprintf("导出 CSV 失败: %v", err))
+	}
+}
+
+// handleExportParquet 将存储中的记录导出为 Parquet 文件
+// 过滤参数见 exportRecords，compression 指定压缩算法（none、snappy、gzip、zstd）
+func (s *Server) handleExportParquet(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	opts := tabular.Options{Compression: values.Get("compression"), CreatedBy: "dnsflux"}
+	if err := tabular.CheckOptions(tabular.FormatParquet, opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	records, ok := s.exportRecords(w, values)
+	if !ok {
+		return
+	}
+
+	setAttachment(w, "application/vnd.apache.parquet", ".parquet")
+	if err := tabular.Export(w, tabular.FormatParquet, records, opts); err != nil {
+		log.Error(fmt.Sprintf("导出 Parquet 失败: %v", err))
 	}
 }
 
@@ -131,20 +559,21 @@ func (s *Server) handleRecords(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		logger.Error(fmt.Sprintf("WebSocket 升级失败: %v", err))
+		log.Error(fmt.Sprintf("WebSocket 升级失败: %v", err))
 		return
 	}
 	defer conn.Close()
 
 	// 注册客户端
+	client := &wsClient{conn: conn}
 	s.mu.Lock()
-	s.clients[conn] = true
+	s.clients[conn] = client
 	s.mu.Unlock()
 
 	// 发送最近的记录
 	if records, err := s.store.GetRecent(50); err == nil {
 		for _, record := range records {
-			if err := conn.WriteJSON(record); err != nil {
+			if err := client.writeJSON(record); err != nil {
 				break
 			}
 		}
@@ -164,17 +593,68 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	s.mu.Unlock()
 }
 
+// alertSnapshot 连接时推送的已有告警的事件类型
+const alertSnapshot = "snapshot"
+
+// handleAlertWebSocket 处理告警 WebSocket 连接
+// 连接时推送最近 50 条未关闭的告警（snapshot），之后推送新建告警（created）以及告警合并与状态变化（updated）
+func (s *Server) handleAlertWebSocket(w http.ResponseWriter, r *http.Request) {
+	if s.alerts == nil {
+		http.Error(w, "未启用告警存储", http.StatusNotFound)
+		return
+	}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error(fmt.Sprintf("WebSocket 升级失败: %v", err))
+		return
+	}
+	defer conn.Close()
+
+	// 先注册再发送，广播与快照通过客户端的写锁串行写入，不会遗漏期间产生的告警
+	client := &wsClient{conn: conn}
+	s.mu.Lock()
+	s.alertClients[conn] = client
+	s.mu.Unlock()
+
+	// 按时间顺序发送未关闭的告警
+	var pending []model.Alert
+	for _, alert := range s.alerts.List(store.AlertFilter{}) {
+		if alert.Status != model.AlertClosed {
+			pending = append(pending, alert)
+			if len(pending) == 50 {
+				break
+			}
+		}
+	}
+	for i := len(pending) - 1; i >= 0; i-- {
+		if err := client.writeJSON(store.AlertEvent{Type: alertSnapshot, Alert: pending[i]}); err != nil {
+			break
+		}
+	}
+
+	for {
+		_, _, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.alertClients, conn)
+	s.mu.Unlock()
+}
+
+// clientCount 返回当前 WebSocket 客户端数，包括记录与告警连接
+func (s *Server) clientCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.clients) + len(s.alertClients)
+}
+
 // broadcastLoop WebSocket 广播循环
 func (s *Server) broadcastLoop(ctx context.Context) {
 	ch := s.store.Subscribe()
-	defer func() {
-		// 关闭所有客户端连接
-		s.mu.Lock()
-		for conn := range s.clients {
-			conn.Close()
-		}
-		s.mu.Unlock()
-	}()
+	defer s.closeClients(s.clients)
 
 	for {
 		select {
@@ -184,27 +664,67 @@ func (s *Server) broadcastLoop(ctx context.Context) {
 			if !ok {
 				return
 			}
-			s.broadcast(record)
+			s.broadcast(s.clients, record)
+		}
+	}
+}
+
+// alertLoop 告警 WebSocket 广播循环
+func (s *Server) alertLoop(ctx context.Context) {
+	ch := s.alerts.Subscribe()
+	defer s.closeClients(s.alertClients)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			s.broadcast(s.alertClients, event)
 		}
 	}
 }
 
-// broadcast 广播消息给所有 WebSocket 客户端
-func (s *Server) broadcast(record model.DNSRecord) {
+// closeClients 关闭所有客户端连接
+func (s *Server) closeClients(clients map[*websocket.Conn]*wsClient) {
+	s.mu.Lock()
+	for conn := range clients {
+		conn.Close()
+	}
+	s.mu.Unlock()
+}
+
+// broadcast 广播消息给一组 WebSocket 客户端
+func (s *Server) broadcast(clients map[*websocket.Conn]*wsClient, message any) {
 	s.mu.RLock()
-	clients := make([]*websocket.Conn, 0, len(s.clients))
-	for conn := range s.clients {
-		clients = append(clients, conn)
+	targets := make([]*wsClient, 0, len(clients))
+	for _, client := range clients {
+		targets = append(targets, client)
 	}
 	s.mu.RUnlock()
 
-	for _, conn := range clients {
-		if err := conn.WriteJSON(record); err != nil {
+	for _, client := range targets {
+		if err := client.writeJSON(message); err != nil {
 			// 连接已断开，移除客户端
 			s.mu.Lock()
-			delete(s.clients, conn)
+			delete(clients, client.conn)
 			s.mu.Unlock()
-			conn.Close()
+			client.conn.Close()
 		}
 	}
 }
+
+// wsClient WebSocket 客户端，连接不支持并发写入，连接时的初始推送与广播通过写锁串行
+type wsClient struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+// writeJSON 以 JSON 写入一条消息
+func (c *wsClient) writeJSON(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(v)
+}
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"dnsflux/internal/model"
+	"dnsflux/internal/store/memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testServer 启动使用内存存储的测试服务器，返回服务器地址与配置重新加载次数
+func testServer(t *testing.T) (*Server, *httptest.Server, *atomic.Int32) {
+	t.Helper()
+	s := New(memory.New(100), "127.0.0.1", 0)
+	alerts := memory.NewAlerts(memory.AlertOptions{Capacity: 10, Window: time.Hour, MaxRecords: 10})
+	t.Cleanup(func() { alerts.Close() })
+	s.SetAlertStore(alerts)
+	var reloads atomic.Int32
+	s.SetReloadFunc(func() error {
+		reloads.Add(1)
+		return nil
+	})
+	server := httptest.NewServer(s.handler())
+	t.Cleanup(server.Close)
+	return s, server, &reloads
+}
+
+func TestCrossSiteRequestsRejected(t *testing.T) {
+	_, server, reloads := testServer(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		{"没有 Origin（curl）", "", http.StatusOK},
+		{"同源页面", "http://" + host, http.StatusOK},
+		{"其他站点", "http://evil.example", http.StatusForbidden},
+		{"同主机不同端口", "http://127.0.0.1:1", http.StatusForbidden},
+		{"沙箱页面", "null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := reloads.Load()
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/config/reload", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("状态码 = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if reloaded := reloads.Load() != before; reloaded != (tt.want == http.StatusOK) {
+				t.Errorf("是否重新加载配置 = %v", reloaded)
+			}
+		})
+	}
+}
+
+func TestCrossSiteAlertAction(t *testing.T) {
+	s, server, _ := testServer(t)
+	alert, _ := s.alerts.Raise(model.Alert{Detector: "intel", Severity: model.SeverityHigh, QueryName: "evil.example"})
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/alerts/"+alert.ID+"/close", nil)
+	req.Header.Set("Origin", "http://evil.example")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("跨站修改告警状态码 = %d, want 403", resp.StatusCode)
+	}
+	if got, _ := s.alerts.Get(alert.ID); got.Status != model.AlertOpen {
+		t.Errorf("跨站请求修改了告警状态: %s", got.Status)
+	}
+
+	// 只读请求不检查 Origin
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/api/alerts", nil)
+	req.Header.Set("Origin", "http://evil.example")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("只读请求状态码 = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	_, server, _ := testServer(t)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	tests := []struct {
+		name   string
+		path   string
+		origin string
+		ok     bool
+	}{
+		{"记录流同源", "/ws", server.URL, true},
+		{"记录流跨站", "/ws", "http://evil.example", false},
+		{"告警流跨站", "/ws/alerts", "http://evil.example", false},
+		{"告警流没有 Origin", "/ws/alerts", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(wsURL+tt.path, header)
+			if conn != nil {
+				conn.Close()
+			}
+			if tt.ok && err != nil {
+				t.Fatalf("握手失败: %v", err)
+			}
+			if !tt.ok && (err == nil || resp == nil || resp.StatusCode != http.StatusForbidden) {
+				t.Errorf("跨站握手应被拒绝, err = %v", err)
+			}
+		})
+	}
+}
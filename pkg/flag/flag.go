@@ -1,19 +1,68 @@
 package flag
 
 import (
-	"flag"
-	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
+// Config 运行参数，由各子命令按需注册的参数组填充
+// 未显式指定的参数保留默认值，由 IsSet 区分，以便配置文件中的值生效
 type Config struct {
+	// 配置文件路径，命令行参数与环境变量优先于配置文件
+	ConfigPath string
+
 	EnableWeb  bool
 	ListenAddr string
 	ListenPort int
 	LogLevel   string
+	Timezone   string
+
+	// 程序日志
+	LogFormat   string
+	LogStream   string
+	LogFile     string
+	LogMaxSize  int
+	LogMaxFiles int
+
+	// 控制台记录输出
+	Quiet           bool
+	ConsoleFormat   string
+	ConsoleTemplate string
+	Color           string
+
+	// JSONL 记录输出
+	OutputDir      string
+	OutputMaxSize  int
+	OutputMaxAge   int
+	OutputMaxTotal int
+	OutputCompress string
+	OutputTimezone string
+
+	// 额外的输出目标定义，可重复指定
+	Sinks []string
+
+	// Prometheus 指标
+	MetricsAddr         string
+	MetricsTopProcesses int
+
+	fs *FlagSet
+}
+
+// stringList 可重复指定的字符串参数
+type stringList []string
+
+// String 实现 flag.Value 接口
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+// Set 实现 flag.Value 接口
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
 // GetEnv 获取环境变量
@@ -44,50 +93,101 @@ func GetEnvAsBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
-// ParseFlags 解析命令行参数
-func ParseFlags() *Config {
+// NewRunFlags 创建 run 子命令的参数集合，check 子命令使用相同的参数
+func NewRunFlags(name string, summary Text) (*FlagSet, *Config) {
 	cfg := &Config{}
+	fs := NewFlagSet(name, Text{"[options]", "[选项]"}, summary)
+	cfg.AddConfigFlags(fs)
+	cfg.AddWebFlags(fs)
+	cfg.AddLogFlags(fs)
+	cfg.AddConsoleFlags(fs)
+	cfg.AddOutputFlags(fs)
+	cfg.AddMetricsFlags(fs)
+	return fs, cfg
+}
 
-	// 定义默认值
-	defaultEnableWeb := GetEnvAsBool("DNSFLUX_ENABLE_WEB", false)
-	defaultListenAddr := GetEnv("DNSFLUX_HOST", "127.0.0.1")
-	defaultListenPort := GetEnvAsInt("DNSFLUX_PORT", 58080)
-	defaultLogLevel := GetEnv("DNSFLUX_LOG_LEVEL", "info")
-
-	// 定义命令行参数
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "使用说明: %s [选项]\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "选项:\n")
-		fmt.Fprintf(os.Stderr, "  -w, --web\t\t是否启用 Web 服务 (默认值: %v)\n", defaultEnableWeb)
-		fmt.Fprintf(os.Stderr, "  -a, --addr string\t\tWeb服务监听地址 (默认值: \"%s\")\n", defaultListenAddr)
-		fmt.Fprintf(os.Stderr, "  -p, --port int\t\tWeb服务监听端口 (默认值: %d)\n", defaultListenPort)
-		fmt.Fprintf(os.Stderr, "  -l, --log-level string\t日志级别 [debug, info, warn, error] (默认值: \"%s\")\n", defaultLogLevel)
-		fmt.Fprintf(os.Stderr, "  -h, --help\t\t\t显示帮助信息\n")
-		fmt.Fprintf(os.Stderr, "\n示例:\n")
-		fmt.Fprintf(os.Stderr, "  %s -a 0.0.0.0 -p 1688 -l info\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s --addr=0.0.0.0 --port=1688 --log-level=info\n", os.Args[0])
-	}
+// AddConfigFlags 注册配置文件与时区参数
+func (c *Config) AddConfigFlags(fs *FlagSet) {
+	c.fs = fs
+	fs.StringVar(&c.ConfigPath, "config", "c", "DNSFLUX_CONFIG", "",
+		Text{"YAML configuration file; flags and environment variables take precedence", "YAML 配置文件路径，命令行参数与环境变量优先"})
+	fs.StringVar(&c.Timezone, "timezone", "", "DNSFLUX_TZ", "Local",
+		Text{"Timezone for displayed times, e.g. UTC or Asia/Shanghai; records are stored in UTC", "展示时间使用的时区，如 UTC、Asia/Shanghai；记录始终以 UTC 保存"})
+}
 
-	// 定义命令行参数（包含简写）
-	flag.BoolVar(&cfg.EnableWeb, "web", defaultEnableWeb, "是否启用 Web 服务")
-	flag.BoolVar(&cfg.EnableWeb, "w", defaultEnableWeb, "是否启用 Web 服务 (简写)")
-	flag.StringVar(&cfg.ListenAddr, "addr", defaultListenAddr, "Web服务监听地址")
-	flag.StringVar(&cfg.ListenAddr, "a", defaultListenAddr, "Web服务监听地址 (简写)")
-	flag.IntVar(&cfg.ListenPort, "port", defaultListenPort, "Web服务监听端口")
-	flag.IntVar(&cfg.ListenPort, "p", defaultListenPort, "服务监听端口 (简写)")
-	flag.StringVar(&cfg.LogLevel, "log-level", defaultLogLevel, "日志级别 (debug, info, warn, error)")
-	flag.StringVar(&cfg.LogLevel, "l", defaultLogLevel, "日志级别 (简写)")
-	flag.BoolVar(&cfg.EnableWeb, "h", false, "显示帮助信息")
-
-	// 解析命令行参数
-	flag.Parse()
-
-	// 设置日志级别（覆盖默认配置）
-	if err := cfg.SetLogLevel(); err != nil {
-		logrus.Fatalf("Failed to set log level: %v", err)
-	}
+// AddWebFlags 注册 Web 服务参数
+func (c *Config) AddWebFlags(fs *FlagSet) {
+	c.fs = fs
+	fs.BoolVar(&c.EnableWeb, "web", "w", "DNSFLUX_ENABLE_WEB", false,
+		Text{"Enable the web service", "启用 Web 服务"})
+	fs.StringVar(&c.ListenAddr, "addr", "a", "DNSFLUX_HOST", "127.0.0.1",
+		Text{"Web service listen address", "Web 服务监听地址"})
+	fs.IntVar(&c.ListenPort, "port", "p", "DNSFLUX_PORT", 58080,
+		Text{"Web service listen port", "Web 服务监听端口"})
+}
+
+// AddLogFlags 注册程序日志参数
+func (c *Config) AddLogFlags(fs *FlagSet) {
+	c.fs = fs
+	fs.StringVar(&c.LogLevel, "log-level", "l", "DNSFLUX_LOG_LEVEL", "info",
+		Text{"Log level [debug, info, warn, error]", "日志级别 [debug, info, warn, error]"})
+	fs.StringVar(&c.LogFormat, "log-format", "", "DNSFLUX_LOG_FORMAT", "text",
+		Text{"Log format [text, json]", "日志格式 [text, json]"})
+	fs.StringVar(&c.LogStream, "log-stream", "", "DNSFLUX_LOG_STREAM", "auto",
+		Text{"Console log stream [auto, stdout, stderr, none]", "控制台日志输出位置 [auto, stdout, stderr, none]"})
+	fs.StringVar(&c.LogFile, "log-file", "", "DNSFLUX_LOG_FILE", "",
+		Text{"Also write logs to this file, rotated by size", "同时写入该日志文件，按大小轮转"})
+	fs.IntVar(&c.LogMaxSize, "log-max-size", "", "DNSFLUX_LOG_MAX_SIZE", 10,
+		Text{"Maximum log file size in MB", "单个日志文件最大大小 (MB)"})
+	fs.IntVar(&c.LogMaxFiles, "log-max-files", "", "DNSFLUX_LOG_MAX_FILES", 5,
+		Text{"Number of rotated log files to keep", "保留的轮转日志文件个数"})
+}
+
+// AddConsoleFlags 注册控制台记录输出参数
+func (c *Config) AddConsoleFlags(fs *FlagSet) {
+	c.fs = fs
+	fs.BoolVar(&c.Quiet, "quiet", "q", "DNSFLUX_QUIET", false,
+		Text{"Do not print DNS records to the console", "不在控制台输出 DNS 记录"})
+	fs.StringVar(&c.ConsoleFormat, "console-format", "f", "DNSFLUX_CONSOLE_FORMAT", "auto",
+		Text{"Console format [auto, pretty, compact, json, template]", "控制台输出格式 [auto, pretty, compact, json, template]"})
+	fs.StringVar(&c.ConsoleTemplate, "console-template", "", "DNSFLUX_CONSOLE_TEMPLATE", "",
+		Text{"Go template for the template format, e.g. '{{.ProcessName}} {{.QueryName}}'", "template 格式使用的 Go 模板，如 '{{.ProcessName}} {{.QueryName}}'"})
+	fs.StringVar(&c.Color, "color", "", "DNSFLUX_COLOR", "auto",
+		Text{"Color for the compact format [auto, always, never]", "compact 格式的颜色 [auto, always, never]"})
+}
+
+// AddOutputFlags 注册 JSONL 记录文件与额外输出目标参数
+func (c *Config) AddOutputFlags(fs *FlagSet) {
+	c.fs = fs
+	fs.StringVar(&c.OutputDir, "output-dir", "", "DNSFLUX_OUTPUT_DIR", "logs",
+		Text{"Directory for JSONL record files", "DNS 记录输出目录"})
+	fs.IntVar(&c.OutputMaxSize, "output-max-size", "", "DNSFLUX_OUTPUT_MAX_SIZE", 100,
+		Text{"Maximum record file size in MB", "单个记录文件最大大小 (MB)"})
+	fs.IntVar(&c.OutputMaxAge, "output-max-age", "", "DNSFLUX_OUTPUT_MAX_AGE", 30,
+		Text{"Days to keep archived record files", "归档文件保留天数"})
+	fs.IntVar(&c.OutputMaxTotal, "output-max-total", "", "DNSFLUX_OUTPUT_MAX_TOTAL", 1024,
+		Text{"Total size limit of archived record files in MB", "归档文件总大小上限 (MB)"})
+	fs.StringVar(&c.OutputCompress, "output-compress", "", "DNSFLUX_OUTPUT_COMPRESS", "gzip",
+		Text{"Compression for archived files [none, gzip, zstd]", "归档文件压缩算法 [none, gzip, zstd]"})
+	fs.StringVar(&c.OutputTimezone, "output-tz", "", "DNSFLUX_OUTPUT_TZ", "",
+		Text{"Timezone for daily rotation (default: same as --timezone)", "按天轮转使用的时区 (默认与 --timezone 相同)"})
+	fs.ListVar(&c.Sinks, "sink", "",
+		Text{"Extra output sink, repeatable, e.g. syslog?network=tcp&address=127.0.0.1:514&format=cef", "额外的输出目标，可重复指定，如 syslog?network=tcp&address=127.0.0.1:514&format=cef"})
+}
+
+// AddMetricsFlags 注册 Prometheus 指标参数
+func (c *Config) AddMetricsFlags(fs *FlagSet) {
+	c.fs = fs
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", "", "DNSFLUX_METRICS_ADDR", "",
+		Text{"Serve /metrics on a separate address, e.g. 0.0.0.0:9153 (default: with the web service only)", "在独立地址上提供 /metrics，如 0.0.0.0:9153 (默认仅随 Web 服务提供)"})
+	fs.IntVar(&c.MetricsTopProcesses, "metrics-top-processes", "", "DNSFLUX_METRICS_TOP_PROCESSES", 20,
+		Text{"Number of processes exported in per-process query counts", "按进程导出查询数的进程个数"})
+}
 
-	return cfg
+// IsSet 判断参数是否在命令行或环境变量中显式指定，name 为完整参数名（如 log-level）
+// 未指定或当前子命令没有的参数使用配置文件中的值
+func (c *Config) IsSet(name string) bool {
+	return c.fs != nil && c.fs.IsSet(name)
 }
 
 // SetLogLevel 设置日志级别
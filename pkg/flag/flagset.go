@@ -0,0 +1,255 @@
+package flag
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// ErrHelp 请求显示帮助信息（-h 或 --help）
+var ErrHelp = flag.ErrHelp
+
+// Text 中英文文本，根据语言环境选择
+type Text struct {
+	EN string
+	ZH string
+}
+
+// String 返回当前语言的文本
+func (t Text) String() string {
+	if Chinese() {
+		return t.ZH
+	}
+	return t.EN
+}
+
+// Chinese 判断帮助信息是否使用中文
+// 依次查看 DNSFLUX_LANG、LC_ALL、LC_MESSAGES、LANG，第一个非空的值以 zh 开头时使用中文；均未设置时使用中文
+func Chinese() bool {
+	for _, key := range []string{"DNSFLUX_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(key); value != "" {
+			return strings.HasPrefix(strings.ToLower(value), "zh")
+		}
+	}
+	return true
+}
+
+// UsageError 命令行参数错误，调用方应提示查看帮助
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// Usagef 创建命令行参数错误
+func Usagef(format string, args ...any) error {
+	return &UsageError{Err: fmt.Errorf(format, args...)}
+}
+
+// entry 帮助信息中的一个参数
+type entry struct {
+	name  string
+	short string
+	env   string
+	kind  string // 参数值类型，布尔参数为空
+	usage Text
+	value string // 默认值，为空时不显示
+}
+
+// FlagSet 子命令的参数集合
+// 每个参数有完整名称（--name）与可选的简写（-n），默认值可由环境变量覆盖，参数与位置参数可以交替出现
+type FlagSet struct {
+	fs       *flag.FlagSet
+	name     string
+	args     Text
+	summary  Text
+	examples []string
+	entries  []*entry
+	aliases  map[string]string
+	set      map[string]bool
+	rest     []string
+}
+
+// NewFlagSet 创建参数集合，name 为完整命令（如 dnsflux query），args 描述位置参数
+func NewFlagSet(name string, args, summary Text) *FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return &FlagSet{
+		fs:      fs,
+		name:    name,
+		args:    args,
+		summary: summary,
+		aliases: make(map[string]string),
+		set:     make(map[string]bool),
+	}
+}
+
+// Example 添加帮助信息中的示例
+func (f *FlagSet) Example(lines ...string) {
+	f.examples = append(f.examples, lines...)
+}
+
+// add 记录参数的帮助信息与简写
+func (f *FlagSet) add(e *entry, define func(name string)) {
+	define(e.name)
+	if e.short != "" {
+		define(e.short)
+		f.aliases[e.short] = e.name
+	}
+	f.entries = append(f.entries, e)
+}
+
+// StringVar 定义字符串参数，env 非空且已设置时作为默认值
+func (f *FlagSet) StringVar(p *string, name, short, env, value string, usage Text) {
+	value = GetEnv(env, value)
+	f.add(&entry{name: name, short: short, env: env, kind: "string", usage: usage, value: quote(value)}, func(n string) {
+		f.fs.StringVar(p, n, value, "")
+	})
+}
+
+// IntVar 定义整数参数
+func (f *FlagSet) IntVar(p *int, name, short, env string, value int, usage Text) {
+	value = GetEnvAsInt(env, value)
+	f.add(&entry{name: name, short: short, env: env, kind: "int", usage: usage, value: strconv.Itoa(value)}, func(n string) {
+		f.fs.IntVar(p, n, value, "")
+	})
+}
+
+// Float64Var 定义浮点数参数
+func (f *FlagSet) Float64Var(p *float64, name, short, env string, value float64, usage Text) {
+	if v, err := strconv.ParseFloat(GetEnv(env, ""), 64); err == nil {
+		value = v
+	}
+	f.add(&entry{name: name, short: short, env: env, kind: "float", usage: usage, value: strconv.FormatFloat(value, 'g', -1, 64)}, func(n string) {
+		f.fs.Float64Var(p, n, value, "")
+	})
+}
+
+// BoolVar 定义布尔参数，可写作 --name 或 --name=false
+func (f *FlagSet) BoolVar(p *bool, name, short, env string, value bool, usage Text) {
+	value = GetEnvAsBool(env, value)
+	shown := ""
+	if value {
+		shown = "true"
+	}
+	f.add(&entry{name: name, short: short, env: env, usage: usage, value: shown}, func(n string) {
+		f.fs.BoolVar(p, n, value, "")
+	})
+}
+
+// ListVar 定义可重复指定的字符串参数
+func (f *FlagSet) ListVar(p *[]string, name, short string, usage Text) {
+	f.add(&entry{name: name, short: short, kind: "string", usage: usage}, func(n string) {
+		f.fs.Var((*stringList)(p), n, "")
+	})
+}
+
+// Parse 解析参数，-h/--help 时返回 ErrHelp，参数错误时返回 *UsageError
+func (f *FlagSet) Parse(args []string) error {
+	var rest []string
+	for {
+		if err := f.fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return ErrHelp
+			}
+			return &UsageError{Err: err}
+		}
+		remaining := f.fs.Args()
+		if len(remaining) == 0 {
+			break
+		}
+		// "--" 之后的内容均为位置参数
+		if consumed := len(args) - len(remaining); consumed > 0 && args[consumed-1] == "--" {
+			rest = append(rest, remaining...)
+			break
+		}
+		rest = append(rest, remaining[0])
+		args = remaining[1:]
+	}
+	f.rest = rest
+
+	f.fs.Visit(func(fl *flag.Flag) {
+		if name, ok := f.aliases[fl.Name]; ok {
+			f.set[name] = true
+			return
+		}
+		f.set[fl.Name] = true
+	})
+	for _, e := range f.entries {
+		if e.env != "" && os.Getenv(e.env) != "" {
+			f.set[e.name] = true
+		}
+	}
+	return nil
+}
+
+// Args 返回位置参数
+func (f *FlagSet) Args() []string {
+	return f.rest
+}
+
+// IsSet 判断参数是否在命令行或环境变量中显式指定，name 为完整参数名
+func (f *FlagSet) IsSet(name string) bool {
+	return f.set[name]
+}
+
+// PrintUsage 输出帮助信息
+func (f *FlagSet) PrintUsage(w io.Writer) {
+	fmt.Fprintf(w, "%s: %s %s\n\n", Text{"Usage", "用法"}, f.name, f.args)
+	if summary := f.summary.String(); summary != "" {
+		fmt.Fprintf(w, "%s\n\n", summary)
+	}
+
+	fmt.Fprintf(w, "%s:\n", Text{"Options", "选项"})
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, e := range f.entries {
+		names := "    --" + e.name
+		if e.short != "" {
+			names = "-" + e.short + ", --" + e.name
+		}
+		if e.kind != "" {
+			names += " " + e.kind
+		}
+
+		usage := e.usage.String()
+		var notes []string
+		if e.value != "" {
+			notes = append(notes, fmt.Sprintf("%s: %s", Text{"default", "默认值"}, e.value))
+		}
+		if e.env != "" {
+			notes = append(notes, fmt.Sprintf("%s: %s", Text{"env", "环境变量"}, e.env))
+		}
+		if len(notes) > 0 {
+			usage += " (" + strings.Join(notes, ", ") + ")"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", names, usage)
+	}
+	fmt.Fprintf(tw, "  -h, --help\t%s\n", Text{"Show this help", "显示帮助信息"})
+	tw.Flush()
+
+	if len(f.examples) > 0 {
+		fmt.Fprintf(w, "\n%s:\n", Text{"Examples", "示例"})
+		for _, line := range f.examples {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+}
+
+// quote 为非空字符串默认值加上引号
+func quote(value string) string {
+	if value == "" {
+		return ""
+	}
+	return strconv.Quote(value)
+}
@@ -0,0 +1,202 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// 日志格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// 控制台日志输出位置
+const (
+	StreamAuto   = "auto" // 标准输出为终端时使用标准输出，否则使用标准错误
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+	StreamNone   = "none" // 不输出到控制台，仅写入日志文件
+)
+
+// Options 日志配置
+type Options struct {
+	Level     string // debug, info, warn, error
+	Format    string // text, json
+	Stream    string // auto, stdout, stderr, none
+	File      string // 日志文件路径，为空时不写文件
+	MaxSizeMB int    // 单个日志文件最大大小
+	MaxFiles  int    // 保留的轮转文件个数
+}
+
+// fileState 当前打开的日志文件，配置未变化时重新配置不会重新打开
+var fileState struct {
+	mu     sync.Mutex
+	writer *RotatingFileWriter
+	key    string
+}
+
+// Configure 按配置设置日志级别、格式与输出位置，可在运行中重复调用
+// 控制台为终端时文本格式带颜色，写入文件的内容始终不带颜色
+func Configure(opts Options) error {
+	setup, err := Prepare(opts)
+	if err != nil {
+		return err
+	}
+	setup.Apply()
+	return nil
+}
+
+// Setup 已校验的日志配置，Apply 之前不影响当前日志输出
+// 用于与其他配置一起校验，全部成功后再统一生效
+type Setup struct {
+	level     logrus.Level
+	setLevel  bool
+	formatter logrus.Formatter
+	out       io.Writer
+	file      *RotatingFileWriter
+	key       string
+	opened    bool // 日志文件为新打开，Discard 时需要关闭
+}
+
+// Prepare 校验日志配置并打开新的日志文件，不修改当前日志输出
+// 返回的 Setup 必须调用 Apply 生效或调用 Discard 放弃
+func Prepare(opts Options) (*Setup, error) {
+	setup := &Setup{}
+	if opts.Level != "" {
+		lvl, err := logrus.ParseLevel(opts.Level)
+		if err != nil {
+			return nil, fmt.Errorf("无效的日志级别 '%s': %v", opts.Level, err)
+		}
+		setup.level, setup.setLevel = lvl, true
+	}
+
+	var console io.Writer
+	tty := false
+	switch opts.Stream {
+	case "", StreamAuto:
+		file := consoleOutput()
+		console, tty = file, term.IsTerminal(int(file.Fd()))
+	case StreamStdout:
+		console, tty = os.Stdout, term.IsTerminal(int(os.Stdout.Fd()))
+	case StreamStderr:
+		console, tty = os.Stderr, term.IsTerminal(int(os.Stderr.Fd()))
+	case StreamNone:
+	default:
+		return nil, fmt.Errorf("无效的日志输出位置 '%s'", opts.Stream)
+	}
+
+	switch opts.Format {
+	case "", FormatText:
+		setup.formatter = NewCustomFormatterWithColor(tty)
+	case FormatJSON:
+		setup.formatter = newJSONFormatter()
+	default:
+		return nil, fmt.Errorf("无效的日志格式 '%s'", opts.Format)
+	}
+
+	if err := setup.openFile(opts); err != nil {
+		return nil, err
+	}
+
+	setup.out = io.Discard
+	switch {
+	case console != nil && setup.file != nil:
+		setup.out = &MultiFormatterWriter{consoleWriter: console, fileWriter: setup.file}
+	case console != nil:
+		setup.out = console
+	case setup.file != nil:
+		setup.out = setup.file
+	}
+	return setup, nil
+}
+
+// openFile 路径或轮转参数变化时打开新的日志文件，否则沿用当前打开的文件
+func (s *Setup) openFile(opts Options) error {
+	fileState.mu.Lock()
+	defer fileState.mu.Unlock()
+
+	if opts.File != "" {
+		s.key = fmt.Sprintf("%s|%d|%d", filepath.Clean(opts.File), opts.MaxSizeMB, opts.MaxFiles)
+	}
+	if s.key == fileState.key {
+		s.file = fileState.writer
+		return nil
+	}
+	if opts.File != "" {
+		writer, err := NewRotatingFileWriter(opts.File, opts.MaxSizeMB, opts.MaxFiles)
+		if err != nil {
+			return err
+		}
+		s.file = writer
+	}
+	s.opened = true
+	return nil
+}
+
+// Apply 使日志配置生效，日志文件变化时关闭原文件
+func (s *Setup) Apply() {
+	var stale *RotatingFileWriter
+	if s.opened {
+		fileState.mu.Lock()
+		stale = fileState.writer
+		fileState.writer, fileState.key = s.file, s.key
+		fileState.mu.Unlock()
+	}
+
+	if s.setLevel {
+		logrus.SetLevel(s.level)
+	}
+	// 日志经由本包的便捷方法记录，调用位置没有意义
+	logrus.SetReportCaller(false)
+	logrus.SetFormatter(s.formatter)
+	logrus.SetOutput(s.out)
+	if stale != nil {
+		stale.Close()
+	}
+}
+
+// Discard 放弃日志配置，关闭 Prepare 新打开的日志文件
+func (s *Setup) Discard() {
+	if s.opened && s.file != nil {
+		s.file.Close()
+	}
+}
+
+// Close 关闭日志文件
+func Close() error {
+	fileState.mu.Lock()
+	defer fileState.mu.Unlock()
+
+	if fileState.writer == nil {
+		return nil
+	}
+	logrus.SetOutput(consoleOutput())
+	err := fileState.writer.Close()
+	fileState.writer, fileState.key = nil, ""
+	return err
+}
+
+// jsonFormatter 每行一个 JSON 对象，时间使用配置的时区
+type jsonFormatter struct {
+	logrus.JSONFormatter
+}
+
+func newJSONFormatter() *jsonFormatter {
+	return &jsonFormatter{logrus.JSONFormatter{
+		TimestampFormat:   "2006-01-02T15:04:05.000Z07:00",
+		DisableHTMLEscape: true,
+	}}
+}
+
+// Format 实现 logrus.Formatter 接口
+func (f *jsonFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	entry.Time = entry.Time.In(Location())
+	return f.JSONFormatter.Format(entry)
+}
@@ -5,12 +5,32 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
 )
 
+// location 日志时间所使用的时区
+var location atomic.Pointer[time.Location]
+
+// SetLocation 设置日志时间所使用的时区
+func SetLocation(loc *time.Location) {
+	location.Store(loc)
+}
+
+// Location 返回日志时间所使用的时区，未设置时为系统本地时区
+func Location() *time.Location {
+	if loc := location.Load(); loc != nil {
+		return loc
+	}
+	return time.Local
+}
+
 // CustomFormatter 自定义日志格式化器
 type CustomFormatter struct {
 	EnableColor bool // 是否启用颜色输出
@@ -29,7 +49,7 @@ func NewCustomFormatterWithColor(enableColor bool) *CustomFormatter {
 // Format 实现logrus.Formatter接口
 func (f *CustomFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	// 格式化时间戳
-	timestamp := entry.Time.Format("2006-01-02 15:04:05.000")
+	timestamp := entry.Time.In(Location()).Format("2006-01-02 15:04:05.000")
 
 	// 获取日志级别的颜色
 	var levelColor int
@@ -60,12 +80,17 @@ func (f *CustomFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	// 构建字段信息（排除 source 字段）
 	var fieldsText string
 	if len(entry.Data) > 0 {
-		var fieldPairs []string
-		for key, value := range entry.Data {
+		keys := make([]string, 0, len(entry.Data))
+		for key := range entry.Data {
 			if key != "source" { // 排除 source 字段
-				fieldPairs = append(fieldPairs, fmt.Sprintf("%s=%v", key, value))
+				keys = append(keys, key)
 			}
 		}
+		sort.Strings(keys)
+		var fieldPairs []string
+		for _, key := range keys {
+			fieldPairs = append(fieldPairs, fmt.Sprintf("%s=%v", key, entry.Data[key]))
+		}
 		if len(fieldPairs) > 0 {
 			fieldsText = fmt.Sprintf(" [%s]", strings.Join(fieldPairs, ", "))
 		}
@@ -84,7 +109,7 @@ func (f *CustomFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 // InitLogger 初始化日志配置
 func InitLogger() {
 	// 设置日志输出为标准输出
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(consoleOutput())
 
 	// 启用调用者信息报告
 	logrus.SetReportCaller(true)
@@ -96,6 +121,25 @@ func InitLogger() {
 	logrus.SetLevel(logrus.InfoLevel)
 }
 
+// SetLevel 设置日志级别
+func SetLevel(level string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("无效的日志级别 '%s': %v", level, err)
+	}
+	logrus.SetLevel(lvl)
+	return nil
+}
+
+// consoleOutput 返回控制台日志的输出位置
+// 标准输出被重定向或接入管道时日志改写到标准错误，避免混入 DNS 记录输出
+func consoleOutput() *os.File {
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
 // RotatingFileWriter 实现日志文件轮转的写入器
 type RotatingFileWriter struct {
 	filename    string
@@ -283,78 +327,85 @@ func removeANSIColors(data []byte) []byte {
 
 // ConfigureLogger 根据配置重新设置日志系统
 func ConfigureLogger(logLevel, logFile string, maxSizeMB, maxFiles int) error {
-	// 设置日志级别
-	if logLevel != "" {
-		level, err := logrus.ParseLevel(logLevel)
-		if err != nil {
-			return fmt.Errorf("无效的日志级别 '%s': %v", logLevel, err)
-		}
-		logrus.SetLevel(level)
-	}
-
-	// 设置日志输出
-	if logFile != "" {
-		// 创建轮转文件写入器
-		fileWriter, err := NewRotatingFileWriter(logFile, maxSizeMB, maxFiles)
-		if err != nil {
-			return err
-		}
-
-		// 创建多格式化器写入器，控制台带颜色，文件无颜色
-		multiWriter := &MultiFormatterWriter{
-			consoleWriter: os.Stdout,
-			fileWriter:    fileWriter,
-		}
-		logrus.SetOutput(multiWriter)
-
-		// 使用带颜色的格式化器（颜色代码会在写入文件时被去除）
-		logrus.SetFormatter(NewCustomFormatterWithColor(true))
-	} else {
-		// 如果没有指定日志文件，只输出到控制台，使用带颜色的格式化器
-		logrus.SetOutput(os.Stdout)
-		logrus.SetFormatter(NewCustomFormatterWithColor(true))
-	}
-
-	return nil
+	return Configure(Options{
+		Level:     logLevel,
+		File:      logFile,
+		MaxSizeMB: maxSizeMB,
+		MaxFiles:  maxFiles,
+	})
 }
 
-// mergeFields 合并用户字段
-func mergeFields(userFields ...logrus.Fields) logrus.Fields {
-	var fields logrus.Fields
-	if len(userFields) > 0 {
-		fields = make(logrus.Fields)
-		for k, v := range userFields[0] {
+// mergeFields 合并固定字段与用户字段，后者优先
+func mergeFields(base logrus.Fields, userFields ...logrus.Fields) logrus.Fields {
+	fields := make(logrus.Fields, len(base))
+	for k, v := range base {
+		fields[k] = v
+	}
+	for _, f := range userFields {
+		for k, v := range f {
 			fields[k] = v
 		}
-	} else {
-		fields = make(logrus.Fields)
 	}
-
 	return fields
 }
 
 // 提供便捷的日志记录方法
 func Debug(msg string, fields ...logrus.Fields) {
-	mergedFields := mergeFields(fields...)
+	mergedFields := mergeFields(nil, fields...)
 	logrus.WithFields(mergedFields).Debug(msg)
 }
 
 func Info(msg string, fields ...logrus.Fields) {
-	mergedFields := mergeFields(fields...)
+	mergedFields := mergeFields(nil, fields...)
 	logrus.WithFields(mergedFields).Info(msg)
 }
 
 func Warn(msg string, fields ...logrus.Fields) {
-	mergedFields := mergeFields(fields...)
+	mergedFields := mergeFields(nil, fields...)
 	logrus.WithFields(mergedFields).Warn(msg)
 }
 
 func Error(msg string, fields ...logrus.Fields) {
-	mergedFields := mergeFields(fields...)
+	mergedFields := mergeFields(nil, fields...)
 	logrus.WithFields(mergedFields).Error(msg)
 }
 
 func Fatal(msg string, fields ...logrus.Fields) {
-	mergedFields := mergeFields(fields...)
+	mergedFields := mergeFields(nil, fields...)
 	logrus.WithFields(mergedFields).Fatal(msg)
 }
+
+// Logger 带固定字段的日志记录器，用于区分日志来源，便于按字段检索
+type Logger struct {
+	fields logrus.Fields
+}
+
+// Component 返回带 component 字段的日志记录器
+func Component(name string) *Logger {
+	return &Logger{fields: logrus.Fields{"component": name}}
+}
+
+// With 返回增加了一个固定字段的日志记录器
+func (l *Logger) With(key string, value any) *Logger {
+	return &Logger{fields: mergeFields(l.fields, logrus.Fields{key: value})}
+}
+
+func (l *Logger) Debug(msg string, fields ...logrus.Fields) {
+	logrus.WithFields(mergeFields(l.fields, fields...)).Debug(msg)
+}
+
+func (l *Logger) Info(msg string, fields ...logrus.Fields) {
+	logrus.WithFields(mergeFields(l.fields, fields...)).Info(msg)
+}
+
+func (l *Logger) Warn(msg string, fields ...logrus.Fields) {
+	logrus.WithFields(mergeFields(l.fields, fields...)).Warn(msg)
+}
+
+func (l *Logger) Error(msg string, fields ...logrus.Fields) {
+	logrus.WithFields(mergeFields(l.fields, fields...)).Error(msg)
+}
+
+func (l *Logger) Fatal(msg string, fields ...logrus.Fields) {
+	logrus.WithFields(mergeFields(l.fields, fields...)).Fatal(msg)
+}